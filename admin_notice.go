@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+	"github.com/wyg1997/LedgerBot/pkg/version"
+)
+
+// adminPingTimeout 启动自检中检查 AI 模型的超时时间
+const adminPingTimeout = 10 * time.Second
+
+// adminNotifier 向 ADMIN_OPEN_ID 发送启动自检、停机通知和错误告警，未配置时不发送；发送失败只记录日志
+type adminNotifier struct {
+	openID string
+	feishu *feishu.FeishuService
+	text   adminNoticeText
+	log    logger.Logger
+}
+
+// adminNoticeText 通知各行的模板
+type adminNoticeText struct {
+	started     string
+	version     string
+	storage     string
+	bitable     string // 参数为 app_token 尾部和 table_id
+	wiki        string
+	schemaError string
+	schemaOK    string
+	aiDown      string // 参数为模型和错误
+	aiUp        string // 参数为模型
+	shutdown    string
+}
+
+// adminNoticeTexts 各语言的通知模板，未知语言使用中文
+var adminNoticeTexts = map[string]adminNoticeText{
+	"zh": {
+		started:     "✅ 记账机器人已启动",
+		version:     "版本：",
+		storage:     "存储：",
+		bitable:     "多维表格：app_token %s，table_id %s",
+		wiki:        "（wiki 链接）",
+		schemaError: "字段校验：⚠️ ",
+		schemaOK:    "字段校验：通过",
+		aiDown:      "AI 模型：⚠️ %s 不可用：%v",
+		aiUp:        "AI 模型：%s 可用",
+		shutdown:    "🛑 记账机器人正在停止：",
+	},
+	"en": {
+		started:     "✅ LedgerBot started",
+		version:     "Version: ",
+		storage:     "Storage: ",
+		bitable:     "Bitable: app_token %s, table_id %s",
+		wiki:        " (wiki link)",
+		schemaError: "Field check: ⚠️ ",
+		schemaOK:    "Field check: passed",
+		aiDown:      "AI model: ⚠️ %s unavailable: %v",
+		aiUp:        "AI model: %s available",
+		shutdown:    "🛑 LedgerBot is stopping: ",
+	},
+}
+
+func newAdminNotifier(openID, language string, feishuService *feishu.FeishuService) *adminNotifier {
+	text, ok := adminNoticeTexts[language]
+	if !ok {
+		text = adminNoticeTexts["zh"]
+	}
+	return &adminNotifier{openID: openID, feishu: feishuService, text: text, log: logger.GetLogger("admin")}
+}
+
+// send 私聊发送给管理员
+func (n *adminNotifier) send(ctx context.Context, text string) {
+	if n.openID == "" {
+		return
+	}
+	if err := n.feishu.SendMessage(ctx, n.openID, text); err != nil {
+		n.log.Warn("Failed to send admin notice: %v", err)
+	}
+}
+
+// NotifyStartup 汇总版本、存储、多维表格、字段校验和 AI 模型的状态发送给管理员
+func (n *adminNotifier) NotifyStartup(ctx context.Context, cfg *config.Config, bitable repository.BitableInfo, aiService domain.AIService) {
+	if n.openID == "" {
+		return
+	}
+
+	lines := []string{n.text.started, n.text.version + version.Get().String(), n.text.storage + cfg.Storage.Backend}
+	table := fmt.Sprintf(n.text.bitable, tokenSuffix(bitable.AppToken), bitable.TableID)
+	if bitable.IsWiki {
+		table += n.text.wiki
+	}
+	lines = append(lines, table)
+	if bitable.SchemaErr != nil {
+		lines = append(lines, n.text.schemaError+bitable.SchemaErr.Error())
+	} else {
+		lines = append(lines, n.text.schemaOK)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, adminPingTimeout)
+	defer cancel()
+	if err := aiService.Ping(pingCtx); err != nil {
+		lines = append(lines, fmt.Sprintf(n.text.aiDown, cfg.AI.Model, err))
+	} else {
+		lines = append(lines, fmt.Sprintf(n.text.aiUp, cfg.AI.Model))
+	}
+
+	n.send(ctx, strings.Join(lines, "\n"))
+}
+
+// NotifyShutdown 通知管理员机器人正在停止，reason 为停止原因
+func (n *adminNotifier) NotifyShutdown(ctx context.Context, reason string) {
+	n.send(ctx, n.text.shutdown+reason)
+}
+
+// tokenSuffix 只显示 app_token 的末几位，如 "…Xy9z"
+func tokenSuffix(token string) string {
+	const n = 6
+	if len(token) <= n {
+		return token
+	}
+	return "…" + token[len(token)-n:]
+}
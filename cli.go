@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+	"github.com/wyg1997/LedgerBot/internal/usecase"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// cliUserID 命令行模式下固定的用户 ID
+const cliUserID = "cli-user"
+
+// runChat 命令行对话模式：从标准输入逐行读取消息交给 AI 处理，账单只保存在内存中
+// 只需要配置 AI，不连接飞书，便于本地调试提示词和工具调用
+func runChat(cfg *config.Config) {
+	if cfg.AI.APIKey == "" {
+		fmt.Fprintln(os.Stderr, "Invalid configuration: ai: AI_API_KEY: AI API key is required (e.g. AI_API_KEY=sk-xxxxxxxx)")
+		os.Exit(1)
+	}
+
+	logFile, err := setupLogging(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+		os.Exit(1)
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
+	repository.SetCustomRangeLookbackDays(cfg.AI.QueryLookbackDays)
+
+	ctx := logger.WithCorrelationID(context.Background(), "cli")
+
+	aiService := ai.NewOpenAIService(&cfg.AI, cfg.Server.Location, nil)
+	userMappingRepo, err := repository.NewUserMappingRepository("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create user mapping repository: %v\n", err)
+		os.Exit(1)
+	}
+	installments, err := repository.NewInstallmentRepository("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create installment repository: %v\n", err)
+		os.Exit(1)
+	}
+	loans, err := repository.NewLoanRepository("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create loan repository: %v\n", err)
+		os.Exit(1)
+	}
+	journal, err := repository.NewOperationJournal("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create operation journal: %v\n", err)
+		os.Exit(1)
+	}
+	templates, err := repository.NewTemplateRepository("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create template repository: %v\n", err)
+		os.Exit(1)
+	}
+	billUseCase := usecase.NewBillUseCase(repository.NewMemoryBillRepository(cfg.Server.Location), userMappingRepo, aiService, nil, 0, usecase.DuplicatePolicy{
+		Window:       time.Duration(cfg.Storage.DuplicateWindowMinutes) * time.Minute,
+		RecordAnyway: cfg.Storage.DuplicateRecordAnyway,
+	}, usecase.ValidationPolicy{
+		MaxAmount:            cfg.Storage.MaxBillAmount,
+		MaxDescriptionLength: cfg.Storage.MaxDescriptionLength,
+	}, installments, nil, loans, journal, templates, cfg.Server.Location)
+
+	renameService := ai.NewRenameService(func(name string) (string, error) {
+		if err := userMappingRepo.SetUserName(domain.PlatformCLI, cliUserID, name); err != nil {
+			return "", err
+		}
+		return userMappingRepo.GetUserName(domain.PlatformCLI, cliUserID)
+	})
+
+	fmt.Println("LedgerBot 命令行模式，账单只保存在内存中，输入 exit 退出")
+
+	// 整个会话视为一个话题，历史消息随每次请求发送
+	var history []domain.AIMessage
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			break
+		}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if text == "exit" || text == "quit" {
+			break
+		}
+
+		userName, _ := userMappingRepo.GetUserName(domain.PlatformCLI, cliUserID)
+		prefs, _ := userMappingRepo.GetPreferences(domain.PlatformCLI, cliUserID)
+		reqCtx := domain.WithPreferences(ctx, prefs, func(prefs domain.UserPreferences) error {
+			return userMappingRepo.SetPreferences(domain.PlatformCLI, cliUserID, prefs)
+		})
+		billService := ai.NewBillService(reqCtx, billUseCase, cliUserID, userName, text)
+
+		var reply string
+		handled := false
+		if confirmed, ok := ai.ParseConfirmationReply(text); ok {
+			reply, handled, err = aiService.ResolveConfirmation(cliUserID, confirmed, billService, renameService)
+		} else if ai.IsMoreReply(text) {
+			reply, handled, err = aiService.MoreResults(cliUserID, billService)
+		}
+		if !handled {
+			history = append(history, domain.AIMessage{Role: "user", Content: text})
+			reply, err = aiService.Execute(reqCtx, text, userName, cliUserID, billService, renameService, history)
+			history = append(history, domain.AIMessage{Role: "assistant", Content: reply})
+		}
+		if err != nil {
+			reply = fmt.Sprintf("AI处理失败：%v", err)
+		}
+		fmt.Println(reply)
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read input: %v\n", err)
+		os.Exit(1)
+	}
+}
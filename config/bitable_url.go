@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BitableLocation is the table a bitable URL points to
+type BitableLocation struct {
+	Token   string // wiki 链接为 node_token，base 链接为 app_token
+	TableID string
+	ViewID  string // 可选，分享链接中附带的视图
+	IsWiki  bool
+}
+
+// ParseBitableURL parses the bitable URL to extract token (node_token or app_token), table id and the optional view id.
+// 支持两种格式，协议可省略，查询参数顺序不限，token 之后的多余路径和 #锚点 会被忽略：
+// 1) base 链接: https://xxx.feishu.cn/base/APP_TOKEN?table=TABLE_ID
+// 2) wiki 链接: https://xxx.feishu.cn/wiki/NODE_TOKEN?table=TABLE_ID&view=...
+// 查询参数缺失时，也会从锚点中查找 table/view 参数（部分分享链接把参数放在 # 之后）
+func ParseBitableURL(bitableURL string) (BitableLocation, error) {
+	var loc BitableLocation
+	raw := strings.TrimSpace(bitableURL)
+	if raw == "" {
+		return loc, fmt.Errorf("bitable URL is empty")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return loc, fmt.Errorf("invalid bitable URL %q: %v", bitableURL, err)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	kind := -1
+	for i, segment := range segments {
+		if segment == "base" || segment == "wiki" {
+			kind = i
+			break
+		}
+	}
+	if kind < 0 {
+		return loc, fmt.Errorf("invalid bitable URL %q: path must contain /base/<app_token> or /wiki/<node_token>", bitableURL)
+	}
+	loc.IsWiki = segments[kind] == "wiki"
+	if kind+1 >= len(segments) || segments[kind+1] == "" {
+		return loc, fmt.Errorf("invalid bitable URL %q: missing token after /%s/", bitableURL, segments[kind])
+	}
+	loc.Token = segments[kind+1]
+
+	query := u.Query()
+	loc.TableID = query.Get("table")
+	loc.ViewID = query.Get("view")
+
+	// 锚点中的参数，如 #xxx?table=tblXXX 或 #table=tblXXX
+	if u.Fragment != "" && (loc.TableID == "" || loc.ViewID == "") {
+		fragment := u.Fragment
+		if i := strings.Index(fragment, "?"); i >= 0 {
+			fragment = fragment[i+1:]
+		}
+		if params, err := url.ParseQuery(fragment); err == nil {
+			if loc.TableID == "" {
+				loc.TableID = params.Get("table")
+			}
+			if loc.ViewID == "" {
+				loc.ViewID = params.Get("view")
+			}
+		}
+	}
+
+	if loc.TableID == "" {
+		return loc, fmt.Errorf("invalid bitable URL %q: missing table parameter (e.g. ?table=tblXXXX), open the table in the browser and copy the URL from the address bar", bitableURL)
+	}
+
+	return loc, nil
+}
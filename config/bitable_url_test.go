@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBitableURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want BitableLocation
+	}{
+		{"base", "https://example.feishu.cn/base/bascnAPP?table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"wiki with view", "https://example.feishu.cn/wiki/wikcnNODE?table=tblA&view=vewB", BitableLocation{Token: "wikcnNODE", TableID: "tblA", ViewID: "vewB", IsWiki: true}},
+		// 分享对话框复制的链接中 view 可能在 table 之前
+		{"view before table", "https://example.feishu.cn/base/bascnAPP?view=vewB&from=share&table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA", ViewID: "vewB"}},
+		{"without scheme", "example.feishu.cn/base/bascnAPP?table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"surrounding spaces", "  https://example.feishu.cn/base/bascnAPP?table=tblA\n", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"trailing slash", "https://example.feishu.cn/base/bascnAPP/?table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"extra path segments", "https://example.feishu.cn/base/bascnAPP/extra/seg?table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"path prefix", "https://example.larksuite.com/space/base/bascnAPP?table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"ignored fragment", "https://example.feishu.cn/base/bascnAPP?table=tblA#record=recX", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		// 查询参数缺失时从锚点中读取
+		{"table in fragment", "https://example.feishu.cn/wiki/wikcnNODE#table=tblA&view=vewB", BitableLocation{Token: "wikcnNODE", TableID: "tblA", ViewID: "vewB", IsWiki: true}},
+		{"query in fragment", "https://example.feishu.cn/base/bascnAPP#share?table=tblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		{"encoded question mark in fragment", "https://example.feishu.cn/base/bascnAPP#share%3Ftable%3DtblA", BitableLocation{Token: "bascnAPP", TableID: "tblA"}},
+		// 查询参数优先，锚点只补充缺失的 view
+		{"query over fragment", "https://example.feishu.cn/base/bascnAPP?table=tblA#table=tblZ&view=vewB", BitableLocation{Token: "bascnAPP", TableID: "tblA", ViewID: "vewB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBitableURL(tt.url)
+			if err != nil {
+				t.Fatalf("ParseBitableURL(%q) = %v", tt.url, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBitableURL(%q) = %+v, want %+v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBitableURLErrors(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string // 错误信息应包含的内容
+	}{
+		{"", "empty"},
+		{"   ", "empty"},
+		{"https://example.feishu.cn/docx/doxcnDOC?table=tblA", "/base/<app_token> or /wiki/<node_token>"},
+		{"https://example.feishu.cn/base/?table=tblA", "missing token after /base/"},
+		{"https://example.feishu.cn/wiki", "missing token after /wiki/"},
+		{"https://example.feishu.cn/base/bascnAPP", "missing table parameter"},
+		{"https://example.feishu.cn/base/bascnAPP?view=vewB#record=recX", "missing table parameter"},
+		{"https://example.feishu.cn/base/bascn%zz?table=tblA", "invalid URL escape"},
+	}
+	for _, tt := range tests {
+		_, err := ParseBitableURL(tt.url)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseBitableURL(%q) = %v, want an error containing %q", tt.url, err, tt.want)
+		}
+	}
+}
@@ -1,68 +1,280 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+	// 内置时区数据库，镜像中没有 /usr/share/zoneinfo 时也能加载 TIMEZONE
+	_ "time/tzdata"
+
+	"github.com/wyg1997/LedgerBot/pkg/logger"
 )
 
 type Config struct {
 	// Server configuration
-	Server ServerConfig
+	Server ServerConfig `yaml:"server"`
 
 	// Platform configurations
-	Feishu FeishuConfig
+	Platforms []string       `yaml:"platforms" env:"PLATFORMS"` // 启用的聊天平台：feishu、telegram
+	Feishu    FeishuConfig   `yaml:"feishu"`
+	Telegram  TelegramConfig `yaml:"telegram"`
 
 	// AI configuration
-	AI AIConfig
+	AI AIConfig `yaml:"ai"`
 
 	// Storage configuration
-	Storage StorageConfig
+	Storage StorageConfig `yaml:"storage"`
 
 	// Cache configuration
-	Cache CacheConfig
+	Cache CacheConfig `yaml:"cache"`
+
+	// Scheduled report configuration
+	Report ReportConfig `yaml:"report"`
+
+	// CSV import configuration
+	Import ImportConfig `yaml:"import"`
+
+	// 使用配置文件时未被环境变量覆盖的配置项：环境变量名 -> 文件中的键，校验错误中使用
+	names map[string]string
+	// 读取 <VAR>_FILE 指定的密钥文件失败的配置项
+	secretFileErrs []secretFileError
 }
 
 type ServerConfig struct {
-	Port         string
-	ReadTimeout  int    // seconds
-	WriteTimeout int    // seconds
+	Port         string `yaml:"port" env:"SERVER_PORT"`
+	ReadTimeout  int    `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`   // seconds
+	WriteTimeout int    `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"` // seconds
+	Workers      int    `yaml:"workers" env:"WORKER_POOL_SIZE"`           // 同时处理消息的数量
+	QueueSize    int    `yaml:"queue_size" env:"WORKER_QUEUE_SIZE"`       // 等待处理的消息队列长度，队列满时回复繁忙提示
+	APIToken     string `yaml:"api_token" env:"API_TOKEN,secret"`         // REST API 的 Bearer 令牌，为空时不开放 /api/v1
+	// 就绪检查 /health/ready 结果的缓存时间（秒），以及是否检查 AI 服务
+	HealthCheckTTL int  `yaml:"health_check_ttl" env:"HEALTH_CHECK_TTL"`
+	HealthCheckAI  bool `yaml:"health_check_ai" env:"HEALTH_CHECK_AI"`
+	// 开启 pprof 和 /debug/stats 诊断接口；DebugAddr 为单独监听的地址，为空时挂在主端口上
+	DebugEndpoints bool   `yaml:"debug_endpoints" env:"DEBUG_ENDPOINTS"`
+	DebugAddr      string `yaml:"debug_addr" env:"DEBUG_ADDR"`
+	// 日期、时间范围和定时任务使用的时区（IANA 名称），传给各组件，不修改进程的本地时区
+	Timezone    string         `yaml:"timezone" env:"TIMEZONE"`
+	Location    *time.Location `yaml:"-"`
+	locationErr error
 }
 
 type FeishuConfig struct {
-	AppID        string
-	AppSecret    string
-	BitableURL   string // 多维表格URL，格式：https://example.feishu.cn/base/APP_TOKEN?table=TABLE_TOKEN
-	EncryptKey   string // 可选的加密密钥
-	Verification string // 可选的验证 token
-	BotName      string // Bot名称，用于识别@提及
+	AppID        string `yaml:"app_id" env:"FEISHU_APP_ID"`
+	AppSecret    string `yaml:"app_secret" env:"FEISHU_APP_SECRET,secret"`
+	APIBaseURL   string `yaml:"api_base_url" env:"FEISHU_API_BASE_URL"`              // 开放平台地址，Lark 国际版为 https://open.larksuite.com
+	BitableURL   string `yaml:"bitable_url" env:"FEISHU_BITABLE_URL"`                // 多维表格URL，格式：https://example.feishu.cn/base/APP_TOKEN?table=TABLE_TOKEN
+	EncryptKey   string `yaml:"encrypt_key" env:"FEISHU_ENCRYPT_KEY,secret"`         // 可选的加密密钥
+	Verification string `yaml:"verification" env:"FEISHU_VERIFICATION_TOKEN,secret"` // 可选的验证 token
+	BotName      string `yaml:"bot_name" env:"FEISHU_BOT_NAME"`                      // Bot名称，用于识别@提及
+	// 事件接收方式：webhook（默认，需要公网地址）或 websocket（长连接）
+	ConnectionMode string `yaml:"connection_mode" env:"FEISHU_CONNECTION_MODE"`
+	CardReplies    bool   `yaml:"card_replies" env:"FEISHU_CARD_REPLIES"`   // 记账结果使用带“撤销/改分类”按钮的交互卡片回复，关闭时使用文本
+	SharedLedger   bool   `yaml:"shared_ledger" env:"FEISHU_SHARED_LEDGER"` // 家庭共享账本：查询时不按记录者过滤，所有人看到全部账单
+	// 按群开启的共享账本（chat_id 列表），群内的查询汇总所有成员的账单，并支持按成员统计和 AA 结算
+	SharedChats []string `yaml:"shared_chats" env:"FEISHU_SHARED_CHATS"`
+	// 查询时最多拉取的记录数，防止异常情况下无限翻页
+	SearchMaxRecords int `yaml:"search_max_records" env:"FEISHU_SEARCH_MAX_RECORDS"`
+	// 处理消息期间添加的表情（如 OnIt），设为 none 关闭；回复后撤销，并按 DoneReaction 换成完成表情
+	ProcessingReaction string `yaml:"processing_reaction" env:"FEISHU_PROCESSING_REACTION"`
+	DoneReaction       string `yaml:"done_reaction" env:"FEISHU_DONE_REACTION"`
+	// 读取话题历史时最多拉取的消息数
+	ThreadHistoryMaxMessages int `yaml:"thread_history_max_messages" env:"FEISHU_THREAD_HISTORY_MAX"`
+	// 启动时多维表格字段校验失败是否拒绝启动，关闭时仅输出警告
+	SchemaStrict bool `yaml:"schema_strict" env:"FEISHU_SCHEMA_STRICT"`
+	// 启动时自动创建缺失的字段和单选选项
+	AutoCreateFields bool `yaml:"auto_create_fields" env:"FEISHU_AUTO_CREATE_FIELDS"`
+	// 缓存 wiki 链接解析出的 app_token，关闭后每次启动都调用 wiki 接口，便于排查问题
+	WikiTokenCache bool `yaml:"wiki_token_cache" env:"FEISHU_WIKI_TOKEN_CACHE"`
+	// 软删除：删除账单时只填写删除时间字段，保留期内可以恢复，过期后由后台真正删除
+	SoftDelete              bool `yaml:"soft_delete" env:"FEISHU_SOFT_DELETE"`
+	SoftDeleteRetentionDays int  `yaml:"soft_delete_retention_days" env:"FEISHU_SOFT_DELETE_RETENTION_DAYS"`
+	// 快捷命令前缀（如 /今天），以此开头的已知命令不经过 AI 直接处理
+	CommandPrefix string `yaml:"command_prefix" env:"FEISHU_COMMAND_PREFIX"`
+	// 管理员的 open_id 列表（其他平台的用户写作 telegram:<id>），可以使用 /admin 命令管理用户
+	AdminOpenIDs []string `yaml:"admin_open_ids" env:"ADMIN_OPEN_IDS"`
+	// 接收启动自检和停机通知的管理员 open_id，为空时不发送
+	AdminOpenID string `yaml:"admin_open_id" env:"ADMIN_OPEN_ID"`
+	// 错误告警：AlertWindowMinutes 分钟内的错误日志达到 AlertErrorThreshold 条时通知 AdminOpenID，0 表示不告警
+	AlertErrorThreshold int `yaml:"alert_error_threshold" env:"ALERT_ERROR_THRESHOLD"`
+	AlertWindowMinutes  int `yaml:"alert_window_minutes" env:"ALERT_WINDOW_MINUTES"`
+	// 两次告警之间至少间隔的分钟数
+	AlertCooldownMinutes int `yaml:"alert_cooldown_minutes" env:"ALERT_COOLDOWN_MINUTES"`
+	// 群聊独立账本：chat_id -> 多维表格 URL，未配置的会话使用 BitableURL
+	ChatTables    map[string]string `yaml:"chat_tables" env:"FEISHU_CHAT_TABLES"`
+	chatTablesErr error
 	// 多维表格字段名配置
-	FieldDescription string // 描述字段名
-	FieldAmount      string // 金额字段名
-	FieldType        string // 类型字段名(Income/Expense)
-	FieldCategory    string // 分类字段名
-	FieldDate        string // 日期字段名
-	FieldUserName    string // 用户名字段名
-	FieldOriginalMsg string // 原始消息字段名
+	FieldDescription  string `yaml:"field_description" env:"FEISHU_FIELD_DESCRIPTION"`     // 描述字段名
+	FieldAmount       string `yaml:"field_amount" env:"FEISHU_FIELD_AMOUNT"`               // 金额字段名
+	FieldType         string `yaml:"field_type" env:"FEISHU_FIELD_TYPE"`                   // 类型字段名(Income/Expense)
+	FieldCategory     string `yaml:"field_category" env:"FEISHU_FIELD_CATEGORY"`           // 分类字段名
+	FieldDate         string `yaml:"field_date" env:"FEISHU_FIELD_DATE"`                   // 日期字段名
+	FieldUserName     string `yaml:"field_user_name" env:"FEISHU_FIELD_USER_NAME"`         // 用户名字段名
+	FieldOriginalMsg  string `yaml:"field_original_msg" env:"FEISHU_FIELD_ORIGINAL_MSG"`   // 原始消息字段名
+	FieldDeletedAt    string `yaml:"field_deleted_at" env:"FEISHU_FIELD_DELETED_AT"`       // 删除时间字段名，仅软删除时使用
+	FieldAttachment   string `yaml:"field_attachment" env:"FEISHU_FIELD_ATTACHMENT"`       // 附件字段名，为空时不上传收据图片
+	FieldAccount      string `yaml:"field_account" env:"FEISHU_FIELD_ACCOUNT"`             // 支付账户字段名，为空时不记录账户
+	FieldTags         string `yaml:"field_tags" env:"FEISHU_FIELD_TAGS"`                   // 标签字段名（多选），为空时不记录标签
+	FieldReimbursable string `yaml:"field_reimbursable" env:"FEISHU_FIELD_REIMBURSABLE"`   // 可报销字段名（复选框），为空时不记录
+	FieldReimbursedAt string `yaml:"field_reimbursed_at" env:"FEISHU_FIELD_REIMBURSED_AT"` // 报销到账时间字段名（日期），为空时不记录
 }
 
+type TelegramConfig struct {
+	BotToken      string `yaml:"bot_token" env:"TELEGRAM_BOT_TOKEN,secret"`           // BotFather 分配的机器人令牌
+	WebhookSecret string `yaml:"webhook_secret" env:"TELEGRAM_WEBHOOK_SECRET,secret"` // setWebhook 时设置的 secret_token，用于校验推送来源
+	APIBaseURL    string `yaml:"api_base_url" env:"TELEGRAM_API_BASE_URL"`            // Bot API 地址，可指向自建的 Bot API 服务
+}
+
+// 支持的聊天平台
+const (
+	PlatformFeishu   = "feishu"
+	PlatformTelegram = "telegram"
+)
+
+// 账单存储方式
+const (
+	StorageBackendBitable = "bitable"
+	StorageBackendDual    = "dual"
+)
+
+// 用户映射和偏好的存储方式
+const (
+	UserStoreJSON = "json"
+	UserStoreBolt = "bolt"
+)
+
+// 缓存存储方式
+const (
+	CacheBackendFile  = "file"
+	CacheBackendRedis = "redis"
+)
+
+// 日志输出格式
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// 用户名与其他用户重复时的处理方式
+const (
+	UserNameConflictReject = "reject"
+	UserNameConflictSuffix = "suffix"
+)
+
+// 飞书事件接收方式
+const (
+	ConnectionModeWebhook   = "webhook"
+	ConnectionModeWebSocket = "websocket"
+)
 
 type AIConfig struct {
-	BaseURL string
-	APIKey  string
-	Model   string
+	BaseURL     string `yaml:"base_url" env:"AI_BASE_URL"`
+	APIKey      string `yaml:"api_key" env:"AI_API_KEY,secret"`
+	Model       string `yaml:"model" env:"AI_MODEL"`
+	VisionModel string `yaml:"vision_model" env:"AI_VISION_MODEL"` // 识别收据图片的多模态模型，为空时使用 Model
+	// 语音转写配置（OpenAI 兼容的 /v1/audio/transcriptions），BaseURL/APIKey 为空时复用上面的配置
+	TranscriptionBaseURL string `yaml:"transcription_base_url" env:"AI_TRANSCRIPTION_BASE_URL"`
+	TranscriptionAPIKey  string `yaml:"transcription_api_key" env:"AI_TRANSCRIPTION_API_KEY,secret"`
+	TranscriptionModel   string `yaml:"transcription_model" env:"AI_TRANSCRIPTION_MODEL"`
+	Language             string `yaml:"language" env:"AI_LANGUAGE"`                         // 回复语言：zh（默认）或 en
+	CurrencySymbol       string `yaml:"currency_symbol" env:"AI_CURRENCY_SYMBOL"`           // 回复中金额使用的货币符号
+	MaxToolCalls         int    `yaml:"max_tool_calls" env:"AI_MAX_TOOL_CALLS"`             // 单条消息最多执行的工具调用数量，<=0 表示不限制
+	MaxHistoryMessages   int    `yaml:"max_history_messages" env:"AI_MAX_HISTORY_MESSAGES"` // 发送给模型的话题历史消息上限，<=0 表示不限制
+	// 确认流程配置
+	ConfirmAmountThreshold float64 `yaml:"confirm_amount_threshold" env:"AI_CONFIRM_AMOUNT_THRESHOLD"` // 金额超过该值时需要确认，<=0 表示不需要
+	ConfirmDeleteCount     int     `yaml:"confirm_delete_count" env:"AI_CONFIRM_DELETE_COUNT"`         // 单条消息删除超过该数量时需要确认，<=0 表示不需要
+	ConfirmTTL             int     `yaml:"confirm_ttl" env:"AI_CONFIRM_TTL"`                           // 待确认操作的有效期（秒）
+	MaxBatchDelete         int     `yaml:"max_batch_delete" env:"AI_MAX_BATCH_DELETE"`                 // 按条件批量删除的上限，匹配的记录超过该数量时直接拒绝
+	// 查询配置
+	QueryLookbackDays int `yaml:"query_lookback_days" env:"AI_QUERY_LOOKBACK_DAYS"` // 自定义时间范围缺少开始时间时向前回溯的天数
+	// 每月支出预算，月末预测时与预测支出对比，<=0 表示未设置
+	MonthlyBudget float64 `yaml:"monthly_budget" env:"MONTHLY_BUDGET"`
+	// 共享账本 AA 结算时各成员的分摊权重（用户名 -> 权重），未配置的成员权重为 1
+	SplitWeights    map[string]float64 `yaml:"split_weights" env:"SPLIT_WEIGHTS"`
+	splitWeightsErr error
+	// 失败回复中附带飞书请求 ID 的末几位，便于排查问题时提供给飞书支持
+	ShowRequestID bool `yaml:"show_request_id" env:"AI_SHOW_REQUEST_ID"`
 }
 
 type StorageConfig struct {
-	DataDir  string // 数据存储目录
-	LogLevel string // 日志级别
+	DataDir  string `yaml:"data_dir" env:"DATA_DIR"`   // 数据存储目录
+	LogLevel string `yaml:"log_level" env:"LOG_LEVEL"` // 日志级别
+	// 按组件覆盖的日志级别，如 feishu=debug,ai=info,default=warn
+	LogLevels string `yaml:"log_levels" env:"LOG_LEVELS"`
+	// 日志格式：text 为一行文本；json 每行一个 JSON 对象，附加的字段作为独立的键
+	LogFormat string `yaml:"log_format" env:"LOG_FORMAT"`
+	// 日志文件路径，为空时只输出到标准错误
+	LogFile string `yaml:"log_file" env:"LOG_FILE"`
+	// 日志文件超过该大小（MB）时轮转，0 表示不轮转
+	LogMaxSizeMB int `yaml:"log_max_size_mb" env:"LOG_MAX_SIZE"`
+	// 最多保留的旧日志文件数，0 表示不限
+	LogMaxBackups int `yaml:"log_max_backups" env:"LOG_MAX_BACKUPS"`
+	// 旧日志文件保留的天数，0 表示不限
+	LogMaxAgeDays int `yaml:"log_max_age_days" env:"LOG_MAX_AGE"`
+	// 写日志文件时是否同时输出到标准错误
+	LogStderr bool `yaml:"log_stderr" env:"LOG_STDERR"`
+	// 日志中的飞书用户 ID 只保留前缀，Debug 日志中的用户名以哈希代替；密钥始终遮盖
+	LogRedactPII bool `yaml:"log_redact_pii" env:"LOG_REDACT_PII"`
+	// 消息与所建账单的对应关系保留天数，期间重复处理同一条消息不会重复记账
+	IdempotencyDays int `yaml:"idempotency_days" env:"IDEMPOTENCY_TTL_DAYS"`
+	// 检测重复记账的时间窗口（分钟），0 表示不检测
+	DuplicateWindowMinutes int `yaml:"duplicate_window_minutes" env:"DUPLICATE_WINDOW_MINUTES"`
+	// 检测到疑似重复时仍然记账，只在回复中提示；默认跳过并请用户确认
+	DuplicateRecordAnyway bool `yaml:"duplicate_record_anyway" env:"DUPLICATE_RECORD_ANYWAY"`
+	// 单笔账单金额上限，超过时需用户确认，0 表示不限制
+	MaxBillAmount float64 `yaml:"max_bill_amount" env:"BILL_MAX_AMOUNT"`
+	// 账单描述最多保留的字数，超出部分截断
+	MaxDescriptionLength int `yaml:"max_description_length" env:"BILL_MAX_DESCRIPTION_LENGTH"`
+	// 账单存储方式：bitable 只用多维表格；dual 同时写入本地库，查询统计从本地库读取
+	Backend string `yaml:"backend" env:"STORAGE_BACKEND"`
+	// dual 模式下启动时与多维表格对账的天数，0 表示不对账
+	ReconcileDays int `yaml:"reconcile_days" env:"STORAGE_RECONCILE_DAYS"`
+	// 相同查询的结果缓存的秒数，记账、修改、删除后立即失效；0 表示不缓存
+	QueryCacheSeconds int `yaml:"query_cache_seconds" env:"QUERY_CACHE_TTL"`
+	// 用户映射和偏好的存储方式：json 为 DATA_DIR/user_mapping.json；bolt 为 DATA_DIR/users.db
+	UserStoreBackend string `yaml:"user_store_backend" env:"USER_STORE_BACKEND"`
+	// 用户名已被其他用户使用时：reject 拒绝并给出建议；suffix 自动加上编号后缀
+	UserNameConflict string `yaml:"user_name_conflict" env:"USER_NAME_CONFLICT"`
 }
 
 type CacheConfig struct {
-	TTL          int  // 缓存过期时间（秒）
-	CleanUpIntvl int  // 清理间隔（秒）
+	TTL          int `yaml:"ttl" env:"CACHE_TTL"`                  // 缓存过期时间（秒）
+	CleanUpIntvl int `yaml:"cleanup_interval" env:"CACHE_CLEANUP"` // 清理间隔（秒）
+	MaxEntries   int `yaml:"max_entries" env:"CACHE_MAX_ENTRIES"`  // 每个缓存最多保存的条数，超过时淘汰最久未使用的
+	// 缓存存储方式：file 为进程内缓存并持久化到 DATA_DIR；redis 在多个副本之间共享
+	Backend       string `yaml:"backend" env:"CACHE_BACKEND"`
+	RedisAddr     string `yaml:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword string `yaml:"redis_password" env:"REDIS_PASSWORD,secret"`
+	RedisDB       int    `yaml:"redis_db" env:"REDIS_DB"`
+}
+
+type ReportConfig struct {
+	DailyAt string `yaml:"daily_at" env:"REPORT_DAILY_AT"` // 每日账单汇总的发送时间（HH:MM），为空时不发送
+	ChatID  string `yaml:"chat_id" env:"REPORT_CHAT_ID"`   // 日报发送到的群聊 chat_id，为空时私聊发送给每个用户
+	// 周期记账每天的执行时间（HH:MM），按 TIMEZONE 时区计算
+	RecurringAt string `yaml:"recurring_at" env:"RECURRING_RUN_AT"`
 }
 
+type ImportConfig struct {
+	// 超过该行数的导入需要用户回复“确认”后才执行，<=0 表示不需要
+	ConfirmRows int `yaml:"confirm_rows" env:"IMPORT_CONFIRM_ROWS"`
+	// 自定义列名映射：date/description/amount/type/category -> CSV 表头，未配置的字段使用内置的常见列名
+	Columns    map[string]string `yaml:"columns" env:"IMPORT_COLUMNS"`
+	columnsErr error
+}
+
+// exampleBitableURL 校验错误中给出的多维表格链接示例
+const exampleBitableURL = "https://example.feishu.cn/base/APP_TOKEN?table=TABLE_ID"
+
+// importFields 可以在 IMPORT_COLUMNS 中配置列名的字段
+var importFields = []string{"date", "description", "amount", "type", "category"}
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() *Config {
 	// Try to load .env file before reading config
@@ -71,19 +283,74 @@ func LoadConfig() *Config {
 		log.Printf("Failed to load .env file: %v", err)
 	}
 
-	return &Config{
+	importColumns, importColumnsErr := parseImportColumns(getEnv("IMPORT_COLUMNS", ""))
+	if importColumnsErr != nil {
+		log.Printf("Failed to parse IMPORT_COLUMNS: %v", importColumnsErr)
+	}
+
+	chatTables, chatTablesErr := loadChatTables(getEnv("FEISHU_CHAT_TABLES", ""))
+	if chatTablesErr != nil {
+		log.Printf("Failed to load FEISHU_CHAT_TABLES: %v", chatTablesErr)
+	}
+
+	splitWeights, splitWeightsErr := parseSplitWeights(getEnv("SPLIT_WEIGHTS", ""))
+	if splitWeightsErr != nil {
+		log.Printf("Failed to parse SPLIT_WEIGHTS: %v", splitWeightsErr)
+	}
+
+	timezone := getEnv("TIMEZONE", "Asia/Shanghai")
+	location, locationErr := time.LoadLocation(timezone)
+	if locationErr != nil {
+		log.Printf("Failed to load TIMEZONE: %v", locationErr)
+		location = time.Local
+	}
+
+	cfg := &Config{
 		Server: ServerConfig{
-			Port:         getEnv("SERVER_PORT", "8080"),
-			ReadTimeout:  getEnvAsInt("SERVER_READ_TIMEOUT", 30),
-			WriteTimeout: getEnvAsInt("SERVER_WRITE_TIMEOUT", 30),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			ReadTimeout:    getEnvAsInt("SERVER_READ_TIMEOUT", 30),
+			WriteTimeout:   getEnvAsInt("SERVER_WRITE_TIMEOUT", 30),
+			Workers:        getEnvAsInt("WORKER_POOL_SIZE", 8),
+			QueueSize:      getEnvAsInt("WORKER_QUEUE_SIZE", 100),
+			APIToken:       getEnv("API_TOKEN", ""),
+			HealthCheckTTL: getEnvAsInt("HEALTH_CHECK_TTL", 60),
+			HealthCheckAI:  getEnvAsBool("HEALTH_CHECK_AI", false),
+			DebugEndpoints: getEnvAsBool("DEBUG_ENDPOINTS", false),
+			DebugAddr:      getEnv("DEBUG_ADDR", "127.0.0.1:6060"),
+			Timezone:       timezone,
+			Location:       location,
+			locationErr:    locationErr,
 		},
+		Platforms: getEnvAsList("PLATFORMS", []string{PlatformFeishu}),
 		Feishu: FeishuConfig{
 			AppID:            getEnv("FEISHU_APP_ID", ""),
 			AppSecret:        getEnv("FEISHU_APP_SECRET", ""),
+			APIBaseURL:       getEnv("FEISHU_API_BASE_URL", "https://open.feishu.cn"),
 			BitableURL:       getEnv("FEISHU_BITABLE_URL", ""),
 			EncryptKey:       getEnv("FEISHU_ENCRYPT_KEY", ""),
 			Verification:     getEnv("FEISHU_VERIFICATION_TOKEN", ""),
 			BotName:          getEnv("FEISHU_BOT_NAME", "记账管家"),
+			ConnectionMode:   getEnv("FEISHU_CONNECTION_MODE", ConnectionModeWebhook),
+			CardReplies:      getEnvAsBool("FEISHU_CARD_REPLIES", true),
+			SharedLedger:     getEnvAsBool("FEISHU_SHARED_LEDGER", false),
+			SharedChats:      getEnvAsList("FEISHU_SHARED_CHATS", nil),
+			SearchMaxRecords: getEnvAsInt("FEISHU_SEARCH_MAX_RECORDS", 5000),
+			SchemaStrict:     getEnvAsBool("FEISHU_SCHEMA_STRICT", true),
+			ThreadHistoryMaxMessages: getEnvAsInt("FEISHU_THREAD_HISTORY_MAX", 200),
+			ProcessingReaction:       getEnv("FEISHU_PROCESSING_REACTION", "OnIt"),
+			CommandPrefix:            getEnv("FEISHU_COMMAND_PREFIX", "/"),
+			AdminOpenIDs:             getEnvAsList("ADMIN_OPEN_IDS", nil),
+			AdminOpenID:              getEnv("ADMIN_OPEN_ID", ""),
+			AlertErrorThreshold:      getEnvAsInt("ALERT_ERROR_THRESHOLD", 5),
+			AlertWindowMinutes:       getEnvAsInt("ALERT_WINDOW_MINUTES", 10),
+			AlertCooldownMinutes:     getEnvAsInt("ALERT_COOLDOWN_MINUTES", 60),
+			ChatTables:               chatTables,
+			chatTablesErr:            chatTablesErr,
+			DoneReaction:             getEnv("FEISHU_DONE_REACTION", ""),
+			AutoCreateFields: getEnvAsBool("FEISHU_AUTO_CREATE_FIELDS", false),
+			WikiTokenCache:   getEnvAsBool("FEISHU_WIKI_TOKEN_CACHE", true),
+			SoftDelete:              getEnvAsBool("FEISHU_SOFT_DELETE", false),
+			SoftDeleteRetentionDays: getEnvAsInt("FEISHU_SOFT_DELETE_RETENTION_DAYS", 30),
 			FieldDescription: getEnv("FEISHU_FIELD_DESCRIPTION", "描述"),
 			FieldAmount:      getEnv("FEISHU_FIELD_AMOUNT", "金额"),
 			FieldType:        getEnv("FEISHU_FIELD_TYPE", "分类"),
@@ -91,21 +358,88 @@ func LoadConfig() *Config {
 			FieldDate:        getEnv("FEISHU_FIELD_DATE", "日期"),
 			FieldUserName:    getEnv("FEISHU_FIELD_USER_NAME", "记录者"),
 			FieldOriginalMsg: getEnv("FEISHU_FIELD_ORIGINAL_MSG", "原始消息"),
+			FieldDeletedAt:   getEnv("FEISHU_FIELD_DELETED_AT", "删除时间"),
+			FieldAttachment:  getEnv("FEISHU_FIELD_ATTACHMENT", ""),
+			FieldAccount:     getEnv("FEISHU_FIELD_ACCOUNT", ""),
+			FieldTags:        getEnv("FEISHU_FIELD_TAGS", ""),
+			FieldReimbursable: getEnv("FEISHU_FIELD_REIMBURSABLE", ""),
+			FieldReimbursedAt: getEnv("FEISHU_FIELD_REIMBURSED_AT", ""),
+		},
+		Telegram: TelegramConfig{
+			BotToken:      getEnv("TELEGRAM_BOT_TOKEN", ""),
+			WebhookSecret: getEnv("TELEGRAM_WEBHOOK_SECRET", ""),
+			APIBaseURL:    getEnv("TELEGRAM_API_BASE_URL", "https://api.telegram.org"),
 		},
 		AI: AIConfig{
-			BaseURL: getEnv("AI_BASE_URL", "https://api.openai.com"),
-			APIKey:  getEnv("AI_API_KEY", ""),
-			Model:   getEnv("AI_MODEL", "gpt-3.5-turbo"),
+			BaseURL:        getEnv("AI_BASE_URL", "https://api.openai.com"),
+			APIKey:         getEnv("AI_API_KEY", ""),
+			Model:          getEnv("AI_MODEL", "gpt-3.5-turbo"),
+			VisionModel:    getEnv("AI_VISION_MODEL", ""),
+			// 语音转写配置
+			TranscriptionBaseURL: getEnv("AI_TRANSCRIPTION_BASE_URL", ""),
+			TranscriptionAPIKey:  getEnv("AI_TRANSCRIPTION_API_KEY", ""),
+			TranscriptionModel:   getEnv("AI_TRANSCRIPTION_MODEL", "whisper-1"),
+			Language:       getEnv("AI_LANGUAGE", "zh"),
+			CurrencySymbol: getEnv("AI_CURRENCY_SYMBOL", "¥"),
+			MaxToolCalls:   getEnvAsInt("AI_MAX_TOOL_CALLS", 10),
+			MaxHistoryMessages: getEnvAsInt("AI_MAX_HISTORY_MESSAGES", 30),
+
+			ConfirmAmountThreshold: getEnvAsFloat("AI_CONFIRM_AMOUNT_THRESHOLD", 5000),
+			ConfirmDeleteCount:     getEnvAsInt("AI_CONFIRM_DELETE_COUNT", 3),
+			ConfirmTTL:             getEnvAsInt("AI_CONFIRM_TTL", 300),
+			MaxBatchDelete:         getEnvAsInt("AI_MAX_BATCH_DELETE", 50),
+
+			QueryLookbackDays: getEnvAsInt("AI_QUERY_LOOKBACK_DAYS", 730),
+			MonthlyBudget:     getEnvAsFloat("MONTHLY_BUDGET", 0),
+			SplitWeights:      splitWeights,
+			splitWeightsErr:   splitWeightsErr,
+			ShowRequestID:     getEnvAsBool("AI_SHOW_REQUEST_ID", false),
 		},
 		Storage: StorageConfig{
 			DataDir:  getEnv("DATA_DIR", "./data"),
 			LogLevel: getEnv("LOG_LEVEL", "info"),
+			LogLevels: getEnv("LOG_LEVELS", ""),
+			LogFormat: strings.ToLower(getEnv("LOG_FORMAT", LogFormatText)),
+			LogFile:       getEnv("LOG_FILE", ""),
+			LogMaxSizeMB:  getEnvAsInt("LOG_MAX_SIZE", 100),
+			LogMaxBackups: getEnvAsInt("LOG_MAX_BACKUPS", 7),
+			LogMaxAgeDays: getEnvAsInt("LOG_MAX_AGE", 30),
+			LogStderr:     getEnvAsBool("LOG_STDERR", true),
+			LogRedactPII:  getEnvAsBool("LOG_REDACT_PII", false),
+			IdempotencyDays: getEnvAsInt("IDEMPOTENCY_TTL_DAYS", 7),
+			DuplicateWindowMinutes: getEnvAsInt("DUPLICATE_WINDOW_MINUTES", 10),
+			DuplicateRecordAnyway:  getEnvAsBool("DUPLICATE_RECORD_ANYWAY", false),
+			MaxBillAmount:          getEnvAsFloat("BILL_MAX_AMOUNT", 1000000),
+			MaxDescriptionLength:   getEnvAsInt("BILL_MAX_DESCRIPTION_LENGTH", 50),
+			Backend:         getEnv("STORAGE_BACKEND", StorageBackendBitable),
+			ReconcileDays:   getEnvAsInt("STORAGE_RECONCILE_DAYS", 30),
+			QueryCacheSeconds: getEnvAsInt("QUERY_CACHE_TTL", 60),
+			UserStoreBackend: getEnv("USER_STORE_BACKEND", UserStoreJSON),
+			UserNameConflict: getEnv("USER_NAME_CONFLICT", UserNameConflictReject),
 		},
 		Cache: CacheConfig{
 			TTL:          getEnvAsInt("CACHE_TTL", 3600),    // 1 hour
 			CleanUpIntvl: getEnvAsInt("CACHE_CLEANUP", 300), // 5 minutes
+			MaxEntries:   getEnvAsInt("CACHE_MAX_ENTRIES", 10000),
+			Backend:       getEnv("CACHE_BACKEND", CacheBackendFile),
+			RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
+			RedisPassword: getEnv("REDIS_PASSWORD", ""),
+			RedisDB:       getEnvAsInt("REDIS_DB", 0),
+		},
+		Report: ReportConfig{
+			DailyAt: getEnv("REPORT_DAILY_AT", ""),
+			ChatID:  getEnv("REPORT_CHAT_ID", ""),
+
+			RecurringAt: getEnv("RECURRING_RUN_AT", "09:00"),
+		},
+		Import: ImportConfig{
+			ConfirmRows: getEnvAsInt("IMPORT_CONFIRM_ROWS", 100),
+			Columns:     importColumns,
+			columnsErr:  importColumnsErr,
 		},
 	}
+	loadSecretFiles(cfg)
+	return cfg
 }
 
 // getEnv gets an environment variable with a default value
@@ -143,13 +477,279 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
-// IsValid checks if the configuration is valid
+// getEnvAsList gets a comma-separated environment variable as a lowercase list
+func getEnvAsList(key string, defaultValue []string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, ""), ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+// loadChatTables parses the chat_id -> bitable URL mapping, given either inline as a
+// JSON object or as the path of a JSON file
+func loadChatTables(value string) (map[string]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	data := []byte(value)
+	if !strings.HasPrefix(value, "{") {
+		var err error
+		if data, err = os.ReadFile(value); err != nil {
+			return nil, fmt.Errorf("read chat tables file: %v", err)
+		}
+	}
+
+	var tables map[string]string
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("parse chat tables: %v", err)
+	}
+	if err := checkChatTables(tables); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// checkChatTables checks that every chat_id maps to a bitable URL
+func checkChatTables(tables map[string]string) error {
+	for chatID, url := range tables {
+		if chatID == "" || url == "" {
+			return fmt.Errorf("chat tables must map non-empty chat_id to non-empty bitable URL")
+		}
+		if strings.Contains(chatID, "@") {
+			return fmt.Errorf("chat_id %q must not contain '@'", chatID)
+		}
+	}
+	return nil
+}
+
+// parseImportColumns parses "field=header" pairs separated by commas, e.g. "date=交易时间,amount=金额(元)"
+func parseImportColumns(value string) (map[string]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		field, header = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(header)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected field=header", pair)
+		}
+		columns[field] = header
+	}
+	if err := checkImportColumns(columns); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// checkImportColumns checks that only known fields are mapped, each to a non-empty header
+func checkImportColumns(columns map[string]string) error {
+	for field, header := range columns {
+		known := false
+		for _, f := range importFields {
+			if f == field {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown import field %q, must be one of %s", field, strings.Join(importFields, "/"))
+		}
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("empty column header for import field %q", field)
+		}
+	}
+	return nil
+}
+
+// parseSplitWeights parses "name=weight" pairs separated by commas, e.g. "张三=2,李四=1"
+func parseSplitWeights(value string) (map[string]float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		name, weightStr, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightStr), 64)
+		if !ok || name == "" || err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid split weight %q, expected name=weight with a non-negative weight", pair)
+		}
+		weights[name] = weight
+	}
+	return weights, nil
+}
+
+// checkSplitWeights checks that no weight is negative
+func checkSplitWeights(weights map[string]float64) error {
+	for name, weight := range weights {
+		if name == "" || weight < 0 {
+			return fmt.Errorf("invalid split weight %s=%v, expected a name with a non-negative weight", name, weight)
+		}
+	}
+	return nil
+}
+
+// PlatformEnabled reports whether the chat platform is enabled
+func (c *Config) PlatformEnabled(platform string) bool {
+	for _, p := range c.Platforms {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid checks the configuration and reports every problem at once as ConfigErrors,
+// each naming the setting to change and an example value
 func (c *Config) IsValid() error {
-	if c.Feishu.AppID == "" || c.Feishu.AppSecret == "" {
-		return &ConfigError{Field: "feishu", Message: "Feishu AppID and AppSecret are required"}
+	var errs ConfigErrors
+	// add 记录一个问题；example 为空时不给出示例
+	add := func(field, env, example, format string, args ...interface{}) {
+		e := &ConfigError{Field: field, Setting: c.settingName(env), Message: fmt.Sprintf(format, args...)}
+		if example != "" {
+			e.Example = c.example(env, example)
+		}
+		errs = append(errs, e)
+	}
+
+	// 飞书应用和多维表格
+	if c.Feishu.AppID == "" {
+		add("feishu", "FEISHU_APP_ID", "cli_a1b2c3d4e5f6g7h8", "Feishu app ID is required")
+	}
+	if c.Feishu.AppSecret == "" {
+		add("feishu", "FEISHU_APP_SECRET", "your_app_secret", "Feishu app secret is required")
+	}
+	if c.Feishu.BitableURL == "" {
+		add("feishu", "FEISHU_BITABLE_URL", exampleBitableURL, "bitable URL is required")
+	} else if _, err := ParseBitableURL(c.Feishu.BitableURL); err != nil {
+		add("feishu", "FEISHU_BITABLE_URL", exampleBitableURL, "%v", err)
+	}
+	if c.Feishu.chatTablesErr != nil {
+		add("feishu", "FEISHU_CHAT_TABLES", `{"oc_xxx":"`+exampleBitableURL+`"}`, "%v", c.Feishu.chatTablesErr)
+	}
+	for chatID, url := range c.Feishu.ChatTables {
+		if _, err := ParseBitableURL(url); err != nil {
+			add("feishu", "FEISHU_CHAT_TABLES", "", "chat %s: %v", chatID, err)
+		}
 	}
+	if c.Feishu.ConnectionMode != ConnectionModeWebhook && c.Feishu.ConnectionMode != ConnectionModeWebSocket {
+		add("feishu", "FEISHU_CONNECTION_MODE", ConnectionModeWebhook, "unknown connection mode %q, must be webhook or websocket", c.Feishu.ConnectionMode)
+	}
+	if c.Feishu.SoftDelete && c.Feishu.FieldDeletedAt == "" {
+		add("feishu", "FEISHU_FIELD_DELETED_AT", "删除时间", "soft delete requires the deleted-at field name")
+	}
+	if c.Feishu.SoftDelete && c.Feishu.SoftDeleteRetentionDays <= 0 {
+		add("feishu", "FEISHU_SOFT_DELETE_RETENTION_DAYS", "30", "soft delete requires a positive retention in days")
+	}
+	if c.Feishu.AlertErrorThreshold > 0 && c.Feishu.AlertWindowMinutes <= 0 {
+		add("feishu", "ALERT_WINDOW_MINUTES", "10", "error alerts require a positive window in minutes, got %d", c.Feishu.AlertWindowMinutes)
+	}
+	if c.Feishu.AlertCooldownMinutes < 0 {
+		add("feishu", "ALERT_COOLDOWN_MINUTES", "60", "alert cool-down must not be negative, got %d", c.Feishu.AlertCooldownMinutes)
+	}
+
+	// 聊天平台
+	for _, p := range c.Platforms {
+		if p != PlatformFeishu && p != PlatformTelegram {
+			add("platforms", "PLATFORMS", "feishu,telegram", "unknown platform %q, must be feishu or telegram", p)
+		}
+	}
+	if c.PlatformEnabled(PlatformTelegram) && c.Telegram.BotToken == "" {
+		add("telegram", "TELEGRAM_BOT_TOKEN", "123456:ABC-DEF1234ghIkl", "Telegram bot token is required when telegram is enabled")
+	}
+
+	// AI 服务
 	if c.AI.APIKey == "" {
-		return &ConfigError{Field: "ai", Message: "AI API key is required"}
+		add("ai", "AI_API_KEY", "sk-xxxxxxxx", "AI API key is required")
+	}
+	if u, err := url.Parse(c.AI.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("ai", "AI_BASE_URL", "https://api.siliconflow.cn", "%q is not the http(s) URL of an OpenAI compatible service", c.AI.BaseURL)
+	}
+	if c.AI.splitWeightsErr != nil {
+		add("ai", "SPLIT_WEIGHTS", "张三=2,李四=1", "%v", c.AI.splitWeightsErr)
+	}
+
+	// 服务
+	if c.Server.ReadTimeout <= 0 {
+		add("server", "SERVER_READ_TIMEOUT", "30", "read timeout must be a positive number of seconds, got %d", c.Server.ReadTimeout)
+	}
+	if c.Server.WriteTimeout <= 0 {
+		add("server", "SERVER_WRITE_TIMEOUT", "30", "write timeout must be a positive number of seconds, got %d", c.Server.WriteTimeout)
+	}
+	if c.Server.locationErr != nil {
+		add("server", "TIMEZONE", "Asia/Shanghai", "%v", c.Server.locationErr)
+	}
+	if c.Server.DebugEndpoints && c.Server.DebugAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Server.DebugAddr); err != nil {
+			add("server", "DEBUG_ADDR", "127.0.0.1:6060", "invalid debug address %q: %v", c.Server.DebugAddr, err)
+		}
+	}
+
+	// 存储和日志
+	if err := checkDataDir(c.Storage.DataDir); err != nil {
+		add("storage", "DATA_DIR", "./data", "%v", err)
+	}
+	if invalid := logger.InvalidLevels(c.Storage.LogLevel); len(invalid) > 0 || strings.Contains(c.Storage.LogLevel, "=") {
+		add("storage", "LOG_LEVEL", "info", "unknown log level %q, must be debug, info, warn or error", c.Storage.LogLevel)
+	}
+	if invalid := logger.InvalidLevels(c.Storage.LogLevels); len(invalid) > 0 {
+		add("storage", "LOG_LEVELS", "feishu=debug,default=warn", "invalid entries %s, expected component=level", strings.Join(invalid, ","))
+	}
+	if c.Storage.LogFormat != LogFormatText && c.Storage.LogFormat != LogFormatJSON {
+		add("storage", "LOG_FORMAT", LogFormatText, "unknown log format %q, must be text or json", c.Storage.LogFormat)
+	}
+	if c.Storage.Backend != StorageBackendBitable && c.Storage.Backend != StorageBackendDual {
+		add("storage", "STORAGE_BACKEND", StorageBackendBitable, "unknown storage backend %q, must be bitable or dual", c.Storage.Backend)
+	}
+	if c.Storage.UserStoreBackend != UserStoreJSON && c.Storage.UserStoreBackend != UserStoreBolt {
+		add("storage", "USER_STORE_BACKEND", UserStoreJSON, "unknown user store backend %q, must be json or bolt", c.Storage.UserStoreBackend)
+	}
+	if c.Storage.UserNameConflict != UserNameConflictReject && c.Storage.UserNameConflict != UserNameConflictSuffix {
+		add("storage", "USER_NAME_CONFLICT", UserNameConflictReject, "unknown user name conflict policy %q, must be reject or suffix", c.Storage.UserNameConflict)
+	}
+	if c.Cache.Backend != CacheBackendFile && c.Cache.Backend != CacheBackendRedis {
+		add("cache", "CACHE_BACKEND", CacheBackendFile, "unknown cache backend %q, must be file or redis", c.Cache.Backend)
+	}
+	for _, e := range c.secretFileErrs {
+		add(e.field, e.env+"_FILE", "", "%v", e.err)
+	}
+	if c.Import.columnsErr != nil {
+		add("import", "IMPORT_COLUMNS", "date=交易时间,amount=金额(元)", "%v", c.Import.columnsErr)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkDataDir 检查数据目录可以读取；不存在时启动时会创建，不算错误
+func checkDataDir(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("data directory is not accessible: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%q is not a directory", dir)
+	}
+	if _, err := os.ReadDir(dir); err != nil {
+		return fmt.Errorf("data directory is not readable: %v", err)
 	}
 	return nil
 }
@@ -157,9 +757,42 @@ func (c *Config) IsValid() error {
 // ConfigError represents a configuration error
 type ConfigError struct {
 	Field   string
+	Setting string // 需要修改的环境变量或配置文件中的键
 	Message string
+	Example string // 可以参照的设置，如 FEISHU_APP_ID=cli_xxx
 }
 
 func (e *ConfigError) Error() string {
-	return e.Field + ": " + e.Message
-}
\ No newline at end of file
+	msg := e.Field + ": "
+	if e.Setting != "" {
+		msg += e.Setting + ": "
+	}
+	msg += e.Message
+	if e.Example != "" {
+		msg += " (e.g. " + e.Example + ")"
+	}
+	return msg
+}
+
+// ConfigErrors lists every problem found by IsValid
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("%d problems:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// Unwrap returns the individual errors for errors.As
+func (e ConfigErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setValidEnv 设置最少的有效配置，DATA_DIR 指向临时目录
+func setValidEnv(t *testing.T) {
+	t.Helper()
+	clearConfigEnv(t)
+	t.Setenv("FEISHU_APP_ID", "cli_test")
+	t.Setenv("FEISHU_APP_SECRET", "secret")
+	t.Setenv("FEISHU_BITABLE_URL", "https://example.feishu.cn/base/bascnAPP?table=tblA")
+	t.Setenv("AI_API_KEY", "sk-test")
+	t.Setenv("DATA_DIR", t.TempDir())
+}
+
+func TestIsValid(t *testing.T) {
+	setValidEnv(t)
+	if err := LoadConfig().IsValid(); err != nil {
+		t.Errorf("IsValid = %v", err)
+	}
+}
+
+// 同时存在的多个问题一次全部报告，每项给出环境变量名和示例
+func TestIsValidCollectsAllProblems(t *testing.T) {
+	clearConfigEnv(t)
+	notDir := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FEISHU_BITABLE_URL", "https://example.feishu.cn/base/bascnAPP")
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LOG_LEVELS", "feishu=loud")
+	t.Setenv("SERVER_READ_TIMEOUT", "0")
+	t.Setenv("SERVER_WRITE_TIMEOUT", "-5")
+	t.Setenv("AI_BASE_URL", "api.openai.com")
+	t.Setenv("TIMEZONE", "Mars/Base")
+	t.Setenv("DATA_DIR", notDir)
+
+	err := LoadConfig().IsValid()
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("IsValid = %v, want ConfigErrors", err)
+	}
+	want := map[string]string{
+		"FEISHU_APP_ID":        "FEISHU_APP_ID=cli_a1b2c3d4e5f6g7h8",
+		"FEISHU_APP_SECRET":    "FEISHU_APP_SECRET=your_app_secret",
+		"FEISHU_BITABLE_URL":   "FEISHU_BITABLE_URL=" + exampleBitableURL,
+		"AI_API_KEY":           "AI_API_KEY=sk-xxxxxxxx",
+		"AI_BASE_URL":          "AI_BASE_URL=https://api.siliconflow.cn",
+		"LOG_LEVEL":            "LOG_LEVEL=info",
+		"LOG_LEVELS":           "LOG_LEVELS=feishu=debug,default=warn",
+		"SERVER_READ_TIMEOUT":  "SERVER_READ_TIMEOUT=30",
+		"SERVER_WRITE_TIMEOUT": "SERVER_WRITE_TIMEOUT=30",
+		"TIMEZONE":             "TIMEZONE=Asia/Shanghai",
+		"DATA_DIR":             "DATA_DIR=./data",
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d problems, want %d:\n%v", len(errs), len(want), err)
+	}
+	for _, e := range errs {
+		if example, ok := want[e.Setting]; !ok || e.Example != example {
+			t.Errorf("unexpected problem %+v", e)
+		}
+		delete(want, e.Setting)
+	}
+	for setting := range want {
+		t.Errorf("no problem reported for %s", setting)
+	}
+
+	// 整体错误列出每个问题，errors.As 可以取出单个 ConfigError
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "11 problems:\n  - feishu: FEISHU_APP_ID: Feishu app ID is required (e.g. FEISHU_APP_ID=cli_a1b2c3d4e5f6g7h8)\n") {
+		t.Errorf("Error() = %q", msg)
+	}
+	var first *ConfigError
+	if !errors.As(err, &first) || first.Setting != "FEISHU_APP_ID" {
+		t.Errorf("errors.As = %+v", first)
+	}
+}
+
+func TestConfigErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&ConfigError{Field: "ai", Setting: "AI_API_KEY", Message: "AI API key is required", Example: "AI_API_KEY=sk-xxxxxxxx"}, "ai: AI_API_KEY: AI API key is required (e.g. AI_API_KEY=sk-xxxxxxxx)"},
+		{&ConfigError{Field: "feishu", Setting: "FEISHU_CHAT_TABLES", Message: "chat oc_1: bad URL"}, "feishu: FEISHU_CHAT_TABLES: chat oc_1: bad URL"},
+		{&ConfigError{Field: "storage", Message: "broken"}, "storage: broken"},
+		// 只有一个问题时不加标题
+		{ConfigErrors{{Field: "ai", Setting: "AI_API_KEY", Message: "AI API key is required"}}, "ai: AI_API_KEY: AI API key is required"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// 未开启的功能不检查相关配置，开启后才报告缺失项
+func TestIsValidConditionalChecks(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+	t.Setenv("FEISHU_SOFT_DELETE_RETENTION_DAYS", "0")
+	if err := LoadConfig().IsValid(); err != nil {
+		t.Fatalf("IsValid = %v", err)
+	}
+
+	t.Setenv("PLATFORMS", "feishu,telegram,slack")
+	t.Setenv("FEISHU_SOFT_DELETE", "true")
+	var errs ConfigErrors
+	if !errors.As(LoadConfig().IsValid(), &errs) {
+		t.Fatal("IsValid passed")
+	}
+	got := make([]string, len(errs))
+	for i, e := range errs {
+		got[i] = e.Setting
+	}
+	if strings.Join(got, ",") != "FEISHU_SOFT_DELETE_RETENTION_DAYS,PLATFORMS,TELEGRAM_BOT_TOKEN" {
+		t.Errorf("problems %v", got)
+	}
+}
+
+func TestCheckDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkDataDir(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing dir: %v, want nil since it is created on start", err)
+	}
+	if err := checkDataDir(dir); err != nil {
+		t.Errorf("existing dir: %v", err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDataDir(file); err == nil || !strings.Contains(err.Error(), "is not a directory") {
+		t.Errorf("file: %v", err)
+	}
+}
+
+// 开启调试接口时检查 DEBUG_ADDR，未开启时不检查
+func TestIsValidDebugAddr(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("DEBUG_ADDR", "6060")
+	if err := LoadConfig().IsValid(); err != nil {
+		t.Errorf("IsValid with debug endpoints off = %v", err)
+	}
+
+	t.Setenv("DEBUG_ENDPOINTS", "true")
+	var errs ConfigErrors
+	if !errors.As(LoadConfig().IsValid(), &errs) || len(errs) != 1 || errs[0].Setting != "DEBUG_ADDR" {
+		t.Errorf("IsValid = %v, want a DEBUG_ADDR problem", errs)
+	}
+	for _, addr := range []string{"127.0.0.1:6060", ":6060", ""} {
+		t.Setenv("DEBUG_ADDR", addr)
+		if err := LoadConfig().IsValid(); err != nil {
+			t.Errorf("DEBUG_ADDR=%q: %v", addr, err)
+		}
+	}
+}
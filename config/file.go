@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting 一个可以通过配置文件或环境变量设置的配置项
+type setting struct {
+	key    string // 配置文件中的键，如 feishu.app_id
+	env    string // 对应的环境变量
+	secret bool   // 密钥，生成示例配置时留空
+	value  reflect.Value
+}
+
+// settingsOf 按声明顺序列出带 env 标签的配置项，嵌套的配置段展开为带前缀的键
+func settingsOf(v reflect.Value, prefix string) []setting {
+	var settings []setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		env, opts, _ := strings.Cut(f.Tag.Get("env"), ",")
+		if env == "" {
+			if f.Type.Kind() == reflect.Struct {
+				settings = append(settings, settingsOf(v.Field(i), prefix+key+".")...)
+			}
+			continue
+		}
+		settings = append(settings, setting{key: prefix + key, env: env, secret: opts == "secret", value: v.Field(i)})
+	}
+	return settings
+}
+
+// LoadConfigFromFile loads configuration from a YAML file with the same structure as Config,
+// such as feishu.app_id or storage.log_level. Environment variables that are set override the
+// file field by field, and settings found in neither keep the defaults of LoadConfig
+func LoadConfigFromFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	envCfg := LoadConfig()
+	cfg := *envCfg
+	// yaml 解码到已有的 map 时会合并键，清空后文件中的 map 完整替换默认值
+	cfg.Feishu.ChatTables, cfg.AI.SplitWeights, cfg.Import.Columns = nil, nil, nil
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	var keys map[string]interface{}
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	cfg.names = make(map[string]string)
+	fileSettings := settingsOf(reflect.ValueOf(&cfg).Elem(), "")
+	envSettings := settingsOf(reflect.ValueOf(envCfg).Elem(), "")
+	for i, s := range fileSettings {
+		switch {
+		case os.Getenv(s.env) != "", s.secret && os.Getenv(s.env+"_FILE") != "":
+			// 环境变量和 <VAR>_FILE 指定的密钥文件都优先于配置文件
+			s.value.Set(envSettings[i].value)
+		default:
+			// 未被环境变量覆盖的项，校验错误中使用文件中的键
+			cfg.names[s.env] = s.key
+			// 与环境变量一致，空的列表和 map（[] 或 {}）视为未设置
+			if k := s.value.Kind(); hasKey(keys, s.key) && (k == reflect.Slice || k == reflect.Map) && s.value.Len() == 0 {
+				s.value.Set(envSettings[i].value)
+			}
+		}
+	}
+
+	// 文件中的值没有经过环境变量的解析，在这里统一规范化和检查
+	for i, p := range cfg.Platforms {
+		cfg.Platforms[i] = strings.ToLower(strings.TrimSpace(p))
+	}
+	cfg.Storage.LogFormat = strings.ToLower(cfg.Storage.LogFormat)
+	if cfg.Server.Location, cfg.Server.locationErr = time.LoadLocation(cfg.Server.Timezone); cfg.Server.locationErr != nil {
+		cfg.Server.Location = time.Local
+	}
+	if cfg.Feishu.chatTablesErr == nil {
+		cfg.Feishu.chatTablesErr = checkChatTables(cfg.Feishu.ChatTables)
+	}
+	if cfg.AI.splitWeightsErr == nil {
+		cfg.AI.splitWeightsErr = checkSplitWeights(cfg.AI.SplitWeights)
+	}
+	if cfg.Import.columnsErr == nil {
+		cfg.Import.columnsErr = checkImportColumns(cfg.Import.Columns)
+	}
+	return &cfg, nil
+}
+
+// hasKey 判断配置文件中是否设置了以点分隔的键
+func hasKey(keys map[string]interface{}, key string) bool {
+	first, rest, nested := strings.Cut(key, ".")
+	value, ok := keys[first]
+	if !ok || !nested {
+		return ok
+	}
+	sub, ok := value.(map[string]interface{})
+	return ok && hasKey(sub, rest)
+}
+
+// settingName 返回校验错误中配置项的名称：使用配置文件且未被环境变量覆盖的用文件中的键，否则用环境变量名
+func (c *Config) settingName(env string) string {
+	if key, ok := c.names[env]; ok {
+		return key
+	}
+	return env
+}
+
+// example 返回配置项的示例写法，如 FEISHU_APP_ID=cli_xxx 或 feishu.app_id: cli_xxx
+func (c *Config) example(env, value string) string {
+	if key, ok := c.names[env]; ok {
+		return key + ": " + value
+	}
+	return env + "=" + value
+}
+
+// WriteExampleConfig writes c as a YAML config file for LoadConfigFromFile, each setting
+// commented with the environment variable overriding it. Secrets are left empty
+func WriteExampleConfig(w io.Writer, c *Config) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+	for _, s := range settingsOf(reflect.ValueOf(c).Elem(), "") {
+		parent := root
+		if section, key, nested := strings.Cut(s.key, "."); nested {
+			if sections[section] == nil {
+				sections[section] = &yaml.Node{Kind: yaml.MappingNode}
+				root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, sections[section])
+			}
+			parent = sections[section]
+			s.key = key
+		}
+
+		value := &yaml.Node{}
+		v := s.value.Interface()
+		if s.secret {
+			v = ""
+		}
+		if err := value.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode %s: %v", s.key, err)
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: s.key}
+		if value.Kind != yaml.ScalarNode && len(value.Content) == 0 {
+			// 空的列表和 map 以 [] 或 {} 写在同一行，注释跟在值后面
+			value.LineComment = s.env
+		} else {
+			key.LineComment = s.env
+		}
+		parent.Content = append(parent.Content, key, value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to write example config: %v", err)
+	}
+	return enc.Close()
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearConfigEnv 清空所有配置项的环境变量（包括密钥的 _FILE 变量），测试结束后恢复
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range settingsOf(reflect.ValueOf(&Config{}).Elem(), "") {
+		t.Setenv(s.env, "")
+		if s.secret {
+			t.Setenv(s.env+"_FILE", "")
+		}
+	}
+}
+
+// writeConfigFile 把 content 写入临时目录中的 config.yaml 并返回路径
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 环境变量生成的配置写成 YAML 后再读回，得到相同的 Config
+func TestLoadConfigFromFileRoundTrip(t *testing.T) {
+	clearConfigEnv(t)
+	env := map[string]string{
+		"FEISHU_APP_ID":               "cli_roundtrip",
+		"FEISHU_APP_SECRET":           "secret-value",
+		"FEISHU_BITABLE_URL":          "https://example.feishu.cn/base/bascnAPP?table=tblA",
+		"FEISHU_CHAT_TABLES":          `{"oc_1":"https://example.feishu.cn/base/bascnB?table=tblB"}`,
+		"FEISHU_SHARED_LEDGER":        "true",
+		"PLATFORMS":                   "Feishu, telegram",
+		"TELEGRAM_BOT_TOKEN":          "123456:ABC",
+		"AI_API_KEY":                  "sk-roundtrip",
+		"AI_CONFIRM_AMOUNT_THRESHOLD": "999.5",
+		"SPLIT_WEIGHTS":               "张三=2,李四=1",
+		"IMPORT_COLUMNS":              "date=交易时间,amount=金额(元)",
+		"SERVER_READ_TIMEOUT":         "45",
+		"TIMEZONE":                    "America/New_York",
+		"LOG_FORMAT":                  "JSON",
+		"LOG_LEVELS":                  "feishu=debug",
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	want := LoadConfig()
+
+	var buf bytes.Buffer
+	if err := WriteExampleConfig(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfigFile(t, buf.String())
+	// 示例配置中密钥留空，仍由环境变量提供；其余环境变量清空后只从文件读取
+	for k := range env {
+		if k != "FEISHU_APP_SECRET" && k != "TELEGRAM_BOT_TOKEN" && k != "AI_API_KEY" {
+			t.Setenv(k, "")
+		}
+	}
+
+	got, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.names = nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the config:\n got %+v\nwant %+v", got, want)
+	}
+	if err := got.IsValid(); err != nil {
+		t.Errorf("IsValid = %v", err)
+	}
+}
+
+// 已设置的环境变量逐项覆盖文件中的值，两边都没有设置的项使用默认值
+func TestLoadConfigFromFileEnvOverride(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+server:
+  port: "9000"
+  read_timeout: 10
+feishu:
+  app_id: cli_from_file
+  chat_tables:
+    oc_1: https://example.feishu.cn/base/bascnB?table=tblB
+storage:
+  log_level: debug
+`)
+	t.Setenv("SERVER_PORT", "9100")
+	t.Setenv("FEISHU_CHAT_TABLES", `{"oc_2":"https://example.feishu.cn/base/bascnC?table=tblC"}`)
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "9100" || cfg.Server.ReadTimeout != 10 || cfg.Feishu.AppID != "cli_from_file" || cfg.Storage.LogLevel != "debug" {
+		t.Errorf("server %+v, app id %q, log level %q", cfg.Server, cfg.Feishu.AppID, cfg.Storage.LogLevel)
+	}
+	// map 由环境变量整体替换，不与文件中的合并
+	if want := map[string]string{"oc_2": "https://example.feishu.cn/base/bascnC?table=tblC"}; !reflect.DeepEqual(cfg.Feishu.ChatTables, want) {
+		t.Errorf("ChatTables = %v, want %v", cfg.Feishu.ChatTables, want)
+	}
+	defaults := LoadConfig()
+	if cfg.Server.WriteTimeout != defaults.Server.WriteTimeout || cfg.Feishu.BotName != defaults.Feishu.BotName {
+		t.Errorf("unset settings = %d, %q, want the defaults", cfg.Server.WriteTimeout, cfg.Feishu.BotName)
+	}
+}
+
+// 空的列表和 map 视为未设置，保留默认值
+func TestLoadConfigFromFileEmptyCollections(t *testing.T) {
+	clearConfigEnv(t)
+	cfg, err := LoadConfigFromFile(writeConfigFile(t, "platforms: []\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Platforms, LoadConfig().Platforms) {
+		t.Errorf("Platforms = %v, want the default", cfg.Platforms)
+	}
+
+	// 空文件等同于只用环境变量
+	cfg, err = LoadConfigFromFile(writeConfigFile(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.names = nil
+	if want := LoadConfig(); !reflect.DeepEqual(cfg, want) {
+		t.Errorf("empty file config = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadConfigFromFileErrors(t *testing.T) {
+	clearConfigEnv(t)
+	for name, content := range map[string]string{
+		"unknown key":     "feishu:\n  app_idd: cli_typo\n",
+		"unknown section": "feishuu:\n  app_id: cli_typo\n",
+		"wrong type":      "server:\n  read_timeout: soon\n",
+		"invalid yaml":    "feishu: [\n",
+	} {
+		if _, err := LoadConfigFromFile(writeConfigFile(t, content)); err == nil {
+			t.Errorf("%s: LoadConfigFromFile succeeded", name)
+		}
+	}
+	if _, err := LoadConfigFromFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+// 校验错误使用文件中的键；被环境变量覆盖的项使用环境变量名
+func TestLoadConfigFromFileErrorNames(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+storage:
+  log_format: xml
+server:
+  timezone: Mars/Base
+  write_timeout: 0
+`)
+	t.Setenv("SERVER_WRITE_TIMEOUT", "-1")
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs ConfigErrors
+	if !errors.As(cfg.IsValid(), &errs) {
+		t.Fatal("IsValid passed")
+	}
+	settings := make(map[string]*ConfigError)
+	for _, e := range errs {
+		settings[e.Setting] = e
+	}
+	for _, key := range []string{"storage.log_format", "server.timezone", "feishu.app_id", "ai.api_key", "SERVER_WRITE_TIMEOUT"} {
+		if settings[key] == nil {
+			t.Errorf("no error names %s in %v", key, errs)
+		}
+	}
+	if e := settings["storage.log_format"]; e != nil && e.Example != "storage.log_format: text" {
+		t.Errorf("Example = %q, want the YAML form", e.Example)
+	}
+	if e := settings["SERVER_WRITE_TIMEOUT"]; e != nil && e.Example != "SERVER_WRITE_TIMEOUT=30" {
+		t.Errorf("Example = %q, want the env form", e.Example)
+	}
+}
+
+// 示例配置覆盖所有配置项，密钥留空，每项注释对应的环境变量
+func TestWriteExampleConfig(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AI_API_KEY", "sk-should-not-appear")
+	cfg := LoadConfig()
+
+	var buf bytes.Buffer
+	if err := WriteExampleConfig(&buf, cfg); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "sk-should-not-appear") {
+		t.Errorf("example config leaks a secret:\n%s", out)
+	}
+	for _, s := range settingsOf(reflect.ValueOf(cfg).Elem(), "") {
+		if !strings.Contains(out, "# "+s.env+"\n") {
+			t.Errorf("example config misses %s (%s)", s.key, s.env)
+		}
+	}
+
+	// 仓库中的 config.example.yaml 与结构体标签保持一致
+	data, err := os.ReadFile("../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigFromFile("../config.example.yaml"); err != nil {
+		t.Errorf("config.example.yaml no longer loads: %v", err)
+	}
+	for _, s := range settingsOf(reflect.ValueOf(cfg).Elem(), "") {
+		if !bytes.Contains(data, []byte("# "+s.env+"\n")) {
+			t.Errorf("config.example.yaml misses %s (%s)", s.key, s.env)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// secretFileError 读取密钥文件失败的配置项
+type secretFileError struct {
+	field string // 配置段，如 feishu
+	env   string
+	err   error
+}
+
+// loadSecretFiles 为密钥配置项读取 <VAR>_FILE 指定的文件（如 Docker/Kubernetes 挂载的 secret），
+// 去掉首尾空白后作为配置值；环境变量本身已设置时以环境变量为准
+func loadSecretFiles(c *Config) {
+	for _, s := range settingsOf(reflect.ValueOf(c).Elem(), "") {
+		path := os.Getenv(s.env + "_FILE")
+		if !s.secret || path == "" || os.Getenv(s.env) != "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			field, _, _ := strings.Cut(s.key, ".")
+			c.secretFileErrs = append(c.secretFileErrs, secretFileError{field: field, env: s.env, err: fmt.Errorf("failed to read secret file: %v", err)})
+			continue
+		}
+		s.value.SetString(strings.TrimSpace(string(data)))
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSecret 把 content 写入临时文件并返回路径
+func writeSecret(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSecretFiles(t *testing.T) {
+	setValidEnv(t)
+	t.Setenv("AI_API_KEY", "")
+	t.Setenv("AI_API_KEY_FILE", writeSecret(t, "  sk-from-file\n"))
+	t.Setenv("FEISHU_ENCRYPT_KEY_FILE", writeSecret(t, "encrypt-from-file\n"))
+	// 显式设置的环境变量优先于 _FILE
+	t.Setenv("FEISHU_APP_SECRET", "secret-from-env")
+	t.Setenv("FEISHU_APP_SECRET_FILE", writeSecret(t, "secret-from-file"))
+	// 非密钥项不读取 _FILE
+	t.Setenv("FEISHU_APP_ID_FILE", writeSecret(t, "cli_from_file"))
+
+	cfg := LoadConfig()
+	if cfg.AI.APIKey != "sk-from-file" || cfg.Feishu.EncryptKey != "encrypt-from-file" {
+		t.Errorf("APIKey %q, EncryptKey %q, want the trimmed file contents", cfg.AI.APIKey, cfg.Feishu.EncryptKey)
+	}
+	if cfg.Feishu.AppSecret != "secret-from-env" {
+		t.Errorf("AppSecret = %q, want the env value", cfg.Feishu.AppSecret)
+	}
+	if cfg.Feishu.AppID != "cli_test" {
+		t.Errorf("AppID = %q, want _FILE ignored for non-secrets", cfg.Feishu.AppID)
+	}
+	if err := cfg.IsValid(); err != nil {
+		t.Errorf("IsValid = %v", err)
+	}
+}
+
+// 无法读取的密钥文件在校验时报告，错误中包含 _FILE 变量名和路径
+func TestSecretFileMissing(t *testing.T) {
+	setValidEnv(t)
+	missing := filepath.Join(t.TempDir(), "no-such-secret")
+	t.Setenv("AI_API_KEY", "")
+	t.Setenv("AI_API_KEY_FILE", missing)
+
+	cfg := LoadConfig()
+	if cfg.AI.APIKey != "" {
+		t.Errorf("APIKey = %q, want empty", cfg.AI.APIKey)
+	}
+	var errs ConfigErrors
+	if !errors.As(cfg.IsValid(), &errs) {
+		t.Fatal("IsValid passed")
+	}
+	found := false
+	for _, e := range errs {
+		if e.Setting == "AI_API_KEY_FILE" {
+			found = true
+			if e.Field != "ai" || !strings.Contains(e.Message, missing) {
+				t.Errorf("problem %+v, want the ai field and the path", e)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no problem names AI_API_KEY_FILE in %v", errs)
+	}
+}
+
+// _FILE 指定的密钥优先于配置文件中的值，未设置时使用配置文件
+func TestSecretFileOverridesConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, "ai:\n  api_key: sk-from-yaml\nfeishu:\n  app_secret: secret-from-yaml\n")
+	t.Setenv("AI_API_KEY_FILE", writeSecret(t, "sk-from-file"))
+
+	cfg, err := LoadConfigFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AI.APIKey != "sk-from-file" || cfg.Feishu.AppSecret != "secret-from-yaml" {
+		t.Errorf("APIKey %q, AppSecret %q", cfg.AI.APIKey, cfg.Feishu.AppSecret)
+	}
+}
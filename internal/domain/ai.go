@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
@@ -61,6 +63,15 @@ type BillExtraction struct {
 	Date        string  `json:"date,omitempty"`
 }
 
+// ReceiptExtraction represents receipt information extracted from an image by AI
+type ReceiptExtraction struct {
+	Merchant   string  `json:"merchant"`
+	Amount     float64 `json:"amount"`
+	Date       string  `json:"date,omitempty"`
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"` // 0-1，识别结果的可信度
+}
+
 // RenameRequest represents a user rename request
 type RenameRequest struct {
 	Name string `json:"name"`
@@ -84,19 +95,71 @@ type AICommand struct {
 
 // AIService interface for AI integration
 type AIService interface {
-	// Execute processes user input via AI function calling
-	Execute(input string, userName string, billService BillServiceInterface, renameService RenameServiceInterface, history []AIMessage) (string, error)
+	// Execute processes user input via AI function calling; the AI call is cancelled with ctx
+	// conversationKey identifies the user+thread, used to store operations awaiting confirmation
+	Execute(ctx context.Context, input string, userName string, conversationKey string, billService BillServiceInterface, renameService RenameServiceInterface, history []AIMessage) (string, error)
+
+	// ResolveConfirmation executes or discards the operation awaiting confirmation for conversationKey.
+	// handled is false when there is no pending operation, so the message should go through Execute.
+	ResolveConfirmation(conversationKey string, confirmed bool, billService BillServiceInterface, renameService RenameServiceInterface) (reply string, handled bool, err error)
+
+	// MoreResults renders the next page of the last truncated query for conversationKey.
+	// handled is false when there is no stored query cursor, so the message should go through Execute.
+	MoreResults(conversationKey string, billService BillServiceInterface) (reply string, handled bool, err error)
+
+	// ForgetConversations discards the operations awaiting confirmation and the query cursors of every thread of openID
+	ForgetConversations(openID string) error
+
+	// ExecuteReceipt recognizes a receipt image and records it as an expense
+	ExecuteReceipt(ctx context.Context, image []byte, userName string, conversationKey string, billService BillServiceInterface, renameService RenameServiceInterface) (string, error)
+
+	// Transcribe converts a voice message into text
+	Transcribe(ctx context.Context, audio []byte, fileName string) (string, error)
+
+	// ClassifyCategory picks the best matching category for a description from the given list
+	ClassifyCategory(ctx context.Context, description string, categories []string) (string, error)
+
+	// Ping checks that the AI endpoint is reachable with the configured key
+	Ping(ctx context.Context) error
 }
 
 // BillServiceInterface defines functionality for handling bills in AI context
 type BillServiceInterface interface {
-	CreateBill(description string, amount float64, billType BillType, date *time.Time, category string, originalMsg string) (*Bill, error)
-	UpdateBill(recordID string, description *string, amount *float64, billType *BillType, category *string, originalMsg *string) (*Bill, error)
+	CreateBill(input NewBillInput) (*Bill, error)
+	CreateBills(inputs []NewBillInput) ([]*Bill, error)
+	UpdateBill(recordID string, updates map[string]interface{}) (*Bill, error)
 	DeleteBill(recordID string) error
+	DeleteBills(bills []*Bill) error
+	RestoreBill(recordID string) error
+	SettleReimbursement(recordIDs []string, amount float64) (*Bill, error)
+	CreateInstallmentBill(input NewBillInput, count int) (*Bill, *InstallmentPlan, error)
+	ListInstallmentPlans() ([]*InstallmentPlan, error)
+	CancelInstallmentPlan(planID string, deleteFuture bool) (*InstallmentPlan, int, error)
+	CreateRecurringRule(rule RecurringRule) (*RecurringRule, error)
+	ListRecurringRules() ([]*RecurringRule, error)
+	DeleteRecurringRule(ruleID string) (*RecurringRule, error)
+	RecordLoan(input LoanInput) (*Bill, error)
+	LoanBalances() ([]*LoanBalance, error)
+	SettleLoan(counterparty string, amount float64) (*Bill, *LoanBalance, error)
+	UndoLast() (*UndoResult, error)
+	SaveTemplate(tpl BillTemplate) (*BillTemplate, *BillTemplate, error)
+	ListTemplates() ([]*BillTemplate, error)
+	DeleteTemplate(name string) (*BillTemplate, error)
+	UseTemplate(name string, amount float64) (*Bill, *BillTemplate, error)
+	SpendingTrends(startTime, endTime time.Time) (*SpendingTrend, error)
+	ForecastMonth() (*MonthForecast, error)
+	SplitBill(input NewBillInput, split *Split, recordOthers bool) ([]*Bill, error)
+	MemberBreakdown(startTime, endTime time.Time) ([]*MemberTotal, error)
+	SettleUp(startTime, endTime time.Time, weights map[string]float64) (*Settlement, error)
 	QueryTransactions(startTime, endTime time.Time, topN int) ([]*Bill, float64, float64, error)
+	QueryTransactionsPage(startTime, endTime time.Time, pageToken string, pageSize int) ([]*Bill, string, error)
+	ExportTransactions(startTime, endTime time.Time, w io.Writer) (int, error)
+	ExportUserData(w io.Writer) (int, error)
 }
 
 // RenameServiceInterface defines functionality for renaming users in AI context
 type RenameServiceInterface interface {
-	Rename(name string) error
+	// Rename saves the user's name and returns the name actually saved, which may carry a
+	// number when another user has the same name; fails with ErrUserNameTaken when refused
+	Rename(name string) (string, error)
 }
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 金额在账单中以元为单位保存，但始终是整数分：写入前用 RoundAmount 取整，
+// 累加时用 AddAmount 按分计算，避免 0.1+0.2 这类浮点误差在合计中累积
+
+// ToCents converts an amount to cents, rounding to the nearest cent
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromCents converts cents back to an amount
+func FromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// RoundAmount rounds an amount to whole cents, e.g. 19.999999 becomes 20; NaN and infinities are returned unchanged
+func RoundAmount(amount float64) float64 {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return amount
+	}
+	return FromCents(ToCents(amount))
+}
+
+// AddAmount adds two amounts in cents, so a running total never drifts
+func AddAmount(total, amount float64) float64 {
+	return FromCents(ToCents(total) + ToCents(amount))
+}
+
+// FormatAmount renders an amount with the currency symbol, thousands separators and two decimals,
+// e.g. ¥1,234.50; negative amounts are rendered as -¥1,234.50
+func FormatAmount(symbol string, amount float64) string {
+	cents := ToCents(amount)
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	whole := strconv.FormatInt(cents/100, 10)
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return fmt.Sprintf("%s%s%s.%02d", sign, symbol, b.String(), cents%100)
+}
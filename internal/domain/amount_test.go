@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+// 用变量而不是常量，常量表达式在编译期精确计算，不会出现浮点误差
+var tenth, fifth = 0.1, 0.2
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{19.999999, 20},
+		{tenth + fifth, 0.3},
+		{35.456, 35.46},
+		{35.454, 35.45},
+		{-12.345000001, -12.35},
+		{1e9 + 0.004, 1e9},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := RoundAmount(tt.in); got != tt.want {
+			t.Errorf("RoundAmount(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if got := RoundAmount(math.NaN()); !math.IsNaN(got) {
+		t.Errorf("RoundAmount(NaN) = %v, want NaN", got)
+	}
+	if got := RoundAmount(math.Inf(1)); !math.IsInf(got, 1) {
+		t.Errorf("RoundAmount(+Inf) = %v, want +Inf", got)
+	}
+}
+
+// 浮点直接累加会产生误差，按分累加的合计是精确的
+func TestAddAmountNoDrift(t *testing.T) {
+	if tenth+fifth == 0.3 {
+		t.Fatalf("0.1+0.2 = %v, expected float drift to demonstrate the fix", tenth+fifth)
+	}
+	if got := AddAmount(tenth, fifth); got != 0.3 {
+		t.Errorf("AddAmount(0.1, 0.2) = %v, want 0.3", got)
+	}
+
+	naive, total := 0.0, 0.0
+	for i := 0; i < 1000; i++ {
+		naive += tenth
+		total = AddAmount(total, tenth)
+	}
+	if naive == 100 {
+		t.Fatalf("naive sum = %v, expected drift to demonstrate the fix", naive)
+	}
+	if total != 100 {
+		t.Errorf("1000 × 0.1 = %v, want exactly 100", total)
+	}
+}
+
+func TestSumBills(t *testing.T) {
+	bills := []*Bill{
+		{Amount: 0.1, Type: BillTypeExpense},
+		{Amount: 0.2, Type: BillTypeExpense},
+		{Amount: 19.999999, Type: BillTypeExpense},
+		{Amount: 1000.1, Type: BillTypeIncome},
+		{Amount: 0.2, Type: BillTypeIncome},
+		// 借贷不计入收支
+		{Amount: 500, Type: BillTypeExpense, Category: CategoryLoan},
+	}
+	income, expense := SumBills(bills)
+	if income != 1000.3 || expense != 20.3 {
+		t.Errorf("SumBills = %v income, %v expense, want 1000.3 and 20.3", income, expense)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		symbol string
+		amount float64
+		want   string
+	}{
+		{"¥", 0, "¥0.00"},
+		{"¥", tenth + fifth, "¥0.30"},
+		{"¥", 19.999999, "¥20.00"},
+		{"¥", 999.995, "¥1,000.00"},
+		{"¥", 1234.5, "¥1,234.50"},
+		{"¥", 3000000, "¥3,000,000.00"},
+		{"$", 123456789.01, "$123,456,789.01"},
+		{"¥", -1234.5, "-¥1,234.50"},
+		{"¥", -0.001, "¥0.00"},
+		{"", 12, "12.00"},
+	}
+	for _, tt := range tests {
+		if got := FormatAmount(tt.symbol, tt.amount); got != tt.want {
+			t.Errorf("FormatAmount(%q, %v) = %q, want %q", tt.symbol, tt.amount, got, tt.want)
+		}
+	}
+}
+
+// 分期和 AA 分摊按分计算，各份之和等于总额
+func TestSplitAmountsSumToTotal(t *testing.T) {
+	plan := &InstallmentPlan{Total: 1000, Count: 3}
+	var installments int64
+	for n := 1; n <= plan.Count; n++ {
+		installments += ToCents(plan.Amount(n))
+	}
+	if plan.Amount(1) != 333.33 || plan.Amount(3) != 333.34 || installments != 100000 {
+		t.Errorf("installments = %v/%v/%v, want 333.33/333.33/333.34", plan.Amount(1), plan.Amount(2), plan.Amount(3))
+	}
+
+	split := &Split{Total: 100, Count: 3}
+	var shares int64
+	for _, share := range split.Shares() {
+		shares += ToCents(share)
+	}
+	if got := split.Shares(); got[0] != 33.34 || got[2] != 33.33 || shares != 10000 {
+		t.Errorf("shares = %v, want 33.34/33.33/33.33", got)
+	}
+}
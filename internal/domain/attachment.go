@@ -0,0 +1,39 @@
+package domain
+
+import "context"
+
+// Attachment is a file stored with a bill, such as the receipt image it was recognized from
+type Attachment struct {
+	FileName string `json:"file_name"`
+	// Data is the file content, cleared once the file has been uploaded to the storage
+	Data []byte `json:"data,omitempty"`
+	// FileToken identifies the uploaded file in the storage (e.g. a bitable file_token)
+	FileToken string `json:"file_token,omitempty"`
+}
+
+// attachmentsKey is the context key of the attachments of the message being processed
+type attachmentsKey struct{}
+
+// WithAttachments returns a context whose newly created bills carry the given attachments
+func WithAttachments(ctx context.Context, attachments ...Attachment) context.Context {
+	return context.WithValue(ctx, attachmentsKey{}, attachments)
+}
+
+// AttachmentsFromContext returns a copy of the attachments of ctx, or nil
+func AttachmentsFromContext(ctx context.Context) []Attachment {
+	attachments, _ := ctx.Value(attachmentsKey{}).([]Attachment)
+	if len(attachments) == 0 {
+		return nil
+	}
+	return append([]Attachment(nil), attachments...)
+}
+
+// HasUploadedAttachment reports whether any attachment of the bill was stored
+func (b *Bill) HasUploadedAttachment() bool {
+	for _, a := range b.Attachments {
+		if a.FileToken != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,11 @@
 package domain
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"time"
 )
 
@@ -12,9 +17,15 @@ const (
 	BillTypeExpense BillType = "Expense" // 支出
 )
 
+// CategoryOther is the fallback category when nothing fits
+const CategoryOther = "其它"
+
+// DefaultCategories lists the categories the AI can choose from
+var DefaultCategories = []string{"餐饮", "交通", "购物", "娱乐", "医疗", "教育", "住房", "水电费", "通讯", "服装", "收入", CategoryOther}
+
 // Bill represents an accounting record
 type Bill struct {
-	ID          string    `json:"id"`
+	ID          string    `json:"id"`          // 账单 ID，与 RecordID 相同，创建前为空
 	Description string    `json:"description"` // 账单描述，如 "午饭"
 	Amount      float64   `json:"amount"`      // 金额
 	Type        BillType  `json:"type"`        // 收入或支出
@@ -23,33 +34,133 @@ type Bill struct {
 	UserName    string    `json:"user_name"`   // 用户姓名（来自映射）
 	OriginalMsg string    `json:"original_msg,omitempty"` // 用户原始消息
 	RecordID    string    `json:"record_id,omitempty"`    // 存储系统的记录ID（如 Bitable 的 record_id）
+	Account     string    `json:"account,omitempty"`     // 支付账户，如 "微信"、"信用卡"，未提及时为空
+	Tags        []string  `json:"tags,omitempty"`        // 标签，如 "旅行"、"报销"，不含 # 号
+	Reimbursable *bool      `json:"reimbursable,omitempty"`  // 是否可报销，nil 表示未设置（部分更新时不修改）
+	ReimbursedAt *time.Time `json:"reimbursed_at,omitempty"` // 报销到账时间，未报销时为 nil
+	Attachments []Attachment `json:"attachments,omitempty"` // 附件，如收据图片
+
+	CategoryFromHistory bool   `json:"-"` // 分类是否根据历史记录自动修正（不持久化）
+	AlreadyRecorded     bool   `json:"-"` // 来源消息此前已创建过该账单，本次未重复写入（不持久化）
+	Queued              bool   `json:"-"` // 存储暂不可用，账单已排队稍后写入，RecordID 为临时 ID（不持久化）
+	DuplicateOf         string `json:"-"` // 疑似重复的已有账单记录 ID，仍然写入时用于提示（不持久化）
 }
 
 // BillRepository interface for bill data access
 type BillRepository interface {
 	// CreateBill creates a new bill
-	CreateBill(bill *Bill) error
+	CreateBill(ctx context.Context, bill *Bill) error
+
+	// CreateBills creates several bills at once; on partial failure it returns a *BatchCreateError
+	// and the successfully created bills have RecordID set
+	CreateBills(ctx context.Context, bills []*Bill) error
 
 	// GetBill gets a bill by ID
-	GetBill(id string) (*Bill, error)
+	GetBill(ctx context.Context, id string) (*Bill, error)
 
 	// UpdateBill updates a bill
-	UpdateBill(bill *Bill) error
+	UpdateBill(ctx context.Context, bill *Bill) error
 
 	// DeleteBill deletes a bill
-	DeleteBill(id string) error
+	DeleteBill(ctx context.Context, id string) error
+
+	// DeleteBills deletes several bills at once, in batches as large as the storage allows.
+	// On failure the bills of earlier batches may already be deleted
+	DeleteBills(ctx context.Context, ids []string) error
+
+	// RestoreBill restores a soft-deleted bill that is still within the retention window.
+	// It returns ErrSoftDeleteDisabled when the storage only supports hard deletes
+	RestoreBill(ctx context.Context, id string) error
 
 	// ListBills list bills with pagination and filtering
-	ListBills(userName string, startDate, endDate *time.Time, billType *BillType, category *string, offset, limit int) ([]*Bill, int, error)
+	ListBills(ctx context.Context, userName string, startDate, endDate *time.Time, billType *BillType, category *string, offset, limit int) ([]*Bill, int, error)
 
 	// GetMonthlySummary gets monthly summary for a user
-	GetMonthlySummary(userName string, year, month int) (*MonthlySummary, error)
+	GetMonthlySummary(ctx context.Context, userName string, year, month int) (*MonthlySummary, error)
 
 	// GetCategories gets all categories for a user
-	GetCategories(userName string) ([]string, error)
+	GetCategories(ctx context.Context, userName string) ([]string, error)
+
+	// Ping checks that the underlying storage is reachable
+	Ping(ctx context.Context) error
 
 	// QueryTransactions queries transactions within a time range
-	QueryTransactions(userName string, startTime, endTime time.Time, topN int) ([]*Bill, float64, float64, error)
+	QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*Bill, float64, float64, error)
+
+	// QueryTransactionsPage queries one page of transactions ordered by date descending, returning the next page token
+	QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*Bill, string, error)
+}
+
+// NewBillInput describes one bill to create. Empty Category falls back to the default and nil Date means now
+type NewBillInput struct {
+	Description string
+	Amount      float64
+	Type        BillType
+	Date        *time.Time
+	Category    string
+	OriginalMsg string
+	Account     string
+	Tags        []string
+	// Reimbursable marks a work expense that will be paid back
+	Reimbursable bool
+}
+
+// IsReimbursable reports whether the bill is marked as reimbursable
+func (b *Bill) IsReimbursable() bool {
+	return b.Reimbursable != nil && *b.Reimbursable
+}
+
+// PendingReimbursement reports whether the bill is reimbursable but not yet settled
+func (b *Bill) PendingReimbursement() bool {
+	return b.IsReimbursable() && b.ReimbursedAt == nil
+}
+
+// NormalizeTags trims tags, strips a leading "#" and drops empty and duplicate ones, keeping the order.
+// 返回 nil 表示没有有效标签
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(tag), "#＃"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// ErrBillNotFound is returned when the requested bill does not exist
+var ErrBillNotFound = errors.New("bill not found")
+
+// ErrSoftDeleteDisabled is returned by RestoreBill when deleted bills are removed immediately
+var ErrSoftDeleteDisabled = errors.New("soft delete is disabled")
+
+// ErrNotReimbursable is returned by SettleReimbursement for bills not marked as reimbursable
+var ErrNotReimbursable = errors.New("bill is not reimbursable")
+
+// ErrAlreadyReimbursed is returned by SettleReimbursement for bills that were already settled
+var ErrAlreadyReimbursed = errors.New("bill is already reimbursed")
+
+// BatchCreateError reports which bills of a batch failed to be created
+type BatchCreateError struct {
+	Errors []error // 与输入顺序一致，成功的位置为 nil
+}
+
+// FailedCount returns the number of bills that failed
+func (e *BatchCreateError) FailedCount() int {
+	n := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (e *BatchCreateError) Error() string {
+	return fmt.Sprintf("%d of %d bills failed to create", e.FailedCount(), len(e.Errors))
 }
 
 // MonthlySummary represents monthly financial summary
@@ -64,29 +175,152 @@ type MonthlySummary struct {
 
 // BillUseCase defines the business logic for bills
 type BillUseCase interface {
-	// CreateBill creates a new bill with AI categorization if needed
-	CreateBill(userName string, userID string, originalMsg string, description string, amount float64, billType BillType, date *time.Time, category *string) (*Bill, error)
+	// CreateBill creates a new bill with history-based categorization if needed
+	CreateBill(ctx context.Context, userName string, userID string, input NewBillInput) (*Bill, error)
+
+	// CreateBills creates several bills in one batch, returning them in input order.
+	// On partial failure the error is a *BatchCreateError and only the successful bills have RecordID set
+	CreateBills(ctx context.Context, userName string, userID string, inputs []NewBillInput) ([]*Bill, error)
 
 	// GetBill retrieves a bill by ID
-	GetBill(id string) (*Bill, error)
+	GetBill(ctx context.Context, id string) (*Bill, error)
 
 	// UpdateBill updates a bill
-	UpdateBill(id string, updates map[string]interface{}) (*Bill, error)
+	UpdateBill(ctx context.Context, id string, updates map[string]interface{}) (*Bill, error)
 
 	// DeleteBill deletes a bill
-	DeleteBill(id string) error
+	DeleteBill(ctx context.Context, id string) error
+
+	// DeleteBills deletes the given bills in one batch, logging each bill's fields first so they can be recovered
+	DeleteBills(ctx context.Context, bills []*Bill) error
+
+	// RestoreBill restores a soft-deleted bill
+	RestoreBill(ctx context.Context, id string) error
 
 	// ListUserBills lists bills for a user with filtering
-	ListUserBills(userName string, startDate, endDate *time.Time, billType *BillType, category *string, offset, limit int) ([]*Bill, int, error)
+	ListUserBills(ctx context.Context, userName string, startDate, endDate *time.Time, billType *BillType, category *string, offset, limit int) ([]*Bill, int, error)
 
 	// GetMonthlySummary gets monthly summary for a user
-	GetMonthlySummary(userName string, year, month int) (*MonthlySummary, error)
+	GetMonthlySummary(ctx context.Context, userName string, year, month int) (*MonthlySummary, error)
 
 	// SuggestCategory suggests category for a bill description
-	SuggestCategory(userName string, description string) ([]string, error)
+	SuggestCategory(ctx context.Context, userName string, description string) ([]string, error)
 
 	// QueryTransactions queries transactions within a time range and returns summary
-	QueryTransactions(userName string, startTime, endTime time.Time, topN int) ([]*Bill, float64, float64, error)
+	QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*Bill, float64, float64, error)
+
+	// QueryTransactionsPage queries one page of transactions ordered by date descending, returning the next page token
+	QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*Bill, string, error)
+
+	// ExportTransactions streams the transactions within a time range to w as CSV, returning the number of rows.
+	// Nothing is written when the first page cannot be read
+	ExportTransactions(ctx context.Context, userName string, startTime, endTime time.Time, w io.Writer) (int, error)
+
+	// ImportBills creates historical bills parsed from a file without duplicate detection.
+	// On partial failure the error is a *BatchCreateError indexed like inputs
+	ImportBills(ctx context.Context, userName string, inputs []NewBillInput) ([]*Bill, error)
+
+	// SettleReimbursement marks reimbursable bills as reimbursed and records the reimbursement as income.
+	// amount <= 0 means the sum of the bills. Bills that are not reimbursable or already settled
+	// fail the whole call with ErrNotReimbursable or ErrAlreadyReimbursed
+	SettleReimbursement(ctx context.Context, userName string, userID string, recordIDs []string, amount float64) (*Bill, error)
+
+	// CreateInstallmentBill records the first installment of input.Amount split into count monthly bills
+	// and saves a plan for the remaining ones
+	CreateInstallmentBill(ctx context.Context, userName string, userID string, input NewBillInput, count int) (*Bill, *InstallmentPlan, error)
+
+	// ListInstallmentPlans lists the unfinished installment plans of a user
+	ListInstallmentPlans(ctx context.Context, userName string) ([]*InstallmentPlan, error)
+
+	// CancelInstallmentPlan stops a plan; deleteFuture also deletes its bills dated after now
+	CancelInstallmentPlan(ctx context.Context, userName string, planID string, deleteFuture bool) (*InstallmentPlan, int, error)
+
+	// MaterializeInstallments records the installments due by now, safe to call repeatedly
+	MaterializeInstallments(ctx context.Context, now time.Time) (int, error)
+
+	// CreateRecurringRule saves a rule that records a bill monthly or weekly, starting from the next run date
+	CreateRecurringRule(ctx context.Context, userName string, userID string, rule RecurringRule) (*RecurringRule, error)
+
+	// ListRecurringRules lists the recurring rules of a user
+	ListRecurringRules(ctx context.Context, userName string) ([]*RecurringRule, error)
+
+	// DeleteRecurringRule deletes one of the user's recurring rules
+	DeleteRecurringRule(ctx context.Context, userName string, ruleID string) (*RecurringRule, error)
+
+	// RunRecurringRules records the recurring bills due by now, safe to call repeatedly;
+	// run dates are computed in the time zone of now
+	RunRecurringRules(ctx context.Context, now time.Time) ([]RecurringRun, error)
+
+	// RecordLoan records money lent to or borrowed from a counterparty, left out of income and expense totals
+	RecordLoan(ctx context.Context, userName string, userID string, input LoanInput) (*Bill, error)
+
+	// LoanBalances lists the user's outstanding balances per counterparty
+	LoanBalances(ctx context.Context, userName string) ([]*LoanBalance, error)
+
+	// SettleLoan records a repayment with a counterparty and returns the remaining balance.
+	// amount <= 0 settles the whole balance; fails with ErrNoOutstandingLoan or ErrLoanOverpaid
+	SettleLoan(ctx context.Context, userName string, userID string, counterparty string, amount float64, originalMsg string) (*Bill, *LoanBalance, error)
+
+	// UndoLast reverts the user's latest create, delete or update.
+	// Fails with ErrNothingToUndo, or ErrUndoUnsupported when the latest operation is an undo
+	UndoLast(ctx context.Context, userName string) (*UndoResult, error)
+
+	// SaveTemplate saves a transaction under a short name, overwriting the user's template with the same name.
+	// Returns the saved template and the overwritten one, nil if there was none
+	SaveTemplate(ctx context.Context, userName string, tpl BillTemplate) (*BillTemplate, *BillTemplate, error)
+
+	// ListTemplates lists the templates of a user
+	ListTemplates(ctx context.Context, userName string) ([]*BillTemplate, error)
+
+	// DeleteTemplate deletes one of the user's templates
+	DeleteTemplate(ctx context.Context, userName string, name string) (*BillTemplate, error)
+
+	// UseTemplate records a bill from a template, amount > 0 overrides the saved amount.
+	// An empty name uses the latest used or saved template; fails with ErrTemplateNotFound
+	UseTemplate(ctx context.Context, userName string, userID string, name string, amount float64, originalMsg string) (*Bill, *BillTemplate, error)
+
+	// SpendingTrends computes expense statistics and the recent trend within a time range
+	SpendingTrends(ctx context.Context, userName string, startTime, endTime time.Time) (*SpendingTrend, error)
+
+	// ForecastMonth extrapolates this month's expense to month end at the current pace
+	ForecastMonth(ctx context.Context, userName string, now time.Time) (*MonthForecast, error)
+
+	// SplitBill records the user's share of input.Amount split among several people; with recordOthers a shared
+	// ledger also records each named participant's share. The user's bill comes first in the result
+	SplitBill(ctx context.Context, userName string, userID string, input NewBillInput, split *Split, recordOthers bool) ([]*Bill, error)
+
+	// MemberBreakdown sums each member's income and expense in a shared ledger; fails with ErrLedgerNotShared
+	MemberBreakdown(ctx context.Context, startTime, endTime time.Time) ([]*MemberTotal, error)
+
+	// SettleUp splits a shared ledger's expenses among its members by weight and returns who pays whom
+	SettleUp(ctx context.Context, startTime, endTime time.Time, weights map[string]float64) (*Settlement, error)
+
+	// ListUsers lists the users who have set a name, ordered by platform ID
+	ListUsers(ctx context.Context) ([]*UserMapping, error)
+
+	// RenameUser sets the name of a user identified by the platform ID built with PlatformUserID
+	// and returns the name saved, which may carry a number when another user has the same name
+	RenameUser(ctx context.Context, platformID string, name string) (string, error)
+
+	// ForgetUser deletes a user's mapping and preferences; when recordsSince is not nil, the user's
+	// records dated after it are deleted first. Fails with ErrUserNotFound for unknown users
+	ForgetUser(ctx context.Context, platformID string, recordsSince *time.Time) (*ForgetUserResult, error)
+
+	// ExportUserData writes a zip archive with the user's mapping, preferences and all bills they
+	// recorded in the ledger of ctx to w, returning the number of bills. Fails with ErrUserNotFound
+	// before anything is written when the user is unknown
+	ExportUserData(ctx context.Context, platformID string, w io.Writer) (int, error)
+
+	// DeleteUserData deletes a user's mapping, preferences, bill templates and undo journal.
+	// The user's bills are left in place; see DeleteUserBills
+	DeleteUserData(ctx context.Context, platformID string) (*UserDataDeletion, error)
+
+	// CountUserBills counts all bills recorded by userName in the ledger of ctx
+	CountUserBills(ctx context.Context, userName string) (int, error)
+
+	// DeleteUserBills deletes all bills recorded by userName in the ledger of ctx, leaving
+	// other members' bills of a shared ledger untouched, and returns how many were deleted
+	DeleteUserBills(ctx context.Context, userName string) (int, error)
 }
 
 // CategorySuggestion represents category suggestion from AI
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// DuplicateBillError is returned when a new bill looks like a repeat of a bill recorded moments ago
+// (same description, amount and type), typically caused by sending the same message twice
+type DuplicateBillError struct {
+	Existing *Bill // 疑似重复的已有账单
+}
+
+func (e *DuplicateBillError) Error() string {
+	return fmt.Sprintf("possible duplicate of %s", e.Existing.RecordID)
+}
+
+// duplicatesAllowedKey is the context key that disables duplicate detection
+type duplicatesAllowedKey struct{}
+
+// WithDuplicatesAllowed returns a context under which bills are created without duplicate detection,
+// used after the user explicitly confirms a bill that was skipped as a duplicate
+func WithDuplicatesAllowed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, duplicatesAllowedKey{}, true)
+}
+
+// DuplicatesAllowed reports whether duplicate detection is disabled for ctx
+func DuplicatesAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(duplicatesAllowedKey{}).(bool)
+	return allowed
+}
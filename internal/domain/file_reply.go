@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+	"io"
+)
+
+// FileReplier sends a generated file as a reply to the chat message being processed
+type FileReplier func(ctx context.Context, fileName string, content io.ReadSeeker) error
+
+// fileReplierKey is the context key of the FileReplier
+type fileReplierKey struct{}
+
+// WithFileReplier returns a context whose requests can reply with file attachments
+func WithFileReplier(ctx context.Context, replier FileReplier) context.Context {
+	return context.WithValue(ctx, fileReplierKey{}, replier)
+}
+
+// FileReplierFromContext returns the FileReplier of ctx, or nil when the platform cannot send files
+func FileReplierFromContext(ctx context.Context) FileReplier {
+	replier, _ := ctx.Value(fileReplierKey{}).(FileReplier)
+	return replier
+}
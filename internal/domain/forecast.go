@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// CategoryForecast is the month-end forecast of one category
+type CategoryForecast struct {
+	Category string
+	Spent    float64
+	Forecast float64
+}
+
+// MonthForecast extrapolates this month's expense to month end at the current pace.
+// 今天还没有过完，日均支出只按今天之前的完整天数计算；借贷和收入不计入
+type MonthForecast struct {
+	Month        time.Time // 本月 1 日 0 点
+	Today        int       // 今天是几号
+	DaysInMonth  int
+	Spent        float64 // 本月截至目前的支出，含今天
+	SpentToday   float64
+	DailyAverage float64 // 今天之前每天的平均支出
+	Forecast     float64 // 预计本月总支出，Today 为 1 时没有完整的天数，等于 Spent
+	Categories   []CategoryForecast
+}
+
+// Estimated reports whether there are complete days to extrapolate from
+func (f *MonthForecast) Estimated() bool {
+	return f.Today > 1
+}
+
+// NewMonthForecast forecasts this month's expense from the bills recorded so far.
+// 日期按 now 所在的时区计算；今天按不低于日均计算，之后每天按日均计算；各分类按目前的支出占比分摊预测总额
+func NewMonthForecast(bills []*Bill, now time.Time) *MonthForecast {
+	today := startOfDay(now, now.Location())
+	month := today.AddDate(0, 0, 1-today.Day())
+	f := &MonthForecast{
+		Month:       month,
+		Today:       today.Day(),
+		DaysInMonth: month.AddDate(0, 1, -1).Day(),
+	}
+
+	spent := make(map[string]float64)
+	for _, bill := range bills {
+		if bill.Type == BillTypeIncome || bill.IsLoan() {
+			continue
+		}
+		day := startOfDay(bill.Date, now.Location())
+		if day.Before(month) || day.After(today) {
+			continue
+		}
+		f.Spent = AddAmount(f.Spent, bill.Amount)
+		if day.Equal(today) {
+			f.SpentToday = AddAmount(f.SpentToday, bill.Amount)
+		}
+		spent[bill.Category] = AddAmount(spent[bill.Category], bill.Amount)
+	}
+
+	f.Forecast = f.Spent
+	if f.Estimated() {
+		f.DailyAverage = (f.Spent - f.SpentToday) / float64(f.Today-1)
+		f.Forecast = f.Spent - f.SpentToday + math.Max(f.SpentToday, f.DailyAverage) + f.DailyAverage*float64(f.DaysInMonth-f.Today)
+	}
+
+	for category, amount := range spent {
+		c := CategoryForecast{Category: category, Spent: amount, Forecast: amount}
+		if f.Spent > 0 {
+			c.Forecast = f.Forecast * amount / f.Spent
+		}
+		f.Categories = append(f.Categories, c)
+	}
+	sort.Slice(f.Categories, func(i, j int) bool {
+		if f.Categories[i].Spent != f.Categories[j].Spent {
+			return f.Categories[i].Spent > f.Categories[j].Spent
+		}
+		return f.Categories[i].Category < f.Categories[j].Category
+	})
+	return f
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxInstallments is the largest number of installments a plan may have
+const MaxInstallments = 120
+
+// InstallmentTag is added to the tags of every installment bill
+const InstallmentTag = "分期"
+
+// ErrInstallmentPlanNotFound is returned when the requested installment plan does not exist
+var ErrInstallmentPlanNotFound = errors.New("installment plan not found")
+
+// InstallmentPlan splits a large purchase into monthly bills.
+// 首期在记账时创建，其余各期由后台任务在每月 1 日补记，Recorded 记录已补记到第几期
+type InstallmentPlan struct {
+	ID          string    `json:"id"`
+	UserName    string    `json:"user_name"`
+	UserID      string    `json:"user_id,omitempty"`
+	Ledger      string    `json:"ledger,omitempty"` // 群聊独立账本，默认账本为空
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	Account     string    `json:"account,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Total       float64   `json:"total"`
+	Count       int       `json:"count"`
+	StartMonth  time.Time `json:"start_month"` // 首期所在月份的 1 日
+	Recorded    int       `json:"recorded"`
+	RecordIDs   []string  `json:"record_ids,omitempty"` // 已创建账单的 record_id，按期数顺序
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Amount returns the amount of installment n (1-based). 每期金额按分取整，最后一期补齐差额
+func (p *InstallmentPlan) Amount(n int) float64 {
+	total := ToCents(p.Total)
+	per := total / int64(p.Count)
+	if n < p.Count {
+		return FromCents(per)
+	}
+	return FromCents(total - per*int64(p.Count-1))
+}
+
+// DueDate returns the date of installment n (1-based), the 1st of its month
+func (p *InstallmentPlan) DueDate(n int) time.Time {
+	return p.StartMonth.AddDate(0, n-1, 0)
+}
+
+// Label returns the label of installment n, e.g. "分期 3/12"
+func (p *InstallmentPlan) Label(n int) string {
+	return fmt.Sprintf("%s %d/%d", InstallmentTag, n, p.Count)
+}
+
+// Marker returns the original message of installment n created by the scheduler.
+// 后台任务据此判断该期是否已经记账，保证重启后不会重复补记
+func (p *InstallmentPlan) Marker(n int) string {
+	return fmt.Sprintf("[%s %s] %s", InstallmentTag, p.ID, p.Label(n))
+}
+
+// Input returns the bill input of installment n
+func (p *InstallmentPlan) Input(n int) NewBillInput {
+	date := p.DueDate(n)
+	return NewBillInput{
+		Description: p.Description + "（" + p.Label(n) + "）",
+		Amount:      p.Amount(n),
+		Type:        BillTypeExpense,
+		Date:        &date,
+		Category:    p.Category,
+		OriginalMsg: p.Marker(n),
+		Account:     p.Account,
+		Tags:        NormalizeTags(append(append([]string(nil), p.Tags...), InstallmentTag)),
+	}
+}
+
+// InstallmentRepository persists installment plans
+type InstallmentRepository interface {
+	SavePlan(plan *InstallmentPlan) error
+	GetPlan(id string) (*InstallmentPlan, error)
+	ListPlans() ([]*InstallmentPlan, error)
+	DeletePlan(id string) error
+}
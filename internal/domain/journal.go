@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OperationKind is the kind of a journaled bill operation
+type OperationKind string
+
+const (
+	OperationCreate OperationKind = "create"
+	OperationDelete OperationKind = "delete"
+	OperationUpdate OperationKind = "update"
+	OperationUndo   OperationKind = "undo" // 撤销本身，不能再次撤销
+)
+
+// JournalLimit is the number of operations kept per user
+const JournalLimit = 20
+
+// ErrNothingToUndo is returned when the user has no operation to undo
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrUndoUnsupported is returned when the last operation is itself an undo
+var ErrUndoUnsupported = errors.New("undoing an undo is not supported")
+
+// Operation is a bill operation recorded in the journal with enough state to invert it.
+// 新建只需 record_id；删除保存删除前的完整账单，用于恢复或重新创建；修改保存修改前后的账单
+type Operation struct {
+	Kind     OperationKind `json:"kind"`
+	RecordID string        `json:"record_id"`
+	Ledger   string        `json:"ledger,omitempty"`
+	Before   *Bill         `json:"before,omitempty"` // 删除、修改前的账单
+	After    *Bill         `json:"after,omitempty"`  // 新建的账单，或修改写入的字段
+	Undone   *Operation    `json:"undone,omitempty"` // 撤销操作对应的原操作
+	At       time.Time     `json:"at"`
+}
+
+// FieldChange is one field changed by an update, formatted for display
+type FieldChange struct {
+	Field string // description/amount/type/category/date/account/tags/reimbursable
+	Old   string
+	New   string
+}
+
+// Changes lists the fields an update operation changed, in a fixed order.
+// 修改只写入非空字段，After 中为空的字段视为未修改
+func (op *Operation) Changes() []FieldChange {
+	if op.Kind != OperationUpdate || op.Before == nil || op.After == nil {
+		return nil
+	}
+	before, after := op.Before, op.After
+	var changes []FieldChange
+	add := func(field, old, new string) {
+		if new != "" && old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	add("description", before.Description, after.Description)
+	if after.Amount > 0 {
+		add("amount", fmt.Sprintf("%.2f", before.Amount), fmt.Sprintf("%.2f", after.Amount))
+	}
+	add("type", string(before.Type), string(after.Type))
+	add("category", before.Category, after.Category)
+	if !after.Date.IsZero() {
+		add("date", before.Date.Format("2006-01-02"), after.Date.Format("2006-01-02"))
+	}
+	add("account", before.Account, after.Account)
+	if len(after.Tags) > 0 && !slices.Equal(before.Tags, after.Tags) {
+		add("tags", formatTagList(before.Tags), formatTagList(after.Tags))
+	}
+	if after.Reimbursable != nil && before.IsReimbursable() != *after.Reimbursable {
+		add("reimbursable", fmt.Sprint(before.IsReimbursable()), fmt.Sprint(*after.Reimbursable))
+	}
+	return changes
+}
+
+// formatTagList 以 #标签 形式拼接标签
+func formatTagList(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+// UndoResult describes what an undo reverted
+type UndoResult struct {
+	Undone    *Operation
+	Bill      *Bill // 撤销后的账单；撤销新建时为被删除的账单
+	Recreated bool  // 撤销删除时无法恢复原记录，按删除前的内容重新创建，Bill.RecordID 为新 ID
+}
+
+// OperationJournal keeps the latest bill operations of each user, at most JournalLimit per user
+type OperationJournal interface {
+	Push(userName string, op *Operation) error
+	Last(userName string) (*Operation, error) // 没有操作时返回 nil
+	Pop(userName string) error
+	Clear(userName string) error // 删除用户的全部操作
+}
+
+// operatorKey is the context key of the user performing bill operations
+type operatorKey struct{}
+
+// WithOperator returns a context whose bill operations are journaled for userName
+func WithOperator(ctx context.Context, userName string) context.Context {
+	return context.WithValue(ctx, operatorKey{}, userName)
+}
+
+// OperatorFromContext returns the user performing bill operations, empty if unknown
+func OperatorFromContext(ctx context.Context) string {
+	userName, _ := ctx.Value(operatorKey{}).(string)
+	return userName
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"strings"
+)
+
+// ledgerKey is the context key of the ledger a request operates on
+type ledgerKey struct{}
+
+// WithLedger returns a context whose bill operations go to the given ledger.
+// An empty ledger means the default ledger.
+func WithLedger(ctx context.Context, ledger string) context.Context {
+	return context.WithValue(ctx, ledgerKey{}, ledger)
+}
+
+// LedgerFromContext returns the ledger of ctx, empty for the default ledger
+func LedgerFromContext(ctx context.Context) string {
+	ledger, _ := ctx.Value(ledgerKey{}).(string)
+	return ledger
+}
+
+// LedgerRecordID appends the ledger to a record ID shown to users, e.g. "recXXX@oc_xxx".
+// Records in the default ledger keep the bare record ID.
+func LedgerRecordID(recordID, ledger string) string {
+	if ledger == "" || recordID == "" {
+		return recordID
+	}
+	return recordID + "@" + ledger
+}
+
+// SplitLedgerRecordID splits an ID built by LedgerRecordID into the record ID and its ledger
+func SplitLedgerRecordID(id string) (recordID, ledger string) {
+	recordID, ledger, _ = strings.Cut(id, "@")
+	return recordID, ledger
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CategoryLoan is the category of loans and repayments; these bills are left out of income and expense totals
+const CategoryLoan = "借贷"
+
+// LoanDirection is who lent the money
+type LoanDirection string
+
+const (
+	LoanLent     LoanDirection = "lent"     // 借出，对方欠我
+	LoanBorrowed LoanDirection = "borrowed" // 借入，我欠对方
+)
+
+// ErrNoOutstandingLoan is returned when settling with a counterparty that owes nothing
+var ErrNoOutstandingLoan = errors.New("no outstanding loan")
+
+// ErrLoanEntryNotFound is returned when a bill is not in the loan index
+var ErrLoanEntryNotFound = errors.New("loan entry not found")
+
+// ErrLoanOverpaid is returned when a repayment is larger than the outstanding balance
+var ErrLoanOverpaid = errors.New("repayment exceeds the outstanding balance")
+
+// IsLoan reports whether the bill is a loan or a repayment
+func (b *Bill) IsLoan() bool {
+	return b.Category == CategoryLoan
+}
+
+// SumBills returns the income and expense totals of bills, leaving out loans and repayments
+// 按分累加，合计不会因浮点误差产生偏差
+func SumBills(bills []*Bill) (income, expense float64) {
+	var incomeCents, expenseCents int64
+	for _, bill := range bills {
+		if bill.IsLoan() {
+			continue
+		}
+		if bill.Type == BillTypeIncome {
+			incomeCents += ToCents(bill.Amount)
+		} else {
+			expenseCents += ToCents(bill.Amount)
+		}
+	}
+	return FromCents(incomeCents), FromCents(expenseCents)
+}
+
+// LoanEntry indexes a loan or repayment bill by counterparty.
+// 账单本身写入账本（分类为借贷），这里只记录对方和方向，用于计算未结清的余额
+type LoanEntry struct {
+	RecordID     string        `json:"record_id"`
+	UserName     string        `json:"user_name"`
+	Ledger       string        `json:"ledger,omitempty"`
+	Counterparty string        `json:"counterparty"`
+	Direction    LoanDirection `json:"direction"`
+	Repayment    bool          `json:"repayment,omitempty"` // 还款，Direction 为被偿还的借款方向
+	Amount       float64       `json:"amount"`
+	Date         time.Time     `json:"date"`
+	Links        []string      `json:"links,omitempty"`   // 还款对应的借款 record_id
+	Deleted      bool          `json:"deleted,omitempty"` // 账单已删除，恢复账单时清除
+}
+
+// LoanBalance is the outstanding balance with one counterparty
+type LoanBalance struct {
+	Counterparty string
+	Amount       float64  // 对方欠我的金额，负数表示我欠对方
+	Open         []string // 上次结清以来的借款 record_id
+}
+
+// LoanInput is the input for recording a loan
+type LoanInput struct {
+	Direction    LoanDirection
+	Counterparty string
+	Amount       float64
+	OriginalMsg  string
+}
+
+// NormalizeCounterparty trims the counterparty name so "小王 " and "小王" share one balance
+func NormalizeCounterparty(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// signedAmount 借出和收到还款记正数方向的变化：借出 +，借入 -，对方还款 -，我还款 +
+func (e *LoanEntry) signedAmount() float64 {
+	amount := e.Amount
+	if e.Direction == LoanBorrowed {
+		amount = -amount
+	}
+	if e.Repayment {
+		amount = -amount
+	}
+	return amount
+}
+
+// LoanBalances sums entries into outstanding balances per counterparty, skipping deleted entries
+// and settled counterparties. 结果按对方名称排序
+func LoanBalances(entries []*LoanEntry) []*LoanBalance {
+	byName := make(map[string]*LoanBalance)
+	sorted := append([]*LoanEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	for _, entry := range sorted {
+		if entry.Deleted {
+			continue
+		}
+		balance, ok := byName[entry.Counterparty]
+		if !ok {
+			balance = &LoanBalance{Counterparty: entry.Counterparty}
+			byName[entry.Counterparty] = balance
+		}
+		balance.Amount = AddAmount(balance.Amount, entry.signedAmount())
+		if !entry.Repayment {
+			balance.Open = append(balance.Open, entry.RecordID)
+		}
+		// 结清后之前的借款不再关联到新的还款
+		if balance.Amount == 0 {
+			balance.Open = nil
+		}
+	}
+
+	var balances []*LoanBalance
+	for _, balance := range byName {
+		if balance.Amount != 0 {
+			balances = append(balances, balance)
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Counterparty < balances[j].Counterparty })
+	return balances
+}
+
+// LoanRepository persists the loan index
+type LoanRepository interface {
+	SaveEntry(entry *LoanEntry) error
+	GetEntry(recordID string) (*LoanEntry, error)
+	ListEntries(userName string) ([]*LoanEntry, error)
+}
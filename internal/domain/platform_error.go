@@ -0,0 +1,24 @@
+package domain
+
+import "errors"
+
+// RequestIDFromError returns the request ID of the failed platform API call wrapped in err,
+// such as the Feishu log ID, or "" when err carries none
+func RequestIDFromError(err error) string {
+	var withID interface{ PlatformRequestID() string }
+	if errors.As(err, &withID) {
+		return withID.PlatformRequestID()
+	}
+	return ""
+}
+
+// IsPermanentError reports whether err is a platform API error that will fail again if retried,
+// such as a rejected field value or a missing permission. Other errors, including network
+// failures, are treated as temporary
+func IsPermanentError(err error) bool {
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return !retryable.Retryable()
+	}
+	return false
+}
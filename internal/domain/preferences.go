@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// UserPreferences holds per-user settings; empty fields fall back to the bot's configuration
+type UserPreferences struct {
+	Currency string `json:"currency,omitempty"` // 金额使用的货币符号，如 "$"
+	Timezone string `json:"timezone,omitempty"` // IANA 时区名，如 "America/New_York"
+	Language string `json:"language,omitempty"` // 回复语言：zh 或 en
+	TopN     int    `json:"top_n,omitempty"`    // 查询时默认列出的明细条数
+}
+
+// Preference names accepted by UserPreferences.Set
+const (
+	PreferenceCurrency = "currency"
+	PreferenceTimezone = "timezone"
+	PreferenceLanguage = "language"
+	PreferenceTopN     = "top_n"
+)
+
+// 偏好取值的限制
+const (
+	maxCurrencyLength = 8
+	maxPreferenceTopN = 50
+)
+
+// ErrInvalidPreference is returned when a preference name or value is not accepted
+var ErrInvalidPreference = errors.New("invalid preference")
+
+// preferenceAliases 偏好名称的常用说法，统一为 Preference* 常量
+var preferenceAliases = map[string]string{
+	"currency": PreferenceCurrency, "货币": PreferenceCurrency, "币种": PreferenceCurrency, "货币符号": PreferenceCurrency,
+	"timezone": PreferenceTimezone, "时区": PreferenceTimezone, "tz": PreferenceTimezone,
+	"language": PreferenceLanguage, "语言": PreferenceLanguage, "lang": PreferenceLanguage,
+	"top_n": PreferenceTopN, "topn": PreferenceTopN, "条数": PreferenceTopN, "明细条数": PreferenceTopN,
+}
+
+// languageAliases 语言的常用说法，统一为 zh / en
+var languageAliases = map[string]string{
+	"zh": "zh", "中文": "zh", "chinese": "zh", "汉语": "zh",
+	"en": "en", "英文": "en", "english": "en", "英语": "en",
+}
+
+// ParsePreferenceName returns the canonical name of a preference, accepting common Chinese aliases
+func ParsePreferenceName(name string) (string, bool) {
+	key, ok := preferenceAliases[strings.ToLower(strings.TrimSpace(name))]
+	return key, ok
+}
+
+// Set validates value and stores it under the named preference.
+// An empty value or "default"/"默认" clears the preference so the configured default applies again
+func (p *UserPreferences) Set(name, value string) error {
+	key, ok := ParsePreferenceName(name)
+	if !ok {
+		return fmt.Errorf("%w: unknown preference %q", ErrInvalidPreference, name)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, "default") || value == "默认" {
+		p.clear(key)
+		return nil
+	}
+
+	switch key {
+	case PreferenceCurrency:
+		if utf8.RuneCountInString(value) > maxCurrencyLength {
+			return fmt.Errorf("%w: currency symbol %q is too long", ErrInvalidPreference, value)
+		}
+		p.Currency = value
+	case PreferenceTimezone:
+		if _, err := time.LoadLocation(value); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreference, value)
+		}
+		p.Timezone = value
+	case PreferenceLanguage:
+		lang, ok := languageAliases[strings.ToLower(value)]
+		if !ok {
+			return fmt.Errorf("%w: unsupported language %q", ErrInvalidPreference, value)
+		}
+		p.Language = lang
+	case PreferenceTopN:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPreferenceTopN {
+			return fmt.Errorf("%w: top_n must be between 1 and %d", ErrInvalidPreference, maxPreferenceTopN)
+		}
+		p.TopN = n
+	}
+	return nil
+}
+
+// clear 清除单项偏好
+func (p *UserPreferences) clear(key string) {
+	switch key {
+	case PreferenceCurrency:
+		p.Currency = ""
+	case PreferenceTimezone:
+		p.Timezone = ""
+	case PreferenceLanguage:
+		p.Language = ""
+	case PreferenceTopN:
+		p.TopN = 0
+	}
+}
+
+// Location returns the preferred time zone, or fallback when none is set or it cannot be loaded
+func (p UserPreferences) Location(fallback *time.Location) *time.Location {
+	if p.Timezone == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
+
+// PreferenceSaver persists the preferences of the user sending the current message
+type PreferenceSaver func(prefs UserPreferences) error
+
+// userPreferencesValue 上下文中保存的用户偏好及其保存方法
+type userPreferencesValue struct {
+	prefs UserPreferences
+	save  PreferenceSaver
+}
+
+// userPreferencesKey is the context key of the current user's preferences
+type userPreferencesKey struct{}
+
+// WithPreferences returns a context carrying the current user's preferences; save may be nil
+// when the preferences cannot be changed from this request
+func WithPreferences(ctx context.Context, prefs UserPreferences, save PreferenceSaver) context.Context {
+	return context.WithValue(ctx, userPreferencesKey{}, userPreferencesValue{prefs: prefs, save: save})
+}
+
+// PreferencesFromContext returns the current user's preferences, or empty preferences when none are set
+func PreferencesFromContext(ctx context.Context) UserPreferences {
+	value, _ := ctx.Value(userPreferencesKey{}).(userPreferencesValue)
+	return value.prefs
+}
+
+// PreferenceSaverFromContext returns the PreferenceSaver of ctx, or nil when preferences cannot be changed
+func PreferenceSaverFromContext(ctx context.Context) PreferenceSaver {
+	value, _ := ctx.Value(userPreferencesKey{}).(userPreferencesValue)
+	return value.save
+}
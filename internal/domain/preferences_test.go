@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserPreferencesSet(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        UserPreferences
+	}{
+		{"currency", "$", UserPreferences{Currency: "$"}},
+		{"货币", " € ", UserPreferences{Currency: "€"}},
+		{"时区", "America/New_York", UserPreferences{Timezone: "America/New_York"}},
+		{"TZ", "Asia/Shanghai", UserPreferences{Timezone: "Asia/Shanghai"}},
+		{"语言", "英文", UserPreferences{Language: "en"}},
+		{"language", "Chinese", UserPreferences{Language: "zh"}},
+		{"top_n", "10", UserPreferences{TopN: 10}},
+		{"明细条数", "50", UserPreferences{TopN: 50}},
+	}
+	for _, tt := range tests {
+		var prefs UserPreferences
+		if err := prefs.Set(tt.name, tt.value); err != nil || prefs != tt.want {
+			t.Errorf("Set(%q, %q) = %+v, %v, want %+v", tt.name, tt.value, prefs, err, tt.want)
+		}
+	}
+
+	invalid := []struct{ name, value string }{
+		{"颜色", "红"},
+		{"currency", "人民币元人民币元人民币"},
+		{"timezone", "Mars/Olympus"},
+		{"language", "fr"},
+		{"top_n", "0"},
+		{"top_n", "51"},
+		{"top_n", "五"},
+	}
+	for _, tt := range invalid {
+		prefs := UserPreferences{Currency: "$", TopN: 3}
+		if err := prefs.Set(tt.name, tt.value); !errors.Is(err, ErrInvalidPreference) {
+			t.Errorf("Set(%q, %q) = %v, want ErrInvalidPreference", tt.name, tt.value, err)
+		}
+		// 无效的取值不改变已有的偏好
+		if prefs != (UserPreferences{Currency: "$", TopN: 3}) {
+			t.Errorf("Set(%q, %q) changed preferences to %+v", tt.name, tt.value, prefs)
+		}
+	}
+}
+
+// 空值、default 和“默认”清除单项偏好，恢复全局配置
+func TestUserPreferencesReset(t *testing.T) {
+	full := UserPreferences{Currency: "$", Timezone: "America/New_York", Language: "en", TopN: 3}
+	tests := []struct {
+		name, value string
+		want        UserPreferences
+	}{
+		{"currency", "", UserPreferences{Timezone: "America/New_York", Language: "en", TopN: 3}},
+		{"时区", "默认", UserPreferences{Currency: "$", Language: "en", TopN: 3}},
+		{"language", "Default", UserPreferences{Currency: "$", Timezone: "America/New_York", TopN: 3}},
+		{"条数", " ", UserPreferences{Currency: "$", Timezone: "America/New_York", Language: "en"}},
+	}
+	for _, tt := range tests {
+		prefs := full
+		if err := prefs.Set(tt.name, tt.value); err != nil || prefs != tt.want {
+			t.Errorf("Set(%q, %q) = %+v, %v, want %+v", tt.name, tt.value, prefs, err, tt.want)
+		}
+	}
+}
+
+func TestUserPreferencesLocation(t *testing.T) {
+	fallback := time.FixedZone("CST", 8*3600)
+	if loc := (UserPreferences{}).Location(fallback); loc != fallback {
+		t.Errorf("Location without a timezone = %v, want the fallback", loc)
+	}
+	if loc := (UserPreferences{Timezone: "America/New_York"}).Location(fallback); loc.String() != "America/New_York" {
+		t.Errorf("Location = %v, want America/New_York", loc)
+	}
+	// 文件中保存了无法识别的时区时回退到全局配置
+	if loc := (UserPreferences{Timezone: "Mars/Olympus"}).Location(fallback); loc != fallback {
+		t.Errorf("Location with an unknown timezone = %v, want the fallback", loc)
+	}
+}
+
+func TestPreferencesContext(t *testing.T) {
+	ctx := context.Background()
+	if prefs := PreferencesFromContext(ctx); prefs != (UserPreferences{}) {
+		t.Errorf("PreferencesFromContext without preferences = %+v, want empty", prefs)
+	}
+	if save := PreferenceSaverFromContext(ctx); save != nil {
+		t.Error("PreferenceSaverFromContext without preferences returned a saver")
+	}
+
+	var saved UserPreferences
+	prefs := UserPreferences{Currency: "$"}
+	ctx = WithPreferences(ctx, prefs, func(p UserPreferences) error {
+		saved = p
+		return nil
+	})
+	if got := PreferencesFromContext(ctx); got != prefs {
+		t.Errorf("PreferencesFromContext = %+v, want %+v", got, prefs)
+	}
+	save := PreferenceSaverFromContext(ctx)
+	if save == nil {
+		t.Fatal("PreferenceSaverFromContext = nil, want the saver")
+	}
+	if err := save(UserPreferences{TopN: 3}); err != nil || saved.TopN != 3 {
+		t.Errorf("saver stored %+v, %v", saved, err)
+	}
+}
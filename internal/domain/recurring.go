@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RecurringCadence is how often a recurring rule fires
+type RecurringCadence string
+
+const (
+	RecurringMonthly RecurringCadence = "monthly" // 每月固定日期，如房租
+	RecurringWeekly  RecurringCadence = "weekly"  // 每周固定星期几
+)
+
+// RecurringTag marks the original message of bills created by recurring rules
+const RecurringTag = "周期"
+
+// ErrRecurringRuleNotFound is returned when the requested recurring rule does not exist
+var ErrRecurringRuleNotFound = errors.New("recurring rule not found")
+
+// RecurringRule records a bill automatically on a monthly or weekly schedule.
+// LastRun 是最近一次已记账的日期（新建时为创建时间），重启后从这里继续，不会重复记账
+type RecurringRule struct {
+	ID          string           `json:"id"`
+	UserName    string           `json:"user_name"`
+	UserID      string           `json:"user_id,omitempty"` // 平台用户 ID，用于私聊发送记账通知
+	Ledger      string           `json:"ledger,omitempty"`  // 群聊独立账本，默认账本为空
+	Description string           `json:"description"`
+	Amount      float64          `json:"amount"`
+	Type        BillType         `json:"type"`
+	Category    string           `json:"category"`
+	Cadence     RecurringCadence `json:"cadence"`
+	DayOfMonth  int              `json:"day_of_month,omitempty"` // 1-31，超过当月天数时在月末记账
+	Weekday     time.Weekday     `json:"weekday"`                // 每周规则使用，0 为周日
+	LastRun     time.Time        `json:"last_run"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// Validate checks the amount and schedule of the rule
+func (r *RecurringRule) Validate() error {
+	if r.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	switch r.Cadence {
+	case RecurringMonthly:
+		if r.DayOfMonth < 1 || r.DayOfMonth > 31 {
+			return fmt.Errorf("day of month must be between 1 and 31")
+		}
+	case RecurringWeekly:
+		if r.Weekday < time.Sunday || r.Weekday > time.Saturday {
+			return fmt.Errorf("invalid weekday: %d", r.Weekday)
+		}
+	default:
+		return fmt.Errorf("invalid cadence: %s", r.Cadence)
+	}
+	return nil
+}
+
+// Next returns the first run date (midnight) strictly after the given time.
+// 日期按 after 所在的时区计算，调用方传入配置的时区（TIMEZONE）中的时间
+func (r *RecurringRule) Next(after time.Time) time.Time {
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, after.Location())
+
+	if r.Cadence == RecurringWeekly {
+		next := day.AddDate(0, 0, (int(r.Weekday)-int(day.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+
+	next := r.monthDay(day.Year(), day.Month(), day.Location())
+	if !next.After(after) {
+		next = r.monthDay(day.Year(), day.Month()+1, day.Location())
+	}
+	return next
+}
+
+// monthDay 返回指定月份的记账日，超过当月天数时取月末
+func (r *RecurringRule) monthDay(year int, month time.Month, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := r.DayOfMonth
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// Marker returns the original message of the bill created on the given run date.
+// 后台任务据此判断当天是否已经记账
+func (r *RecurringRule) Marker(date time.Time) string {
+	return fmt.Sprintf("[%s %s] %s", RecurringTag, r.ID, date.Format("2006-01-02"))
+}
+
+// Input returns the bill input of the given run date
+func (r *RecurringRule) Input(date time.Time) NewBillInput {
+	return NewBillInput{
+		Description: r.Description,
+		Amount:      r.Amount,
+		Type:        r.Type,
+		Date:        &date,
+		Category:    r.Category,
+		OriginalMsg: r.Marker(date),
+	}
+}
+
+// RecurringRun is a bill created by a recurring rule
+type RecurringRun struct {
+	Rule *RecurringRule
+	Bill *Bill
+}
+
+// RecurringRepository persists recurring rules
+type RecurringRepository interface {
+	SaveRule(rule *RecurringRule) error
+	GetRule(id string) (*RecurringRule, error)
+	ListRules() ([]*RecurringRule, error)
+	DeleteRule(id string) error
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// 记账日按传入时间所在的时区计算，与运行测试的机器时区无关
+func TestRecurringRuleNextLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	rule := &RecurringRule{Cadence: RecurringMonthly, DayOfMonth: 1}
+
+	// UTC 4 月 30 日 20:00 在上海已是 5 月 1 日，下一次是 6 月 1 日
+	after := time.Date(2024, time.April, 30, 20, 0, 0, 0, time.UTC).In(shanghai)
+	if got, want := rule.Next(after), time.Date(2024, time.June, 1, 0, 0, 0, 0, shanghai); !got.Equal(want) {
+		t.Errorf("Next in Shanghai = %s, want %s", got, want)
+	}
+	if got, want := rule.Next(after.In(time.UTC)), time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next in UTC = %s, want %s", got, want)
+	}
+}
+
+func TestRecurringRuleNext(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		rule  RecurringRule
+		after time.Time
+		want  time.Time
+	}{
+		// 超过当月天数时在月末记账，闰年为 2 月 29 日
+		{"month end", RecurringRule{Cadence: RecurringMonthly, DayOfMonth: 31}, day(2024, time.February, 1), day(2024, time.February, 29)},
+		{"after month end", RecurringRule{Cadence: RecurringMonthly, DayOfMonth: 31}, day(2024, time.February, 29), day(2024, time.March, 31)},
+		{"across year", RecurringRule{Cadence: RecurringMonthly, DayOfMonth: 15}, day(2024, time.December, 20), day(2025, time.January, 15)},
+		// 2024-05-06 是周一，记账日当天零点不算“之后”
+		{"weekly", RecurringRule{Cadence: RecurringWeekly, Weekday: time.Monday}, day(2024, time.May, 6), day(2024, time.May, 13)},
+		{"weekly later this week", RecurringRule{Cadence: RecurringWeekly, Weekday: time.Friday}, day(2024, time.May, 6), day(2024, time.May, 10)},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.name, tt.after.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+// 本月预测按 now 所在时区的日期统计
+func TestNewMonthForecastLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	now := time.Date(2024, time.May, 10, 12, 0, 0, 0, shanghai)
+	bills := []*Bill{
+		// UTC 4 月 30 日 17:00 是上海 5 月 1 日凌晨，算作本月
+		{Amount: 30, Type: BillTypeExpense, Category: "餐饮", Date: time.Date(2024, time.April, 30, 17, 0, 0, 0, time.UTC)},
+		// UTC 4 月 30 日 15:00 是上海 4 月 30 日，不算
+		{Amount: 70, Type: BillTypeExpense, Category: "餐饮", Date: time.Date(2024, time.April, 30, 15, 0, 0, 0, time.UTC)},
+	}
+	f := NewMonthForecast(bills, now)
+	if f.Spent != 30 || f.Today != 10 || f.DaysInMonth != 31 {
+		t.Errorf("forecast = spent %.2f, day %d/%d, want 30 on day 10/31", f.Spent, f.Today, f.DaysInMonth)
+	}
+}
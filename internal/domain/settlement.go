@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrLedgerNotShared is returned by member statistics outside a shared ledger
+var ErrLedgerNotShared = errors.New("the ledger is not shared")
+
+// sharedLedgerKey is the context key marking a shared ledger
+type sharedLedgerKey struct{}
+
+// WithSharedLedger returns a context whose queries aggregate the bills of all members of the ledger
+func WithSharedLedger(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sharedLedgerKey{}, true)
+}
+
+// IsSharedLedger reports whether ctx operates on a shared ledger
+func IsSharedLedger(ctx context.Context) bool {
+	shared, _ := ctx.Value(sharedLedgerKey{}).(bool)
+	return shared
+}
+
+// MemberTotal is the income and expense of one member of a shared ledger
+type MemberTotal struct {
+	UserName string
+	Income   float64
+	Expense  float64
+	Count    int
+}
+
+// MemberTotals sums bills per member ordered by expense, leaving out loans and repayments
+func MemberTotals(bills []*Bill) []*MemberTotal {
+	byName := make(map[string]*MemberTotal)
+	for _, bill := range bills {
+		if bill.IsLoan() {
+			continue
+		}
+		total, ok := byName[bill.UserName]
+		if !ok {
+			total = &MemberTotal{UserName: bill.UserName}
+			byName[bill.UserName] = total
+		}
+		if bill.Type == BillTypeIncome {
+			total.Income = AddAmount(total.Income, bill.Amount)
+		} else {
+			total.Expense = AddAmount(total.Expense, bill.Amount)
+		}
+		total.Count++
+	}
+
+	totals := make([]*MemberTotal, 0, len(byName))
+	for _, total := range byName {
+		totals = append(totals, total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Expense != totals[j].Expense {
+			return totals[i].Expense > totals[j].Expense
+		}
+		return totals[i].UserName < totals[j].UserName
+	})
+	return totals
+}
+
+// MemberShare is what one member paid and owes in a settlement
+type MemberShare struct {
+	UserName string
+	Paid     float64
+	Share    float64 // 按权重应承担的金额
+	Balance  float64 // Paid - Share，正数表示应收回，负数表示应补出
+}
+
+// Transfer is one payment that settles a shared ledger: From pays To the amount
+type Transfer struct {
+	From   string
+	To     string
+	Amount float64
+}
+
+// Settlement splits the expenses of a shared ledger among its members (AA 结算)
+type Settlement struct {
+	Total     float64
+	Members   []MemberShare
+	Transfers []Transfer
+}
+
+// Settle splits the total expense by weight and returns the transfers that settle it.
+// paid 为每个成员支付的支出；weights 中未出现的成员权重为 1，权重为 0 的成员不分摊；
+// 金额按分计算，分摊不尽的零头依次分给排在前面的成员，保证收付相等
+func Settle(paid map[string]float64, weights map[string]float64) *Settlement {
+	members := make([]string, 0, len(paid))
+	for name := range paid {
+		members = append(members, name)
+	}
+	for name, weight := range weights {
+		if _, ok := paid[name]; !ok && weight > 0 {
+			members = append(members, name)
+		}
+	}
+	sort.Strings(members)
+
+	weightOf := func(name string) float64 {
+		if weight, ok := weights[name]; ok {
+			return math.Max(weight, 0)
+		}
+		return 1
+	}
+	totalWeight := 0.0
+	totalCents := int64(0)
+	for _, name := range members {
+		totalWeight += weightOf(name)
+		totalCents += ToCents(paid[name])
+	}
+
+	settlement := &Settlement{Total: FromCents(totalCents)}
+	if totalWeight == 0 || len(members) == 0 {
+		return settlement
+	}
+
+	// 按权重分摊，先向下取整，余下的零头逐分分配
+	shares := make([]int64, len(members))
+	assigned := int64(0)
+	for i, name := range members {
+		shares[i] = int64(math.Floor(float64(totalCents) * weightOf(name) / totalWeight))
+		assigned += shares[i]
+	}
+	for i := 0; assigned < totalCents; i = (i + 1) % len(members) {
+		if weightOf(members[i]) > 0 {
+			shares[i]++
+			assigned++
+		}
+	}
+
+	balances := make([]int64, len(members))
+	for i, name := range members {
+		balances[i] = ToCents(paid[name]) - shares[i]
+		settlement.Members = append(settlement.Members, MemberShare{
+			UserName: name,
+			Paid:     FromCents(ToCents(paid[name])),
+			Share:    FromCents(shares[i]),
+			Balance:  FromCents(balances[i]),
+		})
+	}
+	settlement.Transfers = settleBalances(members, balances)
+	return settlement
+}
+
+// settleBalances 贪心地让欠得最多的人付给应收最多的人，最多产生 n-1 笔转账
+func settleBalances(members []string, balances []int64) []Transfer {
+	type party struct {
+		name  string
+		cents int64
+	}
+	var debtors, creditors []party
+	for i, name := range members {
+		switch {
+		case balances[i] < 0:
+			debtors = append(debtors, party{name, -balances[i]})
+		case balances[i] > 0:
+			creditors = append(creditors, party{name, balances[i]})
+		}
+	}
+	byAmount := func(parties []party) {
+		sort.SliceStable(parties, func(i, j int) bool { return parties[i].cents > parties[j].cents })
+	}
+	byAmount(debtors)
+	byAmount(creditors)
+
+	var transfers []Transfer
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := debtors[i].cents
+		if creditors[j].cents < amount {
+			amount = creditors[j].cents
+		}
+		transfers = append(transfers, Transfer{From: debtors[i].name, To: creditors[j].name, Amount: FromCents(amount)})
+		debtors[i].cents -= amount
+		creditors[j].cents -= amount
+		if debtors[i].cents == 0 {
+			i++
+		}
+		if creditors[j].cents == 0 {
+			j++
+		}
+	}
+	return transfers
+}
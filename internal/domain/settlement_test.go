@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSettleTwoMembers(t *testing.T) {
+	s := Settle(map[string]float64{"张三": 300, "李四": 53}, nil)
+	if s.Total != 353 {
+		t.Errorf("Total = %v, want 353", s.Total)
+	}
+	wantMembers := []MemberShare{
+		{UserName: "张三", Paid: 300, Share: 176.5, Balance: 123.5},
+		{UserName: "李四", Paid: 53, Share: 176.5, Balance: -123.5},
+	}
+	if !reflect.DeepEqual(s.Members, wantMembers) {
+		t.Errorf("Members = %+v, want %+v", s.Members, wantMembers)
+	}
+	if want := []Transfer{{From: "李四", To: "张三", Amount: 123.5}}; !reflect.DeepEqual(s.Transfers, want) {
+		t.Errorf("Transfers = %+v, want %+v", s.Transfers, want)
+	}
+
+	// 两人支付相同时不需要转账
+	if s := Settle(map[string]float64{"张三": 50, "李四": 50}, nil); len(s.Transfers) != 0 {
+		t.Errorf("Transfers when even = %+v, want none", s.Transfers)
+	}
+}
+
+func TestSettleManyMembers(t *testing.T) {
+	tests := []struct {
+		name      string
+		paid      map[string]float64
+		weights   map[string]float64
+		shares    []float64 // 按成员名排序
+		transfers []Transfer
+	}{
+		{
+			name:      "three members",
+			paid:      map[string]float64{"张三": 100, "李四": 50, "王五": 0},
+			shares:    []float64{50, 50, 50},
+			transfers: []Transfer{{From: "王五", To: "张三", Amount: 50}},
+		},
+		{
+			// 分不尽的 1 分给排在最前的成员
+			name:   "three members with a leftover cent",
+			paid:   map[string]float64{"张三": 100, "李四": 0, "王五": 0},
+			shares: []float64{33.34, 33.33, 33.33},
+			transfers: []Transfer{
+				{From: "李四", To: "张三", Amount: 33.33},
+				{From: "王五", To: "张三", Amount: 33.33},
+			},
+		},
+		{
+			// 欠得最多的先付，最多 n-1 笔转账
+			name:   "four members",
+			paid:   map[string]float64{"张三": 400, "李四": 100, "王五": 0, "赵六": 0},
+			shares: []float64{125, 125, 125, 125},
+			transfers: []Transfer{
+				{From: "王五", To: "张三", Amount: 125},
+				{From: "赵六", To: "张三", Amount: 125},
+				{From: "李四", To: "张三", Amount: 25},
+			},
+		},
+		{
+			name:      "weighted",
+			paid:      map[string]float64{"张三": 0, "李四": 300},
+			weights:   map[string]float64{"张三": 2},
+			shares:    []float64{200, 100},
+			transfers: []Transfer{{From: "张三", To: "李四", Amount: 200}},
+		},
+		{
+			// 权重为 0 的成员不分摊；只在权重中出现的成员也参与分摊
+			name:    "zero weight and weight-only member",
+			paid:    map[string]float64{"张三": 10, "王五": 90},
+			weights: map[string]float64{"王五": 0, "李四": 1},
+			shares:  []float64{50, 50, 0},
+			transfers: []Transfer{
+				{From: "李四", To: "王五", Amount: 50},
+				{From: "张三", To: "王五", Amount: 40},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Settle(tt.paid, tt.weights)
+			var shares []float64
+			for _, m := range s.Members {
+				shares = append(shares, m.Share)
+			}
+			if !reflect.DeepEqual(shares, tt.shares) {
+				t.Errorf("shares = %v, want %v", shares, tt.shares)
+			}
+			if !reflect.DeepEqual(s.Transfers, tt.transfers) {
+				t.Errorf("Transfers = %+v, want %+v", s.Transfers, tt.transfers)
+			}
+		})
+	}
+}
+
+// 按分计算，分摊合计等于总额，转账后每人余额为 0
+func TestSettleBalances(t *testing.T) {
+	cases := []map[string]float64{
+		{"a": 0.01, "b": 0, "c": 0},
+		{"a": 99.99, "b": 0.1, "c": 0.2, "d": 33.33, "e": 7},
+		{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6, "g": 7},
+	}
+	weights := []map[string]float64{nil, {"a": 1.5, "c": 0.5}, {"g": 3}}
+	for i, paid := range cases {
+		s := Settle(paid, weights[i])
+		var shareCents, balanceCents int64
+		net := make(map[string]int64)
+		for _, m := range s.Members {
+			shareCents += ToCents(m.Share)
+			balanceCents += ToCents(m.Balance)
+			net[m.UserName] = ToCents(m.Balance)
+		}
+		if shareCents != ToCents(s.Total) || balanceCents != 0 {
+			t.Errorf("case %d: shares sum to %d cents and balances to %d, want %d and 0", i, shareCents, balanceCents, ToCents(s.Total))
+		}
+		for _, tr := range s.Transfers {
+			net[tr.From] += ToCents(tr.Amount)
+			net[tr.To] -= ToCents(tr.Amount)
+		}
+		for name, cents := range net {
+			if cents != 0 {
+				t.Errorf("case %d: %s is off by %d cents after the transfers", i, name, cents)
+			}
+		}
+		if len(s.Transfers) >= len(s.Members) {
+			t.Errorf("case %d: %d transfers for %d members", i, len(s.Transfers), len(s.Members))
+		}
+	}
+}
+
+func TestSettleEmpty(t *testing.T) {
+	if s := Settle(nil, nil); s.Total != 0 || len(s.Members) != 0 || len(s.Transfers) != 0 {
+		t.Errorf("Settle(nil) = %+v, want empty", s)
+	}
+	// 所有成员权重都为 0 时无法分摊
+	if s := Settle(map[string]float64{"张三": 10}, map[string]float64{"张三": 0}); s.Total != 10 || len(s.Transfers) != 0 {
+		t.Errorf("Settle with zero weights = %+v, want only the total", s)
+	}
+}
+
+// 借贷不计入成员合计，按支出从高到低排序
+func TestMemberTotals(t *testing.T) {
+	bills := []*Bill{
+		{UserName: "李四", Amount: 30, Type: BillTypeExpense},
+		{UserName: "张三", Amount: 10.1, Type: BillTypeExpense},
+		{UserName: "张三", Amount: 20.2, Type: BillTypeExpense},
+		{UserName: "李四", Amount: 5000, Type: BillTypeIncome},
+		{UserName: "张三", Amount: 1000, Type: BillTypeExpense, Category: CategoryLoan},
+		{UserName: "王五", Amount: 8, Type: BillTypeIncome},
+	}
+	want := []*MemberTotal{
+		{UserName: "张三", Expense: 30.3, Count: 2},
+		{UserName: "李四", Income: 5000, Expense: 30, Count: 2},
+		{UserName: "王五", Income: 8, Count: 1},
+	}
+	got := MemberTotals(bills)
+	if len(got) != len(want) {
+		t.Fatalf("MemberTotals returned %d members, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("member %d = %+v, want %+v", i, *got[i], *want[i])
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "context"
+
+// sourceMessageKey is the context key of the chat message that triggered a request
+type sourceMessageKey struct{}
+
+// WithSourceMessage returns a context carrying the ID of the chat message being processed.
+// Bills created under this context are remembered per message, so reprocessing the same
+// message (e.g. a replayed webhook) returns the existing bills instead of writing new rows.
+func WithSourceMessage(ctx context.Context, messageID string) context.Context {
+	return context.WithValue(ctx, sourceMessageKey{}, messageID)
+}
+
+// SourceMessageFromContext returns the source message ID of ctx, or ""
+func SourceMessageFromContext(ctx context.Context) string {
+	messageID, _ := ctx.Value(sourceMessageKey{}).(string)
+	return messageID
+}
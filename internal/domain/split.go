@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxSplitPeople is the largest number of people an expense can be split among
+const MaxSplitPeople = 50
+
+// ErrInvalidSplit is returned when an expense cannot be split among the given people
+var ErrInvalidSplit = errors.New("invalid split")
+
+// Split describes an expense shared among several people, the recorder being one of them (AA 分摊)
+type Split struct {
+	Total        float64
+	Participants []string // 除记账人以外的参与者姓名，只知道人数时可以为空
+	Count        int      // 总人数，含记账人
+}
+
+// NewSplit builds a split of total among the recorder and the named participants.
+// count 为总人数（含记账人），小于已知人数时按已知人数计算
+func NewSplit(total float64, participants []string, count int) (*Split, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range participants {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if count < len(names)+1 {
+		count = len(names) + 1
+	}
+
+	switch {
+	case total <= 0:
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidSplit)
+	case count < 2 || count > MaxSplitPeople:
+		return nil, fmt.Errorf("%w: %d people, must be between 2 and %d", ErrInvalidSplit, count, MaxSplitPeople)
+	case ToCents(total) < int64(count):
+		return nil, fmt.Errorf("%w: %.2f is too small to split among %d people", ErrInvalidSplit, total, count)
+	}
+	return &Split{Total: total, Participants: names, Count: count}, nil
+}
+
+// Shares splits the total into Count shares that sum exactly to it.
+// 按分计算，分不尽的零头从前往后每人多分一分；第 0 份属于记账人，之后依次是 Participants
+func (s *Split) Shares() []float64 {
+	cents := ToCents(s.Total)
+	base, remainder := cents/int64(s.Count), cents%int64(s.Count)
+	shares := make([]float64, s.Count)
+	for i := range shares {
+		share := base
+		if int64(i) < remainder {
+			share++
+		}
+		shares[i] = FromCents(share)
+	}
+	return shares
+}
+
+// PerHead is the even share per person before distributing the remainder cents
+func (s *Split) PerHead() float64 {
+	return FromCents(ToCents(s.Total) / int64(s.Count))
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrTemplateNotFound is returned when the user has no template with the requested name
+var ErrTemplateNotFound = errors.New("template not found")
+
+// BillTemplate is a transaction saved under a short name, e.g. "咖啡", so it can be recorded again
+// without restating the amount
+type BillTemplate struct {
+	Name        string    `json:"name"`
+	UserName    string    `json:"user_name"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	Type        BillType  `json:"type"`
+	Category    string    `json:"category"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UsedAt      time.Time `json:"used_at"` // 最近一次使用的时间，"老样子"使用最近用过的模板
+}
+
+// Input returns the bill input of the template; amount > 0 overrides the saved amount
+func (t *BillTemplate) Input(amount float64, originalMsg string) NewBillInput {
+	if amount <= 0 {
+		amount = t.Amount
+	}
+	return NewBillInput{
+		Description: t.Description,
+		Amount:      amount,
+		Type:        t.Type,
+		Category:    t.Category,
+		OriginalMsg: originalMsg,
+	}
+}
+
+// LastActive returns when the template was last used, or saved if it was never used
+func (t *BillTemplate) LastActive() time.Time {
+	if t.UsedAt.After(t.UpdatedAt) {
+		return t.UsedAt
+	}
+	return t.UpdatedAt
+}
+
+// NormalizeTemplateName trims spaces and quotes around a template name, e.g. "'咖啡'" -> "咖啡"
+func NormalizeTemplateName(name string) string {
+	return strings.Trim(strings.TrimSpace(name), " '\"‘’“”「」")
+}
+
+// TemplateRepository persists bill templates, unique by user and name
+type TemplateRepository interface {
+	SaveTemplate(tpl *BillTemplate) error
+	GetTemplate(userName, name string) (*BillTemplate, error)
+	ListTemplates(userName string) ([]*BillTemplate, error)
+	DeleteTemplate(userName, name string) error
+}
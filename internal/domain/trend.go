@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// Trend comparison windows: week-over-week for ranges shorter than TrendMonthlyMinDays, month-over-month otherwise
+const (
+	TrendWeekDays       = 7
+	TrendMonthDays      = 30
+	TrendMonthlyMinDays = 60
+)
+
+// SpendingTrend is the expense statistics of a time range, by calendar day in local time.
+// 借贷和收入不计入支出
+type SpendingTrend struct {
+	Start        time.Time // 第一天 0 点
+	End          time.Time // 最后一天 0 点
+	DailyTotals  []float64 // 每天的支出，从 Start 开始
+	Expense      float64
+	DailyAverage float64
+	MaxDay       time.Time // 支出最多的一天，没有支出时为零值
+	MaxDayAmount float64
+	NoSpendDays  int
+
+	// 比较最近 Window 天与之前 Window 天的支出；范围不足两个窗口时 Compared 为 false
+	Window   int
+	Compared bool
+	Current  float64
+	Previous float64
+}
+
+// Days returns the number of days in the range
+func (t *SpendingTrend) Days() int {
+	return len(t.DailyTotals)
+}
+
+// Change returns the percentage change from the previous window to the current one.
+// ok is false when there is no comparison or the previous window has no expense
+func (t *SpendingTrend) Change() (percent float64, ok bool) {
+	if !t.Compared || t.Previous == 0 {
+		return 0, false
+	}
+	return (t.Current - t.Previous) / t.Previous * 100, true
+}
+
+// NewSpendingTrend computes the spending trend of bills between start and end, both inclusive by day;
+// days are counted in the time zone of start
+func NewSpendingTrend(bills []*Bill, start, end time.Time) *SpendingTrend {
+	first := startOfDay(start, start.Location())
+	last := startOfDay(end, start.Location())
+	if last.Before(first) {
+		last = first
+	}
+	days := int(math.Round(last.Sub(first).Hours()/24)) + 1
+
+	t := &SpendingTrend{Start: first, End: last, DailyTotals: make([]float64, days)}
+	for _, bill := range bills {
+		if bill.Type == BillTypeIncome || bill.IsLoan() {
+			continue
+		}
+		i := int(math.Round(startOfDay(bill.Date, first.Location()).Sub(first).Hours() / 24))
+		if i < 0 || i >= days {
+			continue
+		}
+		t.DailyTotals[i] = AddAmount(t.DailyTotals[i], bill.Amount)
+		t.Expense = AddAmount(t.Expense, bill.Amount)
+	}
+
+	for i, amount := range t.DailyTotals {
+		if amount == 0 {
+			t.NoSpendDays++
+		} else if amount > t.MaxDayAmount {
+			t.MaxDayAmount = amount
+			t.MaxDay = first.AddDate(0, 0, i)
+		}
+	}
+	t.DailyAverage = t.Expense / float64(days)
+
+	t.Window = TrendWeekDays
+	if days >= TrendMonthlyMinDays {
+		t.Window = TrendMonthDays
+	}
+	if days >= 2*t.Window {
+		t.Compared = true
+		for _, amount := range t.DailyTotals[days-t.Window:] {
+			t.Current = AddAmount(t.Current, amount)
+		}
+		for _, amount := range t.DailyTotals[days-2*t.Window : days-t.Window] {
+			t.Previous = AddAmount(t.Previous, amount)
+		}
+	}
+	return t
+}
+
+// startOfDay 返回 t 在 loc 时区当天的 0 点
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
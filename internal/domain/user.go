@@ -1,25 +1,142 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // Platform constants for different IM platforms
 type Platform string
 
 const (
-	PlatformFeishu Platform = "feishu"
-	PlatformWechat Platform = "wechat"
-	PlatformQQ     Platform = "qq"
+	PlatformFeishu   Platform = "feishu"
+	PlatformWechat   Platform = "wechat"
+	PlatformQQ       Platform = "qq"
+	PlatformTelegram Platform = "telegram"
+	PlatformCLI      Platform = "cli"
 )
 
+// PlatformUserID builds the ID stored in user mappings for a platform user.
+// Feishu keeps the bare open ID; other platforms are prefixed as "platform:id".
+func PlatformUserID(platform Platform, userID string) string {
+	if platform == PlatformFeishu {
+		return userID
+	}
+	return string(platform) + ":" + userID
+}
+
+// PlatformOf returns the platform a user mapping ID belongs to
+func PlatformOf(platformID string) Platform {
+	if i := strings.Index(platformID, ":"); i > 0 {
+		return Platform(platformID[:i])
+	}
+	return PlatformFeishu
+}
+
+// SplitPlatformUserID splits an ID built by PlatformUserID back into its platform and platform user ID
+func SplitPlatformUserID(id string) (Platform, string) {
+	platform := PlatformOf(id)
+	if platform == PlatformFeishu {
+		return platform, id
+	}
+	return platform, strings.TrimPrefix(id, string(platform)+":")
+}
+
 // UserMapping represents a mapping between platform user ID and user name
 type UserMapping struct {
-	PlatformID string `json:"open_id"`  // Open ID from platform (e.g., Feishu)
-	UserName   string `json:"user_name"` // User's display name
+	PlatformID  string          `json:"open_id"`     // Open ID from platform (e.g., Feishu)
+	UserID      string          `json:"user_id"`     // 创建映射时生成的内部用户 ID，不随平台变化
+	UserName    string          `json:"user_name"`   // User's display name
+	Preferences UserPreferences `json:"preferences"` // 用户偏好，未设置的项使用全局配置
+}
+
+// UniqueUsers keeps the first mapping of each internal user ID, dropping the copies made by
+// UserMappingRepository.CopyMapping. Mappings without a user ID are all kept
+func UniqueUsers(mappings []*UserMapping) []*UserMapping {
+	seen := make(map[string]bool, len(mappings))
+	unique := make([]*UserMapping, 0, len(mappings))
+	for _, m := range mappings {
+		if m.UserID != "" {
+			if seen[m.UserID] {
+				continue
+			}
+			seen[m.UserID] = true
+		}
+		unique = append(unique, m)
+	}
+	return unique
+}
+
+// ErrUserNotFound is returned when no mapping exists for a platform user
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserNameTaken is matched by UserNameTakenError with errors.Is
+var ErrUserNameTaken = errors.New("user name taken")
+
+// UserNameTakenError is returned by SetUserName when another user already uses the name
+type UserNameTakenError struct {
+	Name       string
+	Suggestion string // 建议使用的未被占用的名字
+}
+
+func (e *UserNameTakenError) Error() string {
+	return fmt.Sprintf("user name %q is used by another user, try %q", e.Name, e.Suggestion)
+}
+
+// Is reports whether target is ErrUserNameTaken
+func (e *UserNameTakenError) Is(target error) bool {
+	return target == ErrUserNameTaken
+}
+
+// NumberedUserName returns name with the number n appended, e.g. "张三(2)", used to tell apart users sharing a name
+func NumberedUserName(name string, n int) string {
+	return fmt.Sprintf("%s(%d)", name, n)
+}
+
+// SameUser reports whether two mappings belong to the same user: the same platform ID, or copies
+// of one mapping sharing an internal user ID
+func SameUser(a, b *UserMapping) bool {
+	return a.PlatformID == b.PlatformID || (a.UserID != "" && a.UserID == b.UserID)
+}
+
+// ForgetUserResult describes what ForgetUser removed
+type ForgetUserResult struct {
+	PlatformID     string `json:"open_id"`
+	UserName       string `json:"user_name"`       // 删除前的用户名，未设置名字时为空
+	DeletedRecords int    `json:"deleted_records"` // 删除的账单数
 }
 
 // UserMappingRepository interface for user mapping access
 type UserMappingRepository interface {
-	// GetUserName gets user name by open ID
-	GetUserName(openID string) (string, error)
+	// GetUserName gets the user name of a platform user
+	GetUserName(platform Platform, platformID string) (string, error)
+
+	// SetUserName sets the user name of a platform user, creating the mapping when absent
+	SetUserName(platform Platform, platformID, userName string) error
+
+	// GetPreferences gets the preferences of a platform user; unknown users get empty preferences
+	GetPreferences(platform Platform, platformID string) (UserPreferences, error)
+
+	// SetPreferences replaces the preferences of a platform user, creating the mapping when absent
+	SetPreferences(platform Platform, platformID string, prefs UserPreferences) error
+
+	// GetMapping gets the whole mapping of a platform user, including users without a name;
+	// fails with ErrUserNotFound when absent
+	GetMapping(platform Platform, platformID string) (*UserMapping, error)
+
+	// CopyMapping copies the mapping of fromID, including its internal user ID, to toID and keeps
+	// the original, so the user is found under both IDs; an existing mapping of toID is left as is.
+	// Later SetUserName and SetPreferences calls through either ID update both copies.
+	// Fails with ErrUserNotFound when fromID has no mapping
+	CopyMapping(platform Platform, fromID, toID string) error
+
+	// DeleteMapping deletes the mapping and preferences of a platform user; fails with ErrUserNotFound when absent
+	DeleteMapping(platform Platform, platformID string) error
+
+	// ListMappings lists all known users
+	ListMappings() ([]*UserMapping, error)
 
-	// SetUserName sets user name for open ID
-	SetUserName(openID, userName string) error
+	// FindByUserName lists the mappings named name; a user migrated with CopyMapping appears once per ID
+	FindByUserName(name string) ([]*UserMapping, error)
 }
\ No newline at end of file
@@ -0,0 +1,28 @@
+package domain
+
+import "context"
+
+// UserDataDeletion describes what DeleteUserData removed
+type UserDataDeletion struct {
+	UserName       string // 删除前的用户名，未设置名字时为空
+	MappingDeleted bool   // 删除了名字和个人设置
+	Templates      int    // 删除的账单模板数
+	JournalCleared bool   // 清空了撤销记录
+}
+
+// DataDeletionStarter asks the current user to confirm deleting their data and returns the prompt to reply with
+type DataDeletionStarter func() string
+
+// dataDeletionKey is the context key of the DataDeletionStarter
+type dataDeletionKey struct{}
+
+// WithDataDeletion returns a context whose requests can start deleting the current user's data
+func WithDataDeletion(ctx context.Context, start DataDeletionStarter) context.Context {
+	return context.WithValue(ctx, dataDeletionKey{}, start)
+}
+
+// DataDeletionFromContext returns the DataDeletionStarter of ctx, or nil when data cannot be deleted from this request
+func DataDeletionFromContext(ctx context.Context) DataDeletionStarter {
+	start, _ := ctx.Value(dataDeletionKey{}).(DataDeletionStarter)
+	return start
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "testing"
+
+func TestPlatformUserID(t *testing.T) {
+	tests := []struct {
+		platform Platform
+		userID   string
+		want     string
+	}{
+		// 飞书用户沿用旧版不带前缀的 open ID，已有文件无需迁移
+		{PlatformFeishu, "ou_1", "ou_1"},
+		{PlatformTelegram, "42", "telegram:42"},
+		{PlatformCLI, "local", "cli:local"},
+		// 平台用户 ID 中的冒号保留
+		{PlatformWechat, "wx:abc", "wechat:wx:abc"},
+	}
+	for _, tt := range tests {
+		id := PlatformUserID(tt.platform, tt.userID)
+		if id != tt.want {
+			t.Errorf("PlatformUserID(%s, %q) = %q, want %q", tt.platform, tt.userID, id, tt.want)
+		}
+		if platform, userID := SplitPlatformUserID(id); platform != tt.platform || userID != tt.userID {
+			t.Errorf("SplitPlatformUserID(%q) = %s, %q, want %s, %q", id, platform, userID, tt.platform, tt.userID)
+		}
+	}
+}
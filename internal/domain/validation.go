@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// BillValidationReason identifies why a new bill failed validation
+type BillValidationReason string
+
+const (
+	// ValidationAmountInvalid 金额不是有限的正数
+	ValidationAmountInvalid BillValidationReason = "amount_invalid"
+	// ValidationAmountTooLarge 金额超过上限，用户确认后仍可记录
+	ValidationAmountTooLarge BillValidationReason = "amount_too_large"
+	// ValidationDescriptionEmpty 去掉空白后描述为空
+	ValidationDescriptionEmpty BillValidationReason = "description_empty"
+)
+
+// BillValidationError is returned when a new bill is rejected before it is written
+type BillValidationError struct {
+	Reason      BillValidationReason
+	Description string
+	Amount      float64
+	Limit       float64 // 金额上限，仅 ValidationAmountTooLarge 时有效
+}
+
+func (e *BillValidationError) Error() string {
+	switch e.Reason {
+	case ValidationAmountTooLarge:
+		return fmt.Sprintf("amount %.2f of %q exceeds %.2f and needs confirmation", e.Amount, e.Description, e.Limit)
+	case ValidationAmountInvalid:
+		return fmt.Sprintf("invalid amount %v of %q", e.Amount, e.Description)
+	default:
+		return fmt.Sprintf("invalid bill %q: %s", e.Description, e.Reason)
+	}
+}
+
+// NeedsConfirmation reports whether the bill can still be recorded once the user confirms it
+func (e *BillValidationError) NeedsConfirmation() bool {
+	return e.Reason == ValidationAmountTooLarge
+}
+
+// largeAmountConfirmedKey is the context key that skips the amount limit
+type largeAmountConfirmedKey struct{}
+
+// WithLargeAmountConfirmed returns a context under which bills above the amount limit are recorded,
+// used after the user explicitly confirms the amount
+func WithLargeAmountConfirmed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, largeAmountConfirmedKey{}, true)
+}
+
+// LargeAmountConfirmed reports whether the amount limit is skipped for ctx
+func LargeAmountConfirmed(ctx context.Context) bool {
+	confirmed, _ := ctx.Value(largeAmountConfirmedKey{}).(bool)
+	return confirmed
+}
+
+// NormalizeWidth converts full-width letters, digits, punctuation and spaces to their ASCII forms
+// 例如 “３５元” 转为 “35元”，中文字符保持不变
+func NormalizeWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xFEE0
+		}
+		return r
+	}, s)
+}
+
+// amountReplacer 去掉金额中的货币符号、货币单位和千分位逗号
+var amountReplacer = strings.NewReplacer(
+	"人民币", "", "块钱", "", "元", "", "块", "", "圆", "",
+	"¥", "", "￥", "", "$", "", "RMB", "", "rmb", "", "CNY", "", "cny", "",
+	",", "", "，", "", " ", "",
+)
+
+// ParseAmount parses an amount written with full-width digits, currency symbols or units and thousands separators,
+// e.g. "￥1,234.5"、"３５元"、"20块钱". The result keeps its sign; NaN and infinities are rejected
+func ParseAmount(s string) (float64, error) {
+	value := amountReplacer.Replace(NormalizeWidth(strings.TrimSpace(s)))
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	return amount, nil
+}
+
+// CleanDescription normalizes the width of a description, collapses whitespace and
+// truncates it to maxLen characters; maxLen <= 0 means no limit
+func CleanDescription(s string, maxLen int) string {
+	s = strings.Join(strings.FieldsFunc(NormalizeWidth(s), unicode.IsSpace), " ")
+	if runes := []rune(s); maxLen > 0 && len(runes) > maxLen {
+		s = strings.TrimSpace(string(runes[:maxLen]))
+	}
+	return s
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"35", 35},
+		{" 35.5 ", 35.5},
+		{"３５", 35},
+		{"３５．８元", 35.8},
+		{"￥1,234.5", 1234.5},
+		{"¥ 3,000,000", 3000000},
+		{"20块钱", 20},
+		{"15块", 15},
+		{"人民币100", 100},
+		{"RMB 88", 88},
+		{"12，000", 12000},
+		{"-20", -20},
+		{"$9.99", 9.99},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "元", "三千", "abc", "NaN", "Inf", "-Inf", "1e400", "12.3.4"} {
+		if got, err := ParseAmount(in); err == nil {
+			t.Errorf("ParseAmount(%q) = %v, want an error", in, got)
+		}
+	}
+}
+
+func TestNormalizeWidth(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"３５元", "35元"},
+		{"ＡＢＣ　ｘｙｚ", "ABC xyz"},
+		{"（午饭）！", "(午饭)!"},
+		// 中文字符和半角字符保持不变
+		{"午饭 35", "午饭 35"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeWidth(tt.in); got != tt.want {
+			t.Errorf("NormalizeWidth(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCleanDescription(t *testing.T) {
+	tests := []struct {
+		in     string
+		maxLen int
+		want   string
+	}{
+		{"  午饭  ", 0, "午饭"},
+		{"公司\t楼下\n 午饭", 0, "公司 楼下 午饭"},
+		{"　星巴克　拿铁　", 0, "星巴克 拿铁"},
+		{"ＫＦＣ午餐", 0, "KFC午餐"},
+		// 按字符而不是字节截断
+		{"公司楼下的牛肉面馆", 4, "公司楼下"},
+		// 截断后去掉末尾的空白
+		{"午饭 和 晚饭", 3, "午饭"},
+		{"午饭", 10, "午饭"},
+		{" \t ", 5, ""},
+	}
+	for _, tt := range tests {
+		if got := CleanDescription(tt.in, tt.maxLen); got != tt.want {
+			t.Errorf("CleanDescription(%q, %d) = %q, want %q", tt.in, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestBillValidationError(t *testing.T) {
+	tooLarge := &BillValidationError{Reason: ValidationAmountTooLarge, Description: "三千", Amount: 3000000, Limit: 100000}
+	if !tooLarge.NeedsConfirmation() || !strings.Contains(tooLarge.Error(), "exceeds 100000.00") {
+		t.Errorf("too large = %q, NeedsConfirmation %v", tooLarge.Error(), tooLarge.NeedsConfirmation())
+	}
+	for _, reason := range []BillValidationReason{ValidationAmountInvalid, ValidationDescriptionEmpty} {
+		err := &BillValidationError{Reason: reason, Amount: math.NaN()}
+		if err.NeedsConfirmation() {
+			t.Errorf("%s needs confirmation, want a plain rejection", reason)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// defaultMaxBatchDelete 未配置批量删除上限时使用的默认值
+const defaultMaxBatchDelete = 50
+
+// pendingBatchDelete 等待确认的批量删除：查询范围和列出的记录
+// 确认时在同一范围内重新查询，只删除列出过且仍然存在的记录，期间新记的账单不会被删除
+type pendingBatchDelete struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	RecordIDs []string  `json:"record_ids"`
+}
+
+// maxBatchDelete 按条件批量删除的上限
+func (s *OpenAIService) maxBatchDelete() int {
+	if s.config.MaxBatchDelete <= 0 {
+		return defaultMaxBatchDelete
+	}
+	return s.config.MaxBatchDelete
+}
+
+// handleDeleteByQuery 列出时间范围内符合分类、关键词的本人记录，暂存后等待用户回复“确认删除”
+func (s *OpenAIService) handleDeleteByQuery(args map[string]interface{}, svc *BillService, input, userName, conversationKey string) (string, error) {
+	startTime, endTime, reply, err := s.parseToolTimeRange("delete_by_query", args)
+	if err != nil {
+		return reply, err
+	}
+	if conversationKey == "" {
+		return s.msg(msgBatchDeleteUnavailable), nil
+	}
+
+	bills, _, _, err := svc.QueryTransactions(startTime, endTime, 0)
+	if err != nil {
+		s.log.Error("Failed to query transactions for batch delete: %v", err)
+		return s.msg(msgQueryFailed), err
+	}
+	bills = deleteQueryMatches(bills, svc.userName, getString(args, "category"), getString(args, "keyword"))
+
+	start, end := startTime.Format("2006-01-02"), endTime.Format("2006-01-02")
+	if len(bills) == 0 {
+		return s.msg(msgBatchDeleteNone, start, end), nil
+	}
+	if limit := s.maxBatchDelete(); len(bills) > limit {
+		s.log.Warn("Batch delete refused: user=%s, matched=%d, limit=%d", userName, len(bills), limit)
+		return s.msg(msgBatchDeleteTooMany, len(bills), limit), nil
+	}
+
+	pending := &pendingBatchDelete{Start: startTime, End: endTime}
+	for _, bill := range bills {
+		pending.RecordIDs = append(pending.RecordIDs, bill.RecordID)
+	}
+	if err := s.savePending(conversationKey, pendingConfirmation{Input: input, UserName: userName, BatchDelete: pending}); err != nil {
+		s.log.Error("Failed to store pending batch delete: key=%s, err=%v", conversationKey, err)
+		return s.msg(msgBatchDeleteFailed), err
+	}
+	s.log.Info("Batch delete requires confirmation: key=%s, count=%d", conversationKey, len(bills))
+
+	income, expense := sumBills(bills)
+	var b strings.Builder
+	b.WriteString(s.msg(msgBatchDeleteHeader, len(bills), start, end, s.formatAmount("", expense), s.formatAmount("", income)))
+	for _, bill := range bills {
+		b.WriteString(s.msg(msgBatchDeleteItem, bill.Date.Format("01-02 15:04"), bill.Description, s.formatAmount(signOf(bill.Type), bill.Amount), bill.RecordID))
+	}
+	b.WriteString(s.msg(msgBatchDeleteQuestion, int(s.confirmTTL().Minutes())))
+	return b.String(), nil
+}
+
+// executeBatchDelete 用户确认后删除列出过的记录，已不存在的记录跳过
+func (s *OpenAIService) executeBatchDelete(pending *pendingBatchDelete, svc *BillService) (string, error) {
+	bills, _, _, err := svc.QueryTransactions(pending.Start, pending.End, 0)
+	if err != nil {
+		s.log.Error("Failed to query transactions for batch delete: %v", err)
+		return s.msg(msgBatchDeleteFailed), err
+	}
+	listed := make(map[string]bool, len(pending.RecordIDs))
+	for _, recordID := range pending.RecordIDs {
+		listed[recordID] = true
+	}
+	var targets []*domain.Bill
+	for _, bill := range bills {
+		if listed[bill.RecordID] && bill.UserName == svc.userName {
+			targets = append(targets, bill)
+		}
+	}
+	if len(targets) == 0 {
+		return s.msg(msgBatchDeleteGone), nil
+	}
+
+	if err := svc.DeleteBills(targets); err != nil {
+		s.log.Error("Failed to batch delete bills: %v", err)
+		return s.msg(msgBatchDeleteFailed), err
+	}
+
+	income, expense := sumBills(targets)
+	text := s.msg(msgBatchDeleteSuccess, len(targets), s.formatAmount("", expense), s.formatAmount("", income))
+	if skipped := len(pending.RecordIDs) - len(targets); skipped > 0 {
+		text += s.msg(msgBatchDeleteSkipped, skipped)
+	}
+	return text, nil
+}
+
+// deleteQueryMatches 筛选用户本人、符合分类和描述关键词的记录；共享账本中也不会删除其他成员的记录
+func deleteQueryMatches(bills []*domain.Bill, userName, category, keyword string) []*domain.Bill {
+	category = strings.TrimSpace(category)
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	var matched []*domain.Bill
+	for _, bill := range bills {
+		if bill.UserName != userName || bill.RecordID == "" {
+			continue
+		}
+		if category != "" && bill.Category != category {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(bill.Description), keyword) {
+			continue
+		}
+		matched = append(matched, bill)
+	}
+	return matched
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ClassifyCategory 使用一次轻量的 AI 调用为描述选择最合适的分类，回复必须恰好是其中一个分类
+func (s *OpenAIService) ClassifyCategory(ctx context.Context, description string, categories []string) (string, error) {
+	if description == "" || len(categories) == 0 {
+		return "", fmt.Errorf("description and categories are required")
+	}
+
+	prompt := fmt.Sprintf("Classify the bookkeeping transaction into exactly one of these categories: %s."+
+		" Reply with the category name only, without any explanation.", strings.Join(categories, ", "))
+
+	req := openai.ChatCompletionRequest{
+		Model: s.config.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: prompt},
+			{Role: openai.ChatMessageRoleUser, Content: wrapUserContent(description)},
+		},
+		MaxTokens:   16,
+		Temperature: 0,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := s.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("classify category: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("classify category: empty choices")
+	}
+
+	// 只去掉首尾的空白、引号和句号；包含分类名的句子（如“不是餐饮”）不算
+	answer := strings.Trim(strings.TrimSpace(resp.Choices[0].Message.Content), "\"'“”。.")
+	for _, c := range categories {
+		if answer == c {
+			s.log.Debug("ClassifyCategory: description=%s -> category=%s", description, c)
+			return c, nil
+		}
+	}
+
+	return "", fmt.Errorf("classify category: unexpected answer %q", answer)
+}
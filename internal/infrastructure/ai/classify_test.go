@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyCategory(t *testing.T) {
+	categories := []string{"餐饮", "交通", "餐饮外卖"}
+	tests := []struct {
+		answer string
+		want   string // 空表示应返回错误
+	}{
+		{answer: "交通", want: "交通"},
+		{answer: " “餐饮外卖”。\n", want: "餐饮外卖"},
+		// 回复必须恰好是一个分类，较长的分类不会被误判为其中包含的短分类
+		{answer: "餐饮外卖", want: "餐饮外卖"},
+		{answer: "不是餐饮"},
+		{answer: "购物"},
+	}
+	for _, tt := range tests {
+		svc, model := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+			return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: tt.answer}
+		})
+		got, err := svc.ClassifyCategory(context.Background(), "打车回家", categories)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("answer %q classified as %q, want an error", tt.answer, got)
+		case tt.want != "" && (err != nil || got != tt.want):
+			t.Errorf("answer %q = %q, %v, want %q", tt.answer, got, err, tt.want)
+		}
+		if req := model.lastRequest(t); req.Temperature != 0 || len(req.Messages) != 2 {
+			t.Errorf("request = %+v, want one system and one user message", req)
+		}
+	}
+}
+
+// 调用方取消后不再等待模型回复
+func TestClassifyCategoryCanceled(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "交通"}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := svc.ClassifyCategory(ctx, "打车回家", []string{"交通"}); err == nil {
+		t.Error("ClassifyCategory with a canceled context succeeded")
+	}
+}
@@ -0,0 +1,18 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"go.uber.org/goleak"
+)
+
+// Close 停止待确认操作和翻页游标两个缓存的清理协程
+func TestOpenAIServiceClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	svc := NewOpenAIService(&config.AIConfig{APIKey: "test-key", Model: "test-model"}, testLocation, nil).(*OpenAIService)
+	if err := svc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,147 @@
+package ai
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// pendingConfirmation 等待用户确认的工具调用
+type pendingConfirmation struct {
+	ToolCalls []openai.ToolCall `json:"tool_calls"`
+	Input     string            `json:"input"`
+	UserName  string            `json:"user_name"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	// BatchDelete 按条件批量删除时待删除的记录，确认后直接删除，不再执行工具调用
+	BatchDelete *pendingBatchDelete `json:"batch_delete,omitempty"`
+}
+
+var (
+	confirmReplies = []string{"确认", "确定", "确认记录", "确认删除", "confirm", "yes"}
+	cancelReplies  = []string{"取消", "cancel", "no"}
+)
+
+// ParseConfirmationReply 判断消息是否为确认/取消回复
+// ok 为 false 表示不是确认类回复
+func ParseConfirmationReply(text string) (confirmed bool, ok bool) {
+	normalized := strings.ToLower(strings.Trim(strings.TrimSpace(text), "。.！!～~"))
+	for _, r := range confirmReplies {
+		if normalized == r {
+			return true, true
+		}
+	}
+	for _, r := range cancelReplies {
+		if normalized == r {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// confirmTTL 待确认操作的有效期
+func (s *OpenAIService) confirmTTL() time.Duration {
+	if s.config.ConfirmTTL <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(s.config.ConfirmTTL) * time.Second
+}
+
+// confirmationPrompt 检查工具调用是否需要确认，需要时返回确认提示，否则返回空字符串
+func (s *OpenAIService) confirmationPrompt(toolCalls []openai.ToolCall) string {
+	var lines []string
+	deleteCount := 0
+
+	for _, tc := range toolCalls {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+			continue
+		}
+
+		switch tc.Function.Name {
+		case "record_transaction":
+			amount := getAmount(args, "amount")
+			if s.config.ConfirmAmountThreshold > 0 && amount > s.config.ConfirmAmountThreshold {
+				typeLabel := s.msg(msgTypeExpense)
+				if getString(args, "type") == "income" {
+					typeLabel = s.msg(msgTypeIncome)
+				}
+				lines = append(lines, s.msg(msgConfirmRecord, s.formatAmount("", amount), typeLabel, getString(args, "description")))
+			}
+		case "update_transaction":
+			amount := getAmount(args, "amount")
+			if s.config.ConfirmAmountThreshold > 0 && amount > s.config.ConfirmAmountThreshold {
+				lines = append(lines, s.msg(msgConfirmUpdate, getString(args, "record_id"), s.formatAmount("", amount)))
+			}
+		case "delete_transaction":
+			deleteCount++
+		}
+	}
+
+	if s.config.ConfirmDeleteCount > 0 && deleteCount > s.config.ConfirmDeleteCount {
+		lines = append(lines, s.msg(msgConfirmDelete, deleteCount))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n" + s.msg(msgConfirmQuestion, int(s.confirmTTL().Minutes()))
+}
+
+// storePendingConfirmation 暂存待确认的工具调用
+// 缓存保留两倍有效期，以便超时后仍能提示用户操作已过期
+func (s *OpenAIService) storePendingConfirmation(conversationKey string, toolCalls []openai.ToolCall, input string, userName string) error {
+	return s.savePending(conversationKey, pendingConfirmation{
+		ToolCalls: toolCalls,
+		Input:     input,
+		UserName:  userName,
+	})
+}
+
+// savePending 设置有效期后保存待确认的操作
+func (s *OpenAIService) savePending(conversationKey string, pending pendingConfirmation) error {
+	ttl := s.confirmTTL()
+	pending.ExpiresAt = time.Now().Add(ttl)
+	return s.pending.Set(conversationKey, pending, 2*ttl)
+}
+
+// ResolveConfirmation 执行或放弃待确认的操作，无需再次调用 AI
+func (s *OpenAIService) ResolveConfirmation(conversationKey string, confirmed bool, billService domain.BillServiceInterface, renameService domain.RenameServiceInterface) (string, bool, error) {
+	s = s.forRequest(billService)
+
+	// 取出和删除是一次原子操作，重复的“确认”或多个副本同时收到时只执行一次
+	var pending pendingConfirmation
+	if err := s.pending.GetDel(conversationKey, &pending); err != nil {
+		return "", false, nil
+	}
+
+	if time.Now().After(pending.ExpiresAt) {
+		s.log.Info("Pending confirmation expired: key=%s, expired_at=%s", conversationKey, pending.ExpiresAt.Format(time.RFC3339))
+		return s.msg(msgConfirmExpired), true, nil
+	}
+
+	if !confirmed {
+		s.log.Info("Pending confirmation cancelled: key=%s", conversationKey)
+		return s.msg(msgConfirmCancelled), true, nil
+	}
+
+	s.log.Info("Pending confirmation accepted: key=%s, tool_calls=%d", conversationKey, len(pending.ToolCalls))
+
+	if pending.BatchDelete != nil {
+		reply, err := s.executeBatchDelete(pending.BatchDelete, billService.(*BillService))
+		return reply, true, err
+	}
+
+	// 使用触发确认的原始消息作为 original_message，而不是“确认”
+	// 用户已明确确认，疑似重复或金额超过上限的账单也照常记录
+	if svc, ok := billService.(*BillService); ok {
+		svc.originalMsg = pending.Input
+		svc.ctx = domain.WithLargeAmountConfirmed(domain.WithDuplicatesAllowed(svc.ctx))
+	}
+
+	reply, err := s.executeToolCalls(pending.ToolCalls, pending.Input, pending.UserName, conversationKey, billService, renameService)
+	return reply, true, err
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const testConfirmKey = "u1:thread1"
+
+// newConfirmTestService 创建删除超过 3 条需要确认的服务，模型总是要求删除 4 条记录
+func newConfirmTestService(t *testing.T) *OpenAIService {
+	t.Helper()
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return toolCalls(
+			"delete_transaction", `{"record_id":"rec1"}`,
+			"delete_transaction", `{"record_id":"rec2"}`,
+			"delete_transaction", `{"record_id":"rec3"}`,
+			"delete_transaction", `{"record_id":"rec4"}`,
+		)
+	})
+	svc.config.ConfirmDeleteCount = 3
+	return svc
+}
+
+// requestDeletes 发送需要确认的批量删除，确认前不删除任何记录
+func requestDeletes(t *testing.T, svc *OpenAIService, bills *fakeBillUseCase) {
+	t.Helper()
+	reply, err := svc.Execute(context.Background(), "删除 rec1 rec2 rec3 rec4", "张三", testConfirmKey, NewBillService(context.Background(), bills, "u1", "张三", ""), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reply, svc.msg(msgConfirmDelete, 4)) {
+		t.Fatalf("reply = %q, want the confirmation prompt", reply)
+	}
+	if deleted := bills.deletedIDs(); len(deleted) != 0 {
+		t.Fatalf("deleted %v before confirmation", deleted)
+	}
+}
+
+func TestResolveConfirmationConfirm(t *testing.T) {
+	svc := newConfirmTestService(t)
+	bills := &fakeBillUseCase{}
+	requestDeletes(t, svc, bills)
+
+	_, handled, err := svc.ResolveConfirmation(testConfirmKey, true, NewBillService(context.Background(), bills, "u1", "张三", "确认"), nil)
+	if err != nil || !handled {
+		t.Fatalf("ResolveConfirmation = %v, %v", handled, err)
+	}
+	if got, want := bills.deletedIDs(), []string{"rec1", "rec2", "rec3", "rec4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+
+	// 确认只能使用一次
+	if _, handled, _ := svc.ResolveConfirmation(testConfirmKey, true, NewBillService(context.Background(), bills, "u1", "张三", "确认"), nil); handled {
+		t.Error("second confirmation was handled")
+	}
+	if n := len(bills.deletedIDs()); n != 4 {
+		t.Errorf("deleted %d records after the second confirmation, want 4", n)
+	}
+}
+
+func TestResolveConfirmationCancel(t *testing.T) {
+	svc := newConfirmTestService(t)
+	bills := &fakeBillUseCase{}
+	requestDeletes(t, svc, bills)
+
+	reply, handled, err := svc.ResolveConfirmation(testConfirmKey, false, NewBillService(context.Background(), bills, "u1", "张三", "取消"), nil)
+	if err != nil || !handled || reply != svc.msg(msgConfirmCancelled) {
+		t.Fatalf("ResolveConfirmation = %q, %v, %v, want the cancelled reply", reply, handled, err)
+	}
+	if deleted := bills.deletedIDs(); len(deleted) != 0 {
+		t.Errorf("deleted %v after cancel", deleted)
+	}
+	if _, handled, _ := svc.ResolveConfirmation(testConfirmKey, true, NewBillService(context.Background(), bills, "u1", "张三", "确认"), nil); handled {
+		t.Error("confirmation after cancel was handled")
+	}
+}
+
+func TestResolveConfirmationExpired(t *testing.T) {
+	svc := newConfirmTestService(t)
+	bills := &fakeBillUseCase{}
+	requestDeletes(t, svc, bills)
+
+	// 有效期已过但缓存中仍保留，提示超时而不是当作普通消息
+	var pending pendingConfirmation
+	if err := svc.pending.Get(testConfirmKey, &pending); err != nil {
+		t.Fatal(err)
+	}
+	pending.ExpiresAt = time.Now().Add(-time.Second)
+	if err := svc.pending.Set(testConfirmKey, pending, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, handled, err := svc.ResolveConfirmation(testConfirmKey, true, NewBillService(context.Background(), bills, "u1", "张三", "确认"), nil)
+	if err != nil || !handled || reply != svc.msg(msgConfirmExpired) {
+		t.Fatalf("ResolveConfirmation = %q, %v, %v, want the expired reply", reply, handled, err)
+	}
+	if deleted := bills.deletedIDs(); len(deleted) != 0 {
+		t.Errorf("deleted %v after expiry", deleted)
+	}
+	if _, handled, _ := svc.ResolveConfirmation(testConfirmKey, true, NewBillService(context.Background(), bills, "u1", "张三", "确认"), nil); handled {
+		t.Error("expired confirmation was not removed")
+	}
+}
+
+// 同时收到多条“确认”时操作只执行一次
+func TestResolveConfirmationConcurrent(t *testing.T) {
+	svc := newConfirmTestService(t)
+	bills := &fakeBillUseCase{}
+	requestDeletes(t, svc, bills)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svc.ResolveConfirmation(testConfirmKey, true, NewBillService(context.Background(), bills, "u1", "张三", "确认"), nil)
+		}()
+	}
+	wg.Wait()
+	if n := len(bills.deletedIDs()); n != 4 {
+		t.Errorf("deleted %d records, want 4", n)
+	}
+}
+
+func TestParseConfirmationReply(t *testing.T) {
+	tests := []struct {
+		text          string
+		confirmed, ok bool
+	}{
+		{text: "确认", confirmed: true, ok: true},
+		{text: " 确定！", confirmed: true, ok: true},
+		{text: "YES", confirmed: true, ok: true},
+		{text: "取消。", confirmed: false, ok: true},
+		{text: "确认一下今天花了多少", confirmed: false, ok: false},
+	}
+	for _, tt := range tests {
+		confirmed, ok := ParseConfirmationReply(tt.text)
+		if confirmed != tt.confirmed || ok != tt.ok {
+			t.Errorf("ParseConfirmationReply(%q) = %v, %v, want %v, %v", tt.text, confirmed, ok, tt.confirmed, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package ai
+
+import (
+	"strings"
+)
+
+// forecastTopCategories 月末预估最多列出的分类数
+const forecastTopCategories = 5
+
+// handleForecastMonth 按目前的速度预估本月总支出，设置了预算时与预算对比
+func (s *OpenAIService) handleForecastMonth(svc *BillService) (string, error) {
+	forecast, err := svc.ForecastMonth()
+	if err != nil {
+		s.log.Error("Failed to forecast month: %v", err)
+		return s.msg(msgForecastFailed), err
+	}
+
+	var b strings.Builder
+	b.WriteString(s.msg(msgForecastHeader, int(forecast.Month.Month()), forecast.Today, forecast.DaysInMonth))
+	b.WriteString(s.msg(msgForecastSpent, s.formatAmount("", forecast.Spent), s.formatAmount("", forecast.DailyAverage)))
+	if !forecast.Estimated() {
+		b.WriteString(s.msg(msgForecastTooEarly))
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	}
+	b.WriteString(s.msg(msgForecastTotal, s.formatAmount("", forecast.Forecast)))
+
+	if budget := s.config.MonthlyBudget; budget > 0 {
+		if forecast.Forecast > budget {
+			b.WriteString(s.msg(msgForecastOverBudget, s.formatAmount("", budget), s.formatAmount("", forecast.Forecast-budget)))
+		} else {
+			b.WriteString(s.msg(msgForecastInBudget, s.formatAmount("", budget), s.formatAmount("", budget-forecast.Forecast)))
+		}
+	}
+
+	if len(forecast.Categories) > 0 {
+		b.WriteString(s.msg(msgForecastCategories))
+		for i, c := range forecast.Categories {
+			if i == forecastTopCategories {
+				break
+			}
+			b.WriteString(s.msg(msgForecastCategory, c.Category, s.formatAmount("", c.Spent), s.formatAmount("", c.Forecast)))
+		}
+	}
+	b.WriteString(s.msg(msgForecastNote))
+	return b.String(), nil
+}
@@ -0,0 +1,14 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ping 列出模型以检查 AI 服务地址和密钥是否可用
+func (s *OpenAIService) Ping(ctx context.Context) error {
+	if _, err := s.client.ListModels(ctx); err != nil {
+		return fmt.Errorf("list models: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// fakeModel 模拟 OpenAI 兼容的对话接口，记录收到的请求并返回预设的回复
+type fakeModel struct {
+	mu       sync.Mutex
+	requests []openai.ChatCompletionRequest
+	reply    func(req openai.ChatCompletionRequest) openai.ChatCompletionMessage
+}
+
+func (m *fakeModel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	m.mu.Unlock()
+
+	json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: m.reply(req)}},
+	})
+}
+
+// lastRequest 返回最近一次收到的请求
+func (m *fakeModel) lastRequest(t *testing.T) openai.ChatCompletionRequest {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.requests) == 0 {
+		t.Fatal("model was not called")
+	}
+	return m.requests[len(m.requests)-1]
+}
+
+// testLocation 测试服务配置的时区，与运行测试的机器时区无关
+var testLocation = time.FixedZone("CST", 8*3600)
+
+// newTestService 创建连接 fakeModel 的服务，model 回复 reply
+func newTestService(t *testing.T, reply func(req openai.ChatCompletionRequest) openai.ChatCompletionMessage) (*OpenAIService, *fakeModel) {
+	t.Helper()
+	model := &fakeModel{reply: reply}
+	srv := httptest.NewServer(model)
+	t.Cleanup(srv.Close)
+	svc := NewOpenAIService(&config.AIConfig{BaseURL: srv.URL, APIKey: "test-key", Model: "test-model", Language: "zh", CurrencySymbol: "¥"}, testLocation, nil)
+	return svc.(*OpenAIService), model
+}
+
+// toolCalls 构造模型返回的工具调用，参数为 name 和 JSON 参数交替排列
+func toolCalls(nameArgs ...string) openai.ChatCompletionMessage {
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	for i := 0; i+1 < len(nameArgs); i += 2 {
+		msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+			ID:       nameArgs[i] + "-" + nameArgs[i+1],
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: nameArgs[i], Arguments: nameArgs[i+1]},
+		})
+	}
+	return msg
+}
+
+// fakeBillUseCase 只实现测试用到的方法，其余方法调用时 panic
+type fakeBillUseCase struct {
+	domain.BillUseCase
+
+	mu      sync.Mutex
+	bills   []*domain.Bill
+	deleted []string
+	created []domain.NewBillInput
+	ranges  [][2]time.Time // 每次查询的时间范围
+}
+
+// CreateBill 记录收到的输入，返回带新记录 ID 的账单
+func (f *fakeBillUseCase) CreateBill(ctx context.Context, userName string, userID string, input domain.NewBillInput) (*domain.Bill, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, input)
+	bill := &domain.Bill{
+		RecordID:    fmt.Sprintf("rec_new%d", len(f.created)),
+		Description: input.Description,
+		Amount:      input.Amount,
+		Type:        input.Type,
+		Category:    input.Category,
+		UserName:    userName,
+		Date:        time.Now(),
+	}
+	if input.Date != nil {
+		bill.Date = *input.Date
+	}
+	return bill, nil
+}
+
+func (f *fakeBillUseCase) createdInputs() []domain.NewBillInput {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.NewBillInput(nil), f.created...)
+}
+
+// QueryTransactions 返回全部账单，与多维表格一样按金额降序，只记录不过滤时间范围
+func (f *fakeBillUseCase) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ranges = append(f.ranges, [2]time.Time{startTime, endTime})
+	bills := make([]*domain.Bill, len(f.bills))
+	copy(bills, f.bills)
+	sort.SliceStable(bills, func(i, j int) bool { return bills[i].Amount > bills[j].Amount })
+	income, expense := sumBills(bills)
+	return bills, income, expense, nil
+}
+
+// queriedRanges 返回各次查询的时间范围
+func (f *fakeBillUseCase) queriedRanges() [][2]time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][2]time.Time(nil), f.ranges...)
+}
+
+func (f *fakeBillUseCase) DeleteBill(ctx context.Context, recordID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, recordID)
+	return nil
+}
+
+func (f *fakeBillUseCase) deletedIDs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.deleted...)
+}
@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// 发送给模型的话题历史只保留最近的消息
+func TestExecuteTruncatesHistory(t *testing.T) {
+	svc, model := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "好的"}
+	})
+	svc.config.MaxHistoryMessages = 3
+	var history []domain.AIMessage
+	for i := 1; i <= 10; i++ {
+		history = append(history, domain.AIMessage{Role: "user", Content: fmt.Sprintf("消息%d", i)})
+	}
+
+	if _, err := svc.Execute(context.Background(), "消息10", "张三", "", NewBillService(context.Background(), &fakeBillUseCase{}, "u1", "张三", ""), nil, history); err != nil {
+		t.Fatal(err)
+	}
+	req := model.lastRequest(t)
+	if len(req.Messages) != 4 {
+		t.Fatalf("sent %d messages, want the system prompt and 3 history messages", len(req.Messages))
+	}
+	if want := wrapUserContent("消息8"); req.Messages[1].Content != want {
+		t.Errorf("oldest history message = %q, want %q", req.Messages[1].Content, want)
+	}
+}
+
+// 消息处理的 ctx 取消后不再调用模型
+func TestExecuteCancelledContext(t *testing.T) {
+	svc, model := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "好的"}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := svc.Execute(ctx, "午饭30", "张三", "", NewBillService(ctx, &fakeBillUseCase{}, "u1", "张三", ""), nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Execute = %v, want context.Canceled", err)
+	}
+	if _, err := svc.Transcribe(ctx, []byte("ogg"), "audio.ogg"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Transcribe = %v, want context.Canceled", err)
+	}
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	if n := len(model.requests); n != 0 {
+		t.Errorf("model received %d requests, want 0", n)
+	}
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// installmentCount 读取 record_transaction 的分期期数，不分期或只有 1 期时返回 0
+func installmentCount(args map[string]interface{}) int {
+	count := int(getFloat64(args, "installments"))
+	if count < 2 {
+		return 0
+	}
+	return count
+}
+
+// recordInstallments 记录首期并保存分期计划
+func (s *OpenAIService) recordInstallments(input domain.NewBillInput, count int, svc *BillService) (string, error) {
+	if count > domain.MaxInstallments {
+		return s.msg(msgInstallmentTooMany, domain.MaxInstallments), fmt.Errorf("too many installments: %d", count)
+	}
+	if input.Type == domain.BillTypeIncome {
+		return s.msg(msgInstallmentIncome), fmt.Errorf("installments only apply to expenses")
+	}
+
+	bill, plan, err := svc.CreateInstallmentBill(input, count)
+	if needsConfirmation(err) {
+		return "", err
+	}
+	var invalidErr *domain.BillValidationError
+	if errors.As(err, &invalidErr) {
+		return s.validationText(invalidErr), nil
+	}
+	if err != nil && bill == nil {
+		s.log.Error("Failed to create installment bill: %v", err)
+		return s.msg(msgRecordFailed), err
+	}
+	if err != nil {
+		// 首期已经记账，只是分期计划没能保存
+		s.log.Error("Failed to save installment plan: %v", err)
+		return s.recordSuccessText(bill) + s.msg(msgInstallmentPlanFailed), err
+	}
+
+	return s.recordSuccessText(bill) + s.msg(msgInstallmentCreated, plan.Count, s.formatAmount("", plan.Amount(1)), plan.ID), nil
+}
+
+// handleListInstallments 列出进行中的分期计划
+func (s *OpenAIService) handleListInstallments(svc *BillService) (string, error) {
+	plans, err := svc.ListInstallmentPlans()
+	if err != nil {
+		s.log.Error("Failed to list installment plans: %v", err)
+		return s.msg(msgInstallmentFailed), err
+	}
+	if len(plans) == 0 {
+		return s.msg(msgInstallmentNone), nil
+	}
+
+	text := s.msg(msgInstallmentListHeader)
+	for _, plan := range plans {
+		text += s.msg(msgInstallmentListItem, plan.Description, s.formatAmount("", plan.Total), plan.Recorded, plan.Count, s.formatAmount("", plan.Amount(1)), plan.ID)
+	}
+	return text, nil
+}
+
+// handleCancelInstallment 取消分期计划，可选删除未到期的分期账单
+func (s *OpenAIService) handleCancelInstallment(args map[string]interface{}, svc *BillService) (string, error) {
+	planID := getString(args, "plan_id")
+	if planID == "" {
+		return s.msg(msgInstallmentIDRequired), fmt.Errorf("plan_id is required")
+	}
+	deleteFuture, _ := getBool(args, "delete_future_records")
+
+	plan, deleted, err := svc.CancelInstallmentPlan(planID, deleteFuture)
+	if errors.Is(err, domain.ErrInstallmentPlanNotFound) {
+		return s.msg(msgInstallmentNotFound, planID), nil
+	}
+	if err != nil {
+		s.log.Error("Failed to cancel installment plan: %v", err)
+		return s.msg(msgInstallmentFailed), err
+	}
+
+	text := s.msg(msgInstallmentCancelled, plan.Description, plan.Recorded, plan.Count)
+	if deleted > 0 {
+		text += s.msg(msgInstallmentDeleted, deleted)
+	}
+	return text, nil
+}
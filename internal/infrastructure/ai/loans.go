@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// handleRecordLoan 记录借出或借入，并附上与对方的最新余额
+func (s *OpenAIService) handleRecordLoan(args map[string]interface{}, svc *BillService) (string, error) {
+	input := domain.LoanInput{
+		Direction:    domain.LoanDirection(getString(args, "direction")),
+		Counterparty: domain.NormalizeCounterparty(getString(args, "counterparty")),
+		Amount:       getAmount(args, "amount"),
+	}
+	if input.Counterparty == "" || input.Amount <= 0 ||
+		(input.Direction != domain.LoanLent && input.Direction != domain.LoanBorrowed) {
+		return s.msg(msgLoanInvalid), fmt.Errorf("invalid record_loan args: %v", args)
+	}
+
+	bill, err := svc.RecordLoan(input)
+	var dupErr *domain.DuplicateBillError
+	if errors.As(err, &dupErr) {
+		return "", err
+	}
+	if err != nil && bill == nil {
+		s.log.Error("Failed to record loan: %v", err)
+		return s.msg(msgLoanFailed), err
+	}
+	if err != nil {
+		// 账单已经记录，只是没能写入借贷索引
+		s.log.Error("Failed to index loan: %v", err)
+		return s.recordSuccessText(bill) + s.msg(msgLoanIndexFailed), err
+	}
+
+	text := s.recordSuccessText(bill)
+	if balances, err := svc.LoanBalances(); err == nil {
+		for _, balance := range balances {
+			if balance.Counterparty == input.Counterparty {
+				text += s.msg(msgLoanBalance, s.loanBalanceText(balance))
+			}
+		}
+	}
+	return text, nil
+}
+
+// handleLoanStatus 列出与各对象未结清的余额
+func (s *OpenAIService) handleLoanStatus(args map[string]interface{}, svc *BillService) (string, error) {
+	balances, err := svc.LoanBalances()
+	if err != nil {
+		s.log.Error("Failed to list loan balances: %v", err)
+		return s.msg(msgLoanFailed), err
+	}
+
+	counterparty := domain.NormalizeCounterparty(getString(args, "counterparty"))
+	text := ""
+	for _, balance := range balances {
+		if counterparty != "" && balance.Counterparty != counterparty {
+			continue
+		}
+		text += s.msg(msgLoanStatusItem, s.loanBalanceText(balance))
+	}
+	if text == "" {
+		if counterparty != "" {
+			return s.msg(msgLoanNoOutstanding, counterparty), nil
+		}
+		return s.msg(msgLoanNone), nil
+	}
+	return s.msg(msgLoanStatusHeader) + text, nil
+}
+
+// handleSettleLoan 记录还款，方向由当前余额决定
+func (s *OpenAIService) handleSettleLoan(args map[string]interface{}, svc *BillService) (string, error) {
+	counterparty := domain.NormalizeCounterparty(getString(args, "counterparty"))
+	if counterparty == "" {
+		return s.msg(msgLoanInvalid), fmt.Errorf("counterparty is required")
+	}
+
+	bill, remaining, err := svc.SettleLoan(counterparty, getAmount(args, "amount"))
+	var dupErr *domain.DuplicateBillError
+	switch {
+	case errors.As(err, &dupErr):
+		return "", err
+	case errors.Is(err, domain.ErrNoOutstandingLoan):
+		return s.msg(msgLoanNoOutstanding, counterparty), nil
+	case errors.Is(err, domain.ErrLoanOverpaid):
+		return s.msg(msgLoanOverpaid, s.formatAmount("", math.Abs(remaining.Amount))), nil
+	case err != nil && bill != nil:
+		s.log.Error("Failed to index loan repayment: %v", err)
+		return s.recordSuccessText(bill) + s.msg(msgLoanIndexFailed), err
+	case err != nil:
+		s.log.Error("Failed to settle loan: %v", err)
+		return s.msg(msgLoanFailed), err
+	}
+
+	text := s.recordSuccessText(bill)
+	if remaining.Amount == 0 {
+		return text + s.msg(msgLoanSettled, counterparty), nil
+	}
+	return text + s.msg(msgLoanBalance, s.loanBalanceText(remaining)), nil
+}
+
+// loanBalanceText 描述与对方的余额，如"小王 还欠你 ¥200.00"
+func (s *OpenAIService) loanBalanceText(balance *domain.LoanBalance) string {
+	if balance.Amount > 0 {
+		return s.msg(msgLoanOwesYou, balance.Counterparty, s.formatAmount("", balance.Amount))
+	}
+	return s.msg(msgLoanYouOwe, balance.Counterparty, s.formatAmount("", -balance.Amount))
+}
@@ -0,0 +1,824 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+const (
+	LanguageZH = "zh"
+	LanguageEN = "en"
+)
+
+// messageKey 回复模板的键
+type messageKey string
+
+const (
+	msgAIFailed            messageKey = "ai_failed"
+	msgAIEmpty             messageKey = "ai_empty"
+	msgArgsParseFailed     messageKey = "args_parse_failed"
+	msgAskName             messageKey = "ask_name"
+	msgDeleteRefused       messageKey = "delete_refused"
+	msgUnknownTool         messageKey = "unknown_tool"
+	msgToolFailed          messageKey = "tool_failed"
+	msgUnknownOperation    messageKey = "unknown_operation"
+	msgPartialDone         messageKey = "partial_done"
+	msgInvalidTransaction  messageKey = "invalid_transaction"
+	msgRecordFailed        messageKey = "record_failed"
+	msgRecordSuccess       messageKey = "record_success"
+	msgEmptyName           messageKey = "empty_name"
+	msgRenameFailed        messageKey = "rename_failed"
+	msgRenameSuccess       messageKey = "rename_success"
+	msgRenameTaken         messageKey = "rename_taken"
+	msgRecordIDRequired    messageKey = "record_id_required"
+	msgNoFieldsToUpdate    messageKey = "no_fields_to_update"
+	msgUpdateFailed        messageKey = "update_failed"
+	msgUpdateSuccess       messageKey = "update_success"
+	msgDeleteFailed        messageKey = "delete_failed"
+	msgDeleteSuccess       messageKey = "delete_success"
+	msgRecordIDLine        messageKey = "record_id_line"
+	msgTimeRangeRequired   messageKey = "time_range_required"
+	msgCustomRangeRequired messageKey = "custom_range_required"
+	msgTimeRangeFailed     messageKey = "time_range_failed"
+	msgQueryFailed         messageKey = "query_failed"
+	msgQueryHeader         messageKey = "query_header"
+	msgQueryIncome         messageKey = "query_income"
+	msgQueryExpense        messageKey = "query_expense"
+	msgQueryNet            messageKey = "query_net"
+	msgQueryTop            messageKey = "query_top"
+	msgQueryItem           messageKey = "query_item"
+	msgQueryItemRecordID   messageKey = "query_item_record_id"
+	msgQueryEmpty          messageKey = "query_empty"
+	msgTooManyToolCalls    messageKey = "too_many_tool_calls"
+	msgTypeExpense         messageKey = "type_expense"
+	msgTypeIncome          messageKey = "type_income"
+	msgConfirmRecord       messageKey = "confirm_record"
+	msgConfirmUpdate       messageKey = "confirm_update"
+	msgConfirmDelete       messageKey = "confirm_delete"
+	msgConfirmQuestion     messageKey = "confirm_question"
+	msgConfirmExpired      messageKey = "confirm_expired"
+	msgConfirmCancelled    messageKey = "confirm_cancelled"
+	msgCategoryFromHistory messageKey = "category_from_history"
+	msgAlreadyRecorded     messageKey = "already_recorded"
+	msgQueued              messageKey = "queued"
+	msgAttachmentAttached  messageKey = "attachment_attached"
+	msgQueryDayHeader      messageKey = "query_day_header"
+	msgQueryGroupItem      messageKey = "query_group_item"
+	msgQueryCategoryLine   messageKey = "query_category_line"
+	msgQueryTruncated      messageKey = "query_truncated"
+	msgQueryMoreHint       messageKey = "query_more_hint"
+	msgQueryCursorExpired  messageKey = "query_cursor_expired"
+	msgQueryNoMore         messageKey = "query_no_more"
+	msgQueryLastPage       messageKey = "query_last_page"
+	msgQueryPageHeader     messageKey = "query_page_header"
+	msgQueryPageItem       messageKey = "query_page_item"
+	msgQueryOrderAmount    messageKey = "query_order_amount"
+	msgQueryOrderDate      messageKey = "query_order_date"
+	msgQueryAccountFilter  messageKey = "query_account_filter"
+	msgQueryAccountLine    messageKey = "query_account_line"
+	msgQueryAccountNone    messageKey = "query_account_none"
+	msgRecordAccount       messageKey = "record_account"
+	msgQueryTagFilter      messageKey = "query_tag_filter"
+	msgRecordTags          messageKey = "record_tags"
+
+	msgRecordReimbursable        messageKey = "record_reimbursable"
+	msgQueryReimbursable         messageKey = "query_reimbursable"
+	msgQueryPendingReimbursement messageKey = "query_pending_reimbursement"
+
+	msgReceiptFailed             messageKey = "receipt_failed"
+	msgReceiptNoAmount           messageKey = "receipt_no_amount"
+	msgReceiptDefaultDescription messageKey = "receipt_default_description"
+	msgReceiptOriginalMsg        messageKey = "receipt_original_msg"
+	msgReceiptSummary            messageKey = "receipt_summary"
+	msgReceiptLowConfidence      messageKey = "receipt_low_confidence"
+	msgReceiptManual             messageKey = "receipt_manual"
+	msgBatchItemFailed           messageKey = "batch_item_failed"
+	msgDuplicateSkipped          messageKey = "duplicate_skipped"
+	msgDuplicateConfirmHint      messageKey = "duplicate_confirm_hint"
+	msgDuplicateFlagged          messageKey = "duplicate_flagged"
+	msgRestoreFailed             messageKey = "restore_failed"
+	msgRestoreSuccess            messageKey = "restore_success"
+	msgRestoreExpired            messageKey = "restore_expired"
+	msgRestoreDisabled           messageKey = "restore_disabled"
+	msgSettleSuccess             messageKey = "settle_success"
+	msgSettlePartial             messageKey = "settle_partial"
+	msgSettleFailed              messageKey = "settle_failed"
+	msgSettleNotFound            messageKey = "settle_not_found"
+	msgSettleNotReimbursable     messageKey = "settle_not_reimbursable"
+	msgSettleAlreadyReimbursed   messageKey = "settle_already_reimbursed"
+	msgExportSuccess             messageKey = "export_success"
+	msgExportEmpty               messageKey = "export_empty"
+	msgExportFailed              messageKey = "export_failed"
+	msgExportUnsupported         messageKey = "export_unsupported"
+
+	msgInstallmentCreated    messageKey = "installment_created"
+	msgInstallmentPlanFailed messageKey = "installment_plan_failed"
+	msgInstallmentTooMany    messageKey = "installment_too_many"
+	msgInstallmentIncome     messageKey = "installment_income"
+	msgInstallmentFailed     messageKey = "installment_failed"
+	msgInstallmentNone       messageKey = "installment_none"
+	msgInstallmentListHeader messageKey = "installment_list_header"
+	msgInstallmentListItem   messageKey = "installment_list_item"
+	msgInstallmentIDRequired messageKey = "installment_id_required"
+	msgInstallmentNotFound   messageKey = "installment_not_found"
+	msgInstallmentCancelled  messageKey = "installment_cancelled"
+	msgInstallmentDeleted    messageKey = "installment_deleted"
+
+	msgRecurringCreated    messageKey = "recurring_created"
+	msgRecurringInvalid    messageKey = "recurring_invalid"
+	msgRecurringFailed     messageKey = "recurring_failed"
+	msgRecurringNone       messageKey = "recurring_none"
+	msgRecurringListHeader messageKey = "recurring_list_header"
+	msgRecurringListItem   messageKey = "recurring_list_item"
+	msgRecurringIDRequired messageKey = "recurring_id_required"
+	msgRecurringNotFound   messageKey = "recurring_not_found"
+	msgRecurringDeleted    messageKey = "recurring_deleted"
+	msgRecurringMonthly    messageKey = "recurring_monthly"
+	msgRecurringWeekly     messageKey = "recurring_weekly"
+	msgRecurringWeekdays   messageKey = "recurring_weekdays"
+
+	msgLoanInvalid       messageKey = "loan_invalid"
+	msgLoanFailed        messageKey = "loan_failed"
+	msgLoanIndexFailed   messageKey = "loan_index_failed"
+	msgLoanBalance       messageKey = "loan_balance"
+	msgLoanOwesYou       messageKey = "loan_owes_you"
+	msgLoanYouOwe        messageKey = "loan_you_owe"
+	msgLoanSettled       messageKey = "loan_settled"
+	msgLoanNone          messageKey = "loan_none"
+	msgLoanStatusHeader  messageKey = "loan_status_header"
+	msgLoanStatusItem    messageKey = "loan_status_item"
+	msgLoanNoOutstanding messageKey = "loan_no_outstanding"
+	msgLoanOverpaid      messageKey = "loan_overpaid"
+
+	msgUndoNothing           messageKey = "undo_nothing"
+	msgUndoUnsupported       messageKey = "undo_unsupported"
+	msgUndoFailed            messageKey = "undo_failed"
+	msgUndoCreate            messageKey = "undo_create"
+	msgUndoDelete            messageKey = "undo_delete"
+	msgUndoRecreated         messageKey = "undo_recreated"
+	msgUndoUpdate            messageKey = "undo_update"
+	msgUndoChange            messageKey = "undo_change"
+	msgUndoBill              messageKey = "undo_bill"
+	msgUndoFieldDescription  messageKey = "undo_field_description"
+	msgUndoFieldAmount       messageKey = "undo_field_amount"
+	msgUndoFieldType         messageKey = "undo_field_type"
+	msgUndoFieldCategory     messageKey = "undo_field_category"
+	msgUndoFieldDate         messageKey = "undo_field_date"
+	msgUndoFieldAccount      messageKey = "undo_field_account"
+	msgUndoFieldTags         messageKey = "undo_field_tags"
+	msgUndoFieldReimbursable messageKey = "undo_field_reimbursable"
+	msgUndoYes               messageKey = "undo_yes"
+	msgUndoNo                messageKey = "undo_no"
+
+	msgTemplateInvalid    messageKey = "template_invalid"
+	msgTemplateFailed     messageKey = "template_failed"
+	msgTemplateSaved      messageKey = "template_saved"
+	msgTemplateReplaced   messageKey = "template_replaced"
+	msgTemplateNotFound   messageKey = "template_not_found"
+	msgTemplateNone       messageKey = "template_none"
+	msgTemplateUsed       messageKey = "template_used"
+	msgTemplateListHeader messageKey = "template_list_header"
+	msgTemplateListItem   messageKey = "template_list_item"
+	msgTemplateDeleted    messageKey = "template_deleted"
+
+	msgTrendFailed     messageKey = "trend_failed"
+	msgTrendEmpty      messageKey = "trend_empty"
+	msgTrendHeader     messageKey = "trend_header"
+	msgTrendAverage    messageKey = "trend_average"
+	msgTrendMaxDay     messageKey = "trend_max_day"
+	msgTrendNoSpend    messageKey = "trend_no_spend"
+	msgTrendCompare    messageKey = "trend_compare"
+	msgTrendUp         messageKey = "trend_up"
+	msgTrendDown       messageKey = "trend_down"
+	msgTrendFlat       messageKey = "trend_flat"
+	msgTrendNoPrevious messageKey = "trend_no_previous"
+	msgTrendTooShort   messageKey = "trend_too_short"
+	msgTrendSparkline  messageKey = "trend_sparkline"
+
+	msgForecastFailed     messageKey = "forecast_failed"
+	msgForecastHeader     messageKey = "forecast_header"
+	msgForecastSpent      messageKey = "forecast_spent"
+	msgForecastTotal      messageKey = "forecast_total"
+	msgForecastTooEarly   messageKey = "forecast_too_early"
+	msgForecastOverBudget messageKey = "forecast_over_budget"
+	msgForecastInBudget   messageKey = "forecast_in_budget"
+	msgForecastCategories messageKey = "forecast_categories"
+	msgForecastCategory   messageKey = "forecast_category"
+	msgForecastNote       messageKey = "forecast_note"
+
+	msgSharedNotEnabled  messageKey = "shared_not_enabled"
+	msgSharedFailed      messageKey = "shared_failed"
+	msgSharedEmpty       messageKey = "shared_empty"
+	msgMemberHeader      messageKey = "member_header"
+	msgMemberItem        messageKey = "member_item"
+	msgSettleUpHeader    messageKey = "settle_up_header"
+	msgSettleUpMember    messageKey = "settle_up_member"
+	msgSettleUpTransfer  messageKey = "settle_up_transfer"
+	msgSettleUpBalanced  messageKey = "settle_up_balanced"
+	msgSettleUpTransfers messageKey = "settle_up_transfers"
+
+	msgSplitInvalid      messageKey = "split_invalid"
+	msgSplitNote         messageKey = "split_note"
+	msgSplitNameSep      messageKey = "split_name_sep"
+	msgSplitUnnamed      messageKey = "split_unnamed"
+	msgSplitSummary      messageKey = "split_summary"
+	msgSplitOthers       messageKey = "split_others"
+	msgSplitOthersFailed messageKey = "split_others_failed"
+	msgSplitNotShared    messageKey = "split_not_shared"
+
+	msgBatchDeleteNone        messageKey = "batch_delete_none"
+	msgBatchDeleteTooMany     messageKey = "batch_delete_too_many"
+	msgBatchDeleteUnavailable messageKey = "batch_delete_unavailable"
+	msgBatchDeleteHeader      messageKey = "batch_delete_header"
+	msgBatchDeleteItem        messageKey = "batch_delete_item"
+	msgBatchDeleteQuestion    messageKey = "batch_delete_question"
+	msgBatchDeleteSuccess     messageKey = "batch_delete_success"
+	msgBatchDeleteSkipped     messageKey = "batch_delete_skipped"
+	msgBatchDeleteGone        messageKey = "batch_delete_gone"
+	msgBatchDeleteFailed      messageKey = "batch_delete_failed"
+)
+
+const (
+	msgAmountNeedsConfirm messageKey = "amount_needs_confirm"
+	msgAmountInvalid      messageKey = "amount_invalid"
+	msgDescriptionEmpty   messageKey = "description_empty"
+)
+
+const (
+	msgPreferenceSaved        messageKey = "preference_saved"
+	msgPreferenceReset        messageKey = "preference_reset"
+	msgPreferenceInvalid      messageKey = "preference_invalid"
+	msgPreferenceUnknown      messageKey = "preference_unknown"
+	msgPreferenceUnsupported  messageKey = "preference_unsupported"
+	msgPreferenceFailed       messageKey = "preference_failed"
+	msgPreferenceCurrency     messageKey = "preference_currency"
+	msgPreferenceTimezone     messageKey = "preference_timezone"
+	msgPreferenceLanguage     messageKey = "preference_language"
+	msgPreferenceTopN         messageKey = "preference_top_n"
+	msgPreferenceCurrencyHint messageKey = "preference_currency_hint"
+	msgPreferenceTimezoneHint messageKey = "preference_timezone_hint"
+	msgPreferenceLanguageHint messageKey = "preference_language_hint"
+	msgPreferenceTopNHint     messageKey = "preference_top_n_hint"
+)
+
+const (
+	msgDeleteMyDataUnsupported messageKey = "delete_my_data_unsupported"
+	msgExportMyDataSuccess     messageKey = "export_my_data_success"
+	msgExportMyDataNone        messageKey = "export_my_data_none"
+	msgExportMyDataUnsupported messageKey = "export_my_data_unsupported"
+)
+
+// msgRequestID 开启 AI_SHOW_REQUEST_ID 时附加在失败回复后的平台请求 ID
+const msgRequestID messageKey = "request_id"
+
+// languageNames 系统提示词中使用的语言名称
+var languageNames = map[string]string{
+	LanguageZH: "Chinese",
+	LanguageEN: "English",
+}
+
+// messageCatalog 按语言组织的回复模板
+var messageCatalog = map[string]map[messageKey]string{
+	LanguageZH: {
+		msgAIFailed:            "抱歉，无法理解您的请求",
+		msgAIEmpty:             "抱歉，没有获得有效的AI响应",
+		msgArgsParseFailed:     "❌ %s: 参数解析失败",
+		msgAskName:             "我还不知道您是谁？请告诉我您的称呼。\n您可以直接说：我是张三",
+		msgDeleteRefused:       "❌ 已拒绝删除操作：您的消息中没有明确要求删除记录",
+		msgUnknownTool:         "❌ 未知操作: %s",
+		msgToolFailed:          "❌ %s 执行失败: %v",
+		msgUnknownOperation:    "未知操作",
+		msgPartialDone:         "部分操作完成：\n",
+		msgInvalidTransaction:  "请提供有效的交易信息",
+		msgRecordFailed:        "记账失败",
+		msgRecordSuccess:       "✅ 记账成功！\n📋 %s\n💰 %s\n🏷️ %s",
+		msgEmptyName:           "名字不能为空",
+		msgRenameFailed:        "设置失败",
+		msgRenameSuccess:       "✅ 设置成功！从现在起，我将称呼您为：%s",
+		msgRenameTaken:         "「%s」已被其他用户使用，试试「%s」，或加上部门等区分，如「%s(市场部)」",
+		msgRecordIDRequired:    "请提供记录ID",
+		msgNoFieldsToUpdate:    "请提供至少一个要更新的字段",
+		msgUpdateFailed:        "更新失败",
+		msgUpdateSuccess:       "✅ 更新成功！\n📋 %s\n💰 %s\n🏷️ %s",
+		msgDeleteFailed:        "删除失败",
+		msgDeleteSuccess:       "✅ 删除成功！\n🆔 %s",
+		msgRecordIDLine:        "\n🆔 %s",
+		msgTimeRangeRequired:   "请提供时间范围类型",
+		msgCustomRangeRequired: "自定义时间范围至少需要提供开始时间或结束时间",
+		msgTimeRangeFailed:     "时间范围解析失败",
+		msgQueryFailed:         "查询失败",
+		msgQueryHeader:         "📊 查询结果（%s 至 %s）\n\n",
+		msgQueryIncome:         "💰 总收入: %s\n",
+		msgQueryExpense:        "💸 总支出: %s\n",
+		msgQueryNet:            "📈 净收支: %s\n\n",
+		msgQueryTop:            "🔝 Top %d 交易记录:\n",
+		msgQueryItem:           "%d. %s %s [%s]\n",
+		msgQueryItemRecordID:   "   🆔 %s\n",
+		msgQueryEmpty:          "📝 暂无交易记录\n",
+		msgTooManyToolCalls:    "⚠️ 这条消息包含 %d 个操作，超过了单条消息最多 %d 个的限制，本次未执行任何操作。请拆分成几条消息分别发送。",
+		msgTypeExpense:         "支出",
+		msgTypeIncome:          "收入",
+		msgConfirmRecord:       "⚠️ 即将记录 %s 的%s：%s",
+		msgConfirmUpdate:       "⚠️ 即将把 %s 的金额改为 %s",
+		msgConfirmDelete:       "⚠️ 即将删除 %d 条记录",
+		msgConfirmQuestion:     "确认吗？在本话题中回复'确认'执行，回复'取消'放弃（%d 分钟内有效）",
+		msgConfirmExpired:      "⌛ 待确认的操作已超时，未执行。如需继续请重新发送。",
+		msgConfirmCancelled:    "🚫 已取消，未执行任何操作。",
+		msgCategoryFromHistory: "\n💡 已根据历史记录归类为%s",
+		msgAlreadyRecorded:     "\n♻️ 该消息已记录过，未重复记账",
+		msgQueued:              "\n⏳ 表格暂时无法访问，已排队，稍后同步到表格",
+		msgAttachmentAttached:  "\n📎 已附上票据",
+		msgQueryDayHeader:      "📅 %s（收入 %s，支出 %s）\n",
+		msgQueryGroupItem:      "  • %s %s [%s]\n",
+		msgQueryCategoryLine:   "🏷️ %s：%s（%d 笔）\n",
+		msgQueryTruncated:      "…… 记录较多，部分明细已省略\n",
+		msgQueryMoreHint:       "\n👉 回复'更多'查看下一页\n",
+		msgQueryCursorExpired:  "⌛ 上次查询已过期，请重新查询。",
+		msgQueryNoMore:         "📝 没有更多记录了",
+		msgQueryLastPage:       "\n📝 已经是最后一页了\n",
+		msgQueryPageHeader:     "📄 第 %d 页明细（%s 至 %s，%s）\n\n",
+		msgQueryPageItem:       "%s %s %s [%s]\n",
+		msgQueryOrderAmount:    "按金额从高到低",
+		msgQueryOrderDate:      "按时间顺序",
+		msgQueryAccountFilter:  "💳 账户：%s\n",
+		msgQueryAccountLine:    "💳 %s 支出：%s（%d 笔）\n",
+		msgQueryAccountNone:    "未标记账户",
+		msgRecordAccount:       "\n💳 账户：%s",
+		msgQueryTagFilter:      "🏷️ 标签：%s\n",
+		msgRecordTags:          "\n🏷️ 标签：%s",
+
+		msgRecordReimbursable:        "\n🧾 可报销",
+		msgQueryReimbursable:         "   其中可报销: %s（待报销 %s）\n",
+		msgQueryPendingReimbursement: "🧾 仅显示待报销的支出\n",
+
+		msgReceiptFailed:             "抱歉，无法识别这张图片",
+		msgReceiptNoAmount:           "没有在图片中识别到金额，请直接发送文字，例如：午饭30元",
+		msgReceiptDefaultDescription: "收据",
+		msgReceiptOriginalMsg:        "[图片收据] 商户：%s，金额：%.2f，日期：%s",
+		msgReceiptSummary:            "🧾 识别结果：%s %s（日期：%s）\n",
+		msgReceiptLowConfidence:      "⚠️ 识别不太确定，请确认金额是否正确。\n",
+		msgReceiptManual:             "⚠️ 请确认金额后直接发送文字记账，例如：午饭30元",
+		msgBatchItemFailed:           "❌ 记账失败：%s %s\n原因：%v",
+		msgDuplicateSkipped:          "⚠️ 检测到疑似重复记录（🆔 %s），已跳过",
+		msgDuplicateConfirmHint:      "；如确需记录请回复'确认记录'",
+		msgDuplicateFlagged:          "\n⚠️ 与 🆔 %s 疑似重复，如为误记可回复删除",
+		msgRestoreFailed:             "恢复失败",
+		msgRestoreSuccess:            "♻️ 已恢复！\n🆔 %s",
+		msgRestoreExpired:            "❌ 没有找到可恢复的记录 %s，可能已超过保留期被彻底删除",
+		msgRestoreDisabled:           "❌ 未开启软删除，已删除的记录无法恢复",
+		msgSettleSuccess:             "✅ 已报销 %d 笔，记录报销收入 %s\n🆔 %s",
+		msgSettlePartial:             "⚠️ 已记录报销收入 %s（🆔 %s），但部分记录没能标记为已报销：%v",
+		msgSettleFailed:              "报销处理失败",
+		msgSettleNotFound:            "❌ 部分记录不存在，请检查 record_id",
+		msgSettleNotReimbursable:     "❌ 所选记录中有未标记为可报销的账单，可以先把它改为可报销",
+		msgSettleAlreadyReimbursed:   "❌ 所选记录中有已经报销过的账单",
+		msgExportSuccess:             "📎 已导出 %d 条记录（%s 至 %s），请下载上方的 CSV 文件，可直接用 Excel 打开",
+		msgExportEmpty:               "📝 该时间段内没有可导出的记录",
+		msgExportFailed:              "导出失败",
+		msgExportUnsupported:         "❌ 当前平台暂不支持发送文件，请通过 /api/v1/export 接口导出",
+
+		msgInstallmentCreated:    "\n📅 分期：共 %d 期，每期约 %s，剩余各期将在每月 1 日自动记账（计划 %s）",
+		msgInstallmentPlanFailed: "\n⚠️ 分期计划保存失败，后续各期不会自动记账",
+		msgInstallmentTooMany:    "❌ 最多支持分 %d 期",
+		msgInstallmentIncome:     "❌ 只有支出可以分期",
+		msgInstallmentFailed:     "分期操作失败",
+		msgInstallmentNone:       "📝 没有进行中的分期",
+		msgInstallmentListHeader: "📅 进行中的分期：\n",
+		msgInstallmentListItem:   "• %s %s，已记 %d/%d 期，每期约 %s（计划 %s）\n",
+		msgInstallmentIDRequired: "❌ 请提供分期计划 ID",
+		msgInstallmentNotFound:   "❌ 没有找到分期计划 %s",
+		msgInstallmentCancelled:  "🛑 已取消“%s”的分期，已记 %d/%d 期",
+		msgInstallmentDeleted:    "，并删除了 %d 笔未到期的分期记录",
+
+		msgRecurringCreated:    "🔁 已设置周期记账：%s %s（%s），%s，下次记账 %s（规则 %s）",
+		msgRecurringInvalid:    "❌ 周期记账需要正数金额，以及每月的日期（1-31）或每周的星期几",
+		msgRecurringFailed:     "周期记账操作失败",
+		msgRecurringNone:       "📝 还没有周期记账",
+		msgRecurringListHeader: "🔁 周期记账：\n",
+		msgRecurringListItem:   "• %s %s（%s），%s，下次 %s（规则 %s）\n",
+		msgRecurringIDRequired: "❌ 请提供周期记账规则 ID",
+		msgRecurringNotFound:   "❌ 没有找到周期记账规则 %s",
+		msgRecurringDeleted:    "🛑 已删除周期记账“%s”，已记录的账单不受影响",
+		msgRecurringMonthly:    "每月 %d 日",
+		msgRecurringWeekly:     "每周%s",
+		msgRecurringWeekdays:   "日,一,二,三,四,五,六",
+
+		msgLoanInvalid:       "❌ 请说明借给谁或向谁借、借出还是借入，以及金额",
+		msgLoanFailed:        "借贷操作失败",
+		msgLoanIndexFailed:   "\n⚠️ 借贷对象保存失败，这笔不会计入借贷余额",
+		msgLoanBalance:       "\n🤝 %s",
+		msgLoanOwesYou:       "%s 还欠你 %s",
+		msgLoanYouOwe:        "你还欠 %s %s",
+		msgLoanSettled:       "\n✅ 与 %s 的借贷已结清",
+		msgLoanNone:          "📝 没有未结清的借贷",
+		msgLoanStatusHeader:  "🤝 未结清的借贷：\n",
+		msgLoanStatusItem:    "• %s\n",
+		msgLoanNoOutstanding: "❌ 与 %s 没有未结清的借贷",
+		msgLoanOverpaid:      "❌ 还款金额超过未结清的 %s",
+
+		msgUndoNothing:           "📝 没有可以撤销的操作",
+		msgUndoUnsupported:       "❌ 上一步已经是撤销，不支持撤销“撤销”",
+		msgUndoFailed:            "撤销失败",
+		msgUndoCreate:            "↩️ 已撤销新建的记录：%s",
+		msgUndoDelete:            "♻️ 已恢复删除的记录：%s",
+		msgUndoRecreated:         "\n🆔 原记录无法恢复，已按原内容重新创建：%s",
+		msgUndoUpdate:            "↩️ 已撤销对「%s」的修改：",
+		msgUndoChange:            "\n• %s：%s → %s",
+		msgUndoBill:              "%s %s（%s，%s）",
+		msgUndoFieldDescription:  "描述",
+		msgUndoFieldAmount:       "金额",
+		msgUndoFieldType:         "类型",
+		msgUndoFieldCategory:     "分类",
+		msgUndoFieldDate:         "日期",
+		msgUndoFieldAccount:      "账户",
+		msgUndoFieldTags:         "标签",
+		msgUndoFieldReimbursable: "可报销",
+		msgUndoYes:               "是",
+		msgUndoNo:                "否",
+
+		msgTemplateInvalid:    "❌ 请说明模板名称，以及这笔账的描述和金额",
+		msgTemplateFailed:     "模板操作失败",
+		msgTemplateSaved:      "⭐ 已保存模板「%s」：%s %s（%s）\n下次说“记一笔%s”或“老样子”即可记账",
+		msgTemplateReplaced:   "\n⚠️ 已覆盖同名模板，原内容为：%s %s（%s）",
+		msgTemplateNotFound:   "❌ 没有名为「%s」的模板",
+		msgTemplateNone:       "📝 还没有保存模板，可以说“把这笔存为咖啡模板”",
+		msgTemplateUsed:       "\n⭐ 使用模板「%s」",
+		msgTemplateListHeader: "⭐ 记账模板：\n",
+		msgTemplateListItem:   "• %s：%s %s（%s）\n",
+		msgTemplateDeleted:    "🗑️ 已删除模板「%s」",
+
+		msgTrendFailed:     "统计失败",
+		msgTrendEmpty:      "📝 %s ~ %s 没有支出记录",
+		msgTrendHeader:     "📈 消费统计（%s ~ %s，共 %d 天）\n",
+		msgTrendAverage:    "💸 总支出 %s，日均 %s\n",
+		msgTrendMaxDay:     "🔝 花得最多的一天：%s，%s\n",
+		msgTrendNoSpend:    "🌱 无消费天数：%d 天\n",
+		msgTrendCompare:    "📊 最近 %d 天支出 %s，之前 %d 天 %s，%s\n",
+		msgTrendUp:         "增加了 %.1f%%",
+		msgTrendDown:       "减少了 %.1f%%",
+		msgTrendFlat:       "基本持平",
+		msgTrendNoPrevious: "之前没有支出，无法计算变化",
+		msgTrendTooShort:   "📊 时间范围不足 %d 天，无法比较趋势\n",
+		msgTrendSparkline:  "📉 每日支出：%s\n",
+
+		msgForecastFailed:     "预测失败",
+		msgForecastHeader:     "🔮 本月支出预估（%d 月，今天是第 %d 天，共 %d 天）\n",
+		msgForecastSpent:      "💸 目前已支出 %s，今天之前日均 %s\n",
+		msgForecastTotal:      "📈 按这个速度，预计本月共支出约 %s\n",
+		msgForecastTooEarly:   "📈 今天是本月第一天，还没有完整的天数，暂时无法预估\n",
+		msgForecastOverBudget: "⚠️ 预计超出预算 %s 约 %s\n",
+		msgForecastInBudget:   "✅ 预计在预算 %s 之内，约剩余 %s\n",
+		msgForecastCategories: "各分类预估：\n",
+		msgForecastCategory:   "• %s：已花 %s，预计约 %s\n",
+		msgForecastNote:       "ℹ️ 以上为估计值：按今天之前的日均支出线性推算（今天还没过完，不计入日均），仅供参考",
+
+		msgSharedNotEnabled:  "❌ 当前会话没有开启共享账本，无法按成员统计或 AA 结算",
+		msgSharedFailed:      "共享账本统计失败",
+		msgSharedEmpty:       "📝 %s ~ %s 没有记录",
+		msgMemberHeader:      "👪 成员收支（%s ~ %s）：\n",
+		msgMemberItem:        "• %s：支出 %s，收入 %s，共 %d 笔\n",
+		msgSettleUpHeader:    "🧮 AA 结算（%s ~ %s），共同支出 %s：\n",
+		msgSettleUpMember:    "• %s：已付 %s，应摊 %s\n",
+		msgSettleUpTransfers: "💸 结算方式：\n",
+		msgSettleUpTransfer:  "• %s应付%s %s\n",
+		msgSettleUpBalanced:  "✅ 各自支付的金额正好等于应摊金额，无需转账",
+
+		msgSplitInvalid:      "❌ 无法分摊：需要 2 ~ %d 人，且每人至少分到 0.01",
+		msgSplitNote:         "【AA】总额 %s，%d 人（%s），每人 %s",
+		msgSplitNameSep:      "、",
+		msgSplitUnnamed:      "等",
+		msgSplitSummary:      "\n👥 AA：总额 %s，%d 人，每人 %s",
+		msgSplitOthers:       "\n👪 已为 %s 各记一笔自己的份额",
+		msgSplitOthersFailed: "\n⚠️ 没能为部分成员记录份额，可以让他们自己记一笔",
+		msgSplitNotShared:    "\nℹ️ 当前会话不是共享账本，只记录了你的份额",
+
+		msgBatchDeleteNone:        "📝 %s ~ %s 没有符合条件的记录，未删除任何记录",
+		msgBatchDeleteTooMany:     "❌ 符合条件的记录有 %d 条，超过单次批量删除的上限 %d 条，未删除任何记录。请缩小时间范围或增加条件",
+		msgBatchDeleteUnavailable: "❌ 当前会话无法确认批量删除，请在与机器人的会话中重试",
+		msgBatchDeleteHeader:      "🗑️ 即将删除 %d 条记录（%s ~ %s，支出 %s，收入 %s）：\n",
+		msgBatchDeleteItem:        "• %s %s %s 🆔 %s\n",
+		msgBatchDeleteQuestion:    "回复'确认删除'执行删除，回复'取消'放弃（%d 分钟内有效）",
+		msgBatchDeleteSuccess:     "✅ 已删除 %d 条记录（支出 %s，收入 %s）",
+		msgBatchDeleteSkipped:     "\nℹ️ 另有 %d 条记录已不存在或已修改，已跳过",
+		msgBatchDeleteGone:        "ℹ️ 列出的记录都已不存在或已修改，未删除任何记录",
+		msgBatchDeleteFailed:      "❌ 批量删除失败，部分记录可能已经删除，请查询后确认",
+
+		msgAmountNeedsConfirm: "⚠️ 金额 %s 看起来不对，请确认（%s）",
+		msgAmountInvalid:      "⚠️ “%s”的金额无效，请重新说明金额（需大于 0）",
+		msgDescriptionEmpty:   "⚠️ 缺少账单描述，请说明这笔 %s 花在了什么地方",
+
+		msgPreferenceSaved:        "⚙️ 已将%s设置为 %s",
+		msgPreferenceReset:        "⚙️ %s已恢复默认设置",
+		msgPreferenceInvalid:      "⚠️ 无法将%s设置为“%s”：%s",
+		msgPreferenceUnknown:      "⚠️ 不支持的设置项“%s”，可以设置货币、时区、语言和明细条数",
+		msgPreferenceUnsupported:  "⚠️ 当前无法修改个人设置",
+		msgPreferenceFailed:       "❌ 保存设置失败，请稍后重试",
+		msgPreferenceCurrency:     "货币符号",
+		msgPreferenceTimezone:     "时区",
+		msgPreferenceLanguage:     "回复语言",
+		msgPreferenceTopN:         "查询明细条数",
+		msgPreferenceCurrencyHint: "货币符号最多 8 个字符",
+		msgPreferenceTimezoneHint: "请使用 IANA 时区名，如 Asia/Shanghai、America/New_York",
+		msgPreferenceLanguageHint: "支持 zh（中文）和 en（英文）",
+		msgPreferenceTopNHint:     "条数需要在 1 到 50 之间",
+
+		msgDeleteMyDataUnsupported: "⚠️ 当前无法删除个人数据，请在与机器人的对话中发送 /删除我的数据",
+		msgExportMyDataSuccess:     "📎 已导出你的个人数据，请下载上方的压缩包：profile.json 为名字和个人设置，bills.csv 为你记录的 %d 条账单",
+		msgExportMyDataNone:        "📝 我还没有保存你的任何数据",
+		msgExportMyDataUnsupported: "❌ 当前平台暂不支持发送文件，请联系管理员通过 /api/v1/users/{openID}/export 接口导出",
+		msgRequestID:               "（请求 ID：%s）",
+	},
+	LanguageEN: {
+		msgAIFailed:            "Sorry, I couldn't understand your request",
+		msgAIEmpty:             "Sorry, no valid AI response was received",
+		msgArgsParseFailed:     "❌ %s: failed to parse arguments",
+		msgAskName:             "I don't know who you are yet. How should I address you?\nYou can simply say: I'm Alice",
+		msgDeleteRefused:       "❌ Deletion refused: your message did not explicitly ask to delete records",
+		msgUnknownTool:         "❌ Unknown operation: %s",
+		msgToolFailed:          "❌ %s failed: %v",
+		msgUnknownOperation:    "Unknown operation",
+		msgPartialDone:         "Some operations completed:\n",
+		msgInvalidTransaction:  "Please provide valid transaction details",
+		msgRecordFailed:        "Failed to record transaction",
+		msgRecordSuccess:       "✅ Recorded!\n📋 %s\n💰 %s\n🏷️ %s",
+		msgEmptyName:           "Name cannot be empty",
+		msgRenameFailed:        "Failed to set name",
+		msgRenameSuccess:       "✅ Done! From now on I'll call you: %s",
+		msgRenameTaken:         "\"%s\" is used by another user, try \"%s\" or add something to tell you apart, e.g. \"%s (Marketing)\"",
+		msgRecordIDRequired:    "Please provide a record ID",
+		msgNoFieldsToUpdate:    "Please provide at least one field to update",
+		msgUpdateFailed:        "Update failed",
+		msgUpdateSuccess:       "✅ Updated!\n📋 %s\n💰 %s\n🏷️ %s",
+		msgDeleteFailed:        "Delete failed",
+		msgDeleteSuccess:       "✅ Deleted!\n🆔 %s",
+		msgRecordIDLine:        "\n🆔 %s",
+		msgTimeRangeRequired:   "Please provide a time range type",
+		msgCustomRangeRequired: "A custom time range needs a start time or an end time",
+		msgTimeRangeFailed:     "Failed to parse time range",
+		msgQueryFailed:         "Query failed",
+		msgQueryHeader:         "📊 Results (%s to %s)\n\n",
+		msgQueryIncome:         "💰 Total income: %s\n",
+		msgQueryExpense:        "💸 Total expense: %s\n",
+		msgQueryNet:            "📈 Net: %s\n\n",
+		msgQueryTop:            "🔝 Top %d transactions:\n",
+		msgQueryItem:           "%d. %s %s [%s]\n",
+		msgQueryItemRecordID:   "   🆔 %s\n",
+		msgQueryEmpty:          "📝 No transactions found\n",
+		msgTooManyToolCalls:    "⚠️ This message contains %d operations, more than the limit of %d per message, so nothing was executed. Please split it into several messages.",
+		msgTypeExpense:         "expense",
+		msgTypeIncome:          "income",
+		msgConfirmRecord:       "⚠️ About to record %s %s: %s",
+		msgConfirmUpdate:       "⚠️ About to change the amount of %s to %s",
+		msgConfirmDelete:       "⚠️ About to delete %d records",
+		msgConfirmQuestion:     "Continue? Reply 'confirm' in this thread to proceed or 'cancel' to discard (valid for %d minutes)",
+		msgConfirmExpired:      "⌛ The pending operation timed out and was not executed. Please send it again if needed.",
+		msgConfirmCancelled:    "🚫 Cancelled, nothing was executed.",
+		msgCategoryFromHistory: "\n💡 Categorized as %s based on your history",
+		msgAlreadyRecorded:     "\n♻️ This message was already recorded, no duplicate was created",
+		msgQueued:              "\n⏳ The table is temporarily unavailable, queued and will sync to the table later",
+		msgAttachmentAttached:  "\n📎 Receipt attached",
+		msgQueryDayHeader:      "📅 %s (income %s, expense %s)\n",
+		msgQueryGroupItem:      "  • %s %s [%s]\n",
+		msgQueryCategoryLine:   "🏷️ %s: %s (%d transactions)\n",
+		msgQueryTruncated:      "… Too many records, some details were omitted\n",
+		msgQueryMoreHint:       "\n👉 Reply 'more' to see the next page\n",
+		msgQueryCursorExpired:  "⌛ The previous query has expired, please query again.",
+		msgQueryNoMore:         "📝 No more records",
+		msgQueryLastPage:       "\n📝 This is the last page\n",
+		msgQueryPageHeader:     "📄 Page %d (%s to %s, %s)\n\n",
+		msgQueryPageItem:       "%s %s %s [%s]\n",
+		msgQueryOrderAmount:    "largest first",
+		msgQueryOrderDate:      "oldest first",
+		msgQueryAccountFilter:  "💳 Account: %s\n",
+		msgQueryAccountLine:    "💳 %s expense: %s (%d transactions)\n",
+		msgQueryAccountNone:    "No account",
+		msgRecordAccount:       "\n💳 Account: %s",
+		msgQueryTagFilter:      "🏷️ Tag: %s\n",
+		msgRecordTags:          "\n🏷️ Tags: %s",
+
+		msgRecordReimbursable:        "\n🧾 Reimbursable",
+		msgQueryReimbursable:         "   incl. reimbursable: %s (pending: %s)\n",
+		msgQueryPendingReimbursement: "🧾 Pending reimbursement only\n",
+
+		msgReceiptFailed:             "Sorry, I couldn't recognize this image",
+		msgReceiptNoAmount:           "No amount found in the image, please send it as text, e.g. lunch 30",
+		msgReceiptDefaultDescription: "Receipt",
+		msgReceiptOriginalMsg:        "[Receipt image] merchant: %s, amount: %.2f, date: %s",
+		msgReceiptSummary:            "🧾 Recognized: %s %s (date: %s)\n",
+		msgReceiptLowConfidence:      "⚠️ I'm not sure about this, please check the amount.\n",
+		msgReceiptManual:             "⚠️ Please check the amount and send it as text, e.g. lunch 30",
+		msgBatchItemFailed:           "❌ Failed to record %s %s\nReason: %v",
+		msgDuplicateSkipped:          "⚠️ Looks like a duplicate of 🆔 %s, skipped",
+		msgDuplicateConfirmHint:      "; reply 'confirm' if you really want to record it",
+		msgDuplicateFlagged:          "\n⚠️ Looks like a duplicate of 🆔 %s, ask me to delete it if it was a mistake",
+		msgRestoreFailed:             "Restore failed",
+		msgRestoreSuccess:            "♻️ Restored!\n🆔 %s",
+		msgRestoreExpired:            "❌ No restorable record %s was found; it may have been permanently deleted after the retention period",
+		msgRestoreDisabled:           "❌ Soft delete is disabled, deleted records cannot be restored",
+		msgSettleSuccess:             "✅ Reimbursement settled for %d transactions, income %s recorded\n🆔 %s",
+		msgSettlePartial:             "⚠️ Income %s recorded (🆔 %s), but some transactions could not be marked as reimbursed: %v",
+		msgSettleFailed:              "Failed to settle the reimbursement",
+		msgSettleNotFound:            "❌ Some of these records were not found, please check the record IDs",
+		msgSettleNotReimbursable:     "❌ Some of these records are not marked as reimbursable, mark them first",
+		msgSettleAlreadyReimbursed:   "❌ Some of these records have already been reimbursed",
+		msgExportSuccess:             "📎 Exported %d records (%s to %s), download the CSV file above; it opens directly in Excel",
+		msgExportEmpty:               "📝 No records to export in this time range",
+		msgExportFailed:              "Export failed",
+		msgExportUnsupported:         "❌ Sending files is not supported on this platform yet, please use the /api/v1/export endpoint",
+
+		msgInstallmentCreated:    "\n📅 Installments: %d payments of about %s, the rest are recorded on the 1st of each month (plan %s)",
+		msgInstallmentPlanFailed: "\n⚠️ Failed to save the installment plan, later installments will not be recorded",
+		msgInstallmentTooMany:    "❌ At most %d installments are supported",
+		msgInstallmentIncome:     "❌ Only expenses can be paid in installments",
+		msgInstallmentFailed:     "Installment operation failed",
+		msgInstallmentNone:       "📝 No ongoing installments",
+		msgInstallmentListHeader: "📅 Ongoing installments:\n",
+		msgInstallmentListItem:   "• %s %s, %d/%d recorded, about %s each (plan %s)\n",
+		msgInstallmentIDRequired: "❌ Please provide the installment plan ID",
+		msgInstallmentNotFound:   "❌ Installment plan %s not found",
+		msgInstallmentCancelled:  "🛑 Installments of \"%s\" cancelled, %d/%d recorded",
+		msgInstallmentDeleted:    ", %d future installment records deleted",
+
+		msgRecurringCreated:    "🔁 Recurring bill set: %s %s (%s), %s, next on %s (rule %s)",
+		msgRecurringInvalid:    "❌ A recurring bill needs a positive amount and a day of month (1-31) or a day of week",
+		msgRecurringFailed:     "Recurring bill operation failed",
+		msgRecurringNone:       "📝 No recurring bills yet",
+		msgRecurringListHeader: "🔁 Recurring bills:\n",
+		msgRecurringListItem:   "• %s %s (%s), %s, next on %s (rule %s)\n",
+		msgRecurringIDRequired: "❌ Please provide the recurring rule ID",
+		msgRecurringNotFound:   "❌ Recurring rule %s not found",
+		msgRecurringDeleted:    "🛑 Recurring bill \"%s\" deleted, recorded bills are kept",
+		msgRecurringMonthly:    "monthly on day %d",
+		msgRecurringWeekly:     "every %s",
+		msgRecurringWeekdays:   "Sunday,Monday,Tuesday,Wednesday,Thursday,Friday,Saturday",
+
+		msgLoanInvalid:       "❌ Please give the other person, whether you lent or borrowed, and the amount",
+		msgLoanFailed:        "Loan operation failed",
+		msgLoanIndexFailed:   "\n⚠️ Failed to save the counterparty, this record is not counted in loan balances",
+		msgLoanBalance:       "\n🤝 %s",
+		msgLoanOwesYou:       "%s owes you %s",
+		msgLoanYouOwe:        "You owe %s %s",
+		msgLoanSettled:       "\n✅ All settled with %s",
+		msgLoanNone:          "📝 No outstanding loans",
+		msgLoanStatusHeader:  "🤝 Outstanding loans:\n",
+		msgLoanStatusItem:    "• %s\n",
+		msgLoanNoOutstanding: "❌ No outstanding loan with %s",
+		msgLoanOverpaid:      "❌ The repayment exceeds the outstanding %s",
+
+		msgUndoNothing:           "📝 Nothing to undo",
+		msgUndoUnsupported:       "❌ The last operation was an undo, undoing an undo is not supported",
+		msgUndoFailed:            "Undo failed",
+		msgUndoCreate:            "↩️ Undid the new record: %s",
+		msgUndoDelete:            "♻️ Restored the deleted record: %s",
+		msgUndoRecreated:         "\n🆔 The original record could not be restored, re-created it as %s",
+		msgUndoUpdate:            "↩️ Reverted the changes to \"%s\":",
+		msgUndoChange:            "\n• %s: %s → %s",
+		msgUndoBill:              "%s %s (%s, %s)",
+		msgUndoFieldDescription:  "Description",
+		msgUndoFieldAmount:       "Amount",
+		msgUndoFieldType:         "Type",
+		msgUndoFieldCategory:     "Category",
+		msgUndoFieldDate:         "Date",
+		msgUndoFieldAccount:      "Account",
+		msgUndoFieldTags:         "Tags",
+		msgUndoFieldReimbursable: "Reimbursable",
+		msgUndoYes:               "yes",
+		msgUndoNo:                "no",
+
+		msgTemplateInvalid:    "❌ Please give the template name, plus the description and amount of the transaction",
+		msgTemplateFailed:     "Template operation failed",
+		msgTemplateSaved:      "⭐ Template \"%s\" saved: %s %s (%s)\nNext time just say \"%s\" or \"the usual\"",
+		msgTemplateReplaced:   "\n⚠️ Overwrote the template with the same name, which was: %s %s (%s)",
+		msgTemplateNotFound:   "❌ No template named \"%s\"",
+		msgTemplateNone:       "📝 No templates yet, try \"save this as a coffee template\"",
+		msgTemplateUsed:       "\n⭐ From template \"%s\"",
+		msgTemplateListHeader: "⭐ Templates:\n",
+		msgTemplateListItem:   "• %s: %s %s (%s)\n",
+		msgTemplateDeleted:    "🗑️ Template \"%s\" deleted",
+
+		msgTrendFailed:     "Failed to compute statistics",
+		msgTrendEmpty:      "📝 No expenses between %s and %s",
+		msgTrendHeader:     "📈 Spending statistics (%s ~ %s, %d days)\n",
+		msgTrendAverage:    "💸 Total expense %s, daily average %s\n",
+		msgTrendMaxDay:     "🔝 Most expensive day: %s, %s\n",
+		msgTrendNoSpend:    "🌱 No-spend days: %d\n",
+		msgTrendCompare:    "📊 Last %d days %s, the %d days before %s, %s\n",
+		msgTrendUp:         "up %.1f%%",
+		msgTrendDown:       "down %.1f%%",
+		msgTrendFlat:       "about the same",
+		msgTrendNoPrevious: "no expense before to compare with",
+		msgTrendTooShort:   "📊 The range is shorter than %d days, no trend to compare\n",
+		msgTrendSparkline:  "📉 Daily expense: %s\n",
+
+		msgForecastFailed:     "Forecast failed",
+		msgForecastHeader:     "🔮 Month-end expense estimate (month %d, day %d of %d)\n",
+		msgForecastSpent:      "💸 Spent so far %s, daily average before today %s\n",
+		msgForecastTotal:      "📈 At this pace, the month will total about %s\n",
+		msgForecastTooEarly:   "📈 Today is the first day of the month, there are no complete days to estimate from yet\n",
+		msgForecastOverBudget: "⚠️ Expected to exceed the budget of %s by about %s\n",
+		msgForecastInBudget:   "✅ Expected to stay within the budget of %s, about %s left\n",
+		msgForecastCategories: "Estimates by category:\n",
+		msgForecastCategory:   "• %s: spent %s, about %s expected\n",
+		msgForecastNote:       "ℹ️ These are estimates: extrapolated linearly from the daily average before today (today is not over yet), for reference only",
+
+		msgSharedNotEnabled:  "❌ This chat is not a shared ledger, per-member totals and settlement are unavailable",
+		msgSharedFailed:      "Shared ledger statistics failed",
+		msgSharedEmpty:       "📝 No records between %s and %s",
+		msgMemberHeader:      "👪 Members (%s ~ %s):\n",
+		msgMemberItem:        "• %s: expense %s, income %s, %d records\n",
+		msgSettleUpHeader:    "🧮 Settlement (%s ~ %s), shared expense %s:\n",
+		msgSettleUpMember:    "• %s: paid %s, share %s\n",
+		msgSettleUpTransfers: "💸 To settle up:\n",
+		msgSettleUpTransfer:  "• %s pays %s %s\n",
+		msgSettleUpBalanced:  "✅ Everyone paid exactly their share, no transfers needed",
+
+		msgSplitInvalid:      "❌ Cannot split: it takes 2 ~ %d people and at least 0.01 per person",
+		msgSplitNote:         "[Split] total %s, %d people (%s), %s each",
+		msgSplitNameSep:      ", ",
+		msgSplitUnnamed:      " and others",
+		msgSplitSummary:      "\n👥 Split: total %s, %d people, %s each",
+		msgSplitOthers:       "\n👪 Also recorded the shares of %s",
+		msgSplitOthersFailed: "\n⚠️ Failed to record the shares of some members, they can record their own",
+		msgSplitNotShared:    "\nℹ️ This chat is not a shared ledger, only your share was recorded",
+
+		msgBatchDeleteNone:        "📝 No matching records between %s and %s, nothing was deleted",
+		msgBatchDeleteTooMany:     "❌ %d records match, more than the batch delete limit of %d. Nothing was deleted; narrow the time range or add conditions",
+		msgBatchDeleteUnavailable: "❌ Batch deletes cannot be confirmed here, please try again in a chat with the bot",
+		msgBatchDeleteHeader:      "🗑️ About to delete %d records between %s and %s (expense %s, income %s):\n",
+		msgBatchDeleteItem:        "• %s %s %s 🆔 %s\n",
+		msgBatchDeleteQuestion:    "Reply 'confirm' to delete them or 'cancel' to discard (valid for %d minutes)",
+		msgBatchDeleteSuccess:     "✅ Deleted %d records (expense %s, income %s)",
+		msgBatchDeleteSkipped:     "\nℹ️ %d more records no longer exist or were changed and were skipped",
+		msgBatchDeleteGone:        "ℹ️ The listed records no longer exist or were changed, nothing was deleted",
+		msgBatchDeleteFailed:      "❌ Batch delete failed, some records may already be deleted. Please check with a query",
+
+		msgAmountNeedsConfirm: "⚠️ The amount %s looks off, please double-check (%s)",
+		msgAmountInvalid:      "⚠️ The amount of \"%s\" is invalid, please restate it (must be greater than 0)",
+		msgDescriptionEmpty:   "⚠️ The description is missing, what was the %s for?",
+
+		msgPreferenceSaved:        "⚙️ %s set to %s",
+		msgPreferenceReset:        "⚙️ %s reset to the default",
+		msgPreferenceInvalid:      "⚠️ Cannot set %s to \"%s\": %s",
+		msgPreferenceUnknown:      "⚠️ Unknown setting \"%s\", you can set currency, timezone, language and top_n",
+		msgPreferenceUnsupported:  "⚠️ Personal settings cannot be changed here",
+		msgPreferenceFailed:       "❌ Failed to save the setting, please try again later",
+		msgPreferenceCurrency:     "Currency symbol",
+		msgPreferenceTimezone:     "Timezone",
+		msgPreferenceLanguage:     "Reply language",
+		msgPreferenceTopN:         "Query list size",
+		msgPreferenceCurrencyHint: "the currency symbol can have at most 8 characters",
+		msgPreferenceTimezoneHint: "use an IANA timezone name such as Asia/Shanghai or America/New_York",
+		msgPreferenceLanguageHint: "zh (Chinese) and en (English) are supported",
+		msgPreferenceTopNHint:     "the size must be between 1 and 50",
+
+		msgDeleteMyDataUnsupported: "⚠️ Your data cannot be deleted from here; send /删除我的数据 in a chat with the bot",
+		msgExportMyDataSuccess:     "📎 Your data has been exported, download the zip file above: profile.json holds your name and settings, bills.csv the %d records you made",
+		msgExportMyDataNone:        "📝 I don't have any data about you yet",
+		msgExportMyDataUnsupported: "❌ Sending files is not supported on this platform yet, please ask the admin to export it via /api/v1/users/{openID}/export",
+		msgRequestID:               " (request ID: %s)",
+	},
+}
+
+// normalizeLanguage 将配置的语言规范化为受支持的语言，未知语言回退到中文
+func normalizeLanguage(lang string) string {
+	if _, ok := messageCatalog[lang]; ok {
+		return lang
+	}
+	return LanguageZH
+}
+
+// msg 按配置的语言渲染回复模板
+func (s *OpenAIService) msg(key messageKey, args ...interface{}) string {
+	tmpl, ok := messageCatalog[s.language][key]
+	if !ok {
+		tmpl = messageCatalog[LanguageZH][key]
+	}
+	if len(args) == 0 {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// shownRequestIDLength 回复中显示的请求 ID 末尾位数，足够让飞书支持定位日志
+const shownRequestIDLength = 8
+
+// withRequestID 开启 ShowRequestID 时在失败回复后附加平台请求 ID 的末几位，便于用户反馈问题时提供
+func (s *OpenAIService) withRequestID(text string, err error) string {
+	if s.config == nil || !s.config.ShowRequestID {
+		return text
+	}
+	id := domain.RequestIDFromError(err)
+	if id == "" {
+		return text
+	}
+	if len(id) > shownRequestIDLength {
+		id = "…" + id[len(id)-shownRequestIDLength:]
+	}
+	return text + s.msg(msgRequestID, id)
+}
+
+// formatAmount 使用用户偏好或配置的货币符号格式化金额（带千分位），sign 为 "+"、"-" 或空
+func (s *OpenAIService) formatAmount(sign string, amount float64) string {
+	return sign + domain.FormatAmount(s.currency, amount)
+}
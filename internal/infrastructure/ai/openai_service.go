@@ -3,29 +3,78 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/wyg1997/LedgerBot/config"
 	"github.com/wyg1997/LedgerBot/internal/domain"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
 	"github.com/wyg1997/LedgerBot/pkg/logger"
 )
 
+// logComponent 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别
+const logComponent = "ai"
+
 // OpenAIService implements AIService with only function calling
 type OpenAIService struct {
-	config *config.AIConfig
-	client *openai.Client
-	log    logger.Logger
+	config              *config.AIConfig
+	client              *openai.Client
+	transcriptionClient *openai.Client // 语音转写客户端
+	log                 logger.Logger
+	language            string
+	currency            string         // 金额的货币符号，用户偏好可覆盖
+	defaultLocation     *time.Location // 配置的时区（TIMEZONE）
+	location            *time.Location // 日期和时间范围使用的时区，用户偏好可覆盖
+	topN                int            // 查询默认列出的明细条数
+	pending             cache.Cache    // 待确认的操作，key 为用户+话题
+	cursors             cache.Cache    // 查询翻页游标，key 为用户+话题
+}
+
+// NewOpenAIService creates a new OpenAI service; dates and time ranges use location unless a user sets
+// their own time zone. newCache creates the pending confirmation and paging caches, which are kept in
+// memory when it is nil
+func NewOpenAIService(cfg *config.AIConfig, location *time.Location, newCache cache.Factory) domain.AIService {
+	// 语音转写未单独配置时复用对话模型的服务地址和密钥
+	transcriptionBaseURL := cfg.TranscriptionBaseURL
+	if transcriptionBaseURL == "" {
+		transcriptionBaseURL = cfg.BaseURL
+	}
+	transcriptionAPIKey := cfg.TranscriptionAPIKey
+	if transcriptionAPIKey == "" {
+		transcriptionAPIKey = cfg.APIKey
+	}
+
+	return &OpenAIService{
+		config:              cfg,
+		client:              newOpenAIClient(cfg.BaseURL, cfg.APIKey),
+		transcriptionClient: newOpenAIClient(transcriptionBaseURL, transcriptionAPIKey),
+		log:                 logger.GetLogger(logComponent),
+		language:            normalizeLanguage(cfg.Language),
+		currency:            cfg.CurrencySymbol,
+		defaultLocation:     location,
+		location:            location,
+		topN:                defaultQueryTopN,
+		pending:             newCache.Create("ai_pending"),
+		cursors:             newCache.Create("ai_cursors"),
+	}
+}
+
+// Close stops the cleanup of the pending confirmation and paging caches
+func (s *OpenAIService) Close() error {
+	_ = s.pending.Close()
+	return s.cursors.Close()
 }
 
-// NewOpenAIService creates a new OpenAI service
-func NewOpenAIService(cfg *config.AIConfig) domain.AIService {
-	// 使用 go-openai Config，以便支持自定义 BaseURL
-	openaiCfg := openai.DefaultConfig(cfg.APIKey)
-	if cfg.BaseURL != "" {
-		baseURL := cfg.BaseURL
+// newOpenAIClient 创建 go-openai 客户端，以便支持自定义 BaseURL
+func newOpenAIClient(baseURL, apiKey string) *openai.Client {
+	openaiCfg := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
 		// 去掉末尾的斜杠，避免重复 //
 		if baseURL[len(baseURL)-1] == '/' {
 			baseURL = baseURL[:len(baseURL)-1]
@@ -33,25 +82,35 @@ func NewOpenAIService(cfg *config.AIConfig) domain.AIService {
 		// go-openai 期望的是包含 /v1 的完整前缀
 		openaiCfg.BaseURL = fmt.Sprintf("%s/v1", baseURL)
 	}
+	return openai.NewClientWithConfig(openaiCfg)
+}
 
-	return &OpenAIService{
-		config: cfg,
-		client: openai.NewClientWithConfig(openaiCfg),
-		log:    logger.GetLogger(),
+// forRequest 返回使用当前消息日志记录器和用户偏好的服务副本，使处理过程中的日志带有消息的关联 ID
+func (s *OpenAIService) forRequest(billService domain.BillServiceInterface) *OpenAIService {
+	svc, ok := billService.(*BillService)
+	if !ok || svc.ctx == nil {
+		return s
 	}
+	scoped := *s
+	scoped.log = logger.FromContext(svc.ctx, logComponent)
+	scoped.applyPreferences(domain.PreferencesFromContext(svc.ctx))
+	return &scoped
 }
 
 // Execute processes user input via AI tool-calling using go-openai Tools API
-func (s *OpenAIService) Execute(input string, userName string, billService domain.BillServiceInterface, renameService domain.RenameServiceInterface, history []domain.AIMessage) (string, error) {
+func (s *OpenAIService) Execute(ctx context.Context, input string, userName string, conversationKey string, billService domain.BillServiceInterface, renameService domain.RenameServiceInterface, history []domain.AIMessage) (string, error) {
+	s = s.forRequest(billService)
+
 	// Get current year dynamically
-	currentYear := time.Now().Year()
+	currentYear := time.Now().In(s.timeLocation()).Year()
 	
 	// 1. System prompt
 	systemPrompt := "You are a personal finance bot."
 	if userName == "" {
 		systemPrompt += " The user has not provided their name yet." +
 			" If they introduce themselves as '我是XXX' or '叫我XXX' or similar, you MUST extract the name and call rename_user function." +
-			" For any other request (including recording transactions, statistics, or normal chat), you MUST politely ask the user to first tell you how to address them, and DO NOT perform any other operation until a name is set."
+			" If the same message also asks for something else (e.g. '我是张三，午饭30'), call rename_user together with the other tools in the same response." +
+			" For any other request without an introduction (including recording transactions, statistics, or normal chat), you MUST politely ask the user to first tell you how to address them, and DO NOT perform any other operation until a name is set."
 	} else {
 		systemPrompt += fmt.Sprintf(" Current user: %s.", userName)
 	}
@@ -61,11 +120,29 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 		" MULTIPLE TRANSACTIONS: If the user mentions multiple transactions in a single message (e.g., '午饭30元，打车45元' or '今天花了30块吃饭，45块打车'), you MUST call record_transaction MULTIPLE TIMES - once for each transaction. You can make multiple tool calls in a single response. Each transaction should be recorded separately with its own record_transaction call. Do NOT combine multiple transactions into a single record_transaction call." +
 		" UPDATE TRANSACTIONS: If the user wants to update an existing transaction, use the update_transaction tool. The user will provide the record_id (from the original transaction response, shown as 🆔). You can update one or more fields (description, amount, type, category). If the user mentions multiple updates in a single message, you MUST call update_transaction MULTIPLE TIMES - once for each record that needs to be updated. Only include fields that the user wants to change - do not include unchanged fields. NOTE: The original_message field will be automatically updated with the user's current update instruction - you do NOT need to include it in the tool call." +
 		" DELETE TRANSACTIONS: If the user wants to delete an existing transaction, use the delete_transaction tool. The user will provide the record_id (from the original transaction response, shown as 🆔). If the user mentions multiple deletions in a single message, you MUST call delete_transaction MULTIPLE TIMES - once for each record that needs to be deleted." +
-		fmt.Sprintf(" QUERY TRANSACTIONS: If the user wants to query or view their transaction history, use the query_transaction tool. Supported time ranges: 'today', 'yesterday', 'this_week', 'last_week', 'this_month', 'last_month', 'last_7_days', 'last_30_days', or 'custom' for specific date ranges. IMPORTANT: When user mentions dates without year (e.g., '12月1日', '1月15日', '12月1号到12月10号'), you MUST infer the current year (%d) and use 'custom' type with full date format 'YYYY-MM-DD hh:mm:ss'. If only date is provided without time, start_time defaults to 00:00:00 and end_time defaults to 23:59:59. The user may also request a specific number of top transactions (e.g., 'top 10', '前10条', '显示前20条'), which you should set in the top_n parameter (default is 5).", currentYear) +
+		" When the user wants to delete records by time range, category or keyword instead of record_id (e.g. '把今天的测试记录都删掉'), call delete_by_query once; it lists the matching records and asks the user to reply '确认删除', so do not ask for confirmation yourself." +
+		" RESTORE TRANSACTIONS: If the user wants to undo a deletion or restore a deleted transaction (e.g. '恢复 recXXX', '刚才删错了'), use the restore_transaction tool with the record_id of the deleted record." +
+		fmt.Sprintf(" QUERY TRANSACTIONS: If the user wants to query or view their transaction history, use the query_transaction tool. Supported time ranges: 'today', 'yesterday', 'this_week', 'last_week', 'this_month', 'last_month', 'last_7_days', 'last_30_days', 'this_quarter', 'last_quarter', 'this_year', 'last_year', or 'custom' for specific date ranges. For questions like '今年花了多少' or '去年' or '这个季度', use this_year / last_year / this_quarter / last_quarter instead of a custom range. IMPORTANT: When user mentions dates without year (e.g., '12月1日', '1月15日', '12月1号到12月10号'), you MUST infer the current year (%d) and use 'custom' type with full date format 'YYYY-MM-DD hh:mm:ss'. If only date is provided without time, start_time defaults to 00:00:00 and end_time defaults to 23:59:59. For open-ended ranges ('12月1日以后', 'since 12/1') provide only start_time (the range ends now); for '12月10日以前' provide only end_time (the range starts from the earliest queryable date). The user may also request a specific number of top transactions (e.g., 'top 10', '前10条', '显示前20条'), which you should set in the top_n parameter (default is %d). If the user wants a per-day breakdown or per-category statistics, set group_by to 'day' or 'category'.", currentYear, s.topN) +
+		" TAGS: Pass hashtags such as '#旅行 #报销' in the tags parameter of record_transaction (without '#') and keep them out of the description. Use the tag parameter of query_transactions for questions about one tag, e.g. '旅行一共花了多少'." +
+		" PAYMENT ACCOUNTS: Set the account parameter of record_transaction only when the user names the payment account (e.g. '信用卡买了件衣服300', '微信付的'); otherwise leave it out. Use the account parameter of query_transactions for questions about one account, e.g. '这个月信用卡花了多少'." +
+		" REIMBURSEMENTS: Set reimbursable=true on record_transaction when the user says an expense will be reimbursed (e.g. '出差打车80可报销'). When the user says a reimbursement arrived, use settle_reimbursement with the record_ids of those expenses; if they are not named, first query with pending_reimbursement=true and ask which ones were paid back." +
+		" INSTALLMENTS: When the user pays in installments (e.g. '电脑 12000，分12期'), call record_transaction once with the total amount and installments=12; the remaining installments are recorded automatically on the 1st of each month. Use list_installments and cancel_installment to review or stop plans." +
+		" RECURRING BILLS: When the user wants a bill recorded automatically every month or week (e.g. '每月5号房租4500', '每周一交停车费50'), use create_recurring instead of record_transaction. Use list_recurring and delete_recurring to review or stop them." +
+		" LOANS: Lending or borrowing money (e.g. '借给小王500', '跟老李借了2000') is neither income nor expense: use record_loan, never record_transaction. When a loan is paid back (e.g. '小王还了我300', '还了老李2000'), use settle_loan. Use loan_status for questions like '谁还欠我钱'." +
+		" TEMPLATES: '把这笔存为咖啡模板' saves a transaction as a template with save_template (pass the record_id when it refers to an existing record). '记一笔咖啡' or '老样子' records from a template with use_template, without asking for the amount: omit name for '老样子', and pass amount only when the user gives a different one (e.g. '咖啡，今天25'). Use list_templates and delete_template to review or remove templates." +
+		" UNDO: '撤销' or '撤销刚才的操作' means undo the user's latest create, delete or update: use undo_last without arguments. Use restore_transaction only when the user names a record_id to restore." +
+		" TRENDS: For averages and trends (e.g. '我平均每天花多少钱', '最近花钱是不是变多了'), use spending_trends and never compute them yourself; default to last_30_days when the user gives no range." +
+		" FORECAST: For '照这个速度这个月会花多少' or '这个月会不会超预算', use forecast_month." +
+		" SHARED LEDGER: In a shared (family) ledger, use member_breakdown for per-person totals (e.g. '这个月每个人花了多少') and settle_up for AA settlement (e.g. '这个月AA一下', '谁该给谁多少钱'). Do not confuse settle_up with settle_loan or settle_reimbursement." +
+		" SPLIT EXPENSES: When the user shares an expense with others (e.g. '昨晚聚餐600，我和小王小李AA', '四个人AA打车120'), use split_transaction with the total amount; it records only the user's share. Never record the whole amount with record_transaction, and never divide the amount yourself." +
+		" EXPORT TRANSACTIONS: If the user wants to export or download their records (e.g. '导出今年的账单', '导出成 Excel'), use the export_transactions tool with the same time range rules as query_transactions; the file is sent as an attachment, so do not list the records yourself." +
 		" When calling record_transaction, you should provide the original_message parameter with the most relevant user message from the conversation that best represents what the user said about this transaction." +
 		" For thread conversations, extract the most appropriate user message from the conversation history that led to this transaction." +
-		" '叫我XXX' or '我是XXX' means rename to XXX or extract name from the user's introduction." +
-		" Respond in Chinese."
+		" '叫我XXX', '我是XXX', 'call me XXX' or 'I am XXX' means rename to XXX or extract name from the user's introduction." +
+		" SETTINGS: When the user wants to change their currency, timezone, reply language or how many records a query lists (e.g. '金额用美元显示', '我在纽约', '用英文回复', '查询默认显示10条'), use set_preference." +
+		" PRIVACY: When the user asks for a copy of all of their data (e.g. '导出我的数据', '把你存的我的信息都发给我'), call export_my_data. When the user asks to delete all of their own data or to be forgotten by the bot (e.g. '删除我的数据', '删掉我的所有信息'), call delete_my_data; it asks the user to confirm, so do not ask yourself and never use it for deleting individual records." +
+		promptGuardInstruction +
+		fmt.Sprintf(" Respond in %s.", languageNames[s.language])
 
 	// 2. Build messages (system + history or current input)
 	msgs := []openai.ChatCompletionMessage{
@@ -75,23 +152,30 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 		},
 	}
 
+	// 话题历史只保留最近的消息，控制上下文长度
+	if max := s.config.MaxHistoryMessages; max > 0 && len(history) > max {
+		s.log.Debug("Truncating thread history: %d -> %d messages", len(history), max)
+		history = history[len(history)-max:]
+	}
+
 	if len(history) > 0 {
 		for _, m := range history {
+			// 历史消息不允许提升为 system 角色，用户内容统一清理并包裹分隔标签
 			role := openai.ChatMessageRoleUser
-			if m.Role == "system" {
-				role = openai.ChatMessageRoleSystem
-			} else if m.Role == "assistant" {
+			content := wrapUserContent(m.Content)
+			if m.Role == "assistant" {
 				role = openai.ChatMessageRoleAssistant
+				content = SanitizeUserContent(m.Content)
 			}
 			msgs = append(msgs, openai.ChatCompletionMessage{
 				Role:    role,
-				Content: m.Content,
+				Content: content,
 			})
 		}
 	} else {
 		msgs = append(msgs, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleUser,
-			Content: input,
+			Content: wrapUserContent(input),
 		})
 	}
 
@@ -120,13 +204,30 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 						},
 						"category": map[string]interface{}{
 							"type":        "string",
-							"enum":        []string{"餐饮", "交通", "购物", "娱乐", "医疗", "教育", "住房", "水电费", "通讯", "服装", "收入", "其它"},
+							"enum":        domain.DefaultCategories,
 							"description": "Transaction category. CRITICAL: You MUST automatically select a category from this enum list WITHOUT asking the user. NEVER ask '这是什么分类？' or '请选择分类' or any similar questions. Just analyze the transaction description and choose the most appropriate category immediately. Available categories: 餐饮(food/dining), 交通(transportation), 购物(shopping), 娱乐(entertainment), 医疗(medical), 教育(education), 住房(housing), 水电费(utilities), 通讯(communication), 服装(clothing), 收入(income), 其它(other). If unsure, use '其它'. This is a required parameter - you must provide a value, never ask the user to choose.",
 						},
 						"original_message": map[string]string{
 							"type":        "string",
 							"description": "The original user message that led to this transaction. For thread conversations, extract the most relevant user message from the conversation history that best represents what the user said about this transaction.",
 						},
+						"account": map[string]string{
+							"type":        "string",
+							"description": "Payment account the user explicitly mentioned, e.g. 微信, 支付宝, 信用卡, 现金. Omit it when the user did not mention one - never guess.",
+						},
+						"tags": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "Tags the user attached, without the leading '#', e.g. ['旅行', '报销'] for '#旅行 #报销 酒店800'. Omit it when the user gave no tags - never invent tags.",
+						},
+						"reimbursable": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Set to true when the user says the expense will be reimbursed, e.g. '出差打车80可报销', '公司报销'. Omit it otherwise.",
+						},
+						"installments": map[string]interface{}{
+							"type":        "integer",
+							"description": "Number of monthly installments when the user pays in installments, e.g. 12 for '电脑 12000，分12期'. amount stays the total price; only the first installment is recorded now. Omit it for normal purchases.",
+						},
 					},
 					"required": []string{"description", "amount", "type", "category"},
 				}),
@@ -149,6 +250,50 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 				}),
 			},
 		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "set_preference",
+				Description: "Change one of the user's personal settings: the currency symbol shown with amounts, the timezone used for dates and ranges, the reply language, or the default number of transactions listed by queries. Call it once per setting.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{domain.PreferenceCurrency, domain.PreferenceTimezone, domain.PreferenceLanguage, domain.PreferenceTopN},
+							"description": "The setting to change",
+						},
+						"value": map[string]string{
+							"type":        "string",
+							"description": "The new value: a currency symbol such as '$' or 'HK$'; an IANA timezone such as 'America/New_York' (convert city names yourself); 'zh' or 'en' for language; a number from 1 to 50 for top_n. Use 'default' to reset the setting.",
+						},
+					},
+					"required": []string{"name", "value"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "export_my_data",
+				Description: "Send the user a zip file with everything the bot stores about them: their name, personal settings and all transactions they recorded. Use export_transactions instead when the user only wants a spreadsheet of some period.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "delete_my_data",
+				Description: "Delete everything the bot stores about the user: their name, personal settings, bill templates and conversation state, and, after a second confirmation, all transactions they recorded. Nothing is deleted right away: the user must reply '确认' to each step.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
@@ -176,13 +321,26 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 						},
 						"category": map[string]interface{}{
 							"type":        "string",
-							"enum":        []string{"餐饮", "交通", "购物", "娱乐", "医疗", "教育", "住房", "水电费", "通讯", "服装", "收入", "其它"},
+							"enum":        domain.DefaultCategories,
 							"description": "Updated transaction category (optional, only include if user wants to change it). CRITICAL: You MUST automatically select a category from this enum list WITHOUT asking the user if category needs to be updated.",
 						},
 						"original_message": map[string]interface{}{
 							"type":        "string",
 							"description": "This field will be automatically updated with the user's current update instruction/command. You do NOT need to provide this parameter - it is handled automatically by the system. Only include if you have a specific reason to override the automatic value.",
 						},
+						"account": map[string]interface{}{
+							"type":        "string",
+							"description": "Updated payment account, e.g. 微信, 支付宝, 信用卡 (optional, only include if user wants to change it)",
+						},
+						"tags": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "The complete new list of tags without the leading '#' (optional, only include if user wants to change tags). It replaces the existing tags, so keep the tags that should stay.",
+						},
+						"reimbursable": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Mark (true) or unmark (false) the transaction as reimbursable (optional, only include if user wants to change it)",
+						},
 					},
 					"required": []string{"record_id"},
 				}),
@@ -198,38 +356,564 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 					"properties": map[string]interface{}{
 						"record_id": map[string]string{
 							"type":        "string",
-							"description": "The record_id of the transaction to delete (from the original record response, shown as 🆔)",
+							"description": "The record_id of the transaction to delete (from the original record response, shown as 🆔)",
+						},
+					},
+					"required": []string{"record_id"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "delete_by_query",
+				Description: "Delete all of the user's transactions matching a time range and optional category or keyword, e.g. '把今天的测试记录都删掉'. Nothing is deleted right away: the matching records are listed and the user must reply '确认删除' to delete them.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"time_range_type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "this_quarter", "last_quarter", "this_year", "last_year", "custom"},
+							"description": fmt.Sprintf("Time range type, same as query_transactions. For dates without a year, use the current year (%d) with 'custom'.", currentYear),
+						},
+						"start_time": map[string]string{
+							"type":        "string",
+							"description": "Start time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom')",
+						},
+						"end_time": map[string]string{
+							"type":        "string",
+							"description": "End time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom')",
+						},
+						"category": map[string]interface{}{
+							"type":        "string",
+							"enum":        domain.DefaultCategories,
+							"description": "Only delete transactions of this category. Omit it unless the user names a category.",
+						},
+						"keyword": map[string]string{
+							"type":        "string",
+							"description": "Only delete transactions whose description contains this keyword, e.g. 测试 for '测试记录'. Omit it when the user gives none.",
+						},
+					},
+					"required": []string{"time_range_type"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "restore_transaction",
+				Description: "Restore a previously deleted transaction record. Deleted records can only be restored within the retention window. You need the record_id of the deleted record.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"record_id": map[string]string{
+							"type":        "string",
+							"description": "The record_id of the deleted transaction to restore (shown as 🆔)",
+						},
+					},
+					"required": []string{"record_id"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "query_transactions",
+				Description: "Query financial transactions within a specified time range. Use this when the user wants to view their transaction history, check spending, or see financial summaries.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"time_range_type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "this_quarter", "last_quarter", "this_year", "last_year", "custom"},
+							"description": fmt.Sprintf("Time range type. Use predefined ranges (today, yesterday, this_week, last_week, this_month, last_month, last_7_days, last_30_days, this_quarter, last_quarter, this_year, last_year) or 'custom' for specific date ranges. Quarters are calendar quarters (Jan-Mar, Apr-Jun, Jul-Sep, Oct-Dec). IMPORTANT: When user mentions dates without year (e.g., '12月1日', '1月15日'), you MUST infer the current year (%d) and use 'custom' type with full date format.", currentYear),
+						},
+						"start_time": map[string]string{
+							"type":        "string",
+							"description": fmt.Sprintf("Start time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom'). If only date is provided without time, it will default to 00:00:00. MUST include year (e.g., '%d-12-19 00:00:00'). Omit it for open-ended ranges like '12月10日以前' - the range then starts from the earliest queryable date. For 'custom' at least one of start_time and end_time is required.", currentYear),
+						},
+						"end_time": map[string]string{
+							"type":        "string",
+							"description": fmt.Sprintf("End time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom'). If only date is provided without time, it will default to 23:59:59. MUST include year (e.g., '%d-12-19 23:59:59'). Omit it for open-ended ranges like '12月1日以后' - the range then ends now. For 'custom' at least one of start_time and end_time is required.", currentYear),
+						},
+						"top_n": map[string]interface{}{
+							"type":        "integer",
+							"description": "Number of top transactions to return (sorted by amount descending). Default is 5. User may request a different number (e.g., 'top 10', '前10条').",
+							"default":     5,
+						},
+						"group_by": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{GroupByNone, GroupByDay, GroupByCategory},
+							"description": "How to group the results. 'none' (default) lists the top_n transactions by amount; 'day' shows each day's transactions with a daily subtotal (use when the user wants to review spending day by day, e.g. '按天看', '每天花了多少'); 'category' shows subtotals and counts per category (e.g. '按分类统计', '各类花了多少').",
+							"default":     GroupByNone,
+						},
+						"account": map[string]string{
+							"type":        "string",
+							"description": "Only include transactions paid with this account, e.g. 信用卡 for '这个月信用卡花了多少'. Omit it to include all accounts.",
+						},
+						"tag": map[string]string{
+							"type":        "string",
+							"description": "Only include transactions with this tag (without the leading '#'), e.g. 旅行 for '这次旅行花了多少' or '#旅行 的账单'. Omit it to include all transactions.",
+						},
+						"pending_reimbursement": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Set to true to only list reimbursable expenses that have not been reimbursed yet, e.g. '还有哪些没报销'.",
+						},
+					},
+					"required": []string{"time_range_type"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "settle_reimbursement",
+				Description: "Mark reimbursable expenses as reimbursed and record the reimbursement as one income transaction. Use this when the user says a reimbursement has arrived, e.g. '这几笔报销到账了', '报销款 560 到了'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"record_ids": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "The record_ids of the reimbursable expenses that were paid back (shown as 🆔). Query with pending_reimbursement first if the user did not name them.",
+						},
+						"amount": map[string]interface{}{
+							"type":        "number",
+							"description": "The amount actually received (optional). Omit it to use the sum of the expenses.",
+						},
+					},
+					"required": []string{"record_ids"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "list_installments",
+				Description: "List the user's ongoing installment plans. Use this when the user asks about their installments, e.g. '我还有哪些分期'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "cancel_installment",
+				Description: "Cancel an installment plan so the remaining installments are no longer recorded, e.g. '取消电脑的分期', '提前还清了'. Call list_installments first if the plan id is unknown.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"plan_id": map[string]string{
+							"type":        "string",
+							"description": "The id of the installment plan (from list_installments or the record reply)",
+						},
+						"delete_future_records": map[string]interface{}{
+							"type":        "boolean",
+							"description": "Also delete the installment records of this plan dated in the future. Default false.",
+						},
+					},
+					"required": []string{"plan_id"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "create_recurring",
+				Description: "Create a rule that records a bill automatically every month or every week, e.g. '每月5号房租4500', '每周一交停车费50', '每个月1号扣视频会员25'. The first bill is recorded on the next matching date, not now.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"description": map[string]string{
+							"type":        "string",
+							"description": "Description of the bill, e.g. '房租'",
+						},
+						"amount": map[string]string{
+							"type":        "number",
+							"description": "Amount of each bill",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"expense", "income"},
+							"description": "Type of transaction",
+						},
+						"category": map[string]interface{}{
+							"type":        "string",
+							"enum":        domain.DefaultCategories,
+							"description": "Transaction category. Select it automatically from the enum list without asking the user; if unsure, use '其它'.",
+						},
+						"cadence": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{string(domain.RecurringMonthly), string(domain.RecurringWeekly)},
+							"description": "monthly: on day_of_month every month; weekly: on weekday every week",
+						},
+						"day_of_month": map[string]interface{}{
+							"type":        "integer",
+							"description": "Day of month (1-31) for monthly rules. Days beyond the end of a month are recorded on its last day.",
+						},
+						"weekday": map[string]interface{}{
+							"type":        "integer",
+							"description": "Day of week for weekly rules: 1 = Monday ... 7 = Sunday",
+						},
+					},
+					"required": []string{"description", "amount", "type", "category", "cadence"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "list_recurring",
+				Description: "List the user's recurring bill rules, e.g. '我有哪些自动记账', '看看周期账单'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "delete_recurring",
+				Description: "Delete a recurring bill rule so it stops recording, e.g. '房租不用自动记了'. Bills already recorded are kept. Call list_recurring first if the rule id is unknown.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"rule_id": map[string]string{
+							"type":        "string",
+							"description": "The id of the recurring rule (from list_recurring or the create reply)",
+						},
+					},
+					"required": []string{"rule_id"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "record_loan",
+				Description: "Record money lent to or borrowed from someone, e.g. '借给小王500', '跟老李借了2000'. Loans are kept out of income and expense totals.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"direction": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{string(domain.LoanLent), string(domain.LoanBorrowed)},
+							"description": "lent: the user lent money to the counterparty (借出); borrowed: the user borrowed from the counterparty (借入)",
+						},
+						"counterparty": map[string]string{
+							"type":        "string",
+							"description": "Name of the other person, e.g. '小王'",
+						},
+						"amount": map[string]string{
+							"type":        "number",
+							"description": "Amount of the loan",
+						},
+					},
+					"required": []string{"direction", "counterparty", "amount"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "loan_status",
+				Description: "List outstanding loan balances per counterparty, e.g. '谁还欠我钱', '我还欠老李多少'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"counterparty": map[string]string{
+							"type":        "string",
+							"description": "Only show the balance with this person (optional)",
+						},
+					},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "settle_loan",
+				Description: "Record a repayment of a loan with a counterparty, in either direction, e.g. '小王还了我300', '把老李的钱还了'. The direction follows the outstanding balance.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"counterparty": map[string]string{
+							"type":        "string",
+							"description": "Name of the other person",
+						},
+						"amount": map[string]string{
+							"type":        "number",
+							"description": "Amount repaid (optional). Omit it when the whole balance is settled.",
+						},
+					},
+					"required": []string{"counterparty"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "save_template",
+				Description: "Save a transaction as a named template so it can be recorded again by name, e.g. '把这笔存为咖啡模板'. A template with the same name is overwritten.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]string{
+							"type":        "string",
+							"description": "Short name of the template, e.g. 咖啡",
+						},
+						"record_id": map[string]string{
+							"type":        "string",
+							"description": "record_id of an existing record to copy (optional). Omit it and give the fields below when the user describes the transaction.",
+						},
+						"description": map[string]string{
+							"type":        "string",
+							"description": "Description of the transaction",
+						},
+						"amount": map[string]string{
+							"type":        "number",
+							"description": "Amount of money (must be > 0)",
+						},
+						"type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"expense", "income"},
+							"description": "Type of transaction",
+						},
+						"category": map[string]interface{}{
+							"type":        "string",
+							"enum":        domain.DefaultCategories,
+							"description": "Transaction category. Select it automatically from the enum list without asking the user; if unsure, use '其它'.",
+						},
+					},
+					"required": []string{"name"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "use_template",
+				Description: "Record a transaction from a saved template, e.g. '记一笔咖啡', '老样子'. Never ask for the amount: the template's amount is used unless the user gives a different one.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]string{
+							"type":        "string",
+							"description": "Name of the template (optional). Omit it for '老样子' to use the most recently used template.",
+						},
+						"amount": map[string]string{
+							"type":        "number",
+							"description": "Amount overriding the template's amount (optional), only when the user gives one",
+						},
+					},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "list_templates",
+				Description: "List the user's saved templates, e.g. '我有哪些模板'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "delete_template",
+				Description: "Delete one of the user's saved templates, e.g. '删除咖啡模板'. Records already made are kept.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]string{
+							"type":        "string",
+							"description": "Name of the template",
+						},
+					},
+					"required": []string{"name"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "undo_last",
+				Description: "Undo the user's latest operation, e.g. '撤销', '撤销刚才的操作': a new record is deleted, a deleted record is restored, an update is reverted. Undoing an undo is not supported.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "spending_trends",
+				Description: "Compute spending statistics within a time range: daily average expense, the week-over-week (month-over-month for ranges of 60 days or more) trend, the most expensive day and the number of no-spend days. Use this for questions like '我平均每天花多少钱' or '最近花钱是不是变多了'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"time_range_type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "this_quarter", "last_quarter", "this_year", "last_year", "custom"},
+							"description": fmt.Sprintf("Time range type, same as query_transactions. Default to last_30_days when the user gives no range. Use 'custom' with full dates including the year (current year is %d) for specific ranges.", currentYear),
+						},
+						"start_time": map[string]string{
+							"type":        "string",
+							"description": "Start time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
+						},
+						"end_time": map[string]string{
+							"type":        "string",
+							"description": "End time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
+						},
+					},
+					"required": []string{"time_range_type"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "forecast_month",
+				Description: "Estimate this month's total expense at the current pace, compared with the monthly budget when one is set, with per-category estimates. Use it for '照这个速度这个月会花多少', '这个月会超预算吗'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "member_breakdown",
+				Description: "Show each member's expense and income totals within a time range in a shared (family) ledger, e.g. '这个月每个人花了多少'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"time_range_type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "this_quarter", "last_quarter", "this_year", "last_year", "custom"},
+							"description": fmt.Sprintf("Time range type, same as query_transactions. Default to this_month when the user gives no range. Use 'custom' with full dates including the year (current year is %d) for specific ranges.", currentYear),
+						},
+						"start_time": map[string]string{
+							"type":        "string",
+							"description": "Start time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
+						},
+						"end_time": map[string]string{
+							"type":        "string",
+							"description": "End time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
+						},
+					},
+					"required": []string{"time_range_type"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "settle_up",
+				Description: "AA settlement of a shared (family) ledger: split the expenses within a time range among the members (equally, or by configured weights) and list who should pay whom, e.g. '这个月AA一下'.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"time_range_type": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "this_quarter", "last_quarter", "this_year", "last_year", "custom"},
+							"description": fmt.Sprintf("Time range type, same as query_transactions. Default to this_month when the user gives no range. Use 'custom' with full dates including the year (current year is %d) for specific ranges.", currentYear),
+						},
+						"start_time": map[string]string{
+							"type":        "string",
+							"description": "Start time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
+						},
+						"end_time": map[string]string{
+							"type":        "string",
+							"description": "End time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
+						},
+					},
+					"required": []string{"time_range_type"},
+				}),
+			},
+		},
+		{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        "split_transaction",
+				Description: "Record the user's share of an expense split among several people (AA), e.g. '昨晚聚餐600，我和小王小李AA' records 200, not 600. You MUST automatically select the category from the enum list without asking the user.",
+				Parameters: mustMarshalJSON(map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"description": map[string]string{
+							"type":        "string",
+							"description": "Description of the expense",
+						},
+						"total_amount": map[string]interface{}{
+							"type":        "number",
+							"description": "The total amount before splitting (must be > 0)",
+						},
+						"participants": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "Names of the OTHER people sharing the expense, not including the user, e.g. ['小王', '小李'] for '我和小王小李AA'. Omit it when the user only gives a head count.",
+						},
+						"people": map[string]interface{}{
+							"type":        "integer",
+							"description": "Total number of people sharing the expense, including the user, e.g. 4 for '四个人AA'. Omit it when all participants are named.",
+						},
+						"record_for": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{"me", "all"},
+							"description": "Whose share to record. 'me' (default) records only the user's share; 'all' also records each named participant's share under their own name, only in a shared ledger and only when the user asks for it (e.g. '也帮他们各记一笔').",
+							"default":     "me",
+						},
+						"category": map[string]interface{}{
+							"type":        "string",
+							"enum":        domain.DefaultCategories,
+							"description": "Transaction category. Select it automatically from the enum list without asking the user; if unsure, use '其它'.",
+						},
+						"original_message": map[string]string{
+							"type":        "string",
+							"description": "The original user message that led to this transaction",
+						},
+						"account": map[string]string{
+							"type":        "string",
+							"description": "Payment account the user explicitly mentioned, e.g. 微信, 信用卡. Omit it when the user did not mention one - never guess.",
+						},
+						"tags": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]string{"type": "string"},
+							"description": "Tags the user attached, without the leading '#'. Omit it when the user gave no tags - never invent tags.",
 						},
 					},
-					"required": []string{"record_id"},
+					"required": []string{"description", "total_amount", "category"},
 				}),
 			},
 		},
 		{
 			Type: openai.ToolTypeFunction,
 			Function: &openai.FunctionDefinition{
-				Name:        "query_transactions",
-				Description: "Query financial transactions within a specified time range. Use this when the user wants to view their transaction history, check spending, or see financial summaries.",
+				Name:        "export_transactions",
+				Description: "Export financial transactions within a time range as a CSV file (opens in Excel) and send it to the user as an attachment. Use this when the user wants to export, download or back up their records.",
 				Parameters: mustMarshalJSON(map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"time_range_type": map[string]interface{}{
 							"type":        "string",
-							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "custom"},
-							"description": fmt.Sprintf("Time range type. Use predefined ranges (today, yesterday, this_week, last_week, this_month, last_month, last_7_days, last_30_days) or 'custom' for specific date ranges. IMPORTANT: When user mentions dates without year (e.g., '12月1日', '1月15日'), you MUST infer the current year (%d) and use 'custom' type with full date format.", currentYear),
+							"enum":        []string{"today", "yesterday", "this_week", "last_week", "this_month", "last_month", "last_7_days", "last_30_days", "this_quarter", "last_quarter", "this_year", "last_year", "custom"},
+							"description": fmt.Sprintf("Time range type, same as query_transactions. Use 'custom' with full dates including the year (current year is %d) for specific ranges.", currentYear),
 						},
 						"start_time": map[string]string{
 							"type":        "string",
-							"description": fmt.Sprintf("Start time in format 'YYYY-MM-DD hh:mm:ss' (required only if time_range_type is 'custom'). If only date is provided without time, it will default to 00:00:00. MUST include year (e.g., '%d-12-19 00:00:00').", currentYear),
+							"description": "Start time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
 						},
 						"end_time": map[string]string{
 							"type":        "string",
-							"description": fmt.Sprintf("End time in format 'YYYY-MM-DD hh:mm:ss' (required only if time_range_type is 'custom'). If only date is provided without time, it will default to 23:59:59. MUST include year (e.g., '%d-12-19 23:59:59').", currentYear),
-						},
-						"top_n": map[string]interface{}{
-							"type":        "integer",
-							"description": "Number of top transactions to return (sorted by amount descending). Default is 5. User may request a different number (e.g., 'top 10', '前10条').",
-							"default":     5,
+							"description": "End time in format 'YYYY-MM-DD hh:mm:ss' (only used when time_range_type is 'custom').",
 						},
 					},
 					"required": []string{"time_range_type"},
@@ -245,24 +929,26 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 		Tools:    tools,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// 5. Call CreateChatCompletion
+	start := time.Now()
 	resp, err := s.client.CreateChatCompletion(ctx, req)
+	callLog := s.log.WithFields(logger.Fields{"user": userName, "model": s.config.Model, "latency_ms": time.Since(start).Milliseconds()})
 	if err != nil {
-		s.log.Error("ai call: %v", err)
-		return "抱歉，无法理解您的请求", err
+		callLog.Error("ai call: %v", err)
+		return s.msg(msgAIFailed), err
 	}
 	if len(resp.Choices) == 0 {
-		return "抱歉，没有获得有效的AI响应", fmt.Errorf("empty choices")
+		return s.msg(msgAIEmpty), fmt.Errorf("empty choices")
 	}
 
 	choice := resp.Choices[0]
 	msg := choice.Message
 
 	// Debug: Print full AI response
-	s.log.Debug("AI response received: role=%s, content=%s, toolCallsCount=%d", msg.Role, msg.Content, len(msg.ToolCalls))
+	callLog.WithField("tool_calls", len(msg.ToolCalls)).Debug("AI response received: role=%s, content=%s", msg.Role, msg.Content)
 	if len(msg.ToolCalls) > 0 {
 		for i, tc := range msg.ToolCalls {
 			s.log.Debug("ToolCall[%d]: id=%s, type=%s, function.name=%s, function.arguments=%s",
@@ -275,12 +961,58 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 		return msg.Content, nil
 	}
 
+	// 去掉完全重复的工具调用（同名且参数一致），并限制单条消息的工具调用数量
+	// 去重前先记下模型返回的数量，被去掉的调用记下 ID，便于与模型端的日志对照
+	callLog.WithField("tool_calls", len(msg.ToolCalls)).Info("AI returned tool calls")
+	toolCalls, droppedIDs := dedupToolCalls(msg.ToolCalls)
+	if len(droppedIDs) > 0 {
+		callLog.WithField("dropped_ids", strings.Join(droppedIDs, ",")).Warn("Dropped %d duplicate tool calls", len(droppedIDs))
+	}
+	callLog.WithFields(logger.Fields{"received": len(msg.ToolCalls), "unique": len(toolCalls), "limit": s.config.MaxToolCalls}).Info("AI tool calls")
+	if s.config.MaxToolCalls > 0 && len(toolCalls) > s.config.MaxToolCalls {
+		for i, tc := range toolCalls {
+			s.log.Warn("Over-limit ToolCall[%d]: function.name=%s, function.arguments=%s", i, tc.Function.Name, tc.Function.Arguments)
+		}
+		return s.msg(msgTooManyToolCalls, len(toolCalls), s.config.MaxToolCalls), nil
+	}
+
+	// 大额记账或批量删除需要用户确认后再执行，工具调用暂存到待确认队列
+	if userName != "" && conversationKey != "" {
+		if prompt := s.confirmationPrompt(toolCalls); prompt != "" {
+			if err := s.storePendingConfirmation(conversationKey, toolCalls, input, userName); err != nil {
+				s.log.Error("Failed to store pending confirmation: key=%s, err=%v", conversationKey, err)
+			} else {
+				s.log.Info("Operation requires confirmation: key=%s, tool_calls=%d", conversationKey, len(toolCalls))
+				return prompt, nil
+			}
+		}
+	}
+
 	// 7. Handle tool calls locally (record_transaction / rename_user)
-	// Support multiple toolcalls - process all and return combined result
+	return s.executeToolCalls(toolCalls, input, userName, conversationKey, billService, renameService)
+}
+
+// executeToolCalls 依次执行工具调用并合并结果
+// Support multiple toolcalls - process all and return combined result
+func (s *OpenAIService) executeToolCalls(toolCalls []openai.ToolCall, input string, userName string, conversationKey string, billService domain.BillServiceInterface, renameService domain.RenameServiceInterface) (string, error) {
 	var results []string
 	var hasError bool
+	needName := false // 未知用户且没有成功改名时跳过的操作，最后只提示一次询问称呼
 
-	for _, tc := range msg.ToolCalls {
+	// 未知用户在同一条消息里自我介绍并记账（如“我是张三，午饭30”）时，先执行改名，后续操作使用新名字
+	if userName == "" {
+		toolCalls = renameFirst(toolCalls)
+	}
+
+	// 多笔记账合并为一次批量写入，减少请求次数；在第一次遇到记账时执行，此时用户名已确定
+	var batched map[int]toolResult
+	batchDone := false
+
+	// 疑似重复或金额过大而跳过的记账，回复"确认记录"后照常记录
+	var duplicates []openai.ToolCall
+	var duplicateLines []int
+
+	for i, tc := range toolCalls {
 		fn := tc.Function
 		if fn.Name == "" {
 			continue
@@ -290,59 +1022,180 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 		var args map[string]interface{}
 		if err := json.Unmarshal([]byte(fn.Arguments), &args); err != nil {
 			s.log.Error("parse tool args: %v", err)
-			results = append(results, fmt.Sprintf("❌ %s: 参数解析失败", name))
+			results = append(results, s.msg(msgArgsParseFailed, name))
 			hasError = true
 			continue
 		}
 
-		s.log.Info("AI toolcall triggered: tool=%s, user=%s, args=%+v", name, userName, args)
+		toolLog := s.log.WithFields(logger.Fields{"tool": name, "user": userName})
+		toolLog.WithField("args", args).Info("AI toolcall triggered")
+		start := time.Now()
 
 		// 未知用户时，只允许 rename_user
 		if userName == "" && name != "rename_user" {
 			s.log.Info("Blocking tool %s for unknown user, asking for name first", name)
-			return "我还不知道您是谁？请告诉我您的称呼。\n您可以直接说：我是张三", nil
+			needName = true
+			continue
+		}
+
+		// 一次删除多条属于破坏性操作，只有当前用户最新消息明确提到删除时才执行
+		if deletionRefused(name, toolCalls, input) {
+			s.log.Warn("Refusing %s not requested by latest message: user=%s, input=%s, args=%+v", name, userName, input, args)
+			results = append(results, s.msg(msgDeleteRefused))
+			hasError = true
+			continue
 		}
 
 		var result string
 		var err error
 
+		if name == "record_transaction" && !batchDone {
+			batchDone = true
+			if svc, ok := billService.(*BillService); ok {
+				batched = s.batchRecordTransactions(toolCalls, svc)
+			}
+		}
+
 		switch name {
 		case "record_transaction":
-			result, err = s.handleRecordTransaction(args, billService.(*BillService))
+			res, isBatched := batched[i]
+			if isBatched {
+				result, err = res.text, res.err
+			} else {
+				result, err = s.handleRecordTransaction(args, billService.(*BillService))
+			}
+			if needsConfirmation(err) {
+				duplicates = append(duplicates, tc)
+				duplicateLines = append(duplicateLines, len(results))
+				var dupErr *domain.DuplicateBillError
+				var invalidErr *domain.BillValidationError
+				if errors.As(err, &dupErr) {
+					results = append(results, s.msg(msgDuplicateSkipped, dupErr.Existing.RecordID))
+				} else if errors.As(err, &invalidErr) {
+					results = append(results, s.validationText(invalidErr))
+				}
+				continue
+			}
+			if isBatched && err != nil {
+				results = append(results, result)
+				hasError = true
+				continue
+			}
 		case "update_transaction":
 			// Pass current input so we can use it as original_message for updates
 			result, err = s.handleUpdateTransaction(args, billService.(*BillService), input)
 		case "delete_transaction":
 			result, err = s.handleDeleteTransaction(args, billService.(*BillService))
+		case "delete_by_query":
+			result, err = s.handleDeleteByQuery(args, billService.(*BillService), input, userName, conversationKey)
+		case "restore_transaction":
+			result, err = s.handleRestoreTransaction(args, billService.(*BillService))
 		case "query_transactions":
-			result, err = s.handleQueryTransactions(args, billService.(*BillService))
+			result, err = s.handleQueryTransactions(args, billService.(*BillService), conversationKey)
+		case "settle_reimbursement":
+			result, err = s.handleSettleReimbursement(args, billService.(*BillService))
+		case "list_installments":
+			result, err = s.handleListInstallments(billService.(*BillService))
+		case "cancel_installment":
+			result, err = s.handleCancelInstallment(args, billService.(*BillService))
+		case "create_recurring":
+			result, err = s.handleCreateRecurring(args, billService.(*BillService))
+		case "list_recurring":
+			result, err = s.handleListRecurring(billService.(*BillService))
+		case "delete_recurring":
+			result, err = s.handleDeleteRecurring(args, billService.(*BillService))
+		case "record_loan":
+			result, err = s.handleRecordLoan(args, billService.(*BillService))
+		case "loan_status":
+			result, err = s.handleLoanStatus(args, billService.(*BillService))
+		case "settle_loan":
+			result, err = s.handleSettleLoan(args, billService.(*BillService))
+		case "save_template":
+			result, err = s.handleSaveTemplate(args, billService.(*BillService))
+		case "use_template":
+			result, err = s.handleUseTemplate(args, billService.(*BillService))
+		case "list_templates":
+			result, err = s.handleListTemplates(billService.(*BillService))
+		case "delete_template":
+			result, err = s.handleDeleteTemplate(args, billService.(*BillService))
+		case "undo_last":
+			result, err = s.handleUndoLast(billService.(*BillService))
+		case "set_preference":
+			result, err = s.handleSetPreference(args, billService.(*BillService))
+		case "export_my_data":
+			result, err = s.handleExportMyData(billService.(*BillService))
+		case "delete_my_data":
+			result, err = s.handleDeleteMyData(billService.(*BillService))
+		case "spending_trends":
+			result, err = s.handleSpendingTrends(args, billService.(*BillService))
+		case "forecast_month":
+			result, err = s.handleForecastMonth(billService.(*BillService))
+		case "member_breakdown":
+			result, err = s.handleMemberBreakdown(args, billService.(*BillService))
+		case "settle_up":
+			result, err = s.handleSettleUp(args, billService.(*BillService))
+		case "split_transaction":
+			result, err = s.handleSplitTransaction(args, billService.(*BillService))
+		case "export_transactions":
+			result, err = s.handleExportTransactions(args, billService.(*BillService))
 		case "rename_user":
 			result, err = s.handleRenameUser(args, renameService.(*RenameService))
+			if err == nil {
+				userName = getString(args, "name")
+				if svc, ok := billService.(*BillService); ok {
+					svc.userName = userName
+					svc.ctx = domain.WithOperator(svc.ctx, userName)
+				}
+			}
 		default:
 			s.log.Error("Unknown tool call: %s", name)
-			results = append(results, fmt.Sprintf("❌ 未知操作: %s", name))
+			results = append(results, s.msg(msgUnknownTool, name))
 			hasError = true
 			continue
 		}
 
+		toolLog = toolLog.WithField("latency_ms", time.Since(start).Milliseconds())
 		if err != nil {
-			s.log.Error("Tool call %s failed: %v", name, err)
-			results = append(results, fmt.Sprintf("❌ %s 执行失败: %v", name, err))
+			if id := domain.RequestIDFromError(err); id != "" {
+				toolLog = toolLog.WithField("request_id", id)
+			}
+			toolLog.Error("Tool call failed: %v", err)
+			results = append(results, s.withRequestID(s.msg(msgToolFailed, name, err), err))
 			hasError = true
 		} else {
+			toolLog.Info("Tool call done")
 			results = append(results, result)
 		}
 	}
 
+	if len(duplicates) > 0 {
+		if conversationKey == "" {
+			s.log.Info("Duplicate bills skipped without a conversation to confirm in: count=%d", len(duplicates))
+		} else if err := s.storePendingConfirmation(conversationKey, duplicates, input, userName); err != nil {
+			s.log.Error("Failed to store pending duplicate confirmation: key=%s, err=%v", conversationKey, err)
+		} else {
+			for _, line := range duplicateLines {
+				results[line] += s.msg(msgDuplicateConfirmHint)
+			}
+		}
+	}
+
+	if needName {
+		if len(results) == 0 {
+			return s.msg(msgAskName), nil
+		}
+		results = append(results, s.msg(msgAskName))
+	}
+
 	// Return combined results
 	if len(results) == 0 {
-		return "未知操作", fmt.Errorf("no valid tool calls")
+		return s.msg(msgUnknownOperation), fmt.Errorf("no valid tool calls")
 	}
 
 	// If all succeeded, join with double newlines for better separation; if any failed, indicate error
 	response := ""
 	if hasError {
-		response = "部分操作完成：\n" + fmt.Sprintf("%s\n", results[0])
+		response = s.msg(msgPartialDone) + fmt.Sprintf("%s\n", results[0])
 		for i := 1; i < len(results); i++ {
 			response += results[i] + "\n"
 		}
@@ -357,6 +1210,22 @@ func (s *OpenAIService) Execute(input string, userName string, billService domai
 	return response, nil
 }
 
+// renameFirst 将 rename_user 调用移到最前面，其余调用保持原有顺序
+func renameFirst(toolCalls []openai.ToolCall) []openai.ToolCall {
+	ordered := make([]openai.ToolCall, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		if tc.Function.Name == "rename_user" {
+			ordered = append(ordered, tc)
+		}
+	}
+	for _, tc := range toolCalls {
+		if tc.Function.Name != "rename_user" {
+			ordered = append(ordered, tc)
+		}
+	}
+	return ordered
+}
+
 // mustMarshalJSON is a small helper to build json.RawMessage
 func mustMarshalJSON(v interface{}) json.RawMessage {
 	b, err := json.Marshal(v)
@@ -366,100 +1235,244 @@ func mustMarshalJSON(v interface{}) json.RawMessage {
 	return b
 }
 
-func (s *OpenAIService) handleRecordTransaction(args map[string]interface{}, svc *BillService) (string, error) {
-	description := getString(args, "description")
-	amount := getFloat64(args, "amount")
-	transType := getString(args, "type")
-	category := getString(args, "category")
-	originalMsg := getString(args, "original_message")
+// dedupToolCalls 去掉同名且参数 JSON 完全相同的重复工具调用，保留首次出现的顺序，并返回被去掉的调用 ID
+func dedupToolCalls(toolCalls []openai.ToolCall) (unique []openai.ToolCall, droppedIDs []string) {
+	seen := make(map[string]bool, len(toolCalls))
+	unique = make([]openai.ToolCall, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		// 规范化参数 JSON，避免空白或键顺序不同导致判断失误
+		argsKey := tc.Function.Arguments
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &parsed); err == nil {
+			if b, err := json.Marshal(parsed); err == nil {
+				argsKey = string(b)
+			}
+		}
+		key := tc.Function.Name + "\x00" + argsKey
+		if seen[key] {
+			droppedIDs = append(droppedIDs, tc.ID)
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, tc)
+	}
+	return unique, droppedIDs
+}
+
+// recordTransactionInput 解析 record_transaction 的参数，参数无效时 ok 为 false
+func recordTransactionInput(args map[string]interface{}) (input domain.NewBillInput, ok bool) {
+	input = domain.NewBillInput{
+		Description: getString(args, "description"),
+		Amount:      getAmount(args, "amount"),
+		Type:        domain.BillTypeExpense,
+		Category:    getString(args, "category"),
+		OriginalMsg: getString(args, "original_message"),
+		Account:     strings.TrimSpace(getString(args, "account")),
+		Tags:        domain.NormalizeTags(getStringSlice(args, "tags")),
+	}
+	input.Reimbursable, _ = getBool(args, "reimbursable")
+	// 日期由服务器自动使用当前时间，不接收 AI 传入的日期参数；只有收据记账时使用服务端写入的收据日期
+	if date, err := time.Parse(time.RFC3339, getString(args, receiptDateArg)); err == nil {
+		input.Date = &date
+	}
+	if getString(args, "type") == "income" {
+		input.Type = domain.BillTypeIncome
+	}
+	return input, input.Description != "" && input.Amount > 0
+}
+
+// batchRecordTransactions 将同一条回复中的多个 record_transaction 合并为一次批量写入
+// 返回按工具调用下标索引的结果；参数无效或只有一笔时不做批量处理，交给逐条处理的流程
+func (s *OpenAIService) batchRecordTransactions(toolCalls []openai.ToolCall, svc *BillService) map[int]toolResult {
+	var indexes []int
+	var inputs []domain.NewBillInput
+	for i, tc := range toolCalls {
+		if tc.Function.Name != "record_transaction" {
+			continue
+		}
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.Function.Arguments), &args); err != nil {
+			continue
+		}
+		input, ok := recordTransactionInput(args)
+		// 分期记账单独处理，需要同时保存分期计划
+		if !ok || installmentCount(args) > 0 {
+			continue
+		}
+		indexes = append(indexes, i)
+		inputs = append(inputs, input)
+	}
+	if len(inputs) < 2 {
+		return nil
+	}
+
+	s.log.Info("Batch recording transactions: count=%d", len(inputs))
+	bills, err := svc.CreateBills(inputs)
+
+	results := make(map[int]toolResult, len(indexes))
+	batchErr, partial := err.(*domain.BatchCreateError)
+	var invalidErr *domain.BillValidationError
+	for n, idx := range indexes {
+		in := inputs[n]
+		switch {
+		case err == nil, partial && batchErr.Errors[n] == nil:
+			results[idx] = toolResult{text: s.recordSuccessText(bills[n])}
+		case partial && needsConfirmation(batchErr.Errors[n]):
+			results[idx] = toolResult{err: batchErr.Errors[n]}
+		case partial && errors.As(batchErr.Errors[n], &invalidErr):
+			results[idx] = toolResult{text: s.validationText(invalidErr)}
+		case partial:
+			results[idx] = toolResult{text: s.withRequestID(s.msg(msgBatchItemFailed, in.Description, s.formatAmount(signOf(in.Type), in.Amount), batchErr.Errors[n]), batchErr.Errors[n]), err: batchErr.Errors[n]}
+		default:
+			results[idx] = toolResult{text: s.withRequestID(s.msg(msgBatchItemFailed, in.Description, s.formatAmount(signOf(in.Type), in.Amount), err), err), err: err}
+		}
+	}
+	return results
+}
 
-	if description == "" || amount <= 0 {
-		s.log.Error("Invalid transaction args: description=%s, amount=%.2f", description, amount)
-		return "请提供有效的交易信息", fmt.Errorf("invalid args")
+// toolResult 单个工具调用的执行结果
+type toolResult struct {
+	text string
+	err  error
+}
+
+// signOf 返回账单类型对应的金额符号
+func signOf(billType domain.BillType) string {
+	if billType == domain.BillTypeIncome {
+		return "+"
 	}
+	return "-"
+}
 
-	// 日期由服务器自动使用当前时间，不接收 AI 传入的日期参数
-	bt := domain.BillTypeExpense
-	if transType == "income" {
-		bt = domain.BillTypeIncome
+func (s *OpenAIService) handleRecordTransaction(args map[string]interface{}, svc *BillService) (string, error) {
+	input, ok := recordTransactionInput(args)
+	if !ok {
+		s.log.Error("Invalid transaction args: description=%s, amount=%.2f", input.Description, input.Amount)
+		return s.msg(msgInvalidTransaction), fmt.Errorf("invalid args")
+	}
+	if count := installmentCount(args); count > 0 {
+		return s.recordInstallments(input, count, svc)
 	}
 
-	bill, err := svc.CreateBill(description, amount, bt, nil, category, originalMsg)
+	bill, err := svc.CreateBill(input)
+	// 疑似重复或金额过大的账单由 executeToolCalls 统一暂存，等待用户确认
+	if needsConfirmation(err) {
+		return "", err
+	}
+	var invalidErr *domain.BillValidationError
+	if errors.As(err, &invalidErr) {
+		return s.validationText(invalidErr), nil
+	}
 	if err != nil {
 		s.log.Error("Failed to create bill: %v", err)
-		return "记账失败", err
+		return s.withRequestID(s.msg(msgRecordFailed), err), err
 	}
 
-	sign := "-"
-	if bill.Type == domain.BillTypeIncome {
-		sign = "+"
-	}
+	return s.recordSuccessText(bill), nil
+}
+
+// recordSuccessText 渲染单笔记账成功的回复
+func (s *OpenAIService) recordSuccessText(bill *domain.Bill) string {
+	sign := signOf(bill.Type)
 
 	// Include record_id in response for future updates
-	response := fmt.Sprintf("✅ 记账成功！\n📋 %s\n💰 %s¥%.2f\n🏷️ %s",
-		bill.Description, sign, bill.Amount, bill.Category)
+	response := s.msg(msgRecordSuccess, bill.Description, s.formatAmount(sign, bill.Amount), bill.Category)
+	if bill.CategoryFromHistory {
+		response += s.msg(msgCategoryFromHistory, bill.Category)
+	}
+	if bill.AlreadyRecorded {
+		response += s.msg(msgAlreadyRecorded)
+	}
+	if bill.Queued {
+		response += s.msg(msgQueued)
+	}
+	if bill.Account != "" {
+		response += s.msg(msgRecordAccount, bill.Account)
+	}
+	if len(bill.Tags) > 0 {
+		response += s.msg(msgRecordTags, formatTags(bill.Tags))
+	}
+	if bill.IsReimbursable() {
+		response += s.msg(msgRecordReimbursable)
+	}
+	if bill.HasUploadedAttachment() {
+		response += s.msg(msgAttachmentAttached)
+	}
+	if bill.DuplicateOf != "" {
+		response += s.msg(msgDuplicateFlagged, bill.DuplicateOf)
+	}
 	
 	if bill.RecordID != "" {
-		response += fmt.Sprintf("\n🆔 %s", bill.RecordID)
+		response += s.msg(msgRecordIDLine, bill.RecordID)
 	}
 
-	return response, nil
+	return response
 }
 
 func (s *OpenAIService) handleRenameUser(args map[string]interface{}, svc *RenameService) (string, error) {
 	name := getString(args, "name")
 	if name == "" {
 		s.log.Error("Empty name provided for rename_user")
-		return "名字不能为空", fmt.Errorf("empty name")
+		return s.msg(msgEmptyName), fmt.Errorf("empty name")
 	}
 
-	if err := svc.Rename(name); err != nil {
+	saved, err := svc.Rename(name)
+	var taken *domain.UserNameTakenError
+	if errors.As(err, &taken) {
+		s.log.Info("User name taken: name=%s, suggestion=%s", taken.Name, taken.Suggestion)
+		return s.msg(msgRenameTaken, taken.Name, taken.Suggestion, taken.Name), nil
+	}
+	if err != nil {
 		s.log.Error("Failed to rename user: %v", err)
-		return "设置失败", err
+		return s.msg(msgRenameFailed), err
 	}
 
-	return fmt.Sprintf("✅ 设置成功！从现在起，我将称呼您为：%s", name), nil
+	return s.msg(msgRenameSuccess, saved), nil
 }
 
 func (s *OpenAIService) handleUpdateTransaction(args map[string]interface{}, svc *BillService, currentInput string) (string, error) {
 	recordID := getString(args, "record_id")
 	if recordID == "" {
 		s.log.Error("Missing record_id in update_transaction args")
-		return "请提供记录ID", fmt.Errorf("record_id is required")
+		return s.msg(msgRecordIDRequired), fmt.Errorf("record_id is required")
 	}
 
 	// Extract optional update fields
-	var description *string
-	var amount *float64
-	var billType *domain.BillType
-	var category *string
-	var originalMsg *string
-
+	updates := make(map[string]interface{})
 	if desc := getString(args, "description"); desc != "" {
-		description = &desc
+		updates["description"] = desc
 	}
-	if amt := getFloat64(args, "amount"); amt > 0 {
-		amount = &amt
+	if amt := getAmount(args, "amount"); amt > 0 {
+		updates["amount"] = amt
 	}
 	if transType := getString(args, "type"); transType != "" {
 		bt := domain.BillTypeExpense
 		if transType == "income" {
 			bt = domain.BillTypeIncome
 		}
-		billType = &bt
+		updates["type"] = bt
 	}
 	if cat := getString(args, "category"); cat != "" {
-		category = &cat
+		updates["category"] = cat
+	}
+	if account := strings.TrimSpace(getString(args, "account")); account != "" {
+		updates["account"] = account
+	}
+	if tags := domain.NormalizeTags(getStringSlice(args, "tags")); len(tags) > 0 {
+		updates["tags"] = tags
+	}
+	if reimbursable, ok := getBool(args, "reimbursable"); ok {
+		updates["reimbursable"] = reimbursable
 	}
 	
 	// Get the original bill to retrieve the existing original_message
 	// We need to combine the original message with the current update instruction
-	originalBill, err := svc.billUseCase.GetBill(recordID)
+	originalBill, err := svc.billUseCase.GetBill(svc.ctx, recordID)
 	if err != nil {
 		s.log.Error("Failed to get original bill for update: %v", err)
 		// If we can't get the original bill, just use current input as original_message
 		if currentInput != "" {
-			originalMsg = &currentInput
+			updates["original_message"] = currentInput
 		}
 	} else {
 		// Combine original message with current update instruction
@@ -475,19 +1488,19 @@ func (s *OpenAIService) handleUpdateTransaction(args map[string]interface{}, svc
 			}
 		}
 		if combinedMsg != "" {
-			originalMsg = &combinedMsg
+			updates["original_message"] = combinedMsg
 		}
 	}
 
 	// Check if at least one field is being updated
-	if description == nil && amount == nil && billType == nil && category == nil && originalMsg == nil {
-		return "请提供至少一个要更新的字段", fmt.Errorf("no fields to update")
+	if len(updates) == 0 {
+		return s.msg(msgNoFieldsToUpdate), fmt.Errorf("no fields to update")
 	}
 
-	bill, err := svc.UpdateBill(recordID, description, amount, billType, category, originalMsg)
+	bill, err := svc.UpdateBill(recordID, updates)
 	if err != nil {
 		s.log.Error("Failed to update bill: %v", err)
-		return "更新失败", err
+		return s.msg(msgUpdateFailed), err
 	}
 
 	sign := "-"
@@ -495,11 +1508,10 @@ func (s *OpenAIService) handleUpdateTransaction(args map[string]interface{}, svc
 		sign = "+"
 	}
 
-	response := fmt.Sprintf("✅ 更新成功！\n📋 %s\n💰 %s¥%.2f\n🏷️ %s",
-		bill.Description, sign, bill.Amount, bill.Category)
+	response := s.msg(msgUpdateSuccess, bill.Description, s.formatAmount(sign, bill.Amount), bill.Category)
 	
 	if bill.RecordID != "" {
-		response += fmt.Sprintf("\n🆔 %s", bill.RecordID)
+		response += s.msg(msgRecordIDLine, bill.RecordID)
 	}
 
 	return response, nil
@@ -509,26 +1521,78 @@ func (s *OpenAIService) handleDeleteTransaction(args map[string]interface{}, svc
 	recordID := getString(args, "record_id")
 	if recordID == "" {
 		s.log.Error("Missing record_id in delete_transaction args")
-		return "请提供记录ID", fmt.Errorf("record_id is required")
+		return s.msg(msgRecordIDRequired), fmt.Errorf("record_id is required")
 	}
 
 	err := svc.DeleteBill(recordID)
 	if err != nil {
 		s.log.Error("Failed to delete bill: %v", err)
-		return "删除失败", err
+		return s.msg(msgDeleteFailed), err
+	}
+
+	return s.msg(msgDeleteSuccess, recordID), nil
+}
+
+func (s *OpenAIService) handleRestoreTransaction(args map[string]interface{}, svc *BillService) (string, error) {
+	recordID := getString(args, "record_id")
+	if recordID == "" {
+		s.log.Error("Missing record_id in restore_transaction args")
+		return s.msg(msgRecordIDRequired), fmt.Errorf("record_id is required")
+	}
+
+	err := svc.RestoreBill(recordID)
+	switch {
+	case errors.Is(err, domain.ErrSoftDeleteDisabled):
+		return s.msg(msgRestoreDisabled), nil
+	case errors.Is(err, domain.ErrBillNotFound):
+		s.log.Info("No restorable bill: record_id=%s, err=%v", recordID, err)
+		return s.msg(msgRestoreExpired, recordID), nil
+	case err != nil:
+		s.log.Error("Failed to restore bill: %v", err)
+		return s.msg(msgRestoreFailed), err
+	}
+
+	return s.msg(msgRestoreSuccess, recordID), nil
+}
+
+// handleSettleReimbursement 标记报销到账并记录报销收入
+func (s *OpenAIService) handleSettleReimbursement(args map[string]interface{}, svc *BillService) (string, error) {
+	recordIDs := getStringSlice(args, "record_ids")
+	if len(recordIDs) == 0 {
+		s.log.Error("Missing record_ids in settle_reimbursement args")
+		return s.msg(msgRecordIDRequired), fmt.Errorf("record_ids is required")
+	}
+
+	income, err := svc.SettleReimbursement(recordIDs, getAmount(args, "amount"))
+	switch {
+	case errors.Is(err, domain.ErrNotReimbursable):
+		s.log.Info("Settle reimbursement rejected: %v", err)
+		return s.msg(msgSettleNotReimbursable), nil
+	case errors.Is(err, domain.ErrAlreadyReimbursed):
+		s.log.Info("Settle reimbursement rejected: %v", err)
+		return s.msg(msgSettleAlreadyReimbursed), nil
+	case errors.Is(err, domain.ErrBillNotFound):
+		return s.msg(msgSettleNotFound), nil
+	case err != nil && income != nil:
+		// 收入已经记录，只有部分账单没能标记为已报销
+		s.log.Error("Failed to mark bills as reimbursed: %v", err)
+		return s.msg(msgSettlePartial, s.formatAmount("+", income.Amount), income.RecordID, err), err
+	case err != nil:
+		s.log.Error("Failed to settle reimbursement: %v", err)
+		return s.msg(msgSettleFailed), err
 	}
 
-	return fmt.Sprintf("✅ 删除成功！\n🆔 %s", recordID), nil
+	return s.msg(msgSettleSuccess, len(recordIDs), s.formatAmount("+", income.Amount), income.RecordID), nil
 }
 
-func (s *OpenAIService) handleQueryTransactions(args map[string]interface{}, svc *BillService) (string, error) {
+// parseToolTimeRange 解析工具参数中的 time_range_type/start_time/end_time，失败时同时返回给用户的回复
+func (s *OpenAIService) parseToolTimeRange(tool string, args map[string]interface{}) (time.Time, time.Time, string, error) {
 	timeRangeTypeStr := getString(args, "time_range_type")
 	if timeRangeTypeStr == "" {
-		s.log.Error("Missing time_range_type in query_transactions args")
-		return "请提供时间范围类型", fmt.Errorf("time_range_type is required")
+		s.log.Error("Missing time_range_type in %s args", tool)
+		return time.Time{}, time.Time{}, s.msg(msgTimeRangeRequired), fmt.Errorf("time_range_type is required")
 	}
 
-	// Parse time range
 	var startTime, endTime time.Time
 	var err error
 
@@ -536,38 +1600,101 @@ func (s *OpenAIService) handleQueryTransactions(args map[string]interface{}, svc
 	if timeRangeType == repository.TimeRangeCustom {
 		startTimeStr := getString(args, "start_time")
 		endTimeStr := getString(args, "end_time")
-		if startTimeStr == "" || endTimeStr == "" {
-			s.log.Error("Missing start_time or end_time for custom time range")
-			return "自定义时间范围需要提供开始时间和结束时间", fmt.Errorf("start_time and end_time are required for custom time range")
+		if startTimeStr == "" && endTimeStr == "" {
+			s.log.Error("Missing both start_time and end_time for custom time range")
+			return time.Time{}, time.Time{}, s.msg(msgCustomRangeRequired), fmt.Errorf("start_time or end_time is required for custom time range")
 		}
-		startTime, endTime, err = repository.ParseTimeRange(timeRangeType, startTimeStr, endTimeStr)
+		startTime, endTime, err = repository.ParseTimeRangeIn(s.timeLocation(), timeRangeType, startTimeStr, endTimeStr)
 	} else {
-		startTime, endTime, err = repository.ParseTimeRange(timeRangeType, "", "")
+		startTime, endTime, err = repository.ParseTimeRangeIn(s.timeLocation(), timeRangeType, "", "")
 	}
 
 	if err != nil {
 		s.log.Error("Failed to parse time range: %v", err)
-		return "时间范围解析失败", err
+		return time.Time{}, time.Time{}, s.msg(msgTimeRangeFailed), err
+	}
+	return startTime, endTime, "", nil
+}
+
+// handleExportTransactions 把时间范围内的账单导出为 CSV，作为附件回复到当前话题
+// 先写入临时文件再上传，导出大量记录时不占用过多内存
+func (s *OpenAIService) handleExportTransactions(args map[string]interface{}, svc *BillService) (string, error) {
+	startTime, endTime, reply, err := s.parseToolTimeRange("export_transactions", args)
+	if err != nil {
+		return reply, err
+	}
+
+	replyFile := domain.FileReplierFromContext(svc.ctx)
+	if replyFile == nil {
+		return s.msg(msgExportUnsupported), nil
+	}
+
+	tmp, err := os.CreateTemp("", "ledgerbot-export-*.csv")
+	if err != nil {
+		s.log.Error("Failed to create export file: %v", err)
+		return s.msg(msgExportFailed), err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	count, err := svc.ExportTransactions(startTime, endTime, tmp)
+	if err != nil {
+		s.log.Error("Failed to export transactions: %v", err)
+		return s.msg(msgExportFailed), err
+	}
+	if count == 0 {
+		return s.msg(msgExportEmpty), nil
+	}
+
+	from, to := startTime.Format("2006-01-02"), endTime.Format("2006-01-02")
+	fileName := fmt.Sprintf("账单_%s_%s.csv", from, to)
+	if err := replyFile(svc.ctx, fileName, tmp); err != nil {
+		s.log.Error("Failed to send export file: %v", err)
+		return s.msg(msgExportFailed), err
+	}
+
+	s.log.Info("Exported transactions to file: user=%s, file=%s, rows=%d", svc.userName, fileName, count)
+	return s.msg(msgExportSuccess, count, from, to), nil
+}
+
+func (s *OpenAIService) handleQueryTransactions(args map[string]interface{}, svc *BillService, conversationKey string) (string, error) {
+	timeRangeTypeStr := getString(args, "time_range_type")
+	startTime, endTime, reply, err := s.parseToolTimeRange("query_transactions", args)
+	if err != nil {
+		return reply, err
 	}
 
-	// Get top_n (default 5)
-	topN := 5
+	// Get top_n (default from the user's preferences)
+	topN := s.topN
 	if topNVal, ok := args["top_n"]; ok {
 		if topNFloat, ok := topNVal.(float64); ok {
 			topN = int(topNFloat)
 		}
 	}
 
-	s.log.Debug("QueryTransactions params: time_range_type=%s, start_time=%s, end_time=%s, top_n=%d, user_name=%s",
-		timeRangeTypeStr, startTime.Format("2006-01-02 15:04:05"), endTime.Format("2006-01-02 15:04:05"), topN, svc.userName)
+
+	// Get group_by (default none)
+	groupBy := getString(args, "group_by")
+	if groupBy == "" {
+		groupBy = GroupByNone
+	}
 
 	// Query transactions
-	bills, totalIncome, totalExpense, err := svc.QueryTransactions(startTime, endTime, topN)
+	// 查询完整结果集，分组汇总和 Top N 截断都在渲染时完成
+	bills, totalIncome, totalExpense, err := svc.QueryTransactions(startTime, endTime, 0)
 	if err != nil {
 		s.log.Error("Failed to query transactions: %v", err)
-		return "查询失败", err
+		return s.msg(msgQueryFailed), err
+	}
+	// 按账户等条件过滤时，合计只统计过滤后的账单
+	filter := queryFilterFromArgs(args)
+	if !filter.empty() {
+		bills = filter.apply(bills)
+		totalIncome, totalExpense = sumBills(bills)
 	}
 
+	s.log.Debug("QueryTransactions params: time_range_type=%s, start_time=%s, end_time=%s, top_n=%d, group_by=%s, user_name=%s",
+		timeRangeTypeStr, startTime.Format("2006-01-02 15:04:05"), endTime.Format("2006-01-02 15:04:05"), topN, groupBy, svc.userName)
 	s.log.Debug("QueryTransactions result: bills_count=%d, total_income=%.2f, total_expense=%.2f", len(bills), totalIncome, totalExpense)
 	for i, bill := range bills {
 		s.log.Debug("  Bill[%d]: record_id=%s, description=%s, amount=%.2f, type=%s, category=%s, date=%s, user_name=%s",
@@ -576,43 +1703,72 @@ func (s *OpenAIService) handleQueryTransactions(args map[string]interface{}, svc
 
 	// Format response
 	netAmount := totalIncome - totalExpense
-	response := fmt.Sprintf("📊 查询结果（%s 至 %s）\n\n", 
-		startTime.Format("2006-01-02"), endTime.Format("2006-01-02"))
-	response += fmt.Sprintf("💰 总收入: ¥%.2f\n", totalIncome)
-	response += fmt.Sprintf("💸 总支出: ¥%.2f\n", totalExpense)
-	response += fmt.Sprintf("📈 净收支: ¥%.2f\n\n", netAmount)
-
-	if len(bills) > 0 {
-		response += fmt.Sprintf("🔝 Top %d 交易记录:\n", len(bills))
-		for i, bill := range bills {
-			sign := "-"
-			if bill.Type == domain.BillTypeIncome {
-				sign = "+"
-			}
-			response += fmt.Sprintf("%d. %s %s¥%.2f [%s]\n", 
-				i+1, bill.Description, sign, bill.Amount, bill.Category)
-			if bill.RecordID != "" {
-				response += fmt.Sprintf("   🆔 %s\n", bill.RecordID)
-			}
+	r := &replyBuilder{}
+	r.writeTotal(s.msg(msgQueryHeader, startTime.Format("2006-01-02"), endTime.Format("2006-01-02")))
+	if filter.Account != "" {
+		r.writeTotal(s.msg(msgQueryAccountFilter, filter.Account))
+	}
+	if filter.Tag != "" {
+		r.writeTotal(s.msg(msgQueryTagFilter, formatTags([]string{filter.Tag})))
+	}
+	if filter.PendingReimbursement {
+		r.writeTotal(s.msg(msgQueryPendingReimbursement))
+	}
+	r.writeTotal(s.msg(msgQueryIncome, s.formatAmount("", totalIncome)))
+	r.writeTotal(s.msg(msgQueryExpense, s.formatAmount("", totalExpense)))
+	s.renderReimbursableTotal(r, bills)
+	r.writeTotal(s.msg(msgQueryNet, s.formatAmount("", netAmount)))
+	if filter.Account == "" {
+		s.renderAccountTotals(r, bills)
+	}
+
+	if len(bills) == 0 {
+		r.writeTotal(s.msg(msgQueryEmpty))
+		return r.String(), nil
+	}
+
+	// shown 为按 order 排列已显示的明细条数，“更多”从其后继续；按分类汇总时不列明细
+	order, shown := queryOrderAmount, 0
+	switch groupBy {
+	case GroupByDay:
+		order, shown = queryOrderDate, s.renderQueryByDay(r, bills)
+	case GroupByCategory:
+		s.renderQueryByCategory(r, bills)
+	default:
+		shown = s.renderQueryTop(r, bills, topN)
+	}
+
+	if r.truncated {
+		r.writeTotal(s.msg(msgQueryTruncated))
+	}
+
+	// 还有未显示的明细时保存查询游标，用户回复“更多”即可按相同顺序查看下一页
+	if groupBy != GroupByCategory && shown < len(bills) && conversationKey != "" {
+		if err := s.storeQueryCursor(conversationKey, startTime, endTime, filter, order, shown); err != nil {
+			s.log.Error("Failed to store query cursor: key=%s, err=%v", conversationKey, err)
+		} else {
+			r.writeTotal(s.msg(msgQueryMoreHint))
 		}
-	} else {
-		response += "📝 暂无交易记录\n"
 	}
 
-	return response, nil
+	return r.String(), nil
 }
 
 // BillService handles bill operations inside AI service
 type BillService struct {
+	ctx         context.Context // 当前消息的处理上下文，用于取消和超时
 	billUseCase domain.BillUseCase
 	userID      string
 	userName    string
 	originalMsg string
+	created     []*domain.Bill // 本次处理中新建的账单，用于渲染卡片回复
 }
 
 // NewBillService creates bill service for AI usage
-func NewBillService(billUseCase domain.BillUseCase, userID string, userName string, originalMsg string) domain.BillServiceInterface {
+func NewBillService(ctx context.Context, billUseCase domain.BillUseCase, userID string, userName string, originalMsg string) domain.BillServiceInterface {
 	return &BillService{
+		// 通过 AI 执行的账单操作记入该用户的操作日志，用于撤销
+		ctx:         domain.WithOperator(ctx, userName),
 		billUseCase: billUseCase,
 		userID:      userID,
 		userName:    userName,
@@ -621,37 +1777,50 @@ func NewBillService(billUseCase domain.BillUseCase, userID string, userName stri
 }
 
 // CreateBill records new bill
-func (s *BillService) CreateBill(description string, amount float64, billType domain.BillType, date *time.Time, category string, originalMsg string) (*domain.Bill, error) {
+func (s *BillService) CreateBill(input domain.NewBillInput) (*domain.Bill, error) {
 	// Use originalMsg from AI toolcall parameter, fallback to stored originalMsg if not provided
-	if originalMsg == "" {
-		originalMsg = s.originalMsg
+	if input.OriginalMsg == "" {
+		input.OriginalMsg = s.originalMsg
+	}
+	bill, err := s.billUseCase.CreateBill(s.ctx, s.userName, s.userID, input)
+	if err != nil {
+		return nil, err
 	}
-	return s.billUseCase.CreateBill(s.userName, s.userID, originalMsg, description, amount, billType, date, &category)
+	s.created = append(s.created, bill)
+	return bill, nil
 }
 
-// UpdateBill updates an existing bill by record_id
-// Directly updates without querying - only updates fields that are provided
-func (s *BillService) UpdateBill(recordID string, description *string, amount *float64, billType *domain.BillType, category *string, originalMsg *string) (*domain.Bill, error) {
-	// Build updates map with only the fields that are provided
-	updates := make(map[string]interface{})
-	if description != nil {
-		updates["description"] = *description
-	}
-	if amount != nil {
-		updates["amount"] = *amount
-	}
-	if billType != nil {
-		updates["type"] = *billType
+// CreateBills records several bills in one batch
+// On partial failure the error is a *domain.BatchCreateError and only successful bills have RecordID set
+func (s *BillService) CreateBills(inputs []domain.NewBillInput) ([]*domain.Bill, error) {
+	for i := range inputs {
+		if inputs[i].OriginalMsg == "" {
+			inputs[i].OriginalMsg = s.originalMsg
+		}
 	}
-	if category != nil {
-		updates["category"] = *category
+	bills, err := s.billUseCase.CreateBills(s.ctx, s.userName, s.userID, inputs)
+	for _, bill := range bills {
+		if bill.RecordID != "" {
+			s.created = append(s.created, bill)
+		}
 	}
-	if originalMsg != nil {
-		updates["original_message"] = *originalMsg
+	return bills, err
+}
+
+// CreatedBills returns the bills created through the given bill service during this request
+func CreatedBills(billService domain.BillServiceInterface) []*domain.Bill {
+	svc, ok := billService.(*BillService)
+	if !ok {
+		return nil
 	}
-	
+	return svc.created
+}
+
+// UpdateBill updates an existing bill by record_id
+// Directly updates without querying - only updates fields that are provided in updates
+func (s *BillService) UpdateBill(recordID string, updates map[string]interface{}) (*domain.Bill, error) {
 	// Use case UpdateBill will detect record_id (starts with "rec") and update directly without querying
-	updatedBill, err := s.billUseCase.UpdateBill(recordID, updates)
+	updatedBill, err := s.billUseCase.UpdateBill(s.ctx, recordID, updates)
 	if err != nil {
 		return nil, err
 	}
@@ -664,29 +1833,185 @@ func (s *BillService) UpdateBill(recordID string, description *string, amount *f
 
 // DeleteBill deletes an existing bill by record_id
 func (s *BillService) DeleteBill(recordID string) error {
-	return s.billUseCase.DeleteBill(recordID)
+	return s.billUseCase.DeleteBill(s.ctx, recordID)
+}
+
+// RestoreBill restores a soft-deleted bill by record_id
+func (s *BillService) RestoreBill(recordID string) error {
+	return s.billUseCase.RestoreBill(s.ctx, recordID)
+}
+
+// CreateInstallmentBill records the first installment and saves the installment plan
+func (s *BillService) CreateInstallmentBill(input domain.NewBillInput, count int) (*domain.Bill, *domain.InstallmentPlan, error) {
+	if input.OriginalMsg == "" {
+		input.OriginalMsg = s.originalMsg
+	}
+	bill, plan, err := s.billUseCase.CreateInstallmentBill(s.ctx, s.userName, s.userID, input, count)
+	if bill != nil {
+		s.created = append(s.created, bill)
+	}
+	return bill, plan, err
+}
+
+// ListInstallmentPlans lists the user's ongoing installment plans
+func (s *BillService) ListInstallmentPlans() ([]*domain.InstallmentPlan, error) {
+	return s.billUseCase.ListInstallmentPlans(s.ctx, s.userName)
+}
+
+// CancelInstallmentPlan cancels one of the user's installment plans
+func (s *BillService) CancelInstallmentPlan(planID string, deleteFuture bool) (*domain.InstallmentPlan, int, error) {
+	return s.billUseCase.CancelInstallmentPlan(s.ctx, s.userName, planID, deleteFuture)
+}
+
+// CreateRecurringRule saves a recurring rule for the user
+func (s *BillService) CreateRecurringRule(rule domain.RecurringRule) (*domain.RecurringRule, error) {
+	return s.billUseCase.CreateRecurringRule(s.ctx, s.userName, s.userID, rule)
+}
+
+// ListRecurringRules lists the user's recurring rules
+func (s *BillService) ListRecurringRules() ([]*domain.RecurringRule, error) {
+	return s.billUseCase.ListRecurringRules(s.ctx, s.userName)
+}
+
+// DeleteRecurringRule deletes one of the user's recurring rules
+func (s *BillService) DeleteRecurringRule(ruleID string) (*domain.RecurringRule, error) {
+	return s.billUseCase.DeleteRecurringRule(s.ctx, s.userName, ruleID)
+}
+
+// RecordLoan records a loan for the user
+func (s *BillService) RecordLoan(input domain.LoanInput) (*domain.Bill, error) {
+	if input.OriginalMsg == "" {
+		input.OriginalMsg = s.originalMsg
+	}
+	bill, err := s.billUseCase.RecordLoan(s.ctx, s.userName, s.userID, input)
+	if bill != nil {
+		s.created = append(s.created, bill)
+	}
+	return bill, err
+}
+
+// LoanBalances lists the user's outstanding loan balances
+func (s *BillService) LoanBalances() ([]*domain.LoanBalance, error) {
+	return s.billUseCase.LoanBalances(s.ctx, s.userName)
+}
+
+// SettleLoan records a repayment with a counterparty
+func (s *BillService) SettleLoan(counterparty string, amount float64) (*domain.Bill, *domain.LoanBalance, error) {
+	bill, balance, err := s.billUseCase.SettleLoan(s.ctx, s.userName, s.userID, counterparty, amount, s.originalMsg)
+	if bill != nil {
+		s.created = append(s.created, bill)
+	}
+	return bill, balance, err
+}
+
+// UndoLast reverts the user's latest bill operation
+func (s *BillService) UndoLast() (*domain.UndoResult, error) {
+	return s.billUseCase.UndoLast(s.ctx, s.userName)
+}
+
+// SaveTemplate saves a transaction as a template
+func (s *BillService) SaveTemplate(tpl domain.BillTemplate) (*domain.BillTemplate, *domain.BillTemplate, error) {
+	return s.billUseCase.SaveTemplate(s.ctx, s.userName, tpl)
+}
+
+// ListTemplates lists the user's templates
+func (s *BillService) ListTemplates() ([]*domain.BillTemplate, error) {
+	return s.billUseCase.ListTemplates(s.ctx, s.userName)
+}
+
+// DeleteTemplate deletes one of the user's templates
+func (s *BillService) DeleteTemplate(name string) (*domain.BillTemplate, error) {
+	return s.billUseCase.DeleteTemplate(s.ctx, s.userName, name)
+}
+
+// UseTemplate records a bill from a template
+func (s *BillService) UseTemplate(name string, amount float64) (*domain.Bill, *domain.BillTemplate, error) {
+	bill, tpl, err := s.billUseCase.UseTemplate(s.ctx, s.userName, s.userID, name, amount, s.originalMsg)
+	if bill != nil {
+		s.created = append(s.created, bill)
+	}
+	return bill, tpl, err
+}
+
+// SpendingTrends computes expense statistics within a time range
+func (s *BillService) SpendingTrends(startTime, endTime time.Time) (*domain.SpendingTrend, error) {
+	return s.billUseCase.SpendingTrends(s.ctx, s.userName, startTime, endTime)
+}
+
+// ForecastMonth forecasts this month's expense at the current pace
+func (s *BillService) ForecastMonth() (*domain.MonthForecast, error) {
+	return s.billUseCase.ForecastMonth(s.ctx, s.userName, time.Now())
+}
+
+// MemberBreakdown sums each member's income and expense in the shared ledger
+func (s *BillService) MemberBreakdown(startTime, endTime time.Time) ([]*domain.MemberTotal, error) {
+	return s.billUseCase.MemberBreakdown(s.ctx, startTime, endTime)
+}
+
+// SettleUp splits the shared ledger's expenses among its members
+func (s *BillService) SettleUp(startTime, endTime time.Time, weights map[string]float64) (*domain.Settlement, error) {
+	return s.billUseCase.SettleUp(s.ctx, startTime, endTime, weights)
+}
+
+// SplitBill records the user's share of a split expense, and in a shared ledger optionally the other members' shares
+func (s *BillService) SplitBill(input domain.NewBillInput, split *domain.Split, recordOthers bool) ([]*domain.Bill, error) {
+	if input.OriginalMsg == "" {
+		input.OriginalMsg = s.originalMsg
+	}
+	bills, err := s.billUseCase.SplitBill(s.ctx, s.userName, s.userID, input, split, recordOthers)
+	s.created = append(s.created, bills...)
+	return bills, err
+}
+
+// DeleteBills deletes the given bills in one batch
+func (s *BillService) DeleteBills(bills []*domain.Bill) error {
+	return s.billUseCase.DeleteBills(s.ctx, bills)
+}
+
+// SettleReimbursement marks reimbursable bills as reimbursed and records the income
+func (s *BillService) SettleReimbursement(recordIDs []string, amount float64) (*domain.Bill, error) {
+	bill, err := s.billUseCase.SettleReimbursement(s.ctx, s.userName, s.userID, recordIDs, amount)
+	if bill != nil {
+		s.created = append(s.created, bill)
+	}
+	return bill, err
 }
 
 // QueryTransactions queries transactions within a time range
 func (s *BillService) QueryTransactions(startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
-	return s.billUseCase.QueryTransactions(s.userName, startTime, endTime, topN)
+	return s.billUseCase.QueryTransactions(s.ctx, s.userName, startTime, endTime, topN)
+}
+
+// QueryTransactionsPage queries one page of transactions ordered by date descending
+func (s *BillService) QueryTransactionsPage(startTime, endTime time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	return s.billUseCase.QueryTransactionsPage(s.ctx, s.userName, startTime, endTime, pageToken, pageSize)
+}
+
+// ExportTransactions writes the transactions within a time range to w as CSV
+func (s *BillService) ExportTransactions(startTime, endTime time.Time, w io.Writer) (int, error) {
+	return s.billUseCase.ExportTransactions(s.ctx, s.userName, startTime, endTime, w)
+}
+
+// ExportUserData writes the user's data archive to w
+func (s *BillService) ExportUserData(w io.Writer) (int, error) {
+	return s.billUseCase.ExportUserData(s.ctx, s.userID, w)
 }
 
 // RenameService handles rename
 type RenameService struct {
 	userNameGet func() (string, error)
-	userNameSet func(string) error
+	userNameSet func(string) (string, error)
 }
 
-// NewRenameService creates rename service
-func NewRenameService(setName func(string) error) domain.RenameServiceInterface {
+// NewRenameService creates rename service; setName returns the name actually saved
+func NewRenameService(setName func(string) (string, error)) domain.RenameServiceInterface {
 	return &RenameService{
 		userNameSet: setName,
 	}
 }
 
 // Rename updates user name
-func (s *RenameService) Rename(name string) error {
+func (s *RenameService) Rename(name string) (string, error) {
 	return s.userNameSet(name)
 }
 
@@ -698,6 +2023,35 @@ func getString(m map[string]interface{}, key string) string {
 	return v
 }
 
+// getBool 读取布尔参数，参数不存在时 ok 为 false
+func getBool(m map[string]interface{}, key string) (value bool, ok bool) {
+	value, ok = m[key].(bool)
+	return value, ok
+}
+
+// getStringSlice 读取字符串数组参数，也兼容 AI 传入单个字符串的情况
+func getStringSlice(m map[string]interface{}, key string) []string {
+	switch v := m[key].(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	case string:
+		return strings.Fields(v)
+	default:
+		return nil
+	}
+}
+
+// getAmount 读取金额参数并取整到分，AI 计算出的 19.999999 之类的金额按 20 处理
+func getAmount(m map[string]interface{}, key string) float64 {
+	return domain.RoundAmount(getFloat64(m, key))
+}
+
 func getFloat64(m map[string]interface{}, key string) float64 {
 	switch v := m[key].(type) {
 	case float64:
@@ -708,6 +2062,10 @@ func getFloat64(m map[string]interface{}, key string) float64 {
 		return float64(v)
 	case int64:
 		return float64(v)
+	case string:
+		// AI 偶尔把金额作为字符串传入，如 "３５元"、"1,200"
+		amount, _ := domain.ParseAmount(v)
+		return amount
 	default:
 		return 0
 	}
@@ -0,0 +1,101 @@
+package ai
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// defaultQueryTopN 用户未设置时查询默认列出的明细条数
+const defaultQueryTopN = 5
+
+// preferenceLabels 各偏好的显示名称
+var preferenceLabels = map[string]messageKey{
+	domain.PreferenceCurrency: msgPreferenceCurrency,
+	domain.PreferenceTimezone: msgPreferenceTimezone,
+	domain.PreferenceLanguage: msgPreferenceLanguage,
+	domain.PreferenceTopN:     msgPreferenceTopN,
+}
+
+// preferenceHints 偏好取值无效时的提示
+var preferenceHints = map[string]messageKey{
+	domain.PreferenceCurrency: msgPreferenceCurrencyHint,
+	domain.PreferenceTimezone: msgPreferenceTimezoneHint,
+	domain.PreferenceLanguage: msgPreferenceLanguageHint,
+	domain.PreferenceTopN:     msgPreferenceTopNHint,
+}
+
+// applyPreferences 按用户偏好设置回复语言、货币符号、时区和默认明细条数，未设置的项使用全局配置
+func (s *OpenAIService) applyPreferences(prefs domain.UserPreferences) {
+	s.language = normalizeLanguage(s.config.Language)
+	if prefs.Language != "" {
+		s.language = normalizeLanguage(prefs.Language)
+	}
+	s.currency = s.config.CurrencySymbol
+	if prefs.Currency != "" {
+		s.currency = prefs.Currency
+	}
+	s.topN = defaultQueryTopN
+	if prefs.TopN > 0 {
+		s.topN = prefs.TopN
+	}
+	s.location = prefs.Location(s.defaultLocation)
+}
+
+// timeLocation 日期和时间范围使用的时区：用户设置的时区，未设置时为配置的时区
+func (s *OpenAIService) timeLocation() *time.Location {
+	return s.location
+}
+
+// handleSetPreference 修改发消息用户的单项偏好，value 为空或“默认”时恢复全局配置
+func (s *OpenAIService) handleSetPreference(args map[string]interface{}, svc *BillService) (string, error) {
+	save := domain.PreferenceSaverFromContext(svc.ctx)
+	if save == nil {
+		return s.msg(msgPreferenceUnsupported), nil
+	}
+
+	name, value := getString(args, "name"), getString(args, "value")
+	key, ok := domain.ParsePreferenceName(name)
+	if !ok {
+		return s.msg(msgPreferenceUnknown, name), nil
+	}
+	prefs := domain.PreferencesFromContext(svc.ctx)
+	if err := prefs.Set(key, value); err != nil {
+		s.log.Warn("Invalid preference: user=%s, name=%s, value=%s, err=%v", svc.userName, key, value, err)
+		return s.msg(msgPreferenceInvalid, s.msg(preferenceLabels[key]), value, s.msg(preferenceHints[key])), nil
+	}
+
+	if err := save(prefs); err != nil {
+		s.log.Error("Failed to save preferences: user=%s, err=%v", svc.userName, err)
+		return s.msg(msgPreferenceFailed), err
+	}
+	s.log.Info("Preference updated: user=%s, name=%s, value=%s", svc.userName, key, value)
+
+	// 同一条消息里的后续操作和本条回复立即使用新的偏好
+	svc.ctx = domain.WithPreferences(svc.ctx, prefs, save)
+	s.applyPreferences(prefs)
+
+	current := preferenceValue(prefs, key)
+	if current == "" {
+		return s.msg(msgPreferenceReset, s.msg(preferenceLabels[key])), nil
+	}
+	return s.msg(msgPreferenceSaved, s.msg(preferenceLabels[key]), current), nil
+}
+
+// preferenceValue 返回偏好当前的取值，未设置时为空
+func preferenceValue(prefs domain.UserPreferences, key string) string {
+	switch key {
+	case domain.PreferenceCurrency:
+		return prefs.Currency
+	case domain.PreferenceTimezone:
+		return prefs.Timezone
+	case domain.PreferenceLanguage:
+		return prefs.Language
+	case domain.PreferenceTopN:
+		if prefs.TopN > 0 {
+			return strconv.Itoa(prefs.TopN)
+		}
+	}
+	return ""
+}
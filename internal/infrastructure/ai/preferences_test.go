@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// “今天”按配置的时区计算，用户设置了时区时按用户的时区，与运行测试的机器时区无关
+func TestQueryTimeRangeLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		timezone string
+		want     *time.Location
+	}{
+		{timezone: "", want: testLocation},
+		{timezone: "America/New_York", want: newYork},
+	}
+	for _, tt := range tests {
+		svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+			return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+		})
+		bills := &fakeBillUseCase{}
+		ctx := domain.WithPreferences(context.Background(), domain.UserPreferences{Timezone: tt.timezone}, nil)
+		billService := NewBillService(ctx, bills, "u1", "张三", "").(*BillService)
+		if _, err := svc.forRequest(billService).handleQueryTransactions(map[string]interface{}{"time_range_type": "today"}, billService, testCursorKey); err != nil {
+			t.Fatal(err)
+		}
+
+		ranges := bills.queriedRanges()
+		if len(ranges) != 1 {
+			t.Fatalf("timezone %q: %d queries, want 1", tt.timezone, len(ranges))
+		}
+		now := time.Now().In(tt.want)
+		want := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tt.want)
+		if start := ranges[0][0]; !start.Equal(want) {
+			t.Errorf("timezone %q: today starts at %s, want %s", tt.timezone, start, want)
+		}
+	}
+}
+
+// 未设置的偏好使用全局配置，设置的偏好覆盖全局配置
+func TestApplyPreferences(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{}
+	})
+	tests := []struct {
+		prefs    domain.UserPreferences
+		language string
+		currency string
+		topN     int
+		location string
+	}{
+		{domain.UserPreferences{}, LanguageZH, "¥", defaultQueryTopN, testLocation.String()},
+		{domain.UserPreferences{Currency: "$", Timezone: "America/New_York", Language: "en", TopN: 3}, LanguageEN, "$", 3, "America/New_York"},
+		{domain.UserPreferences{TopN: 10}, LanguageZH, "¥", 10, testLocation.String()},
+	}
+	for _, tt := range tests {
+		billService := NewBillService(domain.WithPreferences(context.Background(), tt.prefs, nil), &fakeBillUseCase{}, "u1", "张三", "").(*BillService)
+		scoped := svc.forRequest(billService)
+		if scoped.language != tt.language || scoped.currency != tt.currency || scoped.topN != tt.topN || scoped.timeLocation().String() != tt.location {
+			t.Errorf("preferences %+v: got %s/%s/%d/%s, want %s/%s/%d/%s", tt.prefs,
+				scoped.language, scoped.currency, scoped.topN, scoped.timeLocation(), tt.language, tt.currency, tt.topN, tt.location)
+		}
+	}
+	// 按请求应用偏好，不影响共享的服务
+	if svc.currency != "¥" || svc.language != LanguageZH {
+		t.Errorf("shared service changed to %s/%s", svc.language, svc.currency)
+	}
+}
+
+func TestHandleSetPreference(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{}
+	})
+	var saved []domain.UserPreferences
+	save := func(prefs domain.UserPreferences) error {
+		saved = append(saved, prefs)
+		return nil
+	}
+	ctx := domain.WithPreferences(context.Background(), domain.UserPreferences{TopN: 3}, save)
+	billService := NewBillService(ctx, &fakeBillUseCase{}, "u1", "张三", "").(*BillService)
+	scoped := svc.forRequest(billService)
+
+	reply, err := scoped.handleSetPreference(map[string]interface{}{"name": "货币", "value": "$"}, billService)
+	if err != nil || reply != "⚙️ 已将货币符号设置为 $" {
+		t.Errorf("set currency = %q, %v", reply, err)
+	}
+	// 保存时保留其他偏好，同一条消息的后续操作立即使用新的货币符号
+	if len(saved) != 1 || saved[0] != (domain.UserPreferences{Currency: "$", TopN: 3}) {
+		t.Errorf("saved = %+v, want currency $ and top_n 3", saved)
+	}
+	if scoped.currency != "$" || domain.PreferencesFromContext(billService.ctx).Currency != "$" {
+		t.Errorf("currency after set = %q, want $", scoped.currency)
+	}
+
+	// 切换语言后本条回复就使用新的语言
+	if reply, _ := scoped.handleSetPreference(map[string]interface{}{"name": "language", "value": "英文"}, billService); reply != "⚙️ Reply language set to en" {
+		t.Errorf("set language = %q", reply)
+	}
+	if reply, _ := scoped.handleSetPreference(map[string]interface{}{"name": "top_n", "value": "default"}, billService); reply != "⚙️ Query list size reset to the default" {
+		t.Errorf("reset top_n = %q", reply)
+	}
+	if scoped.topN != defaultQueryTopN {
+		t.Errorf("top_n after reset = %d, want %d", scoped.topN, defaultQueryTopN)
+	}
+	if last := saved[len(saved)-1]; last != (domain.UserPreferences{Currency: "$", Language: "en"}) {
+		t.Errorf("last saved = %+v", last)
+	}
+
+	// 无效取值和未知设置项不保存
+	count := len(saved)
+	scoped.handleSetPreference(map[string]interface{}{"name": "timezone", "value": "Mars/Olympus"}, billService)
+	scoped.handleSetPreference(map[string]interface{}{"name": "颜色", "value": "红"}, billService)
+	if len(saved) != count {
+		t.Errorf("invalid preferences were saved: %+v", saved[count:])
+	}
+}
+
+func TestHandleSetPreferenceUnavailable(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{}
+	})
+	// 没有保存方法（如无法识别用户）时不能修改设置
+	billService := NewBillService(context.Background(), &fakeBillUseCase{}, "u1", "张三", "").(*BillService)
+	if reply, err := svc.forRequest(billService).handleSetPreference(map[string]interface{}{"name": "currency", "value": "$"}, billService); err != nil || reply != "⚠️ 当前无法修改个人设置" {
+		t.Errorf("set without a saver = %q, %v", reply, err)
+	}
+
+	failure := errors.New("disk full")
+	ctx := domain.WithPreferences(context.Background(), domain.UserPreferences{}, func(domain.UserPreferences) error { return failure })
+	billService = NewBillService(ctx, &fakeBillUseCase{}, "u1", "张三", "").(*BillService)
+	scoped := svc.forRequest(billService)
+	if reply, err := scoped.handleSetPreference(map[string]interface{}{"name": "currency", "value": "$"}, billService); !errors.Is(err, failure) || reply != "❌ 保存设置失败，请稍后重试" {
+		t.Errorf("failed save = %q, %v", reply, err)
+	}
+	// 保存失败时不使用新的偏好
+	if scoped.currency != "¥" {
+		t.Errorf("currency after a failed save = %q, want ¥", scoped.currency)
+	}
+}
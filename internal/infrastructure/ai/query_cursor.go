@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+const (
+	// queryCursorTTL 查询翻页游标的有效期
+	queryCursorTTL = 10 * time.Minute
+	// queryMorePageSize “更多”每页显示的记录数
+	queryMorePageSize = 10
+)
+
+// 翻页时记录的排列顺序，与第一页回复的顺序一致
+const (
+	queryOrderAmount = "amount" // 按金额降序，对应 Top N 列表
+	queryOrderDate   = "date"   // 按时间升序，对应按天分组的明细
+)
+
+// queryCursor 查询翻页游标：时间范围 + 过滤条件 + 排列顺序 + 已显示的记录数
+type queryCursor struct {
+	StartTime time.Time   `json:"start_time"`
+	EndTime   time.Time   `json:"end_time"`
+	Order     string      `json:"order"`
+	Offset    int         `json:"offset"` // 已显示的记录数，下一页从这里开始
+	Page      int         `json:"page"`   // 已显示的页数，第一次查询的回复为第 1 页
+	Filter    queryFilter `json:"filter"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+var moreReplies = []string{"更多", "下一页", "more", "next"}
+
+// IsMoreReply 判断消息是否为查看下一页的回复
+func IsMoreReply(text string) bool {
+	normalized := strings.ToLower(strings.Trim(strings.TrimSpace(text), "。.！!～~"))
+	for _, r := range moreReplies {
+		if normalized == r {
+			return true
+		}
+	}
+	return false
+}
+
+// storeQueryCursor 保存新查询的翻页游标，shown 为第一页按 order 排列显示的记录数
+func (s *OpenAIService) storeQueryCursor(conversationKey string, startTime, endTime time.Time, filter queryFilter, order string, shown int) error {
+	return s.saveQueryCursor(conversationKey, &queryCursor{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Order:     order,
+		Offset:    shown,
+		Page:      1,
+		Filter:    filter,
+	})
+}
+
+// saveQueryCursor 保存游标并刷新有效期
+// 缓存保留两倍有效期，以便超时后仍能提示用户查询已过期
+func (s *OpenAIService) saveQueryCursor(conversationKey string, cursor *queryCursor) error {
+	cursor.ExpiresAt = time.Now().Add(queryCursorTTL)
+	return s.cursors.Set(conversationKey, cursor, 2*queryCursorTTL)
+}
+
+// MoreResults 根据保存的游标渲染下一页明细，顺序与第一页相同，从已显示的记录之后开始。
+// 每次重新查询整个时间范围（按金额排序需要全部记录，短时间内的重复查询由查询缓存承担）
+func (s *OpenAIService) MoreResults(conversationKey string, billService domain.BillServiceInterface) (string, bool, error) {
+	s = s.forRequest(billService)
+
+	var cursor queryCursor
+	if err := s.cursors.Get(conversationKey, &cursor); err != nil {
+		return "", false, nil
+	}
+
+	if time.Now().After(cursor.ExpiresAt) {
+		_ = s.cursors.Delete(conversationKey)
+		s.log.Info("Query cursor expired: key=%s, expired_at=%s", conversationKey, cursor.ExpiresAt.Format(time.RFC3339))
+		return s.msg(msgQueryCursorExpired), true, nil
+	}
+
+	bills, _, _, err := billService.QueryTransactions(cursor.StartTime, cursor.EndTime, 0)
+	if err != nil {
+		s.log.Error("Failed to query next page: key=%s, err=%v", conversationKey, err)
+		return s.msg(msgQueryFailed), true, err
+	}
+	bills = cursor.Filter.apply(bills)
+	sortBills(bills, cursor.Order)
+
+	if cursor.Offset >= len(bills) {
+		_ = s.cursors.Delete(conversationKey)
+		return s.msg(msgQueryNoMore), true, nil
+	}
+
+	cursor.Page++
+	r := &replyBuilder{}
+	r.writeTotal(s.msg(msgQueryPageHeader, cursor.Page, cursor.StartTime.Format("2006-01-02"), cursor.EndTime.Format("2006-01-02"), s.msg(queryOrderLabel(cursor.Order))))
+	end := cursor.Offset + queryMorePageSize
+	if end > len(bills) {
+		end = len(bills)
+	}
+	for _, bill := range bills[cursor.Offset:end] {
+		line := s.msg(msgQueryPageItem, bill.Date.Format("01-02 15:04"), bill.Description, s.formatAmount(billSign(bill), bill.Amount), bill.Category)
+		if bill.RecordID != "" {
+			line += s.msg(msgQueryItemRecordID, bill.RecordID)
+		}
+		if !r.writeDetail(line) {
+			break
+		}
+		cursor.Offset++
+	}
+
+	if cursor.Offset >= len(bills) {
+		_ = s.cursors.Delete(conversationKey)
+		r.writeTotal(s.msg(msgQueryLastPage))
+		return r.String(), true, nil
+	}
+
+	if err := s.saveQueryCursor(conversationKey, &cursor); err != nil {
+		s.log.Error("Failed to save query cursor: key=%s, err=%v", conversationKey, err)
+		return r.String(), true, nil
+	}
+	r.writeTotal(s.msg(msgQueryMoreHint))
+	return r.String(), true, nil
+}
+
+// sortBills 按翻页顺序排列账单，与第一页的渲染顺序一致
+func sortBills(bills []*domain.Bill, order string) {
+	if order == queryOrderDate {
+		sort.SliceStable(bills, func(i, j int) bool { return bills[i].Date.Before(bills[j].Date) })
+		return
+	}
+	sort.SliceStable(bills, func(i, j int) bool { return bills[i].Amount > bills[j].Amount })
+}
+
+// queryOrderLabel 返回页眉中说明排列顺序的文案
+func queryOrderLabel(order string) messageKey {
+	if order == queryOrderDate {
+		return msgQueryOrderDate
+	}
+	return msgQueryOrderAmount
+}
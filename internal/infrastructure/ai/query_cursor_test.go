@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+const testCursorKey = "u1:thread1"
+
+// newCursorTestService 创建带 n 条账单的服务，第 i 条金额为 i，时间越晚金额越小，
+// 按时间倒序和按金额降序得到的前几条不同
+func newCursorTestService(t *testing.T, n int) (*OpenAIService, *BillService) {
+	t.Helper()
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	})
+	bills := &fakeBillUseCase{}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		bills.bills = append(bills.bills, &domain.Bill{
+			RecordID:    fmt.Sprintf("rec%d", i),
+			Description: fmt.Sprintf("item%d", i),
+			Amount:      float64(i),
+			Type:        domain.BillTypeExpense,
+			Date:        start.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+	return svc, NewBillService(context.Background(), bills, "u1", "张三", "").(*BillService)
+}
+
+// 第一页显示金额最大的几条，“更多”按相同顺序从其后继续，不重复也不遗漏
+func TestMoreResultsContinuesTopN(t *testing.T) {
+	const total = 25
+	svc, billService := newCursorTestService(t, total)
+
+	first, err := svc.handleQueryTransactions(map[string]interface{}{"time_range_type": "this_month", "top_n": float64(5)}, billService, testCursorKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(first, svc.msg(msgQueryMoreHint)) {
+		t.Fatalf("truncated reply misses the more hint: %q", first)
+	}
+
+	seen := make(map[string]int)
+	for _, bill := range billsInReply(t, first) {
+		seen[bill]++
+	}
+	var pages []string
+	for i := 0; i < 5; i++ {
+		reply, handled, err := svc.MoreResults(testCursorKey, billService)
+		if err != nil || !handled {
+			t.Fatalf("MoreResults = %q, %v, %v", reply, handled, err)
+		}
+		pages = append(pages, reply)
+		for _, bill := range billsInReply(t, reply) {
+			seen[bill]++
+		}
+		if strings.Contains(reply, svc.msg(msgQueryLastPage)) {
+			break
+		}
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d more pages, want 2 pages of %d after the top 5", len(pages), queryMorePageSize)
+	}
+	if !strings.Contains(pages[0], "第 2 页") || !strings.Contains(pages[0], svc.msg(msgQueryOrderAmount)) {
+		t.Errorf("page header = %q, want page 2 ordered by amount", strings.SplitN(pages[0], "\n", 2)[0])
+	}
+	// 第二页从第 6 大的金额开始
+	if got := billsInReply(t, pages[0]); got[0] != "rec20" {
+		t.Errorf("second page starts with %s, want rec20", got[0])
+	}
+	for i := 1; i <= total; i++ {
+		if id := fmt.Sprintf("rec%d", i); seen[id] != 1 {
+			t.Errorf("%s shown %d times, want once", id, seen[id])
+		}
+	}
+
+	// 最后一页之后游标被删除
+	if _, handled, _ := svc.MoreResults(testCursorKey, billService); handled {
+		t.Error("MoreResults after the last page was handled, want no cursor")
+	}
+}
+
+// 按天分组的明细按时间顺序继续
+func TestMoreResultsContinuesByDay(t *testing.T) {
+	svc, billService := newCursorTestService(t, 3)
+	if err := svc.storeQueryCursor(testCursorKey, time.Time{}, time.Now(), queryFilter{}, queryOrderDate, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, _, err := svc.MoreResults(testCursorKey, billService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 最早的是 rec3，已显示，接下来是 rec2、rec1
+	if got := billsInReply(t, reply); strings.Join(got, ",") != "rec2,rec1" {
+		t.Errorf("page = %v, want rec2,rec1", got)
+	}
+}
+
+func TestMoreResultsCursorExpiry(t *testing.T) {
+	svc, billService := newCursorTestService(t, 20)
+
+	if _, handled, _ := svc.MoreResults(testCursorKey, billService); handled {
+		t.Fatal("MoreResults without a cursor was handled")
+	}
+
+	// 有效期内可以翻页，翻页会刷新有效期
+	if err := svc.storeQueryCursor(testCursorKey, time.Time{}, time.Now(), queryFilter{}, queryOrderAmount, 5); err != nil {
+		t.Fatal(err)
+	}
+	if reply, handled, _ := svc.MoreResults(testCursorKey, billService); !handled || !strings.Contains(reply, "rec15") {
+		t.Fatalf("MoreResults within the TTL = %q, %v", reply, handled)
+	}
+	var cursor queryCursor
+	if err := svc.cursors.Get(testCursorKey, &cursor); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(cursor.ExpiresAt); remaining < queryCursorTTL-time.Minute {
+		t.Errorf("cursor expires in %v, want it refreshed to %v", remaining, queryCursorTTL)
+	}
+
+	// 超过有效期提示重新查询，之后不再处理
+	cursor.ExpiresAt = time.Now().Add(-time.Second)
+	if err := svc.cursors.Set(testCursorKey, &cursor, queryCursorTTL); err != nil {
+		t.Fatal(err)
+	}
+	reply, handled, err := svc.MoreResults(testCursorKey, billService)
+	if err != nil || !handled || reply != svc.msg(msgQueryCursorExpired) {
+		t.Fatalf("MoreResults after expiry = %q, %v, %v, want the expired reply", reply, handled, err)
+	}
+	if _, handled, _ := svc.MoreResults(testCursorKey, billService); handled {
+		t.Error("expired cursor was not deleted")
+	}
+}
+
+// 翻页前记录被删除，已显示的记录之后没有剩余
+func TestMoreResultsNoMore(t *testing.T) {
+	svc, billService := newCursorTestService(t, 3)
+	if err := svc.storeQueryCursor(testCursorKey, time.Time{}, time.Now(), queryFilter{}, queryOrderAmount, 5); err != nil {
+		t.Fatal(err)
+	}
+	reply, handled, err := svc.MoreResults(testCursorKey, billService)
+	if err != nil || !handled || reply != svc.msg(msgQueryNoMore) {
+		t.Fatalf("MoreResults = %q, %v, %v, want the no more reply", reply, handled, err)
+	}
+	if _, handled, _ := svc.MoreResults(testCursorKey, billService); handled {
+		t.Error("cursor was not deleted")
+	}
+}
+
+// 结果没有被截断时不保存游标
+func TestQueryWithoutMoreKeepsNoCursor(t *testing.T) {
+	svc, billService := newCursorTestService(t, 3)
+	reply, err := svc.handleQueryTransactions(map[string]interface{}{"time_range_type": "this_month", "top_n": float64(5)}, billService, testCursorKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(reply, svc.msg(msgQueryMoreHint)) || svc.cursors.Exists(testCursorKey) {
+		t.Errorf("complete reply stored a cursor: %q", reply)
+	}
+}
+
+// recordIDPattern 匹配测试账单的记录 ID
+var recordIDPattern = regexp.MustCompile(`rec\d+`)
+
+// billsInReply 按出现顺序返回回复中的记录 ID
+func billsInReply(t *testing.T, reply string) []string {
+	t.Helper()
+	return recordIDPattern.FindAllString(reply, -1)
+}
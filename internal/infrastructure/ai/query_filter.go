@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// queryFilter 查询结果的过滤条件，字段为空时不过滤
+// 过滤在取回时间范围内的全部记录后进行，翻页游标中同样保存，保证“更多”与首次查询一致
+type queryFilter struct {
+	Account string `json:"account,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	// PendingReimbursement 只保留可报销但尚未报销的支出
+	PendingReimbursement bool `json:"pending_reimbursement,omitempty"`
+}
+
+// queryFilterFromArgs 从 query_transactions 的参数中读取过滤条件
+func queryFilterFromArgs(args map[string]interface{}) queryFilter {
+	filter := queryFilter{
+		Account: strings.TrimSpace(getString(args, "account")),
+		Tag:     firstTag(getString(args, "tag")),
+	}
+	filter.PendingReimbursement, _ = getBool(args, "pending_reimbursement")
+	return filter
+}
+
+// empty 是否没有任何过滤条件
+func (f queryFilter) empty() bool {
+	return f.Account == "" && f.Tag == "" && !f.PendingReimbursement
+}
+
+// match 判断账单是否满足过滤条件，账户名和标签不区分大小写
+func (f queryFilter) match(bill *domain.Bill) bool {
+	if f.Account != "" && !strings.EqualFold(strings.TrimSpace(bill.Account), f.Account) {
+		return false
+	}
+	if f.Tag != "" && !hasTag(bill, f.Tag) {
+		return false
+	}
+	if f.PendingReimbursement && (bill.Type == domain.BillTypeIncome || !bill.PendingReimbursement()) {
+		return false
+	}
+	return true
+}
+
+// hasTag 判断账单是否带有指定标签
+func hasTag(bill *domain.Bill, tag string) bool {
+	for _, t := range bill.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstTag 规范化过滤用的标签，去掉 # 号，传入多个时只取第一个
+func firstTag(value string) string {
+	tags := domain.NormalizeTags(strings.Fields(value))
+	if len(tags) == 0 {
+		return ""
+	}
+	return tags[0]
+}
+
+// formatTags 将标签渲染为 "#旅行 #报销"
+func formatTags(tags []string) string {
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = "#" + tag
+	}
+	return strings.Join(formatted, " ")
+}
+
+// apply 返回满足过滤条件的账单，保持原有顺序
+func (f queryFilter) apply(bills []*domain.Bill) []*domain.Bill {
+	if f.empty() {
+		return bills
+	}
+	filtered := make([]*domain.Bill, 0, len(bills))
+	for _, bill := range bills {
+		if f.match(bill) {
+			filtered = append(filtered, bill)
+		}
+	}
+	return filtered
+}
+
+// sumBills 计算账单的收入和支出合计，借贷不计入
+func sumBills(bills []*domain.Bill) (income, expense float64) {
+	return domain.SumBills(bills)
+}
+
+// renderReimbursableTotal 输出支出中可报销的部分，没有可报销的支出时不输出
+// 可报销标记只影响渲染，修改标记后下次查询即按新的标记统计
+func (s *OpenAIService) renderReimbursableTotal(r *replyBuilder, bills []*domain.Bill) {
+	var reimbursable, pending float64
+	for _, bill := range bills {
+		if bill.Type == domain.BillTypeIncome || !bill.IsReimbursable() {
+			continue
+		}
+		reimbursable = domain.AddAmount(reimbursable, bill.Amount)
+		if bill.ReimbursedAt == nil {
+			pending = domain.AddAmount(pending, bill.Amount)
+		}
+	}
+	if reimbursable == 0 {
+		return
+	}
+	r.writeTotal(s.msg(msgQueryReimbursable, s.formatAmount("", reimbursable), s.formatAmount("", pending)))
+}
+
+// renderAccountTotals 按支付账户汇总支出，没有任何账单填写账户时不输出
+func (s *OpenAIService) renderAccountTotals(r *replyBuilder, bills []*domain.Bill) {
+	type accountTotal struct {
+		name   string
+		amount float64
+		count  int
+	}
+
+	totals := make(map[string]*accountTotal)
+	labeled := false
+	for _, bill := range bills {
+		if bill.Type == domain.BillTypeIncome || bill.IsLoan() {
+			continue
+		}
+		account := strings.TrimSpace(bill.Account)
+		if account != "" {
+			labeled = true
+		}
+		t, ok := totals[account]
+		if !ok {
+			t = &accountTotal{name: account}
+			totals[account] = t
+		}
+		t.amount = domain.AddAmount(t.amount, bill.Amount)
+		t.count++
+	}
+	if !labeled {
+		return
+	}
+
+	list := make([]*accountTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, t)
+	}
+	// 未标记账户的放在最后，其余按金额降序
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i].name == "") != (list[j].name == "") {
+			return list[j].name == ""
+		}
+		if list[i].amount != list[j].amount {
+			return list[i].amount > list[j].amount
+		}
+		return list[i].name < list[j].name
+	})
+
+	for _, t := range list {
+		name := t.name
+		if name == "" {
+			name = s.msg(msgQueryAccountNone)
+		}
+		r.writeTotal(s.msg(msgQueryAccountLine, name, s.formatAmount("", t.amount), t.count))
+	}
+	r.writeTotal("\n")
+}
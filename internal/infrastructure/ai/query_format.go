@@ -0,0 +1,152 @@
+package ai
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+const (
+	GroupByNone     = "none"
+	GroupByDay      = "day"
+	GroupByCategory = "category"
+
+	// queryReplyMaxRunes 查询回复的最大长度，超过时截断明细而保留汇总，
+	// 避免超过飞书文本消息的长度限制
+	queryReplyMaxRunes = 4000
+)
+
+// replyBuilder 在长度预算内拼接回复，汇总行总是保留，明细行超出预算时丢弃
+type replyBuilder struct {
+	b         strings.Builder
+	runes     int
+	truncated bool
+}
+
+// writeTotal 写入汇总行，不受长度预算限制
+func (r *replyBuilder) writeTotal(line string) {
+	r.b.WriteString(line)
+	r.runes += utf8.RuneCountInString(line)
+}
+
+// writeDetail 写入明细行，超出长度预算时丢弃并标记截断，返回是否写入
+func (r *replyBuilder) writeDetail(line string) bool {
+	n := utf8.RuneCountInString(line)
+	if r.truncated || r.runes+n > queryReplyMaxRunes {
+		r.truncated = true
+		return false
+	}
+	r.b.WriteString(line)
+	r.runes += n
+	return true
+}
+
+func (r *replyBuilder) String() string {
+	return r.b.String()
+}
+
+// billSign 返回账单金额的符号
+func billSign(bill *domain.Bill) string {
+	if bill.Type == domain.BillTypeIncome {
+		return "+"
+	}
+	return "-"
+}
+
+// renderQueryTop 渲染按金额降序的前 topN 条记录（bills 已按金额降序），返回实际显示的条数
+func (s *OpenAIService) renderQueryTop(r *replyBuilder, bills []*domain.Bill, topN int) int {
+	if topN > 0 && topN < len(bills) {
+		bills = bills[:topN]
+	}
+
+	r.writeTotal(s.msg(msgQueryTop, len(bills)))
+	shown := 0
+	for i, bill := range bills {
+		line := s.msg(msgQueryItem, i+1, bill.Description, s.formatAmount(billSign(bill), bill.Amount), bill.Category)
+		if bill.RecordID != "" {
+			line += s.msg(msgQueryItemRecordID, bill.RecordID)
+		}
+		if r.writeDetail(line) {
+			shown++
+		}
+	}
+	return shown
+}
+
+// renderQueryByDay 按日期分组渲染，每天一个小节，包含当天的记录和小计；
+// 明细按时间升序排列，返回实际显示的条数
+func (s *OpenAIService) renderQueryByDay(r *replyBuilder, bills []*domain.Bill) int {
+	byDay := make(map[string][]*domain.Bill)
+	var days []string
+	for _, bill := range bills {
+		day := bill.Date.Format("2006-01-02")
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		byDay[day] = append(byDay[day], bill)
+	}
+	sort.Strings(days)
+
+	shown := 0
+	for _, day := range days {
+		dayBills := byDay[day]
+		sort.SliceStable(dayBills, func(i, j int) bool {
+			return dayBills[i].Date.Before(dayBills[j].Date)
+		})
+
+		income, expense := sumBills(dayBills)
+
+		r.writeTotal(s.msg(msgQueryDayHeader, day, s.formatAmount("", income), s.formatAmount("", expense)))
+		for _, bill := range dayBills {
+			line := s.msg(msgQueryGroupItem, bill.Description, s.formatAmount(billSign(bill), bill.Amount), bill.Category)
+			if bill.RecordID != "" {
+				line += s.msg(msgQueryItemRecordID, bill.RecordID)
+			}
+			if r.writeDetail(line) {
+				shown++
+			}
+		}
+	}
+	return shown
+}
+
+// renderQueryByCategory 按分类渲染小计和笔数，按金额降序
+func (s *OpenAIService) renderQueryByCategory(r *replyBuilder, bills []*domain.Bill) {
+	type categoryTotal struct {
+		name   string
+		amount float64
+		count  int
+	}
+
+	totals := make(map[string]*categoryTotal)
+	for _, bill := range bills {
+		category := bill.Category
+		if category == "" {
+			category = domain.CategoryOther
+		}
+		t, ok := totals[category]
+		if !ok {
+			t = &categoryTotal{name: category}
+			totals[category] = t
+		}
+		t.amount = domain.AddAmount(t.amount, bill.Amount)
+		t.count++
+	}
+
+	list := make([]*categoryTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].amount != list[j].amount {
+			return list[i].amount > list[j].amount
+		}
+		return list[i].name < list[j].name
+	})
+
+	for _, t := range list {
+		r.writeTotal(s.msg(msgQueryCategoryLine, t.name, s.formatAmount("", t.amount), t.count))
+	}
+}
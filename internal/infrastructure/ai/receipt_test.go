@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestReceiptDate(t *testing.T) {
+	now := time.Date(2025, 6, 3, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		date string
+		want string // 空表示使用记账时间
+	}{
+		{date: "2025-06-01", want: "2025-06-01T15:04:05Z"},
+		{date: " 2025-06-03 ", want: "2025-06-03T15:04:05Z"},
+		{date: ""},
+		{date: "6月1日"},
+		{date: "2025-06-04"},
+	}
+	for _, tt := range tests {
+		got := receiptDate(tt.date, now)
+		switch {
+		case tt.want == "" && got != nil:
+			t.Errorf("receiptDate(%q) = %v, want nil", tt.date, got)
+		case tt.want != "" && (got == nil || got.Format(time.RFC3339) != tt.want):
+			t.Errorf("receiptDate(%q) = %v, want %s", tt.date, got, tt.want)
+		}
+	}
+}
+
+// 收据上的日期写入账单，没有日期时使用记账时间
+func TestExecuteReceiptDate(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	for _, date := range []string{yesterday, ""} {
+		t.Run("date="+date, func(t *testing.T) {
+			svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+				return openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: fmt.Sprintf(`{"merchant": "咖啡店", "amount": 28, "date": %q, "category": "餐饮", "confidence": 0.95}`, date),
+				}
+			})
+			bills := &fakeBillUseCase{}
+			if _, err := svc.ExecuteReceipt(context.Background(), []byte("\x89PNG\r\n\x1a\n"), "张三", "u1:thread1", NewBillService(context.Background(), bills, "u1", "张三", ""), nil); err != nil {
+				t.Fatal(err)
+			}
+
+			created := bills.createdInputs()
+			if len(created) != 1 {
+				t.Fatalf("created %d bills, want 1", len(created))
+			}
+			got := created[0].Date
+			switch {
+			case date == "" && got != nil:
+				t.Errorf("bill date = %v, want the record time", got)
+			case date != "" && (got == nil || got.Format("2006-01-02") != date):
+				t.Errorf("bill date = %v, want %s", got, date)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// handleCreateRecurring 创建周期记账规则
+func (s *OpenAIService) handleCreateRecurring(args map[string]interface{}, svc *BillService) (string, error) {
+	rule := domain.RecurringRule{
+		Description: getString(args, "description"),
+		Amount:      getAmount(args, "amount"),
+		Type:        domain.BillType(getString(args, "type")),
+		Category:    getString(args, "category"),
+		Cadence:     domain.RecurringCadence(getString(args, "cadence")),
+		DayOfMonth:  int(getFloat64(args, "day_of_month")),
+	}
+	// 工具参数中 1-7 表示周一到周日
+	if weekday := int(getFloat64(args, "weekday")); weekday >= 1 && weekday <= 7 {
+		rule.Weekday = time.Weekday(weekday % 7)
+	} else if rule.Cadence == domain.RecurringWeekly {
+		return s.msg(msgRecurringInvalid), fmt.Errorf("invalid weekday: %d", weekday)
+	}
+	if err := rule.Validate(); err != nil {
+		return s.msg(msgRecurringInvalid), err
+	}
+
+	created, err := svc.CreateRecurringRule(rule)
+	if err != nil {
+		s.log.Error("Failed to create recurring rule: %v", err)
+		return s.msg(msgRecurringFailed), err
+	}
+	return s.msg(msgRecurringCreated, created.Description, s.formatAmount("", created.Amount), created.Category,
+		s.recurringSchedule(created), created.Next(time.Now().In(s.timeLocation())).Format("2006-01-02"), created.ID), nil
+}
+
+// handleListRecurring 列出周期记账规则
+func (s *OpenAIService) handleListRecurring(svc *BillService) (string, error) {
+	rules, err := svc.ListRecurringRules()
+	if err != nil {
+		s.log.Error("Failed to list recurring rules: %v", err)
+		return s.msg(msgRecurringFailed), err
+	}
+	if len(rules) == 0 {
+		return s.msg(msgRecurringNone), nil
+	}
+
+	now := time.Now().In(s.timeLocation())
+	text := s.msg(msgRecurringListHeader)
+	for _, rule := range rules {
+		text += s.msg(msgRecurringListItem, rule.Description, s.formatAmount("", rule.Amount), rule.Category,
+			s.recurringSchedule(rule), rule.Next(now).Format("2006-01-02"), rule.ID)
+	}
+	return text, nil
+}
+
+// handleDeleteRecurring 删除周期记账规则，已记录的账单保留
+func (s *OpenAIService) handleDeleteRecurring(args map[string]interface{}, svc *BillService) (string, error) {
+	ruleID := getString(args, "rule_id")
+	if ruleID == "" {
+		return s.msg(msgRecurringIDRequired), fmt.Errorf("rule_id is required")
+	}
+
+	rule, err := svc.DeleteRecurringRule(ruleID)
+	if errors.Is(err, domain.ErrRecurringRuleNotFound) {
+		return s.msg(msgRecurringNotFound, ruleID), nil
+	}
+	if err != nil {
+		s.log.Error("Failed to delete recurring rule: %v", err)
+		return s.msg(msgRecurringFailed), err
+	}
+	return s.msg(msgRecurringDeleted, rule.Description), nil
+}
+
+// recurringSchedule 描述规则的记账周期，如"每月 5 日"、"每周一"
+func (s *OpenAIService) recurringSchedule(rule *domain.RecurringRule) string {
+	if rule.Cadence == domain.RecurringWeekly {
+		names := strings.Split(s.msg(msgRecurringWeekdays), ",")
+		return s.msg(msgRecurringWeekly, names[rule.Weekday])
+	}
+	return s.msg(msgRecurringMonthly, rule.DayOfMonth)
+}
@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// platformError 带平台请求 ID 的错误，与飞书的 APIError 一样实现 PlatformRequestID
+type platformError struct{ id string }
+
+func (e *platformError) Error() string             { return "create bitable record failed" }
+func (e *platformError) PlatformRequestID() string { return e.id }
+
+// 开启 ShowRequestID 时失败回复附加请求 ID 的末 8 位
+func TestWithRequestID(t *testing.T) {
+	svc, _ := newTestService(t, nil)
+	err := fmt.Errorf("create bill: %w", &platformError{id: "20261016143000ABCDEF0123456789"})
+
+	if got := svc.withRequestID("记账失败", err); got != "记账失败" {
+		t.Errorf("reply with ShowRequestID off = %q", got)
+	}
+
+	svc.config.ShowRequestID = true
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err, "记账失败（请求 ID：…23456789）"},
+		{&platformError{id: "short"}, "记账失败（请求 ID：short）"},
+		{&platformError{}, "记账失败"},
+		{errors.New("network down"), "记账失败"},
+		{nil, "记账失败"},
+	}
+	for _, tt := range tests {
+		if got := svc.withRequestID("记账失败", tt.err); got != tt.want {
+			t.Errorf("withRequestID(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+
+	svc.language = "en"
+	if got := svc.withRequestID("Failed to record transaction", err); got != "Failed to record transaction (request ID: …23456789)" {
+		t.Errorf("English reply = %q", got)
+	}
+}
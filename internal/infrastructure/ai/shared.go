@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// handleMemberBreakdown 共享账本中按成员统计时间范围内的收支
+func (s *OpenAIService) handleMemberBreakdown(args map[string]interface{}, svc *BillService) (string, error) {
+	startTime, endTime, reply, err := s.parseToolTimeRange("member_breakdown", args)
+	if err != nil {
+		return reply, err
+	}
+
+	totals, err := svc.MemberBreakdown(startTime, endTime)
+	if err != nil {
+		return s.sharedErrorText(err)
+	}
+	start, end := startTime.Format("2006-01-02"), endTime.Format("2006-01-02")
+	if len(totals) == 0 {
+		return s.msg(msgSharedEmpty, start, end), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(s.msg(msgMemberHeader, start, end))
+	for _, total := range totals {
+		b.WriteString(s.msg(msgMemberItem, total.UserName, s.formatAmount("", total.Expense), s.formatAmount("", total.Income), total.Count))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// handleSettleUp 共享账本 AA 结算：按权重分摊支出，列出谁应付给谁多少
+func (s *OpenAIService) handleSettleUp(args map[string]interface{}, svc *BillService) (string, error) {
+	startTime, endTime, reply, err := s.parseToolTimeRange("settle_up", args)
+	if err != nil {
+		return reply, err
+	}
+
+	settlement, err := svc.SettleUp(startTime, endTime, s.config.SplitWeights)
+	if err != nil {
+		return s.sharedErrorText(err)
+	}
+	start, end := startTime.Format("2006-01-02"), endTime.Format("2006-01-02")
+	if settlement.Total == 0 {
+		return s.msg(msgSharedEmpty, start, end), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(s.msg(msgSettleUpHeader, start, end, s.formatAmount("", settlement.Total)))
+	for _, member := range settlement.Members {
+		b.WriteString(s.msg(msgSettleUpMember, member.UserName, s.formatAmount("", member.Paid), s.formatAmount("", member.Share)))
+	}
+	if len(settlement.Transfers) == 0 {
+		b.WriteString(s.msg(msgSettleUpBalanced))
+		return b.String(), nil
+	}
+	b.WriteString(s.msg(msgSettleUpTransfers))
+	for _, transfer := range settlement.Transfers {
+		b.WriteString(s.msg(msgSettleUpTransfer, transfer.From, transfer.To, s.formatAmount("", transfer.Amount)))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// sharedErrorText 共享账本统计出错时的回复；未开启共享账本不算作错误
+func (s *OpenAIService) sharedErrorText(err error) (string, error) {
+	if errors.Is(err, domain.ErrLedgerNotShared) {
+		return s.msg(msgSharedNotEnabled), nil
+	}
+	s.log.Error("Shared ledger statistics failed: %v", err)
+	return s.msg(msgSharedFailed), err
+}
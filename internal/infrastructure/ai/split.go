@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// handleSplitTransaction 记录 AA 分摊中自己的份额，共享账本中可同时为其他参与者记账
+func (s *OpenAIService) handleSplitTransaction(args map[string]interface{}, svc *BillService) (string, error) {
+	// 与 record_transaction 的参数相同，只是金额为分摊前的总额
+	input, _ := recordTransactionInput(args)
+	input.Amount = getAmount(args, "total_amount")
+	input.Type = domain.BillTypeExpense
+	if input.Description == "" || input.Amount <= 0 {
+		s.log.Error("Invalid split args: description=%s, total=%.2f", input.Description, input.Amount)
+		return s.msg(msgInvalidTransaction), fmt.Errorf("invalid args")
+	}
+
+	// 参与者只包含其他人，AI 误把用户自己列入时去掉
+	var participants []string
+	for _, name := range getStringSlice(args, "participants") {
+		if name = strings.TrimSpace(name); name != svc.userName && name != "我" {
+			participants = append(participants, name)
+		}
+	}
+	split, err := domain.NewSplit(input.Amount, participants, int(getFloat64(args, "people")))
+	if err != nil {
+		return s.msg(msgSplitInvalid, domain.MaxSplitPeople), err
+	}
+
+	// 分摊明细写入原始消息字段，方便日后查看
+	if input.OriginalMsg == "" {
+		input.OriginalMsg = svc.originalMsg
+	}
+	note := s.splitNote(split, svc.userName)
+	if input.OriginalMsg != "" {
+		note = input.OriginalMsg + "\n" + note
+	}
+	input.OriginalMsg = note
+
+	recordOthers := getString(args, "record_for") == "all" && len(split.Participants) > 0
+	bills, err := svc.SplitBill(input, split, recordOthers)
+	var dupErr *domain.DuplicateBillError
+	if errors.As(err, &dupErr) {
+		return "", err
+	}
+	if len(bills) == 0 {
+		s.log.Error("Failed to record split bill: %v", err)
+		return s.msg(msgRecordFailed), err
+	}
+
+	text := s.recordSuccessText(bills[0]) + s.msg(msgSplitSummary, s.formatAmount("", split.Total), split.Count, s.formatAmount("", split.PerHead()))
+	if !recordOthers {
+		return text, nil
+	}
+	var others []string
+	for _, bill := range bills[1:] {
+		others = append(others, bill.UserName)
+	}
+	switch {
+	case len(others) > 0:
+		text += s.msg(msgSplitOthers, strings.Join(others, s.msg(msgSplitNameSep)))
+	case err == nil:
+		// 只记录了自己的份额且没有出错，说明当前会话不是共享账本
+		text += s.msg(msgSplitNotShared)
+	}
+	if err != nil {
+		s.log.Error("Failed to record split shares of other members: %v", err)
+		text += s.msg(msgSplitOthersFailed)
+	}
+	return text, err
+}
+
+// splitNote 描述分摊明细：总额、参与者和人均金额；只知道人数时在名单后注明“等”
+func (s *OpenAIService) splitNote(split *domain.Split, userName string) string {
+	names := append([]string{userName}, split.Participants...)
+	list := strings.Join(names, s.msg(msgSplitNameSep))
+	if split.Count > len(names) {
+		list += s.msg(msgSplitUnnamed)
+	}
+	return s.msg(msgSplitNote, s.formatAmount("", split.Total), split.Count, list, s.formatAmount("", split.PerHead()))
+}
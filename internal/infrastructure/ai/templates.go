@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// handleSaveTemplate 保存记账模板；给出 record_id 时复制该记录，参数中的字段优先
+func (s *OpenAIService) handleSaveTemplate(args map[string]interface{}, svc *BillService) (string, error) {
+	tpl := domain.BillTemplate{Name: domain.NormalizeTemplateName(getString(args, "name"))}
+	if recordID := getString(args, "record_id"); recordID != "" {
+		bill, err := svc.billUseCase.GetBill(svc.ctx, recordID)
+		if err != nil {
+			s.log.Error("Failed to get bill for template: record_id=%s, err=%v", recordID, err)
+			return s.msg(msgTemplateFailed), err
+		}
+		tpl.Description = bill.Description
+		tpl.Amount = bill.Amount
+		tpl.Type = bill.Type
+		tpl.Category = bill.Category
+	}
+	if description := getString(args, "description"); description != "" {
+		tpl.Description = description
+	}
+	if amount := getAmount(args, "amount"); amount > 0 {
+		tpl.Amount = amount
+	}
+	if billType := getString(args, "type"); billType != "" {
+		tpl.Type = domain.BillType(billType)
+	}
+	if category := getString(args, "category"); category != "" {
+		tpl.Category = category
+	}
+	if tpl.Name == "" || tpl.Amount <= 0 {
+		return s.msg(msgTemplateInvalid), fmt.Errorf("invalid save_template args: %v", args)
+	}
+
+	saved, previous, err := svc.SaveTemplate(tpl)
+	if err != nil {
+		s.log.Error("Failed to save template: %v", err)
+		return s.msg(msgTemplateFailed), err
+	}
+	text := s.msg(msgTemplateSaved, saved.Name, saved.Description, s.formatAmount("", saved.Amount), saved.Category, saved.Name)
+	if previous != nil {
+		text += s.msg(msgTemplateReplaced, previous.Description, s.formatAmount("", previous.Amount), previous.Category)
+	}
+	return text, nil
+}
+
+// handleUseTemplate 按模板记账，用户给出金额时只替换金额
+func (s *OpenAIService) handleUseTemplate(args map[string]interface{}, svc *BillService) (string, error) {
+	name := domain.NormalizeTemplateName(getString(args, "name"))
+	bill, tpl, err := svc.UseTemplate(name, getAmount(args, "amount"))
+	var dupErr *domain.DuplicateBillError
+	switch {
+	case errors.As(err, &dupErr):
+		return "", err
+	case errors.Is(err, domain.ErrTemplateNotFound) && name == "":
+		return s.msg(msgTemplateNone), nil
+	case errors.Is(err, domain.ErrTemplateNotFound):
+		return s.msg(msgTemplateNotFound, name), nil
+	case err != nil:
+		s.log.Error("Failed to use template: %v", err)
+		return s.msg(msgTemplateFailed), err
+	}
+	return s.recordSuccessText(bill) + s.msg(msgTemplateUsed, tpl.Name), nil
+}
+
+// handleListTemplates 列出用户的记账模板
+func (s *OpenAIService) handleListTemplates(svc *BillService) (string, error) {
+	templates, err := svc.ListTemplates()
+	if err != nil {
+		s.log.Error("Failed to list templates: %v", err)
+		return s.msg(msgTemplateFailed), err
+	}
+	if len(templates) == 0 {
+		return s.msg(msgTemplateNone), nil
+	}
+
+	text := s.msg(msgTemplateListHeader)
+	for _, tpl := range templates {
+		text += s.msg(msgTemplateListItem, tpl.Name, tpl.Description, s.formatAmount("", tpl.Amount), tpl.Category)
+	}
+	return text, nil
+}
+
+// handleDeleteTemplate 删除记账模板，已记录的账单保留
+func (s *OpenAIService) handleDeleteTemplate(args map[string]interface{}, svc *BillService) (string, error) {
+	name := domain.NormalizeTemplateName(getString(args, "name"))
+	if name == "" {
+		return s.msg(msgTemplateInvalid), fmt.Errorf("name is required")
+	}
+
+	tpl, err := svc.DeleteTemplate(name)
+	if errors.Is(err, domain.ErrTemplateNotFound) {
+		return s.msg(msgTemplateNotFound, name), nil
+	}
+	if err != nil {
+		s.log.Error("Failed to delete template: %v", err)
+		return s.msg(msgTemplateFailed), err
+	}
+	return s.msg(msgTemplateDeleted, tpl.Name), nil
+}
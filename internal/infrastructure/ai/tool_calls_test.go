@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// 同名且参数相同（忽略空白和键顺序）的工具调用只保留第一个
+func TestDedupToolCalls(t *testing.T) {
+	calls := toolCalls(
+		"delete_transaction", `{"record_id":"rec1"}`,
+		"delete_transaction", `{ "record_id": "rec1" }`,
+		"delete_transaction", `{"record_id":"rec2"}`,
+		"query_transactions", `{"time_range_type":"today","top_n":5}`,
+		"query_transactions", `{"top_n":5,"time_range_type":"today"}`,
+	).ToolCalls
+	unique, dropped := dedupToolCalls(calls)
+	var got []string
+	for _, tc := range unique {
+		got = append(got, tc.Function.Name+" "+tc.Function.Arguments)
+	}
+	want := []string{
+		`delete_transaction {"record_id":"rec1"}`,
+		`delete_transaction {"record_id":"rec2"}`,
+		`query_transactions {"time_range_type":"today","top_n":5}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupToolCalls = %v, want %v", got, want)
+	}
+	if want := []string{calls[1].ID, calls[4].ID}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped IDs = %v, want %v", dropped, want)
+	}
+}
+
+func TestExecuteToolCallLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		calls   openai.ChatCompletionMessage
+		deleted []string // 为 nil 时超出上限，不执行任何调用
+	}{
+		{
+			name:    "within limit",
+			calls:   toolCalls("delete_transaction", `{"record_id":"rec1"}`, "delete_transaction", `{"record_id":"rec2"}`),
+			deleted: []string{"rec1", "rec2"},
+		},
+		{
+			// 重复的调用去重后不计入上限
+			name:    "duplicates",
+			calls:   toolCalls("delete_transaction", `{"record_id":"rec1"}`, "delete_transaction", `{"record_id":"rec1"}`, "delete_transaction", `{"record_id":"rec2"}`),
+			deleted: []string{"rec1", "rec2"},
+		},
+		{
+			name:  "over limit",
+			calls: toolCalls("delete_transaction", `{"record_id":"rec1"}`, "delete_transaction", `{"record_id":"rec2"}`, "delete_transaction", `{"record_id":"rec3"}`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage { return tt.calls })
+			svc.config.MaxToolCalls = 2
+			bills := &fakeBillUseCase{}
+			reply, err := svc.Execute(context.Background(), "删除 rec1 rec2 rec3", "张三", "", NewBillService(context.Background(), bills, "u1", "张三", ""), nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bills.deletedIDs(); !reflect.DeepEqual(got, tt.deleted) {
+				t.Errorf("deleted %v, want %v", got, tt.deleted)
+			}
+			if tooMany := svc.msg(msgTooManyToolCalls, 3, 2); (tt.deleted == nil) != (reply == tooMany) {
+				t.Errorf("reply = %q, want the limit message only when over the limit", reply)
+			}
+		})
+	}
+}
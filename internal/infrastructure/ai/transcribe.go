@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Transcribe 调用 OpenAI 兼容的 /v1/audio/transcriptions 接口将语音转为文字
+// fileName 的扩展名用于告诉服务端音频格式
+func (s *OpenAIService) Transcribe(ctx context.Context, audio []byte, fileName string) (string, error) {
+	if len(audio) == 0 {
+		return "", fmt.Errorf("audio is empty")
+	}
+
+	req := openai.AudioRequest{
+		Model:    s.config.TranscriptionModel,
+		FilePath: fileName,
+		Reader:   bytes.NewReader(audio),
+		Format:   openai.AudioResponseFormatJSON,
+	}
+	if s.language == LanguageZH {
+		req.Language = "zh"
+	} else {
+		req.Language = "en"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	resp, err := s.transcriptionClient.CreateTranscription(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("transcribe audio: %w", err)
+	}
+
+	text := strings.TrimSpace(resp.Text)
+	s.log.Debug("Transcribe: %d bytes -> %q", len(audio), text)
+	return text, nil
+}
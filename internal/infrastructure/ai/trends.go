@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"math"
+	"strings"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// trendSparklineMaxDays 超过这个天数时不显示每日支出的迷你图，避免回复过长
+const trendSparklineMaxDays = 31
+
+// trendFlatPercent 变化幅度在这个百分比以内视为基本持平
+const trendFlatPercent = 5
+
+// sparkBlocks 迷你图使用的字符，从低到高
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// handleSpendingTrends 统计时间范围内的日均支出、趋势、花得最多的一天和无消费天数
+func (s *OpenAIService) handleSpendingTrends(args map[string]interface{}, svc *BillService) (string, error) {
+	startTime, endTime, reply, err := s.parseToolTimeRange("spending_trends", args)
+	if err != nil {
+		return reply, err
+	}
+
+	trend, err := svc.SpendingTrends(startTime, endTime)
+	if err != nil {
+		s.log.Error("Failed to compute spending trends: %v", err)
+		return s.msg(msgTrendFailed), err
+	}
+	start, end := trend.Start.Format("2006-01-02"), trend.End.Format("2006-01-02")
+	if trend.Expense == 0 {
+		return s.msg(msgTrendEmpty, start, end), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(s.msg(msgTrendHeader, start, end, trend.Days()))
+	b.WriteString(s.msg(msgTrendAverage, s.formatAmount("", trend.Expense), s.formatAmount("", trend.DailyAverage)))
+	b.WriteString(s.msg(msgTrendMaxDay, trend.MaxDay.Format("2006-01-02"), s.formatAmount("", trend.MaxDayAmount)))
+	b.WriteString(s.msg(msgTrendNoSpend, trend.NoSpendDays))
+
+	if trend.Compared {
+		b.WriteString(s.msg(msgTrendCompare, trend.Window, s.formatAmount("", trend.Current), trend.Window, s.formatAmount("", trend.Previous), s.trendChangeText(trend)))
+	} else {
+		b.WriteString(s.msg(msgTrendTooShort, 2*trend.Window))
+	}
+	if trend.Days() > 1 && trend.Days() <= trendSparklineMaxDays {
+		b.WriteString(s.msg(msgTrendSparkline, sparkline(trend.DailyTotals)))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// trendChangeText 描述最近一个窗口相对之前的变化
+func (s *OpenAIService) trendChangeText(trend *domain.SpendingTrend) string {
+	percent, ok := trend.Change()
+	switch {
+	case !ok:
+		return s.msg(msgTrendNoPrevious)
+	case math.Abs(percent) < trendFlatPercent:
+		return s.msg(msgTrendFlat)
+	case percent > 0:
+		return s.msg(msgTrendUp, percent)
+	default:
+		return s.msg(msgTrendDown, -percent)
+	}
+}
+
+// sparkline 把每日金额画成一行 unicode 迷你图，最大值对应最高的方块
+func sparkline(values []float64) string {
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparkBlocks)-1)))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
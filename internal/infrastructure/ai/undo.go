@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// undoFieldLabels 修改撤销时各字段的显示名称
+var undoFieldLabels = map[string]messageKey{
+	"description":  msgUndoFieldDescription,
+	"amount":       msgUndoFieldAmount,
+	"type":         msgUndoFieldType,
+	"category":     msgUndoFieldCategory,
+	"date":         msgUndoFieldDate,
+	"account":      msgUndoFieldAccount,
+	"tags":         msgUndoFieldTags,
+	"reimbursable": msgUndoFieldReimbursable,
+}
+
+// handleUndoLast 撤销用户最近一次新建、删除或修改，并说明撤销了什么
+func (s *OpenAIService) handleUndoLast(svc *BillService) (string, error) {
+	result, err := svc.UndoLast()
+	switch {
+	case errors.Is(err, domain.ErrNothingToUndo):
+		return s.msg(msgUndoNothing), nil
+	case errors.Is(err, domain.ErrUndoUnsupported):
+		return s.msg(msgUndoUnsupported), nil
+	case err != nil:
+		s.log.Error("Failed to undo last operation: %v", err)
+		return s.msg(msgUndoFailed), err
+	}
+	return s.undoText(result), nil
+}
+
+// undoText 描述撤销的结果：撤销新建、恢复删除，或逐项列出写回的字段
+func (s *OpenAIService) undoText(result *domain.UndoResult) string {
+	switch result.Undone.Kind {
+	case domain.OperationCreate:
+		return s.msg(msgUndoCreate, s.undoBillText(result.Bill))
+	case domain.OperationDelete:
+		text := s.msg(msgUndoDelete, s.undoBillText(result.Bill))
+		if result.Recreated {
+			text += s.msg(msgUndoRecreated, result.Bill.RecordID)
+		}
+		return text
+	}
+
+	text := s.msg(msgUndoUpdate, result.Bill.Description)
+	for _, change := range result.Undone.Changes() {
+		text += s.msg(msgUndoChange, s.msg(undoFieldLabels[change.Field]), s.undoValueText(change.Field, change.New), s.undoValueText(change.Field, change.Old))
+	}
+	return text
+}
+
+// undoBillText 账单的简要描述，如"午饭 ¥30.00（餐饮，2024-05-01）"
+func (s *OpenAIService) undoBillText(bill *domain.Bill) string {
+	if bill.Description == "" {
+		return bill.RecordID
+	}
+	return s.msg(msgUndoBill, bill.Description, s.formatAmount("", bill.Amount), bill.Category, bill.Date.Format("2006-01-02"))
+}
+
+// undoValueText 字段值的显示文本，空值显示为 -
+func (s *OpenAIService) undoValueText(field, value string) string {
+	switch {
+	case value == "":
+		return "-"
+	case field == "amount":
+		if amount, err := strconv.ParseFloat(value, 64); err == nil {
+			return s.formatAmount("", amount)
+		}
+	case field == "type" && value == string(domain.BillTypeIncome):
+		return s.msg(msgTypeIncome)
+	case field == "type" && value == string(domain.BillTypeExpense):
+		return s.msg(msgTypeExpense)
+	case field == "reimbursable" && value == "true":
+		return s.msg(msgUndoYes)
+	case field == "reimbursable":
+		return s.msg(msgUndoNo)
+	}
+	return value
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// handleExportMyData 把发消息用户的个人数据打包为 zip，作为附件回复到当前话题
+// 先写入临时文件再上传，账单较多时不占用过多内存
+func (s *OpenAIService) handleExportMyData(svc *BillService) (string, error) {
+	replyFile := domain.FileReplierFromContext(svc.ctx)
+	if replyFile == nil {
+		return s.msg(msgExportMyDataUnsupported), nil
+	}
+
+	tmp, err := os.CreateTemp("", "ledgerbot-userdata-*.zip")
+	if err != nil {
+		s.log.Error("Failed to create user data file: %v", err)
+		return s.msg(msgExportFailed), err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	count, err := svc.ExportUserData(tmp)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return s.msg(msgExportMyDataNone), nil
+	}
+	if err != nil {
+		s.log.Error("Failed to export user data: %v", err)
+		return s.msg(msgExportFailed), err
+	}
+
+	fileName := UserDataFileName(time.Now().In(s.timeLocation()))
+	if err := replyFile(svc.ctx, fileName, tmp); err != nil {
+		s.log.Error("Failed to send user data file: %v", err)
+		return s.msg(msgExportFailed), err
+	}
+	s.log.Info("Exported user data to file: user=%s, file=%s, bills=%d", svc.userName, fileName, count)
+	return s.msg(msgExportMyDataSuccess, count), nil
+}
+
+// UserDataFileName returns the file name of a personal data archive exported at t
+func UserDataFileName(t time.Time) string {
+	return fmt.Sprintf("ledgerbot_my_data_%s.zip", t.Format("20060102"))
+}
+
+// handleDeleteMyData 发起删除发消息用户全部数据的流程，实际删除在用户回复“确认”后由消息处理方执行
+func (s *OpenAIService) handleDeleteMyData(svc *BillService) (string, error) {
+	start := domain.DataDeletionFromContext(svc.ctx)
+	if start == nil {
+		return s.msg(msgDeleteMyDataUnsupported), nil
+	}
+	s.log.Info("Data deletion requested: user=%s", svc.userName)
+	return start(), nil
+}
+
+// ForgetConversations discards the pending confirmations and query cursors of every thread of openID
+func (s *OpenAIService) ForgetConversations(openID string) error {
+	// 会话键为 openID + ":" + 话题 ID
+	prefix := openID + ":"
+	if _, err := s.pending.DeletePrefix(prefix); err != nil {
+		return fmt.Errorf("failed to delete pending confirmations: %v", err)
+	}
+	if _, err := s.cursors.DeletePrefix(prefix); err != nil {
+		return fmt.Errorf("failed to delete query cursors: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// 只清除该用户所有话题的状态，ID 前缀相同的其他用户不受影响
+func TestForgetConversations(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{}
+	})
+	for _, key := range []string{"ou_1:om_a", "ou_1:om_b", "ou_10:om_a"} {
+		if err := svc.pending.Set(key, "pending", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.cursors.Set(key, "cursor", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.ForgetConversations("ou_1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"ou_1:om_a", "ou_1:om_b"} {
+		if svc.pending.Exists(key) || svc.cursors.Exists(key) {
+			t.Errorf("state of %s left", key)
+		}
+	}
+	if !svc.pending.Exists("ou_10:om_a") || !svc.cursors.Exists("ou_10:om_a") {
+		t.Error("state of another user removed")
+	}
+}
+
+func TestHandleDeleteMyData(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{}
+	})
+	billService := NewBillService(context.Background(), &fakeBillUseCase{}, "u1", "张三", "").(*BillService)
+	if reply, err := svc.handleDeleteMyData(billService); err != nil || reply != svc.msg(msgDeleteMyDataUnsupported) {
+		t.Errorf("without a starter = %q, %v", reply, err)
+	}
+
+	// 工具只发起确认，由消息处理方在用户回复“确认”后删除
+	started := 0
+	ctx := domain.WithDataDeletion(context.Background(), func() string {
+		started++
+		return "请回复“确认”"
+	})
+	billService = NewBillService(ctx, &fakeBillUseCase{}, "u1", "张三", "").(*BillService)
+	if reply, err := svc.handleDeleteMyData(billService); err != nil || reply != "请回复“确认”" || started != 1 {
+		t.Errorf("with a starter = %q, %v, started %d times", reply, err, started)
+	}
+}
@@ -0,0 +1,29 @@
+package ai
+
+import (
+	"errors"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// validationText 把账单校验错误转换为提示用户如何修正的回复
+func (s *OpenAIService) validationText(err *domain.BillValidationError) string {
+	switch err.Reason {
+	case domain.ValidationAmountTooLarge:
+		return s.msg(msgAmountNeedsConfirm, s.formatAmount("", err.Amount), err.Description)
+	case domain.ValidationDescriptionEmpty:
+		return s.msg(msgDescriptionEmpty, s.formatAmount("", err.Amount))
+	default:
+		return s.msg(msgAmountInvalid, err.Description)
+	}
+}
+
+// needsConfirmation 判断错误是否为用户确认后仍可记录的账单（疑似重复或金额过大）
+func needsConfirmation(err error) bool {
+	var dupErr *domain.DuplicateBillError
+	if errors.As(err, &dupErr) {
+		return true
+	}
+	var invalidErr *domain.BillValidationError
+	return errors.As(err, &invalidErr) && invalidErr.NeedsConfirmation()
+}
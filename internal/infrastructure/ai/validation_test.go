@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"fmt"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+func TestValidationText(t *testing.T) {
+	svc, _ := newTestService(t, func(openai.ChatCompletionRequest) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{}
+	})
+	tests := []struct {
+		err  *domain.BillValidationError
+		want string
+	}{
+		{&domain.BillValidationError{Reason: domain.ValidationAmountTooLarge, Description: "三千", Amount: 3000000, Limit: 100000}, "⚠️ 金额 ¥3,000,000.00 看起来不对，请确认（三千）"},
+		{&domain.BillValidationError{Reason: domain.ValidationDescriptionEmpty, Amount: 35}, "⚠️ 缺少账单描述，请说明这笔 ¥35.00 花在了什么地方"},
+		{&domain.BillValidationError{Reason: domain.ValidationAmountInvalid, Description: "午饭", Amount: -35}, "⚠️ “午饭”的金额无效，请重新说明金额（需大于 0）"},
+	}
+	for _, tt := range tests {
+		if got := svc.validationText(tt.err); got != tt.want {
+			t.Errorf("validationText(%s) = %q, want %q", tt.err.Reason, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsConfirmation(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&domain.BillValidationError{Reason: domain.ValidationAmountTooLarge}, true},
+		{fmt.Errorf("create: %w", &domain.BillValidationError{Reason: domain.ValidationAmountTooLarge}), true},
+		{&domain.DuplicateBillError{Existing: &domain.Bill{RecordID: "rec1"}}, true},
+		{&domain.BillValidationError{Reason: domain.ValidationAmountInvalid}, false},
+		{&domain.BillValidationError{Reason: domain.ValidationDescriptionEmpty}, false},
+		{fmt.Errorf("timeout"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := needsConfirmation(tt.err); got != tt.want {
+			t.Errorf("needsConfirmation(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// AI 把金额作为字符串传入时按金额解析
+func TestGetAmountString(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  float64
+	}{
+		{"３５元", 35},
+		{"1,200", 1200},
+		{"￥19.999", 20},
+		{19.999999, 20},
+		{int64(8), 8},
+		{"三千", 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := getAmount(map[string]interface{}{"amount": tt.value}, "amount"); got != tt.want {
+			t.Errorf("getAmount(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package feishu
+
+import (
+	"fmt"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+)
+
+// APIError is a Feishu API call that returned a non-zero code. RequestID is the log ID
+// (X-Tt-Logid) of the response, which Feishu support asks for when investigating a failure.
+// It is kept out of Error() because the error text is shown to users; see domain.RequestIDFromError
+type APIError struct {
+	Op        string
+	Code      int
+	Msg       string
+	RequestID string
+	// retryable 按 isRetryable 判断，限流和服务端临时故障为 true
+	retryable bool
+	// err 对应的哨兵错误，如 ErrAppTokenInvalid，可用 errors.Is 判断
+	err error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s failed: code=%d msg=%s", e.Op, e.Code, e.Msg)
+	if e.err != nil {
+		msg += ": " + e.err.Error()
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// PlatformRequestID returns the request ID of the failed call
+func (e *APIError) PlatformRequestID() string {
+	return e.RequestID
+}
+
+// Retryable reports whether the call may succeed if repeated later, such as after rate
+// limiting or a server-side failure; parameter and permission errors are not retryable
+func (e *APIError) Retryable() bool {
+	return e.retryable
+}
+
+// apiError 构造飞书接口的业务错误，带上响应的请求 ID
+func apiError(op string, apiResp *larkcore.ApiResp, code int, msg string) *APIError {
+	return &APIError{Op: op, Code: code, Msg: msg, RequestID: requestID(apiResp), retryable: isRetryable(apiResp, code, nil)}
+}
+
+// bitableError 构造多维表格接口的业务错误，app_token 相关的错误码包装为 ErrAppTokenInvalid
+func bitableError(op string, apiResp *larkcore.ApiResp, code int, msg string) error {
+	err := apiError(op, apiResp, code, msg)
+	if appTokenErrorCodes[code] {
+		err.err = ErrAppTokenInvalid
+	}
+	return err
+}
+
+// requestID 返回响应的请求 ID（X-Tt-Logid，缺少时为 X-Request-Id），没有响应时为空
+func requestID(apiResp *larkcore.ApiResp) string {
+	if apiResp == nil || apiResp.Header == nil {
+		return ""
+	}
+	return apiResp.RequestId()
+}
+
+// recordNotFoundError 构造记录不存在的错误，可用 errors.Is 判断 ErrRecordNotFound
+func recordNotFoundError(op string, apiResp *larkcore.ApiResp, code int, msg string, recordID string) error {
+	err := apiError(op, apiResp, code, msg)
+	err.err = fmt.Errorf("%w: %s", ErrRecordNotFound, recordID)
+	return err
+}
@@ -0,0 +1,153 @@
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// headerTransport 假的 http.RoundTripper，接口请求返回 code 和指定的响应头
+type headerTransport struct {
+	code   int
+	header http.Header
+}
+
+func (tr *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := map[string]interface{}{"code": tr.code, "msg": "fake error"}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if strings.Contains(req.URL.Path, "/auth/v3/") {
+		body = map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200}
+	} else {
+		for k, v := range tr.header {
+			header[k] = v
+		}
+	}
+	data, _ := json.Marshal(body)
+	return &http.Response{StatusCode: http.StatusBadRequest, Header: header, Body: io.NopCloser(bytes.NewReader(data)), Request: req}, nil
+}
+
+// newTransportService 创建通过 headerTransport 访问开放平台的 FeishuService
+func newTransportService(t *testing.T, tr *headerTransport) *FeishuService {
+	t.Helper()
+	client := lark.NewClient("cli_"+t.Name(), "secret", lark.WithHttpClient(&http.Client{Transport: tr}))
+	return &FeishuService{config: &config.FeishuConfig{}, client: client, fieldCache: make(map[string][]*BitableField)}
+}
+
+// 失败的调用返回带请求 ID 的 APIError，经过 %w 包装后仍能取出，errors.Is 照常匹配
+func TestAPIErrorRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   int
+		header http.Header
+		call   func(svc *FeishuService) error
+		is     error
+		wantID string
+	}{
+		{
+			name:   "record not found",
+			code:   codeRecordNotFound,
+			header: http.Header{larkcore.HttpHeaderKeyLogId: []string{"20261016143000ABCDEF0123456789"}},
+			call: func(svc *FeishuService) error {
+				return svc.DeleteRecordToBitable(context.Background(), "app", "tbl", "rec_gone")
+			},
+			is:     ErrRecordNotFound,
+			wantID: "20261016143000ABCDEF0123456789",
+		},
+		{
+			name:   "app token invalid",
+			code:   1254040,
+			header: http.Header{larkcore.HttpHeaderKeyLogId: []string{"log-add-1"}},
+			call: func(svc *FeishuService) error {
+				_, err := svc.AddRecordToBitable(context.Background(), "app", "tbl", map[string]interface{}{"描述": "午饭"})
+				return err
+			},
+			is:     ErrAppTokenInvalid,
+			wantID: "log-add-1",
+		},
+		{
+			// 没有 X-Tt-Logid 时使用 X-Request-Id
+			name:   "request id fallback",
+			code:   1254045,
+			header: http.Header{larkcore.HttpHeaderKeyRequestId: []string{"req-fallback"}},
+			call: func(svc *FeishuService) error {
+				_, err := svc.AddRecordToBitable(context.Background(), "app", "tbl", map[string]interface{}{"描述": "午饭"})
+				return err
+			},
+			wantID: "req-fallback",
+		},
+		{
+			name: "no header",
+			code: 1254045,
+			call: func(svc *FeishuService) error {
+				return svc.DeleteRecordToBitable(context.Background(), "app", "tbl", "rec1")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTransportService(t, &headerTransport{code: tt.code, header: tt.header})
+			err := tt.call(svc)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+				t.Fatalf("error = %v, want an APIError with code %d", err, tt.code)
+			}
+			if apiErr.RequestID != tt.wantID {
+				t.Errorf("RequestID = %q, want %q", apiErr.RequestID, tt.wantID)
+			}
+			wrapped := fmt.Errorf("create bill: %w", err)
+			if got := domain.RequestIDFromError(wrapped); got != tt.wantID {
+				t.Errorf("RequestIDFromError = %q, want %q", got, tt.wantID)
+			}
+			if tt.is != nil && !errors.Is(wrapped, tt.is) {
+				t.Errorf("errors.Is(%v, %v) = false", wrapped, tt.is)
+			}
+			// 错误文本会展示给用户，不包含请求 ID
+			if tt.wantID != "" && strings.Contains(err.Error(), tt.wantID) {
+				t.Errorf("Error() = %q contains the request ID", err.Error())
+			}
+		})
+	}
+}
+
+func TestRequestIDWithoutResponse(t *testing.T) {
+	if id := requestID(nil); id != "" {
+		t.Errorf("requestID(nil) = %q", id)
+	}
+	if id := requestID(&larkcore.ApiResp{}); id != "" {
+		t.Errorf("requestID without header = %q", id)
+	}
+	if err := apiError("reply message", nil, 230001, "bad"); err.RequestID != "" || err.Error() != "reply message failed: code=230001 msg=bad" {
+		t.Errorf("apiError = %+v", err)
+	}
+}
+
+// 限流和 5xx 是临时错误，参数类错误是永久错误；非平台错误按临时错误处理
+func TestIsPermanentError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{apiError("create bitable record", &larkcore.ApiResp{StatusCode: http.StatusBadRequest}, 1254045, "field not found"), true},
+		{apiError("create bitable record", &larkcore.ApiResp{StatusCode: http.StatusBadRequest}, 1254290, "too many requests"), false},
+		{apiError("create bitable record", &larkcore.ApiResp{StatusCode: http.StatusBadGateway}, 1254045, "bad gateway"), false},
+		{fmt.Errorf("create bill: %w", apiError("create bitable record", nil, 1254045, "field not found")), true},
+		{errors.New("connection reset by peer"), false},
+	}
+	for _, tt := range tests {
+		if got := domain.IsPermanentError(tt.err); got != tt.want {
+			t.Errorf("IsPermanentError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
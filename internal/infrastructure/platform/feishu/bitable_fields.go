@@ -0,0 +1,251 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+)
+
+// 多维表格字段类型，参考飞书字段编辑指南
+const (
+	BitableFieldTypeText         = 1
+	BitableFieldTypeNumber       = 2
+	BitableFieldTypeSingleSelect = 3
+	BitableFieldTypeMultiSelect  = 4
+	BitableFieldTypeDateTime     = 5
+	BitableFieldTypeCheckbox     = 7
+	BitableFieldTypeAttachment   = 17
+)
+
+// BitableField 多维表格字段信息
+type BitableField struct {
+	ID      string
+	Name    string
+	Type    int
+	UIType  string
+	Options []BitableFieldOption // 单选、多选字段的选项
+}
+
+// BitableFieldOption 单选、多选字段的选项
+type BitableFieldOption struct {
+	ID    string
+	Name  string
+	Color int
+}
+
+// HasOption 判断字段是否包含指定名称的选项
+func (f *BitableField) HasOption(name string) bool {
+	for _, opt := range f.Options {
+		if opt.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldCacheKey 字段缓存的键
+func fieldCacheKey(appToken, tableID string) string {
+	return appToken + ":" + tableID
+}
+
+// ListTableFields 列出数据表的全部字段
+// 字段结构很少变化，结果按表缓存，需要最新结构时先调用 InvalidateTableFields
+func (s *FeishuService) ListTableFields(ctx context.Context, appToken, tableID string) ([]*BitableField, error) {
+	key := fieldCacheKey(appToken, tableID)
+	s.fieldsMu.Lock()
+	cached, ok := s.fieldCache[key]
+	s.fieldsMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	s.logFor(ctx).Debug("Listing bitable fields: app_token=%s, table_id=%s", appToken, tableID)
+
+	var fields []*BitableField
+	pageToken := ""
+	for {
+		reqBuilder := larkbitable.NewListAppTableFieldReqBuilder().
+			AppToken(appToken).
+			TableId(tableID).
+			PageSize(100)
+		if pageToken != "" {
+			reqBuilder = reqBuilder.PageToken(pageToken)
+		}
+		req := reqBuilder.Build()
+
+		var resp *larkbitable.ListAppTableFieldResp
+		err := s.withRetry(ctx, "list bitable fields", func() (*larkcore.ApiResp, int, error) {
+			var err error
+			resp, err = s.client.Bitable.V1.AppTableField.List(ctx, req)
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.ApiResp, resp.Code, nil
+		})
+		if err != nil {
+			s.logFor(ctx).Error("List bitable fields API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
+			return nil, fmt.Errorf("list bitable fields failed: %w", err)
+		}
+
+		if !resp.Success() {
+			s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("List bitable fields failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
+			return nil, bitableError("list bitable fields", resp.ApiResp, resp.Code, resp.Msg)
+		}
+
+		pageToken = ""
+		if resp.Data != nil {
+			for _, item := range resp.Data.Items {
+				fields = append(fields, toBitableField(item))
+			}
+			if resp.Data.HasMore != nil && *resp.Data.HasMore && resp.Data.PageToken != nil {
+				pageToken = *resp.Data.PageToken
+			}
+		}
+
+		if pageToken == "" {
+			break
+		}
+	}
+
+	s.fieldsMu.Lock()
+	s.fieldCache[key] = fields
+	s.fieldsMu.Unlock()
+
+	s.logFor(ctx).Debug("Successfully listed bitable fields: count=%d, app_token=%s, table_id=%s", len(fields), appToken, tableID)
+	return fields, nil
+}
+
+// InvalidateTableFields 清除数据表的字段缓存
+func (s *FeishuService) InvalidateTableFields(appToken, tableID string) {
+	s.fieldsMu.Lock()
+	delete(s.fieldCache, fieldCacheKey(appToken, tableID))
+	s.fieldsMu.Unlock()
+}
+
+// CreateTableField 在数据表中新建字段，单选、多选字段可同时指定选项
+func (s *FeishuService) CreateTableField(ctx context.Context, appToken, tableID, name string, fieldType int, options []string) error {
+	s.logFor(ctx).Debug("Creating bitable field: app_token=%s, table_id=%s, name=%s, type=%d, options=%v", appToken, tableID, name, fieldType, options)
+
+	fieldBuilder := larkbitable.NewAppTableFieldBuilder().
+		FieldName(name).
+		Type(fieldType)
+	if len(options) > 0 {
+		opts := make([]*larkbitable.AppTableFieldPropertyOption, 0, len(options))
+		for _, o := range options {
+			opts = append(opts, larkbitable.NewAppTableFieldPropertyOptionBuilder().Name(o).Build())
+		}
+		fieldBuilder = fieldBuilder.Property(larkbitable.NewAppTableFieldPropertyBuilder().Options(opts).Build())
+	}
+
+	req := larkbitable.NewCreateAppTableFieldReqBuilder().
+		AppToken(appToken).
+		TableId(tableID).
+		ClientToken(uuid.New().String()).
+		AppTableField(fieldBuilder.Build()).
+		Build()
+
+	var resp *larkbitable.CreateAppTableFieldResp
+	err := s.withRetry(ctx, "create bitable field", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableField.Create(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		s.logFor(ctx).Error("Create bitable field API call failed: app_token=%s, table_id=%s, name=%s, error=%v", appToken, tableID, name, err)
+		return fmt.Errorf("create bitable field failed: %w", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Create bitable field failed: app_token=%s, table_id=%s, name=%s, code=%d, msg=%s", appToken, tableID, name, resp.Code, resp.Msg)
+		return bitableError("create bitable field", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	s.InvalidateTableFields(appToken, tableID)
+	return nil
+}
+
+// AddFieldOptions 为单选、多选字段追加选项，已有选项保持不变
+func (s *FeishuService) AddFieldOptions(ctx context.Context, appToken, tableID string, field *BitableField, names []string) error {
+	s.logFor(ctx).Debug("Adding bitable field options: app_token=%s, table_id=%s, field=%s, options=%v", appToken, tableID, field.Name, names)
+
+	// 更新接口会覆盖全部选项，需要带上已有选项的 ID
+	opts := make([]*larkbitable.AppTableFieldPropertyOption, 0, len(field.Options)+len(names))
+	for _, o := range field.Options {
+		opts = append(opts, larkbitable.NewAppTableFieldPropertyOptionBuilder().Id(o.ID).Name(o.Name).Color(o.Color).Build())
+	}
+	for _, name := range names {
+		opts = append(opts, larkbitable.NewAppTableFieldPropertyOptionBuilder().Name(name).Build())
+	}
+
+	req := larkbitable.NewUpdateAppTableFieldReqBuilder().
+		AppToken(appToken).
+		TableId(tableID).
+		FieldId(field.ID).
+		AppTableField(larkbitable.NewAppTableFieldBuilder().
+			FieldName(field.Name).
+			Type(field.Type).
+			Property(larkbitable.NewAppTableFieldPropertyBuilder().Options(opts).Build()).
+			Build()).
+		Build()
+
+	var resp *larkbitable.UpdateAppTableFieldResp
+	err := s.withRetry(ctx, "update bitable field", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableField.Update(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		s.logFor(ctx).Error("Update bitable field API call failed: app_token=%s, table_id=%s, field=%s, error=%v", appToken, tableID, field.Name, err)
+		return fmt.Errorf("update bitable field failed: %w", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Update bitable field failed: app_token=%s, table_id=%s, field=%s, code=%d, msg=%s", appToken, tableID, field.Name, resp.Code, resp.Msg)
+		return bitableError("update bitable field", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	s.InvalidateTableFields(appToken, tableID)
+	return nil
+}
+
+// toBitableField 将 SDK 字段结构转换为 BitableField
+func toBitableField(item *larkbitable.AppTableFieldForList) *BitableField {
+	field := &BitableField{}
+	if item.FieldId != nil {
+		field.ID = *item.FieldId
+	}
+	if item.FieldName != nil {
+		field.Name = *item.FieldName
+	}
+	if item.Type != nil {
+		field.Type = *item.Type
+	}
+	if item.UiType != nil {
+		field.UIType = *item.UiType
+	}
+	if item.Property != nil {
+		for _, opt := range item.Property.Options {
+			if opt == nil || opt.Name == nil {
+				continue
+			}
+			option := BitableFieldOption{Name: *opt.Name}
+			if opt.Id != nil {
+				option.ID = *opt.Id
+			}
+			if opt.Color != nil {
+				option.Color = *opt.Color
+			}
+			field.Options = append(field.Options, option)
+		}
+	}
+	return field
+}
@@ -0,0 +1,55 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+)
+
+// BotInfo 机器人自身的信息
+type BotInfo struct {
+	Name   string
+	OpenID string
+}
+
+// botInfoResp bot/v3/info 接口的响应
+type botInfoResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Bot  struct {
+		AppName string `json:"app_name"`
+		OpenID  string `json:"open_id"`
+	} `json:"bot"`
+}
+
+// GetBotInfo 获取机器人的名称和 open_id
+func (s *FeishuService) GetBotInfo(ctx context.Context) (*BotInfo, error) {
+	var body botInfoResp
+	var apiResp *larkcore.ApiResp
+	err := s.withRetry(ctx, "get bot info", func() (*larkcore.ApiResp, int, error) {
+		resp, err := s.client.Get(ctx, "/open-apis/bot/v3/info", nil, larkcore.AccessTokenTypeTenant)
+		if err != nil {
+			return nil, 0, err
+		}
+		apiResp = resp
+		body = botInfoResp{}
+		if err := json.Unmarshal(resp.RawBody, &body); err != nil {
+			return resp, 0, fmt.Errorf("parse bot info: %w", err)
+		}
+		return resp, body.Code, nil
+	})
+	if err != nil {
+		s.logFor(ctx).Error("Get bot info API call failed: %v", err)
+		return nil, fmt.Errorf("get bot info failed: %w", err)
+	}
+
+	if body.Code != 0 {
+		s.logFor(ctx).WithField("request_id", requestID(apiResp)).Error("Get bot info failed: code=%d, msg=%s", body.Code, body.Msg)
+		return nil, apiError("get bot info", apiResp, body.Code, body.Msg)
+	}
+
+	s.logFor(ctx).Debug("Fetched bot info: name=%s, open_id=%s", body.Bot.AppName, body.Bot.OpenID)
+	return &BotInfo{Name: body.Bot.AppName, OpenID: body.Bot.OpenID}, nil
+}
@@ -1,13 +1,22 @@
 package feishu
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/larksuite/oapi-sdk-go/v3"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
 	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+	larkdrive "github.com/larksuite/oapi-sdk-go/v3/service/drive/v1"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
 	larkwiki "github.com/larksuite/oapi-sdk-go/v3/service/wiki/v2"
 	"github.com/wyg1997/LedgerBot/config"
@@ -18,24 +27,49 @@ import (
 type FeishuService struct {
 	config *config.FeishuConfig
 	client *lark.Client
-	log    logger.Logger
-	ctx    context.Context
+
+	fieldsMu   sync.Mutex
+	fieldCache map[string][]*BitableField // 数据表字段缓存，键为 app_token:table_id
 }
 
 // NewFeishuService creates a new Feishu service
 func NewFeishuService(cfg *config.FeishuConfig) *FeishuService {
-	client := lark.NewClient(cfg.AppID, cfg.AppSecret)
+	var opts []lark.ClientOptionFunc
+	if cfg.APIBaseURL != "" {
+		opts = append(opts, lark.WithOpenBaseUrl(strings.TrimSuffix(cfg.APIBaseURL, "/")))
+	}
+	client := lark.NewClient(cfg.AppID, cfg.AppSecret, opts...)
 	return &FeishuService{
 		config: cfg,
 		client: client,
-		log:    logger.GetLogger(),
-		ctx:    context.Background(),
+
+		fieldCache: make(map[string][]*BitableField),
 	}
 }
 
 // ReplyMessage replies to a message in thread
-func (s *FeishuService) ReplyMessage(messageID string, content string, uuid string) error {
-	s.log.Debug("Will reply message: %s, message_id: %s", content, messageID)
+// 超过飞书文本消息大小限制时，按行拆分为多条依次回复
+func (s *FeishuService) ReplyMessage(ctx context.Context, messageID string, content string, uuid string) error {
+	chunks := splitTextMessage(content, textMessageMaxBytes)
+	if len(chunks) == 1 {
+		return s.replyText(ctx, messageID, content, uuid)
+	}
+
+	s.logFor(ctx).Info("Reply is too long, splitting into %d messages: message_id=%s", len(chunks), messageID)
+	for i, chunk := range chunks {
+		// 每条消息使用独立的 uuid，保证重试时的幂等性
+		chunkUUID := fmt.Sprintf("%s-%d", uuid, i+1)
+		text := fmt.Sprintf("%s\n(%d/%d)", chunk, i+1, len(chunks))
+		if err := s.replyText(ctx, messageID, text, chunkUUID); err != nil {
+			return fmt.Errorf("failed to reply chunk %d/%d: %w", i+1, len(chunks), err)
+		}
+	}
+	return nil
+}
+
+// replyText 回复单条文本消息
+func (s *FeishuService) replyText(ctx context.Context, messageID string, content string, uuid string) error {
+	s.logFor(ctx).Debug("Will reply message: %s, message_id: %s", content, messageID)
 
 	// Create a map with the text content and marshal it to JSON
 	messageMap := map[string]string{"text": content}
@@ -56,48 +90,168 @@ func (s *FeishuService) ReplyMessage(messageID string, content string, uuid stri
 		Build()
 
 	// Execute the request
-	resp, err := s.client.Im.Message.Reply(s.ctx, req)
+	var resp *larkim.ReplyMessageResp
+	err = s.withRetry(ctx, "reply message", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.Message.Reply(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to reply message: %v", err)
 	}
 
 	// Check response code
 	if !resp.Success() {
-		s.log.Error("Reply error: %s, code: %s", resp.Code, resp.Msg)
-		return fmt.Errorf("failed to reply message: code=%d, msg=%s", resp.Code, resp.Msg)
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Reply error: %s, code: %s", resp.Code, resp.Msg)
+		return apiError("reply message", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
-	s.log.Debug("Successfully replied to message %s", messageID)
+	s.logFor(ctx).Debug("Successfully replied to message %s", messageID)
 	return nil
 }
 
+// threadMessagesPageSize 拉取话题消息时的单页大小（接口上限 50）
+const threadMessagesPageSize = 50
+
 // ListMessagesByThread 查询指定 thread 下的历史消息（按创建时间升序）
-func (s *FeishuService) ListMessagesByThread(threadID string) ([]*larkim.Message, error) {
-	req := larkim.NewListMessageReqBuilder().
+// 从最新的消息开始翻页，最多返回 ThreadHistoryMaxMessages 条；超出上限时额外带上话题的第一条消息，
+// 保证仍能判断话题是否以@机器人开始
+func (s *FeishuService) ListMessagesByThread(ctx context.Context, threadID string) ([]*larkim.Message, error) {
+	maxMessages := s.config.ThreadHistoryMaxMessages
+	if maxMessages <= 0 {
+		maxMessages = threadMessagesPageSize
+	}
+
+	var messages []*larkim.Message
+	pageToken := ""
+	hasMore := false
+	for len(messages) < maxMessages {
+		items, next, more, err := s.listThreadPage(ctx, threadID, "ByCreateTimeDesc", threadMessagesPageSize, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, items...)
+		pageToken, hasMore = next, more
+		if !hasMore || pageToken == "" {
+			break
+		}
+	}
+	if len(messages) > maxMessages {
+		messages = messages[:maxMessages]
+		hasMore = true
+	}
+
+	// 翻转为按创建时间升序
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	if hasMore {
+		s.logFor(ctx).Info("Thread history exceeds %d messages, keeping the first and the latest messages: thread_id=%s", maxMessages, threadID)
+		first, _, _, err := s.listThreadPage(ctx, threadID, "ByCreateTimeAsc", 1, "")
+		if err != nil {
+			return nil, err
+		}
+		messages = append(first, messages...)
+	}
+
+	return messages, nil
+}
+
+// listThreadPage 拉取一页话题消息
+func (s *FeishuService) listThreadPage(ctx context.Context, threadID, sortType string, pageSize int, pageToken string) ([]*larkim.Message, string, bool, error) {
+	reqBuilder := larkim.NewListMessageReqBuilder().
 		ContainerIdType("thread").
 		ContainerId(threadID).
-		SortType("ByCreateTimeAsc").
-		PageSize(50).
+		SortType(sortType).
+		PageSize(pageSize)
+	if pageToken != "" {
+		reqBuilder = reqBuilder.PageToken(pageToken)
+	}
+	req := reqBuilder.Build()
+
+	var resp *larkim.ListMessageResp
+	err := s.withRetry(ctx, "list thread messages", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.V1.Message.List(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return nil, "", false, fmt.Errorf("list thread messages: %w", err)
+	}
+	if !resp.Success() {
+		return nil, "", false, apiError("list thread messages", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	if resp.Data == nil {
+		return nil, "", false, nil
+	}
+	pageToken = ""
+	if resp.Data.PageToken != nil {
+		pageToken = *resp.Data.PageToken
+	}
+	hasMore := resp.Data.HasMore != nil && *resp.Data.HasMore
+	return resp.Data.Items, pageToken, hasMore, nil
+}
+
+// GetMessageResource 下载消息中的资源文件（图片、音频、文件等）
+// resourceType 为 "image" 或 "file"（音频、视频、文件均使用 file）
+func (s *FeishuService) GetMessageResource(ctx context.Context, messageID, fileKey, resourceType string) ([]byte, error) {
+	s.logFor(ctx).Debug("Downloading message resource: message_id=%s, file_key=%s, type=%s", messageID, fileKey, resourceType)
+
+	req := larkim.NewGetMessageResourceReqBuilder().
+		MessageId(messageID).
+		FileKey(fileKey).
+		Type(resourceType).
 		Build()
 
-	resp, err := s.client.Im.V1.Message.List(s.ctx, req)
+	resp, err := s.client.Im.V1.MessageResource.Get(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("list thread messages: %w", err)
+		return nil, fmt.Errorf("get message resource: %w", err)
 	}
 	if !resp.Success() {
-		return nil, fmt.Errorf("list thread messages failed: code=%d msg=%s", resp.Code, resp.Msg)
+		return nil, apiError("get message resource", resp.ApiResp, resp.Code, resp.Msg)
+	}
+	if resp.File == nil {
+		return nil, fmt.Errorf("get message resource success but file is empty")
 	}
 
-	if resp.Data == nil || len(resp.Data.Items) == 0 {
-		return []*larkim.Message{}, nil
+	data, err := io.ReadAll(resp.File)
+	if err != nil {
+		return nil, fmt.Errorf("read message resource: %w", err)
+	}
+
+	s.logFor(ctx).Debug("Successfully downloaded message resource: message_id=%s, file_key=%s, size=%d", messageID, fileKey, len(data))
+	return data, nil
+}
+
+// SendMessage sends a message to a user identified by an open_id or, when it starts with "on_", a union_id
+func (s *FeishuService) SendMessage(ctx context.Context, openID string, content string) error {
+	return s.sendText(ctx, userIDType(openID), openID, content)
+}
+
+// userIDType 按前缀区分用户 ID 的类型：union_id 以 on_ 开头，open_id 以 ou_ 开头
+func userIDType(id string) string {
+	if strings.HasPrefix(id, "on_") {
+		return "union_id"
 	}
+	return "open_id"
+}
 
-	return resp.Data.Items, nil
+// SendMessageToChat 向群聊主动发送文本消息
+func (s *FeishuService) SendMessageToChat(ctx context.Context, chatID string, content string) error {
+	return s.sendText(ctx, "chat_id", chatID, content)
 }
 
-// SendMessage sends a message to a user
-func (s *FeishuService) SendMessage(openID string, content string) error {
-	s.log.Debug("Will send message: %s to %s", content, openID)
+// sendText 主动发送文本消息，receiveIDType 为 open_id、union_id 或 chat_id
+func (s *FeishuService) sendText(ctx context.Context, receiveIDType, receiveID string, content string) error {
+	s.logFor(ctx).Debug("Will send message: %s to %s %s", content, receiveIDType, receiveID)
 
 	// Create a map with the text content and marshal it to JSON
 	messageMap := map[string]string{"text": content}
@@ -106,28 +260,37 @@ func (s *FeishuService) SendMessage(openID string, content string) error {
 		return fmt.Errorf("failed to marshal message content: %v", err)
 	}
 
-	// Create message request
+	// Create message request, uuid 保证重试时不会重复发送
 	req := larkim.NewCreateMessageReqBuilder().
-		ReceiveIdType("open_id").
+		ReceiveIdType(receiveIDType).
 		Body(larkim.NewCreateMessageReqBodyBuilder().
-			ReceiveId(openID).
+			ReceiveId(receiveID).
 			Content(string(textContent)).
 			MsgType("text").
+			Uuid(uuid.New().String()).
 			Build()).
 		Build()
 
 	// Execute the request
-	resp, err := s.client.Im.Message.Create(s.ctx, req)
+	var resp *larkim.CreateMessageResp
+	err = s.withRetry(ctx, "send message", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.Message.Create(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
 
 	// Check response code
 	if !resp.Success() {
-		return fmt.Errorf("failed to send message: code=%d, msg=%s", resp.Code, resp.Msg)
+		return apiError("send message", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
-	s.log.Debug("Successfully sent message to user %s", openID)
+	s.logFor(ctx).Debug("Successfully sent message to %s %s", receiveIDType, receiveID)
 	return nil
 }
 
@@ -149,47 +312,123 @@ type MessageCallback struct {
 }
 
 // ProcessMessageCallback processes incoming message callback
-func (s *FeishuService) ProcessMessageCallback(callback MessageCallback) (string, error) {
+func (s *FeishuService) ProcessMessageCallback(ctx context.Context, callback MessageCallback) (string, error) {
 	// Avoid using contacts API due to permission requirements
 	return "success", nil
 }
 
 // AddRecordToBitable 使用 Bitable SDK 创建记录
-func (s *FeishuService) AddRecordToBitable(appToken, tableID string, fields map[string]interface{}) (string, error) {
-	s.log.Debug("Creating bitable record: app_token=%s, table_id=%s, fields=%+v", appToken, tableID, fields)
+func (s *FeishuService) AddRecordToBitable(ctx context.Context, appToken, tableID string, fields map[string]interface{}) (string, error) {
+	s.logFor(ctx).Debug("Creating bitable record: app_token=%s, table_id=%s, fields=%+v", appToken, tableID, fields)
 
+	// client_token 保证重试时不会重复创建记录
 	req := larkbitable.NewCreateAppTableRecordReqBuilder().
 		AppToken(appToken).
 		TableId(tableID).
+		ClientToken(uuid.New().String()).
 		AppTableRecord(larkbitable.NewAppTableRecordBuilder().
 			Fields(fields).
 			Build()).
 		Build()
 
-	resp, err := s.client.Bitable.V1.AppTableRecord.Create(s.ctx, req)
+	var resp *larkbitable.CreateAppTableRecordResp
+	err := s.withRetry(ctx, "create bitable record", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableRecord.Create(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
-		s.log.Error("Create bitable record API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
+		s.logFor(ctx).Error("Create bitable record API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
 		return "", fmt.Errorf("create bitable record failed: %w", err)
 	}
 
 	if !resp.Success() {
-		s.log.Error("Create bitable record failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
-		return "", fmt.Errorf("create bitable record failed: code=%d msg=%s", resp.Code, resp.Msg)
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Create bitable record failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
+		return "", bitableError("create bitable record", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
 	if resp.Data == nil || resp.Data.Record == nil || resp.Data.Record.RecordId == nil {
-		s.log.Error("Create bitable record success but record_id is empty: app_token=%s, table_id=%s", appToken, tableID)
+		s.logFor(ctx).Error("Create bitable record success but record_id is empty: app_token=%s, table_id=%s", appToken, tableID)
 		return "", fmt.Errorf("create bitable record success but record_id is empty")
 	}
 
 	recordID := *resp.Data.Record.RecordId
-	s.log.Debug("Successfully created bitable record: record_id=%s, app_token=%s, table_id=%s", recordID, appToken, tableID)
+	s.logFor(ctx).Debug("Successfully created bitable record: record_id=%s, app_token=%s, table_id=%s", recordID, appToken, tableID)
 	return recordID, nil
 }
 
+// bitableMaxBatchCreate 批量创建接口单次最多写入的记录数
+const bitableMaxBatchCreate = 500
+
+// BatchAddRecordsToBitable 使用 Bitable SDK 批量创建记录，返回的 record_id 与 fieldsList 顺序一致
+// 单次请求内的记录要么全部成功要么全部失败；超过单次上限时分批写入，出错时前面批次的记录已经创建
+func (s *FeishuService) BatchAddRecordsToBitable(ctx context.Context, appToken, tableID string, fieldsList []map[string]interface{}) ([]string, error) {
+	s.logFor(ctx).Debug("Batch creating bitable records: app_token=%s, table_id=%s, count=%d", appToken, tableID, len(fieldsList))
+
+	recordIDs := make([]string, 0, len(fieldsList))
+	for start := 0; start < len(fieldsList); start += bitableMaxBatchCreate {
+		end := start + bitableMaxBatchCreate
+		if end > len(fieldsList) {
+			end = len(fieldsList)
+		}
+
+		records := make([]*larkbitable.AppTableRecord, 0, end-start)
+		for _, fields := range fieldsList[start:end] {
+			records = append(records, larkbitable.NewAppTableRecordBuilder().Fields(fields).Build())
+		}
+
+		// client_token 保证重试时不会重复创建记录
+		req := larkbitable.NewBatchCreateAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableID).
+			ClientToken(uuid.New().String()).
+			Body(larkbitable.NewBatchCreateAppTableRecordReqBodyBuilder().
+				Records(records).
+				Build()).
+			Build()
+
+		var resp *larkbitable.BatchCreateAppTableRecordResp
+		err := s.withRetry(ctx, "batch create bitable records", func() (*larkcore.ApiResp, int, error) {
+			var err error
+			resp, err = s.client.Bitable.V1.AppTableRecord.BatchCreate(ctx, req)
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.ApiResp, resp.Code, nil
+		})
+		if err != nil {
+			s.logFor(ctx).Error("Batch create bitable records API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
+			return recordIDs, fmt.Errorf("batch create bitable records failed: %w", err)
+		}
+
+		if !resp.Success() {
+			s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Batch create bitable records failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
+			return recordIDs, bitableError("batch create bitable records", resp.ApiResp, resp.Code, resp.Msg)
+		}
+
+		if resp.Data == nil || len(resp.Data.Records) != end-start {
+			s.logFor(ctx).Error("Batch create bitable records returned unexpected record count: app_token=%s, table_id=%s, want=%d", appToken, tableID, end-start)
+			return recordIDs, fmt.Errorf("batch create bitable records returned unexpected record count")
+		}
+		for _, rec := range resp.Data.Records {
+			recordID := ""
+			if rec != nil && rec.RecordId != nil {
+				recordID = *rec.RecordId
+			}
+			recordIDs = append(recordIDs, recordID)
+		}
+	}
+
+	s.logFor(ctx).Debug("Successfully batch created bitable records: count=%d, app_token=%s, table_id=%s", len(recordIDs), appToken, tableID)
+	return recordIDs, nil
+}
+
 // UpdateRecordToBitable 使用 Bitable SDK 更新记录
-func (s *FeishuService) UpdateRecordToBitable(appToken, tableID, recordID string, fields map[string]interface{}) (string, error) {
-	s.log.Debug("Updating bitable record: app_token=%s, table_id=%s, record_id=%s, fields=%+v", appToken, tableID, recordID, fields)
+func (s *FeishuService) UpdateRecordToBitable(ctx context.Context, appToken, tableID, recordID string, fields map[string]interface{}) (string, error) {
+	s.logFor(ctx).Debug("Updating bitable record: app_token=%s, table_id=%s, record_id=%s, fields=%+v", appToken, tableID, recordID, fields)
 
 	req := larkbitable.NewUpdateAppTableRecordReqBuilder().
 		AppToken(appToken).
@@ -200,30 +439,41 @@ func (s *FeishuService) UpdateRecordToBitable(appToken, tableID, recordID string
 			Build()).
 		Build()
 
-	resp, err := s.client.Bitable.V1.AppTableRecord.Update(s.ctx, req)
+	var resp *larkbitable.UpdateAppTableRecordResp
+	err := s.withRetry(ctx, "update bitable record", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableRecord.Update(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
-		s.log.Error("Update bitable record API call failed: app_token=%s, table_id=%s, record_id=%s, error=%v", appToken, tableID, recordID, err)
+		s.logFor(ctx).Error("Update bitable record API call failed: app_token=%s, table_id=%s, record_id=%s, error=%v", appToken, tableID, recordID, err)
 		return "", fmt.Errorf("update bitable record failed: %w", err)
 	}
 
 	if !resp.Success() {
-		s.log.Error("Update bitable record failed: app_token=%s, table_id=%s, record_id=%s, code=%d, msg=%s", appToken, tableID, recordID, resp.Code, resp.Msg)
-		return "", fmt.Errorf("update bitable record failed: code=%d msg=%s", resp.Code, resp.Msg)
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Update bitable record failed: app_token=%s, table_id=%s, record_id=%s, code=%d, msg=%s", appToken, tableID, recordID, resp.Code, resp.Msg)
+		if resp.Code == codeRecordNotFound {
+			return "", recordNotFoundError("update bitable record", resp.ApiResp, resp.Code, resp.Msg, recordID)
+		}
+		return "", bitableError("update bitable record", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
 	if resp.Data == nil || resp.Data.Record == nil || resp.Data.Record.RecordId == nil {
-		s.log.Error("Update bitable record success but record_id is empty: app_token=%s, table_id=%s, record_id=%s", appToken, tableID, recordID)
+		s.logFor(ctx).Error("Update bitable record success but record_id is empty: app_token=%s, table_id=%s, record_id=%s", appToken, tableID, recordID)
 		return "", fmt.Errorf("update bitable record success but record_id is empty")
 	}
 
 	updatedRecordID := *resp.Data.Record.RecordId
-	s.log.Debug("Successfully updated bitable record: record_id=%s, app_token=%s, table_id=%s", updatedRecordID, appToken, tableID)
+	s.logFor(ctx).Debug("Successfully updated bitable record: record_id=%s, app_token=%s, table_id=%s", updatedRecordID, appToken, tableID)
 	return updatedRecordID, nil
 }
 
 // BatchGetRecordsToBitable 使用 Bitable SDK 批量获取记录
-func (s *FeishuService) BatchGetRecordsToBitable(appToken, tableID string, recordIDs []string) ([]map[string]interface{}, error) {
-	s.log.Debug("Batch getting bitable records: app_token=%s, table_id=%s, record_ids=%v", appToken, tableID, recordIDs)
+func (s *FeishuService) BatchGetRecordsToBitable(ctx context.Context, appToken, tableID string, recordIDs []string) ([]map[string]interface{}, error) {
+	s.logFor(ctx).Debug("Batch getting bitable records: app_token=%s, table_id=%s, record_ids=%v", appToken, tableID, recordIDs)
 
 	if len(recordIDs) == 0 {
 		return []map[string]interface{}{}, nil
@@ -238,19 +488,27 @@ func (s *FeishuService) BatchGetRecordsToBitable(appToken, tableID string, recor
 			Build()).
 		Build()
 
-	resp, err := s.client.Bitable.V1.AppTableRecord.BatchGet(s.ctx, req)
+	var resp *larkbitable.BatchGetAppTableRecordResp
+	err := s.withRetry(ctx, "batch get bitable records", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableRecord.BatchGet(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
-		s.log.Error("BatchGet bitable records API call failed: app_token=%s, table_id=%s, record_ids=%v, error=%v", appToken, tableID, recordIDs, err)
+		s.logFor(ctx).Error("BatchGet bitable records API call failed: app_token=%s, table_id=%s, record_ids=%v, error=%v", appToken, tableID, recordIDs, err)
 		return nil, fmt.Errorf("batch get bitable records failed: %w", err)
 	}
 
 	if !resp.Success() {
-		s.log.Error("BatchGet bitable records failed: app_token=%s, table_id=%s, record_ids=%v, code=%d, msg=%s", appToken, tableID, recordIDs, resp.Code, resp.Msg)
-		return nil, fmt.Errorf("batch get bitable records failed: code=%d msg=%s", resp.Code, resp.Msg)
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("BatchGet bitable records failed: app_token=%s, table_id=%s, record_ids=%v, code=%d, msg=%s", appToken, tableID, recordIDs, resp.Code, resp.Msg)
+		return nil, bitableError("batch get bitable records", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
 	if resp.Data == nil || resp.Data.Records == nil {
-		s.log.Error("BatchGet bitable records success but records is empty: app_token=%s, table_id=%s, record_ids=%v", appToken, tableID, recordIDs)
+		s.logFor(ctx).Error("BatchGet bitable records success but records is empty: app_token=%s, table_id=%s, record_ids=%v", appToken, tableID, recordIDs)
 		return []map[string]interface{}{}, nil
 	}
 
@@ -267,29 +525,45 @@ func (s *FeishuService) BatchGetRecordsToBitable(appToken, tableID string, recor
 		records = append(records, record)
 	}
 
-	s.log.Debug("Successfully batch got bitable records: count=%d, app_token=%s, table_id=%s", len(records), appToken, tableID)
+	s.logFor(ctx).Debug("Successfully batch got bitable records: count=%d, app_token=%s, table_id=%s", len(records), appToken, tableID)
 	return records, nil
 }
 
+// codeRecordNotFound 多维表格接口返回的记录不存在错误码（RecordIdNotFound）
+const codeRecordNotFound = 1254043
+
+// ErrRecordNotFound 多维表格中不存在指定的记录
+var ErrRecordNotFound = errors.New("record not found")
+
+// appTokenErrorCodes 多维表格 app_token 无效或不存在的错误码（WrongBaseToken、BaseTokenNotFound、NOTEXIST）
+var appTokenErrorCodes = map[int]bool{
+	1254003: true,
+	1254040: true,
+	91402:   true,
+}
+
+// ErrAppTokenInvalid 多维表格的 app_token 无效，wiki 节点缓存的 app_token 可能已过时
+var ErrAppTokenInvalid = errors.New("bitable app token invalid")
+
 // GetRecordToBitable 使用 Bitable SDK 通过 record_id 获取单条记录（使用 BatchGet）
-func (s *FeishuService) GetRecordToBitable(appToken, tableID, recordID string) (map[string]interface{}, error) {
-	s.log.Debug("Getting bitable record: app_token=%s, table_id=%s, record_id=%s", appToken, tableID, recordID)
+func (s *FeishuService) GetRecordToBitable(ctx context.Context, appToken, tableID, recordID string) (map[string]interface{}, error) {
+	s.logFor(ctx).Debug("Getting bitable record: app_token=%s, table_id=%s, record_id=%s", appToken, tableID, recordID)
 
-	records, err := s.BatchGetRecordsToBitable(appToken, tableID, []string{recordID})
+	records, err := s.BatchGetRecordsToBitable(ctx, appToken, tableID, []string{recordID})
 	if err != nil {
 		return nil, err
 	}
 
 	if len(records) == 0 {
-		return nil, fmt.Errorf("record not found: %s", recordID)
+		return nil, fmt.Errorf("%w: %s", ErrRecordNotFound, recordID)
 	}
 
 	return records[0], nil
 }
 
 // DeleteRecordToBitable 使用 Bitable SDK 删除记录
-func (s *FeishuService) DeleteRecordToBitable(appToken, tableID, recordID string) error {
-	s.log.Debug("Deleting bitable record: app_token=%s, table_id=%s, record_id=%s", appToken, tableID, recordID)
+func (s *FeishuService) DeleteRecordToBitable(ctx context.Context, appToken, tableID, recordID string) error {
+	s.logFor(ctx).Debug("Deleting bitable record: app_token=%s, table_id=%s, record_id=%s", appToken, tableID, recordID)
 
 	req := larkbitable.NewBatchDeleteAppTableRecordReqBuilder().
 		AppToken(appToken).
@@ -299,35 +573,323 @@ func (s *FeishuService) DeleteRecordToBitable(appToken, tableID, recordID string
 			Build()).
 		Build()
 
-	resp, err := s.client.Bitable.V1.AppTableRecord.BatchDelete(s.ctx, req)
+	var resp *larkbitable.BatchDeleteAppTableRecordResp
+	err := s.withRetry(ctx, "delete bitable record", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableRecord.BatchDelete(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
-		s.log.Error("Delete bitable record API call failed: app_token=%s, table_id=%s, record_id=%s, error=%v", appToken, tableID, recordID, err)
+		s.logFor(ctx).Error("Delete bitable record API call failed: app_token=%s, table_id=%s, record_id=%s, error=%v", appToken, tableID, recordID, err)
 		return fmt.Errorf("delete bitable record failed: %w", err)
 	}
 
 	if !resp.Success() {
-		s.log.Error("Delete bitable record failed: app_token=%s, table_id=%s, record_id=%s, code=%d, msg=%s", appToken, tableID, recordID, resp.Code, resp.Msg)
-		return fmt.Errorf("delete bitable record failed: code=%d msg=%s", resp.Code, resp.Msg)
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Delete bitable record failed: app_token=%s, table_id=%s, record_id=%s, code=%d, msg=%s", appToken, tableID, recordID, resp.Code, resp.Msg)
+		if resp.Code == codeRecordNotFound {
+			return recordNotFoundError("delete bitable record", resp.ApiResp, resp.Code, resp.Msg, recordID)
+		}
+		return bitableError("delete bitable record", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
-	s.log.Debug("Successfully deleted bitable record: record_id=%s, app_token=%s, table_id=%s", recordID, appToken, tableID)
+	s.logFor(ctx).Debug("Successfully deleted bitable record: record_id=%s, app_token=%s, table_id=%s", recordID, appToken, tableID)
 	return nil
 }
 
-func (s *FeishuService) ListRecords(appToken, tableToken string, pageSize, pageToken int) ([]map[string]interface{}, error) {
-	// TODO: Implement with SDK
-	return nil, fmt.Errorf("ListRecords not yet implemented with SDK")
+// bitableMaxBatchDelete 批量删除接口单次最多删除的记录数
+const bitableMaxBatchDelete = 500
+
+// BatchDeleteRecordsToBitable 使用 Bitable SDK 批量删除记录，返回已删除的记录数
+// 超过单次上限时分批删除，出错时前面批次的记录已经删除
+func (s *FeishuService) BatchDeleteRecordsToBitable(ctx context.Context, appToken, tableID string, recordIDs []string) (int, error) {
+	s.logFor(ctx).Debug("Batch deleting bitable records: app_token=%s, table_id=%s, count=%d", appToken, tableID, len(recordIDs))
+
+	deleted := 0
+	for start := 0; start < len(recordIDs); start += bitableMaxBatchDelete {
+		end := start + bitableMaxBatchDelete
+		if end > len(recordIDs) {
+			end = len(recordIDs)
+		}
+
+		req := larkbitable.NewBatchDeleteAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableID).
+			Body(larkbitable.NewBatchDeleteAppTableRecordReqBodyBuilder().
+				Records(recordIDs[start:end]).
+				Build()).
+			Build()
+
+		var resp *larkbitable.BatchDeleteAppTableRecordResp
+		err := s.withRetry(ctx, "batch delete bitable records", func() (*larkcore.ApiResp, int, error) {
+			var err error
+			resp, err = s.client.Bitable.V1.AppTableRecord.BatchDelete(ctx, req)
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.ApiResp, resp.Code, nil
+		})
+		if err != nil {
+			s.logFor(ctx).Error("Batch delete bitable records API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
+			return deleted, fmt.Errorf("batch delete bitable records failed: %w", err)
+		}
+
+		if !resp.Success() {
+			s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Batch delete bitable records failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
+			if resp.Code == codeRecordNotFound {
+				return deleted, recordNotFoundError("batch delete bitable records", resp.ApiResp, resp.Code, resp.Msg, resp.Msg)
+			}
+			return deleted, bitableError("batch delete bitable records", resp.ApiResp, resp.Code, resp.Msg)
+		}
+		deleted += end - start
+	}
+
+	s.logFor(ctx).Debug("Successfully batch deleted bitable records: count=%d, app_token=%s, table_id=%s", deleted, appToken, tableID)
+	return deleted, nil
+}
+
+// bitableMaxPageSize 多维表格接口单页最多返回的记录数
+const bitableMaxPageSize = 500
+
+// recordToMap 将多维表格记录转换为 map，与 SearchRecords 返回的结构一致
+func recordToMap(rec *larkbitable.AppTableRecord) map[string]interface{} {
+	record := make(map[string]interface{})
+	if rec.RecordId != nil {
+		record["_id"] = *rec.RecordId
+		record["record_id"] = *rec.RecordId
+	}
+	if rec.Fields != nil {
+		record["fields"] = rec.Fields
+	}
+	return record
+}
+
+// ListRecords 使用 Bitable SDK 列出记录
+// pageSize 超过单页上限时会自动翻页直到取满；返回的 pageToken 为空表示没有更多数据
+func (s *FeishuService) ListRecords(ctx context.Context, appToken, tableToken string, pageSize int, pageToken string) ([]map[string]interface{}, string, error) {
+	s.logFor(ctx).Debug("Listing bitable records: app_token=%s, table_id=%s, page_size=%d, page_token=%s", appToken, tableToken, pageSize, pageToken)
+
+	if pageSize <= 0 {
+		pageSize = bitableMaxPageSize
+	}
+
+	var records []map[string]interface{}
+	for {
+		batch := pageSize - len(records)
+		if batch > bitableMaxPageSize {
+			batch = bitableMaxPageSize
+		}
+
+		reqBuilder := larkbitable.NewListAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableToken).
+			PageSize(batch)
+		if pageToken != "" {
+			reqBuilder = reqBuilder.PageToken(pageToken)
+		}
+
+		req := reqBuilder.Build()
+		var resp *larkbitable.ListAppTableRecordResp
+		err := s.withRetry(ctx, "list bitable records", func() (*larkcore.ApiResp, int, error) {
+			var err error
+			resp, err = s.client.Bitable.V1.AppTableRecord.List(ctx, req)
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.ApiResp, resp.Code, nil
+		})
+		if err != nil {
+			s.logFor(ctx).Error("List bitable records API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableToken, err)
+			return nil, "", fmt.Errorf("list bitable records failed: %w", err)
+		}
+
+		if !resp.Success() {
+			s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("List bitable records failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableToken, resp.Code, resp.Msg)
+			return nil, "", bitableError("list bitable records", resp.ApiResp, resp.Code, resp.Msg)
+		}
+
+		pageToken = ""
+		if resp.Data != nil {
+			for _, item := range resp.Data.Items {
+				records = append(records, recordToMap(item))
+			}
+			if resp.Data.HasMore != nil && *resp.Data.HasMore && resp.Data.PageToken != nil {
+				pageToken = *resp.Data.PageToken
+			}
+		}
+
+		if pageToken == "" || len(records) >= pageSize {
+			break
+		}
+	}
+
+	s.logFor(ctx).Debug("Successfully listed bitable records: count=%d, has_more=%v, app_token=%s, table_id=%s", len(records), pageToken != "", appToken, tableToken)
+	return records, pageToken, nil
+}
+
+// ListRecordsWithFilter 使用 Bitable SDK 按条件搜索记录
+// filter 格式：
+//
+//	{
+//	  "field_names": []string{...},
+//	  "automatic_fields": false,
+//	  "page_size": 100,
+//	  "filter": {"conjunction": "and", "conditions": []map[string]interface{}{{"field_name": ..., "operator": ..., "value": []string{...}}}}
+//	}
+//
+// page_size 为希望获取的记录总数，超过单页上限时会自动翻页
+func (s *FeishuService) ListRecordsWithFilter(ctx context.Context, appToken, tableToken string, filter map[string]interface{}) ([]map[string]interface{}, error) {
+	s.logFor(ctx).Debug("Listing bitable records with filter: app_token=%s, table_id=%s, filter=%v", appToken, tableToken, filter)
+
+	bodyBuilder := larkbitable.NewSearchAppTableRecordReqBodyBuilder()
+
+	// "_id" 不是表格字段，record_id 总会随记录返回
+	var fieldNames []string
+	for _, name := range toStringSlice(filter["field_names"]) {
+		if name != "_id" {
+			fieldNames = append(fieldNames, name)
+		}
+	}
+	if len(fieldNames) > 0 {
+		bodyBuilder = bodyBuilder.FieldNames(fieldNames)
+	}
+	if automatic, ok := filter["automatic_fields"].(bool); ok {
+		bodyBuilder = bodyBuilder.AutomaticFields(automatic)
+	}
+	if filterInfo := buildFilterInfo(filter["filter"]); filterInfo != nil {
+		bodyBuilder = bodyBuilder.Filter(filterInfo)
+	}
+	body := bodyBuilder.Build()
+
+	limit, _ := filter["page_size"].(int)
+	if limit <= 0 {
+		limit = bitableMaxPageSize
+	}
+
+	var records []map[string]interface{}
+	pageToken := ""
+	for {
+		batch := limit - len(records)
+		if batch > bitableMaxPageSize {
+			batch = bitableMaxPageSize
+		}
+
+		reqBuilder := larkbitable.NewSearchAppTableRecordReqBuilder().
+			AppToken(appToken).
+			TableId(tableToken).
+			PageSize(batch)
+		if pageToken != "" {
+			reqBuilder = reqBuilder.PageToken(pageToken)
+		}
+
+		req := reqBuilder.Body(body).Build()
+		var resp *larkbitable.SearchAppTableRecordResp
+		err := s.withRetry(ctx, "search bitable records", func() (*larkcore.ApiResp, int, error) {
+			var err error
+			resp, err = s.client.Bitable.V1.AppTableRecord.Search(ctx, req)
+			if err != nil {
+				return nil, 0, err
+			}
+			return resp.ApiResp, resp.Code, nil
+		})
+		if err != nil {
+			s.logFor(ctx).Error("Search bitable records with filter API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableToken, err)
+			return nil, fmt.Errorf("list bitable records with filter failed: %w", err)
+		}
+
+		if !resp.Success() {
+			s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Search bitable records with filter failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableToken, resp.Code, resp.Msg)
+			return nil, bitableError("list bitable records with filter", resp.ApiResp, resp.Code, resp.Msg)
+		}
+
+		pageToken = ""
+		if resp.Data != nil {
+			for _, item := range resp.Data.Items {
+				records = append(records, recordToMap(item))
+			}
+			if resp.Data.HasMore != nil && *resp.Data.HasMore && resp.Data.PageToken != nil {
+				pageToken = *resp.Data.PageToken
+			}
+		}
+
+		if pageToken == "" || len(records) >= limit {
+			break
+		}
+	}
+
+	s.logFor(ctx).Debug("Successfully listed bitable records with filter: count=%d, app_token=%s, table_id=%s", len(records), appToken, tableToken)
+	return records, nil
+}
+
+// buildFilterInfo 将 {"conjunction": ..., "conditions": [...]} 转换为 FilterInfo，没有条件时返回 nil
+func buildFilterInfo(raw interface{}) *larkbitable.FilterInfo {
+	filterMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var rawConditions []map[string]interface{}
+	switch v := filterMap["conditions"].(type) {
+	case []map[string]interface{}:
+		rawConditions = v
+	case []interface{}:
+		for _, c := range v {
+			if m, ok := c.(map[string]interface{}); ok {
+				rawConditions = append(rawConditions, m)
+			}
+		}
+	}
+
+	conditions := make([]*larkbitable.Condition, 0, len(rawConditions))
+	for _, c := range rawConditions {
+		fieldName, _ := c["field_name"].(string)
+		operator, _ := c["operator"].(string)
+		if fieldName == "" || operator == "" {
+			continue
+		}
+		conditions = append(conditions, larkbitable.NewConditionBuilder().
+			FieldName(fieldName).
+			Operator(operator).
+			Value(toStringSlice(c["value"])).
+			Build())
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	conjunction, _ := filterMap["conjunction"].(string)
+	if conjunction == "" {
+		conjunction = "and"
+	}
+
+	return larkbitable.NewFilterInfoBuilder().
+		Conjunction(conjunction).
+		Conditions(conditions).
+		Build()
 }
 
-func (s *FeishuService) ListRecordsWithFilter(appToken, tableToken string, filter map[string]interface{}) ([]map[string]interface{}, error) {
-	// TODO: Implement with SDK
-	return nil, fmt.Errorf("ListRecordsWithFilter not yet implemented with SDK")
+// toStringSlice 将 []string 或 []interface{} 转换为 []string
+func toStringSlice(raw interface{}) []string {
+	switch v := raw.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, fmt.Sprintf("%v", item))
+		}
+		return result
+	}
+	return nil
 }
 
 // SearchRecords 使用 Bitable SDK 搜索记录
-func (s *FeishuService) SearchRecords(appToken, tableID string, startTime, endTime int64, fieldNames []string, pageSize int) ([]map[string]interface{}, int, string, error) {
-	s.log.Debug("Searching bitable records: app_token=%s, table_id=%s, start_time=%d (%s), end_time=%d (%s), page_size=%d, field_names=%v", 
-		appToken, tableID, startTime, time.UnixMilli(startTime).Format("2006-01-02 15:04:05"), endTime, time.UnixMilli(endTime).Format("2006-01-02 15:04:05"), pageSize, fieldNames)
+// userName 不为空时只返回该用户记录的账单；pageToken 为空时从第一页开始；返回的 pageToken 为空表示没有更多数据
+func (s *FeishuService) SearchRecords(ctx context.Context, appToken, tableID string, userName string, startTime, endTime int64, fieldNames []string, pageSize int, pageToken string) ([]map[string]interface{}, int, string, error) {
+	s.logFor(ctx).Debug("Searching bitable records: app_token=%s, table_id=%s, user_name=%s, start_time=%d (%s), end_time=%d (%s), page_size=%d, page_token=%s, field_names=%v", 
+		appToken, tableID, userName, startTime, time.UnixMilli(startTime).Format("2006-01-02 15:04:05"), endTime, time.UnixMilli(endTime).Format("2006-01-02 15:04:05"), pageSize, pageToken, fieldNames)
 
 	// Build filter conditions for date range
 	conditions := []*larkbitable.Condition{
@@ -343,6 +905,23 @@ func (s *FeishuService) SearchRecords(appToken, tableID string, startTime, endTi
 			Build(),
 	}
 
+	if userName != "" {
+		conditions = append(conditions, larkbitable.NewConditionBuilder().
+			FieldName(s.config.FieldUserName).
+			Operator("is").
+			Value([]string{userName}).
+			Build())
+	}
+
+	// 软删除的记录不参与查询
+	if s.config.SoftDelete && s.config.FieldDeletedAt != "" {
+		conditions = append(conditions, larkbitable.NewConditionBuilder().
+			FieldName(s.config.FieldDeletedAt).
+			Operator("isEmpty").
+			Value([]string{}).
+			Build())
+	}
+
 	// Build sort by date descending
 	sorts := []*larkbitable.Sort{
 		larkbitable.NewSortBuilder().
@@ -351,10 +930,15 @@ func (s *FeishuService) SearchRecords(appToken, tableID string, startTime, endTi
 			Build(),
 	}
 
-	req := larkbitable.NewSearchAppTableRecordReqBuilder().
+	reqBuilder := larkbitable.NewSearchAppTableRecordReqBuilder().
 		AppToken(appToken).
 		TableId(tableID).
-		PageSize(pageSize).
+		PageSize(pageSize)
+	if pageToken != "" {
+		reqBuilder = reqBuilder.PageToken(pageToken)
+	}
+
+	req := reqBuilder.
 		Body(larkbitable.NewSearchAppTableRecordReqBodyBuilder().
 			FieldNames(fieldNames).
 			Sort(sorts).
@@ -366,63 +950,95 @@ func (s *FeishuService) SearchRecords(appToken, tableID string, startTime, endTi
 			Build()).
 		Build()
 
-	resp, err := s.client.Bitable.V1.AppTableRecord.Search(s.ctx, req)
+	var resp *larkbitable.SearchAppTableRecordResp
+	err := s.withRetry(ctx, "search bitable records", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Bitable.V1.AppTableRecord.Search(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
 	if err != nil {
-		s.log.Error("Search bitable records API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
+		s.logFor(ctx).Error("Search bitable records API call failed: app_token=%s, table_id=%s, error=%v", appToken, tableID, err)
 		return nil, 0, "", fmt.Errorf("search bitable records failed: %w", err)
 	}
 
 	if !resp.Success() {
-		s.log.Error("Search bitable records failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
-		return nil, 0, "", fmt.Errorf("search bitable records failed: code=%d msg=%s", resp.Code, resp.Msg)
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Search bitable records failed: app_token=%s, table_id=%s, code=%d, msg=%s", appToken, tableID, resp.Code, resp.Msg)
+		return nil, 0, "", bitableError("search bitable records", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
 	// Parse response
 	var records []map[string]interface{}
 	var total int
-	var pageToken string
+	var nextPageToken string
 
 	if resp.Data != nil {
-		if resp.Data.HasMore != nil {
-			// has_more is available
-		}
-		if resp.Data.PageToken != nil {
-			pageToken = *resp.Data.PageToken
+		// 只有 has_more 为 true 时才返回下一页的 page_token
+		if resp.Data.HasMore != nil && *resp.Data.HasMore && resp.Data.PageToken != nil {
+			nextPageToken = *resp.Data.PageToken
 		}
 		if resp.Data.Total != nil {
 			total = int(*resp.Data.Total)
 		}
 		if resp.Data.Items != nil {
 			for _, item := range resp.Data.Items {
-				record := make(map[string]interface{})
-				if item.RecordId != nil {
-					record["_id"] = *item.RecordId
-					record["record_id"] = *item.RecordId
-				}
-				if item.Fields != nil {
-					record["fields"] = item.Fields
-				}
-				records = append(records, record)
+				records = append(records, recordToMap(item))
 			}
 		}
 	}
 
-	s.log.Debug("Successfully searched bitable records: count=%d, total=%d, app_token=%s, table_id=%s", len(records), total, appToken, tableID)
+	s.logFor(ctx).Debug("Successfully searched bitable records: count=%d, total=%d, has_more=%v, app_token=%s, table_id=%s", len(records), total, nextPageToken != "", appToken, tableID)
 	
 	// Debug: Print first few records
 	for i := 0; i < len(records) && i < 3; i++ {
 		record := records[i]
 		if fields, ok := record["fields"].(map[string]interface{}); ok {
-			s.log.Debug("  Sample record[%d]: record_id=%v, fields=%v", i, record["record_id"], fields)
+			s.logFor(ctx).Debug("  Sample record[%d]: record_id=%v, fields=%v", i, record["record_id"], fields)
 		}
 	}
 	
-	return records, total, pageToken, nil
+	return records, total, nextPageToken, nil
+}
+
+// SearchAllRecords 按时间范围搜索全部记录，沿 page_token 翻页直到没有更多数据
+// 为避免异常情况下无限拉取，最多返回 FeishuConfig.SearchMaxRecords 条记录
+func (s *FeishuService) SearchAllRecords(ctx context.Context, appToken, tableID string, userName string, startTime, endTime int64, fieldNames []string) ([]map[string]interface{}, error) {
+	maxRecords := s.config.SearchMaxRecords
+	if maxRecords <= 0 {
+		maxRecords = 5000
+	}
+
+	var all []map[string]interface{}
+	pageToken := ""
+	pages := 0
+	for {
+		records, _, nextPageToken, err := s.SearchRecords(ctx, appToken, tableID, userName, startTime, endTime, fieldNames, bitableMaxPageSize, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, records...)
+		pages++
+
+		if nextPageToken == "" {
+			break
+		}
+		if len(all) >= maxRecords {
+			s.logFor(ctx).Warn("SearchAllRecords reached the record cap: cap=%d, pages=%d, app_token=%s, table_id=%s", maxRecords, pages, appToken, tableID)
+			all = all[:maxRecords]
+			break
+		}
+		pageToken = nextPageToken
+	}
+
+	s.logFor(ctx).Debug("SearchAllRecords: count=%d, pages=%d, app_token=%s, table_id=%s", len(all), pages, appToken, tableID)
+	return all, nil
 }
 
 // GetBitableAppTokenFromWikiNode 根据 wiki node_token 获取对应多维表格的 app_token
 // 通过调用 Wiki.V2.Space.GetNode 接口，读取返回的 node.obj_token 作为 app_token
-func (s *FeishuService) GetBitableAppTokenFromWikiNode(nodeToken string) (string, error) {
+func (s *FeishuService) GetBitableAppTokenFromWikiNode(ctx context.Context, nodeToken string) (string, error) {
 	if nodeToken == "" {
 		return "", fmt.Errorf("node token is empty")
 	}
@@ -433,13 +1049,13 @@ func (s *FeishuService) GetBitableAppTokenFromWikiNode(nodeToken string) (string
 		Build()
 
 	// 对于自建应用，使用 tenant access token 即可，SDK 会自动处理，无需额外选项
-	resp, err := s.client.Wiki.V2.Space.GetNode(s.ctx, req)
+	resp, err := s.client.Wiki.V2.Space.GetNode(ctx, req)
 	if err != nil {
 		return "", fmt.Errorf("get wiki node failed: %w", err)
 	}
 
 	if !resp.Success() {
-		return "", fmt.Errorf("get wiki node failed: code=%d msg=%s", resp.Code, resp.Msg)
+		return "", apiError("get wiki node", resp.ApiResp, resp.Code, resp.Msg)
 	}
 
 	if resp.Data == nil || resp.Data.Node == nil || resp.Data.Node.ObjToken == nil {
@@ -447,6 +1063,221 @@ func (s *FeishuService) GetBitableAppTokenFromWikiNode(nodeToken string) (string
 	}
 
 	appToken := *resp.Data.Node.ObjToken
-	s.log.Info("Resolved wiki node to bitable app_token: node_token=%s -> app_token=%s", nodeToken, appToken)
+	s.logFor(ctx).Info("Resolved wiki node to bitable app_token: node_token=%s -> app_token=%s", nodeToken, appToken)
 	return appToken, nil
 }
+
+// CardContent 消息卡片内容（卡片 JSON 1.0 结构）
+type CardContent struct {
+	Config   map[string]interface{}   `json:"config,omitempty"`
+	Header   map[string]interface{}   `json:"header,omitempty"`
+	Elements []map[string]interface{} `json:"elements"`
+}
+
+// UploadFile 上传文件到飞书，返回用于发送文件消息的 file_key
+// content 实现 io.Seeker 时，重试前会回到开头重新读取
+func (s *FeishuService) UploadFile(ctx context.Context, fileName string, content io.Reader) (string, error) {
+	s.logFor(ctx).Debug("Uploading file: name=%s", fileName)
+
+	req := larkim.NewCreateFileReqBuilder().
+		Body(larkim.NewCreateFileReqBodyBuilder().
+			FileType("stream").
+			FileName(fileName).
+			File(content).
+			Build()).
+		Build()
+
+	var resp *larkim.CreateFileResp
+	err := s.withRetry(ctx, "upload file", func() (*larkcore.ApiResp, int, error) {
+		if seeker, ok := content.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, 0, err
+			}
+		}
+		var err error
+		resp, err = s.client.Im.File.Create(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file: %v", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Upload file error: %s, code: %d", resp.Msg, resp.Code)
+		return "", apiError("upload file", resp.ApiResp, resp.Code, resp.Msg)
+	}
+	if resp.Data == nil || resp.Data.FileKey == nil {
+		return "", fmt.Errorf("failed to upload file: empty file_key")
+	}
+
+	s.logFor(ctx).Debug("Successfully uploaded file: name=%s, file_key=%s", fileName, *resp.Data.FileKey)
+	return *resp.Data.FileKey, nil
+}
+
+// ReplyFile 上传文件并以文件消息回复，用户可以在话题中直接下载
+func (s *FeishuService) ReplyFile(ctx context.Context, messageID, fileName string, content io.Reader, uuid string) error {
+	fileKey, err := s.UploadFile(ctx, fileName, content)
+	if err != nil {
+		return err
+	}
+
+	fileContent, err := json.Marshal(map[string]string{"file_key": fileKey})
+	if err != nil {
+		return fmt.Errorf("failed to marshal file content: %v", err)
+	}
+
+	req := larkim.NewReplyMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewReplyMessageReqBodyBuilder().
+			Content(string(fileContent)).
+			MsgType("file").
+			Uuid(uuid).
+			ReplyInThread(true).
+			Build()).
+		Build()
+
+	var resp *larkim.ReplyMessageResp
+	err = s.withRetry(ctx, "reply file", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.Message.Reply(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reply file: %v", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Reply file error: %s, code: %d", resp.Msg, resp.Code)
+		return apiError("reply file", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	s.logFor(ctx).Debug("Successfully replied file %s to message %s", fileName, messageID)
+	return nil
+}
+
+// UploadMediaToBitable 上传素材到多维表格，返回的 file_token 可写入该表格的附件字段
+func (s *FeishuService) UploadMediaToBitable(ctx context.Context, appToken, fileName string, data []byte) (string, error) {
+	s.logFor(ctx).Debug("Uploading media to bitable: app_token=%s, name=%s, size=%d", appToken, fileName, len(data))
+
+	parentType := "bitable_file"
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp":
+		parentType = "bitable_image"
+	}
+
+	var resp *larkdrive.UploadAllMediaResp
+	err := s.withRetry(ctx, "upload media", func() (*larkcore.ApiResp, int, error) {
+		// 每次重试都需要新的 reader
+		req := larkdrive.NewUploadAllMediaReqBuilder().
+			Body(larkdrive.NewUploadAllMediaReqBodyBuilder().
+				FileName(fileName).
+				ParentType(parentType).
+				ParentNode(appToken).
+				Size(len(data)).
+				File(bytes.NewReader(data)).
+				Build()).
+			Build()
+		var err error
+		resp, err = s.client.Drive.Media.UploadAll(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload media: %v", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Upload media error: %s, code: %d", resp.Msg, resp.Code)
+		return "", apiError("upload media", resp.ApiResp, resp.Code, resp.Msg)
+	}
+	if resp.Data == nil || resp.Data.FileToken == nil {
+		return "", fmt.Errorf("failed to upload media: empty file_token")
+	}
+
+	s.logFor(ctx).Debug("Successfully uploaded media: name=%s, file_token=%s", fileName, *resp.Data.FileToken)
+	return *resp.Data.FileToken, nil
+}
+
+// ReplyCard 以交互卡片回复消息
+func (s *FeishuService) ReplyCard(ctx context.Context, messageID string, card CardContent, uuid string) error {
+	cardContent, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("failed to marshal card content: %v", err)
+	}
+	s.logFor(ctx).Debug("Will reply card: %s, message_id: %s", string(cardContent), messageID)
+
+	req := larkim.NewReplyMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewReplyMessageReqBodyBuilder().
+			Content(string(cardContent)).
+			MsgType("interactive").
+			Uuid(uuid).
+			ReplyInThread(true).
+			Build()).
+		Build()
+
+	var resp *larkim.ReplyMessageResp
+	err = s.withRetry(ctx, "reply card", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.Message.Reply(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reply card: %v", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Reply card error: %s, code: %d", resp.Msg, resp.Code)
+		return apiError("reply card", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	s.logFor(ctx).Debug("Successfully replied card to message %s", messageID)
+	return nil
+}
+
+// UpdateCard 更新已发送的卡片内容（卡片需开启 update_multi）
+func (s *FeishuService) UpdateCard(ctx context.Context, messageID string, card CardContent) error {
+	cardContent, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("failed to marshal card content: %v", err)
+	}
+
+	req := larkim.NewPatchMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewPatchMessageReqBodyBuilder().
+			Content(string(cardContent)).
+			Build()).
+		Build()
+
+	resp, err := s.client.Im.Message.Patch(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to update card: %v", err)
+	}
+
+	if !resp.Success() {
+		s.logFor(ctx).WithField("request_id", requestID(resp.ApiResp)).Error("Update card error: %s, code: %d", resp.Msg, resp.Code)
+		return apiError("update card", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	s.logFor(ctx).Debug("Successfully updated card %s", messageID)
+	return nil
+}
+
+// logComponent 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别
+const logComponent = "feishu"
+
+// logFor 返回请求上下文中的日志记录器，日志行带有消息的关联 ID
+func (s *FeishuService) logFor(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, logComponent)
+}
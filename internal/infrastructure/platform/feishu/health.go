@@ -0,0 +1,71 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkbitable "github.com/larksuite/oapi-sdk-go/v3/service/bitable/v1"
+)
+
+// tenantTokenResp auth/v3/tenant_access_token/internal 接口的响应
+type tenantTokenResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// CheckTenantAccessToken 用应用凭证换取 tenant_access_token，用于检查 App ID/Secret 是否有效
+// 健康检查需要反映当前状态，这里不重试也不使用 SDK 的令牌缓存
+func (s *FeishuService) CheckTenantAccessToken(ctx context.Context) error {
+	body := map[string]string{"app_id": s.config.AppID, "app_secret": s.config.AppSecret}
+	resp, err := s.client.Post(ctx, "/open-apis/auth/v3/tenant_access_token/internal", body, larkcore.AccessTokenTypeNone)
+	if err != nil {
+		return fmt.Errorf("get tenant access token failed: %w", err)
+	}
+
+	var result tenantTokenResp
+	if err := json.Unmarshal(resp.RawBody, &result); err != nil {
+		return fmt.Errorf("parse tenant access token response: %w", err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("get tenant access token failed: code=%d msg=%s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// CheckTable 检查多维表格中是否存在指定的数据表
+func (s *FeishuService) CheckTable(ctx context.Context, appToken, tableID string) error {
+	pageToken := ""
+	for {
+		reqBuilder := larkbitable.NewListAppTableReqBuilder().
+			AppToken(appToken).
+			PageSize(100)
+		if pageToken != "" {
+			reqBuilder = reqBuilder.PageToken(pageToken)
+		}
+
+		resp, err := s.client.Bitable.V1.AppTable.List(ctx, reqBuilder.Build())
+		if err != nil {
+			return fmt.Errorf("list bitable tables failed: %w", err)
+		}
+		if !resp.Success() {
+			return bitableError("list bitable tables", resp.ApiResp, resp.Code, resp.Msg)
+		}
+
+		pageToken = ""
+		if resp.Data != nil {
+			for _, table := range resp.Data.Items {
+				if table.TableId != nil && *table.TableId == tableID {
+					return nil
+				}
+			}
+			if resp.Data.HasMore != nil && *resp.Data.HasMore && resp.Data.PageToken != nil {
+				pageToken = *resp.Data.PageToken
+			}
+		}
+		if pageToken == "" {
+			return fmt.Errorf("table %s not found in bitable %s", tableID, appToken)
+		}
+	}
+}
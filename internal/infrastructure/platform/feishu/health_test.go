@@ -0,0 +1,43 @@
+package feishu
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckTenantAccessToken(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+		return http.StatusOK, ok(nil)
+	})
+	if err := svc.CheckTenantAccessToken(context.Background()); err != nil {
+		t.Fatalf("CheckTenantAccessToken = %v, want nil", err)
+	}
+
+	fake.failAuth(10014)
+	if err := svc.CheckTenantAccessToken(context.Background()); err == nil || !strings.Contains(err.Error(), "code=10014") {
+		t.Errorf("CheckTenantAccessToken = %v, want the error code", err)
+	}
+}
+
+func TestCheckTable(t *testing.T) {
+	pages := []map[string]interface{}{
+		ok(map[string]interface{}{"items": []map[string]interface{}{{"table_id": "tbl_other"}}, "has_more": true, "page_token": "p2"}),
+		ok(map[string]interface{}{"items": []map[string]interface{}{{"table_id": "tbl_bills"}}, "has_more": false}),
+	}
+	svc, fake := newTestService(t, nil, func(n int, _ apiCall) (int, interface{}) {
+		return http.StatusOK, pages[n%len(pages)]
+	})
+
+	// 数据表在第二页
+	if err := svc.CheckTable(context.Background(), "app", "tbl_bills"); err != nil {
+		t.Fatalf("CheckTable = %v, want nil", err)
+	}
+	if n := len(fake.requests()); n != 2 {
+		t.Errorf("made %d requests, want 2 pages", n)
+	}
+	if err := svc.CheckTable(context.Background(), "app", "tbl_missing"); err == nil || !strings.Contains(err.Error(), "tbl_missing") {
+		t.Errorf("CheckTable for a missing table = %v, want not found", err)
+	}
+}
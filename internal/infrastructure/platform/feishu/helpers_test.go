@@ -0,0 +1,93 @@
+package feishu
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/wyg1997/LedgerBot/config"
+)
+
+// apiCall 记录假服务器收到的一次开放平台接口请求
+type apiCall struct {
+	Path  string
+	Query string
+	Body  map[string]interface{}
+}
+
+// fakeOpenAPI 模拟飞书开放平台，自动处理获取 tenant_access_token 的请求
+type fakeOpenAPI struct {
+	mu       sync.Mutex
+	calls    []apiCall
+	authCode int // 获取 tenant_access_token 返回的错误码，0 表示成功
+}
+
+// failAuth 让之后获取 tenant_access_token 的请求返回错误码 code
+func (f *fakeOpenAPI) failAuth(code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.authCode = code
+}
+
+func (f *fakeOpenAPI) record(call apiCall) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+// requests 返回收到的接口请求（不含获取 token 的请求）
+func (f *fakeOpenAPI) requests() []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]apiCall(nil), f.calls...)
+}
+
+// newTestService 创建连接到假服务器的 FeishuService，handle 按调用序号（从 0 开始）返回状态码和响应体
+func newTestService(t *testing.T, cfg *config.FeishuConfig, handle func(n int, call apiCall) (int, interface{})) (*FeishuService, *fakeOpenAPI) {
+	t.Helper()
+	fake := &fakeOpenAPI{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "/auth/v3/") {
+			fake.mu.Lock()
+			code := fake.authCode
+			fake.mu.Unlock()
+			if code != 0 {
+				json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": "app secret invalid"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200})
+			return
+		}
+
+		call := apiCall{Path: r.URL.Path, Query: r.URL.RawQuery}
+		if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+			if err := json.Unmarshal(body, &call.Body); err != nil {
+				t.Errorf("request body %s: %v", body, err)
+			}
+		}
+		n := len(fake.requests())
+		fake.record(call)
+		status, resp := handle(n, call)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	if cfg == nil {
+		cfg = &config.FeishuConfig{}
+	}
+	// 每个测试使用独立的 app_id，避免 SDK 的全局 token 缓存串用
+	client := lark.NewClient("cli_"+t.Name(), "secret", lark.WithOpenBaseUrl(srv.URL))
+	return &FeishuService{config: cfg, client: client, fieldCache: make(map[string][]*BitableField)}, fake
+}
+
+// ok 返回业务成功的响应体
+func ok(data interface{}) map[string]interface{} {
+	return map[string]interface{}{"code": 0, "msg": "success", "data": data}
+}
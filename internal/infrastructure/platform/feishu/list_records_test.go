@@ -0,0 +1,235 @@
+package feishu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// pageRequest 返回请求的 page_size 和 page_token
+func pageRequest(t *testing.T, call apiCall) (int, string) {
+	t.Helper()
+	query, err := url.ParseQuery(call.Query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, _ := strconv.Atoi(query.Get("page_size"))
+	return size, query.Get("page_token")
+}
+
+// 按请求的 page_size 返回记录，直到 total 条；page_token 为已返回的条数
+func pagedHandler(t *testing.T, total int) func(int, apiCall) (int, interface{}) {
+	return func(_ int, call apiCall) (int, interface{}) {
+		size, token := pageRequest(t, call)
+		start, _ := strconv.Atoi(token)
+		count := size
+		if start+count > total {
+			count = total - start
+		}
+		next := ""
+		if start+count < total {
+			next = strconv.Itoa(start + count)
+		}
+		return http.StatusOK, searchPage(start, count, next)
+	}
+}
+
+// 取满 pageSize 条后停止翻页，返回下一页的 page_token；超过单页上限时按上限分页
+func TestListRecordsPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageSize  int
+		total     int
+		wantSizes []int
+		wantNext  string
+	}{
+		{"one page", 5, 10, []int{5}, "5"},
+		{"last page", 20, 10, []int{20}, ""},
+		{"over page limit", 700, 1000, []int{500, 200}, "700"},
+		{"default page size", 0, 600, []int{500}, "500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, fake := newTestService(t, nil, pagedHandler(t, tt.total))
+
+			records, next, err := svc.ListRecords(context.Background(), "app", "tbl", tt.pageSize, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantCount := 0
+			for _, size := range tt.wantSizes {
+				wantCount += size
+			}
+			if wantCount > tt.total {
+				wantCount = tt.total
+			}
+			if len(records) != wantCount || next != tt.wantNext {
+				t.Errorf("got %d records, next %q, want %d, %q", len(records), next, wantCount, tt.wantNext)
+			}
+			if len(records) > 0 && (records[0]["record_id"] != "rec0" || records[0]["_id"] != "rec0") {
+				t.Errorf("first record = %v", records[0])
+			}
+
+			calls := fake.requests()
+			if len(calls) != len(tt.wantSizes) {
+				t.Fatalf("made %d requests, want %d", len(calls), len(tt.wantSizes))
+			}
+			offset := 0
+			for i, call := range calls {
+				if call.Path != "/open-apis/bitable/v1/apps/app/tables/tbl/records" {
+					t.Errorf("request %d path = %s", i+1, call.Path)
+				}
+				size, token := pageRequest(t, call)
+				wantToken := ""
+				if offset > 0 {
+					wantToken = strconv.Itoa(offset)
+				}
+				if size != tt.wantSizes[i] || token != wantToken {
+					t.Errorf("request %d page_size=%d page_token=%q, want %d, %q", i+1, size, token, tt.wantSizes[i], wantToken)
+				}
+				offset += size
+			}
+		})
+	}
+}
+
+// 从调用方给的 page_token 继续
+func TestListRecordsPageToken(t *testing.T) {
+	svc, fake := newTestService(t, nil, pagedHandler(t, 10))
+
+	records, next, err := svc.ListRecords(context.Background(), "app", "tbl", 5, "5")
+	if err != nil || len(records) != 5 || next != "" || records[0]["record_id"] != "rec5" {
+		t.Fatalf("ListRecords = %d records, %q, %v, want rec5..rec9", len(records), next, err)
+	}
+	if _, token := pageRequest(t, fake.requests()[0]); token != "5" {
+		t.Errorf("page_token = %q, want 5", token)
+	}
+}
+
+// 限流时重试，参数错误和 app_token 错误直接返回并可用 errors.Is 判断
+func TestListRecordsErrors(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(n int, call apiCall) (int, interface{}) {
+		if n == 0 {
+			return http.StatusBadRequest, map[string]interface{}{"code": 1254290, "msg": "TooManyRequest"}
+		}
+		return http.StatusOK, searchPage(0, 1, "")
+	})
+	if records, _, err := svc.ListRecords(context.Background(), "app", "tbl", 10, ""); err != nil || len(records) != 1 {
+		t.Errorf("ListRecords after rate limit = %d records, %v", len(records), err)
+	}
+	if n := len(fake.requests()); n != 2 {
+		t.Errorf("made %d requests, want 2", n)
+	}
+
+	for _, code := range []int{1254045, 1254040} {
+		svc, fake := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+			return http.StatusBadRequest, map[string]interface{}{"code": code, "msg": "fake error"}
+		})
+		_, _, err := svc.ListRecords(context.Background(), "app", "tbl", 10, "")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != code || apiErr.Op != "list bitable records" {
+			t.Errorf("code %d: ListRecords = %v, want an APIError", code, err)
+		}
+		if errors.Is(err, ErrAppTokenInvalid) != (code == 1254040) {
+			t.Errorf("code %d: errors.Is(ErrAppTokenInvalid) = %v", code, errors.Is(err, ErrAppTokenInvalid))
+		}
+		if n := len(fake.requests()); n != 1 {
+			t.Errorf("code %d: made %d requests, want 1", code, n)
+		}
+	}
+}
+
+// 过滤条件、字段名转换为搜索请求体，"_id" 不作为字段名发送
+func TestListRecordsWithFilterBody(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+		return http.StatusOK, searchPage(0, 2, "")
+	})
+
+	filter := map[string]interface{}{
+		"automatic_fields": false,
+		"field_names":      []string{"_id", "描述", "金额"},
+		"page_size":        50,
+		"filter": map[string]interface{}{
+			"conjunction": "or",
+			"conditions": []map[string]interface{}{
+				{"field_name": "记录者", "operator": "is", "value": []string{"张三"}},
+				{"field_name": "日期", "operator": "isGreaterEqual", "value": []interface{}{"ExactDate", 1714507200000}},
+				// 缺少操作符的条件被忽略
+				{"field_name": "分类"},
+			},
+		},
+	}
+	records, err := svc.ListRecordsWithFilter(context.Background(), "app", "tbl", filter)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("ListRecordsWithFilter = %d records, %v", len(records), err)
+	}
+
+	call := fake.requests()[0]
+	if call.Path != "/open-apis/bitable/v1/apps/app/tables/tbl/records/search" {
+		t.Errorf("path = %s", call.Path)
+	}
+	if size, _ := pageRequest(t, call); size != 50 {
+		t.Errorf("page_size = %d, want 50", size)
+	}
+	if got := fmt.Sprint(call.Body["field_names"]); got != "[描述 金额]" {
+		t.Errorf("field_names = %s, want [描述 金额]", got)
+	}
+	if call.Body["automatic_fields"] != false {
+		t.Errorf("automatic_fields = %v", call.Body["automatic_fields"])
+	}
+	body, _ := call.Body["filter"].(map[string]interface{})
+	if body["conjunction"] != "or" {
+		t.Errorf("conjunction = %v, want or", body["conjunction"])
+	}
+	conditions := searchConditions(t, call)
+	if len(conditions) != 2 {
+		t.Fatalf("conditions = %v, want 2", conditions)
+	}
+	if c := conditions["记录者"]; c["operator"] != "is" || fmt.Sprint(c["value"]) != "[张三]" {
+		t.Errorf("user condition = %v", c)
+	}
+	if c := conditions["日期"]; c["operator"] != "isGreaterEqual" || fmt.Sprint(c["value"]) != "[ExactDate 1714507200000]" {
+		t.Errorf("date condition = %v", c)
+	}
+
+	// 没有条件时不发送 filter
+	if _, err := svc.ListRecordsWithFilter(context.Background(), "app", "tbl", map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if f, found := fake.requests()[1].Body["filter"]; found {
+		t.Errorf("filter without conditions = %v", f)
+	}
+}
+
+// 按 page_size 翻页取满，沿用上一页的 page_token
+func TestListRecordsWithFilterPages(t *testing.T) {
+	svc, fake := newTestService(t, nil, pagedHandler(t, 1000))
+
+	records, err := svc.ListRecordsWithFilter(context.Background(), "app", "tbl", map[string]interface{}{"page_size": 700})
+	if err != nil || len(records) != 700 {
+		t.Fatalf("ListRecordsWithFilter = %d records, %v, want 700", len(records), err)
+	}
+	calls := fake.requests()
+	if len(calls) != 2 {
+		t.Fatalf("made %d requests, want 2", len(calls))
+	}
+	if size, token := pageRequest(t, calls[1]); size != 200 || token != "500" {
+		t.Errorf("second request page_size=%d page_token=%q, want 200, 500", size, token)
+	}
+}
+
+func TestListRecordsWithFilterError(t *testing.T) {
+	svc, _ := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{"code": 1254040, "msg": "AppTokenInvalid"}
+	})
+
+	_, err := svc.ListRecordsWithFilter(context.Background(), "app", "tbl", map[string]interface{}{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Op != "list bitable records with filter" || !errors.Is(err, ErrAppTokenInvalid) {
+		t.Errorf("ListRecordsWithFilter = %v, want an app token APIError", err)
+	}
+}
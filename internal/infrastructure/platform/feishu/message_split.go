@@ -0,0 +1,73 @@
+package feishu
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// textMessageMaxBytes 单条文本消息内容（JSON 转义后）的最大字节数
+// 飞书文本消息上限为 150KB，但请求体过大时接口会报错，这里保守地按 30KB 拆分，
+// 并为 "(1/3)" 后缀预留空间
+const textMessageMaxBytes = 30*1024 - 64
+
+// escapedSize 返回文本放入 {"text": ...} 后 JSON 转义的字节数（不含引号）
+func escapedSize(text string) int {
+	b, err := json.Marshal(text)
+	if err != nil {
+		return len(text)
+	}
+	return len(b) - 2
+}
+
+// splitTextMessage 按行将文本拆分为多段，每段转义后不超过 maxBytes
+// 单行超过限制时按字符继续拆分
+func splitTextMessage(text string, maxBytes int) []string {
+	if escapedSize(text) <= maxBytes {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentSize := 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentSize = 0
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineSize := escapedSize(line)
+		if currentSize+lineSize <= maxBytes {
+			current.WriteString(line)
+			currentSize += lineSize
+			continue
+		}
+
+		flush()
+		if lineSize <= maxBytes {
+			current.WriteString(line)
+			currentSize = lineSize
+			continue
+		}
+
+		// 超长的单行按字符拆分
+		for _, r := range line {
+			runeSize := escapedSize(string(r))
+			if currentSize+runeSize > maxBytes {
+				flush()
+			}
+			current.WriteRune(r)
+			currentSize += runeSize
+		}
+	}
+	flush()
+
+	// 去掉每段末尾多余的换行
+	for i, chunk := range chunks {
+		chunks[i] = strings.TrimRight(chunk, "\n")
+	}
+	return chunks
+}
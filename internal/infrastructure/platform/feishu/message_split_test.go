@@ -0,0 +1,103 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// longReply 返回 n 行的账单列表
+func longReply(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%d. 2025-03-%02d 午饭 \"公司楼下\" ¥%d.50", i+1, i%28+1, i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSplitTextMessage(t *testing.T) {
+	text := longReply(800)
+	chunks := splitTextMessage(text, textMessageMaxBytes)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want the reply split", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if size := escapedSize(chunk); size > textMessageMaxBytes {
+			t.Errorf("chunk %d is %d bytes, over the %d byte limit", i+1, size, textMessageMaxBytes)
+		}
+	}
+	// 按行拆分，拼接后与原文一致
+	if joined := strings.Join(chunks, "\n"); joined != text {
+		t.Error("chunks joined with newlines differ from the original text")
+	}
+
+	if chunks := splitTextMessage("短消息", textMessageMaxBytes); len(chunks) != 1 || chunks[0] != "短消息" {
+		t.Errorf("short text split into %q", chunks)
+	}
+}
+
+// 超长的单行按字符拆分，不会截断多字节字符
+func TestSplitTextMessageLongLine(t *testing.T) {
+	line := strings.Repeat("账", 100)
+	chunks := splitTextMessage(line, 30)
+	if strings.Join(chunks, "") != line {
+		t.Fatalf("chunks %q lost characters", chunks)
+	}
+	for _, chunk := range chunks {
+		if escapedSize(chunk) > 30 {
+			t.Errorf("chunk %q over the limit", chunk)
+		}
+	}
+}
+
+func TestReplyMessageSplit(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+		return http.StatusOK, ok(map[string]interface{}{"message_id": "om_reply"})
+	})
+	text := longReply(800)
+	want := len(splitTextMessage(text, textMessageMaxBytes))
+
+	if err := svc.ReplyMessage(context.Background(), "om_1", text, "uuid"); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.requests()
+	if len(calls) != want {
+		t.Fatalf("sent %d replies, want %d", len(calls), want)
+	}
+	for i, call := range calls {
+		var content map[string]string
+		if err := json.Unmarshal([]byte(call.Body["content"].(string)), &content); err != nil {
+			t.Fatal(err)
+		}
+		if suffix := fmt.Sprintf("(%d/%d)", i+1, want); !strings.HasSuffix(content["text"], suffix) {
+			t.Errorf("reply %d does not end with %s", i+1, suffix)
+		}
+		if got := call.Body["uuid"]; got != fmt.Sprintf("uuid-%d", i+1) {
+			t.Errorf("reply %d uuid = %v, want uuid-%d", i+1, got, i+1)
+		}
+		if call.Body["reply_in_thread"] != true {
+			t.Errorf("reply %d not sent in thread", i+1)
+		}
+	}
+}
+
+// 某段发送失败时返回错误，不再发送后续内容
+func TestReplyMessageSplitFailure(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(n int, _ apiCall) (int, interface{}) {
+		if n == 1 {
+			return http.StatusBadRequest, map[string]interface{}{"code": 230001, "msg": "invalid content"}
+		}
+		return http.StatusOK, ok(map[string]interface{}{"message_id": "om_reply"})
+	})
+
+	err := svc.ReplyMessage(context.Background(), "om_1", longReply(800), "uuid")
+	if err == nil || !strings.Contains(err.Error(), "chunk 2/") {
+		t.Errorf("ReplyMessage = %v, want an error for chunk 2", err)
+	}
+	if n := len(fake.requests()); n != 2 {
+		t.Errorf("sent %d replies, want to stop after the failed one", n)
+	}
+}
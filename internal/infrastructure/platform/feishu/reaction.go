@@ -0,0 +1,69 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// AddReaction 为消息添加表情回复，返回 reaction_id 用于之后撤销
+// emojiType 取值参考飞书表情文案说明，例如 OnIt、THUMBSUP、DONE
+func (s *FeishuService) AddReaction(ctx context.Context, messageID, emojiType string) (string, error) {
+	req := larkim.NewCreateMessageReactionReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewCreateMessageReactionReqBodyBuilder().
+			ReactionType(larkim.NewEmojiBuilder().EmojiType(emojiType).Build()).
+			Build()).
+		Build()
+
+	var resp *larkim.CreateMessageReactionResp
+	err := s.withRetry(ctx, "add reaction", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.V1.MessageReaction.Create(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("add reaction: %w", err)
+	}
+	if !resp.Success() {
+		return "", apiError("add reaction", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	if resp.Data == nil || resp.Data.ReactionId == nil {
+		return "", nil
+	}
+	s.logFor(ctx).Debug("Added reaction: message_id=%s, emoji=%s, reaction_id=%s", messageID, emojiType, *resp.Data.ReactionId)
+	return *resp.Data.ReactionId, nil
+}
+
+// RemoveReaction 撤销消息上的表情回复
+func (s *FeishuService) RemoveReaction(ctx context.Context, messageID, reactionID string) error {
+	req := larkim.NewDeleteMessageReactionReqBuilder().
+		MessageId(messageID).
+		ReactionId(reactionID).
+		Build()
+
+	var resp *larkim.DeleteMessageReactionResp
+	err := s.withRetry(ctx, "remove reaction", func() (*larkcore.ApiResp, int, error) {
+		var err error
+		resp, err = s.client.Im.V1.MessageReaction.Delete(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return resp.ApiResp, resp.Code, nil
+	})
+	if err != nil {
+		return fmt.Errorf("remove reaction: %w", err)
+	}
+	if !resp.Success() {
+		return apiError("remove reaction", resp.ApiResp, resp.Code, resp.Msg)
+	}
+
+	s.logFor(ctx).Debug("Removed reaction: message_id=%s, reaction_id=%s", messageID, reactionID)
+	return nil
+}
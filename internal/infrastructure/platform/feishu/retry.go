@@ -0,0 +1,70 @@
+package feishu
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+)
+
+const (
+	// retryMaxRetries 飞书接口调用失败后的最大重试次数
+	retryMaxRetries = 3
+	// retryBaseDelay 首次重试的等待时间，之后每次翻倍
+	retryBaseDelay = 500 * time.Millisecond
+)
+
+// retryableCodes 可以重试的飞书业务错误码
+var retryableCodes = map[int]bool{
+	99991400: true, // 应用频率限制
+	1254290:  true, // 多维表格请求过于频繁
+	1254291:  true, // 多维表格写冲突
+	1255040:  true, // 多维表格请求超时
+}
+
+// isRetryable 判断一次调用结果是否值得重试：网络错误、5xx、限流类错误码可以重试，
+// 其余 4xx 和参数校验类错误重试也不会成功
+func isRetryable(apiResp *larkcore.ApiResp, code int, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	if retryableCodes[code] {
+		return true
+	}
+	if apiResp != nil && (apiResp.StatusCode == http.StatusTooManyRequests || apiResp.StatusCode >= 500) {
+		return true
+	}
+	return false
+}
+
+// withRetry 执行一次飞书接口调用，遇到限流或临时故障时按指数退避重试
+// call 返回 SDK 响应的 ApiResp 和业务错误码；最终结果由调用方按原有逻辑处理
+func (s *FeishuService) withRetry(ctx context.Context, op string, call func() (*larkcore.ApiResp, int, error)) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		apiResp, code, err := call()
+		if attempt > retryMaxRetries || !isRetryable(apiResp, code, err) {
+			return err
+		}
+
+		// 剩余时间不足以等待下一次重试时直接返回
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		status := 0
+		if apiResp != nil {
+			status = apiResp.StatusCode
+		}
+		s.logFor(ctx).WithField("request_id", requestID(apiResp)).Warn("Feishu %s failed, retrying (%d/%d) in %s: status=%d, code=%d, err=%v", op, attempt, retryMaxRetries, delay, status, code, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,81 @@
+package feishu
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// createdRecord 返回创建记录成功的响应
+func createdRecord() map[string]interface{} {
+	return ok(map[string]interface{}{"record": map[string]interface{}{"record_id": "rec1", "fields": map[string]interface{}{}}})
+}
+
+// 首次调用被限流，退避后重试成功
+func TestWithRetryRateLimited(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		code   int
+	}{
+		{"rate limit code", http.StatusBadRequest, 99991400},
+		{"server error", http.StatusServiceUnavailable, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, fake := newTestService(t, nil, func(n int, _ apiCall) (int, interface{}) {
+				if n == 0 {
+					return tt.status, map[string]interface{}{"code": tt.code, "msg": "try again later"}
+				}
+				return http.StatusOK, createdRecord()
+			})
+
+			recordID, err := svc.AddRecordToBitable(context.Background(), "app", "tbl", map[string]interface{}{"描述": "午饭"})
+			if err != nil || recordID != "rec1" {
+				t.Fatalf("AddRecordToBitable = %q, %v, want rec1", recordID, err)
+			}
+			calls := fake.requests()
+			if len(calls) != 2 {
+				t.Fatalf("made %d requests, want 2", len(calls))
+			}
+			// 重试沿用同一个 client_token，不会重复创建记录
+			if calls[0].Query != calls[1].Query {
+				t.Errorf("retry query %q differs from %q", calls[1].Query, calls[0].Query)
+			}
+		})
+	}
+}
+
+// 参数校验类错误重试也不会成功，直接返回
+func TestWithRetryValidationError(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{"code": 1254045, "msg": "FieldNameNotFound"}
+	})
+
+	_, err := svc.AddRecordToBitable(context.Background(), "app", "tbl", map[string]interface{}{"不存在": 1})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 1254045 {
+		t.Errorf("AddRecordToBitable = %v, want the validation error", err)
+	}
+	if n := len(fake.requests()); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+}
+
+// 剩余时间不足以等待退避时不再重试
+func TestWithRetryDeadline(t *testing.T) {
+	svc, fake := newTestService(t, nil, func(int, apiCall) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{"code": 99991400, "msg": "rate limited"}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), retryBaseDelay/2)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := svc.AddRecordToBitable(ctx, "app", "tbl", map[string]interface{}{"描述": "午饭"}); err == nil {
+		t.Fatal("AddRecordToBitable succeeded while rate limited")
+	}
+	if n := len(fake.requests()); n != 1 || time.Since(start) >= retryBaseDelay {
+		t.Errorf("made %d requests in %s, want 1 without waiting", n, time.Since(start))
+	}
+}
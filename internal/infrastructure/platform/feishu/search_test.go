@@ -0,0 +1,103 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/config"
+)
+
+// searchPage 返回一页搜索结果，pageToken 为空表示最后一页
+func searchPage(start, count int, pageToken string) map[string]interface{} {
+	items := make([]map[string]interface{}, 0, count)
+	for i := start; i < start+count; i++ {
+		items = append(items, map[string]interface{}{
+			"record_id": fmt.Sprintf("rec%d", i),
+			"fields":    map[string]interface{}{"金额": i},
+		})
+	}
+	return ok(map[string]interface{}{"items": items, "has_more": pageToken != "", "page_token": pageToken, "total": 7})
+}
+
+func TestSearchAllRecordsPages(t *testing.T) {
+	pages := []map[string]interface{}{searchPage(0, 3, "p2"), searchPage(3, 3, "p3"), searchPage(6, 1, "")}
+	svc, fake := newTestService(t, &config.FeishuConfig{FieldDate: "日期"}, func(n int, _ apiCall) (int, interface{}) {
+		return http.StatusOK, pages[n]
+	})
+
+	records, err := svc.SearchAllRecords(context.Background(), "app", "tbl", "", 0, 1, []string{"金额"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 || records[0]["record_id"] != "rec0" || records[6]["record_id"] != "rec6" {
+		t.Fatalf("got %d records: %v, want rec0..rec6", len(records), records)
+	}
+	// 第二、三页沿用上一页返回的 page_token
+	calls := fake.requests()
+	if len(calls) != 3 {
+		t.Fatalf("made %d search requests, want 3", len(calls))
+	}
+	for i, want := range []string{"", "p2", "p3"} {
+		query, err := url.ParseQuery(calls[i].Query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := query.Get("page_token"); got != want {
+			t.Errorf("request %d page_token = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+// 达到记录上限后不再翻页
+func TestSearchAllRecordsCap(t *testing.T) {
+	svc, fake := newTestService(t, &config.FeishuConfig{FieldDate: "日期", SearchMaxRecords: 4}, func(n int, _ apiCall) (int, interface{}) {
+		return http.StatusOK, searchPage(n*3, 3, fmt.Sprintf("p%d", n+2))
+	})
+
+	records, err := svc.SearchAllRecords(context.Background(), "app", "tbl", "", 0, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || len(fake.requests()) != 2 {
+		t.Errorf("got %d records in %d requests, want 4 in 2", len(records), len(fake.requests()))
+	}
+}
+
+// searchConditions 返回搜索请求中的过滤条件，键为字段名
+func searchConditions(t *testing.T, call apiCall) map[string]map[string]interface{} {
+	t.Helper()
+	filter, _ := call.Body["filter"].(map[string]interface{})
+	raw, _ := filter["conditions"].([]interface{})
+	conditions := make(map[string]map[string]interface{})
+	for _, c := range raw {
+		condition := c.(map[string]interface{})
+		name, _ := condition["field_name"].(string)
+		conditions[name] = condition
+	}
+	return conditions
+}
+
+func TestSearchRecordsUserFilter(t *testing.T) {
+	svc, fake := newTestService(t, &config.FeishuConfig{FieldDate: "日期", FieldUserName: "记录人"}, func(int, apiCall) (int, interface{}) {
+		return http.StatusOK, searchPage(0, 0, "")
+	})
+
+	if _, _, _, err := svc.SearchRecords(context.Background(), "app", "tbl", "张三", 0, 1, nil, 10, ""); err != nil {
+		t.Fatal(err)
+	}
+	condition, found := searchConditions(t, fake.requests()[0])["记录人"]
+	if !found || condition["operator"] != "is" || fmt.Sprint(condition["value"]) != "[张三]" {
+		t.Errorf("user condition = %v, want 记录人 is 张三", condition)
+	}
+
+	// 未指定用户时不按记录人过滤
+	if _, _, _, err := svc.SearchRecords(context.Background(), "app", "tbl", "", 0, 1, nil, 10, ""); err != nil {
+		t.Fatal(err)
+	}
+	if condition, found := searchConditions(t, fake.requests()[1])["记录人"]; found {
+		t.Errorf("search without user has condition %v", condition)
+	}
+}
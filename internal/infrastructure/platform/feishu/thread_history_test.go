@@ -0,0 +1,84 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/config"
+)
+
+// fakeThread 模拟一个有 n 条消息的话题，按请求的排序方式分页返回
+func fakeThread(t *testing.T, n int) func(int, apiCall) (int, interface{}) {
+	return func(_ int, call apiCall) (int, interface{}) {
+		query, err := url.ParseQuery(call.Query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := 0
+		fmt.Sscan(query.Get("page_token"), &offset)
+		pageSize := 0
+		fmt.Sscan(query.Get("page_size"), &pageSize)
+
+		var items []map[string]interface{}
+		for i := offset; i < n && i < offset+pageSize; i++ {
+			id := n - i // 倒序时从最新的消息开始
+			if query.Get("sort_type") == "ByCreateTimeAsc" {
+				id = i + 1
+			}
+			items = append(items, map[string]interface{}{"message_id": fmt.Sprintf("om%d", id)})
+		}
+		next := offset + pageSize
+		data := map[string]interface{}{"items": items, "has_more": next < n}
+		if next < n {
+			data["page_token"] = fmt.Sprint(next)
+		}
+		return http.StatusOK, ok(data)
+	}
+}
+
+func messageIDs(t *testing.T, svc *FeishuService) []string {
+	t.Helper()
+	messages, err := svc.ListMessagesByThread(context.Background(), "omt_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(messages))
+	for i, m := range messages {
+		ids[i] = *m.MessageId
+	}
+	return ids
+}
+
+// 超过一页的话题按页拉取完整历史，并按创建时间升序返回
+func TestListMessagesByThreadPages(t *testing.T) {
+	svc, fake := newTestService(t, &config.FeishuConfig{ThreadHistoryMaxMessages: 200}, fakeThread(t, 120))
+
+	ids := messageIDs(t, svc)
+	if len(ids) != 120 || ids[0] != "om1" || ids[119] != "om120" {
+		t.Fatalf("got %d messages from %s to %s, want om1..om120", len(ids), ids[0], ids[len(ids)-1])
+	}
+	for i, id := range ids {
+		if id != fmt.Sprintf("om%d", i+1) {
+			t.Fatalf("message %d = %s, want ascending order", i, id)
+		}
+	}
+	if n := len(fake.requests()); n != 3 {
+		t.Errorf("made %d requests, want 3 pages", n)
+	}
+}
+
+// 超过上限时保留最新的消息，并带上话题的第一条消息
+func TestListMessagesByThreadCap(t *testing.T) {
+	svc, _ := newTestService(t, &config.FeishuConfig{ThreadHistoryMaxMessages: 60}, fakeThread(t, 120))
+
+	ids := messageIDs(t, svc)
+	if len(ids) != 61 {
+		t.Fatalf("got %d messages, want 60 plus the first", len(ids))
+	}
+	if ids[0] != "om1" || ids[1] != "om61" || ids[60] != "om120" {
+		t.Errorf("got %s, %s ... %s, want om1, om61 ... om120", ids[0], ids[1], ids[60])
+	}
+}
@@ -0,0 +1,148 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// logComponent 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别
+const logComponent = "telegram"
+
+// maxMessageLength Telegram 单条消息的最大长度，超出部分截断
+const maxMessageLength = 4096
+
+// Service 调用 Telegram Bot API 发送消息
+type Service struct {
+	config *config.TelegramConfig
+	client *http.Client
+}
+
+// NewService creates a Telegram service
+func NewService(cfg *config.TelegramConfig) *Service {
+	return &Service{
+		config: cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// sendMessageReq sendMessage 接口的请求
+type sendMessageReq struct {
+	ChatID          int64            `json:"chat_id"`
+	Text            string           `json:"text"`
+	ReplyParameters *replyParameters `json:"reply_parameters,omitempty"`
+}
+
+// replyParameters 回复的目标消息；目标消息已删除时仍然发送
+type replyParameters struct {
+	MessageID                int64 `json:"message_id"`
+	AllowSendingWithoutReply bool  `json:"allow_sending_without_reply"`
+}
+
+// SendMessage 向会话发送文本消息，返回新消息的 message_id
+func (s *Service) SendMessage(ctx context.Context, chatID int64, text string) (int64, error) {
+	return s.sendMessage(ctx, &sendMessageReq{ChatID: chatID, Text: truncate(text)})
+}
+
+// ReplyMessage 回复指定消息，返回新消息的 message_id
+func (s *Service) ReplyMessage(ctx context.Context, chatID, replyTo int64, text string) (int64, error) {
+	return s.sendMessage(ctx, &sendMessageReq{
+		ChatID:          chatID,
+		Text:            truncate(text),
+		ReplyParameters: &replyParameters{MessageID: replyTo, AllowSendingWithoutReply: true},
+	})
+}
+
+func (s *Service) sendMessage(ctx context.Context, req *sendMessageReq) (int64, error) {
+	var sent Message
+	if err := s.call(ctx, "sendMessage", req, &sent); err != nil {
+		logger.FromContext(ctx, logComponent).Error("Send telegram message failed: chat_id=%d, error=%v", req.ChatID, err)
+		return 0, err
+	}
+	logger.FromContext(ctx, logComponent).Debug("Telegram message sent: chat_id=%d, message_id=%d", req.ChatID, sent.MessageID)
+	return sent.MessageID, nil
+}
+
+// GetMe 获取机器人自身信息，可用于检查令牌是否有效
+func (s *Service) GetMe(ctx context.Context) (*User, error) {
+	var me User
+	if err := s.call(ctx, "getMe", nil, &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+// call 调用 Bot API 方法，并将响应中的 result 解析到 result
+func (s *Service) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body := []byte("{}")
+	if params != nil {
+		var err error
+		if body, err = json.Marshal(params); err != nil {
+			return fmt.Errorf("marshal %s request: %v", method, err)
+		}
+	}
+
+	endpoint := strings.TrimRight(s.config.APIBaseURL, "/") + "/bot" + s.config.BotToken + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create %s request: %v", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// 错误信息中的 URL 含有令牌，不直接输出
+		return fmt.Errorf("telegram %s request failed: %v", method, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read %s response: %v", method, err)
+	}
+
+	var envelope struct {
+		apiResponse
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("parse %s response: status=%d, %v", method, resp.StatusCode, err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram %s failed: code=%d desc=%s", method, envelope.ErrorCode, envelope.Description)
+	}
+	if result != nil && len(envelope.Result) > 0 {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return fmt.Errorf("parse %s result: %v", method, err)
+		}
+	}
+	return nil
+}
+
+// unwrapURLError 去掉 *url.Error 中带令牌的请求地址
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// truncate 按 Telegram 的长度限制截断消息
+func truncate(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxMessageLength {
+		return text
+	}
+	return string(runes[:maxMessageLength-1]) + "…"
+}
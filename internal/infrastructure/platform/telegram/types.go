@@ -0,0 +1,44 @@
+package telegram
+
+// Update Bot API 推送的更新，目前只处理新消息
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+// Message Telegram 消息
+type Message struct {
+	MessageID      int64    `json:"message_id"`
+	From           *User    `json:"from,omitempty"`
+	Chat           Chat     `json:"chat"`
+	Date           int64    `json:"date"`
+	Text           string   `json:"text,omitempty"`
+	ReplyToMessage *Message `json:"reply_to_message,omitempty"`
+}
+
+// User Telegram 用户或机器人
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// Chat 消息所在的会话
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"` // private、group、supergroup 或 channel
+}
+
+// IsPrivate 判断是否为私聊
+func (c Chat) IsPrivate() bool {
+	return c.Type == "private"
+}
+
+// apiResponse Bot API 的通用响应
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code,omitempty"`
+	Description string `json:"description,omitempty"`
+}
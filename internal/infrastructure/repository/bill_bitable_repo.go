@@ -1,41 +1,88 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wyg1997/LedgerBot/config"
 	"github.com/wyg1997/LedgerBot/internal/domain"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
 	"github.com/wyg1997/LedgerBot/pkg/logger"
 )
 
+// logComponent 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别
+const logComponent = "repository"
+
 // bitableBillRepository implements BillRepository using Feishu bitable as storage
 type bitableBillRepository struct {
 	feishuService *feishu.FeishuService
 	config        *config.FeishuConfig
-	logger        logger.Logger
-	appToken      string
 	tableID       string
+	location      *time.Location // 读取的日期转换到该时区
+
+	// wiki 链接的节点 token，非 wiki 链接为空；app_token 失效时据此重新解析
+	nodeToken string
+	wikiCache cache.Cache
+	refreshMu sync.Mutex
+	tokenMu   sync.RWMutex
+	appToken  string
+
+	// 启动时字段校验的结果，FEISHU_SCHEMA_STRICT=false 时校验失败仍继续运行
+	schemaErr error
+
+	tagOptionsMu sync.Mutex
 }
 
 // NewBitableBillRepository creates a new bitable bill repository
-func NewBitableBillRepository(feishuService *feishu.FeishuService, config *config.FeishuConfig) (domain.BillRepository, error) {
-	log := logger.GetLogger()
+// 配置了群聊独立账本时，返回按账本路由到不同表格的仓库
+// wikiCache 缓存 wiki 节点对应的 app_token，为 nil 时每次启动都调用 wiki 接口解析
+// location 为配置的时区，表格中的日期读取后转换到该时区
+func NewBitableBillRepository(ctx context.Context, feishuService *feishu.FeishuService, config *config.FeishuConfig, wikiCache cache.Cache, location *time.Location) (domain.BillRepository, error) {
+	repo, err := newBitableTableRepository(ctx, feishuService, config, wikiCache, config.BitableURL, location)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.ChatTables) == 0 {
+		if repo.softDeleteEnabled() {
+			go runSoftDeleteSweeper(ctx, func() map[string]*bitableBillRepository {
+				return map[string]*bitableBillRepository{"": repo}
+			})
+		}
+		return repo, nil
+	}
+	logger.FromContext(ctx, logComponent).Info("Per-chat ledgers configured: chats=%d", len(config.ChatTables))
+	ledgers := newLedgerBillRepository(repo, feishuService, config, wikiCache)
+	if repo.softDeleteEnabled() {
+		go runSoftDeleteSweeper(ctx, ledgers.tables)
+	}
+	return ledgers, nil
+}
+
+// newBitableTableRepository creates a repository for the table in bitableURL,
+// resolving wiki links and validating the schema
+func newBitableTableRepository(ctx context.Context, feishuService *feishu.FeishuService, config *config.FeishuConfig, wikiCache cache.Cache, bitableURL string, location *time.Location) (*bitableBillRepository, error) {
+	log := logger.FromContext(ctx, logComponent)
 	// Parse the bitable URL to extract node/app token and table id
-	rawToken, tableID, isWiki, err := parseBitableURL(config.BitableURL, log)
+	loc, err := parseBitableURL(bitableURL, log)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse bitable URL: %v", err)
 	}
+	rawToken, tableID, isWiki := loc.Token, loc.TableID, loc.IsWiki
 
 	appToken := rawToken
+	nodeToken := ""
 	if isWiki {
 		// 当 URL 是 wiki 链接时，需要先通过 node_token 换取真正的 bitable app_token
-		log.Info("Converting wiki node_token to bitable app_token: node_token=%s", rawToken)
-		appToken, err = feishuService.GetBitableAppTokenFromWikiNode(rawToken)
+		nodeToken = rawToken
+		appToken, err = resolveWikiAppToken(ctx, feishuService, wikiCache, nodeToken, false)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve bitable app token from wiki node: %v", err)
 		}
@@ -44,102 +91,125 @@ func NewBitableBillRepository(feishuService *feishu.FeishuService, config *confi
 		log.Info("Using direct bitable URL, app_token=%s, table_id=%s", appToken, tableID)
 	}
 
-	return &bitableBillRepository{
+	repo := &bitableBillRepository{
 		feishuService: feishuService,
 		config:        config,
-		logger:        log,
 		appToken:      appToken,
 		tableID:       tableID,
-	}, nil
-}
-
-// parseBitableURL parses the bitable URL to extract token (node_token or app_token) and table id,
-// and returns whether this is a wiki node link.
-// 支持两种格式：
-// 1) base 链接: https://xxx.feishu.cn/base/APP_TOKEN?table=TABLE_ID
-// 2) wiki 链接: https://xxx.feishu.cn/wiki/NODE_TOKEN?table=TABLE_ID&view=...
-func parseBitableURL(bitableURL string, log logger.Logger) (token string, tableID string, isWiki bool, err error) {
-	if bitableURL == "" {
-		return "", "", false, fmt.Errorf("bitable URL is empty")
+		location:      location,
+		nodeToken:     nodeToken,
+		wikiCache:     wikiCache,
 	}
 
-	// Remove protocol prefix (https:// or http://) if present
-	cleanedURL := bitableURL
-	if strings.HasPrefix(cleanedURL, "https://") {
-		cleanedURL = strings.TrimPrefix(cleanedURL, "https://")
-	} else if strings.HasPrefix(cleanedURL, "http://") {
-		cleanedURL = strings.TrimPrefix(cleanedURL, "http://")
+	// 缓存的 app_token 可能已失效，先访问一次表格，失效时会重新解析
+	if nodeToken != "" && wikiCache != nil {
+		if err := repo.Ping(ctx); err != nil {
+			log.Warn("Bitable check with cached app_token failed: %v", err)
+		}
 	}
 
-	// Split URL into path and query parts
-	parts := strings.SplitN(cleanedURL, "?", 2)
-	pathPart := parts[0]
-	var queryPart string
-	if len(parts) > 1 {
-		queryPart = parts[1]
+	// 开启自动建表时先补齐缺失的字段和选项
+	if config.AutoCreateFields {
+		if err := repo.ensureSchema(ctx); err != nil {
+			return nil, fmt.Errorf("failed to create bitable fields: %v", err)
+		}
 	}
 
-	// Parse path: remove leading and trailing slashes, then split
-	path := strings.Trim(pathPart, "/")
-	if path == "" {
-		return "", "", false, fmt.Errorf("empty path in URL: %s", bitableURL)
+	// 启动时校验字段，避免字段名写错到第一次记账时才暴露
+	if err := repo.validateSchema(ctx); err != nil {
+		if config.SchemaStrict {
+			log.Error("Bitable schema validation failed: %v", err)
+			return nil, err
+		}
+		log.Warn("Bitable schema validation failed, continuing because FEISHU_SCHEMA_STRICT=false: %v", err)
+		repo.schemaErr = err
 	}
 
-	// Split path by "/" to get domain and path components
-	// Format: domain.com/wiki/TOKEN or domain.com/base/TOKEN
-	pathSegments := strings.Split(path, "/")
-	if len(pathSegments) < 3 {
-		return "", "", false, fmt.Errorf("invalid bitable URL format: path has less than 3 segments (path=%s, segments=%v), expected: example.feishu.cn/base/APP_TOKEN?table=TABLE_ID or example.feishu.cn/wiki/NODE_TOKEN?table=TABLE_ID", path, pathSegments)
-	}
+	return repo, nil
+}
 
-	// Find "base" or "wiki" in path segments
-	var baseOrWikiIndex = -1
-	for i, segment := range pathSegments {
-		if segment == "base" || segment == "wiki" {
-			baseOrWikiIndex = i
-			break
-		}
+// parseBitableURL parses the bitable URL, see config.ParseBitableURL
+func parseBitableURL(bitableURL string, log logger.Logger) (config.BitableLocation, error) {
+	loc, err := config.ParseBitableURL(bitableURL)
+	if err != nil {
+		return loc, err
 	}
+	log.Debug("parseBitableURL: input=%s, result: token=%s, tableID=%s, viewID=%s, isWiki=%v", bitableURL, loc.Token, loc.TableID, loc.ViewID, loc.IsWiki)
+	return loc, nil
+}
 
-	if baseOrWikiIndex == -1 {
-		return "", "", false, fmt.Errorf("invalid bitable URL format: 'base' or 'wiki' not found in path (path=%s, segments=%v)", path, pathSegments)
-	}
+// CreateBill creates a new bill in bitable
+func (r *bitableBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	fields := r.billFields(ctx, bill)
 
-	if baseOrWikiIndex+1 >= len(pathSegments) {
-		return "", "", false, fmt.Errorf("invalid bitable URL format: token not found after 'base' or 'wiki' (path=%s)", path)
-	}
+	r.logFor(ctx).Debug("Preparing to create bill in bitable: app_token=%s, table_id=%s, fields=%+v", r.token(), r.tableID, fields)
 
-	firstPart := pathSegments[baseOrWikiIndex]
-	token = pathSegments[baseOrWikiIndex+1]
-	if token == "" {
-		return "", "", false, fmt.Errorf("empty token in URL path (path=%s)", path)
+	start := time.Now()
+	recordID, err := r.feishuService.AddRecordToBitable(ctx,
+		r.token(),
+		r.tableID,
+		fields,
+	)
+	r.refreshOnTokenError(ctx, err)
+
+	if err != nil {
+		r.opLog(ctx, start).WithField("user", bill.UserName).Error("Failed to create bill in bitable: %v", err)
+		return fmt.Errorf("failed to create bill: %w", err)
 	}
 
-	isWiki = firstPart == "wiki"
+	// 账单 ID 即多维表格的 record_id，后续修改、删除都使用它
+	bill.ID = recordID
+	bill.RecordID = recordID
 
-	// Parse query parameters to get table id
-	if queryPart != "" {
-		queryParams, err := url.ParseQuery(queryPart)
-		if err != nil {
-			return "", "", isWiki, fmt.Errorf("invalid query parameters: %v", err)
-		}
-		tableID = queryParams.Get("table")
+	r.opLog(ctx, start).WithFields(logger.Fields{"user": bill.UserName, "record_id": recordID}).Info("Created bill in bitable")
+	return nil
+}
+
+// CreateBills creates several bills with a single batch request
+// 批量请求失败时逐条重试，返回的 BatchCreateError 中记录每条失败的原因，成功的账单会设置 RecordID
+func (r *bitableBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	if len(bills) == 0 {
+		return nil
 	}
 
-	if tableID == "" {
-		return "", "", isWiki, fmt.Errorf("table id not found in URL query parameters")
+	fieldsList := make([]map[string]interface{}, 0, len(bills))
+	for _, bill := range bills {
+		fieldsList = append(fieldsList, r.billFields(ctx, bill))
 	}
 
-	log.Debug("parseBitableURL: input=%s, result: token=%s, tableID=%s, isWiki=%v", bitableURL, token, tableID, isWiki)
-	return token, tableID, isWiki, nil
-}
+	r.logFor(ctx).Debug("Preparing to batch create bills in bitable: app_token=%s, table_id=%s, count=%d", r.token(), r.tableID, len(bills))
 
-// CreateBill creates a new bill in bitable
-func (r *bitableBillRepository) CreateBill(bill *domain.Bill) error {
-	if bill.ID == "" {
-		bill.ID = fmt.Sprintf("%s_%d", bill.UserName, time.Now().Unix())
+	start := time.Now()
+	recordIDs, err := r.feishuService.BatchAddRecordsToBitable(ctx, r.token(), r.tableID, fieldsList)
+	r.refreshOnTokenError(ctx, err)
+	for i, recordID := range recordIDs {
+		bills[i].ID = recordID
+		bills[i].RecordID = recordID
+	}
+	if err == nil {
+		r.opLog(ctx, start).WithFields(logger.Fields{"count": len(recordIDs), "record_ids": recordIDs}).Info("Batch created bills in bitable")
+		return nil
+	}
+
+	// 批量写入失败（例如某条记录的字段不合法）时逐条创建，找出具体失败的记录
+	r.opLog(ctx, start).WithFields(logger.Fields{"count": len(recordIDs), "total": len(bills)}).Warn("Batch create bills failed, falling back to one by one: %v", err)
+	batchErr := &domain.BatchCreateError{Errors: make([]error, len(bills))}
+	for i, bill := range bills {
+		if bill.RecordID != "" {
+			continue
+		}
+		if err := r.CreateBill(ctx, bill); err != nil {
+			batchErr.Errors[i] = err
+		}
 	}
+	if batchErr.FailedCount() > 0 {
+		return batchErr
+	}
+	return nil
+}
 
+// billFields converts a bill into bitable record fields
+func (r *bitableBillRepository) billFields(ctx context.Context, bill *domain.Bill) map[string]interface{} {
 	// Convert type to Chinese
 	billType := "支出"
 	if bill.Type == domain.BillTypeIncome {
@@ -151,7 +221,7 @@ func (r *bitableBillRepository) CreateBill(bill *domain.Bill) error {
 
 	fields := map[string]interface{}{
 		r.config.FieldDescription: bill.Description,
-		r.config.FieldAmount:      bill.Amount,
+		r.config.FieldAmount:      domain.RoundAmount(bill.Amount),
 		r.config.FieldType:        bill.Category,
 		r.config.FieldCategory:    billType,
 		r.config.FieldDate:        dateTimestamp,
@@ -162,69 +232,63 @@ func (r *bitableBillRepository) CreateBill(bill *domain.Bill) error {
 	if r.config.FieldOriginalMsg != "" {
 		if bill.OriginalMsg != "" {
 			fields[r.config.FieldOriginalMsg] = bill.OriginalMsg
-			r.logger.Debug("Added original message to fields: field=%s, value=%s", r.config.FieldOriginalMsg, bill.OriginalMsg)
+			r.logFor(ctx).Debug("Added original message to fields: field=%s, value=%s", r.config.FieldOriginalMsg, bill.OriginalMsg)
 		} else {
-			r.logger.Debug("Original message field is configured but bill.OriginalMsg is empty")
+			r.logFor(ctx).Debug("Original message field is configured but bill.OriginalMsg is empty")
 		}
 	} else {
 		if bill.OriginalMsg != "" {
-			r.logger.Debug("Original message exists but field name is not configured: OriginalMsg=%s", bill.OriginalMsg)
+			r.logFor(ctx).Debug("Original message exists but field name is not configured: OriginalMsg=%s", bill.OriginalMsg)
 		}
 	}
 
-	r.logger.Debug("Preparing to create bill in bitable: app_token=%s, table_id=%s, fields=%+v", r.appToken, r.tableID, fields)
-
-	recordID, err := r.feishuService.AddRecordToBitable(
-		r.appToken,
-		r.tableID,
-		fields,
-	)
-
-	if err != nil {
-		r.logger.Error("Failed to create bill in bitable: %v", err)
-		return fmt.Errorf("failed to create bill: %v", err)
+	// 未提及账户时不写入，保持字段为空
+	if r.config.FieldAccount != "" && bill.Account != "" {
+		fields[r.config.FieldAccount] = bill.Account
 	}
 
-	// Store record_id in bill for later use (e.g., updating the record)
-	bill.RecordID = recordID
+	if tags, ok := r.tagsFieldValue(ctx, bill); ok {
+		fields[r.config.FieldTags] = tags
+	}
 
-	r.logger.Info("Created bill in bitable: RecordID=%s, BillID=%s", recordID, bill.ID)
-	return nil
-}
+	r.setReimbursementFields(fields, bill)
 
-// GetBill gets a bill by ID from bitable
-func (r *bitableBillRepository) GetBill(id string) (*domain.Bill, error) {
-	// If id is a record_id (starts with "rec"), get directly by record_id
-	if len(id) >= 3 && id[:3] == "rec" {
-		record, err := r.feishuService.GetRecordToBitable(r.appToken, r.tableID, id)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get record by record_id: %v", err)
-		}
-		return r.convertRecordToBill(record)
+	if attachments, ok := r.attachmentFieldValue(ctx, bill); ok {
+		fields[r.config.FieldAttachment] = attachments
 	}
 
-	// For other IDs, use the old method (query all and filter)
-	// This is less efficient but maintains backward compatibility
-	bills, _, err := r.ListBills("", nil, nil, nil, nil, 0, 100) // Get all and filter
+	return fields
+}
+
+// GetBill gets a bill by its record ID from bitable
+func (r *bitableBillRepository) GetBill(ctx context.Context, id string) (*domain.Bill, error) {
+	record, err := r.feishuService.GetRecordToBitable(ctx, r.token(), r.tableID, id)
+	r.refreshOnTokenError(ctx, err)
 	if err != nil {
-		return nil, err
+		return nil, billError("failed to get record by record_id", err)
 	}
-
-	for _, bill := range bills {
-		if bill.ID == id {
-			return bill, nil
-		}
+	if !r.deletedAt(record).IsZero() {
+		return nil, fmt.Errorf("bill %s is deleted: %w", id, domain.ErrBillNotFound)
 	}
+	return r.convertRecordToBill(record)
+}
 
-	return nil, fmt.Errorf("bill not found: %s", id)
+// billError 包装多维表格操作的错误，记录不存在时转换为 domain.ErrBillNotFound
+func billError(op string, err error) error {
+	if errors.Is(err, feishu.ErrRecordNotFound) {
+		return fmt.Errorf("%s: %w", op, domain.ErrBillNotFound)
+	}
+	return fmt.Errorf("%s: %v", op, err)
 }
 
 // UpdateBill updates a bill in bitable
 // Note: This method supports partial updates - only fields that are set in the bill will be updated
-func (r *bitableBillRepository) UpdateBill(bill *domain.Bill) error {
-	if bill.RecordID == "" {
-		return fmt.Errorf("record_id is required for updating bill")
+func (r *bitableBillRepository) UpdateBill(ctx context.Context, bill *domain.Bill) error {
+	recordID, err := r.resolveRecordID(bill.RecordID, bill.ID)
+	if err != nil {
+		return err
 	}
+	bill.RecordID = recordID
 
 	// Build fields map - only include fields that are being updated (non-zero/non-empty values)
 	fields := make(map[string]interface{})
@@ -236,7 +300,7 @@ func (r *bitableBillRepository) UpdateBill(bill *domain.Bill) error {
 
 	// Only update amount if provided (non-zero)
 	if bill.Amount > 0 {
-		fields[r.config.FieldAmount] = bill.Amount
+		fields[r.config.FieldAmount] = domain.RoundAmount(bill.Amount)
 	}
 
 	// Only update category if provided
@@ -269,67 +333,119 @@ func (r *bitableBillRepository) UpdateBill(bill *domain.Bill) error {
 		fields[r.config.FieldOriginalMsg] = bill.OriginalMsg
 	}
 
+	// Only update account if configured and provided
+	if r.config.FieldAccount != "" && bill.Account != "" {
+		fields[r.config.FieldAccount] = bill.Account
+	}
+
+	// Replace tags if provided
+	if tags, ok := r.tagsFieldValue(ctx, bill); ok {
+		fields[r.config.FieldTags] = tags
+	}
+
+	// Update reimbursement state if provided
+	r.setReimbursementFields(fields, bill)
+
+	// Replace attachments if provided
+	if attachments, ok := r.attachmentFieldValue(ctx, bill); ok {
+		fields[r.config.FieldAttachment] = attachments
+	}
+
 	if len(fields) == 0 {
 		return fmt.Errorf("no fields to update")
 	}
 
-	r.logger.Debug("Preparing to update bill in bitable: app_token=%s, table_id=%s, record_id=%s, fields=%+v", r.appToken, r.tableID, bill.RecordID, fields)
+	r.logFor(ctx).Debug("Preparing to update bill in bitable: app_token=%s, table_id=%s, record_id=%s, fields=%+v", r.token(), r.tableID, bill.RecordID, fields)
 
-	updatedRecordID, err := r.feishuService.UpdateRecordToBitable(
-		r.appToken,
+	start := time.Now()
+	updatedRecordID, err := r.feishuService.UpdateRecordToBitable(ctx,
+		r.token(),
 		r.tableID,
 		bill.RecordID,
 		fields,
 	)
+	r.refreshOnTokenError(ctx, err)
 
 	if err != nil {
-		r.logger.Error("Failed to update bill in bitable: %v", err)
-		return fmt.Errorf("failed to update bill: %v", err)
+		r.opLog(ctx, start).WithFields(logger.Fields{"user": bill.UserName, "record_id": bill.RecordID}).Error("Failed to update bill in bitable: %v", err)
+		return billError(fmt.Sprintf("failed to update bill %s", bill.RecordID), err)
 	}
 
 	// Update bill's record_id in case it changed (shouldn't happen, but just in case)
 	bill.RecordID = updatedRecordID
 
-	r.logger.Info("Updated bill in bitable: RecordID=%s, BillID=%s", updatedRecordID, bill.ID)
+	r.opLog(ctx, start).WithFields(logger.Fields{"user": bill.UserName, "record_id": updatedRecordID}).Info("Updated bill in bitable")
 	return nil
 }
 
 // DeleteBill deletes a bill from bitable
-func (r *bitableBillRepository) DeleteBill(id string) error {
-	// If id is a record_id (starts with "rec"), delete directly by record_id
-	if len(id) >= 3 && id[:3] == "rec" {
-		err := r.feishuService.DeleteRecordToBitable(r.appToken, r.tableID, id)
-		if err != nil {
-			r.logger.Error("Failed to delete bill in bitable: %v", err)
-			return fmt.Errorf("failed to delete bill: %v", err)
-		}
-		r.logger.Info("Deleted bill in bitable: RecordID=%s", id)
-		return nil
+// 开启软删除时只填写删除时间，保留期过后由后台清理
+func (r *bitableBillRepository) DeleteBill(ctx context.Context, id string) error {
+	recordID, err := r.resolveRecordID("", id)
+	if err != nil {
+		return fmt.Errorf("failed to get bill for deletion: %w", err)
+	}
+	if r.softDeleteEnabled() {
+		return r.softDelete(ctx, recordID)
 	}
 
-	// For other IDs, we need to find the record first
-	// This is less efficient but maintains backward compatibility
-	bill, err := r.GetBill(id)
+	start := time.Now()
+	err = r.feishuService.DeleteRecordToBitable(ctx, r.token(), r.tableID, recordID)
+	r.refreshOnTokenError(ctx, err)
 	if err != nil {
-		return fmt.Errorf("failed to get bill for deletion: %v", err)
+		r.opLog(ctx, start).WithField("record_id", recordID).Error("Failed to delete bill in bitable: %v", err)
+		return billError(fmt.Sprintf("failed to delete bill %s", recordID), err)
 	}
 
-	if bill.RecordID == "" {
-		return fmt.Errorf("record_id not found for bill: %s", id)
+	r.opLog(ctx, start).WithField("record_id", recordID).Info("Deleted bill in bitable")
+	return nil
+}
+
+// DeleteBills deletes several bills with the batch delete API
+// 开启软删除时逐条填写删除时间
+func (r *bitableBillRepository) DeleteBills(ctx context.Context, ids []string) error {
+	recordIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		recordID, err := r.resolveRecordID("", id)
+		if err != nil {
+			return fmt.Errorf("failed to get bill for deletion: %w", err)
+		}
+		recordIDs = append(recordIDs, recordID)
+	}
+	if r.softDeleteEnabled() {
+		for _, recordID := range recordIDs {
+			if err := r.softDelete(ctx, recordID); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	err = r.feishuService.DeleteRecordToBitable(r.appToken, r.tableID, bill.RecordID)
+	start := time.Now()
+	deleted, err := r.feishuService.BatchDeleteRecordsToBitable(ctx, r.token(), r.tableID, recordIDs)
+	r.refreshOnTokenError(ctx, err)
 	if err != nil {
-		r.logger.Error("Failed to delete bill in bitable: %v", err)
-		return fmt.Errorf("failed to delete bill: %v", err)
+		r.opLog(ctx, start).WithFields(logger.Fields{"count": deleted, "total": len(recordIDs)}).Error("Failed to batch delete bills in bitable: %v", err)
+		return billError(fmt.Sprintf("failed to delete %d bills", len(recordIDs)), err)
 	}
 
-	r.logger.Info("Deleted bill in bitable: RecordID=%s, BillID=%s", bill.RecordID, id)
+	r.opLog(ctx, start).WithFields(logger.Fields{"count": deleted, "record_ids": recordIDs}).Info("Batch deleted bills in bitable")
 	return nil
 }
 
+// resolveRecordID 返回账单的 record_id：优先使用 RecordID，否则 ID 本身就是 record_id
+func (r *bitableBillRepository) resolveRecordID(recordID, id string) (string, error) {
+	if recordID != "" {
+		return recordID, nil
+	}
+	if id == "" {
+		return "", fmt.Errorf("record_id is required")
+	}
+	return id, nil
+}
+
 // ListBills lists bills with filtering
-func (r *bitableBillRepository) ListBills(username string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
+func (r *bitableBillRepository) ListBills(ctx context.Context, username string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
 	// Build filter conditions
 	filterConditions := []map[string]interface{}{}
 
@@ -361,55 +477,47 @@ func (r *bitableBillRepository) ListBills(username string, startDate, endDate *t
 		})
 	}
 
-	// Date range filter
-	if startDate != nil || endDate != nil {
-		dateCondition := map[string]interface{}{
+	// Date range filter (search API compares dates via ExactDate millisecond timestamps)
+	if startDate != nil {
+		filterConditions = append(filterConditions, map[string]interface{}{
 			"field_name": r.config.FieldDate,
-			"operator":   "is_within",
-			"field_type": 5, // Date field type
-		}
+			"operator":   "isGreaterEqual",
+			"value":      []string{"ExactDate", fmt.Sprintf("%d", startDate.UnixMilli())},
+		})
+	}
+	if endDate != nil {
+		filterConditions = append(filterConditions, map[string]interface{}{
+			"field_name": r.config.FieldDate,
+			"operator":   "isLessEqual",
+			"value":      []string{"ExactDate", fmt.Sprintf("%d", endDate.UnixMilli())},
+		})
+	}
 
-		if startDate != nil && endDate != nil {
-			dateCondition["value"] = []string{
-				startDate.Format("2006-01-02 15:04:05"),
-				endDate.Format("2006-01-02 15:04:05"),
-			}
-		}
-		filterConditions = append(filterConditions, dateCondition)
-	}
-
-	// Build the full filter
-	filter := map[string]interface{}{
-		"automatic_fields": false,
-		"field_names": []string{
-			"_id", // record id
-			r.config.FieldDescription,
-			r.config.FieldAmount,
-			r.config.FieldType,
-			r.config.FieldCategory,
-			r.config.FieldDate,
-			r.config.FieldUserName,
-			r.config.FieldOriginalMsg,
-		},
-		"page_size": limit,
-	}
-
-	if len(filterConditions) > 0 {
-		filter["filter"] = map[string]interface{}{
-			"conjunction": "and",
-			"conditions":  filterConditions,
-		}
+	if cond := r.notDeletedCondition(); cond != nil {
+		filterConditions = append(filterConditions, cond)
 	}
 
-	// Query records
-	records, err := r.feishuService.ListRecordsWithFilter(
-		r.appToken,
-		r.tableID,
-		filter,
-	)
+	var records []map[string]interface{}
+	var err error
+	if len(filterConditions) == 0 {
+		// 没有过滤条件时直接按表格顺序列出记录
+		records, _, err = r.feishuService.ListRecords(ctx, r.token(), r.tableID, limit, "")
+	} else {
+		filter := map[string]interface{}{
+			"automatic_fields": false,
+			"field_names":      append([]string{"_id"}, r.queryFieldNames()...), // _id 为 record id
+			"page_size":        limit,
+			"filter": map[string]interface{}{
+				"conjunction": "and",
+				"conditions":  filterConditions,
+			},
+		}
+		records, err = r.feishuService.ListRecordsWithFilter(ctx, r.token(), r.tableID, filter)
+	}
+	r.refreshOnTokenError(ctx, err)
 
 	if err != nil {
-		r.logger.Error("Failed to list bills from bitable: %v", err)
+		r.logFor(ctx).Error("Failed to list bills from bitable: %v", err)
 		return nil, 0, fmt.Errorf("failed to list bills: %v", err)
 	}
 
@@ -418,7 +526,7 @@ func (r *bitableBillRepository) ListBills(username string, startDate, endDate *t
 	for _, record := range records {
 		bill, err := r.convertRecordToBill(record)
 		if err != nil {
-			r.logger.Error("Failed to convert record to bill: %v", err)
+			r.logFor(ctx).Error("Failed to convert record to bill: %v", err)
 			continue
 		}
 		bills = append(bills, bill)
@@ -428,10 +536,10 @@ func (r *bitableBillRepository) ListBills(username string, startDate, endDate *t
 }
 
 // GetMonthlySummary gets monthly summary for a user
-func (r *bitableBillRepository) GetMonthlySummary(username string, year, month int) (*domain.MonthlySummary, error) {
+func (r *bitableBillRepository) GetMonthlySummary(ctx context.Context, username string, year, month int) (*domain.MonthlySummary, error) {
 	// This would require aggregating data from bitable
 	// For now, return empty summary
-	r.logger.Warn("GetMonthlySummary not implemented for bitable storage")
+	r.logFor(ctx).Warn("GetMonthlySummary not implemented for bitable storage")
 	return &domain.MonthlySummary{
 		Year:  year,
 		Month: month,
@@ -439,88 +547,136 @@ func (r *bitableBillRepository) GetMonthlySummary(username string, year, month i
 }
 
 // GetCategories gets all categories for a user
-func (r *bitableBillRepository) GetCategories(userName string) ([]string, error) {
+func (r *bitableBillRepository) GetCategories(ctx context.Context, userName string) ([]string, error) {
 	// This would require querying unique categories from bitable
 	// For now, return empty list
-	r.logger.Warn("GetCategories not implemented for bitable storage")
+	r.logFor(ctx).Warn("GetCategories not implemented for bitable storage")
 	return []string{}, nil
 }
 
+// Ping 检查应用凭证是否有效、账单数据表是否可以访问
+func (r *bitableBillRepository) Ping(ctx context.Context) error {
+	err := r.feishuService.CheckTable(ctx, r.token(), r.tableID)
+	r.refreshOnTokenError(ctx, err)
+	return err
+}
+
 // QueryTransactions queries transactions within a time range
-func (r *bitableBillRepository) QueryTransactions(userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+func (r *bitableBillRepository) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
 	// Convert time to milliseconds timestamp
 	startTimestamp := startTime.UnixMilli()
 	endTimestamp := endTime.UnixMilli()
 
-	r.logger.Debug("QueryTransactions: user_name=%s, start_time=%s (%d), end_time=%s (%d), top_n=%d",
-		userName, startTime.Format("2006-01-02 15:04:05"), startTimestamp, endTime.Format("2006-01-02 15:04:05"), endTimestamp, topN)
+	r.logFor(ctx).WithField("user_name", userName).Debug("QueryTransactions: start_time=%s (%d), end_time=%s (%d), top_n=%d",
+		startTime.Format("2006-01-02 15:04:05"), startTimestamp, endTime.Format("2006-01-02 15:04:05"), endTimestamp, topN)
 
 	// Get all field names
-	fieldNames := []string{
-		r.config.FieldDescription,
-		r.config.FieldAmount,
-		r.config.FieldType,
-		r.config.FieldCategory,
-		r.config.FieldDate,
-		r.config.FieldUserName,
-		r.config.FieldOriginalMsg,
-	}
+	fieldNames := r.queryFieldNames()
 
-	// Search records (use a large page size to get all records, then filter by user)
-	records, _, _, err := r.feishuService.SearchRecords(r.appToken, r.tableID, startTimestamp, endTimestamp, fieldNames, 500)
+	// Search all pages so that totals are computed over the full set, then truncate to top N for display
+	start := time.Now()
+	records, err := r.feishuService.SearchAllRecords(ctx, r.token(), r.tableID, r.searchUserName(userName), startTimestamp, endTimestamp, fieldNames)
+	r.refreshOnTokenError(ctx, err)
 	if err != nil {
-		r.logger.Error("Failed to query transactions from bitable: %v", err)
+		r.opLog(ctx, start).WithField("user", userName).Error("Failed to query transactions from bitable: %v", err)
 		return nil, 0, 0, fmt.Errorf("failed to query transactions: %v", err)
 	}
 
-	r.logger.Debug("QueryTransactions: received %d records from bitable", len(records))
+	r.opLog(ctx, start).WithFields(logger.Fields{"user": userName, "count": len(records)}).Debug("QueryTransactions: received records from bitable")
 
-	// Convert records to bills (no user filtering - show all family members' transactions)
+	// Convert records to bills (user filtering is done by the search condition unless the ledger is shared)
 	var bills []*domain.Bill
-	var totalIncome, totalExpense float64
-
 	for i, record := range records {
 		bill, err := r.convertRecordToBill(record)
 		if err != nil {
-			r.logger.Error("Failed to convert record to bill: %v", err)
+			r.logFor(ctx).Error("Failed to convert record to bill: %v", err)
 			continue
 		}
 
-		r.logger.Debug("  Record[%d]: record_id=%s, description=%s, amount=%.2f, type=%s, category=%s, date=%s, user_name=%s",
+		r.logFor(ctx).Debug("  Record[%d]: record_id=%s, description=%s, amount=%.2f, type=%s, category=%s, date=%s, user_name=%s",
 			i, bill.RecordID, bill.Description, bill.Amount, bill.Type, bill.Category, bill.Date.Format("2006-01-02 15:04:05"), bill.UserName)
 
-		// Note: No user filtering - show all family members' transactions
-
-		// Calculate totals
-		if bill.Type == domain.BillTypeIncome {
-			totalIncome += bill.Amount
-		} else {
-			totalExpense += bill.Amount
-		}
-
 		bills = append(bills, bill)
 	}
 
-	r.logger.Debug("QueryTransactions: converted %d records to bills (no user filtering applied)", len(bills))
+	// 合计按分累加，借贷不计入收支合计
+	totalIncome, totalExpense := domain.SumBills(bills)
 
-	// Sort by amount descending
-	for i := 0; i < len(bills)-1; i++ {
-		for j := i + 1; j < len(bills); j++ {
-			if bills[i].Amount < bills[j].Amount {
-				bills[i], bills[j] = bills[j], bills[i]
-			}
-		}
-	}
+	r.logFor(ctx).Debug("QueryTransactions: converted %d records to bills", len(bills))
+
+	// 合计覆盖全部记录后再按金额倒序取前 N 笔，金额相同时保持接口返回的顺序
+	sort.SliceStable(bills, func(i, j int) bool { return bills[i].Amount > bills[j].Amount })
 
 	// Take top N if specified
 	if topN > 0 && topN < len(bills) {
 		bills = bills[:topN]
 	}
 
-	r.logger.Debug("QueryTransactions: found %d bills, total_income=%.2f, total_expense=%.2f", len(bills), totalIncome, totalExpense)
+	r.logFor(ctx).Debug("QueryTransactions: found %d bills, total_income=%.2f, total_expense=%.2f", len(bills), totalIncome, totalExpense)
 	return bills, totalIncome, totalExpense, nil
 }
 
+// QueryTransactionsPage queries one page of transactions within a time range, ordered by date descending.
+// It returns the page token of the next page, which is empty when there are no more records.
+func (r *bitableBillRepository) QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	r.logFor(ctx).WithField("user_name", userName).Debug("QueryTransactionsPage: start_time=%s, end_time=%s, page_token=%s, page_size=%d",
+		startTime.Format("2006-01-02 15:04:05"), endTime.Format("2006-01-02 15:04:05"), pageToken, pageSize)
+
+	start := time.Now()
+	records, _, nextPageToken, err := r.feishuService.SearchRecords(ctx, r.token(), r.tableID, r.searchUserName(userName), startTime.UnixMilli(), endTime.UnixMilli(), r.queryFieldNames(), pageSize, pageToken)
+	r.refreshOnTokenError(ctx, err)
+	if err != nil {
+		r.opLog(ctx, start).WithField("user", userName).Error("Failed to query transactions page from bitable: %v", err)
+		return nil, "", fmt.Errorf("failed to query transactions page: %v", err)
+	}
+
+	bills := make([]*domain.Bill, 0, len(records))
+	for _, record := range records {
+		bill, err := r.convertRecordToBill(record)
+		if err != nil {
+			r.logFor(ctx).Error("Failed to convert record to bill: %v", err)
+			continue
+		}
+		bills = append(bills, bill)
+	}
+
+	return bills, nextPageToken, nil
+}
+
+// searchUserName returns the user name to filter searches by, or empty when the ledger is shared
+func (r *bitableBillRepository) searchUserName(userName string) string {
+	if r.config.SharedLedger {
+		return ""
+	}
+	return userName
+}
+
+// queryFieldNames returns the field names needed to build a Bill
+func (r *bitableBillRepository) queryFieldNames() []string {
+	names := []string{
+		r.config.FieldDescription,
+		r.config.FieldAmount,
+		r.config.FieldType,
+		r.config.FieldCategory,
+		r.config.FieldDate,
+		r.config.FieldUserName,
+		r.config.FieldOriginalMsg,
+	}
+	if r.config.FieldAccount != "" {
+		names = append(names, r.config.FieldAccount)
+	}
+	if r.config.FieldTags != "" {
+		names = append(names, r.config.FieldTags)
+	}
+	if r.config.FieldReimbursable != "" {
+		names = append(names, r.config.FieldReimbursable)
+	}
+	if r.config.FieldReimbursedAt != "" {
+		names = append(names, r.config.FieldReimbursedAt)
+	}
+	return names
+}
+
 // Helper function to convert interface to float64
 func toFloat64(v interface{}) float64 {
 	switch val := v.(type) {
@@ -532,6 +688,9 @@ func toFloat64(v interface{}) float64 {
 		return float64(val)
 	case int64:
 		return float64(val)
+	case json.Number:
+		f, _ := val.Float64()
+		return f
 	case string:
 		if f, err := strconv.ParseFloat(val, 64); err == nil {
 			return f
@@ -561,30 +720,29 @@ func (r *bitableBillRepository) convertRecordToBill(record map[string]interface{
 		ID:          recordID,
 		RecordID:    recordID, // Bitable record_id is the same as _id
 		Description: getStringField(fields, r.config.FieldDescription),
-		Amount:      getNumberField(fields, r.config.FieldAmount),
+		Amount:      domain.RoundAmount(getNumberField(fields, r.config.FieldAmount)),
 		Category:    getStringField(fields, r.config.FieldType),
 		UserName:    getStringField(fields, r.config.FieldUserName),
 		OriginalMsg: getStringField(fields, r.config.FieldOriginalMsg),
 	}
-
-	// Parse date - 支持毫秒时间戳（新格式）和字符串格式（向后兼容）
-	if dateVal, ok := fields[r.config.FieldDate]; ok {
-		if dateTimestamp, ok := dateVal.(int64); ok {
-			// 毫秒时间戳格式
-			bill.Date = time.UnixMilli(dateTimestamp)
-		} else if dateTimestamp, ok := dateVal.(float64); ok {
-			// 处理 JSON 数字可能被解析为 float64 的情况
-			bill.Date = time.UnixMilli(int64(dateTimestamp))
-		} else if dateStr, ok := dateVal.(string); ok && dateStr != "" {
-			// 向后兼容：字符串格式
-			if t, err := time.Parse("2006-01-02 15:04:05", dateStr); err == nil {
-				bill.Date = t
-			} else if t, err := time.Parse("2006-01-02", dateStr); err == nil {
-				bill.Date = t
-			}
+	if r.config.FieldAccount != "" {
+		bill.Account = getStringField(fields, r.config.FieldAccount)
+	}
+	if r.config.FieldTags != "" {
+		bill.Tags = getMultiSelectField(fields, r.config.FieldTags)
+	}
+	if r.config.FieldReimbursable != "" {
+		reimbursable := getBoolField(fields, r.config.FieldReimbursable)
+		bill.Reimbursable = &reimbursable
+	}
+	if r.config.FieldReimbursedAt != "" {
+		if reimbursedAt := getDateField(fields, r.config.FieldReimbursedAt, r.location); !reimbursedAt.IsZero() {
+			bill.ReimbursedAt = &reimbursedAt
 		}
 	}
 
+	bill.Date = getDateField(fields, r.config.FieldDate, r.location)
+
 	// Parse bill type from Chinese (收支类型存储在 FieldCategory)
 	if typeStr := getStringField(fields, r.config.FieldCategory); typeStr != "" {
 		if typeStr == "收入" {
@@ -597,28 +755,94 @@ func (r *bitableBillRepository) convertRecordToBill(record map[string]interface{
 	return bill, nil
 }
 
-// Helper functions to extract field values
-func getStringField(fields map[string]interface{}, fieldName string) string {
-	if val, ok := fields[fieldName]; ok {
-		// Handle string format (direct value)
-		if str, ok := val.(string); ok {
-			return str
+// unwrapFieldValue 展开接口返回的包装值，得到字符串、数字等基本值
+// 文本字段可能是多段富文本 [{"text": "午", "type": "text"}, {"text": "饭", ...}]，各段拼接；
+// 公式、查找引用字段为 {"type": 2, "value": [30]}，取其中的第一个值
+func unwrapFieldValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if inner, ok := v["value"]; ok {
+			return unwrapFieldValue(inner)
+		}
+		if text, ok := v["text"]; ok {
+			return text
+		}
+		return nil
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
 		}
-		// Handle array format from BatchGet API: [{"text": "value", "type": "text"}]
-		if arr, ok := val.([]interface{}); ok && len(arr) > 0 {
-			if item, ok := arr[0].(map[string]interface{}); ok {
-				if text, ok := item["text"].(string); ok {
-					return text
-				}
+		var sb strings.Builder
+		for _, item := range v {
+			segment, ok := item.(map[string]interface{})
+			if !ok {
+				break
+			}
+			text, ok := segment["text"].(string)
+			if !ok {
+				break
 			}
+			sb.WriteString(text)
 		}
+		if sb.Len() > 0 {
+			return sb.String()
+		}
+		return unwrapFieldValue(v[0])
+	default:
+		return val
+	}
+}
+
+// Helper functions to extract field values
+func getStringField(fields map[string]interface{}, fieldName string) string {
+	if str, ok := unwrapFieldValue(fields[fieldName]).(string); ok {
+		return str
 	}
 	return ""
 }
 
 func getNumberField(fields map[string]interface{}, fieldName string) float64 {
-	if val, ok := fields[fieldName]; ok {
-		return toFloat64(val)
+	return toFloat64(unwrapFieldValue(fields[fieldName]))
+}
+
+// getBoolField 读取复选框字段，未勾选时接口不返回该字段
+func getBoolField(fields map[string]interface{}, fieldName string) bool {
+	checked, _ := unwrapFieldValue(fields[fieldName]).(bool)
+	return checked
+}
+
+// getDateField 解析日期字段：支持毫秒时间戳（新格式）和字符串格式（向后兼容），结果为 loc 时区的时间
+func getDateField(fields map[string]interface{}, fieldName string, loc *time.Location) time.Time {
+	switch v := unwrapFieldValue(fields[fieldName]).(type) {
+	case int64:
+		return time.UnixMilli(v).In(loc)
+	case float64:
+		// JSON 数字会被解析为 float64
+		return time.UnixMilli(int64(v)).In(loc)
+	case json.Number:
+		if ms, err := v.Int64(); err == nil {
+			return time.UnixMilli(ms).In(loc)
+		}
+	case string:
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.UnixMilli(ms).In(loc)
+		}
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", v, loc); err == nil {
+			return t
+		}
+		if t, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+			return t
+		}
 	}
-	return 0
+	return time.Time{}
+}
+
+// logFor 返回请求上下文中的日志记录器，日志行带有消息的关联 ID
+func (r *bitableBillRepository) logFor(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, logComponent)
+}
+
+// opLog 返回记录一次多维表格请求结果的日志记录器，带有数据表 ID 和从 start 起的耗时
+func (r *bitableBillRepository) opLog(ctx context.Context, start time.Time) logger.Logger {
+	return r.logFor(ctx).WithFields(logger.Fields{"table_id": r.tableID, "latency_ms": time.Since(start).Milliseconds()})
 }
@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// 表格中的日期转换到配置的时区，日期部分与机器时区无关
+func TestGetDateFieldLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// 2024-04-30 20:00 UTC 是上海 5 月 1 日 04:00
+	ms := time.Date(2024, time.April, 30, 20, 0, 0, 0, time.UTC).UnixMilli()
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"int64", ms, "2024-05-01 04:00"},
+		{"float64", float64(ms), "2024-05-01 04:00"},
+		{"json number", json.Number("1714507200000"), "2024-05-01 04:00"},
+		{"string timestamp", "1714507200000", "2024-05-01 04:00"},
+		{"date time string", "2024-05-01 09:30:00", "2024-05-01 09:30"},
+		{"date string", "2024-05-01", "2024-05-01 00:00"},
+	}
+	for _, tt := range tests {
+		got := getDateField(map[string]interface{}{"日期": tt.value}, "日期", shanghai)
+		if got.Location() != shanghai || got.Format("2006-01-02 15:04") != tt.want {
+			t.Errorf("%s: got %s, want %s in Shanghai", tt.name, got, tt.want)
+		}
+	}
+	if got := getDateField(map[string]interface{}{}, "日期", shanghai); !got.IsZero() {
+		t.Errorf("missing field = %s, want zero", got)
+	}
+}
+
+// 共享账本查询时不按记录者过滤
+func TestSearchUserName(t *testing.T) {
+	r := &bitableBillRepository{config: &config.FeishuConfig{}}
+	if got := r.searchUserName("张三"); got != "张三" {
+		t.Errorf("searchUserName = %q, want 张三", got)
+	}
+	r.config.SharedLedger = true
+	if got := r.searchUserName("张三"); got != "" {
+		t.Errorf("searchUserName on a shared ledger = %q, want empty", got)
+	}
+}
+
+// 只写入设置了的字段，收支类型转换为中文，日期为毫秒时间戳
+func TestUpdateBillFields(t *testing.T) {
+	repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, bitableOK(map[string]interface{}{"record": map[string]interface{}{"record_id": "rec1"}})
+	})
+	date := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	bill := &domain.Bill{RecordID: "rec1", Amount: 35.456, Type: domain.BillTypeIncome, Category: "工资", Date: date, OriginalMsg: "改成35"}
+	if err := repo.UpdateBill(context.Background(), bill); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.requests()
+	if len(calls) != 1 || calls[0].Method != http.MethodPut || !strings.HasSuffix(calls[0].Path, "/apps/app_ledger/tables/tbl_bills/records/rec1") {
+		t.Fatalf("requests = %+v, want one PUT of rec1", calls)
+	}
+	want := map[string]interface{}{
+		"金额":   35.46,
+		"收支类型": "收入",
+		"分类":   "工资",
+		"日期":   float64(date.UnixMilli()),
+		"原始消息": "改成35",
+	}
+	if got := calls[0].Body["fields"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+// 旧账单只有 ID 时，ID 即为 record_id
+func TestUpdateBillByID(t *testing.T) {
+	repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, bitableOK(map[string]interface{}{"record": map[string]interface{}{"record_id": "rec2"}})
+	})
+	bill := &domain.Bill{ID: "rec2", Description: "晚饭"}
+	if err := repo.UpdateBill(context.Background(), bill); err != nil {
+		t.Fatal(err)
+	}
+	if bill.RecordID != "rec2" || !strings.HasSuffix(fake.requests()[0].Path, "/records/rec2") {
+		t.Errorf("updated %s, want rec2", fake.requests()[0].Path)
+	}
+	if got := fake.requests()[0].Body["fields"]; !reflect.DeepEqual(got, map[string]interface{}{"描述": "晚饭"}) {
+		t.Errorf("fields = %v, want only the description", got)
+	}
+}
+
+func TestUpdateBillErrors(t *testing.T) {
+	repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{"code": 1254043, "msg": "RecordIdNotFound"}
+	})
+
+	err := repo.UpdateBill(context.Background(), &domain.Bill{RecordID: "rec404", Amount: 35})
+	if !errors.Is(err, domain.ErrBillNotFound) {
+		t.Errorf("UpdateBill of a missing record = %v, want ErrBillNotFound", err)
+	}
+	if err := repo.UpdateBill(context.Background(), &domain.Bill{RecordID: "rec1"}); err == nil {
+		t.Error("UpdateBill without fields succeeded")
+	}
+	if err := repo.UpdateBill(context.Background(), &domain.Bill{Amount: 35}); err == nil {
+		t.Error("UpdateBill without an ID succeeded")
+	}
+	if n := len(fake.requests()); n != 1 {
+		t.Errorf("made %d requests, want only the missing record update", n)
+	}
+}
+
+// batchGetResponse 返回 BatchGet 接口的响应，records 为 record_id 到字段的映射
+func batchGetResponse(records map[string]map[string]interface{}) map[string]interface{} {
+	items := []map[string]interface{}{}
+	for id, fields := range records {
+		items = append(items, map[string]interface{}{"record_id": id, "fields": fields})
+	}
+	return bitableOK(map[string]interface{}{"records": items})
+}
+
+// BatchGet 返回的字段值可能是富文本、人员或公式包装的值
+func TestGetBillBatchGetPayload(t *testing.T) {
+	date := time.Date(2025, time.March, 1, 12, 30, 0, 0, time.UTC)
+	var payload map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"描述": [{"text": "午饭", "type": "text"}, {"text": "（公司楼下）", "type": "text"}],
+		"金额": {"type": 2, "value": [30.456]},
+		"分类": "餐饮",
+		"收支类型": "支出",
+		"日期": `+strconv.FormatInt(date.UnixMilli(), 10)+`,
+		"记录者": [{"text": "张三", "type": "text"}],
+		"原始消息": [{"text": "午饭 30.456", "type": "text"}]
+	}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, batchGetResponse(map[string]map[string]interface{}{"recABC": payload})
+	})
+
+	bill, err := repo.GetBill(context.Background(), "recABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &domain.Bill{
+		ID:          "recABC",
+		RecordID:    "recABC",
+		Description: "午饭（公司楼下）",
+		Amount:      30.46,
+		Type:        domain.BillTypeExpense,
+		Category:    "餐饮",
+		UserName:    "张三",
+		OriginalMsg: "午饭 30.456",
+		Date:        date,
+	}
+	if !reflect.DeepEqual(bill, want) {
+		t.Errorf("GetBill = %+v, want %+v", bill, want)
+	}
+	calls := fake.requests()
+	if len(calls) != 1 || !strings.HasSuffix(calls[0].Path, "/records/batch_get") || fmt.Sprint(calls[0].Body["record_ids"]) != "[recABC]" {
+		t.Errorf("requests = %+v, want one batch_get of recABC", calls)
+	}
+}
+
+func TestGetBillNotFound(t *testing.T) {
+	repo, _ := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, batchGetResponse(nil)
+	})
+	if _, err := repo.GetBill(context.Background(), "rec404"); !errors.Is(err, domain.ErrBillNotFound) {
+		t.Errorf("GetBill of a missing record = %v, want ErrBillNotFound", err)
+	}
+
+	// 软删除的记录视为不存在
+	repo, _ = newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, batchGetResponse(map[string]map[string]interface{}{"recDEL": {"描述": "午饭", "删除时间": float64(time.Now().UnixMilli())}})
+	})
+	repo.config.SoftDelete = true
+	repo.config.FieldDeletedAt = "删除时间"
+	if _, err := repo.GetBill(context.Background(), "recDEL"); !errors.Is(err, domain.ErrBillNotFound) {
+		t.Errorf("GetBill of a deleted record = %v, want ErrBillNotFound", err)
+	}
+}
+
+// searchRecordsPage 返回一页搜索结果，每笔账单为 描述、金额、收支类型
+func searchRecordsPage(pageToken string, bills ...[3]interface{}) map[string]interface{} {
+	items := []map[string]interface{}{}
+	for i, b := range bills {
+		items = append(items, map[string]interface{}{
+			"record_id": fmt.Sprintf("rec_%v_%d", b[0], i),
+			"fields":    map[string]interface{}{"描述": b[0], "金额": b[1], "收支类型": b[2], "记录者": "张三"},
+		})
+	}
+	return bitableOK(map[string]interface{}{"items": items, "has_more": pageToken != "", "page_token": pageToken})
+}
+
+// 合计覆盖全部分页，再按金额取前 N 笔
+func TestQueryTransactionsPages(t *testing.T) {
+	pages := []map[string]interface{}{
+		searchRecordsPage("p2", [3]interface{}{"午饭", 30.1, "支出"}, [3]interface{}{"工资", 8000, "收入"}),
+		searchRecordsPage("p3", [3]interface{}{"房租", 3000, "支出"}, [3]interface{}{"咖啡", 18.2, "支出"}),
+		searchRecordsPage("", [3]interface{}{"红包", 200, "收入"}, [3]interface{}{"打车", 45.3, "支出"}),
+	}
+	repo, fake := newFakeBitableRepo(t, func(n int, _ bitableCall) (int, interface{}) {
+		return http.StatusOK, pages[n]
+	})
+	start := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.March, 31, 23, 59, 59, 0, time.UTC)
+
+	bills, income, expense, err := repo.QueryTransactions(context.Background(), "张三", start, end, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if income != 8200 || expense != 3093.6 {
+		t.Errorf("totals = %v income, %v expense, want 8200 and 3093.6", income, expense)
+	}
+	if len(bills) != 2 || bills[0].Description != "工资" || bills[1].Description != "房租" {
+		t.Errorf("top bills = %v, want 工资 and 房租", bills)
+	}
+
+	calls := fake.requests()
+	if len(calls) != 3 {
+		t.Fatalf("made %d search requests, want 3", len(calls))
+	}
+	// 日期范围和记录者在服务端过滤
+	filter, _ := calls[0].Body["filter"].(map[string]interface{})
+	conditions := fmt.Sprint(filter["conditions"])
+	for _, want := range []string{"isGreater", "isLess", strconv.FormatInt(start.UnixMilli(), 10), strconv.FormatInt(end.UnixMilli(), 10), "记录者", "张三"} {
+		if !strings.Contains(conditions, want) {
+			t.Errorf("search conditions %s miss %s", conditions, want)
+		}
+	}
+}
+
+// 创建后账单的 ID 即 record_id，修改和删除都直接使用它
+func TestBitableBillRoundTrip(t *testing.T) {
+	repo, fake := newFakeBitableRepo(t, func(_ int, call bitableCall) (int, interface{}) {
+		if strings.HasSuffix(call.Path, "/batch_delete") {
+			return http.StatusOK, bitableOK(map[string]interface{}{"records": []map[string]interface{}{{"record_id": "recNew", "deleted": true}}})
+		}
+		// 新增和修改都返回同一条记录
+		return http.StatusOK, bitableOK(map[string]interface{}{"record": map[string]interface{}{"record_id": "recNew"}})
+	})
+	ctx := context.Background()
+
+	bill := &domain.Bill{Description: "午饭", Amount: 35, Type: domain.BillTypeExpense, Category: "餐饮", UserName: "张三", Date: time.Now()}
+	if err := repo.CreateBill(ctx, bill); err != nil {
+		t.Fatal(err)
+	}
+	if bill.ID != "recNew" || bill.RecordID != "recNew" {
+		t.Fatalf("created ID = %q, RecordID = %q, want recNew", bill.ID, bill.RecordID)
+	}
+	if err := repo.UpdateBill(ctx, &domain.Bill{ID: bill.ID, Amount: 40}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteBill(ctx, bill.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.requests()
+	if len(calls) != 3 {
+		t.Fatalf("requests = %+v, want create, update and delete", calls)
+	}
+	if calls[1].Method != http.MethodPut || !strings.HasSuffix(calls[1].Path, "/records/recNew") {
+		t.Errorf("update = %s %s, want PUT of recNew", calls[1].Method, calls[1].Path)
+	}
+	if got := fmt.Sprint(calls[2].Body["records"]); got != "[recNew]" {
+		t.Errorf("deleted %s, want recNew", got)
+	}
+}
+
+// 没有过滤条件时列出记录，有条件时使用搜索接口
+func TestListBillsEndpoint(t *testing.T) {
+	repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, bitableOK(map[string]interface{}{"items": []map[string]interface{}{
+			{"record_id": "rec1", "fields": map[string]interface{}{"描述": "午饭", "金额": 30, "收支类型": "支出", "记录者": "张三"}},
+		}})
+	})
+	ctx := context.Background()
+
+	bills, total, err := repo.ListBills(ctx, "", nil, nil, nil, nil, 0, 10)
+	if err != nil || total != 1 || bills[0].RecordID != "rec1" || bills[0].Description != "午饭" {
+		t.Fatalf("ListBills = %+v, %d, %v", bills, total, err)
+	}
+	if _, _, err := repo.ListBills(ctx, "张三", nil, nil, nil, nil, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.requests()
+	if len(calls) != 2 {
+		t.Fatalf("made %d requests, want 2", len(calls))
+	}
+	if calls[0].Method != http.MethodGet || !strings.HasSuffix(calls[0].Path, "/tables/tbl_bills/records") {
+		t.Errorf("unfiltered request = %s %s, want a list", calls[0].Method, calls[0].Path)
+	}
+	if calls[1].Method != http.MethodPost || !strings.HasSuffix(calls[1].Path, "/tables/tbl_bills/records/search") || calls[1].Body["filter"] == nil {
+		t.Errorf("filtered request = %s %s %v, want a search", calls[1].Method, calls[1].Path, calls[1].Body)
+	}
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// attachmentFieldValue 上传账单中尚未上传的附件，返回附件字段的值
+// 未配置附件字段或没有上传成功的附件时返回 false，上传失败只记录日志，不影响记账
+func (r *bitableBillRepository) attachmentFieldValue(ctx context.Context, bill *domain.Bill) ([]map[string]string, bool) {
+	if r.config.FieldAttachment == "" || len(bill.Attachments) == 0 {
+		return nil, false
+	}
+
+	var value []map[string]string
+	for i := range bill.Attachments {
+		attachment := &bill.Attachments[i]
+		if attachment.FileToken == "" && len(attachment.Data) > 0 {
+			fileToken, err := r.feishuService.UploadMediaToBitable(ctx, r.token(), attachment.FileName, attachment.Data)
+			r.refreshOnTokenError(ctx, err)
+			if err != nil {
+				r.logFor(ctx).Warn("Failed to upload attachment, skipping: name=%s, err=%v", attachment.FileName, err)
+				continue
+			}
+			attachment.FileToken = fileToken
+			// 上传后不再保留文件内容，避免写入本地库和写入队列
+			attachment.Data = nil
+		}
+		if attachment.FileToken != "" {
+			value = append(value, map[string]string{"file_token": attachment.FileToken})
+		}
+	}
+	return value, len(value) > 0
+}
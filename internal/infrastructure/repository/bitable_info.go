@@ -0,0 +1,26 @@
+package repository
+
+import "github.com/wyg1997/LedgerBot/internal/domain"
+
+// BitableInfo describes the default table of a bitable bill repository
+type BitableInfo struct {
+	AppToken  string
+	TableID   string
+	IsWiki    bool  // 表格地址是 wiki 链接，AppToken 由 wiki 节点解析得到
+	SchemaErr error // 启动时字段校验的错误，校验通过时为 nil
+}
+
+// DescribeBitable returns the default table of a repository created by NewBitableBillRepository;
+// ok is false for other repositories
+func DescribeBitable(repo domain.BillRepository) (info BitableInfo, ok bool) {
+	var r *bitableBillRepository
+	switch v := repo.(type) {
+	case *bitableBillRepository:
+		r = v
+	case *ledgerBillRepository:
+		r = v.defaultRepo
+	default:
+		return BitableInfo{}, false
+	}
+	return BitableInfo{AppToken: r.token(), TableID: r.tableID, IsWiki: r.nodeToken != "", SchemaErr: r.schemaErr}, true
+}
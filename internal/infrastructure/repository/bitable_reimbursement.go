@@ -0,0 +1,13 @@
+package repository
+
+import "github.com/wyg1997/LedgerBot/internal/domain"
+
+// setReimbursementFields 写入可报销和报销到账时间字段，未配置字段或账单未设置时跳过
+func (r *bitableBillRepository) setReimbursementFields(fields map[string]interface{}, bill *domain.Bill) {
+	if r.config.FieldReimbursable != "" && bill.Reimbursable != nil {
+		fields[r.config.FieldReimbursable] = *bill.Reimbursable
+	}
+	if r.config.FieldReimbursedAt != "" && bill.ReimbursedAt != nil {
+		fields[r.config.FieldReimbursedAt] = bill.ReimbursedAt.UnixMilli()
+	}
+}
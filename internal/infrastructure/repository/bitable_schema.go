@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+)
+
+// schemaRequirement 描述一个配置字段的校验要求
+type schemaRequirement struct {
+	envName   string // 对应的环境变量，便于用户定位配置
+	fieldName string
+	fieldType int      // 期望的字段类型，0 表示不校验类型
+	typeName  string   // 期望类型的可读名称
+	options   []string // 单选字段需要包含的选项
+}
+
+// schemaRequirements 返回当前配置下账单表需要的字段
+func (r *bitableBillRepository) schemaRequirements() []schemaRequirement {
+	requirements := []schemaRequirement{
+		{envName: "FEISHU_FIELD_DESCRIPTION", fieldName: r.config.FieldDescription},
+		{envName: "FEISHU_FIELD_AMOUNT", fieldName: r.config.FieldAmount, fieldType: feishu.BitableFieldTypeNumber, typeName: "数字"},
+		{envName: "FEISHU_FIELD_TYPE", fieldName: r.config.FieldType, fieldType: feishu.BitableFieldTypeSingleSelect, typeName: "单选", options: domain.DefaultCategories},
+		{envName: "FEISHU_FIELD_CATEGORY", fieldName: r.config.FieldCategory, fieldType: feishu.BitableFieldTypeSingleSelect, typeName: "单选", options: []string{"支出", "收入"}},
+		{envName: "FEISHU_FIELD_DATE", fieldName: r.config.FieldDate, fieldType: feishu.BitableFieldTypeDateTime, typeName: "日期"},
+		{envName: "FEISHU_FIELD_USER_NAME", fieldName: r.config.FieldUserName},
+	}
+	// 原始消息字段是可选的，未配置时不校验
+	if r.config.FieldOriginalMsg != "" {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_ORIGINAL_MSG", fieldName: r.config.FieldOriginalMsg})
+	}
+	if r.config.FieldAccount != "" {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_ACCOUNT", fieldName: r.config.FieldAccount})
+	}
+	if r.config.FieldTags != "" {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_TAGS", fieldName: r.config.FieldTags, fieldType: feishu.BitableFieldTypeMultiSelect, typeName: "多选"})
+	}
+	if r.config.FieldReimbursable != "" {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_REIMBURSABLE", fieldName: r.config.FieldReimbursable, fieldType: feishu.BitableFieldTypeCheckbox, typeName: "复选框"})
+	}
+	if r.config.FieldReimbursedAt != "" {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_REIMBURSED_AT", fieldName: r.config.FieldReimbursedAt, fieldType: feishu.BitableFieldTypeDateTime, typeName: "日期"})
+	}
+	if r.config.FieldAttachment != "" {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_ATTACHMENT", fieldName: r.config.FieldAttachment, fieldType: feishu.BitableFieldTypeAttachment, typeName: "附件"})
+	}
+	if r.softDeleteEnabled() {
+		requirements = append(requirements, schemaRequirement{envName: "FEISHU_FIELD_DELETED_AT", fieldName: r.config.FieldDeletedAt, fieldType: feishu.BitableFieldTypeDateTime, typeName: "日期"})
+	}
+	return requirements
+}
+
+// ensureSchema 创建缺失的字段，并为单选字段补齐缺失的选项
+// 已存在且类型正确的字段不会被修改，重复执行是安全的
+func (r *bitableBillRepository) ensureSchema(ctx context.Context) error {
+	fields, err := r.feishuService.ListTableFields(ctx, r.token(), r.tableID)
+	if err != nil {
+		return fmt.Errorf("failed to list bitable fields: %v", err)
+	}
+
+	byName := make(map[string]*feishu.BitableField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	for _, req := range r.schemaRequirements() {
+		field, ok := byName[req.fieldName]
+		if !ok {
+			fieldType := req.fieldType
+			if fieldType == 0 {
+				fieldType = feishu.BitableFieldTypeText
+			}
+			if err := r.feishuService.CreateTableField(ctx, r.token(), r.tableID, req.fieldName, fieldType, req.options); err != nil {
+				return fmt.Errorf("failed to create field %q: %v", req.fieldName, err)
+			}
+			r.logFor(ctx).Info("Created bitable field: name=%s, type=%d, options=%v", req.fieldName, fieldType, req.options)
+			continue
+		}
+
+		// 类型不符的字段交给 validateSchema 报告，这里不做修改
+		if len(req.options) == 0 || field.Type != req.fieldType {
+			continue
+		}
+		var missing []string
+		for _, opt := range req.options {
+			if !field.HasOption(opt) {
+				missing = append(missing, opt)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if err := r.feishuService.AddFieldOptions(ctx, r.token(), r.tableID, field, missing); err != nil {
+			return fmt.Errorf("failed to add options to field %q: %v", req.fieldName, err)
+		}
+		r.logFor(ctx).Info("Added bitable field options: field=%s, options=%v", req.fieldName, missing)
+	}
+	return nil
+}
+
+// validateSchema 校验多维表格中是否存在所有配置的字段，以及关键字段的类型
+func (r *bitableBillRepository) validateSchema(ctx context.Context) error {
+	fields, err := r.feishuService.ListTableFields(ctx, r.token(), r.tableID)
+	if err != nil {
+		return fmt.Errorf("failed to list bitable fields: %v", err)
+	}
+
+	byName := make(map[string]*feishu.BitableField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	var problems []string
+	for _, req := range r.schemaRequirements() {
+		field, ok := byName[req.fieldName]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("字段 %q 不存在（%s）", req.fieldName, req.envName))
+			continue
+		}
+		if req.fieldType != 0 && field.Type != req.fieldType {
+			problems = append(problems, fmt.Sprintf("字段 %q 应为%s类型，实际类型为 %d（%s）", req.fieldName, req.typeName, field.Type, req.envName))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("bitable schema mismatch: %s", strings.Join(problems, "; "))
+	}
+
+	r.logFor(ctx).Info("Bitable schema validated: app_token=%s, table_id=%s, fields=%d", r.token(), r.tableID, len(fields))
+	return nil
+}
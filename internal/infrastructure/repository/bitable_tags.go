@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+)
+
+// tagsFieldValue 返回标签字段的值，多选字段写入选项名称数组，如 ["旅行", "报销"]
+// 未配置标签字段或账单没有标签时返回 false；开启 FEISHU_AUTO_CREATE_FIELDS 时先补齐缺失的选项
+func (r *bitableBillRepository) tagsFieldValue(ctx context.Context, bill *domain.Bill) ([]string, bool) {
+	if r.config.FieldTags == "" {
+		return nil, false
+	}
+	tags := domain.NormalizeTags(bill.Tags)
+	if len(tags) == 0 {
+		return nil, false
+	}
+	if r.config.AutoCreateFields {
+		r.ensureTagOptions(ctx, tags)
+	}
+	return tags, true
+}
+
+// ensureTagOptions 为标签字段追加尚不存在的选项，失败只记录日志，不影响记账
+func (r *bitableBillRepository) ensureTagOptions(ctx context.Context, tags []string) {
+	// 并发写入相同的新标签时避免重复追加选项
+	r.tagOptionsMu.Lock()
+	defer r.tagOptionsMu.Unlock()
+
+	fields, err := r.feishuService.ListTableFields(ctx, r.token(), r.tableID)
+	r.refreshOnTokenError(ctx, err)
+	if err != nil {
+		r.logFor(ctx).Warn("Failed to list bitable fields, skipping tag options: %v", err)
+		return
+	}
+
+	var field *feishu.BitableField
+	for _, f := range fields {
+		if f.Name == r.config.FieldTags {
+			field = f
+			break
+		}
+	}
+	if field == nil || field.Type != feishu.BitableFieldTypeMultiSelect {
+		r.logFor(ctx).Warn("Tag field is missing or not a multi-select field, skipping tag options: field=%s", r.config.FieldTags)
+		return
+	}
+
+	var missing []string
+	for _, tag := range tags {
+		if !field.HasOption(tag) {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if err := r.feishuService.AddFieldOptions(ctx, r.token(), r.tableID, field, missing); err != nil {
+		r.logFor(ctx).Warn("Failed to add tag options: field=%s, options=%v, err=%v", r.config.FieldTags, missing, err)
+		return
+	}
+	r.logFor(ctx).Info("Added tag options: field=%s, options=%v", r.config.FieldTags, missing)
+}
+
+// getMultiSelectField 读取多选字段的选项名称，接口返回 ["旅行", "报销"]
+func getMultiSelectField(fields map[string]interface{}, fieldName string) []string {
+	var values []string
+	switch v := fields[fieldName].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := unwrapFieldValue(item).(string); ok {
+				values = append(values, name)
+			}
+		}
+	case []string:
+		values = v
+	case string:
+		values = []string{v}
+	}
+	return domain.NormalizeTags(values)
+}
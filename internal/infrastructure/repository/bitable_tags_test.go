@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// createdRecord 返回新增记录接口的响应
+func createdRecord(recordID string) map[string]interface{} {
+	return bitableOK(map[string]interface{}{"record": map[string]interface{}{"record_id": recordID}})
+}
+
+var taggedBill = domain.Bill{
+	Description: "机票",
+	Amount:      1200,
+	Type:        domain.BillTypeExpense,
+	Category:    "交通",
+	Date:        time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC),
+	UserName:    "张三",
+}
+
+// 多选字段写入去重后的选项名称数组
+func TestCreateBillTagsPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		fieldTags string
+		tags      []string
+		want      interface{} // nil 表示不写入标签字段
+	}{
+		{"tags", "标签", []string{"#旅行", " 报销 ", "旅行", "＃出差"}, []interface{}{"旅行", "报销", "出差"}},
+		{"no tags", "标签", nil, nil},
+		{"only blank tags", "标签", []string{"#", " "}, nil},
+		{"field not configured", "", []string{"旅行"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+				return http.StatusOK, createdRecord("rec1")
+			})
+			repo.config.FieldTags = tt.fieldTags
+			bill := taggedBill
+			bill.Tags = tt.tags
+			if err := repo.CreateBill(context.Background(), &bill); err != nil {
+				t.Fatal(err)
+			}
+
+			fields, _ := fake.requests()[0].Body["fields"].(map[string]interface{})
+			got, ok := fields["标签"]
+			switch {
+			case tt.want == nil && ok:
+				t.Errorf("标签 = %v, want the field omitted", got)
+			case tt.want != nil && !reflect.DeepEqual(got, tt.want):
+				t.Errorf("标签 = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tagField 返回字段列表接口中的多选标签字段
+func tagField(options ...string) map[string]interface{} {
+	opts := []map[string]interface{}{}
+	for i, name := range options {
+		opts = append(opts, map[string]interface{}{"id": "opt" + string(rune('A'+i)), "name": name, "color": i})
+	}
+	return map[string]interface{}{
+		"field_id":   "fldTags",
+		"field_name": "标签",
+		"type":       4,
+		"property":   map[string]interface{}{"options": opts},
+	}
+}
+
+// 开启自动创建字段时先追加缺失的选项，更新时带上已有选项
+func TestCreateBillAddsTagOptions(t *testing.T) {
+	repo, fake := newFakeBitableRepo(t, func(_ int, call bitableCall) (int, interface{}) {
+		switch {
+		case call.Method == http.MethodGet:
+			return http.StatusOK, bitableOK(map[string]interface{}{"items": []interface{}{tagField("旅行")}, "has_more": false})
+		case call.Method == http.MethodPut:
+			return http.StatusOK, bitableOK(map[string]interface{}{"field": tagField("旅行", "报销")})
+		}
+		return http.StatusOK, createdRecord("rec1")
+	})
+	repo.config.FieldTags = "标签"
+	repo.config.AutoCreateFields = true
+	bill := taggedBill
+	bill.Tags = []string{"旅行", "报销"}
+	if err := repo.CreateBill(context.Background(), &bill); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.requests()
+	if len(calls) != 3 || calls[1].Method != http.MethodPut || !strings.HasSuffix(calls[1].Path, "/fields/fldTags") {
+		t.Fatalf("requests = %+v, want list fields, update the tag field, create the record", calls)
+	}
+	property, _ := calls[1].Body["property"].(map[string]interface{})
+	want := []interface{}{
+		map[string]interface{}{"id": "optA", "name": "旅行", "color": float64(0)},
+		map[string]interface{}{"name": "报销"},
+	}
+	if got := property["options"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("options = %v, want the existing option and 报销", got)
+	}
+	if calls[1].Body["type"] != float64(4) || calls[1].Body["field_name"] != "标签" {
+		t.Errorf("field update = %v, want the multi-select 标签 field", calls[1].Body)
+	}
+}
+
+// 选项都已存在或字段类型不对时不更新字段，仍然写入记录
+func TestCreateBillTagOptionsUnchanged(t *testing.T) {
+	tests := []struct {
+		name  string
+		field map[string]interface{}
+	}{
+		{"options exist", tagField("旅行", "报销")},
+		{"not multi-select", map[string]interface{}{"field_id": "fldTags", "field_name": "标签", "type": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, fake := newFakeBitableRepo(t, func(_ int, call bitableCall) (int, interface{}) {
+				if call.Method == http.MethodGet {
+					return http.StatusOK, bitableOK(map[string]interface{}{"items": []interface{}{tt.field}})
+				}
+				return http.StatusOK, createdRecord("rec1")
+			})
+			repo.config.FieldTags = "标签"
+			repo.config.AutoCreateFields = true
+			bill := taggedBill
+			bill.Tags = []string{"旅行", "报销"}
+			if err := repo.CreateBill(context.Background(), &bill); err != nil {
+				t.Fatal(err)
+			}
+			calls := fake.requests()
+			if len(calls) != 2 || calls[1].Method != http.MethodPost {
+				t.Errorf("requests = %+v, want list fields and create the record", calls)
+			}
+		})
+	}
+}
+
+func TestGetMultiSelectField(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []string
+	}{
+		{"option names", []interface{}{"旅行", "报销"}, []string{"旅行", "报销"}},
+		{"wrapped values", []interface{}{map[string]interface{}{"text": "旅行"}, "#报销", "旅行"}, []string{"旅行", "报销"}},
+		{"string slice", []string{"出差"}, []string{"出差"}},
+		{"single string", "旅行", []string{"旅行"}},
+		{"missing", nil, nil},
+	}
+	for _, tt := range tests {
+		got := getMultiSelectField(map[string]interface{}{"标签": tt.value}, "标签")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+const (
+	// dualRetryInterval 本地库写入失败后重试的间隔
+	dualRetryInterval = 30 * time.Second
+	// dualMaxAttempts 本地库写入的最多尝试次数，超过后放弃，由下次启动时的对账修复
+	dualMaxAttempts = 10
+)
+
+// dualWrite 一次待重试的本地库写入
+type dualWrite struct {
+	desc     string
+	attempts int
+	apply    func(ctx context.Context) error
+}
+
+// dualBillRepository 双写仓库：多维表格是主库，便于查看；本地库用于查询统计
+// 写操作以主库为准，本地库写入失败只记录日志并稍后重试，不影响用户操作；
+// 查询、统计从本地库读取。非默认账本的请求直接交给主库
+type dualBillRepository struct {
+	primary   domain.BillRepository
+	secondary domain.BillRepository
+
+	mu      sync.Mutex
+	pending []*dualWrite
+}
+
+// NewDualBillRepository creates a repository that writes to both primary and secondary and reads from secondary.
+// It reconciles the last reconcileDays days from primary into secondary in the background, then keeps
+// retrying failed secondary writes until ctx is cancelled.
+func NewDualBillRepository(ctx context.Context, primary, secondary domain.BillRepository, reconcileDays int) domain.BillRepository {
+	r := &dualBillRepository{primary: primary, secondary: secondary}
+	go r.run(ctx, reconcileDays)
+	return r
+}
+
+// run 启动时对账，之后定期重试失败的本地写入
+func (r *dualBillRepository) run(ctx context.Context, reconcileDays int) {
+	if reconcileDays > 0 {
+		if err := r.reconcile(logger.WithCorrelationID(ctx, "reconcile"), reconcileDays); err != nil {
+			logger.FromContext(ctx, logComponent).Error("Reconcile local bills failed: %v", err)
+		}
+	}
+
+	ticker := time.NewTicker(dualRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.retryPending(ctx)
+		}
+	}
+}
+
+// reconcile 把主库最近几天的记录同步到本地库：补齐缺失的、覆盖不一致的、删除主库中已不存在的
+func (r *dualBillRepository) reconcile(ctx context.Context, days int) error {
+	end := time.Now()
+	start := end.AddDate(0, 0, -days)
+
+	remote, _, _, err := r.primary.QueryTransactions(ctx, "", start, end, 0)
+	if err != nil {
+		return err
+	}
+	local, _, err := r.secondary.ListBills(ctx, "", &start, &end, nil, nil, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	localByID := make(map[string]*domain.Bill, len(local))
+	for _, bill := range local {
+		localByID[bill.RecordID] = bill
+	}
+
+	created, updated, deleted := 0, 0, 0
+	for _, bill := range remote {
+		existing, ok := localByID[bill.RecordID]
+		delete(localByID, bill.RecordID)
+		if !ok {
+			if err := r.secondary.CreateBill(ctx, copyBill(bill)); err != nil {
+				return err
+			}
+			created++
+			continue
+		}
+		if !sameBill(existing, bill) {
+			if err := r.secondary.DeleteBill(ctx, bill.RecordID); err != nil {
+				return err
+			}
+			if err := r.secondary.CreateBill(ctx, copyBill(bill)); err != nil {
+				return err
+			}
+			updated++
+		}
+	}
+
+	// 主库查询达到记录上限时结果不完整，不删除本地记录
+	if cap := searchRecordCap(r.primary); cap > 0 && len(remote) >= cap {
+		logger.FromContext(ctx, logComponent).Warn("Reconcile reached the search cap, skipping local deletions: records=%d", len(remote))
+	} else {
+		for recordID := range localByID {
+			if err := r.secondary.DeleteBill(ctx, recordID); err != nil && !errors.Is(err, domain.ErrBillNotFound) {
+				return err
+			}
+			deleted++
+		}
+	}
+
+	logger.FromContext(ctx, logComponent).Info("Reconciled local bills: days=%d, remote=%d, created=%d, updated=%d, deleted=%d",
+		days, len(remote), created, updated, deleted)
+	return nil
+}
+
+// searchRecordCap 返回主库单次查询的记录上限，未知时返回 0
+func searchRecordCap(repo domain.BillRepository) int {
+	switch b := repo.(type) {
+	case *bitableBillRepository:
+		return b.config.SearchMaxRecords
+	case *ledgerBillRepository:
+		return b.config.SearchMaxRecords
+	}
+	return 0
+}
+
+// sameBill 判断两条记录的内容是否一致
+func sameBill(a, b *domain.Bill) bool {
+	return a.Description == b.Description && a.Amount == b.Amount && a.Type == b.Type &&
+		a.Category == b.Category && a.Date.Equal(b.Date) && a.UserName == b.UserName && a.OriginalMsg == b.OriginalMsg &&
+		a.Account == b.Account && slices.Equal(a.Tags, b.Tags) &&
+		a.IsReimbursable() == b.IsReimbursable() && sameTime(a.ReimbursedAt, b.ReimbursedAt)
+}
+
+// sameTime 比较两个可选时间，都为 nil 时相同
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// copyBill 复制账单，避免本地库修改调用方持有的对象
+func copyBill(bill *domain.Bill) *domain.Bill {
+	c := *bill
+	return &c
+}
+
+// mirror 写入本地库，失败时放入重试队列
+func (r *dualBillRepository) mirror(ctx context.Context, desc string, apply func(ctx context.Context) error) {
+	err := apply(ctx)
+	if err == nil {
+		return
+	}
+	logger.FromContext(ctx, logComponent).Warn("Local bill store write failed, will retry: op=%s, err=%v", desc, err)
+	r.mu.Lock()
+	r.pending = append(r.pending, &dualWrite{desc: desc, attempts: 1, apply: apply})
+	r.mu.Unlock()
+}
+
+// retryPending 重试失败的本地写入
+func (r *dualBillRepository) retryPending(ctx context.Context) {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+
+	var failed []*dualWrite
+	for _, w := range pending {
+		err := w.apply(ctx)
+		if err == nil {
+			continue
+		}
+		w.attempts++
+		if w.attempts >= dualMaxAttempts {
+			logger.FromContext(ctx, logComponent).Error("Giving up local bill store write: op=%s, attempts=%d, err=%v", w.desc, w.attempts, err)
+			continue
+		}
+		failed = append(failed, w)
+	}
+
+	r.mu.Lock()
+	r.pending = append(failed, r.pending...)
+	r.mu.Unlock()
+}
+
+// defaultLedger 判断请求是否属于默认账本，只有默认账本同步到本地库
+func defaultLedger(ctx context.Context, id string) bool {
+	_, ledger := domain.SplitLedgerRecordID(id)
+	return ledger == "" && domain.LedgerFromContext(ctx) == ""
+}
+
+// CreateBill creates the bill in bitable, then mirrors it locally
+func (r *dualBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	if err := r.primary.CreateBill(ctx, bill); err != nil {
+		return err
+	}
+	if defaultLedger(ctx, bill.RecordID) {
+		local := copyBill(bill)
+		r.mirror(ctx, "create "+bill.RecordID, func(ctx context.Context) error {
+			return r.secondary.CreateBill(ctx, copyBill(local))
+		})
+	}
+	return nil
+}
+
+// CreateBills creates the bills in bitable, then mirrors the created ones locally
+func (r *dualBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	err := r.primary.CreateBills(ctx, bills)
+	if domain.LedgerFromContext(ctx) != "" {
+		return err
+	}
+	var created []*domain.Bill
+	for _, bill := range bills {
+		if bill.RecordID != "" {
+			created = append(created, copyBill(bill))
+		}
+	}
+	if len(created) > 0 {
+		r.mirror(ctx, "create batch", func(ctx context.Context) error {
+			local := make([]*domain.Bill, len(created))
+			for i, bill := range created {
+				local[i] = copyBill(bill)
+			}
+			return r.secondary.CreateBills(ctx, local)
+		})
+	}
+	return err
+}
+
+// GetBill reads a single bill from bitable, which is as cheap as reading it locally
+func (r *dualBillRepository) GetBill(ctx context.Context, id string) (*domain.Bill, error) {
+	return r.primary.GetBill(ctx, id)
+}
+
+// UpdateBill updates the bill in bitable, then mirrors the change locally
+func (r *dualBillRepository) UpdateBill(ctx context.Context, bill *domain.Bill) error {
+	if err := r.primary.UpdateBill(ctx, bill); err != nil {
+		return err
+	}
+	if defaultLedger(ctx, bill.RecordID) {
+		recordID := bill.RecordID
+		r.mirror(ctx, "update "+recordID, func(ctx context.Context) error {
+			// 整条记录以主库为准，本地缺失时一并补上
+			latest, err := r.primary.GetBill(ctx, recordID)
+			if err != nil {
+				return err
+			}
+			if err := r.secondary.DeleteBill(ctx, recordID); err != nil && !errors.Is(err, domain.ErrBillNotFound) {
+				return err
+			}
+			return r.secondary.CreateBill(ctx, latest)
+		})
+	}
+	return nil
+}
+
+// DeleteBill deletes the bill from bitable, then from the local store
+func (r *dualBillRepository) DeleteBill(ctx context.Context, id string) error {
+	if err := r.primary.DeleteBill(ctx, id); err != nil {
+		return err
+	}
+	if defaultLedger(ctx, id) {
+		r.mirror(ctx, "delete "+id, func(ctx context.Context) error {
+			if err := r.secondary.DeleteBill(ctx, id); err != nil && !errors.Is(err, domain.ErrBillNotFound) {
+				return err
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// DeleteBills deletes the bills from bitable, then from the local store
+func (r *dualBillRepository) DeleteBills(ctx context.Context, ids []string) error {
+	if err := r.primary.DeleteBills(ctx, ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !defaultLedger(ctx, id) {
+			continue
+		}
+		id := id
+		r.mirror(ctx, "delete "+id, func(ctx context.Context) error {
+			if err := r.secondary.DeleteBill(ctx, id); err != nil && !errors.Is(err, domain.ErrBillNotFound) {
+				return err
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// RestoreBill restores the bill in bitable, then copies it back to the local store
+func (r *dualBillRepository) RestoreBill(ctx context.Context, id string) error {
+	if err := r.primary.RestoreBill(ctx, id); err != nil {
+		return err
+	}
+	if defaultLedger(ctx, id) {
+		r.mirror(ctx, "restore "+id, func(ctx context.Context) error {
+			latest, err := r.primary.GetBill(ctx, id)
+			if err != nil {
+				return err
+			}
+			if err := r.secondary.DeleteBill(ctx, id); err != nil && !errors.Is(err, domain.ErrBillNotFound) {
+				return err
+			}
+			return r.secondary.CreateBill(ctx, latest)
+		})
+	}
+	return nil
+}
+
+// reader 返回读请求使用的仓库
+func (r *dualBillRepository) reader(ctx context.Context) domain.BillRepository {
+	if domain.LedgerFromContext(ctx) != "" {
+		return r.primary
+	}
+	return r.secondary
+}
+
+// ListBills lists bills from the local store
+func (r *dualBillRepository) ListBills(ctx context.Context, userName string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
+	return r.reader(ctx).ListBills(ctx, userName, startDate, endDate, billType, category, offset, limit)
+}
+
+// GetMonthlySummary computes the monthly summary from the local store
+func (r *dualBillRepository) GetMonthlySummary(ctx context.Context, userName string, year, month int) (*domain.MonthlySummary, error) {
+	return r.reader(ctx).GetMonthlySummary(ctx, userName, year, month)
+}
+
+// GetCategories lists categories from the local store
+func (r *dualBillRepository) GetCategories(ctx context.Context, userName string) ([]string, error) {
+	return r.reader(ctx).GetCategories(ctx, userName)
+}
+
+// Ping checks bitable; the local store is always available
+func (r *dualBillRepository) Ping(ctx context.Context) error {
+	return r.primary.Ping(ctx)
+}
+
+// QueryTransactions queries transactions from the local store
+func (r *dualBillRepository) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	return r.reader(ctx).QueryTransactions(ctx, userName, startTime, endTime, topN)
+}
+
+// QueryTransactionsPage queries one page of transactions from the local store
+func (r *dualBillRepository) QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	return r.reader(ctx).QueryTransactionsPage(ctx, userName, startTime, endTime, pageToken, pageSize)
+}
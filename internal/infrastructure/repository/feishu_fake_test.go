@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+)
+
+// bitableCall 记录假开放平台收到的一次请求
+type bitableCall struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// fakeBitable 模拟飞书开放平台的多维表格接口，handle 按调用序号（从 0 开始）返回状态码和响应体
+type fakeBitable struct {
+	mu     sync.Mutex
+	calls  []bitableCall
+	handle func(n int, call bitableCall) (int, interface{})
+}
+
+func (f *fakeBitable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.Contains(r.URL.Path, "/auth/v3/") {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200})
+		return
+	}
+
+	call := bitableCall{Method: r.Method, Path: r.URL.Path}
+	if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+		json.Unmarshal(body, &call.Body)
+	}
+	f.mu.Lock()
+	n := len(f.calls)
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	status, resp := f.handle(n, call)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// requests 返回收到的多维表格请求（不含获取 token 的请求）
+func (f *fakeBitable) requests() []bitableCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]bitableCall(nil), f.calls...)
+}
+
+// testBitableConfig 使用默认字段名的多维表格配置
+func testBitableConfig(t *testing.T, baseURL string) *config.FeishuConfig {
+	return &config.FeishuConfig{
+		// 每个测试使用独立的 app_id，避免 SDK 的全局 token 缓存串用
+		AppID:            "cli_" + t.Name(),
+		AppSecret:        "secret",
+		APIBaseURL:       baseURL,
+		FieldDescription: "描述",
+		FieldAmount:      "金额",
+		FieldType:        "分类",
+		FieldCategory:    "收支类型",
+		FieldDate:        "日期",
+		FieldUserName:    "记录者",
+		FieldOriginalMsg: "原始消息",
+	}
+}
+
+// newFakeBitableRepo 创建连接到假开放平台的多维表格账单仓库
+func newFakeBitableRepo(t *testing.T, handle func(n int, call bitableCall) (int, interface{})) (*bitableBillRepository, *fakeBitable) {
+	t.Helper()
+	fake := &fakeBitable{handle: handle}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := testBitableConfig(t, srv.URL)
+	return &bitableBillRepository{
+		feishuService: feishu.NewFeishuService(cfg),
+		config:        cfg,
+		tableID:       "tbl_bills",
+		appToken:      "app_ledger",
+		location:      time.UTC,
+	}, fake
+}
+
+// bitableOK 返回业务成功的响应体
+func bitableOK(data interface{}) map[string]interface{} {
+	return map[string]interface{}{"code": 0, "msg": "success", "data": data}
+}
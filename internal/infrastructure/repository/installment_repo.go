@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// installmentRepository 把分期计划保存在一个 JSON 文件中，每次修改后整体写回
+type installmentRepository struct {
+	file  string
+	mu    sync.RWMutex
+	plans map[string]*domain.InstallmentPlan
+}
+
+// NewInstallmentRepository creates an installment plan repository stored in file
+// An empty file keeps the plans in memory only
+func NewInstallmentRepository(file string) (domain.InstallmentRepository, error) {
+	repo := &installmentRepository{
+		file:  file,
+		plans: make(map[string]*domain.InstallmentPlan),
+	}
+	if file == "" {
+		return repo, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read installment plans: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &repo.plans); err != nil {
+			return nil, fmt.Errorf("failed to parse installment plans: %v", err)
+		}
+	}
+	return repo, nil
+}
+
+// SavePlan creates or replaces a plan
+func (r *installmentRepository) SavePlan(plan *domain.InstallmentPlan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *plan
+	r.plans[plan.ID] = &c
+	return r.save()
+}
+
+// GetPlan gets a plan by ID
+func (r *installmentRepository) GetPlan(id string) (*domain.InstallmentPlan, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	plan, ok := r.plans[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInstallmentPlanNotFound, id)
+	}
+	c := *plan
+	return &c, nil
+}
+
+// ListPlans lists all plans ordered by creation time
+func (r *installmentRepository) ListPlans() ([]*domain.InstallmentPlan, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	plans := make([]*domain.InstallmentPlan, 0, len(r.plans))
+	for _, plan := range r.plans {
+		c := *plan
+		plans = append(plans, &c)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].CreatedAt.Before(plans[j].CreatedAt)
+	})
+	return plans, nil
+}
+
+// DeletePlan deletes a plan
+func (r *installmentRepository) DeletePlan(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.plans[id]; !ok {
+		return fmt.Errorf("%w: %s", domain.ErrInstallmentPlanNotFound, id)
+	}
+	delete(r.plans, id)
+	return r.save()
+}
+
+// save 先写临时文件再替换，避免写入中途退出时损坏计划文件
+func (r *installmentRepository) save() error {
+	if r.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.MarshalIndent(r.plans, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal installment plans: %v", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write installment plans: %v", err)
+	}
+	return os.Rename(tmp, r.file)
+}
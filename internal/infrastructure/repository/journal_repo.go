@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// operationJournal 按用户保存最近的账单操作，保存在一个 JSON 文件中，每次修改后整体写回
+type operationJournal struct {
+	file string
+	mu   sync.Mutex
+	ops  map[string][]*domain.Operation
+}
+
+// NewOperationJournal creates an operation journal stored in file
+// An empty file keeps the journal in memory only
+func NewOperationJournal(file string) (domain.OperationJournal, error) {
+	j := &operationJournal{
+		file: file,
+		ops:  make(map[string][]*domain.Operation),
+	}
+	if file == "" {
+		return j, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read operation journal: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &j.ops); err != nil {
+			return nil, fmt.Errorf("failed to parse operation journal: %v", err)
+		}
+	}
+	return j, nil
+}
+
+// Push appends an operation, dropping the oldest ones beyond domain.JournalLimit
+func (j *operationJournal) Push(userName string, op *domain.Operation) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ops := append(j.ops[userName], op)
+	if len(ops) > domain.JournalLimit {
+		ops = ops[len(ops)-domain.JournalLimit:]
+	}
+	j.ops[userName] = ops
+	return j.save()
+}
+
+// Last returns the latest operation of a user, nil if there is none
+func (j *operationJournal) Last(userName string) (*domain.Operation, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ops := j.ops[userName]
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	return ops[len(ops)-1], nil
+}
+
+// Pop removes the latest operation of a user
+func (j *operationJournal) Pop(userName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ops := j.ops[userName]
+	if len(ops) == 0 {
+		return nil
+	}
+	if len(ops) == 1 {
+		delete(j.ops, userName)
+	} else {
+		j.ops[userName] = ops[:len(ops)-1]
+	}
+	return j.save()
+}
+
+// Clear removes all operations of a user
+func (j *operationJournal) Clear(userName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.ops[userName]; !ok {
+		return nil
+	}
+	delete(j.ops, userName)
+	return j.save()
+}
+
+// save 先写临时文件再替换，避免写入中途退出时损坏日志文件
+func (j *operationJournal) save() error {
+	if j.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(j.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.MarshalIndent(j.ops, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation journal: %v", err)
+	}
+	tmp := j.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write operation journal: %v", err)
+	}
+	return os.Rename(tmp, j.file)
+}
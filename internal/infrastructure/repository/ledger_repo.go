@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// ledgerBillRepository 按账本把请求路由到不同的多维表格
+// 账本由上下文中的 domain.LedgerFromContext 决定，未配置的账本使用默认表格；
+// 非默认账本的记录 ID 带有 "@账本" 后缀，按记录 ID 查询、修改、删除时据此回到记录所在的表格
+type ledgerBillRepository struct {
+	defaultRepo   *bitableBillRepository
+	feishuService *feishu.FeishuService
+	config        *config.FeishuConfig
+	wikiCache     cache.Cache
+
+	mu    sync.Mutex
+	repos map[string]*bitableBillRepository // 已解析的账本，wiki 节点和字段校验每个账本只做一次
+}
+
+func newLedgerBillRepository(defaultRepo *bitableBillRepository, feishuService *feishu.FeishuService, config *config.FeishuConfig, wikiCache cache.Cache) *ledgerBillRepository {
+	return &ledgerBillRepository{
+		defaultRepo:   defaultRepo,
+		feishuService: feishuService,
+		config:        config,
+		wikiCache:     wikiCache,
+		repos:         make(map[string]*bitableBillRepository),
+	}
+}
+
+// repoFor 返回账本对应的仓库，首次使用时解析表格地址
+// 解析失败不缓存，下次请求时重试
+func (r *ledgerBillRepository) repoFor(ctx context.Context, ledger string) (*bitableBillRepository, string, error) {
+	bitableURL, ok := r.config.ChatTables[ledger]
+	if ledger == "" || !ok {
+		return r.defaultRepo, "", nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if repo, ok := r.repos[ledger]; ok {
+		return repo, ledger, nil
+	}
+
+	logger.FromContext(ctx, logComponent).Info("Resolving ledger table: ledger=%s", ledger)
+	repo, err := newBitableTableRepository(ctx, r.feishuService, r.config, r.wikiCache, bitableURL, r.defaultRepo.location)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open ledger %s: %v", ledger, err)
+	}
+	r.repos[ledger] = repo
+	return repo, ledger, nil
+}
+
+// repoForContext 返回上下文所在账本的仓库
+func (r *ledgerBillRepository) repoForContext(ctx context.Context) (*bitableBillRepository, string, error) {
+	return r.repoFor(ctx, domain.LedgerFromContext(ctx))
+}
+
+// repoForID 返回记录 ID 所在账本的仓库和去掉后缀的记录 ID；没有后缀时使用上下文所在的账本
+func (r *ledgerBillRepository) repoForID(ctx context.Context, id string) (*bitableBillRepository, string, string, error) {
+	recordID, ledger := domain.SplitLedgerRecordID(id)
+	if ledger == "" {
+		ledger = domain.LedgerFromContext(ctx)
+	}
+	repo, ledger, err := r.repoFor(ctx, ledger)
+	return repo, ledger, recordID, err
+}
+
+// tagBills 为非默认账本的记录 ID 加上账本后缀
+func tagBills(bills []*domain.Bill, ledger string) {
+	for _, bill := range bills {
+		if bill == nil || bill.RecordID == "" {
+			continue
+		}
+		tagged := domain.LedgerRecordID(bill.RecordID, ledger)
+		bill.ID = tagged
+		bill.RecordID = tagged
+	}
+}
+
+// CreateBill creates the bill in the ledger of ctx
+func (r *ledgerBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	repo, ledger, err := r.repoForContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := repo.CreateBill(ctx, bill); err != nil {
+		return err
+	}
+	tagBills([]*domain.Bill{bill}, ledger)
+	return nil
+}
+
+// CreateBills creates the bills in the ledger of ctx
+func (r *ledgerBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	repo, ledger, err := r.repoForContext(ctx)
+	if err != nil {
+		return err
+	}
+	err = repo.CreateBills(ctx, bills)
+	// 部分失败时成功的账单也已设置 RecordID
+	tagBills(bills, ledger)
+	return err
+}
+
+// GetBill gets a bill from the ledger its ID belongs to
+func (r *ledgerBillRepository) GetBill(ctx context.Context, id string) (*domain.Bill, error) {
+	repo, ledger, recordID, err := r.repoForID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	bill, err := repo.GetBill(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	tagBills([]*domain.Bill{bill}, ledger)
+	return bill, nil
+}
+
+// UpdateBill updates a bill in the ledger its record ID belongs to
+func (r *ledgerBillRepository) UpdateBill(ctx context.Context, bill *domain.Bill) error {
+	repo, ledger, recordID, err := r.repoForID(ctx, bill.RecordID)
+	if err != nil {
+		return err
+	}
+	bill.RecordID = recordID
+	err = repo.UpdateBill(ctx, bill)
+	tagBills([]*domain.Bill{bill}, ledger)
+	return err
+}
+
+// DeleteBill deletes a bill from the ledger its ID belongs to
+func (r *ledgerBillRepository) DeleteBill(ctx context.Context, id string) error {
+	repo, _, recordID, err := r.repoForID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return repo.DeleteBill(ctx, recordID)
+}
+
+// DeleteBills deletes bills from the ledgers their IDs belong to, one batch per ledger
+func (r *ledgerBillRepository) DeleteBills(ctx context.Context, ids []string) error {
+	var ledgers []string
+	byLedger := make(map[string][]string)
+	repos := make(map[string]*bitableBillRepository)
+	for _, id := range ids {
+		repo, ledger, recordID, err := r.repoForID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, ok := repos[ledger]; !ok {
+			ledgers = append(ledgers, ledger)
+			repos[ledger] = repo
+		}
+		byLedger[ledger] = append(byLedger[ledger], recordID)
+	}
+	for _, ledger := range ledgers {
+		if err := repos[ledger].DeleteBills(ctx, byLedger[ledger]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreBill restores a soft-deleted bill in the ledger its ID belongs to
+func (r *ledgerBillRepository) RestoreBill(ctx context.Context, id string) error {
+	repo, _, recordID, err := r.repoForID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return repo.RestoreBill(ctx, recordID)
+}
+
+// ListBills lists bills in the ledger of ctx
+func (r *ledgerBillRepository) ListBills(ctx context.Context, userName string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
+	repo, ledger, err := r.repoForContext(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	bills, total, err := repo.ListBills(ctx, userName, startDate, endDate, billType, category, offset, limit)
+	tagBills(bills, ledger)
+	return bills, total, err
+}
+
+// GetMonthlySummary gets the monthly summary in the ledger of ctx
+func (r *ledgerBillRepository) GetMonthlySummary(ctx context.Context, userName string, year, month int) (*domain.MonthlySummary, error) {
+	repo, _, err := r.repoForContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetMonthlySummary(ctx, userName, year, month)
+}
+
+// GetCategories gets the categories in the ledger of ctx
+func (r *ledgerBillRepository) GetCategories(ctx context.Context, userName string) ([]string, error) {
+	repo, _, err := r.repoForContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetCategories(ctx, userName)
+}
+
+// tables 返回默认账本（键为空）和已打开的账本
+func (r *ledgerBillRepository) tables() map[string]*bitableBillRepository {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	repos := make(map[string]*bitableBillRepository, len(r.repos)+1)
+	for ledger, repo := range r.repos {
+		repos[ledger] = repo
+	}
+	repos[""] = r.defaultRepo
+	return repos
+}
+
+// Ping 检查默认账本和已打开的账本是否可以访问
+func (r *ledgerBillRepository) Ping(ctx context.Context) error {
+	if err := r.defaultRepo.Ping(ctx); err != nil {
+		return err
+	}
+
+	var errs []error
+	for ledger, repo := range r.tables() {
+		if ledger == "" {
+			continue
+		}
+		if err := repo.Ping(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("ledger %s: %v", ledger, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueryTransactions queries transactions in the ledger of ctx
+func (r *ledgerBillRepository) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	repo, ledger, err := r.repoForContext(ctx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	bills, income, expense, err := repo.QueryTransactions(ctx, userName, startTime, endTime, topN)
+	tagBills(bills, ledger)
+	return bills, income, expense, err
+}
+
+// QueryTransactionsPage queries one page of transactions in the ledger of ctx
+func (r *ledgerBillRepository) QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	repo, ledger, err := r.repoForContext(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	bills, next, err := repo.QueryTransactionsPage(ctx, userName, startTime, endTime, pageToken, pageSize)
+	tagBills(bills, ledger)
+	return bills, next, err
+}
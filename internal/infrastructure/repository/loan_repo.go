@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// loanRepository 借贷索引，按 record_id 记录每笔借款和还款的对方，保存在一个 JSON 文件中
+type loanRepository struct {
+	file    string
+	mu      sync.RWMutex
+	entries map[string]*domain.LoanEntry
+}
+
+// NewLoanRepository creates a loan index stored in file
+// An empty file keeps the index in memory only
+func NewLoanRepository(file string) (domain.LoanRepository, error) {
+	repo := &loanRepository{
+		file:    file,
+		entries: make(map[string]*domain.LoanEntry),
+	}
+	if file == "" {
+		return repo, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read loan index: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &repo.entries); err != nil {
+			return nil, fmt.Errorf("failed to parse loan index: %v", err)
+		}
+	}
+	return repo, nil
+}
+
+// SaveEntry creates or replaces the entry of a bill
+func (r *loanRepository) SaveEntry(entry *domain.LoanEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *entry
+	r.entries[entry.RecordID] = &c
+	return r.save()
+}
+
+// GetEntry gets the entry of a bill by record ID
+func (r *loanRepository) GetEntry(recordID string) (*domain.LoanEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.entries[recordID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrLoanEntryNotFound, recordID)
+	}
+	c := *entry
+	return &c, nil
+}
+
+// ListEntries lists the entries of a user ordered by date
+func (r *loanRepository) ListEntries(userName string) ([]*domain.LoanEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []*domain.LoanEntry
+	for _, entry := range r.entries {
+		if entry.UserName != userName {
+			continue
+		}
+		c := *entry
+		entries = append(entries, &c)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+	return entries, nil
+}
+
+// save 先写临时文件再替换，避免写入中途退出时损坏索引文件
+func (r *loanRepository) save() error {
+	if r.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.MarshalIndent(r.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal loan index: %v", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write loan index: %v", err)
+	}
+	return os.Rename(tmp, r.file)
+}
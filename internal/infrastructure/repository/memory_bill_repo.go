@@ -0,0 +1,365 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/atomicfile"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// memoryBillRepository implements BillRepository in memory, for local development and tests
+// 指定文件时每次修改后整体写入文件，作为本地查询库使用
+type memoryBillRepository struct {
+	mu    sync.RWMutex
+	bills []*domain.Bill // 按创建顺序保存
+	file  string         // 持久化文件，为空时只保存在内存中
+
+	location *time.Location // 按月汇总时月份的时区
+}
+
+// NewMemoryBillRepository creates an empty in-memory bill repository; monthly summaries use months in location
+func NewMemoryBillRepository(location *time.Location) domain.BillRepository {
+	return &memoryBillRepository{location: location}
+}
+
+// NewFileBillRepository creates a bill repository kept in memory and persisted to a JSON file;
+// monthly summaries use months in location. A corrupted file is restored from the backup kept by the previous save
+func NewFileBillRepository(file string, location *time.Location) (domain.BillRepository, error) {
+	repo := &memoryBillRepository{file: file, location: location}
+	fromBackup, err := atomicfile.Load(file, func(data []byte) error {
+		var bills []*domain.Bill
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &bills); err != nil {
+				return err
+			}
+		}
+		repo.bills = bills
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load bills file: %v", err)
+	}
+	if fromBackup {
+		logger.GetLogger(logComponent).Error("Bills file %s is corrupted, restored %d bills from %s", file, len(repo.bills), atomicfile.BackupPath(file))
+	}
+	// 旧版本写入的账单 ID 与 record_id 不同，加载时统一
+	for _, bill := range repo.bills {
+		bill.ID = bill.RecordID
+	}
+	return repo, nil
+}
+
+// save 写入持久化文件，调用方需持有写锁
+// 先写临时文件并同步到磁盘再重命名，保留上一版本作为备份，写入中途退出或断电不会损坏文件
+func (r *memoryBillRepository) save() error {
+	if r.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.Marshal(r.bills)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bills: %v", err)
+	}
+	if err := atomicfile.WriteFile(r.file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write bills file: %v", err)
+	}
+	return nil
+}
+
+// CreateBill stores a copy of the bill and assigns its record ID
+func (r *memoryBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.insert(bill)
+	return r.save()
+}
+
+// CreateBills stores all bills; it never fails partially
+func (r *memoryBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, bill := range bills {
+		r.insert(bill)
+	}
+	return r.save()
+}
+
+// insert 保存账单副本，调用方需持有写锁
+// 已有 record_id 的账单（如从多维表格同步来的）保留原 ID
+func (r *memoryBillRepository) insert(bill *domain.Bill) {
+	if bill.RecordID == "" {
+		bill.RecordID = "rec" + uuid.New().String()[:8]
+	}
+	bill.ID = bill.RecordID
+	stored := *bill
+	r.bills = append(r.bills, &stored)
+}
+
+// GetBill gets a bill by record ID
+func (r *memoryBillRepository) GetBill(ctx context.Context, id string) (*domain.Bill, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.indexOf(id)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", domain.ErrBillNotFound, id)
+	}
+	bill := *r.bills[i]
+	return &bill, nil
+}
+
+// UpdateBill updates the non-empty fields of the bill, like the bitable repository
+func (r *memoryBillRepository) UpdateBill(ctx context.Context, bill *domain.Bill) error {
+	if bill.RecordID == "" {
+		return fmt.Errorf("record_id is required for updating bill")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(bill.RecordID)
+	if i < 0 {
+		return fmt.Errorf("failed to update bill: %w", domain.ErrBillNotFound)
+	}
+
+	mergeBill(r.bills[i], bill)
+	return r.save()
+}
+
+// mergeBill 把更新中非零值的字段写入已保存的账单，与多维表格的部分更新一致
+func mergeBill(stored, update *domain.Bill) {
+	if update.Description != "" {
+		stored.Description = update.Description
+	}
+	if update.Amount > 0 {
+		stored.Amount = update.Amount
+	}
+	if update.Category != "" {
+		stored.Category = update.Category
+	}
+	if update.Type != "" {
+		stored.Type = update.Type
+	}
+	if !update.Date.IsZero() {
+		stored.Date = update.Date
+	}
+	if update.UserName != "" {
+		stored.UserName = update.UserName
+	}
+	if update.OriginalMsg != "" {
+		stored.OriginalMsg = update.OriginalMsg
+	}
+	if update.Account != "" {
+		stored.Account = update.Account
+	}
+	if len(update.Tags) > 0 {
+		stored.Tags = append([]string(nil), update.Tags...)
+	}
+	if update.Reimbursable != nil {
+		reimbursable := *update.Reimbursable
+		stored.Reimbursable = &reimbursable
+	}
+	if update.ReimbursedAt != nil {
+		reimbursedAt := *update.ReimbursedAt
+		stored.ReimbursedAt = &reimbursedAt
+	}
+}
+
+// DeleteBill deletes a bill by record ID or bill ID
+func (r *memoryBillRepository) DeleteBill(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(id)
+	if i < 0 {
+		return fmt.Errorf("failed to delete bill: %w", domain.ErrBillNotFound)
+	}
+	r.bills = append(r.bills[:i], r.bills[i+1:]...)
+	return r.save()
+}
+
+// DeleteBills deletes several bills by record ID or bill ID; nothing is deleted when one of them is missing
+func (r *memoryBillRepository) DeleteBills(ctx context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remove := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		i := r.indexOf(id)
+		if i < 0 {
+			return fmt.Errorf("failed to delete bill %s: %w", id, domain.ErrBillNotFound)
+		}
+		remove[i] = true
+	}
+	kept := r.bills[:0]
+	for i, bill := range r.bills {
+		if !remove[i] {
+			kept = append(kept, bill)
+		}
+	}
+	r.bills = kept
+	return r.save()
+}
+
+// RestoreBill is not supported: deleted bills are removed immediately
+func (r *memoryBillRepository) RestoreBill(ctx context.Context, id string) error {
+	return domain.ErrSoftDeleteDisabled
+}
+
+// ListBills lists bills ordered by date descending
+func (r *memoryBillRepository) ListBills(ctx context.Context, userName string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []*domain.Bill
+	for _, bill := range r.bills {
+		if userName != "" && bill.UserName != userName {
+			continue
+		}
+		if startDate != nil && bill.Date.Before(*startDate) {
+			continue
+		}
+		if endDate != nil && bill.Date.After(*endDate) {
+			continue
+		}
+		if billType != nil && bill.Type != *billType {
+			continue
+		}
+		if category != nil && *category != "" && bill.Category != *category {
+			continue
+		}
+		b := *bill
+		matched = append(matched, &b)
+	}
+	sortByDateDesc(matched)
+
+	total := len(matched)
+	return paginate(matched, offset, limit), total, nil
+}
+
+// GetMonthlySummary sums the user's bills in the given month
+func (r *memoryBillRepository) GetMonthlySummary(ctx context.Context, userName string, year, month int) (*domain.MonthlySummary, error) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, r.location)
+	end := start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	bills, income, expense, err := r.QueryTransactions(ctx, userName, start, end, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MonthlySummary{
+		Year:         year,
+		Month:        month,
+		TotalIncome:  income,
+		TotalExpense: expense,
+		NetAmount:    income - expense,
+		Count:        len(bills),
+	}, nil
+}
+
+// GetCategories lists the distinct categories the user has used
+func (r *memoryBillRepository) GetCategories(ctx context.Context, userName string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var categories []string
+	for _, bill := range r.bills {
+		if userName != "" && bill.UserName != userName {
+			continue
+		}
+		if bill.Category != "" && !seen[bill.Category] {
+			seen[bill.Category] = true
+			categories = append(categories, bill.Category)
+		}
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// Ping always succeeds
+func (r *memoryBillRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// QueryTransactions returns the bills in the time range ordered by amount descending, with totals over all of them
+func (r *memoryBillRepository) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	bills, _, err := r.ListBills(ctx, userName, &startTime, &endTime, nil, nil, 0, 0)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// 借贷不计入收支合计
+	totalIncome, totalExpense := domain.SumBills(bills)
+
+	sort.SliceStable(bills, func(i, j int) bool { return bills[i].Amount > bills[j].Amount })
+	if topN > 0 && topN < len(bills) {
+		bills = bills[:topN]
+	}
+	return bills, totalIncome, totalExpense, nil
+}
+
+// QueryTransactionsPage returns one page ordered by date descending; the page token is the next offset
+func (r *memoryBillRepository) QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	offset := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("invalid page token: %s", pageToken)
+		}
+		offset = n
+	}
+
+	bills, total, err := r.ListBills(ctx, userName, &startTime, &endTime, nil, nil, offset, pageSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if offset+len(bills) < total {
+		next = strconv.Itoa(offset + len(bills))
+	}
+	return bills, next, nil
+}
+
+// indexOf 按 record_id 查找，调用方需持有锁
+func (r *memoryBillRepository) indexOf(id string) int {
+	for i, bill := range r.bills {
+		if bill.RecordID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortByDateDesc 按日期倒序排列，日期相同时后创建的在前
+func sortByDateDesc(bills []*domain.Bill) {
+	for i, j := 0, len(bills)-1; i < j; i, j = i+1, j-1 {
+		bills[i], bills[j] = bills[j], bills[i]
+	}
+	sort.SliceStable(bills, func(i, j int) bool { return bills[i].Date.After(bills[j].Date) })
+}
+
+// paginate 截取 offset 开始的 limit 条，limit <= 0 表示不限制
+func paginate(bills []*domain.Bill, offset, limit int) []*domain.Bill {
+	if offset >= len(bills) {
+		return []*domain.Bill{}
+	}
+	bills = bills[offset:]
+	if limit > 0 && limit < len(bills) {
+		bills = bills[:limit]
+	}
+	return bills
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// 内存仓库与多维表格一致：合计覆盖时间范围内的全部账单，再按金额取前 N 笔
+func TestMemoryQueryTransactions(t *testing.T) {
+	repo := NewMemoryBillRepository(time.UTC)
+	ctx := context.Background()
+	march := func(day int) time.Time { return time.Date(2025, time.March, day, 12, 0, 0, 0, time.UTC) }
+	for _, bill := range []*domain.Bill{
+		{Description: "午饭", Amount: 30.1, Type: domain.BillTypeExpense, UserName: "张三", Date: march(1)},
+		{Description: "工资", Amount: 8000, Type: domain.BillTypeIncome, UserName: "张三", Date: march(10)},
+		{Description: "房租", Amount: 3000, Type: domain.BillTypeExpense, UserName: "张三", Date: march(5)},
+		{Description: "咖啡", Amount: 18.2, Type: domain.BillTypeExpense, UserName: "张三", Date: march(20)},
+		// 范围外和其他用户的账单不计入
+		{Description: "上月房租", Amount: 3000, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Date(2025, time.February, 28, 12, 0, 0, 0, time.UTC)},
+		{Description: "李四的午饭", Amount: 50, Type: domain.BillTypeExpense, UserName: "李四", Date: march(1)},
+	} {
+		if err := repo.CreateBill(ctx, bill); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start, end := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.March, 31, 23, 59, 59, 0, time.UTC)
+	bills, income, expense, err := repo.QueryTransactions(ctx, "张三", start, end, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if income != 8000 || expense != 3048.3 {
+		t.Errorf("totals = %v income, %v expense, want 8000 and 3048.3", income, expense)
+	}
+	if len(bills) != 2 || bills[0].Description != "工资" || bills[1].Description != "房租" {
+		t.Errorf("top bills = %v, want 工资 and 房租", bills)
+	}
+}
+
+// 本地查询库写入中途断电留下半截文件时从上一版本的备份恢复
+func TestFileBillRepositoryTruncatedFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bills.json")
+	repo, err := NewFileBillRepository(file, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	first := &domain.Bill{Description: "午饭", Amount: 30, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Now()}
+	second := &domain.Bill{Description: "晚饭", Amount: 50, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Now()}
+	for _, bill := range []*domain.Bill{first, second} {
+		if err := repo.CreateBill(ctx, bill); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 备份是最后一次保存前的版本
+	repo, err = NewFileBillRepository(file, time.UTC)
+	if err != nil {
+		t.Fatalf("NewFileBillRepository with a truncated file = %v, want the backup", err)
+	}
+	if bill, err := repo.GetBill(ctx, first.RecordID); err != nil || bill.Description != "午饭" {
+		t.Errorf("GetBill = %+v, %v, want 午饭", bill, err)
+	}
+	if _, err := repo.GetBill(ctx, second.RecordID); err == nil {
+		t.Error("bill saved after the backup found")
+	}
+
+	// 文件和备份都损坏时报错
+	for _, path := range []string{file, file + ".bak"} {
+		if err := os.WriteFile(path, []byte(`[{"id": `), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewFileBillRepository(file, time.UTC); err == nil {
+		t.Error("NewFileBillRepository with a corrupt file and backup succeeded")
+	}
+}
@@ -0,0 +1,577 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+const (
+	// outboxIDPrefix 排队账单的临时记录 ID 前缀
+	outboxIDPrefix = "pending_"
+	// outboxMinBackoff/outboxMaxBackoff 重试间隔从最小值开始翻倍，不超过最大值
+	outboxMinBackoff = 5 * time.Second
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxResolvedTTL 临时 ID 与真实记录 ID 的对应关系保留时间，期间仍可用临时 ID 修改、删除
+	outboxResolvedTTL = 7 * 24 * time.Hour
+	// outboxWriteTimeout 后台写入单条账单的超时时间
+	outboxWriteTimeout = 30 * time.Second
+	// outboxMaxAttempts 排队账单最多尝试写入的次数，达到后移入死信列表不再重试（按最大退避约 2 小时）
+	outboxMaxAttempts = 30
+)
+
+// outboxEntry 一条等待写入的账单
+type outboxEntry struct {
+	ID          string      `json:"id"` // 临时记录 ID
+	Bill        domain.Bill `json:"bill"`
+	Ledger      string      `json:"ledger,omitempty"`
+	Attempts    int         `json:"attempts"` // 已尝试写入的次数，大于 0 时记录可能已经写入
+	NextAttempt time.Time   `json:"next_attempt"`
+	LastError   string      `json:"last_error,omitempty"`
+}
+
+// outboxResolved 已写入的排队账单对应的真实记录 ID
+type outboxResolved struct {
+	RecordID string    `json:"record_id"`
+	At       time.Time `json:"at"`
+}
+
+// outboxJournal 持久化到文件的队列内容
+// DeadLetter 保存放弃写入的账单（永久错误或超过最大尝试次数），留在文件中供人工处理
+type outboxJournal struct {
+	Pending    []*outboxEntry            `json:"pending"`
+	Resolved   map[string]outboxResolved `json:"resolved"`
+	DeadLetter []*outboxEntry            `json:"dead_letter,omitempty"`
+}
+
+// OutboxBillRepository 写入失败时把新账单放入持久化队列，由后台任务稍后写入
+// 账单先写入队列文件再尝试写入存储：写入成功即从队列移除；失败时返回临时记录 ID，用户照常收到记账成功的回复。
+// 排队中的账单可以用临时 ID 查看、修改、删除；写入后临时 ID 会映射到真实记录 ID。
+// 只有临时错误（网络故障、限流、5xx）才排队；字段值错误、无权限等永久错误重试也不会成功，直接返回给调用方。
+// 查询统计不包含排队中的账单
+type OutboxBillRepository struct {
+	domain.BillRepository
+
+	file string
+
+	// drainMu 保证后台写入某条排队账单时，该账单不会同时被修改或删除
+	drainMu sync.Mutex
+
+	mu      sync.Mutex
+	journal outboxJournal
+	wake    chan struct{}
+}
+
+// NewOutboxBillRepository wraps repo with a durable write queue stored in file.
+// Queued bills left over from a previous run are replayed by the background worker, which runs until ctx is cancelled.
+func NewOutboxBillRepository(ctx context.Context, repo domain.BillRepository, file string) (*OutboxBillRepository, error) {
+	r := &OutboxBillRepository{
+		BillRepository: repo,
+		file:           file,
+		journal:        outboxJournal{Resolved: make(map[string]outboxResolved)},
+		wake:           make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read outbox file: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &r.journal); err != nil {
+			return nil, fmt.Errorf("failed to parse outbox file: %v", err)
+		}
+		if r.journal.Resolved == nil {
+			r.journal.Resolved = make(map[string]outboxResolved)
+		}
+	}
+	if n := len(r.journal.Pending); n > 0 {
+		logger.FromContext(ctx, logComponent).Info("Replaying queued bills: count=%d", n)
+	}
+
+	go r.run(ctx)
+	return r, nil
+}
+
+// Pending returns the number of bills waiting to be written
+func (r *OutboxBillRepository) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.journal.Pending)
+}
+
+// Flush tries to write every queued bill once, ignoring the backoff; it is called on shutdown
+func (r *OutboxBillRepository) Flush(ctx context.Context) error {
+	r.drain(ctx, true)
+	if n := r.Pending(); n > 0 {
+		return fmt.Errorf("%d bills still queued", n)
+	}
+	return nil
+}
+
+// save 写入队列文件，调用方需持有 mu
+// 先写临时文件再重命名，避免写入中途退出损坏文件
+func (r *OutboxBillRepository) save() error {
+	now := time.Now()
+	for id, resolved := range r.journal.Resolved {
+		if now.Sub(resolved.At) > outboxResolvedTTL {
+			delete(r.journal.Resolved, id)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.Marshal(r.journal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox: %v", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write outbox file: %v", err)
+	}
+	return os.Rename(tmp, r.file)
+}
+
+// enqueue 把账单写入队列文件，返回队列中的条目
+// 调用方随后会直接尝试写入，期间后台任务不处理该条目；进程在此期间退出时，重启后照常重放
+func (r *OutboxBillRepository) enqueue(ctx context.Context, bill *domain.Bill) (*outboxEntry, error) {
+	entry := &outboxEntry{
+		ID:          outboxIDPrefix + uuid.New().String()[:8],
+		Bill:        *bill,
+		Ledger:      domain.LedgerFromContext(ctx),
+		NextAttempt: time.Now().Add(2 * outboxWriteTimeout),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.journal.Pending = append(r.journal.Pending, entry)
+	if err := r.save(); err != nil {
+		r.journal.Pending = r.journal.Pending[:len(r.journal.Pending)-1]
+		return nil, err
+	}
+	return entry, nil
+}
+
+// complete 账单已写入，从队列移除并记下真实记录 ID
+func (r *OutboxBillRepository) complete(ctx context.Context, id, recordID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removeLocked(id)
+	r.journal.Resolved[id] = outboxResolved{RecordID: recordID, At: time.Now()}
+	if err := r.save(); err != nil {
+		// 队列文件未更新时，重启后的重放会先查找已写入的记录，不会重复记账
+		logger.FromContext(ctx, logComponent).Error("Failed to save outbox: %v", err)
+	}
+}
+
+// fail 记录一次失败的写入，按退避时间安排下次重试
+// 永久错误或达到最大尝试次数时把账单移入死信列表，返回 true
+func (r *OutboxBillRepository) fail(ctx context.Context, id string, err error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.findLocked(id)
+	if entry == nil {
+		return false
+	}
+	entry.Attempts++
+	entry.LastError = err.Error()
+	if domain.IsPermanentError(err) || entry.Attempts >= outboxMaxAttempts {
+		r.removeLocked(id)
+		r.journal.DeadLetter = append(r.journal.DeadLetter, entry)
+		if err := r.save(); err != nil {
+			logger.FromContext(ctx, logComponent).Error("Failed to save outbox: %v", err)
+		}
+		logger.FromContext(ctx, logComponent).WithField("request_id", domain.RequestIDFromError(err)).Error("Queued bill moved to dead letter: id=%s, attempts=%d, err=%v", entry.ID, entry.Attempts, err)
+		return true
+	}
+	backoff := outboxMinBackoff << (entry.Attempts - 1)
+	if backoff > outboxMaxBackoff || backoff <= 0 {
+		backoff = outboxMaxBackoff
+	}
+	entry.NextAttempt = time.Now().Add(backoff)
+	if err := r.save(); err != nil {
+		logger.FromContext(ctx, logComponent).Error("Failed to save outbox: %v", err)
+	}
+	return false
+}
+
+// discard 从队列移除直接写入时遇到永久错误的账单，错误由调用方返回
+func (r *OutboxBillRepository) discard(ctx context.Context, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.removeLocked(id) {
+		if err := r.save(); err != nil {
+			logger.FromContext(ctx, logComponent).Error("Failed to save outbox: %v", err)
+		}
+	}
+}
+
+func (r *OutboxBillRepository) findLocked(id string) *outboxEntry {
+	for _, entry := range r.journal.Pending {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (r *OutboxBillRepository) removeLocked(id string) bool {
+	for i, entry := range r.journal.Pending {
+		if entry.ID == id {
+			r.journal.Pending = append(r.journal.Pending[:i], r.journal.Pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// queued 标记账单已排队：使用临时记录 ID
+func queued(bill *domain.Bill, entry *outboxEntry) {
+	bill.ID = entry.ID
+	bill.RecordID = entry.ID
+	bill.Queued = true
+}
+
+// CreateBill writes the bill through; if that fails with a temporary error the bill is queued
+// and a provisional record ID is returned. Permanent errors are returned as is
+func (r *OutboxBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	// 先落盘再写入存储，写入过程中进程退出也不会丢失
+	entry, err := r.enqueue(ctx, bill)
+	if err != nil {
+		logger.FromContext(ctx, logComponent).Error("Failed to queue bill, writing directly: %v", err)
+		return r.BillRepository.CreateBill(ctx, bill)
+	}
+
+	err = r.BillRepository.CreateBill(ctx, bill)
+	if err == nil {
+		r.complete(ctx, entry.ID, bill.RecordID)
+		return nil
+	}
+	if domain.IsPermanentError(err) {
+		r.discard(ctx, entry.ID)
+		return err
+	}
+	r.fail(ctx, entry.ID, err)
+	r.notify()
+
+	logger.FromContext(ctx, logComponent).Warn("Bill storage unavailable, bill queued: id=%s, err=%v", entry.ID, err)
+	queued(bill, entry)
+	return nil
+}
+
+// CreateBills writes the bills through; the ones that fail with a temporary error are queued
+// with provisional record IDs, the ones that fail permanently keep their error
+func (r *OutboxBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	entries := make([]*outboxEntry, len(bills))
+	for i, bill := range bills {
+		entry, err := r.enqueue(ctx, bill)
+		if err != nil {
+			logger.FromContext(ctx, logComponent).Error("Failed to queue bill, writing directly: %v", err)
+			continue
+		}
+		entries[i] = entry
+	}
+
+	err := r.BillRepository.CreateBills(ctx, bills)
+	batchErr, _ := err.(*domain.BatchCreateError)
+	unqueued := false
+	for i, bill := range bills {
+		entry := entries[i]
+		failed := err != nil && (batchErr == nil || batchErr.Errors[i] != nil)
+		cause := err
+		if batchErr != nil {
+			cause = batchErr.Errors[i]
+		}
+		switch {
+		case entry == nil:
+			// 未能排队的账单按原样返回结果
+			unqueued = unqueued || failed
+		case !failed:
+			r.complete(ctx, entry.ID, bill.RecordID)
+			if batchErr != nil {
+				batchErr.Errors[i] = nil
+			}
+		case domain.IsPermanentError(cause):
+			// 永久错误不排队，保留该账单的错误
+			r.discard(ctx, entry.ID)
+			unqueued = true
+		default:
+			r.fail(ctx, entry.ID, cause)
+			logger.FromContext(ctx, logComponent).Warn("Bill storage unavailable, bill queued: id=%s, err=%v", entry.ID, cause)
+			queued(bill, entry)
+			if batchErr != nil {
+				batchErr.Errors[i] = nil
+			}
+		}
+	}
+
+	r.notify()
+
+	// 全部成功或已排队时不返回错误
+	if unqueued {
+		return err
+	}
+	return nil
+}
+
+// lookup 返回临时记录 ID 当前的状态：仍在排队的条目，或已写入的真实记录 ID
+func (r *OutboxBillRepository) lookup(id string) (*outboxEntry, string) {
+	if !strings.HasPrefix(id, outboxIDPrefix) {
+		return nil, id
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry := r.findLocked(id); entry != nil {
+		copied := *entry
+		return &copied, ""
+	}
+	if resolved, ok := r.journal.Resolved[id]; ok {
+		return nil, resolved.RecordID
+	}
+	return nil, id
+}
+
+// GetBill gets a bill, including queued ones by their provisional ID
+func (r *OutboxBillRepository) GetBill(ctx context.Context, id string) (*domain.Bill, error) {
+	entry, recordID := r.lookup(id)
+	if entry != nil {
+		bill := entry.Bill
+		queued(&bill, entry)
+		return &bill, nil
+	}
+	return r.BillRepository.GetBill(ctx, recordID)
+}
+
+// UpdateBill updates a bill; a queued bill is updated in the queue before it is written
+func (r *OutboxBillRepository) UpdateBill(ctx context.Context, bill *domain.Bill) error {
+	if !strings.HasPrefix(bill.RecordID, outboxIDPrefix) {
+		return r.BillRepository.UpdateBill(ctx, bill)
+	}
+
+	r.drainMu.Lock()
+	r.mu.Lock()
+	entry := r.findLocked(bill.RecordID)
+	if entry != nil {
+		mergeBill(&entry.Bill, bill)
+		err := r.save()
+		r.mu.Unlock()
+		r.drainMu.Unlock()
+		return err
+	}
+	r.mu.Unlock()
+	r.drainMu.Unlock()
+
+	_, recordID := r.lookup(bill.RecordID)
+	bill.RecordID = recordID
+	return r.BillRepository.UpdateBill(ctx, bill)
+}
+
+// DeleteBill deletes a bill; a queued bill is removed from the queue
+func (r *OutboxBillRepository) DeleteBill(ctx context.Context, id string) error {
+	if !strings.HasPrefix(id, outboxIDPrefix) {
+		return r.BillRepository.DeleteBill(ctx, id)
+	}
+
+	r.drainMu.Lock()
+	r.mu.Lock()
+	removed := r.removeLocked(id)
+	var err error
+	if removed {
+		err = r.save()
+	}
+	r.mu.Unlock()
+	r.drainMu.Unlock()
+	if removed {
+		return err
+	}
+
+	_, recordID := r.lookup(id)
+	return r.BillRepository.DeleteBill(ctx, recordID)
+}
+
+// DeleteBills deletes several bills; queued bills are removed from the queue, the rest are deleted in one batch
+func (r *OutboxBillRepository) DeleteBills(ctx context.Context, ids []string) error {
+	r.drainMu.Lock()
+	r.mu.Lock()
+	removed := false
+	var written []string
+	for _, id := range ids {
+		if strings.HasPrefix(id, outboxIDPrefix) && r.removeLocked(id) {
+			removed = true
+			continue
+		}
+		written = append(written, id)
+	}
+	var err error
+	if removed {
+		err = r.save()
+	}
+	r.mu.Unlock()
+	r.drainMu.Unlock()
+	if err != nil || len(written) == 0 {
+		return err
+	}
+
+	for i, id := range written {
+		_, written[i] = r.lookup(id)
+	}
+	return r.BillRepository.DeleteBills(ctx, written)
+}
+
+// RestoreBill restores a soft-deleted bill, translating a provisional ID to the written record
+// 排队期间删除的账单直接从队列移除，无法恢复；仍在排队的账单没有被删除，无需恢复
+func (r *OutboxBillRepository) RestoreBill(ctx context.Context, id string) error {
+	entry, recordID := r.lookup(id)
+	if entry != nil {
+		return nil
+	}
+	if strings.HasPrefix(recordID, outboxIDPrefix) {
+		return fmt.Errorf("failed to restore queued bill %s: %w", id, domain.ErrBillNotFound)
+	}
+	return r.BillRepository.RestoreBill(ctx, recordID)
+}
+
+// run 后台写入排队的账单，有新账单排队或到达重试时间时执行
+func (r *OutboxBillRepository) run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-r.wake:
+		}
+		r.drain(ctx, false)
+
+		timer.Stop()
+		timer.Reset(r.nextWait())
+	}
+}
+
+// nextWait 返回距离最早一次重试的时间
+func (r *OutboxBillRepository) nextWait() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wait := outboxMaxBackoff
+	for _, entry := range r.journal.Pending {
+		if d := time.Until(entry.NextAttempt); d < wait {
+			wait = d
+		}
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+// drain 写入到达重试时间的账单；force 为 true 时忽略重试时间
+func (r *OutboxBillRepository) drain(ctx context.Context, force bool) {
+	r.mu.Lock()
+	var due []string
+	now := time.Now()
+	for _, entry := range r.journal.Pending {
+		if force || !entry.NextAttempt.After(now) {
+			due = append(due, entry.ID)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, id := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		r.write(ctx, id)
+	}
+}
+
+// write 写入一条排队的账单
+func (r *OutboxBillRepository) write(ctx context.Context, id string) {
+	r.drainMu.Lock()
+	defer r.drainMu.Unlock()
+
+	entry, _ := r.lookup(id)
+	if entry == nil {
+		// 已被删除或已写入
+		return
+	}
+
+	ctx = logger.WithCorrelationID(ctx, "outbox-"+entry.ID)
+	if entry.Ledger != "" {
+		ctx = domain.WithLedger(ctx, entry.Ledger)
+	}
+	ctx, cancel := context.WithTimeout(ctx, outboxWriteTimeout)
+	defer cancel()
+
+	// 之前尝试过的写入可能已经成功（如请求超时但服务端已写入），先查找避免重复记账
+	if entry.Attempts > 0 {
+		recordID, err := r.findWritten(ctx, entry)
+		if err != nil {
+			if r.fail(ctx, entry.ID, err) {
+				return
+			}
+			logger.FromContext(ctx, logComponent).Warn("Queued bill lookup failed: attempts=%d, err=%v", entry.Attempts+1, err)
+			return
+		}
+		if recordID != "" {
+			r.complete(ctx, entry.ID, recordID)
+			logger.FromContext(ctx, logComponent).Info("Queued bill was already written: record_id=%s", recordID)
+			return
+		}
+	}
+
+	bill := entry.Bill
+	if err := r.BillRepository.CreateBill(ctx, &bill); err != nil {
+		if r.fail(ctx, entry.ID, err) {
+			return
+		}
+		logger.FromContext(ctx, logComponent).Warn("Queued bill write failed: attempts=%d, err=%v", entry.Attempts+1, err)
+		return
+	}
+	r.complete(ctx, entry.ID, bill.RecordID)
+	logger.FromContext(ctx, logComponent).Info("Queued bill written: record_id=%s, attempts=%d", bill.RecordID, entry.Attempts+1)
+}
+
+// findWritten 查找与排队账单内容相同、且不是其他排队账单写入的记录
+func (r *OutboxBillRepository) findWritten(ctx context.Context, entry *outboxEntry) (string, error) {
+	bill := entry.Bill
+	start := time.Date(bill.Date.Year(), bill.Date.Month(), bill.Date.Day(), 0, 0, 0, 0, bill.Date.Location())
+	bills, _, _, err := r.BillRepository.QueryTransactions(ctx, bill.UserName, start, start.AddDate(0, 0, 1), 0)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	taken := make(map[string]bool, len(r.journal.Resolved))
+	for _, resolved := range r.journal.Resolved {
+		taken[resolved.RecordID] = true
+	}
+	r.mu.Unlock()
+
+	for _, b := range bills {
+		if taken[b.RecordID] {
+			continue
+		}
+		if b.Description == bill.Description && b.Amount == bill.Amount && b.Type == bill.Type && b.OriginalMsg == bill.OriginalMsg {
+			return b.RecordID, nil
+		}
+	}
+	return "", nil
+}
+
+// notify 唤醒后台任务
+func (r *OutboxBillRepository) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
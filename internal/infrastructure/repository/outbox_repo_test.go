@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// newTestOutbox 创建包装假多维表格的排队仓库，测试结束时停止后台任务
+func newTestOutbox(t *testing.T, handle func(n int, call bitableCall) (int, interface{})) (*OutboxBillRepository, *fakeBitable, string) {
+	t.Helper()
+	repo, fake := newFakeBitableRepo(t, handle)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	file := filepath.Join(t.TempDir(), "outbox.json")
+	outbox, err := NewOutboxBillRepository(ctx, repo, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outbox, fake, file
+}
+
+// shortCtx 截止时间短于首次重试间隔，飞书接口失败后不再重试
+func shortCtx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func readJournal(t *testing.T, file string) outboxJournal {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var journal outboxJournal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		t.Fatal(err)
+	}
+	return journal
+}
+
+// 字段错误等永久错误直接返回，不排队，之后也不会重试
+func TestOutboxPermanentErrorNotQueued(t *testing.T) {
+	outbox, fake, file := newTestOutbox(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{"code": 1254045, "msg": "FieldNameNotFound"}
+	})
+
+	bill := &domain.Bill{Description: "午饭", Amount: 30, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Now()}
+	err := outbox.CreateBill(shortCtx(t), bill)
+	if err == nil || !domain.IsPermanentError(err) {
+		t.Fatalf("CreateBill = %v, want the permanent error", err)
+	}
+	if bill.Queued || bill.RecordID != "" {
+		t.Errorf("bill = %+v, want it not queued", bill)
+	}
+	if n := outbox.Pending(); n != 0 {
+		t.Errorf("Pending = %d, want 0", n)
+	}
+	if journal := readJournal(t, file); len(journal.Pending) != 0 || len(journal.DeadLetter) != 0 {
+		t.Errorf("journal = %+v, want it empty", journal)
+	}
+
+	if err := outbox.Flush(shortCtx(t)); err != nil {
+		t.Errorf("Flush = %v", err)
+	}
+	if calls := fake.requests(); len(calls) != 1 {
+		t.Errorf("requests = %d, want only the direct write", len(calls))
+	}
+}
+
+// 批量写入时永久错误的账单保留错误，临时错误的账单排队
+func TestOutboxCreateBillsPermanentError(t *testing.T) {
+	outbox, _, _ := newTestOutbox(t, func(n int, call bitableCall) (int, interface{}) {
+		// 批量请求失败后逐条重试：第一条字段错误，第二条限流
+		if n == 1 {
+			return http.StatusBadRequest, map[string]interface{}{"code": 1254045, "msg": "FieldNameNotFound"}
+		}
+		return http.StatusBadRequest, map[string]interface{}{"code": 1254290, "msg": "TooManyRequest"}
+	})
+
+	bills := []*domain.Bill{
+		{Description: "午饭", Amount: 30, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Now()},
+		{Description: "晚饭", Amount: 50, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Now()},
+	}
+	err := outbox.CreateBills(shortCtx(t), bills)
+	batchErr, ok := err.(*domain.BatchCreateError)
+	if !ok {
+		t.Fatalf("CreateBills = %v, want a BatchCreateError", err)
+	}
+	if !domain.IsPermanentError(batchErr.Errors[0]) || batchErr.Errors[1] != nil {
+		t.Errorf("errors = %v, want only the first bill to fail", batchErr.Errors)
+	}
+	if bills[0].Queued || !bills[1].Queued {
+		t.Errorf("queued = %v, %v, want only the second bill queued", bills[0].Queued, bills[1].Queued)
+	}
+	if n := outbox.Pending(); n != 1 {
+		t.Errorf("Pending = %d, want 1", n)
+	}
+}
+
+// 临时错误排队；达到最大尝试次数后移入死信列表，不再重试
+func TestOutboxDeadLetter(t *testing.T) {
+	outbox, fake, file := newTestOutbox(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusBadRequest, map[string]interface{}{"code": 1254290, "msg": "TooManyRequest"}
+	})
+
+	bill := &domain.Bill{Description: "午饭", Amount: 30, Type: domain.BillTypeExpense, UserName: "张三", Date: time.Now()}
+	if err := outbox.CreateBill(shortCtx(t), bill); err != nil {
+		t.Fatalf("CreateBill = %v, want the bill queued", err)
+	}
+	if !bill.Queued || outbox.Pending() != 1 {
+		t.Fatalf("bill = %+v, pending = %d, want it queued", bill, outbox.Pending())
+	}
+
+	outbox.mu.Lock()
+	outbox.journal.Pending[0].Attempts = outboxMaxAttempts - 1
+	outbox.mu.Unlock()
+	if err := outbox.Flush(shortCtx(t)); err != nil {
+		t.Errorf("Flush = %v, want the bill moved out of the queue", err)
+	}
+	journal := readJournal(t, file)
+	if len(journal.Pending) != 0 || len(journal.DeadLetter) != 1 || journal.DeadLetter[0].ID != bill.RecordID {
+		t.Fatalf("journal = %+v, want the bill in the dead letter list", journal)
+	}
+	if journal.DeadLetter[0].Attempts != outboxMaxAttempts || journal.DeadLetter[0].LastError == "" {
+		t.Errorf("dead letter = %+v", journal.DeadLetter[0])
+	}
+
+	calls := len(fake.requests())
+	if err := outbox.Flush(shortCtx(t)); err != nil {
+		t.Errorf("Flush = %v", err)
+	}
+	if n := len(fake.requests()); n != calls {
+		t.Errorf("requests = %d after flush, want %d", n, calls)
+	}
+}
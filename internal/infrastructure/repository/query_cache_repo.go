@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+)
+
+// queryCacheMaxEntries 查询缓存最多保存的结果数
+const queryCacheMaxEntries = 1000
+
+// queryEpochTTL 账本纪元的保存时间，远长于结果的缓存时间，纪元过期只会使此前缓存的结果提前失效
+const queryEpochTTL = 24 * time.Hour
+
+// QueryCacheStats counts the lookups of the query cache
+type QueryCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// queryResult 缓存的 QueryTransactions 结果
+type queryResult struct {
+	Bills   []*domain.Bill
+	Income  float64
+	Expense float64
+}
+
+// QueryCacheBillRepository caches QueryTransactions and GetMonthlySummary results for a short time.
+// Every write gives its ledger a new epoch; the epoch is part of the cache key, so results cached
+// before the write are never read again and expire on their own. Results and epochs live in the
+// same store, so with a shared Redis store a write on one replica invalidates the others. Changes
+// made directly in the table are picked up when the TTL expires
+type QueryCacheBillRepository struct {
+	domain.BillRepository
+
+	results   *cache.Typed[queryResult]
+	summaries *cache.Typed[*domain.MonthlySummary]
+	epochs    *cache.Typed[string] // 账本 -> 最近一次写入生成的随机值，默认账本的键为空
+	ttl       time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewQueryCacheBillRepository wraps repo with a query cache whose results live for ttl. newCache
+// creates the stores shared by several replicas, such as Redis; when it is nil or returns nil the
+// results are kept in memory
+func NewQueryCacheBillRepository(repo domain.BillRepository, ttl time.Duration, newCache cache.Factory) *QueryCacheBillRepository {
+	opts := cache.Options{DefaultTTL: ttl, MaxEntries: queryCacheMaxEntries}
+	store := func(name string) cache.Cache {
+		if newCache != nil {
+			if c := newCache(name); c != nil {
+				return c
+			}
+		}
+		return cache.NewUserMappingCache("", opts)
+	}
+	return &QueryCacheBillRepository{
+		BillRepository: repo,
+		results:        cache.NewTyped[queryResult](store("query_results")),
+		summaries:      cache.NewTyped[*domain.MonthlySummary](store("query_summaries")),
+		epochs:         cache.NewTyped[string](store("query_epochs")),
+		ttl:            ttl,
+	}
+}
+
+// Stats returns the hit and miss counts
+func (r *QueryCacheBillRepository) Stats() QueryCacheStats {
+	return QueryCacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+}
+
+// Close stops the cleanup of the cached results, or closes the shared stores
+func (r *QueryCacheBillRepository) Close() error {
+	_ = r.results.Close()
+	_ = r.epochs.Close()
+	return r.summaries.Close()
+}
+
+// keyPrefix 返回上下文所在账本当前纪元对应的缓存键前缀；共享账本的查询包含所有成员，单独区分
+func (r *QueryCacheBillRepository) keyPrefix(ctx context.Context) string {
+	ledger := domain.LedgerFromContext(ctx)
+	return fmt.Sprintf("%s|%s|%v", ledger, r.epoch(ledger), domain.IsSharedLedger(ctx))
+}
+
+// epoch 返回账本当前的纪元。纪元缺失（尚未写入、过期或被淘汰）时生成新的随机值，
+// 不会退回到旧值而读到失效前缓存的结果；多个副本同时生成时以先写入的为准
+func (r *QueryCacheBillRepository) epoch(ledger string) string {
+	if epoch, ok := r.epochs.Get(ledger); ok {
+		return epoch
+	}
+	epoch := uuid.NewString()
+	if set, err := r.epochs.SetNX(ledger, epoch, queryEpochTTL); err == nil && !set {
+		if current, ok := r.epochs.Get(ledger); ok {
+			return current
+		}
+	}
+	return epoch
+}
+
+// invalidate 使写入涉及的账本的缓存失效：带账本后缀的记录 ID 属于该账本，其余属于上下文所在的账本。
+// 纪元取随机值而不是计数，多个副本同时写入时不会因为读改写相互覆盖而退回到旧值
+func (r *QueryCacheBillRepository) invalidate(ctx context.Context, ids ...string) {
+	ledgers := []string{domain.LedgerFromContext(ctx)}
+	for _, id := range ids {
+		if _, ledger := domain.SplitLedgerRecordID(id); ledger != "" && ledger != ledgers[0] {
+			ledgers = append(ledgers, ledger)
+		}
+	}
+	for _, ledger := range ledgers {
+		_ = r.epochs.Set(ledger, uuid.NewString(), queryEpochTTL)
+	}
+}
+
+// copyBills 复制账单，调用方修改返回的账单不会影响缓存
+func copyBills(bills []*domain.Bill) []*domain.Bill {
+	copied := make([]*domain.Bill, len(bills))
+	for i, bill := range bills {
+		copied[i] = copyBill(bill)
+	}
+	return copied
+}
+
+// QueryTransactions queries transactions within a time range, reusing a recent identical query
+func (r *QueryCacheBillRepository) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	key := fmt.Sprintf("%s|%s|%d|%d|%d", r.keyPrefix(ctx), userName, startTime.UnixMilli(), endTime.UnixMilli(), topN)
+	if cached, ok := r.results.Get(key); ok {
+		r.hits.Add(1)
+		return copyBills(cached.Bills), cached.Income, cached.Expense, nil
+	}
+	r.misses.Add(1)
+
+	bills, income, expense, err := r.BillRepository.QueryTransactions(ctx, userName, startTime, endTime, topN)
+	if err != nil {
+		return bills, income, expense, err
+	}
+	_ = r.results.Set(key, queryResult{Bills: copyBills(bills), Income: income, Expense: expense}, r.ttl)
+	return bills, income, expense, nil
+}
+
+// GetMonthlySummary gets monthly summary for a user, reusing a recent identical query
+func (r *QueryCacheBillRepository) GetMonthlySummary(ctx context.Context, userName string, year, month int) (*domain.MonthlySummary, error) {
+	key := fmt.Sprintf("%s|%s|%d-%02d", r.keyPrefix(ctx), userName, year, month)
+	if cached, ok := r.summaries.Get(key); ok {
+		r.hits.Add(1)
+		summary := *cached
+		return &summary, nil
+	}
+	r.misses.Add(1)
+
+	summary, err := r.BillRepository.GetMonthlySummary(ctx, userName, year, month)
+	if err != nil || summary == nil {
+		return summary, err
+	}
+	stored := *summary
+	_ = r.summaries.Set(key, &stored, r.ttl)
+	return summary, nil
+}
+
+// CreateBill creates a new bill
+func (r *QueryCacheBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	defer r.invalidate(ctx)
+	return r.BillRepository.CreateBill(ctx, bill)
+}
+
+// CreateBills creates several bills at once
+func (r *QueryCacheBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	defer r.invalidate(ctx)
+	return r.BillRepository.CreateBills(ctx, bills)
+}
+
+// UpdateBill updates a bill
+func (r *QueryCacheBillRepository) UpdateBill(ctx context.Context, bill *domain.Bill) error {
+	defer r.invalidate(ctx, bill.RecordID, bill.ID)
+	return r.BillRepository.UpdateBill(ctx, bill)
+}
+
+// DeleteBill deletes a bill
+func (r *QueryCacheBillRepository) DeleteBill(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.BillRepository.DeleteBill(ctx, id)
+}
+
+// DeleteBills deletes several bills at once
+func (r *QueryCacheBillRepository) DeleteBills(ctx context.Context, ids []string) error {
+	defer r.invalidate(ctx, ids...)
+	return r.BillRepository.DeleteBills(ctx, ids)
+}
+
+// RestoreBill restores a soft-deleted bill
+func (r *QueryCacheBillRepository) RestoreBill(ctx context.Context, id string) error {
+	defer r.invalidate(ctx, id)
+	return r.BillRepository.RestoreBill(ctx, id)
+}
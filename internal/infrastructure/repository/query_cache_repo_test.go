@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+)
+
+// countingBillRepository 记录查询次数，写入只保存到内存
+type countingBillRepository struct {
+	domain.BillRepository
+
+	mu      sync.Mutex
+	queries int
+	bills   []*domain.Bill
+}
+
+func (r *countingBillRepository) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries++
+	var expense float64
+	for _, bill := range r.bills {
+		expense += bill.Amount
+	}
+	return copyBills(r.bills), 0, expense, nil
+}
+
+func (r *countingBillRepository) CreateBill(ctx context.Context, bill *domain.Bill) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bills = append(r.bills, copyBill(bill))
+	return nil
+}
+
+func (r *countingBillRepository) DeleteBill(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, bill := range r.bills {
+		if bill.RecordID == id {
+			r.bills = append(r.bills[:i], r.bills[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *countingBillRepository) queryCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queries
+}
+
+var (
+	testRangeStart = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	testRangeEnd   = time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// queryExpense 查询测试范围内的支出
+func queryExpense(t *testing.T, repo domain.BillRepository, ctx context.Context) float64 {
+	t.Helper()
+	_, _, expense, err := repo.QueryTransactions(ctx, "张三", testRangeStart, testRangeEnd, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expense
+}
+
+func newTestQueryCache(t *testing.T, repo domain.BillRepository, newCache cache.Factory) *QueryCacheBillRepository {
+	t.Helper()
+	c := NewQueryCacheBillRepository(repo, time.Minute, newCache)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestQueryCacheWriteInvalidates(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingBillRepository{}
+	repo := newTestQueryCache(t, backend, nil)
+
+	queryExpense(t, repo, ctx)
+	queryExpense(t, repo, ctx)
+	if n := backend.queryCount(); n != 1 {
+		t.Fatalf("backend queried %d times, want 1", n)
+	}
+	if stats := repo.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	if err := repo.CreateBill(ctx, &domain.Bill{RecordID: "rec1", Amount: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryExpense(t, repo, ctx); got != 30 {
+		t.Errorf("expense after create = %v, want 30", got)
+	}
+	if err := repo.DeleteBill(ctx, "rec1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryExpense(t, repo, ctx); got != 0 {
+		t.Errorf("expense after delete = %v, want 0", got)
+	}
+	if n := backend.queryCount(); n != 3 {
+		t.Errorf("backend queried %d times, want 3", n)
+	}
+}
+
+// 写入只使所在账本的缓存失效
+func TestQueryCacheInvalidatesOnlyWrittenLedger(t *testing.T) {
+	backend := &countingBillRepository{}
+	repo := newTestQueryCache(t, backend, nil)
+	group := domain.WithLedger(context.Background(), "group1")
+
+	queryExpense(t, repo, context.Background())
+	queryExpense(t, repo, group)
+	if err := repo.CreateBill(group, &domain.Bill{RecordID: "rec1", Amount: 30}); err != nil {
+		t.Fatal(err)
+	}
+	queryExpense(t, repo, context.Background())
+	queryExpense(t, repo, group)
+	if n := backend.queryCount(); n != 3 {
+		t.Errorf("backend queried %d times, want 3", n)
+	}
+}
+
+// 两个副本共享 Redis：一个副本写入后，另一个副本不再返回缓存的旧结果
+func TestQueryCacheSharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisStore := func(name string) cache.Cache {
+		c, err := cache.NewRedisCache(mr.Addr(), "", 0, "test:"+name, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	ctx := context.Background()
+	backend := &countingBillRepository{}
+	a := newTestQueryCache(t, backend, redisStore)
+	b := newTestQueryCache(t, backend, redisStore)
+
+	queryExpense(t, a, ctx)
+	queryExpense(t, b, ctx)
+	if n := backend.queryCount(); n != 1 {
+		t.Fatalf("backend queried %d times, want 1 shared result", n)
+	}
+
+	if err := b.CreateBill(ctx, &domain.Bill{RecordID: "rec1", Amount: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryExpense(t, a, ctx); got != 30 {
+		t.Errorf("expense on the other replica = %v, want 30", got)
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// recurringRepository 把周期记账规则保存在一个 JSON 文件中，每次修改后整体写回
+type recurringRepository struct {
+	file  string
+	mu    sync.RWMutex
+	rules map[string]*domain.RecurringRule
+}
+
+// NewRecurringRepository creates a recurring rule repository stored in file
+// An empty file keeps the rules in memory only
+func NewRecurringRepository(file string) (domain.RecurringRepository, error) {
+	repo := &recurringRepository{
+		file:  file,
+		rules: make(map[string]*domain.RecurringRule),
+	}
+	if file == "" {
+		return repo, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read recurring rules: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &repo.rules); err != nil {
+			return nil, fmt.Errorf("failed to parse recurring rules: %v", err)
+		}
+	}
+	return repo, nil
+}
+
+// SaveRule creates or replaces a rule
+func (r *recurringRepository) SaveRule(rule *domain.RecurringRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *rule
+	r.rules[rule.ID] = &c
+	return r.save()
+}
+
+// GetRule gets a rule by ID
+func (r *recurringRepository) GetRule(id string) (*domain.RecurringRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, ok := r.rules[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRecurringRuleNotFound, id)
+	}
+	c := *rule
+	return &c, nil
+}
+
+// ListRules lists all rules ordered by creation time
+func (r *recurringRepository) ListRules() ([]*domain.RecurringRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]*domain.RecurringRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		c := *rule
+		rules = append(rules, &c)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules, nil
+}
+
+// DeleteRule deletes a rule
+func (r *recurringRepository) DeleteRule(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[id]; !ok {
+		return fmt.Errorf("%w: %s", domain.ErrRecurringRuleNotFound, id)
+	}
+	delete(r.rules, id)
+	return r.save()
+}
+
+// save 先写临时文件再替换，避免写入中途退出时损坏规则文件
+func (r *recurringRepository) save() error {
+	if r.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.MarshalIndent(r.rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal recurring rules: %v", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write recurring rules: %v", err)
+	}
+	return os.Rename(tmp, r.file)
+}
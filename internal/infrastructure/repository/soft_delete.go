@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// softDeleteSweepInterval 后台清理过期软删除记录的间隔
+const softDeleteSweepInterval = time.Hour
+
+// softDeleteEnabled 是否开启软删除
+func (r *bitableBillRepository) softDeleteEnabled() bool {
+	return r.config.SoftDelete && r.config.FieldDeletedAt != ""
+}
+
+// retention 软删除记录的保留时间
+func (r *bitableBillRepository) retention() time.Duration {
+	return time.Duration(r.config.SoftDeleteRetentionDays) * 24 * time.Hour
+}
+
+// deletedAt 返回记录的删除时间，未删除时为零值
+func (r *bitableBillRepository) deletedAt(record map[string]interface{}) time.Time {
+	if !r.softDeleteEnabled() {
+		return time.Time{}
+	}
+	fields, _ := record["fields"].(map[string]interface{})
+	return getDateField(fields, r.config.FieldDeletedAt, r.location)
+}
+
+// notDeletedCondition 查询时排除软删除记录的过滤条件，未开启软删除时返回 nil
+func (r *bitableBillRepository) notDeletedCondition() map[string]interface{} {
+	if !r.softDeleteEnabled() {
+		return nil
+	}
+	return map[string]interface{}{
+		"field_name": r.config.FieldDeletedAt,
+		"operator":   "isEmpty",
+		"value":      []string{},
+	}
+}
+
+// softDelete 填写删除时间，记录在保留期内仍可恢复
+func (r *bitableBillRepository) softDelete(ctx context.Context, recordID string) error {
+	// 已删除或不存在的记录按不存在处理
+	if _, err := r.GetBill(ctx, recordID); err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{r.config.FieldDeletedAt: time.Now().UnixMilli()}
+	_, err := r.feishuService.UpdateRecordToBitable(ctx, r.token(), r.tableID, recordID, fields)
+	r.refreshOnTokenError(ctx, err)
+	if err != nil {
+		r.logFor(ctx).Error("Failed to soft delete bill in bitable: %v", err)
+		return billError(fmt.Sprintf("failed to delete bill %s", recordID), err)
+	}
+
+	r.logFor(ctx).Info("Soft deleted bill in bitable: RecordID=%s", recordID)
+	return nil
+}
+
+// RestoreBill clears the deletion time of a soft-deleted bill within the retention window.
+// 未删除的记录直接返回成功；超过保留期的记录视为不存在
+func (r *bitableBillRepository) RestoreBill(ctx context.Context, id string) error {
+	if !r.softDeleteEnabled() {
+		return domain.ErrSoftDeleteDisabled
+	}
+	recordID, err := r.resolveRecordID("", id)
+	if err != nil {
+		return fmt.Errorf("failed to get bill for restore: %w", err)
+	}
+
+	record, err := r.feishuService.GetRecordToBitable(ctx, r.token(), r.tableID, recordID)
+	r.refreshOnTokenError(ctx, err)
+	if err != nil {
+		return billError("failed to get record by record_id", err)
+	}
+	deletedAt := r.deletedAt(record)
+	if deletedAt.IsZero() {
+		return nil
+	}
+	if time.Since(deletedAt) > r.retention() {
+		return fmt.Errorf("bill %s was deleted at %s, past the retention window: %w", recordID, deletedAt.Format("2006-01-02 15:04"), domain.ErrBillNotFound)
+	}
+
+	fields := map[string]interface{}{r.config.FieldDeletedAt: nil}
+	_, err = r.feishuService.UpdateRecordToBitable(ctx, r.token(), r.tableID, recordID, fields)
+	r.refreshOnTokenError(ctx, err)
+	if err != nil {
+		r.logFor(ctx).Error("Failed to restore bill in bitable: %v", err)
+		return billError(fmt.Sprintf("failed to restore bill %s", recordID), err)
+	}
+
+	r.logFor(ctx).Info("Restored bill in bitable: RecordID=%s, deleted_at=%s", recordID, deletedAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// purgeDeleted 真正删除删除时间早于 before 的记录，返回删除的条数
+func (r *bitableBillRepository) purgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	filter := map[string]interface{}{
+		"automatic_fields": false,
+		"field_names":      []string{"_id", r.config.FieldDeletedAt},
+		"page_size":        r.config.SearchMaxRecords,
+		"filter": map[string]interface{}{
+			"conjunction": "and",
+			"conditions": []map[string]interface{}{
+				{
+					"field_name": r.config.FieldDeletedAt,
+					"operator":   "isNotEmpty",
+					"value":      []string{},
+				},
+				{
+					"field_name": r.config.FieldDeletedAt,
+					"operator":   "isLess",
+					"value":      []string{"ExactDate", fmt.Sprintf("%d", before.UnixMilli())},
+				},
+			},
+		},
+	}
+	records, err := r.feishuService.ListRecordsWithFilter(ctx, r.token(), r.tableID, filter)
+	r.refreshOnTokenError(ctx, err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list soft deleted bills: %v", err)
+	}
+
+	purged := 0
+	var errs []error
+	for _, record := range records {
+		recordID, _ := record["_id"].(string)
+		// 再次确认删除时间，避免过滤条件不生效时误删
+		deletedAt := r.deletedAt(record)
+		if recordID == "" || deletedAt.IsZero() || !deletedAt.Before(before) {
+			continue
+		}
+		err := r.feishuService.DeleteRecordToBitable(ctx, r.token(), r.tableID, recordID)
+		r.refreshOnTokenError(ctx, err)
+		if err != nil {
+			errs = append(errs, billError(fmt.Sprintf("failed to purge bill %s", recordID), err))
+			continue
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// runSoftDeleteSweeper 启动时和之后每小时清理超过保留期的软删除记录，直到 ctx 取消
+// tables 返回需要清理的表格，群聊独立账本只清理已打开的账本
+func runSoftDeleteSweeper(ctx context.Context, tables func() map[string]*bitableBillRepository) {
+	ctx = logger.WithCorrelationID(ctx, "soft-delete-sweep")
+	ticker := time.NewTicker(softDeleteSweepInterval)
+	defer ticker.Stop()
+	for {
+		for ledger, repo := range tables() {
+			before := time.Now().Add(-repo.retention())
+			purged, err := repo.purgeDeleted(ctx, before)
+			if err != nil {
+				logger.FromContext(ctx, logComponent).Error("Failed to purge soft deleted bills: ledger=%s, purged=%d, err=%v", ledger, purged, err)
+			} else if purged > 0 {
+				logger.FromContext(ctx, logComponent).Info("Purged soft deleted bills: ledger=%s, purged=%d", ledger, purged)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
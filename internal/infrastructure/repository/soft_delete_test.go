@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// newSoftDeleteRepo 创建开启软删除、保留 30 天的假多维表格仓库
+func newSoftDeleteRepo(t *testing.T, handle func(n int, call bitableCall) (int, interface{})) (*bitableBillRepository, *fakeBitable) {
+	repo, fake := newFakeBitableRepo(t, handle)
+	repo.config.SoftDelete = true
+	repo.config.FieldDeletedAt = "删除时间"
+	repo.config.SoftDeleteRetentionDays = 30
+	return repo, fake
+}
+
+// deletedDaysAgo 返回 days 天前删除的账单字段
+func deletedDaysAgo(days int) map[string]interface{} {
+	return map[string]interface{}{"描述": "午饭", "金额": 35, "删除时间": float64(time.Now().AddDate(0, 0, -days).UnixMilli())}
+}
+
+// 删除只填写删除时间，不调用删除接口
+func TestDeleteBillSoftDelete(t *testing.T) {
+	repo, fake := newSoftDeleteRepo(t, func(n int, _ bitableCall) (int, interface{}) {
+		if n == 0 {
+			return http.StatusOK, batchGetResponse(map[string]map[string]interface{}{"rec1": {"描述": "午饭", "金额": 35}})
+		}
+		return http.StatusOK, bitableOK(map[string]interface{}{"record": map[string]interface{}{"record_id": "rec1"}})
+	})
+	before := time.Now().UnixMilli()
+	if err := repo.DeleteBill(context.Background(), "rec1"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.requests()
+	if len(calls) != 2 || calls[1].Method != http.MethodPut || !strings.HasSuffix(calls[1].Path, "/records/rec1") {
+		t.Fatalf("requests = %+v, want a lookup and one PUT of rec1", calls)
+	}
+	fields, _ := calls[1].Body["fields"].(map[string]interface{})
+	deletedAt, _ := fields["删除时间"].(float64)
+	if len(fields) != 1 || int64(deletedAt) < before || int64(deletedAt) > time.Now().UnixMilli() {
+		t.Errorf("fields = %v, want only the deletion time", fields)
+	}
+}
+
+// 已软删除的记录再次删除时视为不存在
+func TestDeleteBillAlreadyDeleted(t *testing.T) {
+	repo, fake := newSoftDeleteRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, batchGetResponse(map[string]map[string]interface{}{"rec1": deletedDaysAgo(1)})
+	})
+	if err := repo.DeleteBill(context.Background(), "rec1"); !errors.Is(err, domain.ErrBillNotFound) {
+		t.Errorf("DeleteBill of a deleted bill = %v, want ErrBillNotFound", err)
+	}
+	if n := len(fake.requests()); n != 1 {
+		t.Errorf("made %d requests, want only the lookup", n)
+	}
+}
+
+// 查询时在服务端排除软删除记录
+func TestQueryTransactionsExcludesSoftDeleted(t *testing.T) {
+	repo, fake := newSoftDeleteRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, searchRecordsPage("", [3]interface{}{"午饭", 35, "支出"})
+	})
+	start := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, _, err := repo.QueryTransactions(context.Background(), "张三", start, start.AddDate(0, 1, 0), 0); err != nil {
+		t.Fatal(err)
+	}
+	filter, _ := fake.requests()[0].Body["filter"].(map[string]interface{})
+	conditions, _ := filter["conditions"].([]interface{})
+	// SDK 省略空的 value，isEmpty 不需要值
+	want := map[string]interface{}{"field_name": "删除时间", "operator": "isEmpty"}
+	found := false
+	for _, c := range conditions {
+		if reflect.DeepEqual(c, want) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("conditions = %v, want the deletion time to be empty", conditions)
+	}
+}
+
+func TestRestoreBill(t *testing.T) {
+	repo, fake := newSoftDeleteRepo(t, func(n int, _ bitableCall) (int, interface{}) {
+		if n == 0 {
+			return http.StatusOK, batchGetResponse(map[string]map[string]interface{}{"rec1": deletedDaysAgo(29)})
+		}
+		return http.StatusOK, bitableOK(map[string]interface{}{"record": map[string]interface{}{"record_id": "rec1"}})
+	})
+	if err := repo.RestoreBill(context.Background(), "rec1"); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.requests()
+	if len(calls) != 2 || calls[1].Method != http.MethodPut {
+		t.Fatalf("requests = %+v, want a lookup and one PUT", calls)
+	}
+	if got := calls[1].Body["fields"]; !reflect.DeepEqual(got, map[string]interface{}{"删除时间": nil}) {
+		t.Errorf("fields = %v, want the deletion time cleared", got)
+	}
+}
+
+func TestRestoreBillNotRestorable(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  map[string]interface{}
+		wantErr error
+	}{
+		// 超过保留期的记录视为不存在
+		{"past retention", deletedDaysAgo(31), domain.ErrBillNotFound},
+		// 未删除的记录直接返回成功
+		{"not deleted", map[string]interface{}{"描述": "午饭"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, fake := newSoftDeleteRepo(t, func(int, bitableCall) (int, interface{}) {
+				return http.StatusOK, batchGetResponse(map[string]map[string]interface{}{"rec1": tt.fields})
+			})
+			if err := repo.RestoreBill(context.Background(), "rec1"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RestoreBill = %v, want %v", err, tt.wantErr)
+			}
+			if n := len(fake.requests()); n != 1 {
+				t.Errorf("made %d requests, want only the lookup", n)
+			}
+		})
+	}
+
+	repo, fake := newFakeBitableRepo(t, func(int, bitableCall) (int, interface{}) {
+		return http.StatusOK, bitableOK(nil)
+	})
+	if err := repo.RestoreBill(context.Background(), "rec1"); !errors.Is(err, domain.ErrSoftDeleteDisabled) {
+		t.Errorf("RestoreBill without soft delete = %v, want ErrSoftDeleteDisabled", err)
+	}
+	if n := len(fake.requests()); n != 0 {
+		t.Errorf("made %d requests without soft delete, want 0", n)
+	}
+}
+
+// 清理只删除保留期之前删除的记录，不信任服务端过滤
+func TestPurgeDeleted(t *testing.T) {
+	before := time.Now().AddDate(0, 0, -30)
+	records := []map[string]interface{}{
+		{"record_id": "recOld", "fields": deletedDaysAgo(40)},
+		{"record_id": "recRecent", "fields": deletedDaysAgo(3)},
+		{"record_id": "recAlive", "fields": map[string]interface{}{"描述": "晚饭"}},
+	}
+	repo, fake := newSoftDeleteRepo(t, func(n int, _ bitableCall) (int, interface{}) {
+		if n == 0 {
+			return http.StatusOK, bitableOK(map[string]interface{}{"items": records, "has_more": false})
+		}
+		return http.StatusOK, bitableOK(map[string]interface{}{"records": []map[string]interface{}{{"record_id": "recOld", "deleted": true}}})
+	})
+
+	purged, err := repo.purgeDeleted(context.Background(), before)
+	if err != nil || purged != 1 {
+		t.Fatalf("purgeDeleted = %d, %v, want 1 purged", purged, err)
+	}
+	calls := fake.requests()
+	if len(calls) != 2 || !strings.HasSuffix(calls[1].Path, "/records/batch_delete") {
+		t.Fatalf("requests = %+v, want a search and one delete", calls)
+	}
+	if got := fmt.Sprint(calls[1].Body["records"]); got != "[recOld]" {
+		t.Errorf("deleted %s, want only recOld", got)
+	}
+	filter, _ := calls[0].Body["filter"].(map[string]interface{})
+	conditions := fmt.Sprint(filter["conditions"])
+	for _, want := range []string{"isNotEmpty", "isLess", fmt.Sprint(before.UnixMilli())} {
+		if !strings.Contains(conditions, want) {
+			t.Errorf("conditions = %s, want %s", conditions, want)
+		}
+	}
+}
+
+// 单条删除失败时继续清理其余记录，并返回错误
+func TestPurgeDeletedPartialFailure(t *testing.T) {
+	records := []map[string]interface{}{
+		{"record_id": "recA", "fields": deletedDaysAgo(40)},
+		{"record_id": "recB", "fields": deletedDaysAgo(50)},
+	}
+	repo, fake := newSoftDeleteRepo(t, func(n int, _ bitableCall) (int, interface{}) {
+		switch n {
+		case 0:
+			return http.StatusOK, bitableOK(map[string]interface{}{"items": records})
+		case 1:
+			return http.StatusBadRequest, map[string]interface{}{"code": 1254001, "msg": "WrongRequestBody"}
+		}
+		return http.StatusOK, bitableOK(map[string]interface{}{"records": []map[string]interface{}{{"record_id": "recB", "deleted": true}}})
+	})
+
+	purged, err := repo.purgeDeleted(context.Background(), time.Now().AddDate(0, 0, -30))
+	if err == nil || purged != 1 {
+		t.Errorf("purgeDeleted = %d, %v, want 1 purged and an error", purged, err)
+	}
+	if n := len(fake.requests()); n != 3 {
+		t.Errorf("made %d requests, want a search and two deletes", n)
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// templateRepository 按用户保存记账模板，保存在一个 JSON 文件中，每次修改后整体写回
+type templateRepository struct {
+	file      string
+	mu        sync.RWMutex
+	templates map[string]map[string]*domain.BillTemplate // 用户名 -> 模板名 -> 模板
+}
+
+// NewTemplateRepository creates a bill template repository stored in file
+// An empty file keeps the templates in memory only
+func NewTemplateRepository(file string) (domain.TemplateRepository, error) {
+	repo := &templateRepository{
+		file:      file,
+		templates: make(map[string]map[string]*domain.BillTemplate),
+	}
+	if file == "" {
+		return repo, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read templates: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &repo.templates); err != nil {
+			return nil, fmt.Errorf("failed to parse templates: %v", err)
+		}
+	}
+	return repo, nil
+}
+
+// SaveTemplate creates or replaces the user's template with the same name
+func (r *templateRepository) SaveTemplate(tpl *domain.BillTemplate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owned, ok := r.templates[tpl.UserName]
+	if !ok {
+		owned = make(map[string]*domain.BillTemplate)
+		r.templates[tpl.UserName] = owned
+	}
+	c := *tpl
+	owned[tpl.Name] = &c
+	return r.save()
+}
+
+// GetTemplate gets a template by user and name
+func (r *templateRepository) GetTemplate(userName, name string) (*domain.BillTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tpl, ok := r.templates[userName][name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTemplateNotFound, name)
+	}
+	c := *tpl
+	return &c, nil
+}
+
+// ListTemplates lists the templates of a user ordered by name
+func (r *templateRepository) ListTemplates(userName string) ([]*domain.BillTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	templates := make([]*domain.BillTemplate, 0, len(r.templates[userName]))
+	for _, tpl := range r.templates[userName] {
+		c := *tpl
+		templates = append(templates, &c)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// DeleteTemplate deletes a template
+func (r *templateRepository) DeleteTemplate(userName, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.templates[userName][name]; !ok {
+		return fmt.Errorf("%w: %s", domain.ErrTemplateNotFound, name)
+	}
+	delete(r.templates[userName], name)
+	if len(r.templates[userName]) == 0 {
+		delete(r.templates, userName)
+	}
+	return r.save()
+}
+
+// save 先写临时文件再替换，避免写入中途退出时损坏模板文件
+func (r *templateRepository) save() error {
+	if r.file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.MarshalIndent(r.templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal templates: %v", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write templates: %v", err)
+	}
+	return os.Rename(tmp, r.file)
+}
@@ -9,24 +9,47 @@ import (
 type TimeRangeType string
 
 const (
-	TimeRangeToday      TimeRangeType = "today"          // 今天
-	TimeRangeYesterday  TimeRangeType = "yesterday"      // 昨天
-	TimeRangeThisWeek   TimeRangeType = "this_week"      // 本周
-	TimeRangeLastWeek   TimeRangeType = "last_week"      // 上周
-	TimeRangeThisMonth  TimeRangeType = "this_month"     // 本月
-	TimeRangeLastMonth  TimeRangeType = "last_month"     // 上个月
-	TimeRangeLast7Days  TimeRangeType = "last_7_days"    // 过去七天
-	TimeRangeLast30Days TimeRangeType = "last_30_days"   // 过去30天
-	TimeRangeCustom     TimeRangeType = "custom"          // 自定义时间范围
+	TimeRangeToday       TimeRangeType = "today"        // 今天
+	TimeRangeYesterday   TimeRangeType = "yesterday"    // 昨天
+	TimeRangeThisWeek    TimeRangeType = "this_week"    // 本周
+	TimeRangeLastWeek    TimeRangeType = "last_week"    // 上周
+	TimeRangeThisMonth   TimeRangeType = "this_month"   // 本月
+	TimeRangeLastMonth   TimeRangeType = "last_month"   // 上个月
+	TimeRangeLast7Days   TimeRangeType = "last_7_days"  // 过去七天
+	TimeRangeLast30Days  TimeRangeType = "last_30_days" // 过去30天
+	TimeRangeThisQuarter TimeRangeType = "this_quarter" // 本季度
+	TimeRangeLastQuarter TimeRangeType = "last_quarter" // 上季度
+	TimeRangeThisYear    TimeRangeType = "this_year"    // 今年
+	TimeRangeLastYear    TimeRangeType = "last_year"    // 去年
+	TimeRangeCustom      TimeRangeType = "custom"       // 自定义时间范围
 )
 
+// nowFunc 返回当前时间，测试中可替换以固定“现在”
+var nowFunc = time.Now
+
+// customRangeLookbackDays 自定义时间范围缺少开始时间时，向前回溯的天数
+var customRangeLookbackDays = 730
+
+// SetCustomRangeLookbackDays 设置自定义时间范围缺少开始时间时向前回溯的天数
+func SetCustomRangeLookbackDays(days int) {
+	if days > 0 {
+		customRangeLookbackDays = days
+	}
+}
+
 // ParseTimeRange 解析时间范围
-// 如果 timeRangeType 是 custom，则使用 startTimeStr 和 endTimeStr
+// 如果 timeRangeType 是 custom，则使用 startTimeStr 和 endTimeStr，两者可以缺省其一：
+// 缺少 endTimeStr 表示截止到现在，缺少 startTimeStr 表示从最早可查询日期开始
 // 如果只提供了日期没有时间，开始时间设为 00:00:00，结束时间设为 23:59:59
 func ParseTimeRange(timeRangeType TimeRangeType, startTimeStr, endTimeStr string) (startTime, endTime time.Time, err error) {
-	now := time.Now()
+	return ParseTimeRangeIn(nowFunc().Location(), timeRangeType, startTimeStr, endTimeStr)
+}
+
+// ParseTimeRangeIn 按指定时区解析时间范围，用于设置了个人时区的用户
+// “今天”“本月”等按该时区的日期计算，custom 的时间也按该时区解释
+func ParseTimeRangeIn(location *time.Location, timeRangeType TimeRangeType, startTimeStr, endTimeStr string) (startTime, endTime time.Time, err error) {
+	now := nowFunc().In(location)
 	year := now.Year()
-	location := now.Location()
 
 	switch timeRangeType {
 	case TimeRangeToday:
@@ -64,14 +87,14 @@ func ParseTimeRange(timeRangeType TimeRangeType, startTimeStr, endTimeStr string
 		endTime = time.Date(lastSunday.Year(), lastSunday.Month(), lastSunday.Day(), 23, 59, 59, 999999999, location)
 
 	case TimeRangeThisMonth:
+		// 从本月 1 日推算，避免 31 日加减一个月时溢出到下下个月
 		startTime = time.Date(year, now.Month(), 1, 0, 0, 0, 0, location)
-		nextMonth := now.AddDate(0, 1, 0)
-		endTime = time.Date(nextMonth.Year(), nextMonth.Month(), 1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
+		endTime = startTime.AddDate(0, 1, 0).Add(-time.Nanosecond)
 
 	case TimeRangeLastMonth:
-		lastMonth := now.AddDate(0, -1, 0)
-		startTime = time.Date(lastMonth.Year(), lastMonth.Month(), 1, 0, 0, 0, 0, location)
-		endTime = time.Date(year, now.Month(), 1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
+		thisMonthStart := time.Date(year, now.Month(), 1, 0, 0, 0, 0, location)
+		startTime = thisMonthStart.AddDate(0, -1, 0)
+		endTime = thisMonthStart.Add(-time.Nanosecond)
 
 	case TimeRangeLast7Days:
 		startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location).AddDate(0, 0, -6)
@@ -81,30 +104,60 @@ func ParseTimeRange(timeRangeType TimeRangeType, startTimeStr, endTimeStr string
 		startTime = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location).AddDate(0, 0, -29)
 		endTime = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, location)
 
+	case TimeRangeThisQuarter:
+		// 本季度：季度首月1日 00:00:00 到下季度首月1日前一纳秒
+		quarterStart := time.Date(year, quarterFirstMonth(now.Month()), 1, 0, 0, 0, 0, location)
+		startTime = quarterStart
+		endTime = quarterStart.AddDate(0, 3, 0).Add(-time.Nanosecond)
+
+	case TimeRangeLastQuarter:
+		// 上季度：本季度开始往前推三个月，跨年时 time.Date 会自动归一化年份
+		thisQuarterStart := time.Date(year, quarterFirstMonth(now.Month()), 1, 0, 0, 0, 0, location)
+		startTime = thisQuarterStart.AddDate(0, -3, 0)
+		endTime = thisQuarterStart.Add(-time.Nanosecond)
+
+	case TimeRangeThisYear:
+		startTime = time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+		endTime = time.Date(year+1, time.January, 1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
+
+	case TimeRangeLastYear:
+		startTime = time.Date(year-1, time.January, 1, 0, 0, 0, 0, location)
+		endTime = time.Date(year, time.January, 1, 0, 0, 0, 0, location).Add(-time.Nanosecond)
+
 	case TimeRangeCustom:
-		if startTimeStr == "" || endTimeStr == "" {
-			return time.Time{}, time.Time{}, fmt.Errorf("custom time range requires both start_time and end_time")
+		// 开始和结束时间至少提供一个：缺少结束时间表示到现在，缺少开始时间表示从最早可查询日期开始
+		if startTimeStr == "" && endTimeStr == "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("custom time range requires start_time or end_time")
 		}
 
-		// 尝试解析完整的时间格式 YYYY-MM-DD hh:mm:ss
-		startTime, err = time.Parse("2006-01-02 15:04:05", startTimeStr)
-		if err != nil {
-			// 如果失败，尝试只解析日期 YYYY-MM-DD，然后设置为 00:00:00
-			startTime, err = time.Parse("2006-01-02", startTimeStr)
+		if startTimeStr == "" {
+			earliest := now.AddDate(0, 0, -customRangeLookbackDays)
+			startTime = time.Date(earliest.Year(), earliest.Month(), earliest.Day(), 0, 0, 0, 0, location)
+		} else {
+			// 尝试解析完整的时间格式 YYYY-MM-DD hh:mm:ss
+			startTime, err = time.ParseInLocation("2006-01-02 15:04:05", startTimeStr, location)
 			if err != nil {
-				return time.Time{}, time.Time{}, fmt.Errorf("invalid start_time format: %v", err)
+				// 如果失败，尝试只解析日期 YYYY-MM-DD，然后设置为 00:00:00
+				startTime, err = time.Parse("2006-01-02", startTimeStr)
+				if err != nil {
+					return time.Time{}, time.Time{}, fmt.Errorf("invalid start_time format: %v", err)
+				}
+				startTime = time.Date(startTime.Year(), startTime.Month(), startTime.Day(), 0, 0, 0, 0, location)
 			}
-			startTime = time.Date(startTime.Year(), startTime.Month(), startTime.Day(), 0, 0, 0, 0, location)
 		}
 
-		endTime, err = time.Parse("2006-01-02 15:04:05", endTimeStr)
-		if err != nil {
-			// 如果失败，尝试只解析日期 YYYY-MM-DD，然后设置为 23:59:59
-			endTime, err = time.Parse("2006-01-02", endTimeStr)
+		if endTimeStr == "" {
+			endTime = now
+		} else {
+			endTime, err = time.ParseInLocation("2006-01-02 15:04:05", endTimeStr, location)
 			if err != nil {
-				return time.Time{}, time.Time{}, fmt.Errorf("invalid end_time format: %v", err)
+				// 如果失败，尝试只解析日期 YYYY-MM-DD，然后设置为 23:59:59
+				endTime, err = time.Parse("2006-01-02", endTimeStr)
+				if err != nil {
+					return time.Time{}, time.Time{}, fmt.Errorf("invalid end_time format: %v", err)
+				}
+				endTime = time.Date(endTime.Year(), endTime.Month(), endTime.Day(), 23, 59, 59, 999999999, location)
 			}
-			endTime = time.Date(endTime.Year(), endTime.Month(), endTime.Day(), 23, 59, 59, 999999999, location)
 		}
 
 	default:
@@ -114,3 +167,7 @@ func ParseTimeRange(timeRangeType TimeRangeType, startTimeStr, endTimeStr string
 	return startTime, endTime, nil
 }
 
+// quarterFirstMonth 返回月份所在季度的第一个月
+func quarterFirstMonth(month time.Month) time.Month {
+	return month - (month-1)%3
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+// freezeNow 把 nowFunc 固定为 now，测试结束后恢复
+func freezeNow(t *testing.T, now time.Time) {
+	t.Helper()
+	nowFunc = func() time.Time { return now }
+	t.Cleanup(func() { nowFunc = time.Now })
+}
+
+func TestParseTimeRangeQuarterAndYear(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, shanghai)
+	}
+	endOf := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 23, 59, 59, 999999999, shanghai)
+	}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		rangeType TimeRangeType
+		start     time.Time
+		end       time.Time
+	}{
+		{"this year", day(2025, time.June, 15), TimeRangeThisYear, day(2025, time.January, 1), endOf(2025, time.December, 31)},
+		{"last year", day(2025, time.June, 15), TimeRangeLastYear, day(2024, time.January, 1), endOf(2024, time.December, 31)},
+		// 元旦零点已经是新的一年
+		{"this year at new year", day(2026, time.January, 1), TimeRangeThisYear, day(2026, time.January, 1), endOf(2026, time.December, 31)},
+		{"last year on new year's eve", endOf(2025, time.December, 31), TimeRangeLastYear, day(2024, time.January, 1), endOf(2024, time.December, 31)},
+		{"this quarter", day(2025, time.May, 20), TimeRangeThisQuarter, day(2025, time.April, 1), endOf(2025, time.June, 30)},
+		{"this quarter last day", endOf(2025, time.September, 30), TimeRangeThisQuarter, day(2025, time.July, 1), endOf(2025, time.September, 30)},
+		{"last quarter", day(2025, time.August, 1), TimeRangeLastQuarter, day(2025, time.April, 1), endOf(2025, time.June, 30)},
+		// 第一季度的上季度在去年
+		{"last quarter across year", day(2025, time.February, 10), TimeRangeLastQuarter, day(2024, time.October, 1), endOf(2024, time.December, 31)},
+		// 闰年的第一季度包含 2 月 29 日
+		{"leap year quarter", day(2024, time.February, 29), TimeRangeThisQuarter, day(2024, time.January, 1), endOf(2024, time.March, 31)},
+		// 31 日的上个月/本月不能溢出到相邻月份
+		{"leap year last month", day(2024, time.March, 31), TimeRangeLastMonth, day(2024, time.February, 1), endOf(2024, time.February, 29)},
+		{"this month on the 31st", day(2025, time.January, 31), TimeRangeThisMonth, day(2025, time.January, 1), endOf(2025, time.January, 31)},
+		{"last month across year", day(2025, time.January, 31), TimeRangeLastMonth, day(2024, time.December, 1), endOf(2024, time.December, 31)},
+		{"leap day today", day(2024, time.February, 29), TimeRangeToday, day(2024, time.February, 29), endOf(2024, time.February, 29)},
+		{"yesterday across year", day(2025, time.January, 1), TimeRangeYesterday, day(2024, time.December, 31), endOf(2024, time.December, 31)},
+		// 2025-01-01 是周三，本周从上一年的周一开始
+		{"this week across year", day(2025, time.January, 1), TimeRangeThisWeek, day(2024, time.December, 30), endOf(2025, time.January, 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freezeNow(t, tt.now)
+			start, end, err := ParseTimeRangeIn(shanghai, tt.rangeType, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tt.start) || !end.Equal(tt.end) {
+				t.Errorf("range = %s ~ %s, want %s ~ %s", start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+// 边界按传入时区的日期计算，而不是服务器时区
+func TestParseTimeRangeInLocation(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// UTC 12 月 31 日 20:00 在上海已是新年
+	freezeNow(t, time.Date(2024, time.December, 31, 20, 0, 0, 0, time.UTC))
+
+	start, _, err := ParseTimeRangeIn(shanghai, TimeRangeThisYear, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, time.January, 1, 0, 0, 0, 0, shanghai); !start.Equal(want) {
+		t.Errorf("start in Shanghai = %s, want %s", start, want)
+	}
+	start, _, err = ParseTimeRangeIn(time.UTC, TimeRangeThisYear, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("start in UTC = %s, want %s", start, want)
+	}
+}
+
+func TestParseTimeRangeCustom(t *testing.T) {
+	freezeNow(t, time.Date(2025, time.March, 10, 9, 30, 0, 0, time.UTC))
+
+	start, end, err := ParseTimeRangeIn(time.UTC, TimeRangeCustom, "2025-02-01", "2025-02-28")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, time.February, 28, 23, 59, 59, 999999999, time.UTC)) {
+		t.Errorf("range = %s ~ %s, want the whole days", start, end)
+	}
+	// 缺少结束时间表示截止到现在
+	if _, end, err = ParseTimeRangeIn(time.UTC, TimeRangeCustom, "2025-03-01", ""); err != nil || !end.Equal(nowFunc()) {
+		t.Errorf("open end = %s, %v, want now", end, err)
+	}
+	if _, _, err = ParseTimeRangeIn(time.UTC, TimeRangeCustom, "", ""); err == nil {
+		t.Error("custom range without start and end succeeded")
+	}
+}
+
+// 缺少开始时间时从回溯天数前的 0 点开始
+func TestParseTimeRangeCustomOpenStart(t *testing.T) {
+	freezeNow(t, time.Date(2025, time.March, 10, 9, 30, 0, 0, time.UTC))
+	defer SetCustomRangeLookbackDays(customRangeLookbackDays)
+	SetCustomRangeLookbackDays(30)
+	// 非正数不修改回溯天数
+	SetCustomRangeLookbackDays(0)
+
+	start, end, err := ParseTimeRangeIn(time.UTC, TimeRangeCustom, "", "2025-03-01 12:00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, time.February, 8, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("open start = %s, want %s", start, want)
+	}
+	if want := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("end = %s, want %s", end, want)
+	}
+	if _, _, err := ParseTimeRangeIn(time.UTC, TimeRangeCustom, "", "3月1日"); err == nil {
+		t.Error("invalid end_time accepted")
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// maxNameSuffix 查找未被占用的编号名字时最多尝试到的编号
+const maxNameSuffix = 100
+
+// uniqueNameRepository keeps user names unique across users. Bills are recorded under the user
+// name, so two users with the same name could not be told apart in a shared ledger
+type uniqueNameRepository struct {
+	domain.UserMappingRepository
+	// suffix 为 true 时重名自动加编号，否则返回 domain.UserNameTakenError
+	suffix bool
+	// mu 使检查名字和保存名字不会与其他用户的改名交错
+	mu sync.Mutex
+}
+
+// NewUniqueNameRepository wraps repo so that SetUserName refuses a name used by another user,
+// or with suffix appends a number such as "张三(2)". Names already shared by several users are logged
+func NewUniqueNameRepository(repo domain.UserMappingRepository, suffix bool) domain.UserMappingRepository {
+	logDuplicateUserNames(repo)
+	return &uniqueNameRepository{UserMappingRepository: repo, suffix: suffix}
+}
+
+// SetUserName sets the user name of a platform user, creating the mapping when absent
+func (r *uniqueNameRepository) SetUserName(platform domain.Platform, platformID, userName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name, err := r.availableName(platform, platformID, userName)
+	if err != nil {
+		return err
+	}
+	return r.UserMappingRepository.SetUserName(platform, platformID, name)
+}
+
+// availableName 返回该用户可以使用的名字：未被其他用户使用时原样返回，否则按配置加编号或返回错误
+func (r *uniqueNameRepository) availableName(platform domain.Platform, platformID, userName string) (string, error) {
+	name := strings.TrimSpace(userName)
+	if name == "" {
+		// 交给底层仓库报告空名字
+		return userName, nil
+	}
+	self, err := r.GetMapping(platform, platformID)
+	if err != nil {
+		self = &domain.UserMapping{PlatformID: domain.PlatformUserID(platform, platformID)}
+	}
+
+	taken, err := r.takenByOthers(name, self)
+	if err != nil || !taken {
+		return userName, err
+	}
+	for n := 2; n <= maxNameSuffix; n++ {
+		candidate := domain.NumberedUserName(name, n)
+		taken, err := r.takenByOthers(candidate, self)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			continue
+		}
+		if r.suffix {
+			logger.GetLogger(logComponent).Info("User name %q is taken, using %q for %s", name, candidate, self.PlatformID)
+			return candidate, nil
+		}
+		return "", &domain.UserNameTakenError{Name: name, Suggestion: candidate}
+	}
+	return "", fmt.Errorf("%w: no free numbered name for %q", domain.ErrUserNameTaken, name)
+}
+
+// takenByOthers 判断名字是否已被 self 以外的用户使用
+func (r *uniqueNameRepository) takenByOthers(name string, self *domain.UserMapping) (bool, error) {
+	mappings, err := r.FindByUserName(name)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up user name %q: %v", name, err)
+	}
+	for _, m := range mappings {
+		if !domain.SameUser(m, self) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Close closes the wrapped repository when it holds resources
+func (r *uniqueNameRepository) Close() error {
+	if closer, ok := r.UserMappingRepository.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// logDuplicateUserNames 启动时报告已被多个用户使用的名字，这些用户的账单在账本中无法区分
+func logDuplicateUserNames(repo domain.UserMappingRepository) {
+	mappings, err := repo.ListMappings()
+	if err != nil {
+		logger.GetLogger(logComponent).Error("Failed to check duplicate user names: %v", err)
+		return
+	}
+	byName := make(map[string][]*domain.UserMapping)
+	for _, m := range domain.UniqueUsers(mappings) {
+		name := strings.TrimSpace(m.UserName)
+		byName[name] = append(byName[name], m)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name, users := range byName {
+		if len(users) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ids := make([]string, 0, len(byName[name]))
+		for _, m := range byName[name] {
+			ids = append(ids, m.PlatformID)
+		}
+		logger.GetLogger(logComponent).Error("DUPLICATE USER NAME %q is used by %d users (%s); their records cannot be told apart, rename them with /admin rename",
+			name, len(ids), strings.Join(ids, ", "))
+	}
+}
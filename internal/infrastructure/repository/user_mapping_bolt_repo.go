@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/atomicfile"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// boltUsersBucket 用户记录，key 为 domain.PlatformUserID，value 为 userRecord 的 JSON
+	boltUsersBucket = []byte("users")
+	// boltMetaBucket 存储自身的元数据，如是否已导入 user_mapping.json
+	boltMetaBucket = []byte("meta")
+	// boltMigratedKey 记录已导入 JSON 文件，之后不再重复导入
+	boltMigratedKey = []byte("json_migrated")
+)
+
+// boltUserMappingRepository implements UserMappingRepository on a bbolt database,
+// reading and writing one user per operation instead of rewriting a whole file
+type boltUserMappingRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltUserMappingRepository opens (or creates) the bbolt database at path.
+// On first open, user_mapping.json in the same directory is imported; the file is left in place.
+func NewBoltUserMappingRepository(path string) (domain.UserMappingRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	// 数据库文件被其他进程锁定时不无限等待
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user database %s: %v", path, err)
+	}
+
+	repo := &boltUserMappingRepository{db: db}
+	if err := repo.migrate(filepath.Join(filepath.Dir(path), "user_mapping.json")); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// migrate 创建 bucket，并在首次打开时导入 JSON 文件中的用户
+func (r *boltUserMappingRepository) migrate(jsonFile string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		users, err := tx.CreateBucketIfNotExists(boltUsersBucket)
+		if err != nil {
+			return fmt.Errorf("failed to create users bucket: %v", err)
+		}
+		meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		if err != nil {
+			return fmt.Errorf("failed to create meta bucket: %v", err)
+		}
+		if meta.Get(boltMigratedKey) != nil {
+			return nil
+		}
+
+		var mappings map[string]*userRecord
+		_, err = atomicfile.Load(jsonFile, func(data []byte) error {
+			mappings, err = parseUserMappings(data)
+			return err
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to import %s: %v", jsonFile, err)
+		}
+		for openID, record := range mappings {
+			if err := putUserRecord(users, openID, record); err != nil {
+				return err
+			}
+		}
+		if len(mappings) > 0 {
+			logger.GetLogger(logComponent).Info("Imported %d users from %s", len(mappings), jsonFile)
+		}
+		return meta.Put(boltMigratedKey, []byte(time.Now().Format(time.RFC3339)))
+	})
+}
+
+// GetUserName gets the user name of a platform user
+func (r *boltUserMappingRepository) GetUserName(platform domain.Platform, platformID string) (string, error) {
+	openID := domain.PlatformUserID(platform, platformID)
+	record, err := r.get(openID)
+	if err != nil {
+		return "", err
+	}
+	if record == nil {
+		return "", fmt.Errorf("user name not found for openID: %s", openID)
+	}
+	if strings.TrimSpace(record.UserName) == "" {
+		return "", fmt.Errorf("user name is empty or invalid for openID: %s", openID)
+	}
+	return record.UserName, nil
+}
+
+// SetUserName sets the user name of a platform user, creating the mapping when absent
+func (r *boltUserMappingRepository) SetUserName(platform domain.Platform, platformID, userName string) error {
+	if strings.TrimSpace(userName) == "" {
+		return fmt.Errorf("user name cannot be empty")
+	}
+	return r.update(domain.PlatformUserID(platform, platformID), func(record *userRecord) {
+		record.UserName = userName
+	})
+}
+
+// GetPreferences gets the preferences of a platform user; unknown users get empty preferences
+func (r *boltUserMappingRepository) GetPreferences(platform domain.Platform, platformID string) (domain.UserPreferences, error) {
+	record, err := r.get(domain.PlatformUserID(platform, platformID))
+	if err != nil || record == nil {
+		return domain.UserPreferences{}, err
+	}
+	return record.Preferences, nil
+}
+
+// SetPreferences replaces the preferences of a platform user, creating the mapping when absent
+func (r *boltUserMappingRepository) SetPreferences(platform domain.Platform, platformID string, prefs domain.UserPreferences) error {
+	return r.update(domain.PlatformUserID(platform, platformID), func(record *userRecord) {
+		record.Preferences = prefs
+	})
+}
+
+// GetMapping gets the whole mapping of a platform user
+func (r *boltUserMappingRepository) GetMapping(platform domain.Platform, platformID string) (*domain.UserMapping, error) {
+	openID := domain.PlatformUserID(platform, platformID)
+	record, err := r.get(openID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUserNotFound, openID)
+	}
+	return &domain.UserMapping{PlatformID: openID, UserID: record.UserID, UserName: record.UserName, Preferences: record.Preferences}, nil
+}
+
+// CopyMapping copies the mapping of fromID to toID, keeping both
+func (r *boltUserMappingRepository) CopyMapping(platform domain.Platform, fromID, toID string) error {
+	from, to := domain.PlatformUserID(platform, fromID), domain.PlatformUserID(platform, toID)
+	return r.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(boltUsersBucket)
+		data := users.Get([]byte(from))
+		if data == nil {
+			return fmt.Errorf("%w: %s", domain.ErrUserNotFound, from)
+		}
+		if users.Get([]byte(to)) != nil {
+			return nil
+		}
+		// Get 返回的切片只在事务内有效，Put 前复制一份
+		return users.Put([]byte(to), append([]byte(nil), data...))
+	})
+}
+
+// DeleteMapping deletes the mapping and preferences of a platform user
+func (r *boltUserMappingRepository) DeleteMapping(platform domain.Platform, platformID string) error {
+	openID := domain.PlatformUserID(platform, platformID)
+	return r.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(boltUsersBucket)
+		if users.Get([]byte(openID)) == nil {
+			return fmt.Errorf("%w: %s", domain.ErrUserNotFound, openID)
+		}
+		return users.Delete([]byte(openID))
+	})
+}
+
+// ListMappings lists all known users ordered by open ID
+func (r *boltUserMappingRepository) ListMappings() ([]*domain.UserMapping, error) {
+	var mappings []*domain.UserMapping
+	err := r.db.View(func(tx *bolt.Tx) error {
+		// bbolt 按 key 的字节序遍历，结果已按 open ID 排序
+		return tx.Bucket(boltUsersBucket).ForEach(func(k, v []byte) error {
+			var record userRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("invalid user record %s: %v", k, err)
+			}
+			if strings.TrimSpace(record.UserName) == "" {
+				return nil
+			}
+			mappings = append(mappings, &domain.UserMapping{PlatformID: string(k), UserID: record.UserID, UserName: record.UserName, Preferences: record.Preferences})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// FindByUserName lists the mappings named name ordered by open ID
+func (r *boltUserMappingRepository) FindByUserName(name string) ([]*domain.UserMapping, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	mappings, err := r.ListMappings()
+	if err != nil {
+		return nil, err
+	}
+	// 用户数量不多，遍历全部用户即可，不单独维护名字索引
+	var found []*domain.UserMapping
+	for _, m := range mappings {
+		if strings.TrimSpace(m.UserName) == name {
+			found = append(found, m)
+		}
+	}
+	return found, nil
+}
+
+// Close closes the database
+func (r *boltUserMappingRepository) Close() error {
+	return r.db.Close()
+}
+
+// get 读取用户记录，不存在时返回 nil
+func (r *boltUserMappingRepository) get(openID string) (*userRecord, error) {
+	var record *userRecord
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltUsersBucket).Get([]byte(openID))
+		if data == nil {
+			return nil
+		}
+		record = &userRecord{}
+		if err := json.Unmarshal(data, record); err != nil {
+			return fmt.Errorf("invalid user record %s: %v", openID, err)
+		}
+		return nil
+	})
+	return record, err
+}
+
+// update 在同一事务中读取、修改并写回用户记录，不存在时新建并生成用户 ID
+// CopyMapping 复制出的同一用户 ID 的记录一并修改
+func (r *boltUserMappingRepository) update(openID string, change func(record *userRecord)) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(boltUsersBucket)
+		data := users.Get([]byte(openID))
+		if data == nil {
+			record := &userRecord{UserID: uuid.New().String()}
+			change(record)
+			return putUserRecord(users, openID, record)
+		}
+
+		var userID string
+		var ids []string
+		records := make(map[string]*userRecord)
+		if err := users.ForEach(func(k, v []byte) error {
+			record := &userRecord{}
+			if err := json.Unmarshal(v, record); err != nil {
+				return fmt.Errorf("invalid user record %s: %v", k, err)
+			}
+			ids = append(ids, string(k))
+			records[string(k)] = record
+			if string(k) == openID {
+				userID = record.UserID
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		// 遍历时不能修改 bucket，读完后再写回
+		for _, id := range ids {
+			record := records[id]
+			if id != openID && (userID == "" || record.UserID != userID) {
+				continue
+			}
+			change(record)
+			if err := putUserRecord(users, id, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// putUserRecord 写入一条用户记录
+func putUserRecord(users *bolt.Bucket, openID string, record *userRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user %s: %v", openID, err)
+	}
+	return users.Put([]byte(openID), data)
+}
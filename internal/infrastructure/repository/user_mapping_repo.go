@@ -5,24 +5,37 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/google/uuid"
 	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/atomicfile"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
 )
 
 // userMappingRepository implements UserMappingRepository with file-based storage
 type userMappingRepository struct {
 	dataDir  string
 	mu       sync.RWMutex
-	mappings map[string]string // openID -> userName
+	mappings map[string]*userRecord // domain.PlatformUserID -> 用户信息
+}
+
+// userRecord user_mapping.json 中每个用户保存的内容
+// 旧版文件中值直接是用户名字符串，加载时自动迁移为该结构，偏好为空即使用全局配置
+type userRecord struct {
+	UserID      string                 `json:"user_id"`
+	UserName    string                 `json:"user_name"`
+	Preferences domain.UserPreferences `json:"preferences"`
 }
 
 // NewUserMappingRepository creates a new user mapping repository
+// An empty dataDir keeps the mappings in memory only
 func NewUserMappingRepository(dataDir string) (domain.UserMappingRepository, error) {
 	repo := &userMappingRepository{
 		dataDir:  dataDir,
-		mappings: make(map[string]string),
+		mappings: make(map[string]*userRecord),
 	}
 
 	// Try to load from file
@@ -36,15 +49,17 @@ func NewUserMappingRepository(dataDir string) (domain.UserMappingRepository, err
 	return repo, nil
 }
 
-// GetUserName gets user name by open ID
-func (r *userMappingRepository) GetUserName(openID string) (string, error) {
+// GetUserName gets the user name of a platform user
+func (r *userMappingRepository) GetUserName(platform domain.Platform, platformID string) (string, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	name, exists := r.mappings[openID]
+	openID := domain.PlatformUserID(platform, platformID)
+	record, exists := r.mappings[openID]
 	if !exists {
 		return "", fmt.Errorf("user name not found for openID: %s", openID)
 	}
+	name := record.UserName
 
 	// Validate that the retrieved name is not empty or whitespace-only
 	if name == "" || strings.TrimSpace(name) == "" {
@@ -54,8 +69,8 @@ func (r *userMappingRepository) GetUserName(openID string) (string, error) {
 	return name, nil
 }
 
-// SetUserName sets user name for open ID
-func (r *userMappingRepository) SetUserName(openID, userName string) error {
+// SetUserName sets the user name of a platform user, creating the mapping when absent
+func (r *userMappingRepository) SetUserName(platform domain.Platform, platformID, userName string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -65,30 +80,192 @@ func (r *userMappingRepository) SetUserName(openID, userName string) error {
 	}
 
 	// Update mapping
-	r.mappings[openID] = userName
+	r.update(domain.PlatformUserID(platform, platformID), func(record *userRecord) {
+		record.UserName = userName
+	})
 
 	// Save to file
 	return r.save()
 }
 
+// GetPreferences gets the preferences of a platform user; unknown users get empty preferences
+func (r *userMappingRepository) GetPreferences(platform domain.Platform, platformID string) (domain.UserPreferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if record, exists := r.mappings[domain.PlatformUserID(platform, platformID)]; exists {
+		return record.Preferences, nil
+	}
+	return domain.UserPreferences{}, nil
+}
+
+// SetPreferences replaces the preferences of a platform user, creating the mapping when absent
+func (r *userMappingRepository) SetPreferences(platform domain.Platform, platformID string, prefs domain.UserPreferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.update(domain.PlatformUserID(platform, platformID), func(record *userRecord) {
+		record.Preferences = prefs
+	})
+	return r.save()
+}
+
+// GetMapping gets the whole mapping of a platform user
+func (r *userMappingRepository) GetMapping(platform domain.Platform, platformID string) (*domain.UserMapping, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	openID := domain.PlatformUserID(platform, platformID)
+	record, exists := r.mappings[openID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUserNotFound, openID)
+	}
+	return &domain.UserMapping{PlatformID: openID, UserID: record.UserID, UserName: record.UserName, Preferences: record.Preferences}, nil
+}
+
+// CopyMapping copies the mapping of fromID to toID, keeping both
+func (r *userMappingRepository) CopyMapping(platform domain.Platform, fromID, toID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	from, to := domain.PlatformUserID(platform, fromID), domain.PlatformUserID(platform, toID)
+	record, exists := r.mappings[from]
+	if !exists {
+		return fmt.Errorf("%w: %s", domain.ErrUserNotFound, from)
+	}
+	if _, exists := r.mappings[to]; exists {
+		return nil
+	}
+	copied := *record
+	r.mappings[to] = &copied
+	return r.save()
+}
+
+// DeleteMapping deletes the mapping and preferences of a platform user
+func (r *userMappingRepository) DeleteMapping(platform domain.Platform, platformID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	openID := domain.PlatformUserID(platform, platformID)
+	if _, exists := r.mappings[openID]; !exists {
+		return fmt.Errorf("%w: %s", domain.ErrUserNotFound, openID)
+	}
+	delete(r.mappings, openID)
+	return r.save()
+}
+
+// record 返回用户的记录，不存在时新建并生成用户 ID，调用方需持有写锁
+func (r *userMappingRepository) record(openID string) *userRecord {
+	record, exists := r.mappings[openID]
+	if !exists {
+		record = &userRecord{UserID: uuid.New().String()}
+		r.mappings[openID] = record
+	}
+	return record
+}
+
+// update 修改用户的记录，不存在时新建；CopyMapping 复制出的同一用户 ID 的记录一并修改，调用方需持有写锁
+func (r *userMappingRepository) update(openID string, change func(record *userRecord)) {
+	record := r.record(openID)
+	for _, linked := range r.mappings {
+		if linked != record && linked.UserID == record.UserID {
+			change(linked)
+		}
+	}
+	change(record)
+}
+
+// ListMappings lists all known users ordered by open ID
+func (r *userMappingRepository) ListMappings() ([]*domain.UserMapping, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mappings := make([]*domain.UserMapping, 0, len(r.mappings))
+	for openID, record := range r.mappings {
+		if strings.TrimSpace(record.UserName) == "" {
+			continue
+		}
+		mappings = append(mappings, &domain.UserMapping{PlatformID: openID, UserID: record.UserID, UserName: record.UserName, Preferences: record.Preferences})
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].PlatformID < mappings[j].PlatformID
+	})
+	return mappings, nil
+}
+
+// FindByUserName lists the mappings named name ordered by open ID
+func (r *userMappingRepository) FindByUserName(name string) ([]*domain.UserMapping, error) {
+	name = strings.TrimSpace(name)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var mappings []*domain.UserMapping
+	for openID, record := range r.mappings {
+		if name != "" && strings.TrimSpace(record.UserName) == name {
+			mappings = append(mappings, &domain.UserMapping{PlatformID: openID, UserID: record.UserID, UserName: record.UserName, Preferences: record.Preferences})
+		}
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].PlatformID < mappings[j].PlatformID
+	})
+	return mappings, nil
+}
+
 // load loads mappings from file
+// 文件损坏时（如写入中途断电）尝试上一次保存时留下的备份
 func (r *userMappingRepository) load() error {
+	if r.dataDir == "" {
+		return nil
+	}
 	filePath := filepath.Join(r.dataDir, "user_mapping.json")
 
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
+	fromBackup, err := atomicfile.Load(filePath, func(data []byte) error {
+		mappings, err := parseUserMappings(data)
+		if err != nil {
+			return err
+		}
+		r.mappings = mappings
+		return nil
+	})
+	if fromBackup {
+		logger.GetLogger(logComponent).Error("User mapping file %s is corrupted, restored %d users from %s", filePath, len(r.mappings), atomicfile.BackupPath(filePath))
 	}
+	return err
+}
 
+// parseUserMappings 解析 user_mapping.json，兼容旧版只保存用户名的格式
+func parseUserMappings(data []byte) (map[string]*userRecord, error) {
+	mappings := make(map[string]*userRecord)
 	if len(data) == 0 {
-		return nil
+		return mappings, nil
 	}
 
-	return json.Unmarshal(data, &r.mappings)
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for openID, value := range raw {
+		record := &userRecord{}
+		// 旧版格式 {"openID": "userName"}，迁移后偏好为空，下次保存时写成新格式
+		if err := json.Unmarshal(value, &record.UserName); err != nil {
+			if err := json.Unmarshal(value, record); err != nil {
+				return nil, fmt.Errorf("invalid user mapping for %s: %v", openID, err)
+			}
+		}
+		// 旧版记录没有用户 ID，加载时补上，下次保存时写入文件
+		if record.UserID == "" {
+			record.UserID = uuid.New().String()
+		}
+		mappings[openID] = record
+	}
+	return mappings, nil
 }
 
 // save saves mappings to file
 func (r *userMappingRepository) save() error {
+	if r.dataDir == "" {
+		return nil
+	}
 	filePath := filepath.Join(r.dataDir, "user_mapping.json")
 
 	// Create directory if needed
@@ -101,5 +278,6 @@ func (r *userMappingRepository) save() error {
 		return fmt.Errorf("failed to marshal mappings: %v", err)
 	}
 
-	return os.WriteFile(filePath, data, 0644)
+	// 先写临时文件再重命名，并保留上一版本作为备份，写入中途退出不会损坏文件
+	return atomicfile.WriteFile(filePath, data, 0644)
 }
@@ -0,0 +1,449 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// userMappingBackends 各个用户映射实现，open 在 dir 中打开（或重新打开）存储
+var userMappingBackends = []struct {
+	name string
+	open func(dir string) (domain.UserMappingRepository, error)
+}{
+	{name: "json", open: NewUserMappingRepository},
+	{name: "bolt", open: func(dir string) (domain.UserMappingRepository, error) {
+		return NewBoltUserMappingRepository(filepath.Join(dir, "users.db"))
+	}},
+}
+
+// closeRepo 关闭需要关闭的实现，bbolt 在关闭前不能被再次打开
+func closeRepo(t *testing.T, repo domain.UserMappingRepository) {
+	t.Helper()
+	if c, ok := repo.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// forEachUserMappingBackend 对每个实现在新的目录中运行 test
+func forEachUserMappingBackend(t *testing.T, test func(t *testing.T, dir string, open func() domain.UserMappingRepository)) {
+	for _, backend := range userMappingBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			dir := t.TempDir()
+			open := func() domain.UserMappingRepository {
+				t.Helper()
+				repo, err := backend.open(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return repo
+			}
+			test(t, dir, open)
+		})
+	}
+}
+
+func TestUserMappingNames(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		repo := open()
+		defer closeRepo(t, repo)
+
+		if _, err := repo.GetUserName(domain.PlatformFeishu, "ou_1"); err == nil {
+			t.Error("GetUserName of an unknown user succeeded")
+		}
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "  "); err == nil {
+			t.Error("SetUserName accepted a blank name")
+		}
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetUserName(domain.PlatformTelegram, "42", "李四"); err != nil {
+			t.Fatal(err)
+		}
+		if name, err := repo.GetUserName(domain.PlatformFeishu, "ou_1"); err != nil || name != "张三" {
+			t.Errorf("GetUserName = %q, %v, want 张三", name, err)
+		}
+		// 同一个 ID 在不同平台是不同的用户
+		if _, err := repo.GetUserName(domain.PlatformFeishu, "42"); err == nil {
+			t.Error("telegram user found as a feishu user")
+		}
+
+		// 改名保留内部用户 ID
+		before, err := repo.GetMapping(domain.PlatformFeishu, "ou_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before.UserID == "" {
+			t.Error("new mapping has no user ID")
+		}
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三丰"); err != nil {
+			t.Fatal(err)
+		}
+		after, err := repo.GetMapping(domain.PlatformFeishu, "ou_1")
+		if err != nil || after.UserName != "张三丰" || after.UserID != before.UserID {
+			t.Errorf("GetMapping after rename = %+v, %v, want the same user ID %s", after, err, before.UserID)
+		}
+	})
+}
+
+func TestUserMappingPreferences(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		repo := open()
+		defer closeRepo(t, repo)
+
+		if prefs, err := repo.GetPreferences(domain.PlatformFeishu, "ou_1"); err != nil || prefs != (domain.UserPreferences{}) {
+			t.Errorf("GetPreferences of an unknown user = %+v, %v, want empty", prefs, err)
+		}
+		// 只设置偏好的用户没有名字，不出现在用户列表中
+		prefs := domain.UserPreferences{Currency: "$", Timezone: "America/New_York", Language: "en", TopN: 3}
+		if err := repo.SetPreferences(domain.PlatformFeishu, "ou_1", prefs); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.GetPreferences(domain.PlatformFeishu, "ou_1"); err != nil || got != prefs {
+			t.Errorf("GetPreferences = %+v, %v, want %+v", got, err, prefs)
+		}
+		if m, err := repo.GetMapping(domain.PlatformFeishu, "ou_1"); err != nil || m.UserName != "" {
+			t.Errorf("GetMapping = %+v, %v, want a mapping without a name", m, err)
+		}
+		if list, err := repo.ListMappings(); err != nil || len(list) != 0 {
+			t.Errorf("ListMappings = %v, %v, want no named users", list, err)
+		}
+
+		// 设置名字不影响偏好
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := repo.GetPreferences(domain.PlatformFeishu, "ou_1"); got != prefs {
+			t.Errorf("preferences after SetUserName = %+v, want %+v", got, prefs)
+		}
+	})
+}
+
+func TestUserMappingCopyAndDelete(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		repo := open()
+		defer closeRepo(t, repo)
+
+		if err := repo.CopyMapping(domain.PlatformFeishu, "ou_missing", "on_1"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("CopyMapping of an unknown user = %v, want ErrUserNotFound", err)
+		}
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetUserName(domain.PlatformFeishu, "on_2", "李四"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.CopyMapping(domain.PlatformFeishu, "ou_1", "on_1"); err != nil {
+			t.Fatal(err)
+		}
+		// 已有映射的目标保持不变
+		if err := repo.CopyMapping(domain.PlatformFeishu, "ou_1", "on_2"); err != nil {
+			t.Fatal(err)
+		}
+		if name, _ := repo.GetUserName(domain.PlatformFeishu, "on_2"); name != "李四" {
+			t.Errorf("CopyMapping overwrote an existing mapping, name = %q", name)
+		}
+
+		from, _ := repo.GetMapping(domain.PlatformFeishu, "ou_1")
+		to, err := repo.GetMapping(domain.PlatformFeishu, "on_1")
+		if err != nil || to.UserName != "张三" || to.UserID != from.UserID {
+			t.Errorf("copied mapping = %+v, %v, want 张三 with user ID %s", to, err, from.UserID)
+		}
+		found, err := repo.FindByUserName(" 张三 ")
+		if err != nil || len(found) != 2 {
+			t.Errorf("FindByUserName = %v, %v, want both IDs", found, err)
+		}
+		if found, _ := repo.FindByUserName(""); len(found) != 0 {
+			t.Errorf("FindByUserName of a blank name = %v, want nothing", found)
+		}
+		list, err := repo.ListMappings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, m := range list {
+			ids = append(ids, m.PlatformID)
+		}
+		if want := []string{"on_1", "on_2", "ou_1"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("ListMappings = %v, want %v ordered by open ID", ids, want)
+		}
+		if unique := domain.UniqueUsers(list); len(unique) != 2 {
+			t.Errorf("UniqueUsers = %d users, want 2", len(unique))
+		}
+
+		if err := repo.DeleteMapping(domain.PlatformFeishu, "ou_1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteMapping(domain.PlatformFeishu, "ou_1"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("second DeleteMapping = %v, want ErrUserNotFound", err)
+		}
+		if _, err := repo.GetMapping(domain.PlatformFeishu, "ou_1"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("GetMapping after delete = %v, want ErrUserNotFound", err)
+		}
+		if name, _ := repo.GetUserName(domain.PlatformFeishu, "on_1"); name != "张三" {
+			t.Errorf("deleting one ID removed its copy, name = %q", name)
+		}
+	})
+}
+
+// 迁移后改名、改偏好同步到两个 ID，旧名字不再被占用
+func TestUserMappingRenameAfterCopy(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		base := open()
+		defer closeRepo(t, base)
+		repo := NewUniqueNameRepository(base, false)
+
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.CopyMapping(domain.PlatformFeishu, "ou_1", "on_1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetUserName(domain.PlatformFeishu, "on_1", "张小三"); err != nil {
+			t.Fatal(err)
+		}
+		prefs := domain.UserPreferences{Language: "en"}
+		if err := repo.SetPreferences(domain.PlatformFeishu, "ou_1", prefs); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, id := range []string{"ou_1", "on_1"} {
+			m, err := repo.GetMapping(domain.PlatformFeishu, id)
+			if err != nil || m.UserName != "张小三" || m.Preferences != prefs {
+				t.Errorf("%s = %+v, %v, want 张小三 with the new preferences", id, m, err)
+			}
+		}
+		if found, _ := repo.FindByUserName("张三"); len(found) != 0 {
+			t.Errorf("FindByUserName of the old name = %v, want nothing", found)
+		}
+		// 其他用户可以使用旧名字
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_2", "张三"); err != nil {
+			t.Errorf("SetUserName of the freed name = %v", err)
+		}
+		// 其他用户不受影响
+		if name, _ := repo.GetUserName(domain.PlatformFeishu, "ou_2"); name != "张三" {
+			t.Errorf("other user name = %q, want 张三", name)
+		}
+	})
+}
+
+func TestUserMappingPersistence(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		repo := open()
+		if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetPreferences(domain.PlatformFeishu, "ou_1", domain.UserPreferences{Currency: "$"}); err != nil {
+			t.Fatal(err)
+		}
+		before, _ := repo.GetMapping(domain.PlatformFeishu, "ou_1")
+		closeRepo(t, repo)
+
+		repo = open()
+		defer closeRepo(t, repo)
+		after, err := repo.GetMapping(domain.PlatformFeishu, "ou_1")
+		if err != nil || !reflect.DeepEqual(after, before) {
+			t.Errorf("GetMapping after reopen = %+v, %v, want %+v", after, err, before)
+		}
+	})
+}
+
+// 旧版 user_mapping.json 只保存用户名，两种实现都能读取；bbolt 只在首次打开时导入
+func TestUserMappingLegacyJSON(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		legacy := `{"ou_1": "张三", "telegram:42": {"user_name": "李四", "preferences": {"currency": "$"}}}`
+		if err := os.WriteFile(filepath.Join(dir, "user_mapping.json"), []byte(legacy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		repo := open()
+		if name, err := repo.GetUserName(domain.PlatformFeishu, "ou_1"); err != nil || name != "张三" {
+			t.Errorf("GetUserName = %q, %v, want 张三", name, err)
+		}
+		if prefs, _ := repo.GetPreferences(domain.PlatformTelegram, "42"); prefs.Currency != "$" {
+			t.Errorf("GetPreferences = %+v, want currency $", prefs)
+		}
+		if m, _ := repo.GetMapping(domain.PlatformFeishu, "ou_1"); m == nil || m.UserID == "" {
+			t.Errorf("legacy mapping has no user ID: %+v", m)
+		}
+		if err := repo.DeleteMapping(domain.PlatformFeishu, "ou_1"); err != nil {
+			t.Fatal(err)
+		}
+		closeRepo(t, repo)
+
+		// 重新打开后删除仍然有效，JSON 文件不会被再次导入
+		repo = open()
+		defer closeRepo(t, repo)
+		if _, err := repo.GetMapping(domain.PlatformFeishu, "ou_1"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("GetMapping after reopen = %v, want ErrUserNotFound", err)
+		}
+	})
+}
+
+// 旧版 {"openID": "userName"} 文件自动迁移：偏好为空（使用全局配置），下次保存时写成新格式
+func TestUserMappingLegacyMigration(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "user_mapping.json")
+	if err := os.WriteFile(file, []byte(`{"ou_1": "张三", "ou_2": "李四"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewUserMappingRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"ou_1", "ou_2"} {
+		if prefs, err := repo.GetPreferences(domain.PlatformFeishu, id); err != nil || prefs != (domain.UserPreferences{}) {
+			t.Errorf("GetPreferences(%s) = %+v, %v, want defaults", id, prefs, err)
+		}
+	}
+	before, err := repo.GetMapping(domain.PlatformFeishu, "ou_2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.SetPreferences(domain.PlatformFeishu, "ou_1", domain.UserPreferences{Currency: "$"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := parseUserMappings(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 保存后所有记录都是新格式，补上的用户 ID 写入文件
+	want := map[string]*userRecord{
+		"ou_1": {UserName: "张三", Preferences: domain.UserPreferences{Currency: "$"}},
+		"ou_2": {UserID: before.UserID, UserName: "李四"},
+	}
+	if record := records["ou_1"]; record == nil || record.UserID == "" {
+		t.Fatalf("saved ou_1 = %+v, want a user ID", record)
+	}
+	records["ou_1"].UserID = ""
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("saved records = %+v, want %+v", records, want)
+	}
+
+	// 重新打开后用户 ID 保持不变
+	reopened, err := NewUserMappingRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after, err := reopened.GetMapping(domain.PlatformFeishu, "ou_2"); err != nil || after.UserID != before.UserID {
+		t.Errorf("GetMapping after reopen = %+v, %v, want user ID %s", after, err, before.UserID)
+	}
+	if prefs, _ := reopened.GetPreferences(domain.PlatformFeishu, "ou_1"); prefs.Currency != "$" {
+		t.Errorf("GetPreferences after reopen = %+v, want currency $", prefs)
+	}
+}
+
+// 处理器在多个 goroutine 中读写映射，配合 -race 运行
+func TestUserMappingConcurrent(t *testing.T) {
+	forEachUserMappingBackend(t, func(t *testing.T, dir string, open func() domain.UserMappingRepository) {
+		repo := open()
+		defer closeRepo(t, repo)
+
+		const workers = 8
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id := strconv.Itoa(i)
+				for j := 0; j < 20; j++ {
+					if err := repo.SetUserName(domain.PlatformTelegram, id, fmt.Sprintf("用户%d-%d", i, j)); err != nil {
+						t.Error(err)
+						return
+					}
+					if err := repo.SetPreferences(domain.PlatformTelegram, id, domain.UserPreferences{TopN: j + 1}); err != nil {
+						t.Error(err)
+						return
+					}
+					if _, err := repo.GetUserName(domain.PlatformTelegram, id); err != nil {
+						t.Error(err)
+						return
+					}
+					if _, err := repo.ListMappings(); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		list, err := repo.ListMappings()
+		if err != nil || len(list) != workers {
+			t.Fatalf("ListMappings = %d mappings, %v, want %d", len(list), err, workers)
+		}
+		for i := 0; i < workers; i++ {
+			id := strconv.Itoa(i)
+			name, err := repo.GetUserName(domain.PlatformTelegram, id)
+			if want := fmt.Sprintf("用户%d-19", i); err != nil || name != want {
+				t.Errorf("GetUserName(%s) = %q, %v, want %s", id, name, err, want)
+			}
+			if prefs, _ := repo.GetPreferences(domain.PlatformTelegram, id); prefs.TopN != 20 {
+				t.Errorf("GetPreferences(%s) = %+v, want top_n 20", id, prefs)
+			}
+		}
+	})
+}
+
+// 写入中途断电留下半截文件时从上一版本的备份恢复，而不是启动失败
+func TestUserMappingTruncatedFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "user_mapping.json")
+	repo, err := NewUserMappingRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetUserName(domain.PlatformFeishu, "ou_2", "李四"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 备份是最后一次保存前的版本
+	repo, err = NewUserMappingRepository(dir)
+	if err != nil {
+		t.Fatalf("NewUserMappingRepository with a truncated file = %v, want the backup", err)
+	}
+	if name, err := repo.GetUserName(domain.PlatformFeishu, "ou_1"); err != nil || name != "张三" {
+		t.Errorf("GetUserName = %q, %v, want 张三", name, err)
+	}
+	if _, err := repo.GetUserName(domain.PlatformFeishu, "ou_2"); err == nil {
+		t.Error("user saved after the backup found")
+	}
+	if restored, _ := os.ReadFile(file); !json.Valid(restored) {
+		t.Errorf("file not restored from the backup: %s", restored)
+	}
+
+	// 文件和备份都损坏时报错
+	for _, path := range []string{file, file + ".bak"} {
+		if err := os.WriteFile(path, []byte(`{"ou_1": `), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewUserMappingRepository(dir); err == nil {
+		t.Error("NewUserMappingRepository with a corrupt file and backup succeeded")
+	}
+}
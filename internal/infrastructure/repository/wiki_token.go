@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// wikiTokenCacheTTL wiki 节点对应 app_token 的缓存时间，节点移动或重建时由接口报错触发重新解析
+const wikiTokenCacheTTL = 30 * 24 * time.Hour
+
+// wikiTokenKey wiki 节点在缓存中的键
+func wikiTokenKey(nodeToken string) string {
+	return "wiki:" + nodeToken
+}
+
+// resolveWikiAppToken 把 wiki 节点换成多维表格的 app_token，优先使用缓存
+// refresh 为 true 时忽略缓存重新解析；wikiCache 为 nil 时不缓存
+func resolveWikiAppToken(ctx context.Context, feishuService *feishu.FeishuService, wikiCache cache.Cache, nodeToken string, refresh bool) (string, error) {
+	log := logger.FromContext(ctx, logComponent)
+	if wikiCache != nil && !refresh {
+		var appToken string
+		if err := wikiCache.Get(wikiTokenKey(nodeToken), &appToken); err == nil && appToken != "" {
+			log.Info("Using cached app_token for wiki node: node_token=%s -> app_token=%s", nodeToken, appToken)
+			return appToken, nil
+		}
+	}
+
+	log.Info("Converting wiki node_token to bitable app_token: node_token=%s", nodeToken)
+	appToken, err := feishuService.GetBitableAppTokenFromWikiNode(ctx, nodeToken)
+	if err != nil {
+		return "", err
+	}
+	if wikiCache != nil {
+		if err := wikiCache.Set(wikiTokenKey(nodeToken), appToken, wikiTokenCacheTTL); err != nil {
+			log.Warn("Failed to cache wiki node app_token: node_token=%s, err=%v", nodeToken, err)
+		}
+	}
+	return appToken, nil
+}
+
+// token 返回当前使用的 app_token
+func (r *bitableBillRepository) token() string {
+	r.tokenMu.RLock()
+	defer r.tokenMu.RUnlock()
+	return r.appToken
+}
+
+// refreshOnTokenError 接口报告 app_token 无效时，重新解析 wiki 节点并更新缓存，之后的请求使用新的 app_token
+// 本次请求仍返回原错误；非 wiki 链接无法重新解析
+func (r *bitableBillRepository) refreshOnTokenError(ctx context.Context, err error) {
+	if err == nil || r.nodeToken == "" || !errors.Is(err, feishu.ErrAppTokenInvalid) {
+		return
+	}
+
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	stale := r.token()
+	appToken, rerr := resolveWikiAppToken(ctx, r.feishuService, r.wikiCache, r.nodeToken, true)
+	if rerr != nil {
+		r.logFor(ctx).Error("Failed to re-resolve wiki node after app_token error: node_token=%s, err=%v", r.nodeToken, rerr)
+		return
+	}
+
+	r.tokenMu.Lock()
+	r.appToken = appToken
+	r.tokenMu.Unlock()
+	r.logFor(ctx).Warn("Re-resolved wiki node after app_token error: node_token=%s, app_token=%s -> %s", r.nodeToken, stale, appToken)
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// adminForgetRecordDays forget 带 --records 时删除该用户最近多少天的账单
+const adminForgetRecordDays = 30
+
+// adminIDSuffixLength 列出用户时显示的 open_id 尾部长度，足以区分用户又不暴露完整 ID
+const adminIDSuffixLength = 6
+
+// isAdmin 判断用户是否在配置的管理员列表中；飞书用户的 open_id 和 union_id 都可以配置
+func (h *FeishuHandlerAITools) isAdmin(ctx context.Context, openID string) bool {
+	sender := senderIDsFromContext(ctx)
+	for _, id := range h.config.AdminOpenIDs {
+		// 配置按小写读取，这里忽略大小写比较
+		if strings.EqualFold(id, openID) || strings.EqualFold(id, sender.OpenID) || strings.EqualFold(id, sender.UnionID) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminCommand 处理 /admin 命令，仅限 ADMIN_OPEN_IDS 中的用户使用
+func (h *FeishuHandlerAITools) adminCommand(ctx context.Context, openID, userName, arg string) string {
+	if !h.isAdmin(ctx, openID) {
+		h.logFor(ctx).Warn("Admin command refused: open_id=%s, arg=%s", openID, arg)
+		return h.text(ctx, replyAdminOnly)
+	}
+
+	sub, rest, _ := strings.Cut(arg, " ")
+	rest = strings.TrimSpace(rest)
+	switch strings.ToLower(sub) {
+	case "users":
+		return h.adminListUsers(ctx)
+	case "rename":
+		target, name, _ := strings.Cut(rest, " ")
+		if target == "" || strings.TrimSpace(name) == "" {
+			return h.text(ctx, replyAdminRenameUsage, h.config.CommandPrefix)
+		}
+		return h.adminRenameUser(ctx, target, name)
+	case "forget":
+		fields := strings.Fields(rest)
+		if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != "--records") {
+			return h.text(ctx, replyAdminForgetUsage, h.config.CommandPrefix)
+		}
+		return h.adminForgetUser(ctx, fields[0], len(fields) == 2)
+	default:
+		return h.text(ctx, replyAdminUsage, h.config.CommandPrefix, adminForgetRecordDays)
+	}
+}
+
+// adminListUsers 列出用户的 open_id 尾部和名字
+func (h *FeishuHandlerAITools) adminListUsers(ctx context.Context) string {
+	users, err := h.billUseCase.ListUsers(ctx)
+	if err != nil {
+		h.logFor(ctx).Error("List users failed: %v", err)
+		return h.text(ctx, replyAdminListFailed, err)
+	}
+	if len(users) == 0 {
+		return h.text(ctx, replyAdminNoUsers)
+	}
+
+	var b strings.Builder
+	b.WriteString(h.text(ctx, replyAdminUsers, len(users)))
+	for _, user := range users {
+		fmt.Fprintf(&b, "\n…%s %s", idSuffix(user.PlatformID), user.UserName)
+	}
+	return b.String()
+}
+
+// adminRenameUser 强制修改用户的名字
+func (h *FeishuHandlerAITools) adminRenameUser(ctx context.Context, target, name string) string {
+	platformID, err := h.resolveUser(ctx, target)
+	if err != nil {
+		return err.Error()
+	}
+	saved, err := h.billUseCase.RenameUser(ctx, platformID, name)
+	var taken *domain.UserNameTakenError
+	if errors.As(err, &taken) {
+		return h.text(ctx, replyAdminNameTaken, taken.Name, taken.Suggestion)
+	}
+	if err != nil {
+		h.logFor(ctx).Error("Rename user failed: platform_id=%s, err=%v", platformID, err)
+		return h.text(ctx, replyAdminRenameFailed, err)
+	}
+	return h.text(ctx, replyAdminRenamed, idSuffix(platformID), saved)
+}
+
+// adminForgetUser 删除用户的名字和设置，withRecords 为 true 时同时删除其最近的账单
+func (h *FeishuHandlerAITools) adminForgetUser(ctx context.Context, target string, withRecords bool) string {
+	platformID, err := h.resolveUser(ctx, target)
+	if err != nil {
+		return err.Error()
+	}
+	var since *time.Time
+	if withRecords {
+		t := time.Now().AddDate(0, 0, -adminForgetRecordDays)
+		since = &t
+	}
+
+	result, err := h.billUseCase.ForgetUser(ctx, platformID, since)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return h.text(ctx, replyAdminUserNotFound, target)
+	}
+	if err != nil {
+		h.logFor(ctx).Error("Forget user failed: platform_id=%s, err=%v", platformID, err)
+		return h.text(ctx, replyAdminForgetFailed, err)
+	}
+	reply := h.text(ctx, replyAdminForgot, idSuffix(platformID))
+	if result.UserName != "" {
+		reply += h.text(ctx, replyAdminForgotName, result.UserName)
+	}
+	if withRecords {
+		reply += h.text(ctx, replyAdminForgotRecords, adminForgetRecordDays, result.DeletedRecords)
+	}
+	return reply
+}
+
+// resolveUser 按完整 open_id 或唯一的尾部找到用户，返回的错误可以直接回复给管理员
+func (h *FeishuHandlerAITools) resolveUser(ctx context.Context, target string) (string, error) {
+	users, err := h.billUseCase.ListUsers(ctx)
+	if err != nil {
+		h.logFor(ctx).Error("List users failed: %v", err)
+		return "", errors.New(h.text(ctx, replyAdminListFailed, err))
+	}
+	platformID, err := matchUser(users, target)
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		// 没有名字的用户不在列表中，完整 ID 仍然可以直接使用
+		if len(strings.TrimPrefix(target, "…")) > adminIDSuffixLength {
+			return strings.TrimPrefix(target, "…"), nil
+		}
+		return "", errors.New(h.text(ctx, replyAdminUserNotFound, target))
+	case err != nil:
+		return "", errors.New(h.text(ctx, replyAdminAmbiguousUser, target))
+	}
+	return platformID, nil
+}
+
+// errAmbiguousUser open_id 尾部匹配到多位用户
+var errAmbiguousUser = errors.New("ambiguous user")
+
+// matchUser 在 users 中按完整 ID 或唯一的尾部查找用户；target 可以带 users 列表中的 “…” 前缀
+func matchUser(users []*domain.UserMapping, target string) (string, error) {
+	target = strings.TrimPrefix(strings.TrimSpace(target), "…")
+	var matched []string
+	for _, user := range users {
+		if user.PlatformID == target {
+			return target, nil
+		}
+		if strings.HasSuffix(user.PlatformID, target) {
+			matched = append(matched, user.PlatformID)
+		}
+	}
+	switch {
+	case target == "" || len(matched) == 0:
+		return "", fmt.Errorf("%w: %s", domain.ErrUserNotFound, target)
+	case len(matched) > 1:
+		return "", fmt.Errorf("%w: %s", errAmbiguousUser, target)
+	}
+	return matched[0], nil
+}
+
+// idSuffix 返回 ID 的尾部
+func idSuffix(id string) string {
+	if len(id) <= adminIDSuffixLength {
+		return id
+	}
+	return id[len(id)-adminIDSuffixLength:]
+}
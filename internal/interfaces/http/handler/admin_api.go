@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
+)
+
+// adminUserRequest PATCH /admin/users/{openID} 的请求体
+type adminUserRequest struct {
+	Name string `json:"name"`
+}
+
+// adminUser GET /admin/users 返回的用户
+type adminUser struct {
+	OpenID string `json:"open_id"`
+	UserID string `json:"user_id,omitempty"`
+	Name   string `json:"name"`
+}
+
+// adminUsers 处理 /api/v1/admin/users
+func (h *BillAPIHandler) adminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	users, err := h.billUseCase.ListUsers(r.Context())
+	if err != nil {
+		h.writeError(w, "list users", err)
+		return
+	}
+	result := make([]adminUser, 0, len(users))
+	for _, user := range users {
+		result = append(result, adminUser{OpenID: user.PlatformID, UserID: user.UserID, Name: user.UserName})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// adminUser 处理 /api/v1/admin/users/{openID}，openID 为完整 ID（其他平台如 telegram:123 需 URL 编码）
+func (h *BillAPIHandler) adminUser(w http.ResponseWriter, r *http.Request) {
+	openID := strings.TrimPrefix(r.URL.Path, "/api/v1/admin/users/")
+	if openID == "" || strings.Contains(openID, "/") {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		h.renameUser(w, r, openID)
+	case http.MethodDelete:
+		h.forgetUser(w, r, openID)
+	default:
+		w.Header().Set("Allow", "PATCH, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+	}
+}
+
+func (h *BillAPIHandler) renameUser(w http.ResponseWriter, r *http.Request, openID string) {
+	var req adminUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid JSON body: %v", err)})
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "name is required"})
+		return
+	}
+
+	name, err := h.billUseCase.RenameUser(r.Context(), openID, req.Name)
+	if err != nil {
+		h.writeError(w, "rename user", err)
+		return
+	}
+	h.logger.Info("API renamed user: open_id=%s, name=%s", openID, name)
+	writeJSON(w, http.StatusOK, adminUser{OpenID: openID, Name: name})
+}
+
+// forgetUser 删除用户的名字和设置；?records=true 时同时删除其最近 adminForgetRecordDays 天的账单
+func (h *BillAPIHandler) forgetUser(w http.ResponseWriter, r *http.Request, openID string) {
+	var since *time.Time
+	if r.URL.Query().Get("records") == "true" {
+		t := time.Now().AddDate(0, 0, -adminForgetRecordDays)
+		since = &t
+	}
+
+	result, err := h.billUseCase.ForgetUser(r.Context(), openID, since)
+	if err != nil {
+		h.writeError(w, "forget user", err)
+		return
+	}
+	h.logger.Info("API forgot user: open_id=%s, deleted_records=%d", openID, result.DeletedRecords)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// userData 处理 /api/v1/users/{openID}/export，以 zip 附件返回用户的名字、个人设置和全部账单
+func (h *BillAPIHandler) userData(w http.ResponseWriter, r *http.Request) {
+	openID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/export")
+	if !ok || openID == "" || strings.Contains(openID, "/") {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	// 第一次写入时才发送响应头，用户不存在或第一页查询失败时仍可以返回 JSON 错误
+	zw := &attachmentWriter{w: w, contentType: "application/zip", fileName: ai.UserDataFileName(time.Now())}
+	count, err := h.billUseCase.ExportUserData(r.Context(), openID, zw)
+	if err != nil {
+		if !zw.started {
+			h.writeError(w, "export user data", err)
+			return
+		}
+		h.logger.Error("API export user data failed after %d bills: %v", count, err)
+		panic(http.ErrAbortHandler)
+	}
+	h.logger.Info("API exported user data: open_id=%s, bills=%d", openID, count)
+}
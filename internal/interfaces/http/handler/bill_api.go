@@ -0,0 +1,490 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// billAPIDefaultLimit GET /bills 未指定 limit 时返回的条数
+const billAPIDefaultLimit = 100
+
+// BillAPIHandler 账单的 REST 接口，供脚本、快捷指令等飞书以外的客户端读写账本
+type BillAPIHandler struct {
+	token       string
+	billUseCase domain.BillUseCase
+	location    *time.Location // 解析和默认日期使用的时区
+	logger      logger.Logger
+}
+
+// NewBillAPIHandler creates the REST API handler; token is the required Bearer token and dates
+// without an offset are read in location
+func NewBillAPIHandler(token string, billUseCase domain.BillUseCase, location *time.Location) *BillAPIHandler {
+	return &BillAPIHandler{
+		token:       token,
+		billUseCase: billUseCase,
+		location:    location,
+		logger:      logger.GetLogger(httpLogComponent),
+	}
+}
+
+// Register 在 mux 上注册 /api/v1/ 下的路由
+func (h *BillAPIHandler) Register(mux *http.ServeMux) {
+	mux.Handle("/api/v1/bills", h.authenticate(http.HandlerFunc(h.bills)))
+	mux.Handle("/api/v1/bills/", h.authenticate(http.HandlerFunc(h.bill)))
+	mux.Handle("/api/v1/summary", h.authenticate(http.HandlerFunc(h.summary)))
+	mux.Handle("/api/v1/export", h.authenticate(http.HandlerFunc(h.export)))
+	mux.Handle("/api/v1/admin/users", h.authenticate(http.HandlerFunc(h.adminUsers)))
+	mux.Handle("/api/v1/admin/users/", h.authenticate(http.HandlerFunc(h.adminUser)))
+	mux.Handle("/api/v1/users/", h.authenticate(http.HandlerFunc(h.userData)))
+}
+
+// RegisterMetrics 在 mux 上注册 /metrics，与 REST 接口使用同一个令牌
+func (h *BillAPIHandler) RegisterMetrics(mux *http.ServeMux, app func() interface{}) {
+	mux.Handle("/metrics", h.authenticate(Metrics(app)))
+}
+
+// billRequest POST /bills 和 PATCH /bills/{recordID} 的请求体，PATCH 时只更新出现的字段
+type billRequest struct {
+	User            *string  `json:"user"`
+	Description     *string  `json:"description"`
+	Amount          *float64 `json:"amount"`
+	Type            *string  `json:"type"` // expense 或 income
+	Category        *string  `json:"category"`
+	Date            *string  `json:"date"` // 2006-01-02 或 2006-01-02 15:04:05
+	OriginalMessage *string  `json:"original_message"`
+	Account         *string  `json:"account"` // 支付账户，如 微信、信用卡
+	Tags            []string `json:"tags"`    // 标签，PATCH 时整体替换
+	Reimbursable    *bool    `json:"reimbursable"`
+}
+
+// apiError 错误响应
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// authenticate 校验 Authorization: Bearer <token>
+func (h *BillAPIHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "invalid or missing bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bills 处理 /api/v1/bills
+func (h *BillAPIHandler) bills(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.createBill(w, r)
+	case http.MethodGet:
+		h.listBills(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+	}
+}
+
+// bill 处理 /api/v1/bills/{recordID}
+func (h *BillAPIHandler) bill(w http.ResponseWriter, r *http.Request) {
+	recordID := strings.TrimPrefix(r.URL.Path, "/api/v1/bills/")
+	if recordID == "" || strings.Contains(recordID, "/") {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		h.updateBill(w, r, recordID)
+	case http.MethodDelete:
+		h.deleteBill(w, r, recordID)
+	default:
+		w.Header().Set("Allow", "PATCH, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+	}
+}
+
+func (h *BillAPIHandler) createBill(w http.ResponseWriter, r *http.Request) {
+	var req billRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid JSON body: %v", err)})
+		return
+	}
+
+	if req.User == nil || strings.TrimSpace(*req.User) == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "user is required"})
+		return
+	}
+	if req.Description == nil || strings.TrimSpace(*req.Description) == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "description is required"})
+		return
+	}
+	if req.Amount == nil || *req.Amount <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "amount must be a positive number"})
+		return
+	}
+
+	billType := domain.BillTypeExpense
+	if req.Type != nil {
+		t, err := parseBillType(*req.Type)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		billType = t
+	}
+
+	var date *time.Time
+	if req.Date != nil {
+		d, err := parseAPIDate(*req.Date, h.location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		date = &d
+	}
+
+	input := domain.NewBillInput{
+		Description: strings.TrimSpace(*req.Description),
+		Amount:      *req.Amount,
+		Type:        billType,
+		Date:        date,
+	}
+	if req.Category != nil {
+		input.Category = *req.Category
+	}
+	if req.OriginalMessage != nil {
+		input.OriginalMsg = *req.OriginalMessage
+	}
+	if req.Account != nil {
+		input.Account = strings.TrimSpace(*req.Account)
+	}
+	input.Tags = req.Tags
+	if req.Reimbursable != nil {
+		input.Reimbursable = *req.Reimbursable
+	}
+
+	bill, err := h.billUseCase.CreateBill(r.Context(), strings.TrimSpace(*req.User), "", input)
+	if err != nil {
+		h.writeError(w, "create bill", err)
+		return
+	}
+	h.logger.Info("API created bill: record_id=%s, user=%s", bill.RecordID, bill.UserName)
+	writeJSON(w, http.StatusCreated, bill)
+}
+
+func (h *BillAPIHandler) listBills(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	user := query.Get("user")
+	if user == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "user is required"})
+		return
+	}
+
+	start, end, err := parseRangeQuery(query, h.location)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	var category *string
+	if v := query.Get("category"); v != "" {
+		category = &v
+	}
+
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "offset must be a non-negative integer"})
+		return
+	}
+	limit, err := queryInt(query.Get("limit"), billAPIDefaultLimit)
+	if err != nil || limit <= 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "limit must be a positive integer"})
+		return
+	}
+
+	bills, total, err := h.billUseCase.ListUserBills(r.Context(), user, start, end, nil, category, offset, limit)
+	if err != nil {
+		h.writeError(w, "list bills", err)
+		return
+	}
+	if bills == nil {
+		bills = []*domain.Bill{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"bills": bills, "total": total})
+}
+
+func (h *BillAPIHandler) updateBill(w http.ResponseWriter, r *http.Request, recordID string) {
+	var req billRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid JSON body: %v", err)})
+		return
+	}
+
+	// 与 update_transaction 工具使用相同的更新字段
+	updates := make(map[string]interface{})
+	if req.Description != nil {
+		if strings.TrimSpace(*req.Description) == "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "description must not be empty"})
+			return
+		}
+		updates["description"] = strings.TrimSpace(*req.Description)
+	}
+	if req.Amount != nil {
+		if *req.Amount <= 0 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "amount must be a positive number"})
+			return
+		}
+		updates["amount"] = *req.Amount
+	}
+	if req.Type != nil {
+		billType, err := parseBillType(*req.Type)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		updates["type"] = billType
+	}
+	if req.Category != nil && *req.Category != "" {
+		updates["category"] = *req.Category
+	}
+	if req.Date != nil {
+		date, err := parseAPIDate(*req.Date, h.location)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		updates["date"] = &date
+	}
+	if req.OriginalMessage != nil && *req.OriginalMessage != "" {
+		updates["original_message"] = *req.OriginalMessage
+	}
+	if req.Account != nil && strings.TrimSpace(*req.Account) != "" {
+		updates["account"] = strings.TrimSpace(*req.Account)
+	}
+	if tags := domain.NormalizeTags(req.Tags); len(tags) > 0 {
+		updates["tags"] = tags
+	}
+	if req.Reimbursable != nil {
+		updates["reimbursable"] = *req.Reimbursable
+	}
+	if len(updates) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "no fields to update"})
+		return
+	}
+
+	bill, err := h.billUseCase.UpdateBill(r.Context(), recordID, updates)
+	if err != nil {
+		h.writeError(w, "update bill", err)
+		return
+	}
+	h.logger.Info("API updated bill: record_id=%s", recordID)
+	writeJSON(w, http.StatusOK, bill)
+}
+
+func (h *BillAPIHandler) deleteBill(w http.ResponseWriter, r *http.Request, recordID string) {
+	if err := h.billUseCase.DeleteBill(r.Context(), recordID); err != nil {
+		h.writeError(w, "delete bill", err)
+		return
+	}
+	h.logger.Info("API deleted bill: record_id=%s", recordID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *BillAPIHandler) summary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	user := query.Get("user")
+	if user == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "user is required"})
+		return
+	}
+
+	now := time.Now().In(h.location)
+	year, err := queryInt(query.Get("year"), now.Year())
+	if err != nil || year < 1970 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "year must be a valid year"})
+		return
+	}
+	month, err := queryInt(query.Get("month"), int(now.Month()))
+	if err != nil || month < 1 || month > 12 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "month must be between 1 and 12"})
+		return
+	}
+
+	summary, err := h.billUseCase.GetMonthlySummary(r.Context(), user, year, month)
+	if err != nil {
+		h.writeError(w, "get monthly summary", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// export 以 CSV 附件返回时间范围内的账单，边查询边写出
+// 未指定 start 时从今年 1 月 1 日开始，未指定 end 时到当前时间为止
+func (h *BillAPIHandler) export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	user := query.Get("user")
+	if user == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "user is required"})
+		return
+	}
+	start, end, err := parseRangeQuery(query, h.location)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	now := time.Now().In(h.location)
+	if end == nil {
+		end = &now
+	}
+	if start == nil {
+		yearStart := time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, h.location)
+		start = &yearStart
+	}
+
+	// 第一次写入时才发送响应头，第一页查询失败时仍可以返回 JSON 错误
+	cw := &attachmentWriter{w: w, contentType: "text/csv; charset=utf-8", fileName: fmt.Sprintf("transactions_%s_%s.csv", start.Format("2006-01-02"), end.Format("2006-01-02"))}
+	count, err := h.billUseCase.ExportTransactions(r.Context(), user, *start, *end, cw)
+	if err != nil {
+		if !cw.started {
+			h.writeError(w, "export bills", err)
+			return
+		}
+		// 已经开始传输，只能中断响应
+		h.logger.Error("API export bills failed after %d rows: %v", count, err)
+		panic(http.ErrAbortHandler)
+	}
+	if !cw.started {
+		cw.writeHeader()
+	}
+	h.logger.Info("API exported bills: user=%s, rows=%d", user, count)
+}
+
+// attachmentWriter 在第一次写入时设置文件下载的响应头
+type attachmentWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	fileName    string
+	started     bool
+}
+
+func (c *attachmentWriter) writeHeader() {
+	c.started = true
+	c.w.Header().Set("Content-Type", c.contentType)
+	c.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", c.fileName))
+	c.w.WriteHeader(http.StatusOK)
+}
+
+func (c *attachmentWriter) Write(p []byte) (int, error) {
+	if !c.started {
+		c.writeHeader()
+	}
+	return c.w.Write(p)
+}
+
+// writeError 账单或用户不存在时返回 404，其余错误返回 500
+func (h *BillAPIHandler) writeError(w http.ResponseWriter, op string, err error) {
+	if errors.Is(err, domain.ErrBillNotFound) || errors.Is(err, domain.ErrUserNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, domain.ErrUserNameTaken) {
+		writeJSON(w, http.StatusConflict, apiError{Error: err.Error()})
+		return
+	}
+	var invalidErr *domain.BillValidationError
+	if errors.As(err, &invalidErr) {
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: err.Error()})
+		return
+	}
+	h.logger.Error("API %s failed: %v", op, err)
+	writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+}
+
+// writeJSON 以 JSON 格式写出响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// parseBillType 解析 expense/income，也接受中文和 domain 中的取值
+func parseBillType(value string) (domain.BillType, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "expense", "支出":
+		return domain.BillTypeExpense, nil
+	case "income", "收入":
+		return domain.BillTypeIncome, nil
+	}
+	return "", fmt.Errorf("type must be expense or income, got %q", value)
+}
+
+// parseAPIDate 解析 loc 时区的日期或日期时间
+func parseAPIDate(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or YYYY-MM-DD HH:MM:SS", value)
+}
+
+// parseRangeQuery 解析 start/end 查询参数，未提供的一端返回 nil
+// end 只有日期时包含当天全天
+func parseRangeQuery(query url.Values, loc *time.Location) (*time.Time, *time.Time, error) {
+	var start, end *time.Time
+	if v := query.Get("start"); v != "" {
+		d, err := parseAPIDate(v, loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("start: %v", err)
+		}
+		start = &d
+	}
+	if v := query.Get("end"); v != "" {
+		d, err := parseAPIDate(v, loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("end: %v", err)
+		}
+		if len(v) == len("2006-01-02") {
+			d = d.Add(24*time.Hour - time.Nanosecond)
+		}
+		end = &d
+	}
+	if start != nil && end != nil && end.Before(*start) {
+		return nil, nil, fmt.Errorf("end is before start")
+	}
+	return start, end, nil
+}
+
+// queryInt 解析整数查询参数，为空时返回默认值
+func queryInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
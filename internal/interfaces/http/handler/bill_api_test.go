@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// apiBillUseCase 内存中的账单，只实现 REST 接口用到的方法
+type apiBillUseCase struct {
+	domain.BillUseCase
+
+	bills    map[string]*domain.Bill
+	listed   [2]*time.Time // 最近一次 ListUserBills 的时间范围
+	category *string
+}
+
+func (u *apiBillUseCase) CreateBill(ctx context.Context, userName, userID string, input domain.NewBillInput) (*domain.Bill, error) {
+	bill := &domain.Bill{
+		RecordID:    fmt.Sprintf("rec%d", len(u.bills)+1),
+		Description: input.Description,
+		Amount:      input.Amount,
+		Type:        input.Type,
+		Category:    input.Category,
+		UserName:    userName,
+		Date:        time.Now(),
+	}
+	if input.Date != nil {
+		bill.Date = *input.Date
+	}
+	u.bills[bill.RecordID] = bill
+	return bill, nil
+}
+
+func (u *apiBillUseCase) ListUserBills(ctx context.Context, userName string, start, end *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
+	u.listed = [2]*time.Time{start, end}
+	u.category = category
+	var bills []*domain.Bill
+	for _, bill := range u.bills {
+		if bill.UserName == userName {
+			bills = append(bills, bill)
+		}
+	}
+	return bills, len(bills), nil
+}
+
+func (u *apiBillUseCase) UpdateBill(ctx context.Context, id string, updates map[string]interface{}) (*domain.Bill, error) {
+	bill, ok := u.bills[id]
+	if !ok {
+		return nil, fmt.Errorf("update %s: %w", id, domain.ErrBillNotFound)
+	}
+	if amount, ok := updates["amount"].(float64); ok {
+		bill.Amount = amount
+	}
+	if description, ok := updates["description"].(string); ok {
+		bill.Description = description
+	}
+	return bill, nil
+}
+
+func (u *apiBillUseCase) DeleteBill(ctx context.Context, id string) error {
+	if _, ok := u.bills[id]; !ok {
+		return fmt.Errorf("delete %s: %w", id, domain.ErrBillNotFound)
+	}
+	delete(u.bills, id)
+	return nil
+}
+
+func (u *apiBillUseCase) GetMonthlySummary(ctx context.Context, userName string, year, month int) (*domain.MonthlySummary, error) {
+	return &domain.MonthlySummary{Year: year, Month: month, TotalExpense: 30, NetAmount: -30, Count: 1}, nil
+}
+
+const testAPIToken = "secret-token"
+
+// newBillAPITestServer 启动注册了 REST 接口的测试服务器
+func newBillAPITestServer(t *testing.T) (*httptest.Server, *apiBillUseCase) {
+	t.Helper()
+	bills := &apiBillUseCase{bills: make(map[string]*domain.Bill)}
+	mux := http.NewServeMux()
+	NewBillAPIHandler(testAPIToken, bills, time.UTC).Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, bills
+}
+
+// apiRequest 发送带令牌的请求，返回状态码并将响应体解析到 out
+func apiRequest(t *testing.T, srv *httptest.Server, method, path, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestBillAPIAuthentication(t *testing.T) {
+	srv, _ := newBillAPITestServer(t)
+	for _, header := range []string{"", "Bearer wrong", testAPIToken} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/bills?user=张三", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", header, resp.StatusCode)
+		}
+	}
+}
+
+func TestBillAPICRUD(t *testing.T) {
+	srv, bills := newBillAPITestServer(t)
+
+	var created domain.Bill
+	status := apiRequest(t, srv, http.MethodPost, "/api/v1/bills", `{"user":"张三","description":"午饭","amount":30,"category":"餐饮","date":"2025-03-01 12:30:00"}`, &created)
+	if status != http.StatusCreated || created.RecordID != "rec1" || created.UserName != "张三" || created.Type != domain.BillTypeExpense {
+		t.Fatalf("POST = %d, %+v", status, created)
+	}
+	if want := time.Date(2025, time.March, 1, 12, 30, 0, 0, time.UTC); !created.Date.Equal(want) {
+		t.Errorf("date = %s, want %s", created.Date, want)
+	}
+
+	var list struct {
+		Bills []*domain.Bill `json:"bills"`
+		Total int            `json:"total"`
+	}
+	if status := apiRequest(t, srv, http.MethodGet, "/api/v1/bills?user=张三&start=2025-03-01&end=2025-03-31&category=餐饮", "", &list); status != http.StatusOK || list.Total != 1 {
+		t.Fatalf("GET = %d, %+v", status, list)
+	}
+	// 只有日期的 end 包含当天全天
+	if end := bills.listed[1]; end == nil || !end.Equal(time.Date(2025, time.March, 31, 23, 59, 59, 999999999, time.UTC)) {
+		t.Errorf("end = %v, want the end of 2025-03-31", end)
+	}
+	if bills.category == nil || *bills.category != "餐饮" {
+		t.Errorf("category filter = %v, want 餐饮", bills.category)
+	}
+
+	var updated domain.Bill
+	if status := apiRequest(t, srv, http.MethodPatch, "/api/v1/bills/rec1", `{"amount":35}`, &updated); status != http.StatusOK || updated.Amount != 35 {
+		t.Errorf("PATCH = %d, %+v", status, updated)
+	}
+	if status := apiRequest(t, srv, http.MethodDelete, "/api/v1/bills/rec1", "", nil); status != http.StatusNoContent || len(bills.bills) != 0 {
+		t.Errorf("DELETE = %d, %d bills left", status, len(bills.bills))
+	}
+
+	var summary domain.MonthlySummary
+	if status := apiRequest(t, srv, http.MethodGet, "/api/v1/summary?user=张三&year=2025&month=3", "", &summary); status != http.StatusOK || summary.Year != 2025 || summary.Month != 3 {
+		t.Errorf("summary = %d, %+v", status, summary)
+	}
+}
+
+func TestBillAPIErrors(t *testing.T) {
+	srv, _ := newBillAPITestServer(t)
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/v1/bills", `{"description":"午饭","amount":30}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/bills", `{"user":"张三","description":"午饭","amount":-1}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/bills", `{"user":"张三","description":"午饭","amount":30,"type":"loan"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/bills", `{"user":"张三","description":"午饭","amount":30,"date":"3月1日"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/bills", `not json`, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/bills", "", http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/bills?user=张三&limit=0", "", http.StatusBadRequest},
+		{http.MethodPatch, "/api/v1/bills/rec404", `{"amount":35}`, http.StatusNotFound},
+		{http.MethodPatch, "/api/v1/bills/rec404", `{}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/bills/rec404", "", http.StatusNotFound},
+		{http.MethodPut, "/api/v1/bills/rec1", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/summary?user=张三&month=13", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var body apiError
+		if status := apiRequest(t, srv, tt.method, tt.path, tt.body, &body); status != tt.want || body.Error == "" {
+			t.Errorf("%s %s %s = %d %q, want %d with an error message", tt.method, tt.path, tt.body, status, body.Error, tt.want)
+		}
+	}
+}
+
+// 主端口的 /metrics 需要 REST 接口的令牌
+func TestBillAPIMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	NewBillAPIHandler(testAPIToken, &apiBillUseCase{}, time.UTC).RegisterMetrics(mux, func() interface{} {
+		return map[string]int{"outbox_pending": 2}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("/metrics without token: status %d, want 401", resp.StatusCode)
+	}
+
+	var metrics map[string]int
+	if status := apiRequest(t, srv, http.MethodGet, "/metrics", "", &metrics); status != http.StatusOK || metrics["outbox_pending"] != 2 {
+		t.Errorf("/metrics = %d %v, want 200 with outbox_pending", status, metrics)
+	}
+}
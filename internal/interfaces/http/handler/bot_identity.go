@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// botInfoRefreshInterval 机器人信息的刷新间隔，管理员在后台改名后最迟在该时间后生效
+	botInfoRefreshInterval = time.Hour
+	// botInfoRetryInterval 获取机器人信息失败后的重试间隔
+	botInfoRetryInterval = time.Minute
+)
+
+// botIdentity 用于识别消息中@的是否为机器人自己
+type botIdentity struct {
+	name   string
+	openID string
+}
+
+// matches 判断一个@对象是否为机器人：有 open_id 时按 open_id 匹配，避免与同名成员混淆
+func (b botIdentity) matches(name, openID string) bool {
+	if b.openID != "" && openID != "" {
+		return openID == b.openID
+	}
+	return name != "" && name == b.name
+}
+
+// botIdentity 返回机器人的名称和 open_id，按间隔从飞书接口刷新
+// 接口调用失败时沿用上次的结果，从未成功时使用配置的 BotName
+func (h *FeishuHandlerAITools) botIdentity(ctx context.Context) botIdentity {
+	h.botMu.Lock()
+	defer h.botMu.Unlock()
+
+	if time.Now().Before(h.botRefreshAt) {
+		return h.bot
+	}
+
+	info, err := h.feishuService.GetBotInfo(ctx)
+	if err != nil || info.Name == "" && info.OpenID == "" {
+		h.logFor(ctx).Warn("Failed to fetch bot info, using %q for mention detection: %v", h.bot.name, err)
+		h.botRefreshAt = time.Now().Add(botInfoRetryInterval)
+		return h.bot
+	}
+
+	if info.Name != h.bot.name || info.OpenID != h.bot.openID {
+		h.logFor(ctx).Info("Bot identity updated: name=%s, open_id=%s", info.Name, info.OpenID)
+	}
+	h.bot = botIdentity{name: info.Name, openID: info.OpenID}
+	h.botRefreshAt = time.Now().Add(botInfoRefreshInterval)
+	return h.bot
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+)
+
+// 卡片按钮的操作类型
+const (
+	cardActionUndo        = "undo"
+	cardActionSetCategory = "set_category"
+)
+
+// plainText 构造卡片中的纯文本对象
+func plainText(content string) map[string]interface{} {
+	return map[string]interface{}{"tag": "plain_text", "content": content}
+}
+
+// markdownDiv 构造卡片中的 lark_md 文本块
+func markdownDiv(content string) map[string]interface{} {
+	return map[string]interface{}{
+		"tag":  "div",
+		"text": map[string]interface{}{"tag": "lark_md", "content": content},
+	}
+}
+
+// billSummary 账单的简短描述，用于卡片中标识每一笔记录
+func billSummary(bill *domain.Bill) string {
+	sign := "-"
+	if bill.Type == domain.BillTypeIncome {
+		sign = "+"
+	}
+	return fmt.Sprintf("%s %s%s [%s]", bill.Description, sign, domain.FormatAmount("", bill.Amount), bill.Category)
+}
+
+// buildRecordCard 记账结果卡片：展示回复内容，并为每笔新记录提供“撤销”和“改分类”操作
+// 按钮的 value 中带上记录者的 open_id，回调时只允许记录者本人操作
+func buildRecordCard(response string, bills []*domain.Bill, openID string) feishu.CardContent {
+	elements := []map[string]interface{}{markdownDiv(response)}
+
+	options := make([]map[string]interface{}, 0, len(domain.DefaultCategories))
+	for _, c := range domain.DefaultCategories {
+		options = append(options, map[string]interface{}{"text": plainText(c), "value": c})
+	}
+
+	for _, bill := range bills {
+		if bill.RecordID == "" {
+			continue
+		}
+		summary := billSummary(bill)
+		if len(bills) > 1 {
+			elements = append(elements, markdownDiv(summary))
+		}
+		value := func(action string) map[string]interface{} {
+			return map[string]interface{}{
+				"action":    action,
+				"record_id": bill.RecordID,
+				"open_id":   openID,
+				"summary":   summary,
+			}
+		}
+		elements = append(elements, map[string]interface{}{
+			"tag": "action",
+			"actions": []map[string]interface{}{
+				{
+					"tag":   "button",
+					"text":  plainText("撤销"),
+					"type":  "danger",
+					"value": value(cardActionUndo),
+				},
+				{
+					"tag":         "select_static",
+					"placeholder": plainText("改分类"),
+					"options":     options,
+					"value":       value(cardActionSetCategory),
+				},
+			},
+		})
+	}
+
+	return feishu.CardContent{
+		// update_multi 使卡片更新对所有人可见
+		Config:   map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		Header:   map[string]interface{}{"title": plainText("✅ 记账成功"), "template": "green"},
+		Elements: elements,
+	}
+}
+
+// buildOutcomeCard 按钮操作完成后替换原卡片，展示操作结果
+func buildOutcomeCard(title, template, content string) feishu.CardContent {
+	return feishu.CardContent{
+		Config:   map[string]interface{}{"wide_screen_mode": true, "update_multi": true},
+		Header:   map[string]interface{}{"title": plainText(title), "template": template},
+		Elements: []map[string]interface{}{markdownDiv(content)},
+	}
+}
+
+// cardAction 卡片回调中的操作信息，兼容旧版卡片回调和 card.action.trigger 事件
+type cardAction struct {
+	OperatorOpenID  string
+	OperatorUnionID string
+	MessageID       string
+	Value           map[string]interface{}
+	Option          string
+}
+
+// parseCardAction 从回调请求体中解析卡片操作
+func parseCardAction(payload map[string]interface{}) *cardAction {
+	// 新版回调：{"schema": "2.0", "header": {"event_type": "card.action.trigger"}, "event": {...}}
+	if header := getMap(payload, "header"); header != nil && getString(header, "event_type") == "card.action.trigger" {
+		event := getMap(payload, "event")
+		if event == nil {
+			return nil
+		}
+		action := getMap(event, "action")
+		if action == nil {
+			return nil
+		}
+		a := &cardAction{
+			Value:  getMap(action, "value"),
+			Option: getString(action, "option"),
+		}
+		if operator := getMap(event, "operator"); operator != nil {
+			a.OperatorOpenID = getString(operator, "open_id")
+			a.OperatorUnionID = getString(operator, "union_id")
+		}
+		if ctx := getMap(event, "context"); ctx != nil {
+			a.MessageID = getString(ctx, "open_message_id")
+		}
+		return a
+	}
+
+	// 旧版回调：{"open_id": ..., "open_message_id": ..., "action": {...}}
+	action := getMap(payload, "action")
+	if action == nil {
+		return nil
+	}
+	return &cardAction{
+		OperatorOpenID:  getString(payload, "open_id"),
+		OperatorUnionID: getString(payload, "union_id"),
+		MessageID:       getString(payload, "open_message_id"),
+		Value:           getMap(action, "value"),
+		Option:          getString(action, "option"),
+	}
+}
+
+// CardWebhook 处理卡片按钮回调
+func (h *FeishuHandlerAITools) CardWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logFor(ctx).Error("read card callback body: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.logFor(ctx).Error("card callback json unmarshal: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.logFor(ctx).Debug("Card callback payload: %s", string(body))
+
+	// Handle challenge
+	if challenge := payload["challenge"]; challenge != nil {
+		json.NewEncoder(w).Encode(map[string]string{"challenge": fmt.Sprintf("%v", challenge)})
+		return
+	}
+
+	h.handleCardAction(ctx, w, payload)
+}
+
+// handleCardAction 处理 webhook 推送的卡片回调
+func (h *FeishuHandlerAITools) handleCardAction(ctx context.Context, w http.ResponseWriter, payload map[string]interface{}) {
+	// 卡片回调要求返回 JSON，空对象表示不通过响应更新卡片
+	defer w.Write([]byte("{}"))
+	h.processCardAction(ctx, payload)
+}
+
+// processCardAction 将按钮操作映射为删除或修改分类，并就地更新卡片；webhook 和长连接共用
+func (h *FeishuHandlerAITools) processCardAction(ctx context.Context, payload map[string]interface{}) {
+	action := parseCardAction(payload)
+	if action == nil || action.Value == nil {
+		h.logFor(ctx).Debug("No card action found in payload, keys: %v", getObjectKeys(payload))
+		return
+	}
+
+	recordID := getString(action.Value, "record_id")
+	summary := getString(action.Value, "summary")
+	if recordID == "" {
+		return
+	}
+
+	// 卡片中记录的是用户映射使用的 ID，可能是 open_id 或 union_id
+	if owner := getString(action.Value, "open_id"); owner != "" && !sameUser(owner, userIDs{OpenID: action.OperatorOpenID, UnionID: action.OperatorUnionID}) {
+		h.logFor(ctx).Info("Card action by non-owner ignored: record_id=%s, operator=%s", recordID, action.OperatorOpenID)
+		return
+	}
+
+	var card feishu.CardContent
+	switch getString(action.Value, "action") {
+	case cardActionUndo:
+		if err := h.billUseCase.DeleteBill(ctx, recordID); err != nil {
+			h.logFor(ctx).Error("Card undo failed: record_id=%s, err=%v", recordID, err)
+			card = buildOutcomeCard("❌ 撤销失败", "red", fmt.Sprintf("%s\n%v", summary, err))
+		} else {
+			h.logFor(ctx).Info("Card undo: record_id=%s", recordID)
+			card = buildOutcomeCard("↩️ 已撤销", "grey", summary)
+		}
+	case cardActionSetCategory:
+		if action.Option == "" {
+			return
+		}
+		if _, err := h.billUseCase.UpdateBill(ctx, recordID, map[string]interface{}{"category": action.Option}); err != nil {
+			h.logFor(ctx).Error("Card set category failed: record_id=%s, err=%v", recordID, err)
+			card = buildOutcomeCard("❌ 修改分类失败", "red", fmt.Sprintf("%s\n%v", summary, err))
+		} else {
+			h.logFor(ctx).Info("Card set category: record_id=%s, category=%s", recordID, action.Option)
+			card = buildOutcomeCard("✏️ 分类已修改", "blue", fmt.Sprintf("%s\n分类已改为：%s", summary, action.Option))
+		}
+	default:
+		return
+	}
+
+	if action.MessageID == "" {
+		return
+	}
+	if err := h.feishuService.UpdateCard(ctx, action.MessageID, card); err != nil {
+		h.logFor(ctx).Error("Update card failed: %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+	"github.com/wyg1997/LedgerBot/pkg/version"
+)
+
+// commandListLimit 快捷查询最多列出的明细条数
+const commandListLimit = 10
+
+// command 不经过 AI 直接处理的快捷命令
+type command struct {
+	usage replyKey
+	// needsName 为 true 时要求用户已设置名字，未设置时交给 AI 询问名字
+	needsName bool
+	// takesArg 为 true 时命令名后可以带参数（如 /恢复 recXXX），否则带参数的消息交给 AI
+	takesArg bool
+	run      func(h *FeishuHandlerAITools, ctx context.Context, openID, userName, arg string) string
+}
+
+// commands 快捷命令，键为去掉前缀后的命令名
+var commands = map[string]command{
+	"今天":     {usage: replyUsageToday, needsName: true, run: rangeCommand(replyRangeToday, repository.TimeRangeToday)},
+	"本周":     {usage: replyUsageWeek, needsName: true, run: rangeCommand(replyRangeWeek, repository.TimeRangeThisWeek)},
+	"本月":     {usage: replyUsageMonth, needsName: true, run: rangeCommand(replyRangeMonth, repository.TimeRangeThisMonth)},
+	"撤销":     {usage: replyUsageUndo, needsName: true, run: (*FeishuHandlerAITools).undoLastCommand},
+	"恢复":     {usage: replyUsageRestore, needsName: true, takesArg: true, run: (*FeishuHandlerAITools).restoreCommand},
+	"设置":     {usage: replyUsageSettings, takesArg: true, run: (*FeishuHandlerAITools).settingsCommand},
+	"导出我的数据": {usage: replyUsageExport, run: (*FeishuHandlerAITools).exportMyDataCommand},
+	"删除我的数据": {usage: replyUsageDeleteData, run: (*FeishuHandlerAITools).deleteMyDataCommand},
+	"版本":     {usage: replyUsageVersion, run: (*FeishuHandlerAITools).versionCommand},
+	// admin 仅限管理员使用，不在帮助中列出
+	"admin": {usage: replyUsageAdmin, takesArg: true, run: (*FeishuHandlerAITools).adminCommand},
+}
+
+// commandOrder 帮助中命令的展示顺序
+var commandOrder = []string{"今天", "本周", "本月", "撤销", "恢复", "设置", "导出我的数据", "删除我的数据", "版本"}
+
+// helpCommand 显示快捷命令说明，单独处理以免与 commands 互相引用
+const helpCommand = "帮助"
+
+// runCommand 识别并执行快捷命令；不是已知命令时返回 false，交给 AI 处理
+func (h *FeishuHandlerAITools) runCommand(ctx context.Context, openID, userName, text string) (string, bool) {
+	prefix := h.config.CommandPrefix
+	if prefix == "" || strings.EqualFold(prefix, "none") || !strings.HasPrefix(text, prefix) {
+		return "", false
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(text, prefix))
+	if name == helpCommand {
+		return formatHelp(h.languageFor(ctx), prefix), true
+	}
+	name, arg, _ := strings.Cut(name, " ")
+	arg = strings.TrimSpace(arg)
+	cmd, ok := commands[name]
+	if !ok || (cmd.needsName && userName == "") || (arg != "" && !cmd.takesArg) {
+		return "", false
+	}
+
+	h.logFor(ctx).Info("Running command: open_id=%s, command=%s, arg=%s", openID, name, arg)
+	return cmd.run(h, domain.WithOperator(ctx, userName), openID, userName, arg), true
+}
+
+// rangeCommand 返回查询指定时间范围收支的命令
+func rangeCommand(title replyKey, rangeType repository.TimeRangeType) func(h *FeishuHandlerAITools, ctx context.Context, openID, userName, arg string) string {
+	return func(h *FeishuHandlerAITools, ctx context.Context, openID, userName, arg string) string {
+		start, end, err := repository.ParseTimeRangeIn(h.locationFor(ctx), rangeType, "", "")
+		if err != nil {
+			h.logFor(ctx).Error("Parse time range for command failed: %v", err)
+			return h.text(ctx, replyQueryFailed, err)
+		}
+
+		bills, income, expense, err := h.billUseCase.QueryTransactions(ctx, userName, start, end, 0)
+		if err != nil {
+			h.logFor(ctx).Error("Query transactions for command failed: %v", err)
+			return h.text(ctx, replyQueryFailed, err)
+		}
+		return formatRangeSummary(h.languageFor(ctx), title, start, end, bills, income, expense, h.currencyFor(ctx))
+	}
+}
+
+// undoLastCommand 撤销用户最近一次新建、删除或修改账单的操作
+func (h *FeishuHandlerAITools) undoLastCommand(ctx context.Context, openID, userName, arg string) string {
+	result, err := h.billUseCase.UndoLast(ctx, userName)
+	switch {
+	case errors.Is(err, domain.ErrNothingToUndo):
+		return h.text(ctx, replyNothingToUndo)
+	case errors.Is(err, domain.ErrUndoUnsupported):
+		return h.text(ctx, replyUndoUnsupported)
+	case err != nil:
+		h.logFor(ctx).Error("Undo last operation failed: open_id=%s, err=%v", openID, err)
+		return h.text(ctx, replyUndoFailed, err)
+	}
+	h.logFor(ctx).Info("Undo last operation: open_id=%s, kind=%s, record_id=%s", openID, result.Undone.Kind, result.Undone.RecordID)
+
+	reply := formatUndo(h.languageFor(ctx), result, h.currencyFor(ctx))
+	if result.Undone.Kind == domain.OperationCreate && h.config.SoftDelete {
+		reply += h.text(ctx, replyUndoRestoreHint, h.config.SoftDeleteRetentionDays, h.config.CommandPrefix, result.Undone.RecordID)
+	}
+	return reply
+}
+
+// restoreCommand 恢复软删除的账单
+func (h *FeishuHandlerAITools) restoreCommand(ctx context.Context, openID, userName, arg string) string {
+	if arg == "" {
+		return h.text(ctx, replyRestoreUsage, h.config.CommandPrefix)
+	}
+	err := h.billUseCase.RestoreBill(ctx, arg)
+	switch {
+	case errors.Is(err, domain.ErrSoftDeleteDisabled):
+		return h.text(ctx, replySoftDeleteOff)
+	case errors.Is(err, domain.ErrBillNotFound):
+		return h.text(ctx, replyRestoreNotFound, arg)
+	case err != nil:
+		h.logFor(ctx).Error("Restore bill failed: record_id=%s, err=%v", arg, err)
+		return h.text(ctx, replyRestoreFailed, err)
+	}
+	h.logFor(ctx).Info("Restored bill: open_id=%s, record_id=%s", openID, arg)
+
+	bill, err := h.billUseCase.GetBill(ctx, arg)
+	if err != nil {
+		return h.text(ctx, replyRestored, arg)
+	}
+	return h.text(ctx, replyRestoredBill, bill.Description, domain.FormatAmount(h.currencyFor(ctx), bill.Amount), bill.Category, bill.Date.Format("2006-01-02"))
+}
+
+// settingsCommand 不带参数时列出个人设置，带参数时修改一项，如 “设置 时区 America/New_York”
+func (h *FeishuHandlerAITools) settingsCommand(ctx context.Context, openID, userName, arg string) string {
+	prefs := domain.PreferencesFromContext(ctx)
+	if arg == "" {
+		return formatPreferences(h.languageFor(ctx), prefs, h.config.CommandPrefix)
+	}
+
+	name, value, _ := strings.Cut(arg, " ")
+	if strings.TrimSpace(value) == "" {
+		return h.text(ctx, replySettingsNoValue, h.config.CommandPrefix, name)
+	}
+	if err := prefs.Set(name, value); err != nil {
+		return h.text(ctx, replySettingsInvalid, err, h.config.CommandPrefix)
+	}
+	platform, platformID := domain.SplitPlatformUserID(openID)
+	if err := h.userMappingRepo.SetPreferences(platform, platformID, prefs); err != nil {
+		h.logFor(ctx).Error("Save preferences failed: open_id=%s, err=%v", openID, err)
+		return h.text(ctx, replySettingsFailed, err)
+	}
+	h.logFor(ctx).Info("Preferences updated: open_id=%s, arg=%s", openID, arg)
+	// 新的语言设置对这条回复立即生效
+	lang := prefs.Language
+	if lang == "" {
+		lang = h.language
+	}
+	return localize(lang, replySettingsSaved) + formatPreferences(lang, prefs, h.config.CommandPrefix)
+}
+
+// versionCommand 显示运行中程序的版本、提交、构建时间和运行时长
+func (h *FeishuHandlerAITools) versionCommand(ctx context.Context, openID, userName, arg string) string {
+	return formatVersion(h.languageFor(ctx), version.Get(), version.Uptime())
+}
+
+// locationFor 返回日期使用的时区，用户设置过时优先使用
+func (h *FeishuHandlerAITools) locationFor(ctx context.Context) *time.Location {
+	return domain.PreferencesFromContext(ctx).Location(h.location)
+}
+
+// currencyFor 返回快捷命令回复使用的货币符号，用户设置过时优先使用
+func (h *FeishuHandlerAITools) currencyFor(ctx context.Context) string {
+	if currency := domain.PreferencesFromContext(ctx).Currency; currency != "" {
+		return currency
+	}
+	return h.currency
+}
+
+// formatPreferences 列出个人设置，未设置的项显示“默认”
+func formatPreferences(lang string, prefs domain.UserPreferences, prefix string) string {
+	orDefault := func(value string) string {
+		if value == "" {
+			return localize(lang, replySettingsDefault)
+		}
+		return value
+	}
+	topN := ""
+	if prefs.TopN > 0 {
+		topN = strconv.Itoa(prefs.TopN)
+	}
+
+	var b strings.Builder
+	b.WriteString(localize(lang, replySettingsTitle))
+	b.WriteString(localize(lang, replySettingsCurrency, orDefault(prefs.Currency)))
+	b.WriteString(localize(lang, replySettingsTimezone, orDefault(prefs.Timezone)))
+	b.WriteString(localize(lang, replySettingsLanguage, orDefault(prefs.Language)))
+	b.WriteString(localize(lang, replySettingsTopN, orDefault(topN)))
+	b.WriteString(localize(lang, replySettingsExample, prefix))
+	return b.String()
+}
+
+// formatRangeSummary 快捷查询的回复：收支合计和金额最大的几笔明细
+func formatRangeSummary(lang string, title replyKey, start, end time.Time, bills []*domain.Bill, income, expense float64, currency string) string {
+	var b strings.Builder
+	period := start.Format("2006-01-02")
+	if !sameDay(start, end) {
+		period += " ~ " + end.Format("2006-01-02")
+	}
+	b.WriteString(localize(lang, replyRangeHeader, localize(lang, title), period))
+	b.WriteString(localize(lang, replyRangeTotals,
+		domain.FormatAmount(currency, expense), domain.FormatAmount(currency, income), domain.FormatAmount(currency, domain.AddAmount(income, -expense))))
+
+	if len(bills) == 0 {
+		b.WriteString(localize(lang, replyRangeEmpty))
+		return b.String()
+	}
+
+	sorted := make([]*domain.Bill, len(bills))
+	copy(sorted, bills)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Amount > sorted[j].Amount })
+
+	b.WriteString(localize(lang, replyRangeCount, len(bills)))
+	if len(sorted) > commandListLimit {
+		b.WriteString(localize(lang, replyRangeTop, commandListLimit))
+		sorted = sorted[:commandListLimit]
+	} else {
+		b.WriteString(localize(lang, replyRangeAll))
+	}
+	for _, bill := range sorted {
+		sign := "-"
+		if bill.Type == domain.BillTypeIncome {
+			sign = "+"
+		}
+		b.WriteString(localize(lang, replyRangeItem, bill.Date.Format("01-02"), bill.Description, sign, domain.FormatAmount(currency, bill.Amount), bill.Category))
+	}
+	return b.String()
+}
+
+// undoFieldNames 撤销修改时各字段名称的模板键
+var undoFieldNames = map[string]replyKey{
+	"description":  replyFieldDescription,
+	"amount":       replyFieldAmount,
+	"type":         replyFieldType,
+	"category":     replyFieldCategory,
+	"date":         replyFieldDate,
+	"account":      replyFieldAccount,
+	"tags":         replyFieldTags,
+	"reimbursable": replyFieldReimbursable,
+}
+
+// formatUndo 撤销成功的回复：撤销新建、恢复删除，或逐项列出写回的字段
+func formatUndo(lang string, result *domain.UndoResult, currency string) string {
+	bill := result.Bill
+	summary := bill.RecordID
+	if bill.Description != "" {
+		summary = localize(lang, replyBillSummary, bill.Description, domain.FormatAmount(currency, bill.Amount), bill.Category, bill.Date.Format("2006-01-02"))
+	}
+
+	switch result.Undone.Kind {
+	case domain.OperationCreate:
+		return localize(lang, replyUndoCreate) + summary
+	case domain.OperationDelete:
+		reply := localize(lang, replyUndoDelete) + summary
+		if result.Recreated {
+			reply += localize(lang, replyUndoRecreated) + bill.RecordID
+		}
+		return reply
+	}
+
+	var b strings.Builder
+	b.WriteString(localize(lang, replyUndoUpdate, bill.Description))
+	for _, change := range result.Undone.Changes() {
+		b.WriteString(localize(lang, replyUndoChange, localize(lang, undoFieldNames[change.Field]), undoValue(lang, change.Field, change.New, currency), undoValue(lang, change.Field, change.Old, currency)))
+	}
+	return b.String()
+}
+
+// undoValue 字段值的显示文本，空值显示为 -
+func undoValue(lang, field, value, currency string) string {
+	switch {
+	case value == "":
+		return "-"
+	case field == "amount":
+		if amount, err := strconv.ParseFloat(value, 64); err == nil {
+			return domain.FormatAmount(currency, amount)
+		}
+		return currency + value
+	case field == "type" && value == string(domain.BillTypeIncome):
+		return localize(lang, replyTypeIncome)
+	case field == "type" && value == string(domain.BillTypeExpense):
+		return localize(lang, replyTypeExpense)
+	case field == "reimbursable" && value == "true":
+		return localize(lang, replyYes)
+	case field == "reimbursable":
+		return localize(lang, replyNo)
+	}
+	return value
+}
+
+// formatHelp 快捷命令的使用说明
+func formatHelp(lang, prefix string) string {
+	var b strings.Builder
+	b.WriteString(localize(lang, replyHelpTitle))
+	for _, name := range commandOrder {
+		b.WriteString(localize(lang, replyHelpLine, prefix, name, localize(lang, commands[name].usage)))
+	}
+	b.WriteString(localize(lang, replyHelpLine, prefix, helpCommand, localize(lang, replyHelpSelf)))
+	b.WriteString(localize(lang, replyHelpFooter))
+	return b.String()
+}
+
+// formatVersion 版本命令的回复，未知的项显示为 -
+func formatVersion(lang string, info version.Info, uptime time.Duration) string {
+	orUnknown := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+	var b strings.Builder
+	b.WriteString(localize(lang, replyVersion, info.Version))
+	b.WriteString(localize(lang, replyVersionCommit, orUnknown(info.Commit)))
+	b.WriteString(localize(lang, replyVersionBuildTime, orUnknown(info.BuildTime)))
+	b.WriteString(localize(lang, replyVersionGo, info.GoVersion))
+	b.WriteString(localize(lang, replyVersionUptime, formatUptime(lang, uptime)))
+	return b.String()
+}
+
+// formatUptime 运行时长的写法，如 "3 天 4 小时 5 分钟"
+func formatUptime(lang string, d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return localize(lang, replyUptimeDays, days, hours, minutes)
+	case hours > 0:
+		return localize(lang, replyUptimeHours, hours, minutes)
+	default:
+		return localize(lang, replyUptimeMinutes, minutes)
+	}
+}
+
+// sameDay 判断两个时间是否在同一天
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
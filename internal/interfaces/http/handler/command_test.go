@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// commandBillUseCase 快捷命令用到的账单操作，其余方法调用时 panic
+type commandBillUseCase struct {
+	domain.BillUseCase
+
+	bills   []*domain.Bill
+	ranges  [][2]time.Time
+	undo    *domain.UndoResult
+	undoErr error
+}
+
+func (u *commandBillUseCase) QueryTransactions(ctx context.Context, userName string, start, end time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	u.ranges = append(u.ranges, [2]time.Time{start, end})
+	income, expense := domain.SumBills(u.bills)
+	return u.bills, income, expense, nil
+}
+
+func (u *commandBillUseCase) UndoLast(ctx context.Context, userName string) (*domain.UndoResult, error) {
+	return u.undo, u.undoErr
+}
+
+func newCommandTestHandler(t *testing.T, bills *commandBillUseCase) *FeishuHandlerAITools {
+	t.Helper()
+	h := newIdentityTestHandler(t)
+	h.config.CommandPrefix = "/"
+	h.billUseCase = bills
+	h.language = "zh"
+	h.currency = "¥"
+	h.location = time.UTC
+	return h
+}
+
+func TestRunCommandRange(t *testing.T) {
+	now := time.Now().In(time.UTC)
+	bills := &commandBillUseCase{bills: []*domain.Bill{
+		{Description: "午饭", Amount: 30, Type: domain.BillTypeExpense, Category: "餐饮", Date: now},
+		{Description: "工资", Amount: 8000, Type: domain.BillTypeIncome, Category: "工资", Date: now},
+	}}
+	h := newCommandTestHandler(t, bills)
+
+	reply, handled := h.runCommand(context.Background(), "ou_1", "张三", "/今天")
+	if !handled {
+		t.Fatal("/今天 not handled")
+	}
+	for _, want := range []string{"📊 今天（" + now.Format("2006-01-02") + "）", "支出 ¥30.00", "收入 ¥8,000.00", "结余 ¥7,970.00", "共 2 笔", "工资 +¥8,000.00", "午饭 -¥30.00"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply %q does not contain %q", reply, want)
+		}
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if len(bills.ranges) != 1 || !bills.ranges[0][0].Equal(start) {
+		t.Errorf("queried %v, want today from %s", bills.ranges, start)
+	}
+
+	if _, handled := h.runCommand(context.Background(), "ou_1", "张三", "/本月"); !handled || len(bills.ranges) != 2 {
+		t.Fatal("/本月 not handled")
+	}
+	if monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC); !bills.ranges[1][0].Equal(monthStart) {
+		t.Errorf("/本月 starts at %s, want %s", bills.ranges[1][0], monthStart)
+	}
+}
+
+// 不是已知命令、缺少名字或多余参数时交给 AI
+func TestRunCommandFallsThrough(t *testing.T) {
+	h := newCommandTestHandler(t, &commandBillUseCase{})
+	tests := []struct{ userName, text string }{
+		{"张三", "今天花了多少"},
+		{"张三", "/买菜 30"},
+		{"张三", "/今天 餐饮"},
+		{"", "/本周"},
+	}
+	for _, tt := range tests {
+		if reply, handled := h.runCommand(context.Background(), "ou_1", tt.userName, tt.text); handled {
+			t.Errorf("runCommand(%q, %q) handled with %q", tt.userName, tt.text, reply)
+		}
+	}
+
+	// 关闭快捷命令后全部交给 AI
+	h.config.CommandPrefix = "none"
+	if _, handled := h.runCommand(context.Background(), "ou_1", "张三", "none今天"); handled {
+		t.Error("command handled with the prefix disabled")
+	}
+}
+
+func TestRunCommandUndo(t *testing.T) {
+	bills := &commandBillUseCase{undoErr: fmt.Errorf("undo: %w", domain.ErrNothingToUndo)}
+	h := newCommandTestHandler(t, bills)
+	if reply, _ := h.runCommand(context.Background(), "ou_1", "张三", "/撤销"); reply != "没有可以撤销的操作" {
+		t.Errorf("reply = %q, want nothing to undo", reply)
+	}
+
+	bills.undoErr = nil
+	bills.undo = &domain.UndoResult{
+		Undone: &domain.Operation{Kind: domain.OperationCreate, RecordID: "rec1"},
+		Bill:   &domain.Bill{RecordID: "rec1", Description: "午饭", Amount: 30, Category: "餐饮", Date: time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	reply, _ := h.runCommand(context.Background(), "ou_1", "张三", "/撤销")
+	if !strings.HasPrefix(reply, "↩️ 已撤销新建的记录：") || !strings.Contains(reply, "午饭") || !strings.Contains(reply, "2025-03-01") {
+		t.Errorf("reply = %q, want the undone bill", reply)
+	}
+}
+
+func TestFormatHelp(t *testing.T) {
+	help := formatHelp("zh", "/")
+	last := -1
+	for _, name := range append(commandOrder, helpCommand) {
+		i := strings.Index(help, "/"+name+"　")
+		if i < 0 || i < last {
+			t.Errorf("help lists /%s at %d, want after %d", name, i, last)
+		}
+		last = i
+	}
+	if strings.Contains(help, "admin") {
+		t.Error("help lists the admin command")
+	}
+}
+
+// 明细超过上限时只列出金额最大的几笔
+func TestFormatRangeSummaryTop(t *testing.T) {
+	day := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	var bills []*domain.Bill
+	for i := 1; i <= commandListLimit+2; i++ {
+		bills = append(bills, &domain.Bill{Description: fmt.Sprintf("账单%d", i), Amount: float64(i), Type: domain.BillTypeExpense, Category: "其他", Date: day})
+	}
+
+	reply := formatRangeSummary("zh", replyRangeMonth, day, day.AddDate(0, 1, -1), bills, 0, 78, "¥")
+	if !strings.Contains(reply, "（2025-03-01 ~ 2025-03-31）") || !strings.Contains(reply, fmt.Sprintf("金额最大的 %d 笔", commandListLimit)) {
+		t.Errorf("reply = %q", reply)
+	}
+	if strings.Contains(reply, "账单1 -") || strings.Contains(reply, "账单2 -") || !strings.Contains(reply, "账单12") {
+		t.Errorf("reply %q does not keep the largest bills", reply)
+	}
+
+	if empty := formatRangeSummary("zh", replyRangeToday, day, day, nil, 0, 0, "¥"); !strings.Contains(empty, "（2025-03-01）") {
+		t.Errorf("empty summary = %q", empty)
+	}
+}
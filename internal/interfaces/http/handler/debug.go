@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// runtimeStats /debug/stats 中的运行时状态
+type runtimeStats struct {
+	Goroutines   int     `json:"goroutines"`
+	HeapAlloc    uint64  `json:"heap_alloc_bytes"`
+	HeapInuse    uint64  `json:"heap_inuse_bytes"`
+	HeapObjects  uint64  `json:"heap_objects"`
+	Sys          uint64  `json:"sys_bytes"`
+	NumGC        uint32  `json:"num_gc"`
+	PauseTotalMs float64 `json:"gc_pause_total_ms"`
+	// LastGC 最近一次 GC 的时间，尚未 GC 时为空
+	LastGC        *time.Time `json:"last_gc,omitempty"`
+	GCCPUFraction float64    `json:"gc_cpu_fraction"`
+}
+
+// RegisterDebug registers the net/http/pprof handlers under /debug/pprof/ and a
+// /debug/stats JSON endpoint with runtime memory and GC stats plus the result of app,
+// such as worker queue depth and cache sizes. Only for a mux that is not publicly exposed
+func RegisterDebug(mux *http.ServeMux, app func() interface{}) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		stats := runtimeStats{
+			Goroutines:    runtime.NumGoroutine(),
+			HeapAlloc:     m.HeapAlloc,
+			HeapInuse:     m.HeapInuse,
+			HeapObjects:   m.HeapObjects,
+			Sys:           m.Sys,
+			NumGC:         m.NumGC,
+			PauseTotalMs:  float64(m.PauseTotalNs) / float64(time.Millisecond),
+			GCCPUFraction: m.GCCPUFraction,
+		}
+		if m.LastGC > 0 {
+			lastGC := time.Unix(0, int64(m.LastGC))
+			stats.LastGC = &lastGC
+		}
+
+		var appStats interface{}
+		if app != nil {
+			appStats = app()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Runtime runtimeStats `json:"runtime"`
+			App     interface{}  `json:"app,omitempty"`
+		}{stats, appStats})
+	})
+}
+
+// Metrics returns a handler that serves the result of app as JSON, such as worker queue depth,
+// queued bills and cache sizes. It is unauthenticated: register it on the debug listener, or on
+// the public mux through BillAPIHandler.RegisterMetrics
+func Metrics(app func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(app())
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// getDebug 请求 mux 上的 path，返回状态码和响应
+func getDebug(t *testing.T, mux *http.ServeMux, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+// 注册后调试接口可以访问，未注册时返回 404
+func TestDebugEndpoints(t *testing.T) {
+	paths := []string{"/debug/stats", "/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"}
+
+	disabled := http.NewServeMux()
+	for _, path := range paths {
+		if rec := getDebug(t, disabled, path); rec.Code != http.StatusNotFound {
+			t.Errorf("disabled %s: status %d, want 404", path, rec.Code)
+		}
+	}
+
+	enabled := http.NewServeMux()
+	RegisterDebug(enabled, func() interface{} { return map[string]int{"queue_depth": 3} })
+	for _, path := range paths {
+		if rec := getDebug(t, enabled, path); rec.Code != http.StatusOK {
+			t.Errorf("enabled %s: status %d, want 200", path, rec.Code)
+		}
+	}
+}
+
+func TestDebugStats(t *testing.T) {
+	mux := http.NewServeMux()
+	RegisterDebug(mux, func() interface{} { return map[string]int{"queue_depth": 3} })
+
+	rec := getDebug(t, mux, "/debug/stats")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var body struct {
+		Runtime map[string]interface{} `json:"runtime"`
+		App     map[string]int         `json:"app"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"goroutines", "heap_alloc_bytes", "heap_inuse_bytes", "num_gc"} {
+		if _, ok := body.Runtime[key]; !ok {
+			t.Errorf("runtime stats miss %s: %v", key, body.Runtime)
+		}
+	}
+	if n, _ := body.Runtime["goroutines"].(float64); n < 1 {
+		t.Errorf("goroutines = %v", body.Runtime["goroutines"])
+	}
+	if body.App["queue_depth"] != 3 {
+		t.Errorf("app stats = %v", body.App)
+	}
+
+	// 没有应用统计时省略 app
+	mux = http.NewServeMux()
+	RegisterDebug(mux, nil)
+	var plain map[string]interface{}
+	if err := json.NewDecoder(getDebug(t, mux, "/debug/stats").Body).Decode(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plain["app"]; ok {
+		t.Errorf("stats = %v, want no app key", plain)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+)
+
+// 共享同一缓存的多个处理器中，同一事件只处理一次
+func TestIsDuplicateEvent(t *testing.T) {
+	store := cache.NewMemoryCache()
+	defer store.Close()
+	first, second := newIdentityTestHandler(t), newIdentityTestHandler(t)
+	first.seenEvents = cache.NewTyped[bool](store)
+	second.seenEvents = cache.NewTyped[bool](store)
+	ctx := context.Background()
+
+	if first.isDuplicateEvent(ctx, "ev1", "om1") {
+		t.Fatal("first delivery reported as duplicate")
+	}
+	if !second.isDuplicateEvent(ctx, "ev1", "om1") {
+		t.Error("retried delivery not reported as duplicate")
+	}
+	// 不同事件互不影响
+	if second.isDuplicateEvent(ctx, "ev2", "om1") {
+		t.Error("new event_id reported as duplicate")
+	}
+	// 缺少 event_id 时按 message_id 去重
+	if first.isDuplicateEvent(ctx, "", "om3") || !second.isDuplicateEvent(ctx, "", "om3") {
+		t.Error("message_id fallback did not deduplicate")
+	}
+	// 两者都缺失时无法去重，照常处理
+	if first.isDuplicateEvent(ctx, "", "") || first.isDuplicateEvent(ctx, "", "") {
+		t.Error("event without IDs reported as duplicate")
+	}
+}
+
+// 记录持久化到文件，重启后仍能识别重试推送
+func TestIsDuplicateEventAfterRestart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "events.json")
+	before := cache.NewUserMappingCache(file, cache.Options{})
+	h := newIdentityTestHandler(t)
+	h.seenEvents = cache.NewTyped[bool](before)
+	if h.isDuplicateEvent(context.Background(), "ev1", "") {
+		t.Fatal("first delivery reported as duplicate")
+	}
+	before.Close()
+
+	after := cache.NewUserMappingCache(file, cache.Options{})
+	defer after.Close()
+	h = newIdentityTestHandler(t)
+	h.seenEvents = cache.NewTyped[bool](after)
+	if !h.isDuplicateEvent(context.Background(), "ev1", "") {
+		t.Error("event seen before restart not reported as duplicate")
+	}
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
@@ -13,76 +17,123 @@ import (
 	"github.com/wyg1997/LedgerBot/internal/domain"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
 	"github.com/wyg1997/LedgerBot/pkg/logger"
+	"github.com/wyg1997/LedgerBot/pkg/workerpool"
 )
 
 // FeishuHandlerAITools processes requests using AI tool calling
 type FeishuHandlerAITools struct {
 	config          *config.FeishuConfig
+	importConfig    *config.ImportConfig
 	feishuService   *feishu.FeishuService
 	billUseCase     domain.BillUseCase
 	aiservice       domain.AIService
 	userMappingRepo domain.UserMappingRepository
-	logger          logger.Logger
+	seenEvents      *cache.Typed[bool] // 已处理的事件，用于过滤飞书的重试推送
+	messageRecords  *cache.Typed[messageRecords]      // 消息创建的账单，消息撤回时据此删除
+	pendingImports  *cache.Typed[pendingImport]       // 等待用户确认的导入，不持久化到文件
+	pendingDeletes  *cache.Typed[pendingDataDeletion] // 等待用户确认的删除个人数据，不持久化到文件
+	pool            *workerpool.Pool // 消息处理的工作池，限制同时处理的消息数
+	lanesMu         sync.Mutex
+	lanes           map[string]*userLane // 每个用户待处理的消息，同一用户的消息按到达顺序依次处理
+	currency        string          // 快捷命令回复中金额的货币符号
+	language        string          // 用户未设置语言时回复使用的语言
+	location        *time.Location  // 用户未设置时区时日期使用的时区
+	baseCtx         context.Context // 根上下文，服务关闭时取消，后台处理中的请求随之停止
+
+	botMu        sync.Mutex
+	bot          botIdentity // 机器人自身信息，用于识别@提及
+	botRefreshAt time.Time   // 下次从接口刷新机器人信息的时间
 }
 
+// messageTimeout 单条消息后台处理的超时时间
+const messageTimeout = 2 * time.Minute
+
+// eventDedupTTL 事件去重的保留时间，覆盖飞书的重试窗口（15 秒、5 分钟、1 小时、6 小时）
+const eventDedupTTL = 12 * time.Hour
+
 // NewFeishuHandlerAITools creates handler
 func NewFeishuHandlerAITools(
+	ctx context.Context,
 	config *config.FeishuConfig,
+	importConfig *config.ImportConfig,
 	feishuService *feishu.FeishuService,
 	billUseCase domain.BillUseCase,
 	aiservice domain.AIService,
 	userMappingRepo domain.UserMappingRepository,
+	seenEvents cache.Cache,
+	createdRecords cache.Cache,
+	newStateCache cache.Factory,
+	pool *workerpool.Pool,
+	currency string,
+	language string,
+	location *time.Location,
 ) *FeishuHandlerAITools {
 	return &FeishuHandlerAITools{
 		config:          config,
+		importConfig:    importConfig,
 		feishuService:   feishuService,
 		billUseCase:     billUseCase,
 		aiservice:       aiservice,
 		userMappingRepo: userMappingRepo,
-		logger:          logger.GetLogger(),
+		seenEvents:      cache.NewTyped[bool](seenEvents),
+		messageRecords:  cache.NewTyped[messageRecords](createdRecords),
+		pendingImports:  cache.NewTyped[pendingImport](newStateCache.Create("pending_imports")),
+		pendingDeletes:  cache.NewTyped[pendingDataDeletion](newStateCache.Create("pending_deletes")),
+		pool:            pool,
+		lanes:           make(map[string]*userLane),
+		currency:        currency,
+		language:        language,
+		location:        location,
+		baseCtx:         ctx,
+		bot:             botIdentity{name: config.BotName},
 	}
 }
 
-// ExecuteFunc creates the service wrappers for AI execution
-func (h *FeishuHandlerAITools) ExecuteFunc(openID string, userName string, renameFunc func(string) error) func(string, string, domain.BillUseCase, func(string) error, []domain.AIMessage) (string, error) {
-	return func(input string, name string, billUseCase domain.BillUseCase, renameFunc func(string) error, history []domain.AIMessage) (string, error) {
-		// Create bill service wrapper - pass original message (input) to preserve it
-		billService := ai.NewBillService(billUseCase, openID, name, input)
-		// Create rename service wrapper
-		renameService := ai.NewRenameService(renameFunc)
-
-		// Call the proper Execute method
-		return h.aiservice.Execute(input, name, billService, renameService, history)
-	}
+// Close stops the cleanup of the in-memory caches owned by the handler; the caches passed to
+// NewFeishuHandlerAITools are closed by their creator
+func (h *FeishuHandlerAITools) Close() {
+	_ = h.pendingImports.Close()
+	_ = h.pendingDeletes.Close()
 }
 
 // Webhook processes Feishu webhook
 func (h *FeishuHandlerAITools) Webhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	// Log the incoming request
-	h.logger.Debug("=== Received Feishu Webhook Request ===")
-	h.logger.Debug("Method: %s", r.Method)
-	h.logger.Debug("URL: %s", r.URL.String())
-	h.logger.Debug("Headers: %v", r.Header)
+	h.logFor(ctx).Debug("=== Received Feishu Webhook Request ===")
+	h.logFor(ctx).Debug("Method: %s", r.Method)
+	h.logFor(ctx).Debug("URL: %s", r.URL.String())
+	h.logFor(ctx).Debug("Headers: %v", r.Header)
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.logger.Error("read body: %v", err)
+		h.logFor(ctx).Error("read body: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	var payload map[string]interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
-		h.logger.Error("json unmarshal: %v", err)
+		h.logFor(ctx).Error("json unmarshal: %v", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	// 以事件 ID 作为关联 ID，同一事件的日志带有相同的 cid
+	ctx = eventContext(ctx, payload)
+	h.logFor(ctx).WithFields(logger.Fields{
+		"event_type": getString(getMap(payload, "header"), "event_type"),
+		"bytes":      len(body),
+		"remote":     r.RemoteAddr,
+	}).Info("Feishu webhook received")
+
 	// Log the received payload
-	h.logger.Debug("Payload: %s", string(body))
+	h.logFor(ctx).Debug("Payload: %s", string(body))
 	if challenge, ok := payload["challenge"]; ok {
-		h.logger.Debug("Challenge received: %v", challenge)
+		h.logFor(ctx).Debug("Challenge received: %v", challenge)
 	}
 
 	// Handle challenge
@@ -96,65 +147,365 @@ func (h *FeishuHandlerAITools) Webhook(w http.ResponseWriter, r *http.Request) {
 	if header != nil {
 		eventType := getString(header, "event_type")
 		if eventType == "im.message.receive_v1" {
-			h.logger.Debug("检测到新的IM消息格式，调用处理函数")
-			h.handleIMMessage(w, payload)
+			h.logFor(ctx).Debug("检测到新的IM消息格式，调用处理函数")
+			h.handleIMMessage(ctx, w, body)
+			return
+		}
+		if eventType == "im.chat.access_event.bot_p2p_chat_entered_v1" || eventType == "im.chat.member.bot.added_v1" {
+			if err := h.processWelcomeEvent(ctx, eventType, payload); err != nil {
+				h.logFor(ctx).Error("Process welcome event: %v", err)
+			}
+			w.Write([]byte("success"))
+			return
+		}
+		if eventType == "im.message.recalled_v1" {
+			if err := h.processRecallEvent(ctx, payload); err != nil {
+				h.logFor(ctx).Error("Process recall event: %v", err)
+			}
+			w.Write([]byte("success"))
 			return
 		}
+		if eventType == "card.action.trigger" {
+			h.handleCardAction(ctx, w, payload)
+			return
+		}
+	}
+
+	// 旧版（1.0）事件：用户首次打开与机器人的私聊
+	if event := getMap(payload, "event"); event != nil && getString(event, "type") == "p2p_chat_create" {
+		if err := h.processWelcomeEvent(ctx, "p2p_chat_create", payload); err != nil {
+			h.logFor(ctx).Error("Process welcome event: %v", err)
+		}
+		w.Write([]byte("success"))
+		return
 	}
 
 	// 如果没有header.event_type = im.message.receive_v1，则直接返回ok
-	h.logger.Debug("Unknown message format, returning ok")
+	h.logFor(ctx).Debug("Unknown message format, returning ok")
 	w.Write([]byte("ok"))
 }
 
-func (h *FeishuHandlerAITools) processMessage(openID, text, messageID string, history []domain.AIMessage) {
+func (h *FeishuHandlerAITools) processMessage(ctx context.Context, openID, text, messageID, conversationKey string, history []domain.AIMessage) {
+	ctx, cancel := h.detach(ctx, messageTimeout)
+	defer cancel()
+	ctx = domain.WithSourceMessage(ctx, messageID)
+	ctx = domain.WithFileReplier(ctx, h.fileReplier(messageID))
+	defer h.markProcessing(ctx, messageID)()
+
+	response, created := h.generateReply(ctx, openID, text, conversationKey, history)
+	h.reply(ctx, openID, messageID, response, created)
+	h.rememberCreatedRecords(ctx, openID, messageID, conversationKey, created)
+}
+
+// generateReply 生成对一条文本消息的回复，错误也以回复文本的形式返回
+// 同时返回本次新建的账单，用于渲染带快捷操作的卡片
+func (h *FeishuHandlerAITools) generateReply(ctx context.Context, openID, text, conversationKey string, history []domain.AIMessage) (string, []*domain.Bill) {
 	// text is the current/latest message from the webhook, which will be used as originalMsg
 	// For thread conversations, we only record the latest message as originalMsg, not the entire history
 	
 	// If message is empty, fill with default greeting to avoid triggering rename
 	if text == "" {
 		text = "你好"
-		h.logger.Debug("Empty message detected, filled with default greeting: 你好")
+		h.logFor(ctx).Debug("Empty message detected, filled with default greeting: 你好")
 	}
 	
-	h.logger.Info("Processing from %s: %s", openID, text)
+	userName, hasName := h.getUserNameIfExists(ctx, openID)
+	h.logFor(ctx).WithFields(logger.Fields{"user": openID, "user_name": userName, "has_name": hasName}).Info("Processing message: %s", text)
+	ctx = h.withPreferences(ctx, openID)
+	ctx = domain.WithDataDeletion(ctx, func() string {
+		return h.startDataDeletion(ctx, openID, userName, conversationKey)
+	})
 
-	userName, hasName := h.getUserNameIfExists(openID)
-	h.logger.Info("用户名: %s，是否已存在映射: %v", userName, hasName)
+	// 快捷命令直接查询或操作账单，不经过 AI
+	if response, handled := h.runCommand(ctx, openID, userName, text); handled {
+		return response, nil
+	}
+
+	renameFunc := h.renameFunc(openID)
+
+	// "确认/取消" 回复直接处理待确认的操作，不再调用 AI
+	if confirmed, ok := ai.ParseConfirmationReply(text); ok {
+		if response, handled := h.resolvePendingDataDeletion(ctx, conversationKey, confirmed); handled {
+			return response, nil
+		}
+		if response, handled := h.resolvePendingImport(ctx, conversationKey, confirmed); handled {
+			return response, nil
+		}
+		billService := ai.NewBillService(ctx, h.billUseCase, openID, userName, text)
+		renameService := ai.NewRenameService(renameFunc)
+		response, handled, err := h.aiservice.ResolveConfirmation(conversationKey, confirmed, billService, renameService)
+		if handled {
+			if err != nil {
+				h.logFor(ctx).Error("Resolve confirmation: %v", err)
+				response = h.text(ctx, replyAIFailed, err)
+			}
+			return response, ai.CreatedBills(billService)
+		}
+	}
 
-	// Rename function - simplifies to just updating stored name
-	renameFunc := func(name string) error {
-		return h.userMappingRepo.SetUserName(openID, name)
+	// "更多" 回复直接翻页上一次被截断的查询结果，不再调用 AI
+	if ai.IsMoreReply(text) {
+		billService := ai.NewBillService(ctx, h.billUseCase, openID, userName, text)
+		response, handled, err := h.aiservice.MoreResults(conversationKey, billService)
+		if handled {
+			if err != nil {
+				h.logFor(ctx).Error("More results: %v", err)
+			}
+			return response, nil
+		}
 	}
 
 	// Execute via tool service
 	// Note: text (current message) is passed as input, which will be stored as originalMsg in bill
-	toolService := h.ExecuteFunc(openID, userName, renameFunc)
-	response, err := toolService(text, userName, h.billUseCase, renameFunc, history)
+	billService := ai.NewBillService(ctx, h.billUseCase, openID, userName, text)
+	renameService := ai.NewRenameService(renameFunc)
+	response, err := h.aiservice.Execute(ctx, text, userName, conversationKey, billService, renameService, history)
+	if err != nil {
+		h.logFor(ctx).Error("AI execution: %v", err)
+		return h.text(ctx, replyAIFailed, err), ai.CreatedBills(billService)
+	}
+
+	return response, ai.CreatedBills(billService)
+}
+
+// markProcessing 给消息加上“处理中”的表情，提示用户不必重复发送；返回的函数在回复后调用，
+// 撤销该表情并按配置换成完成表情。表情相关的失败只记录日志，不影响消息处理
+func (h *FeishuHandlerAITools) markProcessing(ctx context.Context, messageID string) func() {
+	if !reactionEnabled(h.config.ProcessingReaction) {
+		return func() {}
+	}
+
+	reactionID, err := h.feishuService.AddReaction(ctx, messageID, h.config.ProcessingReaction)
+	if err != nil {
+		h.logFor(ctx).Warn("Add processing reaction failed: message_id=%s, err=%v", messageID, err)
+	}
+
+	return func() {
+		// 处理超时后 ctx 已失效，这里单独设置超时
+		doneCtx, cancel := h.detach(ctx, 10*time.Second)
+		defer cancel()
+
+		if reactionID != "" {
+			if err := h.feishuService.RemoveReaction(doneCtx, messageID, reactionID); err != nil {
+				h.logFor(ctx).Warn("Remove processing reaction failed: message_id=%s, err=%v", messageID, err)
+			}
+		}
+		if reactionEnabled(h.config.DoneReaction) {
+			if _, err := h.feishuService.AddReaction(doneCtx, messageID, h.config.DoneReaction); err != nil {
+				h.logFor(ctx).Warn("Add done reaction failed: message_id=%s, err=%v", messageID, err)
+			}
+		}
+	}
+}
+
+// reactionEnabled 判断表情配置是否开启，空或 none 表示关闭
+func reactionEnabled(emojiType string) bool {
+	return emojiType != "" && !strings.EqualFold(emojiType, "none")
+}
+
+// reply 回复消息：开启卡片回复且本次新建了账单时使用交互卡片，否则使用文本
+func (h *FeishuHandlerAITools) reply(ctx context.Context, openID, messageID, response string, created []*domain.Bill) {
+	if h.config.CardReplies && len(created) > 0 {
+		card := buildRecordCard(response, created, openID)
+		err := h.feishuService.ReplyCard(ctx, messageID, card, uuid.New().String())
+		if err == nil {
+			return
+		}
+		h.logFor(ctx).Error("Reply card failed, falling back to text: %v", err)
+	}
+	_ = h.feishuService.ReplyMessage(ctx, messageID, response, uuid.New().String())
+}
+
+// fileReplier 以文件消息回复 messageID，用于导出账单等需要发送附件的操作
+func (h *FeishuHandlerAITools) fileReplier(messageID string) domain.FileReplier {
+	return func(ctx context.Context, fileName string, content io.ReadSeeker) error {
+		return h.feishuService.ReplyFile(ctx, messageID, fileName, content, uuid.New().String())
+	}
+}
+
+// processAudioMessage 下载语音并转写，再按文本消息处理，回复前附上识别结果便于用户发现误听
+func (h *FeishuHandlerAITools) processAudioMessage(ctx context.Context, openID, messageID, fileKey, conversationKey string) {
+	ctx, cancel := h.detach(ctx, messageTimeout)
+	defer cancel()
+	ctx = domain.WithSourceMessage(ctx, messageID)
+	ctx = domain.WithFileReplier(ctx, h.fileReplier(messageID))
+	ctx = h.withPreferences(ctx, openID)
+	defer h.markProcessing(ctx, messageID)()
+
+	h.logFor(ctx).Info("Processing voice message from %s: message_id=%s", openID, messageID)
+
+	audio, err := h.feishuService.GetMessageResource(ctx, messageID, fileKey, "file")
+	if err != nil {
+		h.logFor(ctx).Error("Download audio: %v", err)
+		_ = h.feishuService.ReplyMessage(ctx, messageID, h.text(ctx, replyVoiceDownloadFailed, err), uuid.New().String())
+		return
+	}
+
+	// 飞书语音为 ogg 封装的 opus 音频
+	text, err := h.aiservice.Transcribe(ctx, audio, "audio.ogg")
+	if err != nil {
+		h.logFor(ctx).Error("Transcribe audio: %v", err)
+		_ = h.feishuService.ReplyMessage(ctx, messageID, h.text(ctx, replyVoiceFailed, err), uuid.New().String())
+		return
+	}
+	if text == "" {
+		_ = h.feishuService.ReplyMessage(ctx, messageID, h.text(ctx, replyVoiceEmpty), uuid.New().String())
+		return
+	}
+
+	response, created := h.generateReply(ctx, openID, text, conversationKey, nil)
+	h.reply(ctx, openID, messageID, h.text(ctx, replyVoiceTranscript, text, response), created)
+	h.rememberCreatedRecords(ctx, openID, messageID, conversationKey, created)
+}
+
+// handleMediaMessage 处理图片、语音等无法 @机器人 的消息：私聊直接处理，群聊仅在以 @机器人 开头的话题中处理
+func (h *FeishuHandlerAITools) handleMediaMessage(ctx context.Context, message *imMessage, contentObj map[string]interface{}, resourceKey, openID string, process func(messageID, conversationKey string)) {
+	if resourceKey == "" {
+		h.logFor(ctx).Debug("No resource key found in content, content keys: %v", getObjectKeys(contentObj))
+		return
+	}
+
+	if message.isGroup() {
+		// 只处理首条消息提及机器人的话题
+		mentioned := false
+		if message.ThreadID != "" {
+			threadMessages, err := h.feishuService.ListMessagesByThread(ctx, message.ThreadID)
+			if err != nil {
+				h.logFor(ctx).Error("List thread messages failed: %v", err)
+			} else {
+				mentioned = h.firstMessageMentionsBot(threadMessages, h.botIdentity(ctx))
+			}
+		}
+		if !mentioned {
+			h.logFor(ctx).Debug("Media message not in a thread started with bot mention, skipping message")
+			return
+		}
+	}
+
+	messageID := message.MessageID
+	conversationKey := openID + ":" + message.rootID()
+
+	h.enqueue(ctx, openID, messageID, func() {
+		process(messageID, conversationKey)
+	}, func() { h.replyBusy(ctx, messageID) })
+
+	h.logFor(ctx).Debug("=== Media message queued for processing ===")
+}
+
+// processImageMessage 下载图片并交给 AI 识别收据
+func (h *FeishuHandlerAITools) processImageMessage(ctx context.Context, openID, messageID, imageKey, conversationKey string) {
+	ctx, cancel := h.detach(ctx, messageTimeout)
+	defer cancel()
+	ctx = domain.WithSourceMessage(ctx, messageID)
+	ctx = h.withPreferences(ctx, openID)
+	defer h.markProcessing(ctx, messageID)()
+
+	h.logFor(ctx).Info("Processing receipt image from %s: message_id=%s", openID, messageID)
+
+	userName, hasName := h.getUserNameIfExists(ctx, openID)
+	h.logFor(ctx).Info("用户名: %s，是否已存在映射: %v", userName, hasName)
+
+	image, err := h.feishuService.GetMessageResource(ctx, messageID, imageKey, "image")
 	if err != nil {
-		h.logger.Error("AI execution: %v", err)
-		// Use ReplyMessage with UUID for error response
-		errMsg := fmt.Sprintf("AI处理失败：%v", err)
-		_ = h.feishuService.ReplyMessage(messageID, errMsg, uuid.New().String())
+		h.logFor(ctx).Error("Download image: %v", err)
+		_ = h.feishuService.ReplyMessage(ctx, messageID, h.text(ctx, replyImageDownloadFailed, err), uuid.New().String())
 		return
 	}
+	// 配置了附件字段时，识别出的账单附上收据原图
+	if h.config.FieldAttachment != "" {
+		ctx = domain.WithAttachments(ctx, domain.Attachment{FileName: receiptFileName(image), Data: image})
+	}
+
+	renameFunc := h.renameFunc(openID)
+	billService := ai.NewBillService(ctx, h.billUseCase, openID, userName, "")
+	renameService := ai.NewRenameService(renameFunc)
+
+	response, err := h.aiservice.ExecuteReceipt(ctx, image, userName, conversationKey, billService, renameService)
+	if err != nil {
+		h.logFor(ctx).Error("Receipt execution: %v", err)
+	}
+	created := ai.CreatedBills(billService)
+	h.reply(ctx, openID, messageID, response, created)
+	h.rememberCreatedRecords(ctx, openID, messageID, conversationKey, created)
+}
+
+// receiptFileName 根据图片内容确定收据附件的文件名
+func receiptFileName(image []byte) string {
+	switch http.DetectContentType(image) {
+	case "image/png":
+		return "receipt.png"
+	case "image/gif":
+		return "receipt.gif"
+	case "image/webp":
+		return "receipt.webp"
+	default:
+		return "receipt.jpg"
+	}
+}
+
+// isDuplicateEvent 判断事件是否已处理过，未处理过时记录下来
+// 优先使用 event_id，缺失时退回到 message_id
+func (h *FeishuHandlerAITools) isDuplicateEvent(ctx context.Context, eventID, messageID string) bool {
+	key := ""
+	switch {
+	case eventID != "":
+		key = "event:" + eventID
+	case messageID != "":
+		key = "message:" + messageID
+	default:
+		return false
+	}
 
-	// Use ReplyMessage with UUID for successful response
-	_ = h.feishuService.ReplyMessage(messageID, response, uuid.New().String())
+	// 检查和记录是一次原子操作，共享 Redis 的多个副本中只有一个会处理该事件
+	set, err := h.seenEvents.SetNX(key, true, eventDedupTTL)
+	if err != nil {
+		// 记录失败时照常处理，宁可重复也不丢消息
+		h.logFor(ctx).Warn("Failed to record seen event %s: %v", key, err)
+		return false
+	}
+	if !set {
+		h.logFor(ctx).Info("Duplicate event skipped: %s", key)
+		return true
+	}
+	return false
 }
 
 // getUserNameIfExists 尝试从映射获取用户名，不存在时返回空字符串
-func (h *FeishuHandlerAITools) getUserNameIfExists(openID string) (string, bool) {
-	userName, err := h.userMappingRepo.GetUserName(openID)
+// openID 为 domain.PlatformUserID 生成的 ID，其他平台的用户带有平台前缀
+func (h *FeishuHandlerAITools) getUserNameIfExists(ctx context.Context, openID string) (string, bool) {
+	userName, err := h.userMappingRepo.GetUserName(domain.SplitPlatformUserID(openID))
 	if err != nil {
-		h.logger.Debug("用户未在映射中找到: %s, err: %v", openID, err)
+		h.logFor(ctx).Debug("用户未在映射中找到: %s, err: %v", openID, err)
 		return "", false
 	}
 
-	h.logger.Debug("获取用户映射: %s -> %s", openID, userName)
+	h.logFor(ctx).Debug("获取用户映射: %s -> %s", openID, userName)
 	return userName, true
 }
 
+// renameFunc 返回保存该用户名字的函数，返回值为实际保存的名字（重名时可能带有编号）
+func (h *FeishuHandlerAITools) renameFunc(openID string) func(name string) (string, error) {
+	platform, platformID := domain.SplitPlatformUserID(openID)
+	return func(name string) (string, error) {
+		if err := h.userMappingRepo.SetUserName(platform, platformID, name); err != nil {
+			return "", err
+		}
+		return h.userMappingRepo.GetUserName(platform, platformID)
+	}
+}
+
+// withPreferences 把用户偏好放入上下文，供回复格式和时间范围使用；读取失败时按全局配置处理
+func (h *FeishuHandlerAITools) withPreferences(ctx context.Context, openID string) context.Context {
+	platform, platformID := domain.SplitPlatformUserID(openID)
+	prefs, err := h.userMappingRepo.GetPreferences(platform, platformID)
+	if err != nil {
+		h.logFor(ctx).Warn("Get user preferences failed: open_id=%s, err=%v", openID, err)
+	}
+	return domain.WithPreferences(ctx, prefs, func(prefs domain.UserPreferences) error {
+		return h.userMappingRepo.SetPreferences(platform, platformID, prefs)
+	})
+}
+
 func getString(m map[string]interface{}, key string) string {
 	v, ok := m[key].(string)
 	if !ok {
@@ -186,27 +537,11 @@ func getObjectKeys(m map[string]interface{}) []string {
 }
 
 // checkAndStripMention 判断当前消息是否@Bot并去掉文本中的@占位
-func (h *FeishuHandlerAITools) checkAndStripMention(text string, message map[string]interface{}, botName string) (bool, string) {
-	mentions := message["mentions"]
-	if mentions == nil {
-		return false, text
-	}
-	mentionList, ok := mentions.([]interface{})
-	if !ok || len(mentionList) == 0 {
-		return false, text
-	}
-
-	for _, mention := range mentionList {
-		mentionMap, ok := mention.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		name := getString(mentionMap, "name")
-		mentionKey := getString(mentionMap, "key")
-
-		if name == botName {
-			if mentionKey != "" && strings.Contains(text, mentionKey) {
-				text = strings.TrimSpace(strings.Replace(text, mentionKey, "", 1))
+func (h *FeishuHandlerAITools) checkAndStripMention(text string, mentions []imMention, bot botIdentity) (bool, string) {
+	for _, mention := range mentions {
+		if bot.matches(mention.Name, mention.ID.OpenID) {
+			if mention.Key != "" && strings.Contains(text, mention.Key) {
+				text = strings.TrimSpace(strings.Replace(text, mention.Key, "", 1))
 			}
 			return true, text
 		}
@@ -216,25 +551,32 @@ func (h *FeishuHandlerAITools) checkAndStripMention(text string, message map[str
 }
 
 // firstMessageMentionsBot 判断线程第一条消息是否@了机器人
-func (h *FeishuHandlerAITools) firstMessageMentionsBot(messages []*larkim.Message, botName string) bool {
+func (h *FeishuHandlerAITools) firstMessageMentionsBot(messages []*larkim.Message, bot botIdentity) bool {
 	if len(messages) == 0 {
 		return false
 	}
 
-	return h.messageMentionsBot(messages[0], botName)
+	return h.messageMentionsBot(messages[0], bot)
 }
 
 // messageMentionsBot 判断单条消息的mentions中是否包含Bot
-func (h *FeishuHandlerAITools) messageMentionsBot(msg *larkim.Message, botName string) bool {
+func (h *FeishuHandlerAITools) messageMentionsBot(msg *larkim.Message, bot botIdentity) bool {
 	if msg == nil || msg.Mentions == nil {
 		return false
 	}
 
 	for _, mention := range msg.Mentions {
-		if mention == nil || mention.Name == nil {
+		if mention == nil {
 			continue
 		}
-		if *mention.Name == botName {
+		name, openID := "", ""
+		if mention.Name != nil {
+			name = *mention.Name
+		}
+		if mention.Id != nil && (mention.IdType == nil || *mention.IdType == "open_id") {
+			openID = *mention.Id
+		}
+		if bot.matches(name, openID) {
 			return true
 		}
 	}
@@ -243,7 +585,7 @@ func (h *FeishuHandlerAITools) messageMentionsBot(msg *larkim.Message, botName s
 }
 
 // buildAIHistoryFromThread 构建AI上下文，映射sender_type到角色
-func (h *FeishuHandlerAITools) buildAIHistoryFromThread(messages []*larkim.Message, botName string) []domain.AIMessage {
+func (h *FeishuHandlerAITools) buildAIHistoryFromThread(messages []*larkim.Message, bot botIdentity) []domain.AIMessage {
 	history := make([]domain.AIMessage, 0, len(messages))
 
 	for _, msg := range messages {
@@ -265,13 +607,17 @@ func (h *FeishuHandlerAITools) buildAIHistoryFromThread(messages []*larkim.Messa
 			continue
 		}
 
-		text := getString(contentObj, "text")
+		msgType := ""
+		if msg.MsgType != nil {
+			msgType = *msg.MsgType
+		}
+		text := extractMessageText(msgType, contentObj)
 		if text == "" {
 			continue
 		}
 
 		// 去掉@Bot的key，避免AI误判
-		if h.messageMentionsBot(msg, botName) && msg.Mentions != nil {
+		if h.messageMentionsBot(msg, bot) && msg.Mentions != nil {
 			for _, mention := range msg.Mentions {
 				if mention == nil || mention.Key == nil {
 					continue
@@ -283,6 +629,12 @@ func (h *FeishuHandlerAITools) buildAIHistoryFromThread(messages []*larkim.Messa
 			}
 		}
 
+		// 清理伪造的角色前缀等内容，避免群成员通过历史消息注入指令
+		text = ai.SanitizeUserContent(text)
+		if text == "" {
+			continue
+		}
+
 		role := "user"
 		if msg.Sender != nil && msg.Sender.SenderType != nil && *msg.Sender.SenderType == "app" {
 			role = "assistant"
@@ -297,143 +649,148 @@ func (h *FeishuHandlerAITools) buildAIHistoryFromThread(messages []*larkim.Messa
 	return history
 }
 
-// handleIMMessage handles the new IM message format (im.message.receive_v1)
-func (h *FeishuHandlerAITools) handleIMMessage(w http.ResponseWriter, payload map[string]interface{}) {
-	h.logger.Debug("=== Processing new IM message format ===")
-
-	// Extract header info
-	header := getMap(payload, "header")
-	if header == nil {
-		h.logger.Debug("No header found in payload")
-		w.Write([]byte("ok"))
+// handleIMMessage handles the new IM message format (im.message.receive_v1) pushed via webhook
+func (h *FeishuHandlerAITools) handleIMMessage(ctx context.Context, w http.ResponseWriter, body []byte) {
+	if err := h.processIMEvent(ctx, body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("success"))
+}
 
-	eventType := getString(header, "event_type")
-	eventID := getString(header, "event_id")
-	h.logger.Debug("Header info - event_type: %s, event_id: %s", eventType, eventID)
+// processIMEvent 解析 im.message.receive_v1 事件并异步处理消息，webhook 和长连接共用
+// 只有事件体或消息内容无法解析时返回错误，其余被忽略的消息返回 nil
+func (h *FeishuHandlerAITools) processIMEvent(ctx context.Context, body []byte) error {
+	h.logFor(ctx).Debug("=== Processing new IM message format ===")
 
-	// Extract event info
-	event := getMap(payload, "event")
-	if event == nil {
-		h.logger.Debug("No event found in payload, keys: %v", getObjectKeys(payload))
-		w.Write([]byte("ok"))
-		return
+	event, err := decodeIMMessageEvent(body)
+	if err != nil {
+		h.logFor(ctx).Error("Failed to decode IM message event: %v, payload: %s", err, string(body))
+		return err
 	}
+	h.logFor(ctx).Debug("Header info - event_type: %s, event_id: %s", event.Header.EventType, event.Header.EventID)
 
-	// Extract message details
-	message := getMap(event, "message")
-	if message == nil {
-		h.logger.Debug("No message found in event, event keys: %v", getObjectKeys(event))
-		w.Write([]byte("ok"))
-		return
+	if err := event.validate(); err != nil {
+		h.logFor(ctx).Warn("Invalid IM message event skipped: event_id=%s, %v", event.Header.EventID, err)
+		h.logFor(ctx).Debug("Invalid IM message payload: %s", string(body))
+		return nil
 	}
 
-	// Log message basics
-	chatID := getString(message, "chat_id")
-	chatType := getString(message, "chat_type")
-	messageType := getString(message, "message_type")
-	h.logger.Debug("Message info - chat_id: %s, chat_type: %s, message_type: %s", chatID, chatType, messageType)
+	message := &event.Event.Message
+	sender := &event.Event.Sender
 
-	// Extract sender info
-	sender := getMap(event, "sender")
-	if sender == nil {
-		h.logger.Debug("No sender found in message")
-		w.Write([]byte("ok"))
-		return
+	// 长连接推送的事件在这里设置关联 ID，webhook 已在入口处设置
+	if logger.CorrelationID(ctx) == "" {
+		id := event.Header.EventID
+		if id == "" {
+			id = message.MessageID
+		}
+		ctx = logger.WithCorrelationID(ctx, id)
 	}
 
-	senderID := getMap(sender, "sender_id")
-	if senderID == nil {
-		h.logger.Debug("No sender_id found in sender")
-		w.Write([]byte("ok"))
-		return
+	// 飞书在响应慢时会重试推送，同一事件只处理一次
+	if h.isDuplicateEvent(ctx, event.Header.EventID, message.MessageID) {
+		return nil
 	}
 
-	// Get sender details
-	openID := getString(senderID, "open_id")
-	unionID := getString(senderID, "union_id")
-	h.logger.Debug("Sender info - open_id: %s, union_id: %s", openID, unionID)
+	// 配置了独立账本的群聊，账单写入该群的表格
+	ctx = h.withLedger(ctx, message.ChatID)
 
-	if openID == "" {
-		h.logger.Debug("No open_id found in sender")
-		w.Write([]byte("ok"))
-		return
+	// openID 为用户映射使用的 ID，有 union_id 时为 union_id
+	ctx = withSenderIDs(ctx, sender.SenderID)
+	openID := h.feishuUserKey(ctx, sender.SenderID)
+	h.logFor(ctx).Debug("Message info - chat_id: %s, chat_type: %s, message_type: %s", message.ChatID, message.ChatType, message.MessageType)
+	h.logFor(ctx).Debug("Sender info - open_id: %s, union_id: %s, user_key: %s", sender.SenderID.OpenID, sender.SenderID.UnionID, openID)
+	h.logFor(ctx).Debug("Raw content: %s", message.Content)
+
+	// Parse content JSON
+	var contentObj map[string]interface{}
+	if err := json.Unmarshal([]byte(message.Content), &contentObj); err != nil {
+		h.logFor(ctx).Error("Failed to parse message content: %v", err)
+		return fmt.Errorf("parse message content: %w", err)
 	}
 
-	// Get message content (JSON string)
-	content := getString(message, "content")
-	if content == "" {
-		h.logger.Debug("No content found in message")
-		w.Write([]byte("ok"))
-		return
+	// 图片消息：识别收据后记账
+	if message.MessageType == "image" {
+		imageKey := getString(contentObj, "image_key")
+		h.handleMediaMessage(ctx, message, contentObj, imageKey, openID, func(messageID, conversationKey string) {
+			h.processImageMessage(ctx, openID, messageID, imageKey, conversationKey)
+		})
+		return nil
 	}
-	h.logger.Debug("Raw content: %s", content)
 
-	// Parse content JSON
-	var contentObj map[string]interface{}
-	if err := json.Unmarshal([]byte(content), &contentObj); err != nil {
-		h.logger.Error("Failed to parse message content: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	// 语音消息：转写后按文本处理
+	if message.MessageType == "audio" {
+		fileKey := getString(contentObj, "file_key")
+		h.handleMediaMessage(ctx, message, contentObj, fileKey, openID, func(messageID, conversationKey string) {
+			h.processAudioMessage(ctx, openID, messageID, fileKey, conversationKey)
+		})
+		return nil
 	}
 
-	// Extract text
-	text := getString(contentObj, "text")
-	if text == "" {
-		h.logger.Debug("No text found in content, content keys: %v", getObjectKeys(contentObj))
-		w.Write([]byte("ok"))
-		return
+	// 文件消息：导入 CSV 账单
+	if message.MessageType == "file" {
+		fileKey := getString(contentObj, "file_key")
+		fileName := getString(contentObj, "file_name")
+		h.handleMediaMessage(ctx, message, contentObj, fileKey, openID, func(messageID, conversationKey string) {
+			h.processFileMessage(ctx, openID, messageID, fileKey, fileName, conversationKey)
+		})
+		return nil
 	}
-	h.logger.Debug("Extracted text: '%s'", text)
 
-	// Extract thread info and chat type
-	threadID := getString(message, "thread_id")
-	h.logger.Debug("Chat type: %s, thread_id: %s", chatType, threadID)
+	// Extract text (plain text or rich-text post)
+	text := extractMessageText(message.MessageType, contentObj)
+	if text == "" {
+		h.logFor(ctx).Debug("No text found in content, content keys: %v", getObjectKeys(contentObj))
+		return nil
+	}
+	h.logFor(ctx).Debug("Extracted text: '%s'", text)
+	h.logFor(ctx).Debug("Chat type: %s, thread_id: %s", message.ChatType, message.ThreadID)
 
 	// Prepare history for AI
 	var historyMsgs []domain.AIMessage
 	var firstMentioned bool
-	botName := h.config.BotName
+	bot := h.botIdentity(ctx)
 
 	// Handle different chat types
-	switch chatType {
-	case "p2p":
+	switch {
+	case message.ChatType == "p2p":
 		// Private chat - no mention requirement
-		h.logger.Debug("Private chat detected, processing directly")
-	case "group", "pgroup", "sgroup":
-		h.logger.Debug("Group chat detected, checking mentions or thread context")
+		h.logFor(ctx).Debug("Private chat detected, processing directly")
+	case message.isGroup():
+		h.logFor(ctx).Debug("Group chat detected, checking mentions or thread context")
 
-		mentioned, newText := h.checkAndStripMention(text, message, botName)
+		mentioned, newText := h.checkAndStripMention(text, message.Mentions, bot)
 		text = newText
 
 		// Try loading full thread history when thread_id exists
-		if threadID != "" {
-			threadMessages, err := h.feishuService.ListMessagesByThread(threadID)
+		if message.ThreadID != "" {
+			threadMessages, err := h.feishuService.ListMessagesByThread(ctx, message.ThreadID)
 			if err != nil {
-				h.logger.Error("List thread messages failed: %v", err)
+				h.logFor(ctx).Error("List thread messages failed: %v", err)
 			} else {
-				firstMentioned = h.firstMessageMentionsBot(threadMessages, botName)
-				historyMsgs = h.buildAIHistoryFromThread(threadMessages, botName)
-				h.logger.Debug("Loaded %d messages for history, firstMentioned=%v", len(historyMsgs), firstMentioned)
+				firstMentioned = h.firstMessageMentionsBot(threadMessages, bot)
+				historyMsgs = h.buildAIHistoryFromThread(threadMessages, bot)
+				h.logFor(ctx).Debug("Loaded %d messages for history, firstMentioned=%v", len(historyMsgs), firstMentioned)
 			}
 		}
 
 		if !mentioned && !firstMentioned {
-			h.logger.Debug("Bot not mentioned and thread does not start with bot mention, skipping message")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("success"))
-			return
+			h.logFor(ctx).Debug("Bot not mentioned and thread does not start with bot mention, skipping message")
+			return nil
 		}
 
-		h.logger.Debug("Bot mention validated, final text: '%s'", text)
+		h.logFor(ctx).Debug("Bot mention validated, final text: '%s'", text)
 	default:
-		h.logger.Debug("Unknown chat type '%s', still processing", chatType)
+		h.logFor(ctx).Debug("Unknown chat type '%s', still processing", message.ChatType)
 	}
 
-	// Extract message_id for threading
-	messageID := getString(message, "message_id")
-	h.logger.Debug("Message ID: %s", messageID)
+	messageID := message.MessageID
+	h.logFor(ctx).Debug("Message ID: %s", messageID)
+
+	// 用户+话题根消息作为会话 key，用于关联待确认的操作
+	conversationKey := openID + ":" + message.rootID()
 
 	// If we already built history, ensure latest user message text matches incoming text
 	if len(historyMsgs) > 0 && historyMsgs[len(historyMsgs)-1].Role != "assistant" {
@@ -444,10 +801,60 @@ func (h *FeishuHandlerAITools) handleIMMessage(w http.ResponseWriter, payload ma
 	// Process the message
 	// Note: text is from the current webhook message (the latest message in thread),
 	// which will be used as originalMsg for bill recording
-	h.logger.Debug("Processing message for open_id: %s, text: '%s' (this will be recorded as originalMsg)", openID, text)
-	go h.processMessage(openID, text, messageID, historyMsgs)
+	h.logFor(ctx).Debug("Processing message for open_id: %s, text: '%s' (this will be recorded as originalMsg)", openID, text)
+	h.enqueue(ctx, openID, messageID, func() {
+		h.processMessage(ctx, openID, text, messageID, conversationKey, historyMsgs)
+	}, func() { h.replyBusy(ctx, messageID) })
 
-	h.logger.Debug("=== IM message queued for processing ===")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("success"))
+	h.logFor(ctx).Debug("=== IM message queued for processing ===")
+	return nil
+}
+
+// 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别：飞书事件、Telegram 更新和 REST 接口分开设置
+const (
+	feishuLogComponent   = "feishu"
+	telegramLogComponent = "telegram"
+	httpLogComponent     = "http"
+)
+
+// logFor 返回请求上下文中的日志记录器，日志行带有事件的关联 ID
+func (h *FeishuHandlerAITools) logFor(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, feishuLogComponent)
+}
+
+// eventContext 以事件 ID 作为关联 ID，旧版（1.0）事件使用 uuid
+func eventContext(ctx context.Context, payload map[string]interface{}) context.Context {
+	id := getString(getMap(payload, "header"), "event_id")
+	if id == "" {
+		id = getString(payload, "uuid")
+	}
+	return logger.WithCorrelationID(ctx, id)
+}
+
+// detach 创建后台处理用的上下文：随根上下文取消并带有超时，沿用 ctx 中的关联 ID、账本和发送者的原始 ID
+// webhook 返回后请求的 ctx 即被取消，后台处理不能直接使用它
+func (h *FeishuHandlerAITools) detach(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	bg, cancel := context.WithTimeout(h.baseCtx, timeout)
+	bg = domain.WithLedger(bg, domain.LedgerFromContext(ctx))
+	if domain.IsSharedLedger(ctx) {
+		bg = domain.WithSharedLedger(bg)
+	}
+	// 用户映射以 union_id 为键时，管理员判断等仍需比较 open_id
+	if ids := senderIDsFromContext(ctx); ids != (userIDs{}) {
+		bg = withSenderIDs(bg, ids)
+	}
+	return logger.WithCorrelationID(bg, logger.CorrelationID(ctx)), cancel
+}
+
+// withLedger 会话配置了独立账本时，后续的账单操作使用该账本；共享账本的群聊查询汇总所有成员
+func (h *FeishuHandlerAITools) withLedger(ctx context.Context, chatID string) context.Context {
+	if h.config.SharedLedger || slices.Contains(h.config.SharedChats, chatID) {
+		h.logFor(ctx).Debug("Using shared ledger: chat_id=%s", chatID)
+		ctx = domain.WithSharedLedger(ctx)
+	}
+	if _, ok := h.config.ChatTables[chatID]; !ok {
+		return ctx
+	}
+	h.logFor(ctx).Debug("Using ledger of chat: chat_id=%s", chatID)
+	return domain.WithLedger(ctx, chatID)
 }
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
+)
+
+const (
+	// 长连接启动失败后的重试退避区间
+	wsMinBackoff = 5 * time.Second
+	wsMaxBackoff = 5 * time.Minute
+	// wsDialTimeout 建立长连接 TCP 连接的超时时间
+	wsDialTimeout = 30 * time.Second
+)
+
+// errLongConnectionStopped 长连接已停止，不再建立新连接
+var errLongConnectionStopped = errors.New("feishu long connection stopped")
+
+// StartLongConnection 以长连接（WebSocket）模式接收消息、撤回、进入会话事件和卡片回调，无需公网 webhook 地址
+// 连接建立后的断线由 SDK 自动重连；启动失败时按指数退避重试。ctx 取消后关闭连接、不再处理事件并返回
+func (h *FeishuHandlerAITools) StartLongConnection(ctx context.Context) {
+	eventHandler := h.longConnectionDispatcher(ctx)
+
+	// SDK 的客户端没有停止方法，通过拨号记录连接，ctx 取消后关闭，SDK 的收发循环随之退出
+	conns := newWSConnTracker()
+	websocket.DefaultDialer = &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		NetDialContext:   conns.dial,
+	}
+	defer conns.closeAll()
+
+	backoff := wsMinBackoff
+	for {
+		client := larkws.NewClient(h.config.AppID, h.config.AppSecret,
+			larkws.WithEventHandler(eventHandler),
+			larkws.WithLogLevel(larkcore.LogLevelInfo),
+		)
+
+		// 连接成功后 Start 不再返回，只有启动失败时才会收到错误
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- client.Start(ctx)
+		}()
+
+		h.logFor(ctx).Info("Feishu long connection starting")
+
+		select {
+		case <-ctx.Done():
+			h.logFor(ctx).Info("Feishu long connection stopped")
+			return
+		case err := <-errCh:
+			h.logFor(ctx).Error("Feishu long connection failed: %v, retrying in %s", err, backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			h.logFor(ctx).Info("Feishu long connection stopped")
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > wsMaxBackoff {
+			backoff = wsMaxBackoff
+		}
+	}
+}
+
+// longConnectionDispatcher 注册长连接处理的事件和卡片回调，ctx 取消后收到的事件直接忽略
+func (h *FeishuHandlerAITools) longConnectionDispatcher(ctx context.Context) *dispatcher.EventDispatcher {
+	return dispatcher.NewEventDispatcher("", "").
+		OnP2MessageReceiveV1(func(eventCtx context.Context, event *larkim.P2MessageReceiveV1) error {
+			if event.EventReq == nil || ctx.Err() != nil {
+				return nil
+			}
+			return h.processIMEvent(eventCtx, event.EventReq.Body)
+		}).
+		OnP2MessageRecalledV1(func(eventCtx context.Context, event *larkim.P2MessageRecalledV1) error {
+			return h.dispatchLongConnectionEvent(ctx, eventCtx, event.EventReq, h.processRecallEvent)
+		}).
+		OnP2ChatAccessEventBotP2pChatEnteredV1(func(eventCtx context.Context, event *larkim.P2ChatAccessEventBotP2pChatEnteredV1) error {
+			return h.dispatchLongConnectionEvent(ctx, eventCtx, event.EventReq, func(ctx context.Context, payload map[string]interface{}) error {
+				return h.processWelcomeEvent(ctx, "im.chat.access_event.bot_p2p_chat_entered_v1", payload)
+			})
+		}).
+		OnP2ChatMemberBotAddedV1(func(eventCtx context.Context, event *larkim.P2ChatMemberBotAddedV1) error {
+			return h.dispatchLongConnectionEvent(ctx, eventCtx, event.EventReq, func(ctx context.Context, payload map[string]interface{}) error {
+				return h.processWelcomeEvent(ctx, "im.chat.member.bot.added_v1", payload)
+			})
+		}).
+		OnP2CardActionTrigger(func(eventCtx context.Context, event *callback.CardActionTriggerEvent) (*callback.CardActionTriggerResponse, error) {
+			// 与 webhook 相同，返回空响应，卡片通过接口就地更新
+			err := h.dispatchLongConnectionEvent(ctx, eventCtx, event.EventReq, func(ctx context.Context, payload map[string]interface{}) error {
+				h.processCardAction(ctx, payload)
+				return nil
+			})
+			return &callback.CardActionTriggerResponse{}, err
+		})
+}
+
+// dispatchLongConnectionEvent 长连接推送的事件体与 webhook 相同，解析后复用同一处理流程；stopCtx 取消后不再处理
+func (h *FeishuHandlerAITools) dispatchLongConnectionEvent(stopCtx, ctx context.Context, req *larkevent.EventReq, process func(context.Context, map[string]interface{}) error) error {
+	if req == nil || stopCtx.Err() != nil {
+		return nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		h.logFor(ctx).Error("Failed to parse long connection event: %v", err)
+		return err
+	}
+	return process(eventContext(ctx, payload), payload)
+}
+
+// wsConnTracker 记录长连接建立的 TCP 连接，停止后关闭全部连接并拒绝新的连接
+type wsConnTracker struct {
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	stopped bool
+}
+
+func newWSConnTracker() *wsConnTracker {
+	return &wsConnTracker{conns: make(map[net.Conn]struct{})}
+}
+
+// dial 建立连接并记录，连接关闭时移除
+func (t *wsConnTracker) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: wsDialTimeout}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		conn.Close()
+		return nil, errLongConnectionStopped
+	}
+	tracked := &trackedConn{Conn: conn, tracker: t}
+	t.conns[tracked] = struct{}{}
+	return tracked, nil
+}
+
+// closeAll 关闭全部连接，之后的拨号直接失败
+func (t *wsConnTracker) closeAll() {
+	t.mu.Lock()
+	t.stopped = true
+	conns := t.conns
+	t.conns = make(map[net.Conn]struct{})
+	t.mu.Unlock()
+
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+// trackedConn 关闭时从 tracker 中移除的连接
+type trackedConn struct {
+	net.Conn
+	tracker *wsConnTracker
+}
+
+func (c *trackedConn) Close() error {
+	c.tracker.mu.Lock()
+	delete(c.tracker.conns, c)
+	c.tracker.mu.Unlock()
+	return c.Conn.Close()
+}
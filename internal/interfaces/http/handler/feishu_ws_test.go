@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+)
+
+// 停止后已建立的连接被关闭，新的拨号直接失败
+func TestWSConnTrackerCloseAll(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			if _, err := ln.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+
+	tracker := newWSConnTracker()
+	conn, err := tracker.dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 先关闭的连接从记录中移除
+	closed, err := tracker.dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	if n := len(tracker.conns); n != 1 {
+		t.Fatalf("tracking %d connections, want 1", n)
+	}
+
+	tracker.closeAll()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read after closeAll = %v, want net.ErrClosed", err)
+	}
+	if _, err := tracker.dial(context.Background(), "tcp", ln.Addr().String()); !errors.Is(err, errLongConnectionStopped) {
+		t.Errorf("dial after closeAll = %v, want errLongConnectionStopped", err)
+	}
+}
+
+func TestDispatchLongConnectionEventAfterStop(t *testing.T) {
+	h := newIdentityTestHandler(t)
+	req := &larkevent.EventReq{Body: []byte(`{"header": {"event_id": "ev1"}, "event": {}}`)}
+	calls := 0
+	process := func(context.Context, map[string]interface{}) error {
+		calls++
+		return nil
+	}
+
+	stopCtx, stop := context.WithCancel(context.Background())
+	if err := h.dispatchLongConnectionEvent(stopCtx, context.Background(), req, process); err != nil || calls != 1 {
+		t.Fatalf("dispatch = %v, %d calls, want 1", err, calls)
+	}
+	stop()
+	if err := h.dispatchLongConnectionEvent(stopCtx, context.Background(), req, process); err != nil || calls != 1 {
+		t.Errorf("dispatch after stop = %v, %d calls, want the event ignored", err, calls)
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// healthCheckTimeout 单个依赖检查的超时时间
+const healthCheckTimeout = 10 * time.Second
+
+// HealthCheck 一个外部依赖的就绪检查
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// readiness 一次就绪检查的结果
+type readiness struct {
+	Status    string            `json:"status"`            // ok 或 unavailable
+	Checks    map[string]string `json:"checks"`            // 每个依赖的结果，成功为 ok，失败为错误信息
+	Failing   []string          `json:"failing,omitempty"` // 检查失败的依赖
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// HealthHandler 提供存活检查 /health 和就绪检查 /health/ready
+// 就绪检查只在探测请求中执行并缓存结果，不影响消息处理
+type HealthHandler struct {
+	checks []HealthCheck
+	ttl    time.Duration
+	logger logger.Logger
+
+	mu     sync.Mutex
+	result *readiness
+}
+
+// NewHealthHandler creates the health handler; readiness results are cached for ttl
+func NewHealthHandler(ttl time.Duration, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{
+		checks: checks,
+		ttl:    ttl,
+		logger: logger.GetLogger(httpLogComponent),
+	}
+}
+
+// Live 存活检查，只要进程能响应就返回 OK
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// Ready 就绪检查，任一依赖不可用时返回 503 并指出失败的依赖
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	result := h.readiness(r.Context())
+	status := http.StatusOK
+	if len(result.Failing) > 0 {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, result)
+}
+
+// readiness 返回缓存的检查结果，过期后重新检查；并发的探测请求共用同一次检查
+func (h *HealthHandler) readiness(ctx context.Context) *readiness {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.result != nil && time.Since(h.result.CheckedAt) < h.ttl {
+		return h.result
+	}
+
+	result := &readiness{
+		Status:    "ok",
+		Checks:    make(map[string]string, len(h.checks)),
+		CheckedAt: time.Now(),
+	}
+
+	errs := make([]error, len(h.checks))
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			errs[i] = check.Check(checkCtx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, check := range h.checks {
+		if errs[i] != nil {
+			h.logger.Warn("Readiness check failed: %s: %v", check.Name, errs[i])
+			result.Checks[check.Name] = errs[i].Error()
+			result.Failing = append(result.Failing, check.Name)
+			continue
+		}
+		result.Checks[check.Name] = "ok"
+	}
+	if len(result.Failing) > 0 {
+		result.Status = "unavailable"
+	}
+
+	h.result = result
+	return result
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCheck 返回记录调用次数的检查，err 为检查结果
+func countingCheck(name string, calls *atomic.Int32, err error) HealthCheck {
+	return HealthCheck{Name: name, Check: func(context.Context) error {
+		calls.Add(1)
+		return err
+	}}
+}
+
+func getReady(t *testing.T, h *HealthHandler) (int, readiness) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Ready(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	var body readiness
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+func TestHealthReadyFailingDependency(t *testing.T) {
+	var feishuCalls, bitableCalls atomic.Int32
+	h := NewHealthHandler(time.Minute,
+		countingCheck("feishu", &feishuCalls, nil),
+		countingCheck("bitable", &bitableCalls, errors.New("table tbl1 not found")),
+	)
+
+	status, body := getReady(t, h)
+	if status != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Fatalf("status = %d %s, want 503 unavailable", status, body.Status)
+	}
+	if len(body.Failing) != 1 || body.Failing[0] != "bitable" || body.Checks["bitable"] != "table tbl1 not found" || body.Checks["feishu"] != "ok" {
+		t.Errorf("body = %+v, want bitable failing", body)
+	}
+
+	// 缓存有效期内不再重复检查
+	getReady(t, h)
+	if feishuCalls.Load() != 1 || bitableCalls.Load() != 1 {
+		t.Errorf("checks ran %d and %d times, want cached results", feishuCalls.Load(), bitableCalls.Load())
+	}
+}
+
+func TestHealthReadyRecheckAfterTTL(t *testing.T) {
+	var calls atomic.Int32
+	h := NewHealthHandler(time.Millisecond, countingCheck("ai", &calls, nil))
+
+	if status, body := getReady(t, h); status != http.StatusOK || body.Status != "ok" || body.Checks["ai"] != "ok" {
+		t.Fatalf("status = %d, %+v, want 200 ok", status, body)
+	}
+	time.Sleep(5 * time.Millisecond)
+	getReady(t, h)
+	if calls.Load() != 2 {
+		t.Errorf("check ran %d times, want it repeated after the cache expired", calls.Load())
+	}
+}
+
+// 检查超时后返回失败，而不是一直挂起
+func TestHealthReadyTimeout(t *testing.T) {
+	h := NewHealthHandler(time.Minute, HealthCheck{Name: "slow", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	result := h.readiness(ctx)
+	if len(result.Failing) != 1 || result.Failing[0] != "slow" {
+		t.Errorf("result = %+v, want the slow check failing", result)
+	}
+}
+
+func TestHealthLive(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHealthHandler(time.Minute).Live(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+		t.Errorf("live = %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// senderIDsKey is the context key of the Feishu IDs of the message sender
+type senderIDsKey struct{}
+
+// withSenderIDs 把发送者的原始 ID 放入上下文，供需要同时比较 open_id 和 union_id 的地方使用
+func withSenderIDs(ctx context.Context, ids userIDs) context.Context {
+	return context.WithValue(ctx, senderIDsKey{}, ids)
+}
+
+// senderIDsFromContext 返回发送者的原始 ID，非飞书消息时为空
+func senderIDsFromContext(ctx context.Context) userIDs {
+	ids, _ := ctx.Value(senderIDsKey{}).(userIDs)
+	return ids
+}
+
+// feishuUserKey 返回飞书用户在映射中使用的 ID：优先使用 union_id，它在同一开发者的应用之间不变，
+// 重建应用后用户无需重新介绍自己；事件中没有 union_id 时使用 open_id。
+// union_id 还没有映射而 open_id 有时，把映射复制到 union_id 下，原映射保留
+func (h *FeishuHandlerAITools) feishuUserKey(ctx context.Context, ids userIDs) string {
+	if ids.UnionID == "" {
+		return ids.OpenID
+	}
+	if _, err := h.userMappingRepo.GetMapping(domain.PlatformFeishu, ids.UnionID); err == nil || ids.OpenID == "" {
+		return ids.UnionID
+	}
+
+	err := h.userMappingRepo.CopyMapping(domain.PlatformFeishu, ids.OpenID, ids.UnionID)
+	switch {
+	case err == nil:
+		h.logFor(ctx).Info("User mapping migrated to union_id: open_id=%s, union_id=%s", ids.OpenID, ids.UnionID)
+	case errors.Is(err, domain.ErrUserNotFound):
+		// 新用户直接以 union_id 建立映射
+	default:
+		// 迁移失败时本条消息继续使用 open_id 下的映射，下一条消息再尝试
+		h.logFor(ctx).Error("Migrate user mapping to union_id failed: open_id=%s, union_id=%s, err=%v", ids.OpenID, ids.UnionID, err)
+		return ids.OpenID
+	}
+	return ids.UnionID
+}
+
+// sameUser 判断映射 ID 是否属于 open_id 或 union_id 为 ids 的用户
+func sameUser(key string, ids userIDs) bool {
+	return key != "" && (key == ids.OpenID || key == ids.UnionID)
+}
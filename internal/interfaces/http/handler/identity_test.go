@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+)
+
+func newIdentityTestHandler(t *testing.T, admins ...string) *FeishuHandlerAITools {
+	t.Helper()
+	repo, err := repository.NewUserMappingRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &FeishuHandlerAITools{
+		config:          &config.FeishuConfig{AdminOpenIDs: admins},
+		userMappingRepo: repo,
+		baseCtx:         context.Background(),
+	}
+}
+
+func TestFeishuUserKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // 已有映射的 ID
+		ids      userIDs
+		want     string
+	}{
+		{name: "fresh user", ids: userIDs{OpenID: "ou_new", UnionID: "on_new"}, want: "on_new"},
+		{name: "migrated user", existing: "ou_old", ids: userIDs{OpenID: "ou_old", UnionID: "on_old"}, want: "on_old"},
+		{name: "already on union_id", existing: "on_done", ids: userIDs{OpenID: "ou_other_app", UnionID: "on_done"}, want: "on_done"},
+		{name: "no union_id", existing: "ou_only", ids: userIDs{OpenID: "ou_only"}, want: "ou_only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIdentityTestHandler(t)
+			if tt.existing != "" {
+				if err := h.userMappingRepo.SetUserName(domain.PlatformFeishu, tt.existing, "张三"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			key := h.feishuUserKey(context.Background(), tt.ids)
+			if key != tt.want {
+				t.Fatalf("feishuUserKey = %q, want %q", key, tt.want)
+			}
+			if tt.existing == "" {
+				return
+			}
+			// 迁移后两个 ID 都能找到同一个名字
+			for _, id := range []string{tt.ids.OpenID, tt.ids.UnionID} {
+				if id == "" || (id != tt.existing && id != key) {
+					continue
+				}
+				name, err := h.userMappingRepo.GetUserName(domain.PlatformFeishu, id)
+				if err != nil || name != "张三" {
+					t.Errorf("GetUserName(%s) = %q, %v, want 张三", id, name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestIsAdminAfterDetach(t *testing.T) {
+	sender := userIDs{OpenID: "ou_admin", UnionID: "on_admin"}
+	tests := []struct {
+		name   string
+		admins []string
+		want   bool
+	}{
+		{name: "configured by open_id", admins: []string{"ou_admin"}, want: true},
+		{name: "configured by union_id", admins: []string{"on_admin"}, want: true},
+		{name: "not an admin", admins: []string{"ou_someone"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIdentityTestHandler(t, tt.admins...)
+			// 后台处理使用 detach 后的上下文，用户键为 union_id
+			ctx, cancel := h.detach(withSenderIDs(context.Background(), sender), messageTimeout)
+			defer cancel()
+			if got := h.isAdmin(ctx, sender.UnionID); got != tt.want {
+				t.Fatalf("isAdmin = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 其他平台的用户按自己的平台保存，不当作飞书用户
+func TestRenameFuncPlatform(t *testing.T) {
+	h := newIdentityTestHandler(t)
+	ctx := context.Background()
+	openID := domain.PlatformUserID(domain.PlatformTelegram, "42")
+
+	if _, ok := h.getUserNameIfExists(ctx, openID); ok {
+		t.Fatal("unknown telegram user found")
+	}
+	if name, err := h.renameFunc(openID)("李四"); err != nil || name != "李四" {
+		t.Fatalf("rename = %q, %v", name, err)
+	}
+	if name, err := h.userMappingRepo.GetUserName(domain.PlatformTelegram, "42"); err != nil || name != "李四" {
+		t.Errorf("GetUserName(telegram, 42) = %q, %v, want 李四", name, err)
+	}
+	if _, err := h.userMappingRepo.GetUserName(domain.PlatformFeishu, "42"); err == nil {
+		t.Error("telegram user saved as a feishu user")
+	}
+	if name, ok := h.getUserNameIfExists(ctx, openID); !ok || name != "李四" {
+		t.Errorf("getUserNameIfExists = %q, %v, want 李四", name, ok)
+	}
+
+	// 偏好同样按平台保存
+	ctx = h.withPreferences(ctx, openID)
+	if err := domain.PreferenceSaverFromContext(ctx)(domain.UserPreferences{Currency: "$"}); err != nil {
+		t.Fatal(err)
+	}
+	if prefs, _ := h.userMappingRepo.GetPreferences(domain.PlatformTelegram, "42"); prefs.Currency != "$" {
+		t.Errorf("GetPreferences(telegram, 42) = %+v, want currency $", prefs)
+	}
+}
+
+// 多条消息同时处理时并发读取和保存用户名，配合 -race 运行
+func TestRenameFuncConcurrent(t *testing.T) {
+	h := newIdentityTestHandler(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			openID := domain.PlatformUserID(domain.PlatformTelegram, strconv.Itoa(i))
+			for j := 0; j < 20; j++ {
+				if _, err := h.renameFunc(openID)(fmt.Sprintf("用户%d", i)); err != nil {
+					t.Error(err)
+					return
+				}
+				h.getUserNameIfExists(context.Background(), openID)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if list, _ := h.userMappingRepo.ListMappings(); len(list) != 8 {
+		t.Errorf("ListMappings = %d users, want 8", len(list))
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// imMessageEvent im.message.receive_v1 事件（2.0 版本事件结构）
+// 参考 https://open.feishu.cn/document/server-docs/im-v1/message/events/receive
+type imMessageEvent struct {
+	Schema string      `json:"schema"`
+	Header eventHeader `json:"header"`
+	Event  struct {
+		Sender  imSender  `json:"sender"`
+		Message imMessage `json:"message"`
+	} `json:"event"`
+}
+
+// eventHeader 2.0 版本事件的公共头
+type eventHeader struct {
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	CreateTime string `json:"create_time"`
+	Token      string `json:"token"`
+	AppID      string `json:"app_id"`
+	TenantKey  string `json:"tenant_key"`
+}
+
+// userIDs 同一用户的多种 ID
+type userIDs struct {
+	OpenID  string `json:"open_id"`
+	UnionID string `json:"union_id"`
+	UserID  string `json:"user_id"`
+}
+
+// imSender 消息发送者
+type imSender struct {
+	SenderID   userIDs `json:"sender_id"`
+	SenderType string  `json:"sender_type"`
+	TenantKey  string  `json:"tenant_key"`
+}
+
+// imMessage 事件中的消息，content 为 JSON 字符串，结构随 message_type 变化
+type imMessage struct {
+	MessageID   string      `json:"message_id"`
+	RootID      string      `json:"root_id"`
+	ParentID    string      `json:"parent_id"`
+	CreateTime  string      `json:"create_time"`
+	ChatID      string      `json:"chat_id"`
+	ThreadID    string      `json:"thread_id"`
+	ChatType    string      `json:"chat_type"`
+	MessageType string      `json:"message_type"`
+	Content     string      `json:"content"`
+	Mentions    []imMention `json:"mentions"`
+}
+
+// imMention 消息中的 @ 提及，key 为文本中的占位符（如 @_user_1）
+type imMention struct {
+	Key       string  `json:"key"`
+	ID        userIDs `json:"id"`
+	Name      string  `json:"name"`
+	TenantKey string  `json:"tenant_key"`
+}
+
+// decodeIMMessageEvent 解析 im.message.receive_v1 事件体
+func decodeIMMessageEvent(body []byte) (*imMessageEvent, error) {
+	var event imMessageEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("decode im.message.receive_v1: %w", err)
+	}
+	return &event, nil
+}
+
+// validate 检查处理消息必需的字段，返回缺失的字段
+func (e *imMessageEvent) validate() error {
+	var missing []string
+	if e.Event.Sender.SenderID.OpenID == "" {
+		missing = append(missing, "event.sender.sender_id.open_id")
+	}
+	if e.Event.Message.MessageID == "" {
+		missing = append(missing, "event.message.message_id")
+	}
+	if e.Event.Message.MessageType == "" {
+		missing = append(missing, "event.message.message_type")
+	}
+	if e.Event.Message.Content == "" {
+		missing = append(missing, "event.message.content")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// rootID 话题根消息 ID：话题内的回复带有 root_id，话题首条消息用自身 message_id
+func (m *imMessage) rootID() string {
+	if m.RootID != "" {
+		return m.RootID
+	}
+	return m.MessageID
+}
+
+// isGroup 判断消息是否来自群聊
+func (m *imMessage) isGroup() bool {
+	switch m.ChatType {
+	case "group", "pgroup", "sgroup":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadIMMessageEvent(t *testing.T, name string) *imMessageEvent {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := decodeIMMessageEvent(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := event.validate(); err != nil {
+		t.Fatalf("validate %s: %v", name, err)
+	}
+	return event
+}
+
+func TestDecodeIMMessageEventGroup(t *testing.T) {
+	event := loadIMMessageEvent(t, "im_message_group.json")
+	if event.Header.EventID != "5e3702a84e847582be8db7fb73283c02" || event.Header.EventType != "im.message.receive_v1" {
+		t.Errorf("header = %+v", event.Header)
+	}
+	sender := event.Event.Sender.SenderID
+	if sender.OpenID != "ou_84aad35d084aa403a838cf73ee18467" || sender.UnionID != "on_8ed6aa67826108097d9ee143816345" {
+		t.Errorf("sender = %+v", sender)
+	}
+	message := &event.Event.Message
+	if !message.isGroup() || message.rootID() != message.MessageID {
+		t.Errorf("group message: isGroup=%v, rootID=%s", message.isGroup(), message.rootID())
+	}
+	if len(message.Mentions) != 1 || message.Mentions[0].Key != "@_user_1" || message.Mentions[0].ID.OpenID != "ou_bot00000000000000000000000000" {
+		t.Errorf("mentions = %+v", message.Mentions)
+	}
+	if message.Content != `{"text":"@_user_1 午饭 30"}` {
+		t.Errorf("content = %s", message.Content)
+	}
+}
+
+func TestDecodeIMMessageEventP2P(t *testing.T) {
+	message := &loadIMMessageEvent(t, "im_message_p2p.json").Event.Message
+	if message.isGroup() || message.ChatType != "p2p" || len(message.Mentions) != 0 {
+		t.Errorf("p2p message = %+v", message)
+	}
+	if message.rootID() != "om_dc13264520392913993dd051dba21dcf" {
+		t.Errorf("rootID = %s, want the message itself", message.rootID())
+	}
+}
+
+func TestDecodeIMMessageEventThread(t *testing.T) {
+	message := &loadIMMessageEvent(t, "im_message_thread.json").Event.Message
+	if message.rootID() != "om_5ce6d572455d361153b7cb51da133945" || message.ThreadID != "omt_1a2b3c4d5e6f7a8b" {
+		t.Errorf("thread reply: rootID=%s, thread_id=%s", message.rootID(), message.ThreadID)
+	}
+	if message.MessageType != "post" {
+		t.Errorf("message_type = %s, want post", message.MessageType)
+	}
+}
+
+// 缺少必需字段时列出全部缺失的字段
+func TestIMMessageEventValidate(t *testing.T) {
+	event, err := decodeIMMessageEvent([]byte(`{"header": {"event_id": "ev1"}, "event": {"message": {"message_type": "text"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = event.validate()
+	if err == nil {
+		t.Fatal("validate accepted an event without sender and message_id")
+	}
+	for _, field := range []string{"event.sender.sender_id.open_id", "event.message.message_id", "event.message.content"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+	if strings.Contains(err.Error(), "message_type") {
+		t.Errorf("error %q mentions a present field", err)
+	}
+
+	if _, err := decodeIMMessageEvent([]byte(`{"event": []}`)); err == nil {
+		t.Error("decoded a malformed payload")
+	}
+}
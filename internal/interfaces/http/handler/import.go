@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/usecase"
+)
+
+// importConfirmTTL 大批量导入等待用户确认的时间
+const importConfirmTTL = 10 * time.Minute
+
+// importFailureLines 导入结果中每种失败原因最多列出的行号数
+const importFailureLines = 10
+
+// pendingImport 等待用户确认的导入
+type pendingImport struct {
+	FileName string                   `json:"file_name"`
+	UserName string                   `json:"user_name"`
+	Rows     []usecase.ImportRow      `json:"rows"`
+	Failures []usecase.ImportRowError `json:"failures"`
+}
+
+// pendingImportKey 待确认导入的缓存键，与 AI 的待确认操作一样按会话区分
+func pendingImportKey(conversationKey string) string {
+	return "import:" + conversationKey
+}
+
+// processFileMessage 下载用户发送的 CSV 文件并导入账单，行数较多时先请用户确认
+func (h *FeishuHandlerAITools) processFileMessage(ctx context.Context, openID, messageID, fileKey, fileName, conversationKey string) {
+	ctx, cancel := h.detach(ctx, messageTimeout)
+	defer cancel()
+	ctx = domain.WithSourceMessage(ctx, messageID)
+	defer h.markProcessing(ctx, messageID)()
+
+	h.logFor(ctx).Info("Processing file message from %s: message_id=%s, file_name=%s", openID, messageID, fileName)
+
+	replyText := func(text string) {
+		_ = h.feishuService.ReplyMessage(ctx, messageID, text, uuid.New().String())
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+	case ".xlsx", ".xls":
+		replyText("暂只支持导入 CSV 文件，请在 Excel 中将表格另存为“CSV UTF-8”格式后再发送")
+		return
+	default:
+		replyText("暂只支持导入 CSV 格式的账单文件")
+		return
+	}
+
+	userName, hasName := h.getUserNameIfExists(ctx, openID)
+	if !hasName {
+		replyText("导入前请先告诉我你的名字，例如“我叫小明”")
+		return
+	}
+
+	data, err := h.feishuService.GetMessageResource(ctx, messageID, fileKey, "file")
+	if err != nil {
+		h.logFor(ctx).Error("Download file: %v", err)
+		replyText(fmt.Sprintf("文件下载失败：%v", err))
+		return
+	}
+
+	rows, failures, err := usecase.ParseImportCSV(bytes.NewReader(data), h.importConfig.Columns, h.locationFor(ctx))
+	if err != nil {
+		h.logFor(ctx).Warn("Parse import file failed: file_name=%s, err=%v", fileName, err)
+		replyText(fmt.Sprintf("导入失败：%v", err))
+		return
+	}
+	h.logFor(ctx).Info("Parsed import file: file_name=%s, rows=%d, invalid=%d", fileName, len(rows), len(failures))
+	if len(rows) == 0 {
+		replyText("导入失败：没有可以导入的账单" + formatImportFailures(failures))
+		return
+	}
+
+	pending := pendingImport{FileName: fileName, UserName: userName, Rows: rows, Failures: failures}
+	if h.importConfig.ConfirmRows > 0 && len(rows) > h.importConfig.ConfirmRows {
+		if err := h.pendingImports.Set(pendingImportKey(conversationKey), pending, importConfirmTTL); err != nil {
+			h.logFor(ctx).Error("Save pending import: %v", err)
+			replyText(fmt.Sprintf("导入失败：%v", err))
+			return
+		}
+		replyText(fmt.Sprintf("文件“%s”中有 %d 条账单，确认导入吗？请在 %d 分钟内回复“确认”或“取消”%s",
+			fileName, len(rows), int(importConfirmTTL.Minutes()), formatImportFailures(failures)))
+		return
+	}
+
+	replyText(h.runImport(ctx, pending))
+}
+
+// resolvePendingImport 处理对待确认导入的“确认/取消”回复，没有待确认的导入时返回 false
+func (h *FeishuHandlerAITools) resolvePendingImport(ctx context.Context, conversationKey string, confirmed bool) (string, bool) {
+	key := pendingImportKey(conversationKey)
+	pending, ok := h.pendingImports.Get(key)
+	if !ok {
+		return "", false
+	}
+	// 先删除再导入，避免重复回复“确认”时导入两次
+	_ = h.pendingImports.Delete(key)
+
+	if !confirmed {
+		h.logFor(ctx).Info("Import cancelled: file_name=%s, rows=%d", pending.FileName, len(pending.Rows))
+		return fmt.Sprintf("已取消导入“%s”", pending.FileName), true
+	}
+	return h.runImport(ctx, pending), true
+}
+
+// runImport 写入解析出的账单并返回导入结果
+func (h *FeishuHandlerAITools) runImport(ctx context.Context, pending pendingImport) string {
+	inputs := make([]domain.NewBillInput, len(pending.Rows))
+	for i, row := range pending.Rows {
+		inputs[i] = row.Input
+		inputs[i].OriginalMsg = fmt.Sprintf("[导入 %s] 第 %d 行", pending.FileName, row.Line)
+	}
+
+	failures := pending.Failures
+	_, err := h.billUseCase.ImportBills(ctx, pending.UserName, inputs)
+	if batchErr, ok := err.(*domain.BatchCreateError); ok {
+		for i, rowErr := range batchErr.Errors {
+			if rowErr != nil {
+				failures = append(failures, usecase.ImportRowError{Line: pending.Rows[i].Line, Reason: "写入失败"})
+			}
+		}
+	} else if err != nil {
+		h.logFor(ctx).Error("Import bills: %v", err)
+		return fmt.Sprintf("导入失败：%v", err)
+	}
+
+	succeeded := len(pending.Rows) - (len(failures) - len(pending.Failures))
+	h.logFor(ctx).Info("Import finished: file_name=%s, user=%s, succeeded=%d, failed=%d", pending.FileName, pending.UserName, succeeded, len(failures))
+	if len(failures) == 0 {
+		return fmt.Sprintf("导入成功 %d 条", succeeded)
+	}
+	return fmt.Sprintf("导入成功 %d 条，失败 %d 条%s", succeeded, len(failures), formatImportFailures(failures))
+}
+
+// formatImportFailures 按原因汇总失败的行号，如“（第 12/57/88 行：金额无效）”，没有失败时返回空字符串
+func formatImportFailures(failures []usecase.ImportRowError) string {
+	if len(failures) == 0 {
+		return ""
+	}
+
+	lines := make(map[string][]int)
+	var reasons []string
+	for _, f := range failures {
+		if _, ok := lines[f.Reason]; !ok {
+			reasons = append(reasons, f.Reason)
+		}
+		lines[f.Reason] = append(lines[f.Reason], f.Line)
+	}
+
+	parts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		nums := lines[reason]
+		sort.Ints(nums)
+		shown := make([]string, 0, importFailureLines)
+		for i, n := range nums {
+			if i == importFailureLines {
+				break
+			}
+			shown = append(shown, fmt.Sprintf("%d", n))
+		}
+		part := fmt.Sprintf("第 %s 行", strings.Join(shown, "/"))
+		if len(nums) > importFailureLines {
+			part = fmt.Sprintf("第 %s 等 %d 行", strings.Join(shown, "/"), len(nums))
+		}
+		parts = append(parts, part+"："+reason)
+	}
+	return "（" + strings.Join(parts, "；") + "）"
+}
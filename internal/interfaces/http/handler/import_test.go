@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/internal/usecase"
+)
+
+func TestFormatImportFailures(t *testing.T) {
+	if got := formatImportFailures(nil); got != "" {
+		t.Errorf("no failures = %q, want empty", got)
+	}
+
+	// 按原因首次出现的顺序分组，行号升序
+	failures := []usecase.ImportRowError{
+		{Line: 88, Reason: "金额无效"},
+		{Line: 12, Reason: "金额无效"},
+		{Line: 30, Reason: "日期无效"},
+		{Line: 57, Reason: "金额无效"},
+	}
+	if got, want := formatImportFailures(failures), "（第 12/57/88 行：金额无效；第 30 行：日期无效）"; got != want {
+		t.Errorf("formatImportFailures = %q, want %q", got, want)
+	}
+
+	// 超过上限时只列出前几行
+	var many []usecase.ImportRowError
+	for line := 2; line <= 13; line++ {
+		many = append(many, usecase.ImportRowError{Line: line, Reason: "写入失败"})
+	}
+	if got, want := formatImportFailures(many), "（第 2/3/4/5/6/7/8/9/10/11 等 12 行：写入失败）"; got != want {
+		t.Errorf("formatImportFailures = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,437 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
+)
+
+// replyKey 处理器直接回复的模板键，AI 回复的模板在 ai 包中
+type replyKey string
+
+const (
+	replyAIFailed            replyKey = "ai_failed"
+	replyBusy                replyKey = "busy"
+	replyVoiceDownloadFailed replyKey = "voice_download_failed"
+	replyVoiceFailed         replyKey = "voice_failed"
+	replyVoiceEmpty          replyKey = "voice_empty"
+	replyVoiceTranscript     replyKey = "voice_transcript"
+	replyImageDownloadFailed replyKey = "image_download_failed"
+
+	replyUsageToday        replyKey = "usage_today"
+	replyUsageWeek         replyKey = "usage_week"
+	replyUsageMonth        replyKey = "usage_month"
+	replyUsageUndo         replyKey = "usage_undo"
+	replyUsageRestore      replyKey = "usage_restore"
+	replyUsageSettings     replyKey = "usage_settings"
+	replyUsageExport       replyKey = "usage_export"
+	replyUsageDeleteData   replyKey = "usage_delete_data"
+	replyUsageVersion      replyKey = "usage_version"
+	replyUsageAdmin        replyKey = "usage_admin"
+	replyHelpTitle         replyKey = "help_title"
+	replyHelpLine          replyKey = "help_line"
+	replyHelpSelf          replyKey = "help_self"
+	replyHelpFooter        replyKey = "help_footer"
+	replyQueryFailed       replyKey = "query_failed"
+	replyRangeToday        replyKey = "range_today"
+	replyRangeWeek         replyKey = "range_week"
+	replyRangeMonth        replyKey = "range_month"
+	replyRangeHeader       replyKey = "range_header"
+	replyRangeTotals       replyKey = "range_totals"
+	replyRangeEmpty        replyKey = "range_empty"
+	replyRangeCount        replyKey = "range_count"
+	replyRangeTop          replyKey = "range_top"
+	replyRangeAll          replyKey = "range_all"
+	replyRangeItem         replyKey = "range_item"
+	replyNothingToUndo     replyKey = "nothing_to_undo"
+	replyUndoUnsupported   replyKey = "undo_unsupported"
+	replyUndoFailed        replyKey = "undo_failed"
+	replyUndoRestoreHint   replyKey = "undo_restore_hint"
+	replyUndoCreate        replyKey = "undo_create"
+	replyUndoDelete        replyKey = "undo_delete"
+	replyUndoRecreated     replyKey = "undo_recreated"
+	replyUndoUpdate        replyKey = "undo_update"
+	replyUndoChange        replyKey = "undo_change"
+	replyBillSummary       replyKey = "bill_summary"
+	replyFieldDescription  replyKey = "field_description"
+	replyFieldAmount       replyKey = "field_amount"
+	replyFieldType         replyKey = "field_type"
+	replyFieldCategory     replyKey = "field_category"
+	replyFieldDate         replyKey = "field_date"
+	replyFieldAccount      replyKey = "field_account"
+	replyFieldTags         replyKey = "field_tags"
+	replyFieldReimbursable replyKey = "field_reimbursable"
+	replyTypeIncome        replyKey = "type_income"
+	replyTypeExpense       replyKey = "type_expense"
+	replyYes               replyKey = "yes"
+	replyNo                replyKey = "no"
+	replyRestoreUsage      replyKey = "restore_usage"
+	replySoftDeleteOff     replyKey = "soft_delete_off"
+	replyRestoreNotFound   replyKey = "restore_not_found"
+	replyRestoreFailed     replyKey = "restore_failed"
+	replyRestored          replyKey = "restored"
+	replyRestoredBill      replyKey = "restored_bill"
+	replySettingsNoValue   replyKey = "settings_no_value"
+	replySettingsInvalid   replyKey = "settings_invalid"
+	replySettingsFailed    replyKey = "settings_failed"
+	replySettingsSaved     replyKey = "settings_saved"
+	replySettingsTitle     replyKey = "settings_title"
+	replySettingsCurrency  replyKey = "settings_currency"
+	replySettingsTimezone  replyKey = "settings_timezone"
+	replySettingsLanguage  replyKey = "settings_language"
+	replySettingsTopN      replyKey = "settings_top_n"
+	replySettingsExample   replyKey = "settings_example"
+	replySettingsDefault   replyKey = "settings_default"
+	replyVersion           replyKey = "version"
+	replyVersionCommit     replyKey = "version_commit"
+	replyVersionBuildTime  replyKey = "version_build_time"
+	replyVersionGo         replyKey = "version_go"
+	replyVersionUptime     replyKey = "version_uptime"
+	replyUptimeDays        replyKey = "uptime_days"
+	replyUptimeHours       replyKey = "uptime_hours"
+	replyUptimeMinutes     replyKey = "uptime_minutes"
+
+	replyAdminOnly          replyKey = "admin_only"
+	replyAdminUsage         replyKey = "admin_usage"
+	replyAdminRenameUsage   replyKey = "admin_rename_usage"
+	replyAdminForgetUsage   replyKey = "admin_forget_usage"
+	replyAdminListFailed    replyKey = "admin_list_failed"
+	replyAdminNoUsers       replyKey = "admin_no_users"
+	replyAdminUsers         replyKey = "admin_users"
+	replyAdminNameTaken     replyKey = "admin_name_taken"
+	replyAdminRenameFailed  replyKey = "admin_rename_failed"
+	replyAdminRenamed       replyKey = "admin_renamed"
+	replyAdminUserNotFound  replyKey = "admin_user_not_found"
+	replyAdminAmbiguousUser replyKey = "admin_ambiguous_user"
+	replyAdminForgetFailed  replyKey = "admin_forget_failed"
+	replyAdminForgot        replyKey = "admin_forgot"
+	replyAdminForgotName    replyKey = "admin_forgot_name"
+	replyAdminForgotRecords replyKey = "admin_forgot_records"
+
+	replyExportUnsupported  replyKey = "export_unsupported"
+	replyExportFailed       replyKey = "export_failed"
+	replyExportNoData       replyKey = "export_no_data"
+	replyExportSendFailed   replyKey = "export_send_failed"
+	replyExportDone         replyKey = "export_done"
+	replyDeleteUnavailable  replyKey = "delete_unavailable"
+	replyDeleteNeedsChat    replyKey = "delete_needs_chat"
+	replyDeleteFailed       replyKey = "delete_failed"
+	replyDeleteIntro        replyKey = "delete_intro"
+	replyDeleteNamedProfile replyKey = "delete_named_profile"
+	replyDeleteProfile      replyKey = "delete_profile"
+	replyDeleteSessions     replyKey = "delete_sessions"
+	replyDeleteRecordsLater replyKey = "delete_records_later"
+	replyDeleteConfirm      replyKey = "delete_confirm"
+	replyDeleteCancelled    replyKey = "delete_cancelled"
+	replyDeleteRecordsKept  replyKey = "delete_records_kept"
+	replyDeleteCountFailed  replyKey = "delete_count_failed"
+	replyDeleteSaveFailed   replyKey = "delete_save_failed"
+	replyDeleteAskRecords   replyKey = "delete_ask_records"
+	replyDeleteBillsFailed  replyKey = "delete_bills_failed"
+	replyDeleteSoftDeleted  replyKey = "delete_soft_deleted"
+	replyDeletedSummary     replyKey = "deleted_summary"
+	replyDeletedSeparator   replyKey = "deleted_separator"
+	replyDeletedNamed       replyKey = "deleted_named"
+	replyDeletedProfile     replyKey = "deleted_profile"
+	replyDeletedTemplates   replyKey = "deleted_templates"
+	replyDeletedJournal     replyKey = "deleted_journal"
+	replyDeletedSessions    replyKey = "deleted_sessions"
+	replyDeletedRecords     replyKey = "deleted_records"
+)
+
+// replyCatalog 各语言的回复模板，未翻译的键退回中文
+var replyCatalog = map[string]map[replyKey]string{
+	ai.LanguageZH: {
+		replyAIFailed:            "AI处理失败：%v",
+		replyBusy:                "系统繁忙，请稍后再试",
+		replyVoiceDownloadFailed: "语音下载失败：%v",
+		replyVoiceFailed:         "语音识别失败：%v",
+		replyVoiceEmpty:          "没有识别到语音内容，请再说一遍或直接发送文字",
+		replyVoiceTranscript:     "🎤 识别内容：%s\n\n%s",
+		replyImageDownloadFailed: "图片下载失败：%v",
+
+		replyUsageToday:        "查看今天的收支",
+		replyUsageWeek:         "查看本周的收支",
+		replyUsageMonth:        "查看本月的收支",
+		replyUsageUndo:         "撤销最近一次操作（新建、删除或修改）",
+		replyUsageRestore:      "恢复已删除的账单，后接记录 ID，如 恢复 recXXX（需开启软删除）",
+		replyUsageSettings:     "查看或修改个人设置，如 设置 时区 America/New_York、设置 货币 $、设置 语言 en、设置 条数 10",
+		replyUsageExport:       "以 zip 文件导出机器人保存的你的名字、设置和你记录的全部账单",
+		replyUsageDeleteData:   "删除机器人保存的你的名字、设置等数据，并可选择删除你记录的账单（需两次确认）",
+		replyUsageVersion:      "查看机器人的版本、构建时间和运行时长",
+		replyUsageAdmin:        "管理用户（仅限管理员）",
+		replyHelpTitle:         "快捷命令（不经过 AI，响应更快）：",
+		replyHelpLine:          "\n  %s%s　%s",
+		replyHelpSelf:          "显示本帮助",
+		replyHelpFooter:        "\n其他内容直接发送即可，例如“午饭 30”“本月餐饮花了多少”",
+		replyQueryFailed:       "查询失败：%v",
+		replyRangeToday:        "今天",
+		replyRangeWeek:         "本周",
+		replyRangeMonth:        "本月",
+		replyRangeHeader:       "📊 %s（%s）\n",
+		replyRangeTotals:       "💸 支出 %s　💰 收入 %s　结余 %s",
+		replyRangeEmpty:        "\n暂无记录",
+		replyRangeCount:        "\n共 %d 笔",
+		replyRangeTop:          "，金额最大的 %d 笔：",
+		replyRangeAll:          "：",
+		replyRangeItem:         "\n  • %s %s %s%s（%s）",
+		replyNothingToUndo:     "没有可以撤销的操作",
+		replyUndoUnsupported:   "上一步已经是撤销，不支持撤销“撤销”",
+		replyUndoFailed:        "撤销失败：%v",
+		replyUndoRestoreHint:   "\n误删可在 %d 天内发送 %s恢复 %s 找回",
+		replyUndoCreate:        "↩️ 已撤销新建的记录：",
+		replyUndoDelete:        "♻️ 已恢复删除的记录：",
+		replyUndoRecreated:     "\n原记录无法恢复，已按原内容重新创建：",
+		replyUndoUpdate:        "↩️ 已撤销对「%s」的修改：",
+		replyUndoChange:        "\n  • %s：%s → %s",
+		replyBillSummary:       "%s %s（%s，%s）",
+		replyFieldDescription:  "描述",
+		replyFieldAmount:       "金额",
+		replyFieldType:         "类型",
+		replyFieldCategory:     "分类",
+		replyFieldDate:         "日期",
+		replyFieldAccount:      "账户",
+		replyFieldTags:         "标签",
+		replyFieldReimbursable: "可报销",
+		replyTypeIncome:        "收入",
+		replyTypeExpense:       "支出",
+		replyYes:               "是",
+		replyNo:                "否",
+		replyRestoreUsage:      "请在命令后附上要恢复的记录 ID，例如：%s恢复 recXXXX",
+		replySoftDeleteOff:     "未开启软删除，已删除的记录无法恢复",
+		replyRestoreNotFound:   "没有找到可恢复的记录 %s，可能已超过保留期被彻底删除",
+		replyRestoreFailed:     "恢复失败：%v",
+		replyRestored:          "♻️ 已恢复记录 %s",
+		replyRestoredBill:      "♻️ 已恢复：%s %s（%s，%s）",
+		replySettingsNoValue:   "请在设置项后附上新的值，例如 %s设置 %s 默认",
+		replySettingsInvalid:   "⚠️ 无法修改设置：%v\n可以设置货币、时区、语言和条数，例如 %s设置 时区 Asia/Shanghai",
+		replySettingsFailed:    "保存设置失败：%v",
+		replySettingsSaved:     "⚙️ 设置已保存\n",
+		replySettingsTitle:     "⚙️ 个人设置：",
+		replySettingsCurrency:  "\n  货币符号：%s",
+		replySettingsTimezone:  "\n  时区：%s",
+		replySettingsLanguage:  "\n  回复语言：%s",
+		replySettingsTopN:      "\n  查询明细条数：%s",
+		replySettingsExample:   "\n修改示例：%[1]s设置 货币 $；恢复默认：%[1]s设置 货币 默认",
+		replySettingsDefault:   "默认",
+		replyVersion:           "🤖 版本：%s",
+		replyVersionCommit:     "\n  提交：%s",
+		replyVersionBuildTime:  "\n  构建时间：%s",
+		replyVersionGo:         "\n  Go：%s",
+		replyVersionUptime:     "\n  已运行：%s",
+		replyUptimeDays:        "%d 天 %d 小时 %d 分钟",
+		replyUptimeHours:       "%d 小时 %d 分钟",
+		replyUptimeMinutes:     "%d 分钟",
+
+		replyAdminOnly: "抱歉，该命令仅限管理员使用",
+		replyAdminUsage: `管理命令：
+%[1]sadmin users：列出已设置名字的用户
+%[1]sadmin rename <open_id> <名字>：修改用户的名字
+%[1]sadmin forget <open_id> [--records]：删除用户的名字和设置，带 --records 时同时删除其最近 %[2]d 天的账单
+open_id 可以只写 users 列出的尾部，只要能唯一确定用户`,
+		replyAdminRenameUsage:   "用法：%sadmin rename <open_id> <名字>",
+		replyAdminForgetUsage:   "用法：%sadmin forget <open_id> [--records]",
+		replyAdminListFailed:    "查询用户失败：%v",
+		replyAdminNoUsers:       "还没有用户设置名字",
+		replyAdminUsers:         "👥 共 %d 位用户：",
+		replyAdminNameTaken:     "「%s」已被其他用户使用，试试「%s」",
+		replyAdminRenameFailed:  "修改名字失败：%v",
+		replyAdminRenamed:       "✅ 已将 …%s 的名字改为 %s",
+		replyAdminUserNotFound:  "没有找到用户 %s",
+		replyAdminAmbiguousUser: "%s 匹配到多位用户，请写出更长的 open_id",
+		replyAdminForgetFailed:  "删除用户失败：%v",
+		replyAdminForgot:        "🗑️ 已删除用户 …%s",
+		replyAdminForgotName:    "（%s）",
+		replyAdminForgotRecords: "，以及其最近 %d 天的 %d 条账单",
+
+		replyExportUnsupported:  "当前平台暂不支持发送文件，请联系管理员通过 /api/v1/users/{openID}/export 接口导出",
+		replyExportFailed:       "导出失败：%v",
+		replyExportNoData:       "我还没有保存你的任何数据",
+		replyExportSendFailed:   "发送文件失败：%v",
+		replyExportDone:         "📎 已导出你的个人数据，请下载上方的压缩包：profile.json 为名字和个人设置，bills.csv 为你记录的 %d 条账单",
+		replyDeleteUnavailable:  "当前无法删除个人数据",
+		replyDeleteNeedsChat:    "当前无法删除个人数据，请在与机器人的对话中发送此命令",
+		replyDeleteFailed:       "删除失败：%v",
+		replyDeleteIntro:        "⚠️ 将删除我保存的你的以下数据：\n",
+		replyDeleteNamedProfile: "- 名字（%s）和个人设置\n- 账单模板和撤销记录\n",
+		replyDeleteProfile:      "- 个人设置\n",
+		replyDeleteSessions:     "- 进行中的确认、翻页等会话状态\n",
+		replyDeleteRecordsLater: "表格中你记录的账单会在下一步单独确认。\n",
+		replyDeleteConfirm:      "删除后无法恢复，确认删除请在 %d 分钟内回复“确认”，回复“取消”放弃",
+		replyDeleteCancelled:    "已取消，你的数据没有被删除",
+		replyDeleteRecordsKept:  "\n表格中的账单已保留",
+		replyDeleteCountFailed:  "\n查询表格中的账单失败：%v",
+		replyDeleteSaveFailed:   "\n暂时无法删除表格中的账单：%v",
+		replyDeleteAskRecords:   "\n\n表格中还有你（%s）记录的 %d 条账单，是否一并删除？其他成员的账单不受影响。\n删除后无法恢复，确认请在 %d 分钟内回复“确认”，回复“取消”保留账单",
+		replyDeleteBillsFailed:  "\n删除账单失败：%v",
+		replyDeleteSoftDeleted:  "\n已开启软删除，账单将在 %d 天后彻底清除",
+		replyDeletedSummary:     "🗑️ 已删除：",
+		replyDeletedSeparator:   "、",
+		replyDeletedNamed:       "名字（%s）和个人设置",
+		replyDeletedProfile:     "个人设置",
+		replyDeletedTemplates:   "%d 个账单模板",
+		replyDeletedJournal:     "撤销记录",
+		replyDeletedSessions:    "会话状态",
+		replyDeletedRecords:     "表格中的 %d 条账单",
+	},
+	ai.LanguageEN: {
+		replyAIFailed:            "AI processing failed: %v",
+		replyBusy:                "The system is busy, please try again later",
+		replyVoiceDownloadFailed: "Failed to download the voice message: %v",
+		replyVoiceFailed:         "Failed to transcribe the voice message: %v",
+		replyVoiceEmpty:          "No speech was recognized, please say it again or send text instead",
+		replyVoiceTranscript:     "🎤 Heard: %s\n\n%s",
+		replyImageDownloadFailed: "Failed to download the image: %v",
+
+		replyUsageToday:        "show today's income and expenses",
+		replyUsageWeek:         "show this week's income and expenses",
+		replyUsageMonth:        "show this month's income and expenses",
+		replyUsageUndo:         "undo the last create, delete or update",
+		replyUsageRestore:      "restore a deleted bill by record ID, e.g. 恢复 recXXX (requires soft delete)",
+		replyUsageSettings:     "show or change your settings, e.g. 设置 时区 America/New_York, 设置 货币 $, 设置 语言 en, 设置 条数 10",
+		replyUsageExport:       "export your name, settings and all bills you recorded as a zip file",
+		replyUsageDeleteData:   "delete your name, settings and other data, optionally with the bills you recorded (asks twice)",
+		replyUsageVersion:      "show the bot's version, build time and uptime",
+		replyUsageAdmin:        "manage users (admins only)",
+		replyHelpTitle:         "Quick commands (answered without AI, faster):",
+		replyHelpLine:          "\n  %s%s　%s",
+		replyHelpSelf:          "show this help",
+		replyHelpFooter:        "\nAnything else can be sent as is, e.g. \"lunch 30\" or \"how much did I spend on food this month\"",
+		replyQueryFailed:       "Query failed: %v",
+		replyRangeToday:        "Today",
+		replyRangeWeek:         "This week",
+		replyRangeMonth:        "This month",
+		replyRangeHeader:       "📊 %s (%s)\n",
+		replyRangeTotals:       "💸 Expenses %s　💰 Income %s　Net %s",
+		replyRangeEmpty:        "\nNo records",
+		replyRangeCount:        "\n%d records",
+		replyRangeTop:          ", the %d largest:",
+		replyRangeAll:          ":",
+		replyRangeItem:         "\n  • %s %s %s%s (%s)",
+		replyNothingToUndo:     "Nothing to undo",
+		replyUndoUnsupported:   "The last operation was an undo, which cannot be undone",
+		replyUndoFailed:        "Undo failed: %v",
+		replyUndoRestoreHint:   "\nIf this was a mistake, send %[2]s恢复 %[3]s within %[1]d days to get it back",
+		replyUndoCreate:        "↩️ Undid the new record: ",
+		replyUndoDelete:        "♻️ Restored the deleted record: ",
+		replyUndoRecreated:     "\nThe original record could not be restored and was recreated as: ",
+		replyUndoUpdate:        "↩️ Undid the changes to \"%s\":",
+		replyUndoChange:        "\n  • %s: %s → %s",
+		replyBillSummary:       "%s %s (%s, %s)",
+		replyFieldDescription:  "description",
+		replyFieldAmount:       "amount",
+		replyFieldType:         "type",
+		replyFieldCategory:     "category",
+		replyFieldDate:         "date",
+		replyFieldAccount:      "account",
+		replyFieldTags:         "tags",
+		replyFieldReimbursable: "reimbursable",
+		replyTypeIncome:        "income",
+		replyTypeExpense:       "expense",
+		replyYes:               "yes",
+		replyNo:                "no",
+		replyRestoreUsage:      "Please add the record ID to restore, e.g. %s恢复 recXXXX",
+		replySoftDeleteOff:     "Soft delete is off, deleted records cannot be restored",
+		replyRestoreNotFound:   "No restorable record %s, it may have been purged after the retention period",
+		replyRestoreFailed:     "Restore failed: %v",
+		replyRestored:          "♻️ Restored record %s",
+		replyRestoredBill:      "♻️ Restored: %s %s (%s, %s)",
+		replySettingsNoValue:   "Please add the new value after the setting, e.g. %s设置 %s 默认",
+		replySettingsInvalid:   "⚠️ Cannot change the setting: %v\nYou can set 货币, 时区, 语言 and 条数, e.g. %s设置 时区 Asia/Shanghai",
+		replySettingsFailed:    "Failed to save the settings: %v",
+		replySettingsSaved:     "⚙️ Settings saved\n",
+		replySettingsTitle:     "⚙️ Your settings:",
+		replySettingsCurrency:  "\n  Currency symbol: %s",
+		replySettingsTimezone:  "\n  Time zone: %s",
+		replySettingsLanguage:  "\n  Reply language: %s",
+		replySettingsTopN:      "\n  Records per query: %s",
+		replySettingsExample:   "\nExample: %[1]s设置 货币 $; reset: %[1]s设置 货币 默认",
+		replySettingsDefault:   "default",
+		replyVersion:           "🤖 Version: %s",
+		replyVersionCommit:     "\n  Commit: %s",
+		replyVersionBuildTime:  "\n  Built: %s",
+		replyVersionGo:         "\n  Go: %s",
+		replyVersionUptime:     "\n  Uptime: %s",
+		replyUptimeDays:        "%dd %dh %dm",
+		replyUptimeHours:       "%dh %dm",
+		replyUptimeMinutes:     "%dm",
+
+		replyAdminOnly: "Sorry, this command is for admins only",
+		replyAdminUsage: `Admin commands:
+%[1]sadmin users: list users with a name
+%[1]sadmin rename <open_id> <name>: change a user's name
+%[1]sadmin forget <open_id> [--records]: delete a user's name and settings, with --records also their bills of the last %[2]d days
+open_id may be the suffix shown by users as long as it matches one user`,
+		replyAdminRenameUsage:   "Usage: %sadmin rename <open_id> <name>",
+		replyAdminForgetUsage:   "Usage: %sadmin forget <open_id> [--records]",
+		replyAdminListFailed:    "Failed to list users: %v",
+		replyAdminNoUsers:       "No user has set a name yet",
+		replyAdminUsers:         "👥 %d users:",
+		replyAdminNameTaken:     "\"%s\" is used by another user, try \"%s\"",
+		replyAdminRenameFailed:  "Rename failed: %v",
+		replyAdminRenamed:       "✅ Renamed …%s to %s",
+		replyAdminUserNotFound:  "User %s not found",
+		replyAdminAmbiguousUser: "%s matches several users, please give a longer open_id",
+		replyAdminForgetFailed:  "Failed to delete the user: %v",
+		replyAdminForgot:        "🗑️ Deleted user …%s",
+		replyAdminForgotName:    " (%s)",
+		replyAdminForgotRecords: ", with their %[2]d bills of the last %[1]d days",
+
+		replyExportUnsupported:  "This platform cannot send files yet, please ask an admin to export through /api/v1/users/{openID}/export",
+		replyExportFailed:       "Export failed: %v",
+		replyExportNoData:       "I have not stored any of your data",
+		replyExportSendFailed:   "Failed to send the file: %v",
+		replyExportDone:         "📎 Your data is exported, please download the zip above: profile.json holds your name and settings, bills.csv the %d bills you recorded",
+		replyDeleteUnavailable:  "Your data cannot be deleted right now",
+		replyDeleteNeedsChat:    "Your data cannot be deleted here, please send this command in a chat with the bot",
+		replyDeleteFailed:       "Delete failed: %v",
+		replyDeleteIntro:        "⚠️ I will delete the following data I keep about you:\n",
+		replyDeleteNamedProfile: "- your name (%s) and settings\n- bill templates and undo history\n",
+		replyDeleteProfile:      "- your settings\n",
+		replyDeleteSessions:     "- pending confirmations, paging and other conversation state\n",
+		replyDeleteRecordsLater: "The bills you recorded in the table are confirmed separately in the next step.\n",
+		replyDeleteConfirm:      "This cannot be undone. Reply 'confirm' within %d minutes to delete, or 'cancel' to keep your data",
+		replyDeleteCancelled:    "Cancelled, your data was not deleted",
+		replyDeleteRecordsKept:  "\nThe bills in the table were kept",
+		replyDeleteCountFailed:  "\nFailed to count your bills in the table: %v",
+		replyDeleteSaveFailed:   "\nYour bills in the table cannot be deleted right now: %v",
+		replyDeleteAskRecords:   "\n\nThe table still has %[2]d bills recorded by you (%[1]s). Delete them too? Bills of other members are not affected.\nThis cannot be undone. Reply 'confirm' within %[3]d minutes to delete, or 'cancel' to keep the bills",
+		replyDeleteBillsFailed:  "\nFailed to delete the bills: %v",
+		replyDeleteSoftDeleted:  "\nSoft delete is on, the bills are purged after %d days",
+		replyDeletedSummary:     "🗑️ Deleted: ",
+		replyDeletedSeparator:   ", ",
+		replyDeletedNamed:       "your name (%s) and settings",
+		replyDeletedProfile:     "your settings",
+		replyDeletedTemplates:   "%d bill templates",
+		replyDeletedJournal:     "undo history",
+		replyDeletedSessions:    "conversation state",
+		replyDeletedRecords:     "%d bills in the table",
+	},
+}
+
+// localize 按语言渲染回复模板，不支持的语言使用中文
+func localize(lang string, key replyKey, args ...interface{}) string {
+	tmpl, ok := replyCatalog[lang][key]
+	if !ok {
+		tmpl = replyCatalog[ai.LanguageZH][key]
+	}
+	if len(args) == 0 {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// languageFor 返回回复使用的语言，用户设置过时优先使用
+func (h *FeishuHandlerAITools) languageFor(ctx context.Context) string {
+	if lang := domain.PreferencesFromContext(ctx).Language; lang != "" {
+		return lang
+	}
+	return h.language
+}
+
+// text 按用户的语言渲染回复模板
+func (h *FeishuHandlerAITools) text(ctx context.Context, key replyKey, args ...interface{}) string {
+	return localize(h.languageFor(ctx), key, args...)
+}
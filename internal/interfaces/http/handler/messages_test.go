@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
+	"github.com/wyg1997/LedgerBot/pkg/version"
+)
+
+// verbPattern 匹配模板中的格式化动词，可带参数序号
+var verbPattern = regexp.MustCompile(`%(?:\[(\d+)\])?([a-z])`)
+
+// templateArgs 按模板中的动词构造参数，%d 为整数，其他为字符串
+func templateArgs(tmpl string) []interface{} {
+	var args []interface{}
+	next := 0
+	for _, m := range verbPattern.FindAllStringSubmatch(tmpl, -1) {
+		if m[1] != "" {
+			next, _ = strconv.Atoi(m[1])
+		} else {
+			next++
+		}
+		for len(args) < next {
+			args = append(args, nil)
+		}
+		if m[2] == "d" {
+			args[next-1] = 1
+		} else {
+			args[next-1] = "x"
+		}
+	}
+	return args
+}
+
+// 每种语言都翻译了全部模板，参数个数与中文一致
+func TestReplyCatalogComplete(t *testing.T) {
+	zh := replyCatalog[ai.LanguageZH]
+	for lang, catalog := range replyCatalog {
+		if len(catalog) != len(zh) {
+			t.Errorf("%s has %d templates, want %d", lang, len(catalog), len(zh))
+		}
+		for key, tmpl := range zh {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("%s misses %s", lang, key)
+				continue
+			}
+			args := templateArgs(tmpl)
+			if got := localize(lang, key, args...); strings.Contains(got, "%!") {
+				t.Errorf("%s %s = %q with %d args", lang, key, got, len(args))
+			}
+		}
+	}
+}
+
+func TestTextLanguage(t *testing.T) {
+	h := newIdentityTestHandler(t)
+	h.language = ai.LanguageEN
+
+	// 未设置语言的用户使用配置的语言，设置过的优先
+	if got := h.text(context.Background(), replyBusy); got != replyCatalog[ai.LanguageEN][replyBusy] {
+		t.Errorf("busy reply = %q, want the English one", got)
+	}
+	ctx := domain.WithPreferences(context.Background(), domain.UserPreferences{Language: ai.LanguageZH}, nil)
+	if got := h.text(ctx, replyBusy); got != "系统繁忙，请稍后再试" {
+		t.Errorf("busy reply = %q, want the user's language", got)
+	}
+	// 不支持的语言使用中文
+	if got := localize("fr", replyNothingToUndo); got != "没有可以撤销的操作" {
+		t.Errorf("localize(fr) = %q, want Chinese", got)
+	}
+}
+
+func TestFormatHelpEnglish(t *testing.T) {
+	help := formatHelp(ai.LanguageEN, "/")
+	if !strings.HasPrefix(help, "Quick commands") || !strings.Contains(help, "/今天　show today's income and expenses") {
+		t.Errorf("help = %q, want English descriptions with the command names", help)
+	}
+	if regexp.MustCompile(`　[^\x00-\x7f]`).MatchString(help) {
+		t.Errorf("help has untranslated descriptions: %q", help)
+	}
+}
+
+func TestFormatUndoEnglish(t *testing.T) {
+	bill := &domain.Bill{RecordID: "rec1", Description: "lunch", Amount: 35, Category: "food", Date: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	result := &domain.UndoResult{Bill: bill, Undone: &domain.Operation{Kind: domain.OperationCreate, RecordID: "rec1"}}
+	if got, want := formatUndo(ai.LanguageEN, result, "$"), "↩️ Undid the new record: lunch $35.00 (food, 2025-06-01)"; got != want {
+		t.Errorf("formatUndo = %q, want %q", got, want)
+	}
+}
+
+func TestFormatVersionEnglish(t *testing.T) {
+	got := formatVersion(ai.LanguageEN, version.Info{Version: "v1.2.3", GoVersion: "go1.21"}, 26*time.Hour+5*time.Minute)
+	for _, want := range []string{"Version: v1.2.3", "Commit: -", "Uptime: 1d 2h 5m"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatVersion = %q, want %q", got, want)
+		}
+	}
+}
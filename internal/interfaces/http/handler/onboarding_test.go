@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
+)
+
+// recordingBillUseCase 记录新建的账单，其余方法调用时 panic
+type recordingBillUseCase struct {
+	domain.BillUseCase
+
+	mu    sync.Mutex
+	users []string // 每笔账单的记录人
+}
+
+func (u *recordingBillUseCase) CreateBill(ctx context.Context, userName, userID string, input domain.NewBillInput) (*domain.Bill, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.users = append(u.users, userName)
+	return &domain.Bill{RecordID: "rec1", Description: input.Description, Amount: input.Amount, Type: input.Type, Category: input.Category, UserName: userName, Date: time.Now()}, nil
+}
+
+func (u *recordingBillUseCase) createdBy() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.users...)
+}
+
+// newAITestHandler 创建使用真实 AI 服务的处理器，模型依次返回 replies 中的消息，用完后回复纯文本
+func newAITestHandler(t *testing.T, replies ...openai.ChatCompletionMessage) (*FeishuHandlerAITools, *recordingBillUseCase) {
+	t.Helper()
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reply := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "好的"}
+		if len(replies) > 0 {
+			reply, replies = replies[0], replies[1:]
+		}
+		mu.Unlock()
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: reply}}})
+	}))
+	t.Cleanup(srv.Close)
+
+	h := newIdentityTestHandler(t)
+	bills := &recordingBillUseCase{}
+	h.billUseCase = bills
+	h.aiservice = ai.NewOpenAIService(&config.AIConfig{BaseURL: srv.URL, APIKey: "test-key", Model: "test-model", Language: "zh", CurrencySymbol: "¥"}, time.UTC, nil)
+	return h, bills
+}
+
+func toolCall(name, args string) openai.ToolCall {
+	return openai.ToolCall{ID: name, Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: name, Arguments: args}}
+}
+
+const lunchArgs = `{"description":"午饭","amount":30,"type":"expense","category":"餐饮"}`
+
+// 新用户在同一条消息里自我介绍并记账，先改名再用新名字记账
+func TestGenerateReplyNewUserWithIntroduction(t *testing.T) {
+	h, bills := newAITestHandler(t, openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{toolCall("record_transaction", lunchArgs), toolCall("rename_user", `{"name":"张三"}`)},
+	})
+
+	reply, created := h.generateReply(context.Background(), "ou_new", "我是张三，午饭30", "", nil)
+	if name, err := h.userMappingRepo.GetUserName(domain.PlatformFeishu, "ou_new"); err != nil || name != "张三" {
+		t.Fatalf("user name = %q, %v, want 张三", name, err)
+	}
+	if users := bills.createdBy(); len(users) != 1 || users[0] != "张三" || len(created) != 1 {
+		t.Errorf("created bills by %v, want one by 张三", users)
+	}
+	if strings.Contains(reply, "我还不知道您是谁") {
+		t.Errorf("reply asks for a name after the introduction: %q", reply)
+	}
+}
+
+// 新用户没有自我介绍时不记账，只询问一次称呼
+func TestGenerateReplyNewUserWithoutIntroduction(t *testing.T) {
+	h, bills := newAITestHandler(t, openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{toolCall("record_transaction", lunchArgs), toolCall("record_transaction", `{"description":"咖啡","amount":18,"type":"expense","category":"餐饮"}`)},
+	})
+
+	reply, created := h.generateReply(context.Background(), "ou_new", "午饭30 咖啡18", "", nil)
+	if users := bills.createdBy(); len(users) != 0 || len(created) != 0 {
+		t.Errorf("created bills by %v for an unknown user", users)
+	}
+	if n := strings.Count(reply, "我还不知道您是谁"); n != 1 {
+		t.Errorf("reply asks for a name %d times, want once: %q", n, reply)
+	}
+}
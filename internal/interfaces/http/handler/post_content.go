@@ -0,0 +1,59 @@
+package handler
+
+import "strings"
+
+// extractMessageText 提取消息中的纯文本，支持 text 和 post（富文本）消息
+// post 中的 @ 元素保留为 mention key（如 @_user_1），以便与文本消息使用同样的 @Bot 检测
+func extractMessageText(messageType string, contentObj map[string]interface{}) string {
+	if messageType == "post" {
+		return extractPostText(contentObj)
+	}
+	return getString(contentObj, "text")
+}
+
+// extractPostText 遍历富文本结构（标题 + 段落中的 text/a/at 元素），拼接为纯文本
+func extractPostText(contentObj map[string]interface{}) string {
+	post := contentObj
+	// 部分场景下富文本按语言包装，如 {"zh_cn": {"title": ..., "content": ...}}
+	if _, ok := post["content"]; !ok {
+		for _, locale := range []string{"zh_cn", "en_us", "ja_jp"} {
+			if m := getMap(contentObj, locale); m != nil {
+				post = m
+				break
+			}
+		}
+	}
+
+	var lines []string
+	if title := strings.TrimSpace(getString(post, "title")); title != "" {
+		lines = append(lines, title)
+	}
+
+	paragraphs, _ := post["content"].([]interface{})
+	for _, p := range paragraphs {
+		elements, ok := p.([]interface{})
+		if !ok {
+			continue
+		}
+
+		var b strings.Builder
+		for _, e := range elements {
+			element, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch getString(element, "tag") {
+			case "text", "a":
+				b.WriteString(getString(element, "text"))
+			case "at":
+				b.WriteString(getString(element, "user_id"))
+			}
+		}
+
+		if line := strings.TrimSpace(b.String()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
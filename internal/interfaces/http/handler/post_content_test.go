@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExtractMessageText(t *testing.T) {
+	tests := []struct {
+		name        string
+		messageType string
+		content     string
+		want        string
+	}{
+		{"text", "text", `{"text": "午饭 30"}`, "午饭 30"},
+		{
+			name:        "post",
+			messageType: "post",
+			content: `{"title": "今天的账", "content": [
+				[{"tag": "at", "user_id": "@_user_1"}, {"tag": "text", "text": " 午饭 30"}],
+				[{"tag": "img", "image_key": "img_1"}],
+				[{"tag": "text", "text": "打车 "}, {"tag": "a", "text": "行程单", "href": "https://example.com"}]
+			]}`,
+			want: "今天的账\n@_user_1 午饭 30\n打车 行程单",
+		},
+		{
+			// 按语言包装的富文本
+			name:        "post with locale",
+			messageType: "post",
+			content:     `{"en_us": {"title": "", "content": [[{"tag": "text", "text": "coffee 18"}]]}}`,
+			want:        "coffee 18",
+		},
+		{"empty post", "post", `{"content": []}`, ""},
+	}
+	for _, tt := range tests {
+		var content map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.content), &content); err != nil {
+			t.Fatal(err)
+		}
+		if got := extractMessageText(tt.messageType, content); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// messageRecordsTTL 消息与所建账单对应关系的保留时间，覆盖飞书允许撤回消息的时间范围
+const messageRecordsTTL = 7 * 24 * time.Hour
+
+// messageRecords 一条消息创建的账单，用于消息撤回时删除
+type messageRecords struct {
+	OpenID    string   `json:"open_id"`
+	RootID    string   `json:"root_id"`
+	RecordIDs []string `json:"record_ids"`
+}
+
+// rememberCreatedRecords 记录消息创建了哪些账单，没有新建账单时不记录
+func (h *FeishuHandlerAITools) rememberCreatedRecords(ctx context.Context, openID, messageID, conversationKey string, created []*domain.Bill) {
+	var recordIDs []string
+	for _, bill := range created {
+		if bill != nil && bill.RecordID != "" {
+			recordIDs = append(recordIDs, bill.RecordID)
+		}
+	}
+	if len(recordIDs) == 0 {
+		return
+	}
+
+	entry := messageRecords{
+		OpenID:    openID,
+		RootID:    strings.TrimPrefix(conversationKey, openID+":"),
+		RecordIDs: recordIDs,
+	}
+	if err := h.messageRecords.Set("message:"+messageID, entry, messageRecordsTTL); err != nil {
+		h.logFor(ctx).Warn("Failed to remember created records: message_id=%s, err=%v", messageID, err)
+	}
+}
+
+// processRecallEvent 处理 im.message.recalled_v1：删除被撤回消息创建的账单并通知用户
+func (h *FeishuHandlerAITools) processRecallEvent(ctx context.Context, payload map[string]interface{}) error {
+	header := getMap(payload, "header")
+	event := getMap(payload, "event")
+	if event == nil {
+		return fmt.Errorf("event not found")
+	}
+
+	messageID := getString(event, "message_id")
+	if messageID == "" || h.isDuplicateEvent(ctx, getString(header, "event_id"), "") {
+		return nil
+	}
+
+	key := "message:" + messageID
+	entry, ok := h.messageRecords.Get(key)
+	if !ok || len(entry.RecordIDs) == 0 {
+		// 没有创建账单的消息被撤回时不做处理
+		h.logFor(ctx).Debug("Recalled message created no records: message_id=%s", messageID)
+		return nil
+	}
+	_ = h.messageRecords.Delete(key)
+
+	h.logFor(ctx).Info("Message recalled, deleting %d records: message_id=%s, open_id=%s, records=%v", len(entry.RecordIDs), messageID, entry.OpenID, entry.RecordIDs)
+	go h.deleteRecalledRecords(ctx, messageID, entry)
+	return nil
+}
+
+// deleteRecalledRecords 删除撤回消息对应的账单，并在话题中回复结果
+func (h *FeishuHandlerAITools) deleteRecalledRecords(ctx context.Context, messageID string, entry messageRecords) {
+	ctx, cancel := h.detach(ctx, messageTimeout)
+	defer cancel()
+
+	deleted := 0
+	for _, recordID := range entry.RecordIDs {
+		if err := h.billUseCase.DeleteBill(ctx, recordID); err != nil {
+			h.logFor(ctx).Error("Delete recalled record failed: record_id=%s, err=%v", recordID, err)
+			continue
+		}
+		deleted++
+	}
+
+	text := fmt.Sprintf("已撤销 %d 条对应的记账记录", deleted)
+	if failed := len(entry.RecordIDs) - deleted; failed > 0 {
+		text += fmt.Sprintf("，%d 条删除失败：%s", failed, strings.Join(entry.RecordIDs, ", "))
+	}
+
+	// 被撤回的消息无法回复：话题中的消息回复到话题，否则私聊通知用户
+	var err error
+	if entry.RootID != "" && entry.RootID != messageID {
+		err = h.feishuService.ReplyMessage(ctx, entry.RootID, text, uuid.New().String())
+	} else {
+		err = h.feishuService.SendMessage(ctx, entry.OpenID, text)
+	}
+	if err != nil {
+		h.logFor(ctx).Error("Notify recall result failed: message_id=%s, err=%v", messageID, err)
+	}
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/telegram"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+// telegramMessageTTL 收发消息的保留时间，超过后回复旧消息不再带上之前的对话
+const telegramMessageTTL = 7 * 24 * time.Hour
+
+// telegramMaxChainDepth 沿回复链向上查找的最大消息数
+const telegramMaxChainDepth = 50
+
+// telegramSecretHeader setWebhook 设置 secret_token 后，Telegram 推送时携带的请求头
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// telegramMessage 缓存的一条消息，用于还原回复链
+type telegramMessage struct {
+	Role    string `json:"role"` // user 或 assistant
+	Content string `json:"content"`
+	ReplyTo int64  `json:"reply_to,omitempty"`
+}
+
+// TelegramHandler 处理 Telegram 推送的更新
+// 回复由飞书处理器的同一套逻辑生成（快捷命令、待确认操作、AI 工具调用），账单仓库不变
+type TelegramHandler struct {
+	config          *config.TelegramConfig
+	telegramService *telegram.Service
+	chat            *FeishuHandlerAITools
+	messages        cache.Cache // 收发过的消息，回复链对应飞书的话题
+}
+
+// NewTelegramHandler creates a Telegram handler that shares message processing with chat
+func NewTelegramHandler(config *config.TelegramConfig, telegramService *telegram.Service, chat *FeishuHandlerAITools, messages cache.Cache) *TelegramHandler {
+	return &TelegramHandler{
+		config:          config,
+		telegramService: telegramService,
+		chat:            chat,
+		messages:        messages,
+	}
+}
+
+// Webhook processes Telegram webhook updates
+func (h *TelegramHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.config.WebhookSecret != "" {
+		secret := r.Header.Get(telegramSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(h.config.WebhookSecret)) != 1 {
+			logger.FromContext(ctx, telegramLogComponent).Warn("Telegram webhook secret mismatch, remote=%s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var update telegram.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		logger.FromContext(ctx, telegramLogComponent).Error("Decode telegram update: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 以 update_id 作为关联 ID
+	ctx = logger.WithCorrelationID(ctx, "tg-"+strconv.FormatInt(update.UpdateID, 10))
+	logger.FromContext(ctx, telegramLogComponent).WithField("remote", r.RemoteAddr).Info("Telegram webhook received")
+	h.processUpdate(ctx, &update)
+
+	// 处理在后台进行，立即应答避免 Telegram 重试
+	w.WriteHeader(http.StatusOK)
+}
+
+// processUpdate 将文本消息放入发送者的处理队列
+func (h *TelegramHandler) processUpdate(ctx context.Context, update *telegram.Update) {
+	msg := update.Message
+	if msg == nil || msg.From == nil || msg.From.IsBot {
+		logger.FromContext(ctx, telegramLogComponent).Debug("Telegram update without user message skipped: update_id=%d", update.UpdateID)
+		return
+	}
+	if h.chat.isDuplicateEvent(ctx, "telegram:"+strconv.FormatInt(update.UpdateID, 10), "") {
+		return
+	}
+
+	text, ok := telegramText(msg.Text)
+	if !ok {
+		logger.FromContext(ctx, telegramLogComponent).Debug("Non-text telegram message skipped: chat_id=%d, message_id=%d", msg.Chat.ID, msg.MessageID)
+		return
+	}
+
+	openID := domain.PlatformUserID(domain.PlatformTelegram, strconv.FormatInt(msg.From.ID, 10))
+	var replyTo int64
+	if msg.ReplyToMessage != nil {
+		replyTo = msg.ReplyToMessage.MessageID
+	}
+	h.remember(ctx, msg.Chat.ID, msg.MessageID, telegramMessage{Role: "user", Content: text, ReplyTo: replyTo})
+
+	// 回复链的起点对应飞书的话题根消息；不是回复时不带历史，与飞书的非话题消息一致
+	history, rootID := h.replyChain(msg)
+	if len(history) > 0 {
+		history = append(history, domain.AIMessage{Role: "user", Content: text})
+	}
+	conversationKey := openID + ":" + strconv.FormatInt(rootID, 10)
+	messageKey := telegramMessageKey(msg.Chat.ID, msg.MessageID)
+
+	logger.FromContext(ctx, telegramLogComponent).Debug("Telegram message queued: open_id=%s, chat_id=%d, message_id=%d, history=%d", openID, msg.Chat.ID, msg.MessageID, len(history))
+	h.chat.enqueue(ctx, openID, messageKey, func() {
+		h.processMessage(ctx, openID, msg.Chat.ID, msg.MessageID, text, conversationKey, history)
+	}, func() { h.replyBusy(ctx, msg.Chat.ID, msg.MessageID) })
+}
+
+// processMessage 生成回复并回复到原消息
+func (h *TelegramHandler) processMessage(ctx context.Context, openID string, chatID, messageID int64, text, conversationKey string, history []domain.AIMessage) {
+	ctx, cancel := h.chat.detach(ctx, messageTimeout)
+	defer cancel()
+	ctx = domain.WithSourceMessage(ctx, telegramMessageKey(chatID, messageID))
+
+	response, created := h.chat.generateReply(ctx, openID, text, conversationKey, history)
+	sentID, err := h.telegramService.ReplyMessage(ctx, chatID, messageID, response)
+	if err != nil {
+		logger.FromContext(ctx, telegramLogComponent).Error("Reply telegram message failed: chat_id=%d, message_id=%d, error=%v", chatID, messageID, err)
+		return
+	}
+	h.remember(ctx, chatID, sentID, telegramMessage{Role: "assistant", Content: response, ReplyTo: messageID})
+	h.chat.rememberCreatedRecords(ctx, openID, telegramMessageKey(chatID, messageID), conversationKey, created)
+}
+
+// replyBusy 回复繁忙提示
+func (h *TelegramHandler) replyBusy(ctx context.Context, chatID, messageID int64) {
+	ctx, cancel := h.chat.detach(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := h.telegramService.ReplyMessage(ctx, chatID, messageID, h.chat.text(ctx, replyBusy)); err != nil {
+		logger.FromContext(ctx, telegramLogComponent).Error("Reply busy message failed: %v", err)
+	}
+}
+
+// remember 缓存一条消息，供之后的回复还原对话
+func (h *TelegramHandler) remember(ctx context.Context, chatID, messageID int64, msg telegramMessage) {
+	if err := h.messages.Set(telegramMessageKey(chatID, messageID), msg, telegramMessageTTL); err != nil {
+		logger.FromContext(ctx, telegramLogComponent).Warn("Failed to remember telegram message: chat_id=%d, message_id=%d, err=%v", chatID, messageID, err)
+	}
+}
+
+// replyChain 沿回复链向上还原之前的对话（不含当前消息），同时返回链的起点消息 ID
+// 被回复的消息不在缓存中时（如重启前的消息），使用推送中附带的内容，且不再继续向上查找
+func (h *TelegramHandler) replyChain(msg *telegram.Message) ([]domain.AIMessage, int64) {
+	rootID := msg.MessageID
+	if msg.ReplyToMessage == nil {
+		return nil, rootID
+	}
+
+	var chain []domain.AIMessage
+	parentID := msg.ReplyToMessage.MessageID
+	for depth := 0; parentID != 0 && depth < telegramMaxChainDepth; depth++ {
+		var cached telegramMessage
+		if err := h.messages.Get(telegramMessageKey(msg.Chat.ID, parentID), &cached); err != nil {
+			if parentID == msg.ReplyToMessage.MessageID && msg.ReplyToMessage.Text != "" {
+				role := "user"
+				if msg.ReplyToMessage.From != nil && msg.ReplyToMessage.From.IsBot {
+					role = "assistant"
+				}
+				chain = append(chain, domain.AIMessage{Role: role, Content: msg.ReplyToMessage.Text})
+				rootID = parentID
+			}
+			break
+		}
+		chain = append(chain, domain.AIMessage{Role: cached.Role, Content: cached.Content})
+		rootID = parentID
+		parentID = cached.ReplyTo
+	}
+
+	// 查找顺序是由近及远，历史需要按时间顺序排列
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, rootID
+}
+
+// telegramMessageKey 消息在缓存和处理队列中的键
+func telegramMessageKey(chatID, messageID int64) string {
+	return fmt.Sprintf("telegram:%d:%d", chatID, messageID)
+}
+
+// telegramText 清理消息文本：去掉群聊中开头的 @机器人 和命令后缀 /今天@bot，
+// /start 视为空消息，由默认问候触发欢迎回复；没有文本时返回 false
+func telegramText(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", false
+	}
+
+	if strings.HasPrefix(text, "@") {
+		if i := strings.IndexAny(text, " \n"); i > 0 {
+			text = strings.TrimSpace(text[i:])
+		} else {
+			return "", true
+		}
+	}
+
+	if strings.HasPrefix(text, "/") {
+		command, rest, _ := strings.Cut(text, " ")
+		if i := strings.Index(command, "@"); i > 0 {
+			command = command[:i]
+		}
+		if command == "/start" {
+			return "", true
+		}
+		text = strings.TrimSpace(command + " " + rest)
+	}
+	return text, true
+}
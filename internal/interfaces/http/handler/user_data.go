@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
+)
+
+// dataDeletionConfirmTTL 删除个人数据每一步等待用户确认的时间
+const dataDeletionConfirmTTL = 10 * time.Minute
+
+// 删除个人数据的两个确认步骤
+const (
+	dataDeletionProfile = "profile" // 删除名字、个人设置、模板和会话状态
+	dataDeletionRecords = "records" // 删除表格中的账单
+)
+
+// pendingDataDeletion 等待用户确认的删除个人数据
+type pendingDataDeletion struct {
+	Step     string `json:"step"`
+	OpenID   string `json:"open_id"`
+	UserName string `json:"user_name"`
+	// Removed 第一步已删除的内容，第二步结束后一起汇总
+	Removed *domain.UserDataDeletion `json:"removed,omitempty"`
+	Records int                      `json:"records"` // 第二步确认时表格中该用户的账单数
+}
+
+// pendingDataDeletionKey 待确认删除的缓存键，按会话区分
+func pendingDataDeletionKey(conversationKey string) string {
+	return "delete_data:" + conversationKey
+}
+
+// exportMyDataCommand 把用户的名字、个人设置和全部账单打包为 zip 文件回复
+func (h *FeishuHandlerAITools) exportMyDataCommand(ctx context.Context, openID, userName, arg string) string {
+	replyFile := domain.FileReplierFromContext(ctx)
+	if replyFile == nil {
+		return h.text(ctx, replyExportUnsupported)
+	}
+
+	// 先写入临时文件再上传，账单较多时不占用过多内存
+	tmp, err := os.CreateTemp("", "ledgerbot-userdata-*.zip")
+	if err != nil {
+		h.logFor(ctx).Error("Create user data file failed: %v", err)
+		return h.text(ctx, replyExportFailed, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	count, err := h.billUseCase.ExportUserData(ctx, openID, tmp)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return h.text(ctx, replyExportNoData)
+	}
+	if err != nil {
+		h.logFor(ctx).Error("Export user data failed: open_id=%s, err=%v", openID, err)
+		return h.text(ctx, replyExportFailed, err)
+	}
+	if err := replyFile(ctx, ai.UserDataFileName(time.Now().In(h.locationFor(ctx))), tmp); err != nil {
+		h.logFor(ctx).Error("Send user data file failed: open_id=%s, err=%v", openID, err)
+		return h.text(ctx, replyExportSendFailed, err)
+	}
+	return h.text(ctx, replyExportDone, count)
+}
+
+// deleteMyDataCommand 发起删除个人数据，与 AI 的 delete_my_data 工具相同
+func (h *FeishuHandlerAITools) deleteMyDataCommand(ctx context.Context, openID, userName, arg string) string {
+	start := domain.DataDeletionFromContext(ctx)
+	if start == nil {
+		return h.text(ctx, replyDeleteUnavailable)
+	}
+	return start()
+}
+
+// startDataDeletion 列出将要删除的数据，暂存后等待用户回复“确认”
+func (h *FeishuHandlerAITools) startDataDeletion(ctx context.Context, openID, userName, conversationKey string) string {
+	if conversationKey == "" {
+		return h.text(ctx, replyDeleteNeedsChat)
+	}
+	pending := pendingDataDeletion{Step: dataDeletionProfile, OpenID: openID, UserName: userName}
+	if err := h.pendingDeletes.Set(pendingDataDeletionKey(conversationKey), pending, dataDeletionConfirmTTL); err != nil {
+		h.logFor(ctx).Error("Save pending data deletion: %v", err)
+		return h.text(ctx, replyDeleteFailed, err)
+	}
+	h.logFor(ctx).Info("Data deletion requires confirmation: open_id=%s, user=%s", openID, userName)
+
+	var b strings.Builder
+	b.WriteString(h.text(ctx, replyDeleteIntro))
+	if userName != "" {
+		b.WriteString(h.text(ctx, replyDeleteNamedProfile, userName))
+	} else {
+		b.WriteString(h.text(ctx, replyDeleteProfile))
+	}
+	b.WriteString(h.text(ctx, replyDeleteSessions))
+	if userName != "" {
+		b.WriteString(h.text(ctx, replyDeleteRecordsLater))
+	}
+	b.WriteString(h.text(ctx, replyDeleteConfirm, int(dataDeletionConfirmTTL.Minutes())))
+	return b.String()
+}
+
+// resolvePendingDataDeletion 处理对删除个人数据的“确认/取消”回复，没有待确认的删除时返回 false
+func (h *FeishuHandlerAITools) resolvePendingDataDeletion(ctx context.Context, conversationKey string, confirmed bool) (string, bool) {
+	key := pendingDataDeletionKey(conversationKey)
+	pending, ok := h.pendingDeletes.Get(key)
+	if !ok {
+		return "", false
+	}
+	// 先删除再执行，避免重复回复“确认”时执行两次
+	_ = h.pendingDeletes.Delete(key)
+
+	switch pending.Step {
+	case dataDeletionProfile:
+		if !confirmed {
+			h.logFor(ctx).Info("Data deletion cancelled: open_id=%s", pending.OpenID)
+			return h.text(ctx, replyDeleteCancelled), true
+		}
+		return h.deleteProfileData(ctx, conversationKey, pending), true
+	case dataDeletionRecords:
+		if !confirmed {
+			h.logFor(ctx).Info("Record deletion cancelled: open_id=%s, user=%s", pending.OpenID, pending.UserName)
+			return h.formatDataDeletion(ctx, pending.Removed, 0) + h.text(ctx, replyDeleteRecordsKept), true
+		}
+		return h.deleteRecordData(ctx, pending), true
+	}
+	return "", false
+}
+
+// deleteProfileData 删除名字、个人设置、模板和会话状态；用户在表格中还有账单时请用户再次确认
+func (h *FeishuHandlerAITools) deleteProfileData(ctx context.Context, conversationKey string, pending pendingDataDeletion) string {
+	removed, err := h.billUseCase.DeleteUserData(ctx, pending.OpenID)
+	if err != nil {
+		h.logFor(ctx).Error("Delete user data failed: open_id=%s, err=%v", pending.OpenID, err)
+		return h.text(ctx, replyDeleteFailed, err)
+	}
+	h.forgetThreads(ctx, pending.OpenID)
+
+	if pending.UserName == "" {
+		return h.formatDataDeletion(ctx, removed, 0)
+	}
+	count, err := h.billUseCase.CountUserBills(ctx, pending.UserName)
+	if err != nil {
+		h.logFor(ctx).Error("Count user bills failed: user=%s, err=%v", pending.UserName, err)
+		return h.formatDataDeletion(ctx, removed, 0) + h.text(ctx, replyDeleteCountFailed, err)
+	}
+	if count == 0 {
+		return h.formatDataDeletion(ctx, removed, 0)
+	}
+
+	pending.Step, pending.Removed, pending.Records = dataDeletionRecords, removed, count
+	if err := h.pendingDeletes.Set(pendingDataDeletionKey(conversationKey), pending, dataDeletionConfirmTTL); err != nil {
+		h.logFor(ctx).Error("Save pending record deletion: %v", err)
+		return h.formatDataDeletion(ctx, removed, 0) + h.text(ctx, replyDeleteSaveFailed, err)
+	}
+	return h.formatDataDeletion(ctx, removed, 0) + h.text(ctx, replyDeleteAskRecords,
+		pending.UserName, count, int(dataDeletionConfirmTTL.Minutes()))
+}
+
+// deleteRecordData 删除用户在表格中记录的全部账单
+func (h *FeishuHandlerAITools) deleteRecordData(ctx context.Context, pending pendingDataDeletion) string {
+	deleted, err := h.billUseCase.DeleteUserBills(ctx, pending.UserName)
+	if err != nil {
+		h.logFor(ctx).Error("Delete user bills failed: user=%s, err=%v", pending.UserName, err)
+		return h.formatDataDeletion(ctx, pending.Removed, 0) + h.text(ctx, replyDeleteBillsFailed, err)
+	}
+	reply := h.formatDataDeletion(ctx, pending.Removed, deleted)
+	if deleted > 0 && h.config.SoftDelete {
+		reply += h.text(ctx, replyDeleteSoftDeleted, h.config.SoftDeleteRetentionDays)
+	}
+	return reply
+}
+
+// forgetThreads 清除用户所有话题中待确认的操作和翻页状态，失败只记录日志
+func (h *FeishuHandlerAITools) forgetThreads(ctx context.Context, openID string) {
+	if err := h.aiservice.ForgetConversations(openID); err != nil {
+		h.logFor(ctx).Warn("Forget AI conversations failed: open_id=%s, err=%v", openID, err)
+	}
+	prefix := openID + ":"
+	if _, err := h.pendingImports.DeletePrefix(pendingImportKey(prefix)); err != nil {
+		h.logFor(ctx).Warn("Forget pending imports failed: open_id=%s, err=%v", openID, err)
+	}
+	if _, err := h.pendingDeletes.DeletePrefix(pendingDataDeletionKey(prefix)); err != nil {
+		h.logFor(ctx).Warn("Forget pending data deletions failed: open_id=%s, err=%v", openID, err)
+	}
+}
+
+// formatDataDeletion 汇总已删除的数据
+func (h *FeishuHandlerAITools) formatDataDeletion(ctx context.Context, removed *domain.UserDataDeletion, records int) string {
+	var items []string
+	if removed != nil {
+		if removed.MappingDeleted {
+			if removed.UserName != "" {
+				items = append(items, h.text(ctx, replyDeletedNamed, removed.UserName))
+			} else {
+				items = append(items, h.text(ctx, replyDeletedProfile))
+			}
+		}
+		if removed.Templates > 0 {
+			items = append(items, h.text(ctx, replyDeletedTemplates, removed.Templates))
+		}
+		if removed.JournalCleared {
+			items = append(items, h.text(ctx, replyDeletedJournal))
+		}
+	}
+	items = append(items, h.text(ctx, replyDeletedSessions))
+	if records > 0 {
+		items = append(items, h.text(ctx, replyDeletedRecords, records))
+	}
+	return h.text(ctx, replyDeletedSummary) + strings.Join(items, h.text(ctx, replyDeletedSeparator))
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+)
+
+// deleteDataBillUseCase 记录删除个人数据的调用，records 为表格中该用户的账单数
+type deleteDataBillUseCase struct {
+	commandBillUseCase
+
+	records        int
+	deletedData    []string
+	deletedRecords []string
+}
+
+func (u *deleteDataBillUseCase) DeleteUserData(ctx context.Context, platformID string) (*domain.UserDataDeletion, error) {
+	u.deletedData = append(u.deletedData, platformID)
+	return &domain.UserDataDeletion{UserName: "张三", MappingDeleted: true, Templates: 2, JournalCleared: true}, nil
+}
+
+func (u *deleteDataBillUseCase) CountUserBills(ctx context.Context, userName string) (int, error) {
+	return u.records, nil
+}
+
+func (u *deleteDataBillUseCase) DeleteUserBills(ctx context.Context, userName string) (int, error) {
+	u.deletedRecords = append(u.deletedRecords, userName)
+	return u.records, nil
+}
+
+// forgetAIService 记录被清除会话状态的用户
+type forgetAIService struct {
+	domain.AIService
+	forgotten []string
+}
+
+func (s *forgetAIService) ForgetConversations(openID string) error {
+	s.forgotten = append(s.forgotten, openID)
+	return nil
+}
+
+func newDeleteDataTestHandler(t *testing.T, bills *deleteDataBillUseCase) (*FeishuHandlerAITools, *forgetAIService) {
+	t.Helper()
+	h := newCommandTestHandler(t, &bills.commandBillUseCase)
+	aiService := &forgetAIService{}
+	h.billUseCase = bills
+	h.aiservice = aiService
+	h.pendingImports = cache.NewTyped[pendingImport](cache.NewMemoryCache())
+	h.pendingDeletes = cache.NewTyped[pendingDataDeletion](cache.NewMemoryCache())
+	t.Cleanup(func() {
+		h.pendingImports.Close()
+		h.pendingDeletes.Close()
+	})
+	if err := h.userMappingRepo.SetUserName(domain.PlatformFeishu, "ou_1", "张三"); err != nil {
+		t.Fatal(err)
+	}
+	return h, aiService
+}
+
+// 两步确认：先删除名字、设置和会话状态，再确认删除表格中的账单
+func TestDeleteMyDataFlow(t *testing.T) {
+	bills := &deleteDataBillUseCase{records: 3}
+	h, aiService := newDeleteDataTestHandler(t, bills)
+	ctx := context.Background()
+	const conversation = "ou_1:om_root"
+
+	reply, _ := h.generateReply(ctx, "ou_1", "/删除我的数据", conversation, nil)
+	for _, want := range []string{"名字（张三）和个人设置", "下一步单独确认", "回复“确认”"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("prompt %q does not contain %q", reply, want)
+		}
+	}
+	// 确认之前不删除任何数据
+	if len(bills.deletedData) != 0 || len(aiService.forgotten) != 0 {
+		t.Fatalf("data deleted before confirmation: %v, %v", bills.deletedData, aiService.forgotten)
+	}
+
+	reply, _ = h.generateReply(ctx, "ou_1", "确认", conversation, nil)
+	for _, want := range []string{"🗑️ 已删除：名字（张三）和个人设置、2 个账单模板、撤销记录、会话状态", "还有你（张三）记录的 3 条账单"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("first confirmation %q does not contain %q", reply, want)
+		}
+	}
+	if len(bills.deletedData) != 1 || bills.deletedData[0] != "ou_1" || len(aiService.forgotten) != 1 {
+		t.Errorf("deleted %v, forgot %v, want ou_1 once", bills.deletedData, aiService.forgotten)
+	}
+	if len(bills.deletedRecords) != 0 {
+		t.Fatal("bills deleted before the second confirmation")
+	}
+
+	reply, _ = h.generateReply(ctx, "ou_1", "确认", conversation, nil)
+	if !strings.Contains(reply, "表格中的 3 条账单") || len(bills.deletedRecords) != 1 || bills.deletedRecords[0] != "张三" {
+		t.Errorf("second confirmation = %q, deleted %v", reply, bills.deletedRecords)
+	}
+	// 确认后不再有待确认的删除
+	if _, handled := h.resolvePendingDataDeletion(ctx, conversation, true); handled {
+		t.Error("deletion still pending after the second confirmation")
+	}
+}
+
+// 第二步取消时保留表格中的账单
+func TestDeleteMyDataKeepRecords(t *testing.T) {
+	bills := &deleteDataBillUseCase{records: 3}
+	h, _ := newDeleteDataTestHandler(t, bills)
+	ctx := context.Background()
+	const conversation = "ou_1:om_root"
+
+	h.generateReply(ctx, "ou_1", "/删除我的数据", conversation, nil)
+	h.generateReply(ctx, "ou_1", "确认", conversation, nil)
+	reply, _ := h.generateReply(ctx, "ou_1", "取消", conversation, nil)
+	if !strings.Contains(reply, "表格中的账单已保留") || len(bills.deletedRecords) != 0 {
+		t.Errorf("cancel = %q, deleted %v, want the bills kept", reply, bills.deletedRecords)
+	}
+}
+
+func TestDeleteMyDataCancel(t *testing.T) {
+	bills := &deleteDataBillUseCase{}
+	h, _ := newDeleteDataTestHandler(t, bills)
+	ctx := context.Background()
+
+	h.generateReply(ctx, "ou_1", "/删除我的数据", "ou_1:om_root", nil)
+	// 其他话题中的“确认”不会执行这次删除
+	if _, handled := h.resolvePendingDataDeletion(ctx, "ou_1:om_other", true); handled {
+		t.Error("confirmation in another thread resolved the deletion")
+	}
+	reply, _ := h.generateReply(ctx, "ou_1", "取消", "ou_1:om_root", nil)
+	if reply != "已取消，你的数据没有被删除" || len(bills.deletedData) != 0 {
+		t.Errorf("cancel = %q, deleted %v", reply, bills.deletedData)
+	}
+}
+
+// 没有账单时第一步确认后即完成，不再询问
+func TestDeleteMyDataWithoutRecords(t *testing.T) {
+	bills := &deleteDataBillUseCase{}
+	h, _ := newDeleteDataTestHandler(t, bills)
+	ctx := context.Background()
+
+	h.generateReply(ctx, "ou_1", "/删除我的数据", "ou_1:om_root", nil)
+	reply, _ := h.generateReply(ctx, "ou_1", "确认", "ou_1:om_root", nil)
+	if strings.Contains(reply, "是否一并删除") || !strings.HasPrefix(reply, "🗑️ 已删除：") {
+		t.Errorf("confirmation = %q, want a summary without a second step", reply)
+	}
+	if _, handled := h.resolvePendingDataDeletion(ctx, "ou_1:om_root", true); handled {
+		t.Error("deletion still pending")
+	}
+}
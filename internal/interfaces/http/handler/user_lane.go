@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userLane 一个用户等待处理的消息
+// 同一用户的消息依次执行，避免"改成35"在"午饭30"记账完成前执行
+type userLane struct {
+	pending []func()
+}
+
+// enqueue 将消息放入用户的处理队列
+// 用户没有正在处理的消息时向工作池提交一个任务，由它依次执行该用户的全部消息；
+// 不同用户之间仍然并行处理。工作池队列已满时调用 onBusy 回复繁忙提示
+func (h *FeishuHandlerAITools) enqueue(ctx context.Context, openID, messageID string, job func(), onBusy func()) {
+	// 无法识别用户时不与其他消息排队
+	key := openID
+	if key == "" {
+		key = "message:" + messageID
+	}
+
+	h.lanesMu.Lock()
+	defer h.lanesMu.Unlock()
+
+	if lane, ok := h.lanes[key]; ok {
+		lane.pending = append(lane.pending, job)
+		h.logFor(ctx).Debug("Message queued behind earlier messages of the same user: open_id=%s, message_id=%s, pending=%d", openID, messageID, len(lane.pending))
+		return
+	}
+
+	lane := &userLane{pending: []func(){job}}
+	// Submit 不会阻塞，持锁提交保证提交失败时队列里只有当前消息
+	if !h.pool.Submit(func() { h.drainLane(key, lane) }) {
+		stats := h.pool.Stats()
+		h.logFor(ctx).Warn("Worker pool is full, rejecting message: message_id=%s, queue_depth=%d, running=%d", messageID, stats.QueueDepth, stats.Running)
+		go onBusy()
+		return
+	}
+	h.lanes[key] = lane
+}
+
+// drainLane 依次执行用户队列中的消息，队列清空后移除
+func (h *FeishuHandlerAITools) drainLane(key string, lane *userLane) {
+	for {
+		h.lanesMu.Lock()
+		if len(lane.pending) == 0 {
+			delete(h.lanes, key)
+			h.lanesMu.Unlock()
+			return
+		}
+		job := lane.pending[0]
+		lane.pending = lane.pending[1:]
+		h.lanesMu.Unlock()
+
+		job()
+	}
+}
+
+// replyBusy 回复繁忙提示
+func (h *FeishuHandlerAITools) replyBusy(ctx context.Context, messageID string) {
+	ctx, cancel := h.detach(ctx, 10*time.Second)
+	defer cancel()
+	if err := h.feishuService.ReplyMessage(ctx, messageID, h.text(ctx, replyBusy), uuid.New().String()); err != nil {
+		h.logFor(ctx).Error("Reply busy message failed: %v", err)
+	}
+}
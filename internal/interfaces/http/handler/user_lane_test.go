@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/pkg/workerpool"
+)
+
+func newLaneTestHandler(t *testing.T) *FeishuHandlerAITools {
+	t.Helper()
+	h := newIdentityTestHandler(t)
+	h.pool = workerpool.New(4, 16)
+	h.lanes = make(map[string]*userLane)
+	t.Cleanup(func() { h.pool.Shutdown(context.Background()) })
+	return h
+}
+
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+// 同一用户的消息按到达顺序依次处理，前一条完成前不会开始下一条
+func TestEnqueueSameUserInOrder(t *testing.T) {
+	h := newLaneTestHandler(t)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	done := make(chan struct{})
+
+	h.enqueue(context.Background(), "ou_1", "om1", func() {
+		<-release
+		mu.Lock()
+		order = append(order, "午饭30")
+		mu.Unlock()
+	}, func() { t.Error("first message rejected") })
+	h.enqueue(context.Background(), "ou_1", "om2", func() {
+		mu.Lock()
+		order = append(order, "改成35")
+		mu.Unlock()
+		close(done)
+	}, func() { t.Error("second message rejected") })
+
+	// 第二条消息在第一条完成前不会执行
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	started := len(order)
+	mu.Unlock()
+	if started != 0 {
+		t.Fatalf("%d messages ran before the first one finished", started)
+	}
+
+	close(release)
+	waitFor(t, done, "the second message")
+	if len(order) != 2 || order[0] != "午饭30" || order[1] != "改成35" {
+		t.Errorf("processed %v, want arrival order", order)
+	}
+}
+
+// 不同用户之间并行处理，一个用户的慢消息不阻塞其他用户
+func TestEnqueueDifferentUsersInParallel(t *testing.T) {
+	h := newLaneTestHandler(t)
+	release := make(chan struct{})
+	defer close(release)
+	done := make(chan struct{})
+
+	h.enqueue(context.Background(), "ou_slow", "om1", func() { <-release }, func() { t.Error("slow message rejected") })
+	h.enqueue(context.Background(), "ou_other", "om2", func() { close(done) }, func() { t.Error("other message rejected") })
+
+	waitFor(t, done, "the other user's message")
+}
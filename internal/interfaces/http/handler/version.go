@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/wyg1997/LedgerBot/pkg/version"
+)
+
+// versionResponse /version 的响应：构建信息和运行时长
+type versionResponse struct {
+	version.Info
+	UptimeSeconds int64 `json:"uptime_seconds"`
+}
+
+// Version 返回运行中程序的版本、提交、构建时间和运行时长
+func Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionResponse{Info: version.Get(), UptimeSeconds: int64(version.Uptime().Seconds())})
+}
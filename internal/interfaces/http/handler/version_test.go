@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/pkg/version"
+)
+
+// setVersion 模拟 -ldflags -X 注入的构建信息，测试结束后恢复
+func setVersion(t *testing.T, v, commit, buildTime string) {
+	t.Helper()
+	oldVersion, oldCommit, oldBuildTime := version.Version, version.Commit, version.BuildTime
+	version.Version, version.Commit, version.BuildTime = v, commit, buildTime
+	t.Cleanup(func() { version.Version, version.Commit, version.BuildTime = oldVersion, oldCommit, oldBuildTime })
+}
+
+// /version 返回注入的构建信息
+func TestVersionEndpoint(t *testing.T) {
+	setVersion(t, "v1.2.0", "a1b2c3d", "2026-10-16T14:55:03Z")
+	rec := httptest.NewRecorder()
+	Version(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"version": "v1.2.0", "commit": "a1b2c3d", "build_time": "2026-10-16T14:55:03Z", "go_version": runtime.Version()}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %v, want %v", key, body[key], value)
+		}
+	}
+	if uptime, ok := body["uptime_seconds"].(float64); !ok || uptime < 0 {
+		t.Errorf("uptime_seconds = %v", body["uptime_seconds"])
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	setVersion(t, "v1.2.0", "a1b2c3d", "")
+	h := newCommandTestHandler(t, &commandBillUseCase{})
+	reply, handled := h.runCommand(context.Background(), "ou_1", "张三", "/版本")
+	if !handled || !strings.HasPrefix(reply, "🤖 版本：v1.2.0\n  提交：a1b2c3d\n  构建时间：-\n") || !strings.Contains(reply, "已运行：") {
+		t.Errorf("reply = %q, %v", reply, handled)
+	}
+}
+
+func TestFormatVersion(t *testing.T) {
+	info := version.Info{Version: "v1.2.0", Commit: "a1b2c3d", BuildTime: "2026-10-16T14:55:03Z", GoVersion: "go1.21.0"}
+	want := "🤖 版本：v1.2.0\n  提交：a1b2c3d\n  构建时间：2026-10-16T14:55:03Z\n  Go：go1.21.0\n  已运行：1 天 2 小时 3 分钟"
+	if got := formatVersion("zh", info, 26*time.Hour+3*time.Minute+40*time.Second); got != want {
+		t.Errorf("formatVersion =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		uptime time.Duration
+		want   string
+	}{
+		{30 * time.Second, "0 分钟"},
+		{59 * time.Minute, "59 分钟"},
+		{2*time.Hour + 5*time.Minute, "2 小时 5 分钟"},
+		{48 * time.Hour, "2 天 0 小时 0 分钟"},
+	}
+	for _, tt := range tests {
+		if got := formatUptime("zh", tt.uptime); got != tt.want {
+			t.Errorf("formatUptime(%s) = %q, want %q", tt.uptime, got, tt.want)
+		}
+	}
+	if got := formatVersion("en", version.Info{Version: "dev", GoVersion: "go1.21.0"}, 0); !strings.HasPrefix(got, "🤖 Version: dev\n  Commit: -\n  Built: -\n") {
+		t.Errorf("English reply = %q", got)
+	}
+}
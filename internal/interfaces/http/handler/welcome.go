@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// welcomeTTL 同一用户两次欢迎消息的最短间隔，进入会话事件每次打开私聊都会推送
+const welcomeTTL = 30 * 24 * time.Hour
+
+// welcomeUsage 欢迎消息中的使用说明
+const welcomeUsage = "我可以帮你记账和查账，直接用自然语言告诉我就行：\n" +
+	"📝 记账：午饭30、打车45，咖啡18、工资到账8000\n" +
+	"🔍 查询：今天花了多少、这个月的支出、上周餐饮花了多少\n" +
+	"✏️ 修改：把刚才那笔改成35、删除刚才那笔\n" +
+	"🧾 也可以直接发送付款截图或语音"
+
+// processWelcomeEvent 处理用户首次打开与机器人的私聊（bot_p2p_chat_entered / p2p_chat_create）和机器人被拉进群的事件
+func (h *FeishuHandlerAITools) processWelcomeEvent(ctx context.Context, eventType string, payload map[string]interface{}) error {
+	header := getMap(payload, "header")
+	event := getMap(payload, "event")
+	if event == nil {
+		return fmt.Errorf("event not found")
+	}
+	// 旧版事件没有 header，使用顶层的 uuid 去重
+	eventID := getString(header, "event_id")
+	if eventID == "" {
+		eventID = getString(payload, "uuid")
+	}
+	if h.isDuplicateEvent(ctx, eventID, "") {
+		return nil
+	}
+	botName := h.botIdentity(ctx).name
+
+	switch eventType {
+	case "im.chat.member.bot.added_v1":
+		chatID := getString(event, "chat_id")
+		if chatID == "" {
+			return nil
+		}
+		text := fmt.Sprintf("大家好，我是%s！\n%s\n\n在群里使用时请先 @我，之后可以在同一个话题里继续对话。第一次使用时请告诉我你的称呼，例如：@%s 我是张三",
+			botName, welcomeUsage, botName)
+		go h.sendWelcome(ctx, func(ctx context.Context) error {
+			return h.feishuService.SendMessageToChat(ctx, chatID, text)
+		})
+		return nil
+	}
+
+	// 私聊：新版事件为 operator_id，旧版 p2p_chat_create 为 user
+	user := getMap(event, "operator_id")
+	if getString(user, "open_id") == "" {
+		user = getMap(event, "user")
+	}
+	ids := userIDs{OpenID: getString(user, "open_id"), UnionID: getString(user, "union_id")}
+	if ids.OpenID == "" && ids.UnionID == "" {
+		return nil
+	}
+	openID := h.feishuUserKey(ctx, ids)
+
+	key := "welcome:" + openID
+	if set, err := h.seenEvents.SetNX(key, true, welcomeTTL); err != nil || !set {
+		return nil
+	}
+
+	text := fmt.Sprintf("👋 你好，我是%s！\n%s", botName, welcomeUsage)
+	if userName, ok := h.getUserNameIfExists(ctx, openID); ok {
+		text += fmt.Sprintf("\n\n%s，欢迎回来～", userName)
+	} else {
+		// 同时询问称呼，用户可以在第一条消息里一并介绍自己并记账，例如：我是张三，午饭30
+		text += "\n\n先告诉我怎么称呼你吧，例如：我是张三"
+	}
+
+	h.logFor(ctx).Info("Sending welcome message: open_id=%s", openID)
+	go h.sendWelcome(ctx, func(ctx context.Context) error {
+		return h.feishuService.SendMessage(ctx, openID, text)
+	})
+	return nil
+}
+
+// sendWelcome 在后台发送欢迎消息，避免阻塞事件响应
+func (h *FeishuHandlerAITools) sendWelcome(ctx context.Context, send func(ctx context.Context) error) {
+	ctx, cancel := h.detach(ctx, messageTimeout)
+	defer cancel()
+
+	if err := send(ctx); err != nil {
+		h.logFor(ctx).Error("Send welcome message failed: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+const (
+	// reportMarkerTTL 已发送标记的保留时间，只需覆盖当天
+	reportMarkerTTL = 48 * time.Hour
+	// reportTimeout 单次生成并发送日报的超时时间
+	reportTimeout = 5 * time.Minute
+	// reportTopN 日报中列出的最大几笔支出
+	reportTopN = 3
+)
+
+// logComponent 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别
+const logComponent = "scheduler"
+
+// DailyReport 每天定时推送当日账单汇总
+type DailyReport struct {
+	config          *config.ReportConfig
+	location        *time.Location // 日报日期和发送时间使用的时区
+	currency        string
+	feishuService   *feishu.FeishuService
+	billUseCase     domain.BillUseCase
+	userMappingRepo domain.UserMappingRepository
+	markers         cache.Cache // 已发送标记，重启后避免同一天重复发送
+	logger          logger.Logger
+}
+
+// NewDailyReport creates the daily report scheduler; dates and the send time are in location
+func NewDailyReport(
+	config *config.ReportConfig,
+	location *time.Location,
+	currency string,
+	feishuService *feishu.FeishuService,
+	billUseCase domain.BillUseCase,
+	userMappingRepo domain.UserMappingRepository,
+	markers cache.Cache,
+) *DailyReport {
+	return &DailyReport{
+		config:          config,
+		location:        location,
+		currency:        currency,
+		feishuService:   feishuService,
+		billUseCase:     billUseCase,
+		userMappingRepo: userMappingRepo,
+		markers:         markers,
+		logger:          logger.GetLogger(logComponent),
+	}
+}
+
+// Start 按配置的时间每天发送日报，ctx 取消后返回
+// 服务在发送时间之后才启动时，当天未发送过的日报会立即补发
+func (r *DailyReport) Start(ctx context.Context) {
+	hour, minute, err := parseClock(r.config.DailyAt)
+	if err != nil {
+		r.logger.Error("Invalid REPORT_DAILY_AT %q, daily report disabled: %v", r.config.DailyAt, err)
+		return
+	}
+	r.logger.Info("Daily report scheduled at %02d:%02d", hour, minute)
+
+	for {
+		now := time.Now().In(r.location)
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !next.After(now) {
+			r.sendFor(ctx, now)
+			next = next.AddDate(0, 0, 1)
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Daily report scheduler stopped")
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// sendFor 发送指定日期的日报，已发送过的接收方会被跳过
+func (r *DailyReport) sendFor(parent context.Context, day time.Time) {
+	date := day.Format("2006-01-02")
+	ctx, cancel := context.WithTimeout(logger.WithCorrelationID(parent, "report-"+date), reportTimeout)
+	defer cancel()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1).Add(-time.Millisecond)
+
+	users, err := r.userMappingRepo.ListMappings()
+	if err != nil {
+		r.logger.Error("Daily report: list users failed: %v", err)
+		return
+	}
+	// 迁移到 union_id 的用户同时保留 open_id 下的映射，只发送一次；按 ID 排序后 union_id（on_）在 open_id（ou_）之前
+	users = domain.UniqueUsers(users)
+
+	// 发送到群聊时合并为一条消息
+	if r.config.ChatID != "" {
+		marker := "report:" + date + ":chat:" + r.config.ChatID
+		if r.markers.Exists(marker) {
+			return
+		}
+		sections := make([]string, 0, len(users))
+		for _, u := range users {
+			section, _, err := r.userSummary(ctx, u, start, end)
+			if err != nil {
+				r.logger.Error("Daily report: query %s failed: %v", u.UserName, err)
+				continue
+			}
+			sections = append(sections, section)
+		}
+		if len(sections) == 0 {
+			return
+		}
+		text := fmt.Sprintf("📊 %s 账单日报\n\n%s", date, strings.Join(sections, "\n\n"))
+		if err := r.feishuService.SendMessageToChat(ctx, r.config.ChatID, text); err != nil {
+			r.logger.Error("Daily report: send to chat %s failed: %v", r.config.ChatID, err)
+			return
+		}
+		r.mark(marker)
+		r.logger.Info("Daily report sent to chat: date=%s, chat_id=%s, users=%d", date, r.config.ChatID, len(sections))
+		return
+	}
+
+	// 未配置群聊时私聊发送给每个用户，当天没有记账的用户不打扰
+	for _, u := range users {
+		// 日报通过飞书发送，其他平台的用户暂不私聊推送
+		if domain.PlatformOf(u.PlatformID) != domain.PlatformFeishu {
+			continue
+		}
+		marker := "report:" + date + ":user:" + u.PlatformID
+		if r.markers.Exists(marker) {
+			continue
+		}
+		section, count, err := r.userSummary(ctx, u, start, end)
+		if err != nil {
+			r.logger.Error("Daily report: query %s failed: %v", u.UserName, err)
+			continue
+		}
+		if count > 0 {
+			text := fmt.Sprintf("📊 %s 账单日报\n\n%s", date, section)
+			if err := r.feishuService.SendMessage(ctx, u.PlatformID, text); err != nil {
+				r.logger.Error("Daily report: send to %s failed: %v", u.UserName, err)
+				continue
+			}
+			r.logger.Info("Daily report sent: date=%s, user=%s", date, u.UserName)
+		}
+		r.mark(marker)
+	}
+}
+
+// userSummary 生成单个用户当天的汇总，同时返回账单笔数；金额使用用户设置的货币符号
+func (r *DailyReport) userSummary(ctx context.Context, user *domain.UserMapping, start, end time.Time) (string, int, error) {
+	userName, currency := user.UserName, r.currency
+	if user.Preferences.Currency != "" {
+		currency = user.Preferences.Currency
+	}
+	bills, income, expense, err := r.billUseCase.QueryTransactions(ctx, userName, start, end, 0)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(bills) == 0 {
+		return fmt.Sprintf("👤 %s：今天还没有记账", userName), 0, nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "👤 %s：共 %d 笔\n💸 支出 %s　💰 收入 %s", userName, len(bills), domain.FormatAmount(currency, expense), domain.FormatAmount(currency, income))
+	shown := 0
+	for _, bill := range bills {
+		if bill.Type != domain.BillTypeExpense {
+			continue
+		}
+		if shown == 0 {
+			b.WriteString("\n最大几笔支出：")
+		}
+		fmt.Fprintf(&b, "\n  • %s %s（%s）", bill.Description, domain.FormatAmount(currency, bill.Amount), bill.Category)
+		shown++
+		if shown >= reportTopN {
+			break
+		}
+	}
+	return b.String(), len(bills), nil
+}
+
+// mark 记录已发送，写入失败时只记录日志
+func (r *DailyReport) mark(key string) {
+	if err := r.markers.Set(key, true, reportMarkerTTL); err != nil {
+		r.logger.Warn("Daily report: save marker %s failed: %v", key, err)
+	}
+}
+
+// parseClock 解析 HH:MM 格式的时间
+func parseClock(value string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
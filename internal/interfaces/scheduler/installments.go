@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+const (
+	// installmentTimeout 单次补记分期账单的超时时间
+	installmentTimeout = 10 * time.Minute
+	// installmentRetryInterval 补记失败后重试的间隔
+	installmentRetryInterval = time.Hour
+)
+
+// InstallmentScheduler 每月 1 日把到期的分期写入账本
+type InstallmentScheduler struct {
+	billUseCase domain.BillUseCase
+	location    *time.Location // 按该时区的月份补记
+	logger      logger.Logger
+}
+
+// NewInstallmentScheduler creates the installment scheduler running at the start of each month in location
+func NewInstallmentScheduler(billUseCase domain.BillUseCase, location *time.Location) *InstallmentScheduler {
+	return &InstallmentScheduler{
+		billUseCase: billUseCase,
+		location:    location,
+		logger:      logger.GetLogger(logComponent),
+	}
+}
+
+// Start 启动时立即补记一次，之后在每月 1 日零点运行，ctx 取消后返回
+// 补记本身是幂等的，重启后重复执行不会重复记账
+func (s *InstallmentScheduler) Start(ctx context.Context) {
+	for {
+		next := nextMonthStart(time.Now().In(s.location))
+		if !s.run(ctx) {
+			// 失败时稍后重试，不等到下个月
+			if retry := time.Now().Add(installmentRetryInterval); retry.Before(next) {
+				next = retry
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Installment scheduler stopped")
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// run 补记到期的分期，返回是否全部成功
+func (s *InstallmentScheduler) run(parent context.Context) bool {
+	now := time.Now().In(s.location)
+	ctx, cancel := context.WithTimeout(logger.WithCorrelationID(parent, "installments-"+now.Format("2006-01")), installmentTimeout)
+	defer cancel()
+
+	created, err := s.billUseCase.MaterializeInstallments(ctx, now)
+	if created > 0 {
+		s.logger.Info("Installments recorded: count=%d", created)
+	}
+	if err != nil {
+		s.logger.Error("Installment scheduler: %v", err)
+		return false
+	}
+	return true
+}
+
+// nextMonthStart 返回下个月 1 日零点
+func nextMonthStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, 1, 0)
+}
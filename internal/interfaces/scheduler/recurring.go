@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
+)
+
+const (
+	// recurringTimeout 单次执行周期记账的超时时间
+	recurringTimeout = 10 * time.Minute
+	// recurringRetryInterval 执行失败后重试的间隔
+	recurringRetryInterval = time.Hour
+)
+
+// RecurringScheduler 每天按配置的时间为到期的周期规则记账，并私聊通知用户
+type RecurringScheduler struct {
+	runAt         string
+	location      *time.Location // 执行时间和记账日期使用的时区
+	currency      string
+	feishuService *feishu.FeishuService
+	billUseCase   domain.BillUseCase
+	logger        logger.Logger
+}
+
+// NewRecurringScheduler creates the recurring bill scheduler running daily at runAt in location
+func NewRecurringScheduler(runAt string, location *time.Location, currency string, feishuService *feishu.FeishuService, billUseCase domain.BillUseCase) *RecurringScheduler {
+	return &RecurringScheduler{
+		runAt:         runAt,
+		location:      location,
+		currency:      currency,
+		feishuService: feishuService,
+		billUseCase:   billUseCase,
+		logger:        logger.GetLogger(logComponent),
+	}
+}
+
+// Start 启动时立即补记一次，之后每天在配置的时间运行，ctx 取消后返回
+// 时间按配置的时区（TIMEZONE）计算；补记本身是幂等的，重启后不会重复记账
+func (s *RecurringScheduler) Start(ctx context.Context) {
+	hour, minute, err := parseClock(s.runAt)
+	if err != nil {
+		s.logger.Error("Invalid RECURRING_RUN_AT %q, recurring bills disabled: %v", s.runAt, err)
+		return
+	}
+	s.logger.Info("Recurring bills scheduled at %02d:%02d", hour, minute)
+
+	for {
+		now := time.Now().In(s.location)
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		if !s.run(ctx) {
+			// 失败时稍后重试，不等到第二天
+			if retry := time.Now().Add(recurringRetryInterval); retry.Before(next) {
+				next = retry
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Recurring scheduler stopped")
+			return
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+// run 为到期的规则记账并发送通知，返回是否全部成功
+func (s *RecurringScheduler) run(parent context.Context) bool {
+	now := time.Now().In(s.location)
+	ctx, cancel := context.WithTimeout(logger.WithCorrelationID(parent, "recurring-"+now.Format("2006-01-02")), recurringTimeout)
+	defer cancel()
+
+	runs, err := s.billUseCase.RunRecurringRules(ctx, now)
+	for _, run := range runs {
+		s.notify(ctx, run)
+	}
+	if len(runs) > 0 {
+		s.logger.Info("Recurring bills recorded: count=%d", len(runs))
+	}
+	if err != nil {
+		s.logger.Error("Recurring scheduler: %v", err)
+		return false
+	}
+	return true
+}
+
+// notify 私聊通知规则所属用户，其他平台的用户暂不推送，发送失败只记录日志
+func (s *RecurringScheduler) notify(ctx context.Context, run domain.RecurringRun) {
+	if run.Rule.UserID == "" || domain.PlatformOf(run.Rule.UserID) != domain.PlatformFeishu {
+		return
+	}
+	bill := run.Bill
+	text := fmt.Sprintf("🔁 已自动记账：%s %s（%s）\n📅 %s\n🆔 %s",
+		bill.Description, domain.FormatAmount(s.currency, bill.Amount), bill.Category, bill.Date.Format("2006-01-02"), bill.RecordID)
+	if err := s.feishuService.SendMessage(ctx, run.Rule.UserID, text); err != nil {
+		s.logger.Error("Recurring scheduler: notify %s failed: %v", run.Rule.UserName, err)
+	}
+}
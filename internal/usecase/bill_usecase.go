@@ -1,183 +1,544 @@
 package usecase
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
 	"github.com/wyg1997/LedgerBot/pkg/logger"
 )
 
+const (
+	// categoryHistoryDays 分类建议回溯的历史天数
+	categoryHistoryDays = 180
+	// categoryHistoryLimit 分类建议最多参考的最近账单数量
+	categoryHistoryLimit = 200
+	// categoryStrongMinCount 历史记录“强烈建议”某分类所需的最少匹配次数
+	categoryStrongMinCount = 2
+	// categoryStrongMinRatio 历史记录“强烈建议”某分类所需的最低占比
+	categoryStrongMinRatio = 0.6
+)
+
+// logComponent 本包日志的组件名，可通过 LOG_LEVELS 单独设置级别
+const logComponent = "usecase"
+
 // BillUseCaseImpl implements BillUseCase
 type BillUseCaseImpl struct {
 	billRepo       domain.BillRepository
 	userMappingRepo domain.UserMappingRepository
-	logger         logger.Logger
+	aiService      domain.AIService
+
+	// 来源消息 -> 该消息创建的账单，重复处理同一条消息时返回已有账单，为 nil 时不去重
+	sourceIndex cache.Cache
+	sourceTTL   time.Duration
+	sourceMu    sync.Mutex
+
+	// 短时间内重复记录相同账单的检测策略
+	duplicates DuplicatePolicy
+
+	// 新账单的校验规则
+	validation ValidationPolicy
+
+	// 分期计划，为 nil 时不支持分期
+	installments domain.InstallmentRepository
+
+	// 周期记账规则，为 nil 时不支持周期记账
+	recurring domain.RecurringRepository
+
+	// 借贷索引，记录借款和还款的对方，为 nil 时不支持借贷
+	loans domain.LoanRepository
+
+	// 账单操作日志，用于撤销最近一次操作，为 nil 时不记录
+	journalRepo domain.OperationJournal
+
+	// 记账模板，为 nil 时不支持模板
+	templates domain.TemplateRepository
+
+	// 默认记账时间和日期计算使用的时区，用户设置的时区优先
+	location *time.Location
 }
 
 // NewBillUseCase creates a new bill use case
+// sourceIndex remembers the bills created by each source message for sourceTTL; nil disables it
+// installments stores installment plans; nil disables installments
+// recurring stores recurring rules; nil disables recurring bills
+// loans indexes loans and repayments by counterparty; nil disables loan tracking
+// journal keeps the latest operations of each user for undo; nil disables undo
+// templates stores the users' bill templates; nil disables templates
+// location is the time zone of default bill dates and date calculations; a user's own time zone takes precedence
 func NewBillUseCase(
 	billRepo domain.BillRepository,
 	userMappingRepo domain.UserMappingRepository,
+	aiService domain.AIService,
+	sourceIndex cache.Cache,
+	sourceTTL time.Duration,
+	duplicates DuplicatePolicy,
+	validation ValidationPolicy,
+	installments domain.InstallmentRepository,
+	recurring domain.RecurringRepository,
+	loans domain.LoanRepository,
+	journal domain.OperationJournal,
+	templates domain.TemplateRepository,
+	location *time.Location,
 ) domain.BillUseCase {
 	return &BillUseCaseImpl{
 		billRepo:        billRepo,
 		userMappingRepo: userMappingRepo,
-		logger:          logger.GetLogger(),
+		aiService:       aiService,
+		sourceIndex:     sourceIndex,
+		sourceTTL:       sourceTTL,
+		duplicates:      duplicates,
+		validation:      validation,
+		installments:    installments,
+		recurring:       recurring,
+		loans:           loans,
+		journalRepo:     journal,
+		templates:       templates,
+		location:        location,
 	}
 }
 
-// CreateBill creates a new bill with AI categorization if needed
-func (u *BillUseCaseImpl) CreateBill(userName string, userID string, originalMsg string, description string, amount float64, billType domain.BillType, date *time.Time, category *string) (*domain.Bill, error) {
-	u.logger.Info("BillUseCase.CreateBill called: userName=%s, userID=%s, description=%s, amount=%.2f, billType=%s, category=%v, originalMsg=%s",
-		userName, userID, description, amount, billType, category, originalMsg)
+// CreateBill creates a new bill with history-based categorization if needed
+func (u *BillUseCaseImpl) CreateBill(ctx context.Context, userName string, userID string, input domain.NewBillInput) (*domain.Bill, error) {
+	u.logFor(ctx).Info("BillUseCase.CreateBill called: userName=%s, userID=%s, description=%s, amount=%.2f, billType=%s, category=%s, account=%s, tags=%v, originalMsg=%s",
+		userName, userID, input.Description, input.Amount, input.Type, input.Category, input.Account, input.Tags, input.OriginalMsg)
 
-	// If category is not provided, use default
-	if category == nil || *category == "" {
-		defaultCat := "其他"
-		category = &defaultCat
-		u.logger.Info("Category not provided, using default: %s", defaultCat)
+	if err := u.validateInput(ctx, &input); err != nil {
+		return nil, err
 	}
 
-	// Generate bill ID
-	billID := fmt.Sprintf("%s_%d_%d", userName, time.Now().Unix(), rand.Int63n(1000))
-
-	// Set date to now if not provided
-	if date == nil {
-		now := time.Now()
-		date = &now
-		u.logger.Info("Date not provided, using current time: %s", date.Format(time.RFC3339))
+	// 同一条消息被重复处理时（如 webhook 重放），返回此前创建的账单
+	_, recorded := u.sourceRecords(ctx)
+	if existing := u.findRecorded(ctx, recorded, &domain.Bill{Description: input.Description, Amount: input.Amount, Type: input.Type, UserName: userName}); existing != nil {
+		return existing, nil
 	}
 
-	bill := &domain.Bill{
-		ID:          billID,
-		Description: description,
-		Amount:      amount,
-		Type:        billType,
-		Category:    *category,
-		Date:        *date,
-		UserName:    userName,
-		OriginalMsg: originalMsg,
+	bill := u.newBill(ctx, userName, input)
+
+	// 未指定日期的账单检测是否刚刚记录过同一笔
+	if input.Date == nil {
+		if err := u.checkDuplicate(ctx, u.recentBills(ctx, userName, bill.Date), bill, bill.Date); err != nil {
+			return nil, err
+		}
 	}
 
-	u.logger.Info("Calling billRepo.CreateBill: billID=%s, description=%s, amount=%.2f, type=%s, category=%s, userName=%s, date=%s",
-		bill.ID, bill.Description, bill.Amount, bill.Type, bill.Category, bill.UserName, bill.Date.Format(time.RFC3339))
+	u.logFor(ctx).Info("Calling billRepo.CreateBill: description=%s, amount=%.2f, type=%s, category=%s, userName=%s, date=%s",
+		bill.Description, bill.Amount, bill.Type, bill.Category, bill.UserName, bill.Date.Format(time.RFC3339))
 
-	if err := u.billRepo.CreateBill(bill); err != nil {
-		u.logger.Error("billRepo.CreateBill failed: %v, billID=%s, description=%s, amount=%.2f, type=%s, category=%s, userName=%s",
-			err, bill.ID, bill.Description, bill.Amount, bill.Type, bill.Category, bill.UserName)
-		return nil, fmt.Errorf("failed to create bill: %v", err)
+	if err := u.billRepo.CreateBill(ctx, bill); err != nil {
+		u.logFor(ctx).Error("billRepo.CreateBill failed: %v, description=%s, amount=%.2f, type=%s, category=%s, userName=%s",
+			err, bill.Description, bill.Amount, bill.Type, bill.Category, bill.UserName)
+		return nil, fmt.Errorf("failed to create bill: %w", err)
 	}
 
-	u.logger.Info("Bill created successfully: ID=%s, Description=%s, Amount=%.2f, Category=%s, UserName=%s, OriginalMsg=%s",
-		bill.ID, bill.Description, bill.Amount, bill.Category, bill.UserName, bill.OriginalMsg)
+	u.rememberRecorded(ctx, []*domain.Bill{bill})
+	u.journalCreated(ctx, userName, []*domain.Bill{bill})
+
+	u.logFor(ctx).Info("Bill created successfully: RecordID=%s, Description=%s, Amount=%.2f, Category=%s, UserName=%s, OriginalMsg=%s",
+		bill.RecordID, bill.Description, bill.Amount, bill.Category, bill.UserName, bill.OriginalMsg)
 	return bill, nil
 }
 
-// GetBill retrieves a bill by ID
-func (u *BillUseCaseImpl) GetBill(id string) (*domain.Bill, error) {
-	return u.billRepo.GetBill(id)
-}
+// CreateBills creates several bills in one batch
+func (u *BillUseCaseImpl) CreateBills(ctx context.Context, userName string, userID string, inputs []domain.NewBillInput) ([]*domain.Bill, error) {
+	u.logFor(ctx).Info("BillUseCase.CreateBills called: userName=%s, userID=%s, count=%d", userName, userID, len(inputs))
 
-// UpdateBill updates a bill
-// If id starts with "rec" (record_id format), it will update directly without querying
-func (u *BillUseCaseImpl) UpdateBill(id string, updates map[string]interface{}) (*domain.Bill, error) {
-	var bill *domain.Bill
-	
-	// If id is a record_id (starts with "rec"), update directly without querying
-	// This avoids the need to implement ListRecordsWithFilter for simple updates
-	if len(id) >= 3 && id[:3] == "rec" {
-		// Direct update by record_id - construct bill with only fields to update
-		bill = &domain.Bill{
-			ID:       id,
-			RecordID: id,
+	// 已由同一条消息创建过的账单直接返回，只写入其余的账单
+	_, recorded := u.sourceRecords(ctx)
+	bills := make([]*domain.Bill, len(inputs))
+	var pending []*domain.Bill
+	var pendingIdx []int
+	// 未通过校验或疑似重复而跳过的账单，下标对应全部输入
+	var skipped []error
+	now := time.Now()
+	var recent []*domain.Bill
+	recentLoaded := false
+	skip := func(i int, err error) {
+		if skipped == nil {
+			skipped = make([]error, len(inputs))
 		}
-		
-		// Apply updates to bill object (only non-empty values)
-		if desc, ok := updates["description"].(string); ok && desc != "" {
-			bill.Description = desc
+		skipped[i] = err
+	}
+	for i, in := range inputs {
+		if err := u.validateInput(ctx, &in); err != nil {
+			// 返回的账单与输入一一对应，未写入的账单没有 RecordID
+			bills[i] = &domain.Bill{Description: in.Description, Amount: in.Amount, Type: in.Type, UserName: userName}
+			skip(i, err)
+			continue
 		}
-		if amount, ok := updates["amount"].(float64); ok && amount > 0 {
-			bill.Amount = amount
+		if existing := u.findRecorded(ctx, recorded, &domain.Bill{Description: in.Description, Amount: in.Amount, Type: in.Type, UserName: userName}); existing != nil {
+			bills[i] = existing
+			continue
 		}
-		if category, ok := updates["category"].(string); ok && category != "" {
-			bill.Category = category
+		bills[i] = u.newBill(ctx, userName, in)
+		// 同一条消息中的相同账单视为有意记录多笔，只与之前记录的账单比较
+		if in.Date == nil {
+			if !recentLoaded {
+				recent, recentLoaded = u.recentBills(ctx, userName, now), true
+			}
+			if err := u.checkDuplicate(ctx, recent, bills[i], now); err != nil {
+				skip(i, err)
+				continue
+			}
 		}
-		if date, ok := updates["date"].(*time.Time); ok && date != nil {
-			bill.Date = *date
+		pending = append(pending, bills[i])
+		pendingIdx = append(pendingIdx, i)
+	}
+	if len(pending) == 0 {
+		if skipped != nil {
+			return bills, &domain.BatchCreateError{Errors: skipped}
 		}
-		if billType, ok := updates["type"].(domain.BillType); ok && billType != "" {
-			bill.Type = billType
+		return bills, nil
+	}
+
+	if err := u.billRepo.CreateBills(ctx, pending); err != nil {
+		if pendingErr, ok := err.(*domain.BatchCreateError); ok {
+			u.rememberRecorded(ctx, pending)
+			u.journalCreated(ctx, userName, pending)
+			u.logFor(ctx).Warn("billRepo.CreateBills partially failed: userName=%s, err=%v", userName, err)
+			// 错误下标对应全部输入
+			batchErr := &domain.BatchCreateError{Errors: make([]error, len(inputs))}
+			copy(batchErr.Errors, skipped)
+			for n, idx := range pendingIdx {
+				batchErr.Errors[idx] = pendingErr.Errors[n]
+			}
+			return bills, batchErr
 		}
-		if originalMsg, ok := updates["original_message"].(string); ok && originalMsg != "" {
-			bill.OriginalMsg = originalMsg
+		u.logFor(ctx).Error("billRepo.CreateBills failed: %v, userName=%s, count=%d", err, userName, len(pending))
+		return nil, fmt.Errorf("failed to create bills: %w", err)
+	}
+	u.rememberRecorded(ctx, pending)
+	u.journalCreated(ctx, userName, pending)
+
+	u.logFor(ctx).Info("Bills created successfully: userName=%s, count=%d", userName, len(pending))
+	if skipped != nil {
+		return bills, &domain.BatchCreateError{Errors: skipped}
+	}
+	return bills, nil
+}
+
+// newBill fills in defaults (category, date) for a bill that is about to be created
+// ID 由存储层在写入后设置为记录 ID
+func (u *BillUseCaseImpl) newBill(ctx context.Context, userName string, in domain.NewBillInput) *domain.Bill {
+	// If category is not provided, use default
+	category := in.Category
+	if category == "" {
+		category = domain.CategoryOther
+		u.logFor(ctx).Info("Category not provided, using default: %s", category)
+	}
+
+	// AI 归类为“其它”时，如果历史记录强烈指向另一个分类，则使用历史分类
+	categoryFromHistory := false
+	if isOtherCategory(category) {
+		if suggested, ok := u.strongHistoryCategory(ctx, userName, in.Description); ok {
+			u.logFor(ctx).Info("Category overridden by history: description=%s, %s -> %s", in.Description, category, suggested)
+			category = suggested
+			categoryFromHistory = true
 		}
+	}
+
+	// Set date to now if not provided
+	date := u.now(ctx)
+	if in.Date != nil {
+		date = *in.Date
 	} else {
-		// Traditional flow: get bill first, then update
-		var err error
-		bill, err = u.billRepo.GetBill(id)
-		if err != nil {
-			return nil, err
-		}
+		u.logFor(ctx).Info("Date not provided, using current time: %s", date.Format(time.RFC3339))
+	}
 
-		// Apply updates
-		if desc, ok := updates["description"].(string); ok {
-			bill.Description = desc
-		}
-		if amount, ok := updates["amount"].(float64); ok {
-			bill.Amount = amount
-		}
-		if category, ok := updates["category"].(string); ok {
-			bill.Category = category
-		}
-		if date, ok := updates["date"].(*time.Time); ok {
-			bill.Date = *date
-		}
-		if billType, ok := updates["type"].(domain.BillType); ok {
-			bill.Type = billType
-		}
-		if originalMsg, ok := updates["original_message"].(string); ok {
-			bill.OriginalMsg = originalMsg
+	var reimbursable *bool
+	if in.Reimbursable {
+		reimbursable = &in.Reimbursable
+	}
+
+	return &domain.Bill{
+		Description: in.Description,
+		Amount:      in.Amount,
+		Type:        in.Type,
+		Category:    category,
+		Date:        date,
+		UserName:    userName,
+		OriginalMsg: in.OriginalMsg,
+		Account:     in.Account,
+		Tags:        domain.NormalizeTags(in.Tags),
+		Reimbursable: reimbursable,
+		// 来自收据图片的账单附上原图，每条账单使用各自的副本
+		Attachments: domain.AttachmentsFromContext(ctx),
+
+		CategoryFromHistory: categoryFromHistory,
+	}
+}
+
+// GetBill retrieves a bill by ID
+func (u *BillUseCaseImpl) GetBill(ctx context.Context, id string) (*domain.Bill, error) {
+	return u.billRepo.GetBill(ctx, id)
+}
+
+// UpdateBill updates a bill by its record ID
+// 只写入更新中非空的字段，存储层按部分更新处理，不需要先查询整条记录
+func (u *BillUseCaseImpl) UpdateBill(ctx context.Context, id string, updates map[string]interface{}) (*domain.Bill, error) {
+	// 记录修改前的内容，撤销时写回
+	var before *domain.Bill
+	if u.journalRepo != nil {
+		if b, err := u.billRepo.GetBill(ctx, id); err == nil {
+			before = journalSnapshot(b)
 		}
 	}
 
+	bill := &domain.Bill{
+		ID:       id,
+		RecordID: id,
+	}
+	if desc, ok := updates["description"].(string); ok && desc != "" {
+		bill.Description = desc
+	}
+	if amount, ok := updates["amount"].(float64); ok && amount > 0 {
+		bill.Amount = domain.RoundAmount(amount)
+	}
+	if category, ok := updates["category"].(string); ok && category != "" {
+		bill.Category = category
+	}
+	if date, ok := updates["date"].(*time.Time); ok && date != nil {
+		bill.Date = *date
+	}
+	if billType, ok := updates["type"].(domain.BillType); ok && billType != "" {
+		bill.Type = billType
+	}
+	if originalMsg, ok := updates["original_message"].(string); ok && originalMsg != "" {
+		bill.OriginalMsg = originalMsg
+	}
+	if account, ok := updates["account"].(string); ok && account != "" {
+		bill.Account = account
+	}
+	if tags, ok := updates["tags"].([]string); ok && len(tags) > 0 {
+		bill.Tags = domain.NormalizeTags(tags)
+	}
+	if reimbursable, ok := updates["reimbursable"].(bool); ok {
+		bill.Reimbursable = &reimbursable
+	}
+
 	// Update through repository (supports partial updates)
-	if err := u.billRepo.UpdateBill(bill); err != nil {
-		return nil, fmt.Errorf("failed to update bill: %v", err)
+	if err := u.billRepo.UpdateBill(ctx, bill); err != nil {
+		return nil, fmt.Errorf("failed to update bill: %w", err)
 	}
 
-	// Ensure RecordID is set for return value
-	if bill.RecordID == "" {
-		bill.RecordID = id
+	u.syncLoanAmount(ctx, bill)
+
+	if before != nil {
+		u.journal(ctx, journalOperator(ctx, before.UserName), &domain.Operation{Kind: domain.OperationUpdate, RecordID: before.RecordID, Before: before, After: journalSnapshot(bill)})
 	}
 
 	return bill, nil
 }
 
 // DeleteBill deletes a bill
-func (u *BillUseCaseImpl) DeleteBill(id string) error {
-	return u.billRepo.DeleteBill(id)
+func (u *BillUseCaseImpl) DeleteBill(ctx context.Context, id string) error {
+	// 记录删除前的内容，撤销时用于恢复
+	var before *domain.Bill
+	if u.journalRepo != nil {
+		before, _ = u.billRepo.GetBill(ctx, id)
+	}
+	if err := u.billRepo.DeleteBill(ctx, id); err != nil {
+		return err
+	}
+	u.markLoanDeleted(ctx, id, true)
+
+	operator := ""
+	if before != nil {
+		operator = before.UserName
+	}
+	u.journal(ctx, journalOperator(ctx, operator), &domain.Operation{Kind: domain.OperationDelete, RecordID: id, Before: journalSnapshot(before)})
+	return nil
+}
+
+// RestoreBill restores a soft-deleted bill
+func (u *BillUseCaseImpl) RestoreBill(ctx context.Context, id string) error {
+	if err := u.billRepo.RestoreBill(ctx, id); err != nil {
+		return err
+	}
+	u.markLoanDeleted(ctx, id, false)
+	return nil
 }
 
 // ListUserBills lists bills for a user with filtering
-func (u *BillUseCaseImpl) ListUserBills(userID string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
-	return u.billRepo.ListBills(userID, startDate, endDate, billType, category, offset, limit)
+func (u *BillUseCaseImpl) ListUserBills(ctx context.Context, userID string, startDate, endDate *time.Time, billType *domain.BillType, category *string, offset, limit int) ([]*domain.Bill, int, error) {
+	return u.billRepo.ListBills(ctx, userID, startDate, endDate, billType, category, offset, limit)
 }
 
 // GetMonthlySummary gets monthly summary for a user
-func (u *BillUseCaseImpl) GetMonthlySummary(userID string, year, month int) (*domain.MonthlySummary, error) {
-	return u.billRepo.GetMonthlySummary(userID, year, month)
+func (u *BillUseCaseImpl) GetMonthlySummary(ctx context.Context, userID string, year, month int) (*domain.MonthlySummary, error) {
+	return u.billRepo.GetMonthlySummary(ctx, userID, year, month)
 }
 
 // QueryTransactions queries transactions within a time range
-func (u *BillUseCaseImpl) QueryTransactions(userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
-	return u.billRepo.QueryTransactions(userName, startTime, endTime, topN)
+func (u *BillUseCaseImpl) QueryTransactions(ctx context.Context, userName string, startTime, endTime time.Time, topN int) ([]*domain.Bill, float64, float64, error) {
+	return u.billRepo.QueryTransactions(ctx, scopeUserName(ctx, userName), startTime, endTime, topN)
+}
+
+// QueryTransactionsPage queries one page of transactions ordered by date descending
+func (u *BillUseCaseImpl) QueryTransactionsPage(ctx context.Context, userName string, startTime, endTime time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	return u.billRepo.QueryTransactionsPage(ctx, scopeUserName(ctx, userName), startTime, endTime, pageToken, pageSize)
 }
 
 // SuggestCategory suggests category for a bill description
-func (u *BillUseCaseImpl) SuggestCategory(userID string, description string) ([]string, error) {
-	// TODO: Implement AI-based category suggestion
-	// For now, return empty suggestions
-	return []string{}, nil
-}
\ No newline at end of file
+// 优先根据用户历史记录匹配，历史中没有相似描述时回退到 AI 分类
+func (u *BillUseCaseImpl) SuggestCategory(ctx context.Context, userName string, description string) ([]string, error) {
+	counts, err := u.historyCategoryCounts(ctx, userName, description)
+	if err != nil {
+		u.logFor(ctx).Warn("SuggestCategory: failed to load history, falling back to AI: %v", err)
+	}
+
+	if len(counts) > 0 {
+		return rankCategories(counts), nil
+	}
+
+	if u.aiService == nil {
+		return []string{}, nil
+	}
+
+	category, err := u.aiService.ClassifyCategory(ctx, description, domain.DefaultCategories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify category: %v", err)
+	}
+	return []string{category}, nil
+}
+
+// strongHistoryCategory 当历史记录强烈指向某个非“其它”分类时返回该分类
+func (u *BillUseCaseImpl) strongHistoryCategory(ctx context.Context, userName, description string) (string, bool) {
+	counts, err := u.historyCategoryCounts(ctx, userName, description)
+	if err != nil {
+		u.logFor(ctx).Warn("Failed to load category history: %v", err)
+		return "", false
+	}
+	return strongCategory(counts)
+}
+
+// historyCategoryCounts 统计用户最近账单中与描述相似的记录的分类频次
+func (u *BillUseCaseImpl) historyCategoryCounts(ctx context.Context, userName, description string) (map[string]int, error) {
+	target := normalizeDescription(description)
+	if userName == "" || target == "" {
+		return nil, nil
+	}
+
+	endTime := time.Now()
+	startTime := endTime.AddDate(0, 0, -categoryHistoryDays)
+	bills, _, _, err := u.billRepo.QueryTransactions(ctx, userName, startTime, endTime, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只参考当前用户最近的账单
+	recent := make([]*domain.Bill, 0, len(bills))
+	for _, bill := range bills {
+		if bill.UserName == userName {
+			recent = append(recent, bill)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[i].Date.After(recent[j].Date)
+	})
+	if len(recent) > categoryHistoryLimit {
+		recent = recent[:categoryHistoryLimit]
+	}
+
+	return matchCategoryCounts(recent, target), nil
+}
+
+// matchCategoryCounts 构建 描述→分类 频次表，并统计与目标描述相互包含的记录的分类频次
+func matchCategoryCounts(bills []*domain.Bill, target string) map[string]int {
+	byDescription := make(map[string]map[string]int)
+	for _, bill := range bills {
+		desc := normalizeDescription(bill.Description)
+		if desc == "" || bill.Category == "" {
+			continue
+		}
+		if byDescription[desc] == nil {
+			byDescription[desc] = make(map[string]int)
+		}
+		byDescription[desc][bill.Category]++
+	}
+
+	counts := make(map[string]int)
+	for desc, categories := range byDescription {
+		if !strings.Contains(desc, target) && !strings.Contains(target, desc) {
+			continue
+		}
+		for category, n := range categories {
+			counts[category] += n
+		}
+	}
+	return counts
+}
+
+// strongCategory 判断频次表是否强烈指向某个非“其它”分类
+func strongCategory(counts map[string]int) (string, bool) {
+	ranked := rankCategories(counts)
+	if len(ranked) == 0 {
+		return "", false
+	}
+
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+
+	top := ranked[0]
+	if isOtherCategory(top) || counts[top] < categoryStrongMinCount {
+		return "", false
+	}
+	if float64(counts[top])/float64(total) < categoryStrongMinRatio {
+		return "", false
+	}
+	return top, true
+}
+
+// rankCategories 按频次降序排列分类，频次相同按名称排序保证结果稳定
+func rankCategories(counts map[string]int) []string {
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// normalizeDescription 规范化描述：转小写，去掉空白、标点和数字
+func normalizeDescription(description string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(description) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsDigit(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isOtherCategory 判断是否为兜底分类（兼容“其它”和“其他”两种写法）
+func isOtherCategory(category string) bool {
+	return category == domain.CategoryOther || category == "其他"
+}
+// logFor 返回请求上下文中的日志记录器，日志行带有消息的关联 ID
+func (u *BillUseCaseImpl) logFor(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, logComponent)
+}
+
+// locationFor 返回用户设置的时区，未设置时为配置的时区
+func (u *BillUseCaseImpl) locationFor(ctx context.Context) *time.Location {
+	return domain.PreferencesFromContext(ctx).Location(u.location)
+}
+
+// now 返回用户时区的当前时间
+func (u *BillUseCaseImpl) now(ctx context.Context) time.Time {
+	return time.Now().In(u.locationFor(ctx))
+}
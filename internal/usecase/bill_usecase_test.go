@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+)
+
+// 创建返回的 record ID 可以直接用于查询、修改和删除
+func TestBillRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bills.json")
+	fileRepo, err := repository.NewFileBillRepository(file, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := map[string]domain.BillRepository{
+		"memory": repository.NewMemoryBillRepository(time.UTC),
+		"file":   fileRepo,
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			u := NewBillUseCase(repo, nil, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+
+			created, err := u.CreateBill(ctx, "张三", "ou_1", domain.NewBillInput{Description: "午饭", Amount: 35, Type: domain.BillTypeExpense, Category: "餐饮"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := created.RecordID
+			if id == "" || created.ID != id {
+				t.Fatalf("created ID = %q, RecordID = %q, want the same non-empty record ID", created.ID, id)
+			}
+
+			got, err := u.GetBill(ctx, id)
+			if err != nil || got.RecordID != id || got.Description != "午饭" {
+				t.Fatalf("GetBill(%s) = %+v, %v, want the created bill", id, got, err)
+			}
+
+			updated, err := u.UpdateBill(ctx, id, map[string]interface{}{"amount": 40.0})
+			if err != nil || updated.RecordID != id {
+				t.Fatalf("UpdateBill(%s) = %+v, %v", id, updated, err)
+			}
+			got, err = u.GetBill(ctx, id)
+			if err != nil || got.Amount != 40 || got.Description != "午饭" || got.Category != "餐饮" {
+				t.Errorf("after update = %+v, %v, want only the amount changed", got, err)
+			}
+
+			if err := u.DeleteBill(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := u.GetBill(ctx, id); !errors.Is(err, domain.ErrBillNotFound) {
+				t.Errorf("GetBill after delete = %v, want ErrBillNotFound", err)
+			}
+			if err := u.DeleteBill(ctx, id); !errors.Is(err, domain.ErrBillNotFound) {
+				t.Errorf("second DeleteBill = %v, want ErrBillNotFound", err)
+			}
+		})
+	}
+}
+
+// 写入文件的账单重新加载后仍能用同一个 record ID 找到
+func TestBillRoundTripReload(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "bills.json")
+	repo, err := repository.NewFileBillRepository(file, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewBillUseCase(repo, nil, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+	created, err := u.CreateBill(ctx, "张三", "ou_1", domain.NewBillInput{Description: "打车", Amount: 23.5, Type: domain.BillTypeExpense})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := repository.NewFileBillRepository(file, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u = NewBillUseCase(reloaded, nil, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+	if _, err := u.UpdateBill(ctx, created.RecordID, map[string]interface{}{"description": "打车回家"}); err != nil {
+		t.Fatalf("UpdateBill after reload = %v", err)
+	}
+	got, err := u.GetBill(ctx, created.RecordID)
+	if err != nil || got.ID != created.RecordID || got.Description != "打车回家" {
+		t.Errorf("GetBill after reload = %+v, %v, want the updated bill", got, err)
+	}
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// DeleteBills deletes the given bills in one batch.
+// 删除前把每条账单的完整内容写入日志，误删时可以据此恢复；删除成功后逐条记入操作日志
+func (u *BillUseCaseImpl) DeleteBills(ctx context.Context, bills []*domain.Bill) error {
+	u.logFor(ctx).Info("BillUseCase.DeleteBills called: count=%d", len(bills))
+	if len(bills) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(bills))
+	for _, bill := range bills {
+		u.logFor(ctx).Info("Deleting bill: record_id=%s, id=%s, user=%s, date=%s, type=%s, amount=%.2f, category=%s, description=%s, account=%s, tags=%v, originalMsg=%s",
+			bill.RecordID, bill.ID, bill.UserName, bill.Date.Format(time.RFC3339), bill.Type, bill.Amount, bill.Category, bill.Description, bill.Account, bill.Tags, bill.OriginalMsg)
+		ids = append(ids, bill.RecordID)
+	}
+	if err := u.billRepo.DeleteBills(ctx, ids); err != nil {
+		u.logFor(ctx).Error("billRepo.DeleteBills failed: count=%d, err=%v", len(ids), err)
+		return fmt.Errorf("failed to delete %d bills: %w", len(ids), err)
+	}
+
+	for _, bill := range bills {
+		u.markLoanDeleted(ctx, bill.RecordID, true)
+		u.journal(ctx, journalOperator(ctx, bill.UserName), &domain.Operation{Kind: domain.OperationDelete, RecordID: bill.RecordID, Before: journalSnapshot(bill)})
+	}
+	u.logFor(ctx).Info("Bills deleted: count=%d, record_ids=%v", len(ids), ids)
+	return nil
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// DuplicatePolicy configures detection of bills recorded twice in a short time (e.g. a double-tapped send)
+type DuplicatePolicy struct {
+	Window       time.Duration // 回溯的时间窗口，0 表示不检测
+	RecordAnyway bool          // 为 true 时仍然写入，只在回复中提示；否则跳过并请用户确认
+}
+
+// sameTransaction 判断两笔账单的描述、金额和收支类型是否相同
+func sameTransaction(description string, amount float64, billType domain.BillType, bill *domain.Bill) bool {
+	return billType == bill.Type &&
+		math.Abs(amount-bill.Amount) < 0.005 &&
+		strings.TrimSpace(description) == strings.TrimSpace(bill.Description)
+}
+
+// recentBills 返回用户在检测窗口内记录的账单；未开启检测、用户已确认记录或查询失败时返回 nil
+func (u *BillUseCaseImpl) recentBills(ctx context.Context, userName string, now time.Time) []*domain.Bill {
+	if u.duplicates.Window <= 0 || domain.DuplicatesAllowed(ctx) {
+		return nil
+	}
+	since := now.Add(-u.duplicates.Window)
+	bills, _, _, err := u.billRepo.QueryTransactions(ctx, userName, since, now, 0)
+	if err != nil {
+		// 检测失败不影响记账
+		u.logFor(ctx).Warn("Failed to query recent bills for duplicate detection: userName=%s, err=%v", userName, err)
+		return nil
+	}
+	return bills
+}
+
+// findDuplicate 在最近的账单中查找与新账单相同的一笔，返回最近的一笔
+// 只检测日期为当前时间的账单：窗口之外的账单即使相同也不算重复，窗口起点本身计入窗口
+func findDuplicate(recent []*domain.Bill, bill *domain.Bill, now time.Time, window time.Duration) *domain.Bill {
+	since := now.Add(-window)
+	var found *domain.Bill
+	for _, b := range recent {
+		if b.Date.Before(since) || b.Date.After(now) {
+			continue
+		}
+		if !sameTransaction(bill.Description, bill.Amount, bill.Type, b) {
+			continue
+		}
+		if found == nil || b.Date.After(found.Date) {
+			found = b
+		}
+	}
+	return found
+}
+
+// checkDuplicate 检测新账单是否疑似重复
+// 按策略返回 DuplicateBillError 跳过写入，或标记 DuplicateOf 后照常写入
+func (u *BillUseCaseImpl) checkDuplicate(ctx context.Context, recent []*domain.Bill, bill *domain.Bill, now time.Time) error {
+	existing := findDuplicate(recent, bill, now, u.duplicates.Window)
+	if existing == nil {
+		return nil
+	}
+	u.logFor(ctx).Info("Possible duplicate bill: description=%s, amount=%.2f, existing=%s, record_anyway=%v",
+		bill.Description, bill.Amount, existing.RecordID, u.duplicates.RecordAnyway)
+	if u.duplicates.RecordAnyway {
+		bill.DuplicateOf = existing.RecordID
+		return nil
+	}
+	return &domain.DuplicateBillError{Existing: existing}
+}
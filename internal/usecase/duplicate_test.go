@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+)
+
+func TestFindDuplicateWindow(t *testing.T) {
+	now := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	window := 10 * time.Minute
+	bill := &domain.Bill{Description: "午饭", Amount: 35, Type: domain.BillTypeExpense}
+	at := func(id string, d time.Duration) *domain.Bill {
+		return &domain.Bill{RecordID: id, Description: "午饭", Amount: 35, Type: domain.BillTypeExpense, Date: now.Add(d)}
+	}
+
+	tests := []struct {
+		name   string
+		recent []*domain.Bill
+		want   string // 空表示不算重复
+	}{
+		// 窗口起点本身计入窗口
+		{"at window start", []*domain.Bill{at("rec_start", -window)}, "rec_start"},
+		{"just before window", []*domain.Bill{at("rec_old", -window-time.Nanosecond)}, ""},
+		{"just now", []*domain.Bill{at("rec_now", 0)}, "rec_now"},
+		// 晚于当前时间的账单是手动指定日期的，不算重复
+		{"in the future", []*domain.Bill{at("rec_future", time.Nanosecond)}, ""},
+		{"most recent match", []*domain.Bill{at("rec_1", -8*time.Minute), at("rec_2", -time.Minute), at("rec_3", -5*time.Minute)}, "rec_2"},
+		{"amount within a cent", []*domain.Bill{{RecordID: "rec_round", Description: " 午饭 ", Amount: 35.004, Type: domain.BillTypeExpense, Date: now}}, "rec_round"},
+		{"different amount", []*domain.Bill{{RecordID: "rec_amount", Description: "午饭", Amount: 35.01, Type: domain.BillTypeExpense, Date: now}}, ""},
+		{"different type", []*domain.Bill{{RecordID: "rec_type", Description: "午饭", Amount: 35, Type: domain.BillTypeIncome, Date: now}}, ""},
+		{"different description", []*domain.Bill{{RecordID: "rec_desc", Description: "晚饭", Amount: 35, Type: domain.BillTypeExpense, Date: now}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findDuplicate(tt.recent, bill, now, window)
+			switch {
+			case tt.want == "" && got != nil:
+				t.Errorf("findDuplicate = %s, want none", got.RecordID)
+			case tt.want != "" && (got == nil || got.RecordID != tt.want):
+				t.Errorf("findDuplicate = %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func newDuplicateTestUseCase(policy DuplicatePolicy) *BillUseCaseImpl {
+	return NewBillUseCase(repository.NewMemoryBillRepository(time.UTC), nil, nil, nil, 0,
+		policy, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC).(*BillUseCaseImpl)
+}
+
+var lunchInput = domain.NewBillInput{Description: "午饭", Amount: 35, Type: domain.BillTypeExpense, Category: "餐饮"}
+
+func TestCreateBillDuplicate(t *testing.T) {
+	ctx := context.Background()
+	u := newDuplicateTestUseCase(DuplicatePolicy{Window: 10 * time.Minute})
+	first, err := u.CreateBill(ctx, "张三", "ou_1", lunchInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = u.CreateBill(ctx, "张三", "ou_1", lunchInput)
+	var dup *domain.DuplicateBillError
+	if !errors.As(err, &dup) || dup.Existing.RecordID != first.RecordID {
+		t.Fatalf("second CreateBill = %v, want a DuplicateBillError for %s", err, first.RecordID)
+	}
+	// 其他用户的相同账单不算重复
+	if _, err := u.CreateBill(ctx, "李四", "ou_2", lunchInput); err != nil {
+		t.Errorf("CreateBill for another user = %v", err)
+	}
+	// 用户确认后照常记录
+	if _, err := u.CreateBill(domain.WithDuplicatesAllowed(ctx), "张三", "ou_1", lunchInput); err != nil {
+		t.Errorf("CreateBill with duplicates allowed = %v", err)
+	}
+}
+
+// 窗口之前记录的相同账单不算重复
+func TestCreateBillDuplicateOutsideWindow(t *testing.T) {
+	ctx := context.Background()
+	u := newDuplicateTestUseCase(DuplicatePolicy{Window: 10 * time.Minute})
+	old := lunchInput
+	date := time.Now().Add(-11 * time.Minute)
+	old.Date = &date
+	if _, err := u.CreateBill(ctx, "张三", "ou_1", old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.CreateBill(ctx, "张三", "ou_1", lunchInput); err != nil {
+		t.Errorf("CreateBill after the window = %v, want no duplicate", err)
+	}
+}
+
+func TestCreateBillDuplicateRecordAnyway(t *testing.T) {
+	ctx := context.Background()
+	u := newDuplicateTestUseCase(DuplicatePolicy{Window: 10 * time.Minute, RecordAnyway: true})
+	first, err := u.CreateBill(ctx, "张三", "ou_1", lunchInput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := u.CreateBill(ctx, "张三", "ou_1", lunchInput)
+	if err != nil || second.DuplicateOf != first.RecordID || second.RecordID == first.RecordID {
+		t.Errorf("second CreateBill = %+v, %v, want a new bill marked as duplicate of %s", second, err, first.RecordID)
+	}
+}
+
+// 窗口为 0 时不检测
+func TestCreateBillDuplicateDisabled(t *testing.T) {
+	ctx := context.Background()
+	u := newDuplicateTestUseCase(DuplicatePolicy{})
+	for i := 0; i < 2; i++ {
+		if _, err := u.CreateBill(ctx, "张三", "ou_1", lunchInput); err != nil {
+			t.Fatalf("CreateBill #%d = %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// exportPageSize 导出时每次从仓库读取的记录数（多维表格单页上限）
+const exportPageSize = 500
+
+// utf8BOM 让 Excel 按 UTF-8 识别中文
+const utf8BOM = "\xEF\xBB\xBF"
+
+// exportHeader CSV 的表头
+var exportHeader = []string{"date", "description", "amount", "type", "category", "user", "record_id"}
+
+// ExportTransactions streams the transactions within a time range to w as RFC 4180 CSV.
+// 按页读取并逐页写出，不在内存中拼接整个文件；第一页读取成功后才开始写入
+func (u *BillUseCaseImpl) ExportTransactions(ctx context.Context, userName string, startTime, endTime time.Time, w io.Writer) (int, error) {
+	userName = scopeUserName(ctx, userName)
+	count, err := u.writeCSV(ctx, userName, startTime, endTime, w, nil)
+	if err != nil {
+		return count, err
+	}
+	u.logFor(ctx).Info("Exported transactions: user=%s, start=%s, end=%s, rows=%d",
+		userName, startTime.Format("2006-01-02"), endTime.Format("2006-01-02"), count)
+	return count, nil
+}
+
+// writeCSV 分页查询账单并写出 CSV，keep 不为 nil 时只写出 keep 返回 true 的账单
+func (u *BillUseCaseImpl) writeCSV(ctx context.Context, userName string, startTime, endTime time.Time, w io.Writer, keep func(bill *domain.Bill) bool) (int, error) {
+	bills, next, err := u.billRepo.QueryTransactionsPage(ctx, userName, startTime, endTime, "", exportPageSize)
+	if err != nil {
+		return 0, err
+	}
+
+	cw, err := newExportCSV(w)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		for _, bill := range bills {
+			if keep != nil && !keep(bill) {
+				continue
+			}
+			if err := cw.Write(exportRow(bill)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return count, err
+		}
+		if next == "" {
+			return count, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		bills, next, err = u.billRepo.QueryTransactionsPage(ctx, userName, startTime, endTime, next, exportPageSize)
+		if err != nil {
+			return count, fmt.Errorf("export stopped after %d rows: %v", count, err)
+		}
+	}
+}
+
+// newExportCSV 写出 BOM 和表头，返回用于写入账单行的 CSV writer
+func newExportCSV(w io.Writer) (*csv.Writer, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	if err := cw.Write(exportHeader); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// exportRow 账单对应的 CSV 行，与 exportHeader 的列一一对应
+func exportRow(bill *domain.Bill) []string {
+	return []string{
+		bill.Date.Format("2006-01-02 15:04:05"),
+		bill.Description,
+		fmt.Sprintf("%.2f", bill.Amount),
+		strings.ToLower(string(bill.Type)),
+		bill.Category,
+		bill.UserName,
+		bill.RecordID,
+	}
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// sourceRecord 来源消息创建过的一笔账单
+type sourceRecord struct {
+	RecordID    string          `json:"record_id"`
+	Description string          `json:"description"`
+	Amount      float64         `json:"amount"`
+	Type        domain.BillType `json:"type"`
+	UserName    string          `json:"user_name,omitempty"`
+}
+
+// sourceKey 消息创建的账单在索引中的键
+func sourceKey(messageID string) string {
+	return "source:" + messageID
+}
+
+// matches 判断待创建的账单是否就是该消息此前创建过的账单
+// 同一条消息可能包含多笔账单，按描述、金额和收支类型区分；AA 分摊时还可能为不同成员各记一笔，按用户区分
+func (r sourceRecord) matches(bill *domain.Bill) bool {
+	if r.UserName != "" && r.UserName != bill.UserName {
+		return false
+	}
+	return sameTransaction(r.Description, r.Amount, r.Type, bill)
+}
+
+// sourceRecords 返回上下文中来源消息已创建的账单，未开启索引或没有来源消息时返回空
+func (u *BillUseCaseImpl) sourceRecords(ctx context.Context) (string, []sourceRecord) {
+	messageID := domain.SourceMessageFromContext(ctx)
+	if u.sourceIndex == nil || messageID == "" {
+		return "", nil
+	}
+	var records []sourceRecord
+	if err := u.sourceIndex.Get(sourceKey(messageID), &records); err != nil {
+		return messageID, nil
+	}
+	return messageID, records
+}
+
+// findRecorded 查找来源消息此前为同一笔账单创建的记录
+// 记录已被删除时返回 nil，按新账单重新创建
+func (u *BillUseCaseImpl) findRecorded(ctx context.Context, records []sourceRecord, bill *domain.Bill) *domain.Bill {
+	for _, rec := range records {
+		if !rec.matches(bill) {
+			continue
+		}
+		existing, err := u.billRepo.GetBill(ctx, rec.RecordID)
+		if err != nil {
+			if !errors.Is(err, domain.ErrBillNotFound) {
+				u.logFor(ctx).Warn("Failed to load previously recorded bill, creating a new one: record_id=%s, err=%v", rec.RecordID, err)
+			}
+			continue
+		}
+		existing.AlreadyRecorded = true
+		u.logFor(ctx).Info("Bill already recorded by the same message, skipping: message_id=%s, record_id=%s",
+			domain.SourceMessageFromContext(ctx), existing.RecordID)
+		return existing
+	}
+	return nil
+}
+
+// rememberRecorded 把新建的账单加入来源消息的索引
+func (u *BillUseCaseImpl) rememberRecorded(ctx context.Context, bills []*domain.Bill) {
+	u.sourceMu.Lock()
+	defer u.sourceMu.Unlock()
+
+	messageID, records := u.sourceRecords(ctx)
+	if messageID == "" {
+		return
+	}
+	added := false
+	for _, bill := range bills {
+		if bill == nil || bill.RecordID == "" || bill.AlreadyRecorded {
+			continue
+		}
+		records = append(records, sourceRecord{
+			RecordID:    bill.RecordID,
+			Description: bill.Description,
+			Amount:      bill.Amount,
+			Type:        bill.Type,
+			UserName:    bill.UserName,
+		})
+		added = true
+	}
+	if !added {
+		return
+	}
+	if err := u.sourceIndex.Set(sourceKey(messageID), records, u.sourceTTL); err != nil {
+		u.logFor(ctx).Warn("Failed to remember bills of message: message_id=%s, err=%v", messageID, err)
+	}
+}
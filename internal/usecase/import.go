@@ -0,0 +1,294 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// importBatchSize 导入时每次写入仓库的账单数（多维表格批量创建的上限）
+const importBatchSize = 500
+
+// importColumnAliases 各字段默认识别的表头，包含导出文件的表头，比较时忽略大小写
+var importColumnAliases = map[string][]string{
+	"date":        {"date", "日期", "时间", "交易时间", "记账时间"},
+	"description": {"description", "描述", "备注", "说明", "商品", "名称"},
+	"amount":      {"amount", "金额", "金额(元)", "金额（元）"},
+	"type":        {"type", "类型", "收支", "收支类型", "收/支"},
+	"category":    {"category", "分类", "类别", "交易分类"},
+}
+
+// importDateLayouts 支持的日期格式，解析前 "/" 和 "." 统一替换为 "-"
+var importDateLayouts = []string{
+	"2006-1-2 15:04:05",
+	"2006-1-2 15:04",
+	"2006-1-2",
+}
+
+// ImportRow is one parsed CSV row ready to be imported
+type ImportRow struct {
+	Line  int // CSV 中的行号，表头为第 1 行
+	Input domain.NewBillInput
+}
+
+// ImportRowError describes why a CSV row could not be imported
+type ImportRowError struct {
+	Line   int
+	Reason string // 展示给用户的原因，如“金额无效”
+}
+
+// ParseImportCSV parses bills from a CSV file. columns maps date/description/amount/type/category
+// to custom headers and takes precedence over the built-in aliases; dates are read in location.
+// Rows that fail validation are returned as ImportRowError and do not stop parsing;
+// an error is returned only when the file as a whole cannot be used.
+func ParseImportCSV(r io.Reader, columns map[string]string, location *time.Location) ([]ImportRow, []ImportRowError, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取文件失败：%v", err)
+	}
+	data = bytes.TrimPrefix(data, []byte(utf8BOM))
+	if !utf8.Valid(data) {
+		return nil, nil, errors.New("文件不是 UTF-8 编码，请在 Excel 中另存为“CSV UTF-8”格式")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var index map[string]int
+	var rows []ImportRow
+	var failures []ImportRowError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			failures = append(failures, ImportRowError{Line: parseErr.StartLine, Reason: "格式错误"})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("解析文件失败：%v", err)
+		}
+		if blankRecord(record) {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if index == nil {
+			if index, err = importColumnIndex(record, columns); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		input, reason := parseImportRecord(record, index, location)
+		if reason != "" {
+			failures = append(failures, ImportRowError{Line: line, Reason: reason})
+			continue
+		}
+		rows = append(rows, ImportRow{Line: line, Input: input})
+	}
+
+	if index == nil {
+		return nil, nil, errors.New("文件为空")
+	}
+	return rows, failures, nil
+}
+
+// blankRecord 判断是否为空行
+func blankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// importColumnIndex 根据表头找到各字段所在的列，日期和金额列是必需的
+func importColumnIndex(header []string, columns map[string]string) (map[string]int, error) {
+	normalized := make([]string, len(header))
+	for i, h := range header {
+		normalized[i] = strings.ToLower(strings.TrimSpace(h))
+	}
+	find := func(name string) int {
+		for i, h := range normalized {
+			if h == strings.ToLower(name) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	index := make(map[string]int)
+	for field, aliases := range importColumnAliases {
+		if custom, ok := columns[field]; ok {
+			aliases = append([]string{custom}, aliases...)
+		}
+		for _, alias := range aliases {
+			if i := find(alias); i >= 0 {
+				index[field] = i
+				break
+			}
+		}
+	}
+
+	var missing []string
+	if _, ok := index["date"]; !ok {
+		missing = append(missing, "日期")
+	}
+	if _, ok := index["amount"]; !ok {
+		missing = append(missing, "金额")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("表头中没有找到%s列，可以通过 IMPORT_COLUMNS 配置列名", strings.Join(missing, "、"))
+	}
+	return index, nil
+}
+
+// parseImportRecord 校验并转换一行数据，失败时返回原因
+func parseImportRecord(record []string, index map[string]int, location *time.Location) (domain.NewBillInput, string) {
+	field := func(name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	date, ok := parseImportDate(field("date"), location)
+	if !ok {
+		return domain.NewBillInput{}, "日期无效"
+	}
+	amount, ok := parseImportAmount(field("amount"))
+	if !ok {
+		return domain.NewBillInput{}, "金额无效"
+	}
+
+	// 类型为空时记为支出，金额带负号的也按支出处理
+	billType := domain.BillTypeExpense
+	switch strings.ToLower(field("type")) {
+	case "", "支出", "支", "expense", "出":
+		billType = domain.BillTypeExpense
+	case "收入", "收", "income", "入":
+		billType = domain.BillTypeIncome
+	default:
+		return domain.NewBillInput{}, "类型无效"
+	}
+
+	category := field("category")
+	if category == "" {
+		category = domain.CategoryOther
+	}
+	description := field("description")
+	if description == "" {
+		description = category
+	}
+
+	return domain.NewBillInput{
+		Description: description,
+		Amount:      math.Abs(amount),
+		Type:        billType,
+		Date:        &date,
+		Category:    category,
+	}, ""
+}
+
+// parseImportDate 解析 location 时区的日期，支持 2024-05-01、2024/5/1 等格式，可带时间
+func parseImportDate(value string, location *time.Location) (time.Time, bool) {
+	value = strings.NewReplacer("/", "-", ".", "-").Replace(strings.TrimSpace(value))
+	for _, layout := range importDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseImportAmount 解析金额，允许全角数字、货币符号和千分位逗号，金额不能为 0
+func parseImportAmount(value string) (float64, bool) {
+	amount, err := domain.ParseAmount(value)
+	if err != nil || amount == 0 {
+		return 0, false
+	}
+	return amount, true
+}
+
+// ImportBills creates historical bills in batches. Duplicate detection, category suggestions and
+// the per-message index are skipped because every row already carries its own date and category.
+// 部分失败时返回 *domain.BatchCreateError，下标与 inputs 一致
+func (u *BillUseCaseImpl) ImportBills(ctx context.Context, userName string, inputs []domain.NewBillInput) ([]*domain.Bill, error) {
+	u.logFor(ctx).Info("BillUseCase.ImportBills called: userName=%s, count=%d", userName, len(inputs))
+
+	bills := make([]*domain.Bill, len(inputs))
+	for i, in := range inputs {
+		date := u.now(ctx)
+		if in.Date != nil {
+			date = *in.Date
+		}
+		bills[i] = &domain.Bill{
+			Description: in.Description,
+			Amount:      in.Amount,
+			Type:        in.Type,
+			Category:    in.Category,
+			Date:        date,
+			UserName:    userName,
+			OriginalMsg: in.OriginalMsg,
+		}
+	}
+
+	var batchErr *domain.BatchCreateError
+	for start := 0; start < len(bills); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(bills) {
+			end = len(bills)
+		}
+		if err := ctx.Err(); err != nil {
+			return bills, u.importFailed(batchErr, len(bills), start, len(bills), err)
+		}
+
+		err := u.billRepo.CreateBills(ctx, bills[start:end])
+		if err == nil {
+			continue
+		}
+		if partial, ok := err.(*domain.BatchCreateError); ok {
+			if batchErr == nil {
+				batchErr = &domain.BatchCreateError{Errors: make([]error, len(bills))}
+			}
+			copy(batchErr.Errors[start:end], partial.Errors)
+			continue
+		}
+		// 整批失败时后面的批次大概率也会失败，不再继续
+		u.logFor(ctx).Error("Import batch failed: userName=%s, rows=%d-%d, err=%v", userName, start, end, err)
+		return bills, u.importFailed(batchErr, len(bills), start, len(bills), err)
+	}
+
+	if batchErr != nil {
+		u.logFor(ctx).Warn("Import partially failed: userName=%s, failed=%d, total=%d", userName, batchErr.FailedCount(), len(bills))
+		return bills, batchErr
+	}
+	u.logFor(ctx).Info("Bills imported: userName=%s, count=%d", userName, len(bills))
+	return bills, nil
+}
+
+// importFailed 把 [from, to) 范围内尚未写入的账单标记为失败
+func (u *BillUseCaseImpl) importFailed(batchErr *domain.BatchCreateError, total, from, to int, err error) *domain.BatchCreateError {
+	if batchErr == nil {
+		batchErr = &domain.BatchCreateError{Errors: make([]error, total)}
+	}
+	for i := from; i < to; i++ {
+		batchErr.Errors[i] = err
+	}
+	return batchErr
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// Excel 另存的 CSV UTF-8 带 BOM，表头含空格和大小写不同的别名
+	data := utf8BOM + "交易时间, 备注 ,金额（元）,收/支,Category\n" +
+		"2024-05-01,午饭,35.5,支出,餐饮\n" +
+		"2024/5/2 18:30,工资,\"8,000\",收入,工资\n" +
+		"\n" +
+		"2024.5.3 09:15:20,,￥12,,\n" +
+		"2024-05-04,退款,-20,,购物\n"
+
+	rows, failures, err := ParseImportCSV(strings.NewReader(data), nil, shanghai)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 0 {
+		t.Errorf("failures = %+v, want none", failures)
+	}
+	date := func(day, hour, min, sec int) *time.Time {
+		d := time.Date(2024, time.May, day, hour, min, sec, 0, shanghai)
+		return &d
+	}
+	want := []ImportRow{
+		{Line: 2, Input: domain.NewBillInput{Description: "午饭", Amount: 35.5, Type: domain.BillTypeExpense, Category: "餐饮", Date: date(1, 0, 0, 0)}},
+		{Line: 3, Input: domain.NewBillInput{Description: "工资", Amount: 8000, Type: domain.BillTypeIncome, Category: "工资", Date: date(2, 18, 30, 0)}},
+		// 空行跳过但计入行号；缺少描述和分类时使用默认分类
+		{Line: 5, Input: domain.NewBillInput{Description: domain.CategoryOther, Amount: 12, Type: domain.BillTypeExpense, Category: domain.CategoryOther, Date: date(3, 9, 15, 20)}},
+		// 负数金额取绝对值
+		{Line: 6, Input: domain.NewBillInput{Description: "退款", Amount: 20, Type: domain.BillTypeExpense, Category: "购物", Date: date(4, 0, 0, 0)}},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("parsed %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(rows[i], want[i]) {
+			t.Errorf("row %d = %+v (date %v), want %+v (date %v)", i, rows[i], rows[i].Input.Date, want[i], want[i].Input.Date)
+		}
+	}
+}
+
+// 无效的行记录行号和原因，不影响其他行
+func TestParseImportCSVMalformedRows(t *testing.T) {
+	data := "date,description,amount,type\n" +
+		"2024-05-01,午饭,35,支出\n" +
+		"2024-13-01,晚饭,40,支出\n" +
+		"2024-05-02,打车,abc,支出\n" +
+		"2024-05-03,咖啡,0,支出\n" +
+		"2024-05-04,红包,100,转账\n" +
+		"2024-05-05,午\"饭,20,支出\n" +
+		"昨天,早饭,8,支出\n" +
+		"2024-05-06,夜宵\n" +
+		"2024-05-07,地铁,4,支出\n"
+
+	rows, failures, err := ParseImportCSV(strings.NewReader(data), nil, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Line != 2 || rows[1].Line != 10 {
+		t.Errorf("rows = %+v, want lines 2 and 10", rows)
+	}
+	want := []ImportRowError{
+		{Line: 3, Reason: "日期无效"},
+		{Line: 4, Reason: "金额无效"},
+		{Line: 5, Reason: "金额无效"},
+		{Line: 6, Reason: "类型无效"},
+		{Line: 7, Reason: "格式错误"},
+		{Line: 8, Reason: "日期无效"},
+		// 缺少金额列的短行
+		{Line: 9, Reason: "金额无效"},
+	}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("failures = %+v, want %+v", failures, want)
+	}
+}
+
+// 自定义列名优先于内置别名
+func TestParseImportCSVCustomColumns(t *testing.T) {
+	data := "when,what,how much,日期\n2024-05-01,午饭,35,not a date\n"
+	columns := map[string]string{"date": "When", "description": "what", "amount": "how much"}
+	rows, failures, err := ParseImportCSV(strings.NewReader(data), columns, time.UTC)
+	if err != nil || len(failures) != 0 || len(rows) != 1 {
+		t.Fatalf("ParseImportCSV = %+v, %+v, %v, want one row", rows, failures, err)
+	}
+	if in := rows[0].Input; in.Description != "午饭" || in.Amount != 35 || !in.Date.Equal(time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("row = %+v, want the custom columns", in)
+	}
+}
+
+// 整个文件不可用时返回错误
+func TestParseImportCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "文件为空"},
+		{"only blank lines", utf8BOM + "\n,,\n", "文件为空"},
+		{"missing amount column", "日期,描述\n2024-05-01,午饭\n", "没有找到金额列"},
+		{"missing both columns", "描述,分类\n午饭,餐饮\n", "没有找到日期、金额列"},
+		// GBK 编码的“日期,金额”
+		{"not utf-8", "\xc8\xd5\xc6\xda,\xbd\xf0\xb6\xee\n", "CSV UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseImportCSV(strings.NewReader(tt.data), nil, time.UTC)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseImportCSV = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// failingBillRepository 批量写入时按 fail 返回错误的账单仓库
+type failingBillRepository struct {
+	domain.BillRepository
+	batches []int
+	fail    func(batch int, bills []*domain.Bill) error
+}
+
+func (r *failingBillRepository) CreateBills(ctx context.Context, bills []*domain.Bill) error {
+	r.batches = append(r.batches, len(bills))
+	if err := r.fail(len(r.batches)-1, bills); err != nil {
+		return err
+	}
+	return r.BillRepository.CreateBills(ctx, bills)
+}
+
+func importInputs(n int) []domain.NewBillInput {
+	date := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	inputs := make([]domain.NewBillInput, n)
+	for i := range inputs {
+		inputs[i] = domain.NewBillInput{Description: "午饭", Amount: 35, Type: domain.BillTypeExpense, Category: "餐饮", Date: &date}
+	}
+	return inputs
+}
+
+// 按批量接口的上限分批写入，单行失败不影响其他行
+func TestImportBillsBatches(t *testing.T) {
+	repo := &failingBillRepository{
+		BillRepository: repository.NewMemoryBillRepository(time.UTC),
+		fail: func(batch int, bills []*domain.Bill) error {
+			if batch != 1 {
+				return nil
+			}
+			errs := make([]error, len(bills))
+			errs[3] = errors.New("write failed")
+			return &domain.BatchCreateError{Errors: errs}
+		},
+	}
+	u := NewBillUseCase(repo, nil, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+
+	bills, err := u.ImportBills(context.Background(), "张三", importInputs(1200))
+	if !reflect.DeepEqual(repo.batches, []int{500, 500, 200}) {
+		t.Errorf("batches = %v, want 500/500/200", repo.batches)
+	}
+	var batchErr *domain.BatchCreateError
+	if !errors.As(err, &batchErr) || batchErr.FailedCount() != 1 || batchErr.Errors[503] == nil {
+		t.Fatalf("ImportBills = %v, want only row 503 failed", err)
+	}
+	if len(bills) != 1200 || bills[0].UserName != "张三" || !bills[0].Date.Equal(time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("bills[0] = %+v, want the imported date and user", bills[0])
+	}
+}
+
+// 整批失败时不再写入后面的批次，剩余的行都算失败
+func TestImportBillsBatchFailure(t *testing.T) {
+	repo := &failingBillRepository{
+		BillRepository: repository.NewMemoryBillRepository(time.UTC),
+		fail: func(batch int, _ []*domain.Bill) error {
+			if batch == 1 {
+				return errors.New("rate limited")
+			}
+			return nil
+		},
+	}
+	u := NewBillUseCase(repo, nil, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+
+	_, err := u.ImportBills(context.Background(), "张三", importInputs(1200))
+	if len(repo.batches) != 2 {
+		t.Errorf("wrote %d batches, want to stop after the failed one", len(repo.batches))
+	}
+	var batchErr *domain.BatchCreateError
+	if !errors.As(err, &batchErr) || batchErr.FailedCount() != 700 || batchErr.Errors[499] != nil || batchErr.Errors[500] == nil {
+		t.Errorf("ImportBills = %v, want rows 500 onwards failed", err)
+	}
+}
@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// errInstallmentsDisabled 未配置分期计划存储时返回
+var errInstallmentsDisabled = errors.New("installment plans are not enabled")
+
+// CreateInstallmentBill records the first installment of a purchase and saves a plan for the remaining ones.
+// input.Amount is the total price; the first installment is dated now, later ones on the 1st of each month
+func (u *BillUseCaseImpl) CreateInstallmentBill(ctx context.Context, userName string, userID string, input domain.NewBillInput, count int) (*domain.Bill, *domain.InstallmentPlan, error) {
+	u.logFor(ctx).Info("BillUseCase.CreateInstallmentBill called: userName=%s, description=%s, total=%.2f, count=%d", userName, input.Description, input.Amount, count)
+
+	if u.installments == nil {
+		return nil, nil, errInstallmentsDisabled
+	}
+	if count < 2 || count > domain.MaxInstallments {
+		return nil, nil, fmt.Errorf("installments must be between 2 and %d", domain.MaxInstallments)
+	}
+	if input.Type == domain.BillTypeIncome {
+		return nil, nil, fmt.Errorf("installments only apply to expenses")
+	}
+
+	now := u.now(ctx)
+	if input.Date != nil {
+		now = *input.Date
+	}
+	category := input.Category
+	if category == "" {
+		category = domain.CategoryOther
+	}
+	plan := &domain.InstallmentPlan{
+		ID:          uuid.New().String()[:8],
+		UserName:    userName,
+		UserID:      userID,
+		Ledger:      domain.LedgerFromContext(ctx),
+		Description: input.Description,
+		Category:    category,
+		Account:     input.Account,
+		Tags:        domain.NormalizeTags(input.Tags),
+		Total:       input.Amount,
+		Count:       count,
+		StartMonth:  time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
+		CreatedAt:   time.Now(),
+	}
+
+	// 首期使用用户的原始消息和当前时间，其余字段与后续各期一致
+	first := plan.Input(1)
+	first.Date = input.Date
+	first.OriginalMsg = input.OriginalMsg
+	first.Reimbursable = input.Reimbursable
+	bill, err := u.CreateBill(ctx, userName, userID, first)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan.Recorded = 1
+	plan.RecordIDs = []string{bill.RecordID}
+	if err := u.installments.SavePlan(plan); err != nil {
+		u.logFor(ctx).Error("Failed to save installment plan: id=%s, err=%v", plan.ID, err)
+		return bill, nil, fmt.Errorf("failed to save installment plan: %v", err)
+	}
+	u.logFor(ctx).Info("Installment plan created: id=%s, userName=%s, total=%.2f, count=%d", plan.ID, userName, plan.Total, plan.Count)
+	return bill, plan, nil
+}
+
+// ListInstallmentPlans lists the unfinished installment plans of a user
+func (u *BillUseCaseImpl) ListInstallmentPlans(ctx context.Context, userName string) ([]*domain.InstallmentPlan, error) {
+	if u.installments == nil {
+		return nil, errInstallmentsDisabled
+	}
+	plans, err := u.installments.ListPlans()
+	if err != nil {
+		return nil, err
+	}
+	var owned []*domain.InstallmentPlan
+	for _, plan := range plans {
+		if plan.UserName == userName {
+			owned = append(owned, plan)
+		}
+	}
+	return owned, nil
+}
+
+// CancelInstallmentPlan stops an installment plan so no further installments are recorded.
+// deleteFuture also deletes the installments of the plan dated after now; returns the number of deleted bills
+func (u *BillUseCaseImpl) CancelInstallmentPlan(ctx context.Context, userName string, planID string, deleteFuture bool) (*domain.InstallmentPlan, int, error) {
+	u.logFor(ctx).Info("BillUseCase.CancelInstallmentPlan called: userName=%s, planID=%s, deleteFuture=%v", userName, planID, deleteFuture)
+
+	if u.installments == nil {
+		return nil, 0, errInstallmentsDisabled
+	}
+	plan, err := u.installments.GetPlan(strings.TrimSpace(planID))
+	if err != nil {
+		return nil, 0, err
+	}
+	// 只能取消自己的分期
+	if plan.UserName != userName {
+		return nil, 0, fmt.Errorf("%w: %s", domain.ErrInstallmentPlanNotFound, planID)
+	}
+	if err := u.installments.DeletePlan(plan.ID); err != nil {
+		return nil, 0, err
+	}
+
+	deleted := 0
+	if deleteFuture {
+		ctx := domain.WithLedger(ctx, plan.Ledger)
+		now := time.Now()
+		for n, recordID := range plan.RecordIDs {
+			if !plan.DueDate(n+1).After(now) || recordID == "" {
+				continue
+			}
+			if err := u.billRepo.DeleteBill(ctx, recordID); err != nil {
+				u.logFor(ctx).Error("Failed to delete installment bill: plan=%s, record_id=%s, err=%v", plan.ID, recordID, err)
+				continue
+			}
+			deleted++
+		}
+	}
+	u.logFor(ctx).Info("Installment plan cancelled: id=%s, recorded=%d/%d, deleted=%d", plan.ID, plan.Recorded, plan.Count, deleted)
+	return plan, deleted, nil
+}
+
+// MaterializeInstallments records every installment due by now that has not been recorded yet,
+// returning the number of bills created. Finished plans are removed.
+// 每期账单的原始消息带有计划 ID 和期数，补记前先在当月查找，重启或保存计划失败后重复执行也不会重复记账
+func (u *BillUseCaseImpl) MaterializeInstallments(ctx context.Context, now time.Time) (int, error) {
+	if u.installments == nil {
+		return 0, nil
+	}
+	plans, err := u.installments.ListPlans()
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var errs []string
+	for _, plan := range plans {
+		n, err := u.materializePlan(domain.WithLedger(ctx, plan.Ledger), plan, now)
+		created += n
+		if err != nil {
+			u.logFor(ctx).Error("Failed to materialize installment plan: id=%s, err=%v", plan.ID, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", plan.ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return created, fmt.Errorf("failed to materialize installments: %s", strings.Join(errs, "; "))
+	}
+	return created, nil
+}
+
+// materializePlan 补记单个计划中已到期的各期，每记一期保存一次进度
+func (u *BillUseCaseImpl) materializePlan(ctx context.Context, plan *domain.InstallmentPlan, now time.Time) (int, error) {
+	created := 0
+	for n := plan.Recorded + 1; n <= plan.Count && !plan.DueDate(n).After(now); n++ {
+		recordID, err := u.findInstallment(ctx, plan, n)
+		if err != nil {
+			return created, err
+		}
+		if recordID == "" {
+			bill, err := u.CreateBill(ctx, plan.UserName, plan.UserID, plan.Input(n))
+			if err != nil {
+				return created, err
+			}
+			recordID = bill.RecordID
+			created++
+			u.logFor(ctx).Info("Installment recorded: plan=%s, %s, record_id=%s", plan.ID, plan.Label(n), recordID)
+		}
+
+		plan.Recorded = n
+		plan.RecordIDs = append(plan.RecordIDs, recordID)
+		if err := u.installments.SavePlan(plan); err != nil {
+			return created, err
+		}
+	}
+
+	if plan.Recorded >= plan.Count {
+		u.logFor(ctx).Info("Installment plan finished: id=%s", plan.ID)
+		if err := u.installments.DeletePlan(plan.ID); err != nil && !errors.Is(err, domain.ErrInstallmentPlanNotFound) {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// findInstallment 在该期所在月份查找已记录的账单，返回其 record_id，未找到时返回空字符串
+func (u *BillUseCaseImpl) findInstallment(ctx context.Context, plan *domain.InstallmentPlan, n int) (string, error) {
+	start := plan.DueDate(n)
+	end := start.AddDate(0, 1, 0).Add(-time.Millisecond)
+	bills, _, _, err := u.billRepo.QueryTransactions(ctx, plan.UserName, start, end, 0)
+	if err != nil {
+		return "", err
+	}
+	marker := plan.Marker(n)
+	for _, bill := range bills {
+		if bill.OriginalMsg == marker {
+			return bill.RecordID, nil
+		}
+	}
+	return "", nil
+}
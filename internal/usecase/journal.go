@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// journalSnapshot 复制账单写入操作日志，不保存附件内容
+func journalSnapshot(bill *domain.Bill) *domain.Bill {
+	if bill == nil {
+		return nil
+	}
+	c := *bill
+	c.Attachments = nil
+	return &c
+}
+
+// journalOperator 返回执行操作的用户：优先使用上下文中的用户，其次是 fallback（通常为账单的记录者）
+func journalOperator(ctx context.Context, fallback string) string {
+	if userName := domain.OperatorFromContext(ctx); userName != "" {
+		return userName
+	}
+	return fallback
+}
+
+// journal 记录一次账单操作，写入失败只记录日志，不影响操作本身
+func (u *BillUseCaseImpl) journal(ctx context.Context, userName string, op *domain.Operation) {
+	if u.journalRepo == nil || userName == "" {
+		return
+	}
+	op.Ledger = domain.LedgerFromContext(ctx)
+	op.At = time.Now()
+	if err := u.journalRepo.Push(userName, op); err != nil {
+		u.logFor(ctx).Warn("Failed to journal %s operation: record_id=%s, err=%v", op.Kind, op.RecordID, err)
+	}
+}
+
+// journalCreated 记录新建的账单，同一条消息记了多笔时逐笔记录，撤销时逐笔删除
+func (u *BillUseCaseImpl) journalCreated(ctx context.Context, userName string, bills []*domain.Bill) {
+	for _, bill := range bills {
+		if bill == nil || bill.RecordID == "" {
+			continue
+		}
+		u.journal(ctx, journalOperator(ctx, userName), &domain.Operation{
+			Kind:     domain.OperationCreate,
+			RecordID: bill.RecordID,
+			After:    journalSnapshot(bill),
+		})
+	}
+}
+
+// UndoLast reverts the user's latest bill operation: a created bill is deleted, a deleted bill is
+// restored (or re-created from the journal when it cannot be restored), an update is reverted.
+// 撤销本身会记入日志，紧接着再次撤销时返回 ErrUndoUnsupported
+func (u *BillUseCaseImpl) UndoLast(ctx context.Context, userName string) (*domain.UndoResult, error) {
+	u.logFor(ctx).Info("BillUseCase.UndoLast called: userName=%s", userName)
+
+	if u.journalRepo == nil {
+		return nil, domain.ErrNothingToUndo
+	}
+	op, err := u.journalRepo.Last(userName)
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		return nil, domain.ErrNothingToUndo
+	}
+	if op.Kind == domain.OperationUndo {
+		return nil, domain.ErrUndoUnsupported
+	}
+
+	ctx = domain.WithLedger(ctx, op.Ledger)
+	var result *domain.UndoResult
+	switch op.Kind {
+	case domain.OperationCreate:
+		result, err = u.undoCreate(ctx, op)
+	case domain.OperationDelete:
+		result, err = u.undoDelete(ctx, op)
+	case domain.OperationUpdate:
+		result, err = u.undoUpdate(ctx, op)
+	default:
+		err = fmt.Errorf("unknown operation: %s", op.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.journalRepo.Pop(userName); err != nil {
+		u.logFor(ctx).Warn("Failed to pop journal: userName=%s, err=%v", userName, err)
+	}
+	u.journal(ctx, userName, &domain.Operation{Kind: domain.OperationUndo, RecordID: result.Bill.RecordID, Undone: op})
+	u.logFor(ctx).Info("Undo %s: userName=%s, record_id=%s, recreated=%v", op.Kind, userName, op.RecordID, result.Recreated)
+	return result, nil
+}
+
+// undoCreate 删除新建的账单
+func (u *BillUseCaseImpl) undoCreate(ctx context.Context, op *domain.Operation) (*domain.UndoResult, error) {
+	if err := u.billRepo.DeleteBill(ctx, op.RecordID); err != nil {
+		return nil, fmt.Errorf("failed to delete bill %s: %w", op.RecordID, err)
+	}
+	u.markLoanDeleted(ctx, op.RecordID, true)
+
+	bill := op.After
+	if bill == nil {
+		bill = &domain.Bill{RecordID: op.RecordID}
+	}
+	return &domain.UndoResult{Undone: op, Bill: bill}, nil
+}
+
+// undoDelete 优先恢复软删除的记录，无法恢复时按删除前的内容重新创建
+func (u *BillUseCaseImpl) undoDelete(ctx context.Context, op *domain.Operation) (*domain.UndoResult, error) {
+	err := u.billRepo.RestoreBill(ctx, op.RecordID)
+	if err == nil {
+		u.markLoanDeleted(ctx, op.RecordID, false)
+		bill := op.Before
+		if restored, err := u.billRepo.GetBill(ctx, op.RecordID); err == nil {
+			bill = restored
+		}
+		if bill == nil {
+			bill = &domain.Bill{RecordID: op.RecordID}
+		}
+		return &domain.UndoResult{Undone: op, Bill: bill}, nil
+	}
+	if op.Before == nil || (!errors.Is(err, domain.ErrSoftDeleteDisabled) && !errors.Is(err, domain.ErrBillNotFound)) {
+		return nil, fmt.Errorf("failed to restore bill %s: %w", op.RecordID, err)
+	}
+
+	bill := *op.Before
+	bill.RecordID = ""
+	if err := u.billRepo.CreateBill(ctx, &bill); err != nil {
+		return nil, fmt.Errorf("failed to re-create bill %s: %v", op.RecordID, err)
+	}
+	u.relinkLoan(ctx, op.RecordID, bill.RecordID)
+	return &domain.UndoResult{Undone: op, Bill: &bill, Recreated: true}, nil
+}
+
+// undoUpdate 把修改过的字段写回修改前的值
+// 修改只写入非空字段，原本为空的账户、标签无法清空，保持修改后的值
+func (u *BillUseCaseImpl) undoUpdate(ctx context.Context, op *domain.Operation) (*domain.UndoResult, error) {
+	if op.Before == nil || op.After == nil {
+		return nil, fmt.Errorf("journal has no previous values of %s", op.RecordID)
+	}
+	before := op.Before
+	revert := &domain.Bill{ID: op.RecordID, RecordID: op.RecordID}
+	for _, change := range op.Changes() {
+		switch change.Field {
+		case "description":
+			revert.Description = before.Description
+		case "amount":
+			revert.Amount = before.Amount
+		case "type":
+			revert.Type = before.Type
+		case "category":
+			revert.Category = before.Category
+		case "date":
+			revert.Date = before.Date
+		case "account":
+			revert.Account = before.Account
+		case "tags":
+			revert.Tags = before.Tags
+		case "reimbursable":
+			reimbursable := before.IsReimbursable()
+			revert.Reimbursable = &reimbursable
+		}
+	}
+	if err := u.billRepo.UpdateBill(ctx, revert); err != nil {
+		return nil, fmt.Errorf("failed to revert bill %s: %w", op.RecordID, err)
+	}
+	u.syncLoanAmount(ctx, revert)
+	return &domain.UndoResult{Undone: op, Bill: before}, nil
+}
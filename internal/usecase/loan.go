@@ -0,0 +1,204 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// errLoansDisabled 未配置借贷索引时返回
+var errLoansDisabled = errors.New("loan tracking is not enabled")
+
+// RecordLoan records money lent to or borrowed from a counterparty.
+// 借出记为支出、借入记为收入，分类为借贷，不计入收支合计；对方记录在借贷索引中
+func (u *BillUseCaseImpl) RecordLoan(ctx context.Context, userName string, userID string, input domain.LoanInput) (*domain.Bill, error) {
+	u.logFor(ctx).Info("BillUseCase.RecordLoan called: userName=%s, direction=%s, counterparty=%s, amount=%.2f", userName, input.Direction, input.Counterparty, input.Amount)
+
+	if u.loans == nil {
+		return nil, errLoansDisabled
+	}
+	counterparty := domain.NormalizeCounterparty(input.Counterparty)
+	if counterparty == "" {
+		return nil, fmt.Errorf("counterparty is required")
+	}
+	if input.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+
+	bill := domain.NewBillInput{
+		Amount:      input.Amount,
+		Category:    domain.CategoryLoan,
+		OriginalMsg: input.OriginalMsg,
+	}
+	switch input.Direction {
+	case domain.LoanLent:
+		bill.Description = "借给" + counterparty
+		bill.Type = domain.BillTypeExpense
+	case domain.LoanBorrowed:
+		bill.Description = "向" + counterparty + "借款"
+		bill.Type = domain.BillTypeIncome
+	default:
+		return nil, fmt.Errorf("invalid loan direction: %s", input.Direction)
+	}
+
+	created, err := u.CreateBill(ctx, userName, userID, bill)
+	if err != nil {
+		return nil, err
+	}
+	if created.AlreadyRecorded {
+		return created, nil
+	}
+	entry := &domain.LoanEntry{
+		RecordID:     created.RecordID,
+		UserName:     userName,
+		Ledger:       domain.LedgerFromContext(ctx),
+		Counterparty: counterparty,
+		Direction:    input.Direction,
+		Amount:       created.Amount,
+		Date:         created.Date,
+	}
+	if err := u.loans.SaveEntry(entry); err != nil {
+		// 账单已经记录，只是不会计入借贷余额
+		u.logFor(ctx).Error("Failed to save loan entry: record_id=%s, err=%v", created.RecordID, err)
+		return created, fmt.Errorf("failed to save loan entry: %v", err)
+	}
+	return created, nil
+}
+
+// LoanBalances lists the user's outstanding balances per counterparty
+func (u *BillUseCaseImpl) LoanBalances(ctx context.Context, userName string) ([]*domain.LoanBalance, error) {
+	if u.loans == nil {
+		return nil, errLoansDisabled
+	}
+	entries, err := u.loans.ListEntries(userName)
+	if err != nil {
+		return nil, err
+	}
+	return domain.LoanBalances(entries), nil
+}
+
+// SettleLoan records a repayment with a counterparty and links it to the open loans.
+// 方向由当前余额决定：对方欠我时记为收入，我欠对方时记为支出；amount <= 0 表示全部结清
+func (u *BillUseCaseImpl) SettleLoan(ctx context.Context, userName string, userID string, counterparty string, amount float64, originalMsg string) (*domain.Bill, *domain.LoanBalance, error) {
+	u.logFor(ctx).Info("BillUseCase.SettleLoan called: userName=%s, counterparty=%s, amount=%.2f", userName, counterparty, amount)
+
+	balances, err := u.LoanBalances(ctx, userName)
+	if err != nil {
+		return nil, nil, err
+	}
+	counterparty = domain.NormalizeCounterparty(counterparty)
+	var balance *domain.LoanBalance
+	for _, b := range balances {
+		if b.Counterparty == counterparty {
+			balance = b
+			break
+		}
+	}
+	if balance == nil {
+		return nil, nil, fmt.Errorf("%s: %w", counterparty, domain.ErrNoOutstandingLoan)
+	}
+
+	outstanding := math.Abs(balance.Amount)
+	if amount <= 0 {
+		amount = outstanding
+	}
+	if amount > outstanding+0.005 {
+		return nil, balance, fmt.Errorf("%.2f > %.2f: %w", amount, outstanding, domain.ErrLoanOverpaid)
+	}
+
+	bill := domain.NewBillInput{
+		Amount:      amount,
+		Category:    domain.CategoryLoan,
+		OriginalMsg: originalMsg,
+	}
+	direction := domain.LoanLent
+	if balance.Amount > 0 {
+		bill.Description = counterparty + "还款"
+		bill.Type = domain.BillTypeIncome
+	} else {
+		direction = domain.LoanBorrowed
+		bill.Description = "还给" + counterparty
+		bill.Type = domain.BillTypeExpense
+	}
+
+	created, err := u.CreateBill(ctx, userName, userID, bill)
+	if err != nil {
+		return nil, nil, err
+	}
+	if created.AlreadyRecorded {
+		return created, balance, nil
+	}
+	entry := &domain.LoanEntry{
+		RecordID:     created.RecordID,
+		UserName:     userName,
+		Ledger:       domain.LedgerFromContext(ctx),
+		Counterparty: counterparty,
+		Direction:    direction,
+		Repayment:    true,
+		Amount:       created.Amount,
+		Date:         created.Date,
+		Links:        balance.Open,
+	}
+	if err := u.loans.SaveEntry(entry); err != nil {
+		u.logFor(ctx).Error("Failed to save loan entry: record_id=%s, err=%v", created.RecordID, err)
+		return created, nil, fmt.Errorf("failed to save loan entry: %v", err)
+	}
+
+	remaining := &domain.LoanBalance{Counterparty: counterparty}
+	if direction == domain.LoanLent {
+		remaining.Amount = domain.AddAmount(balance.Amount, -amount)
+	} else {
+		remaining.Amount = domain.AddAmount(balance.Amount, amount)
+	}
+	u.logFor(ctx).Info("Loan settled: userName=%s, counterparty=%s, amount=%.2f, remaining=%.2f, links=%v", userName, counterparty, amount, remaining.Amount, entry.Links)
+	return created, remaining, nil
+}
+
+// markLoanDeleted 删除或恢复账单时同步借贷索引，不是借贷账单时忽略
+func (u *BillUseCaseImpl) markLoanDeleted(ctx context.Context, recordID string, deleted bool) {
+	if u.loans == nil {
+		return
+	}
+	entry, err := u.loans.GetEntry(recordID)
+	if err != nil || entry.Deleted == deleted {
+		return
+	}
+	entry.Deleted = deleted
+	if err := u.loans.SaveEntry(entry); err != nil {
+		u.logFor(ctx).Error("Failed to update loan entry: record_id=%s, err=%v", recordID, err)
+	}
+}
+
+// syncLoanAmount 修改借贷账单的金额后同步借贷索引
+func (u *BillUseCaseImpl) syncLoanAmount(ctx context.Context, bill *domain.Bill) {
+	if u.loans == nil || bill.Amount <= 0 {
+		return
+	}
+	entry, err := u.loans.GetEntry(bill.RecordID)
+	if err != nil || entry.Amount == bill.Amount {
+		return
+	}
+	entry.Amount = bill.Amount
+	if err := u.loans.SaveEntry(entry); err != nil {
+		u.logFor(ctx).Error("Failed to update loan entry: record_id=%s, err=%v", bill.RecordID, err)
+	}
+}
+
+// relinkLoan 撤销删除时重新创建了借贷账单，把索引指向新的 record_id
+func (u *BillUseCaseImpl) relinkLoan(ctx context.Context, oldID, newID string) {
+	if u.loans == nil || newID == "" {
+		return
+	}
+	entry, err := u.loans.GetEntry(oldID)
+	if err != nil {
+		return
+	}
+	entry.RecordID = newID
+	entry.Deleted = false
+	if err := u.loans.SaveEntry(entry); err != nil {
+		u.logFor(ctx).Error("Failed to relink loan entry: %s -> %s, err=%v", oldID, newID, err)
+	}
+}
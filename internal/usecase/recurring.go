@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// errRecurringDisabled 未配置周期记账规则存储时返回
+var errRecurringDisabled = errors.New("recurring bills are not enabled")
+
+// CreateRecurringRule saves a rule that records a bill on a monthly or weekly schedule.
+// 规则从下一个记账日开始生效，创建当天不会立即记账
+func (u *BillUseCaseImpl) CreateRecurringRule(ctx context.Context, userName string, userID string, rule domain.RecurringRule) (*domain.RecurringRule, error) {
+	u.logFor(ctx).Info("BillUseCase.CreateRecurringRule called: userName=%s, description=%s, amount=%.2f, cadence=%s, day=%d, weekday=%d",
+		userName, rule.Description, rule.Amount, rule.Cadence, rule.DayOfMonth, rule.Weekday)
+
+	if u.recurring == nil {
+		return nil, errRecurringDisabled
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	if rule.Type == "" {
+		rule.Type = domain.BillTypeExpense
+	}
+	if rule.Category == "" {
+		rule.Category = domain.CategoryOther
+	}
+
+	now := u.now(ctx)
+	rule.ID = uuid.New().String()[:8]
+	rule.UserName = userName
+	rule.UserID = userID
+	rule.Ledger = domain.LedgerFromContext(ctx)
+	rule.LastRun = now
+	rule.CreatedAt = now
+	if err := u.recurring.SaveRule(&rule); err != nil {
+		return nil, fmt.Errorf("failed to save recurring rule: %v", err)
+	}
+	u.logFor(ctx).Info("Recurring rule created: id=%s, userName=%s, next=%s", rule.ID, userName, rule.Next(now).Format("2006-01-02"))
+	return &rule, nil
+}
+
+// ListRecurringRules lists the recurring rules of a user
+func (u *BillUseCaseImpl) ListRecurringRules(ctx context.Context, userName string) ([]*domain.RecurringRule, error) {
+	if u.recurring == nil {
+		return nil, errRecurringDisabled
+	}
+	rules, err := u.recurring.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	var owned []*domain.RecurringRule
+	for _, rule := range rules {
+		if rule.UserName == userName {
+			owned = append(owned, rule)
+		}
+	}
+	return owned, nil
+}
+
+// DeleteRecurringRule deletes one of the user's recurring rules; bills already recorded are kept
+func (u *BillUseCaseImpl) DeleteRecurringRule(ctx context.Context, userName string, ruleID string) (*domain.RecurringRule, error) {
+	u.logFor(ctx).Info("BillUseCase.DeleteRecurringRule called: userName=%s, ruleID=%s", userName, ruleID)
+
+	if u.recurring == nil {
+		return nil, errRecurringDisabled
+	}
+	rule, err := u.recurring.GetRule(strings.TrimSpace(ruleID))
+	if err != nil {
+		return nil, err
+	}
+	// 只能删除自己的规则
+	if rule.UserName != userName {
+		return nil, fmt.Errorf("%w: %s", domain.ErrRecurringRuleNotFound, ruleID)
+	}
+	if err := u.recurring.DeleteRule(rule.ID); err != nil {
+		return nil, err
+	}
+	u.logFor(ctx).Info("Recurring rule deleted: id=%s", rule.ID)
+	return rule, nil
+}
+
+// RunRecurringRules records the bills of every rule due by now, including runs missed while the service was down.
+// 每条规则每记一笔就保存 LastRun，账单的原始消息带有规则 ID 和日期，记账前先查找，重启后不会重复记账
+func (u *BillUseCaseImpl) RunRecurringRules(ctx context.Context, now time.Time) ([]domain.RecurringRun, error) {
+	if u.recurring == nil {
+		return nil, nil
+	}
+	rules, err := u.recurring.ListRules()
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []domain.RecurringRun
+	var errs []string
+	for _, rule := range rules {
+		created, err := u.runRule(domain.WithLedger(ctx, rule.Ledger), rule, now)
+		runs = append(runs, created...)
+		if err != nil {
+			u.logFor(ctx).Error("Failed to run recurring rule: id=%s, err=%v", rule.ID, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", rule.ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return runs, fmt.Errorf("failed to run recurring rules: %s", strings.Join(errs, "; "))
+	}
+	return runs, nil
+}
+
+// runRule 补记单条规则到期的各次记账，记账日按 now 所在的时区计算
+func (u *BillUseCaseImpl) runRule(ctx context.Context, rule *domain.RecurringRule, now time.Time) ([]domain.RecurringRun, error) {
+	var runs []domain.RecurringRun
+	for date := rule.Next(rule.LastRun.In(now.Location())); !date.After(now); date = rule.Next(date) {
+		found, err := u.findRecurring(ctx, rule, date)
+		if err != nil {
+			return runs, err
+		}
+		if !found {
+			// 同一规则补记多次时金额、描述相同，不做重复记录检测
+			bill, err := u.CreateBill(domain.WithDuplicatesAllowed(ctx), rule.UserName, rule.UserID, rule.Input(date))
+			if err != nil {
+				return runs, err
+			}
+			runs = append(runs, domain.RecurringRun{Rule: rule, Bill: bill})
+			u.logFor(ctx).Info("Recurring bill recorded: rule=%s, date=%s, record_id=%s", rule.ID, date.Format("2006-01-02"), bill.RecordID)
+		}
+
+		rule.LastRun = date
+		if err := u.recurring.SaveRule(rule); err != nil {
+			return runs, err
+		}
+	}
+	return runs, nil
+}
+
+// findRecurring 查找规则在该日期是否已经记账
+func (u *BillUseCaseImpl) findRecurring(ctx context.Context, rule *domain.RecurringRule, date time.Time) (bool, error) {
+	end := date.AddDate(0, 0, 1).Add(-time.Millisecond)
+	bills, _, _, err := u.billRepo.QueryTransactions(ctx, rule.UserName, date, end, 0)
+	if err != nil {
+		return false, err
+	}
+	marker := rule.Marker(date)
+	for _, bill := range bills {
+		if bill.OriginalMsg == marker {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// reimbursementDescription 报销到账收入的描述
+const reimbursementDescription = "报销到账"
+
+// SettleReimbursement marks reimbursable bills as reimbursed and records the reimbursement as income.
+// 先校验全部账单，任何一笔不可报销或已报销时不做修改；收入的原始消息记录对应的 record_id
+func (u *BillUseCaseImpl) SettleReimbursement(ctx context.Context, userName string, userID string, recordIDs []string, amount float64) (*domain.Bill, error) {
+	u.logFor(ctx).Info("BillUseCase.SettleReimbursement called: userName=%s, recordIDs=%v, amount=%.2f", userName, recordIDs, amount)
+
+	var ids []string
+	seen := make(map[string]bool, len(recordIDs))
+	for _, id := range recordIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no bills to settle")
+	}
+
+	var total float64
+	for _, id := range ids {
+		bill, err := u.billRepo.GetBill(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bill %s: %w", id, err)
+		}
+		if !bill.IsReimbursable() {
+			return nil, fmt.Errorf("%s: %w", id, domain.ErrNotReimbursable)
+		}
+		if bill.ReimbursedAt != nil {
+			return nil, fmt.Errorf("%s: %w", id, domain.ErrAlreadyReimbursed)
+		}
+		total = domain.AddAmount(total, bill.Amount)
+	}
+	if amount <= 0 {
+		amount = total
+	}
+
+	// 报销收入和原账单金额相同，不做重复记录检测
+	income, err := u.CreateBill(domain.WithDuplicatesAllowed(ctx), userName, userID, domain.NewBillInput{
+		Description: reimbursementDescription,
+		Amount:      amount,
+		Type:        domain.BillTypeIncome,
+		Category:    "收入",
+		OriginalMsg: "报销：" + strings.Join(ids, "、"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 收入已经记录，标记失败时返回收入账单，便于用户手动处理剩余的记录
+	now := time.Now()
+	var failed []string
+	for _, id := range ids {
+		if err := u.billRepo.UpdateBill(ctx, &domain.Bill{ID: id, RecordID: id, ReimbursedAt: &now}); err != nil {
+			u.logFor(ctx).Error("Failed to mark bill as reimbursed: record_id=%s, err=%v", id, err)
+			failed = append(failed, id)
+		}
+	}
+	if len(failed) > 0 {
+		return income, fmt.Errorf("failed to mark bills as reimbursed: %s", strings.Join(failed, ", "))
+	}
+
+	u.logFor(ctx).Info("Reimbursement settled: userName=%s, bills=%d, amount=%.2f, income_record_id=%s", userName, len(ids), amount, income.RecordID)
+	return income, nil
+}
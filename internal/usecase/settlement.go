@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// scopeUserName 共享账本中查询全部成员的账单，返回空用户名表示不按记录者过滤
+func scopeUserName(ctx context.Context, userName string) string {
+	if domain.IsSharedLedger(ctx) {
+		return ""
+	}
+	return userName
+}
+
+// MemberBreakdown sums the income and expense of each member of the shared ledger within a time range
+func (u *BillUseCaseImpl) MemberBreakdown(ctx context.Context, startTime, endTime time.Time) ([]*domain.MemberTotal, error) {
+	u.logFor(ctx).Info("BillUseCase.MemberBreakdown called: ledger=%s, start=%s, end=%s", domain.LedgerFromContext(ctx), startTime.Format("2006-01-02"), endTime.Format("2006-01-02"))
+
+	if !domain.IsSharedLedger(ctx) {
+		return nil, domain.ErrLedgerNotShared
+	}
+	bills, err := u.queryAllTransactions(ctx, "", startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	return domain.MemberTotals(bills), nil
+}
+
+// SettleUp splits the shared ledger's expenses within a time range among its members by weight.
+// 收入和借贷不参与分摊；范围内只有收入的成员、以及配置了权重的成员也参与分摊
+func (u *BillUseCaseImpl) SettleUp(ctx context.Context, startTime, endTime time.Time, weights map[string]float64) (*domain.Settlement, error) {
+	totals, err := u.MemberBreakdown(ctx, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	paid := make(map[string]float64, len(totals))
+	for _, total := range totals {
+		paid[total.UserName] = total.Expense
+	}
+
+	settlement := domain.Settle(paid, weights)
+	u.logFor(ctx).Info("Settled shared ledger: ledger=%s, total=%.2f, members=%d, transfers=%d", domain.LedgerFromContext(ctx), settlement.Total, len(settlement.Members), len(settlement.Transfers))
+	return settlement, nil
+}
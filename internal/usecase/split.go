@@ -0,0 +1,53 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// SplitBill records the recorder's share of an expense split among several people.
+// input.Amount 为总金额；recordOthers 时在共享账本中为其他有名字的参与者各记一笔各自的份额，
+// 不是共享账本时只记录自己的份额。返回的第一笔账单是记账人的，其他成员记账失败时同时返回已记录的账单和错误
+func (u *BillUseCaseImpl) SplitBill(ctx context.Context, userName string, userID string, input domain.NewBillInput, split *domain.Split, recordOthers bool) ([]*domain.Bill, error) {
+	u.logFor(ctx).Info("BillUseCase.SplitBill called: userName=%s, description=%s, total=%.2f, people=%d, participants=%v, recordOthers=%v",
+		userName, input.Description, split.Total, split.Count, split.Participants, recordOthers)
+
+	if input.Type == domain.BillTypeIncome {
+		return nil, fmt.Errorf("%w: splits only apply to expenses", domain.ErrInvalidSplit)
+	}
+
+	shares := split.Shares()
+	own := input
+	own.Amount = shares[0]
+	bill, err := u.CreateBill(ctx, userName, userID, own)
+	if err != nil {
+		return nil, err
+	}
+	bills := []*domain.Bill{bill}
+	if !recordOthers || !domain.IsSharedLedger(ctx) {
+		return bills, nil
+	}
+
+	// 其他成员的账单记在各自名下，失败时继续记录其余成员
+	var failed []string
+	for i, name := range split.Participants {
+		if name == userName {
+			continue
+		}
+		share := input
+		share.Amount = shares[i+1]
+		memberBill, err := u.CreateBill(ctx, name, u.memberUserID(ctx, name), share)
+		if err != nil {
+			u.logFor(ctx).Error("Failed to record split share: member=%s, amount=%.2f, err=%v", name, share.Amount, err)
+			failed = append(failed, name)
+			continue
+		}
+		bills = append(bills, memberBill)
+	}
+	if len(failed) > 0 {
+		return bills, fmt.Errorf("failed to record the shares of %v", failed)
+	}
+	return bills, nil
+}
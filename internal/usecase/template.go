@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// errTemplatesDisabled 未配置模板存储时返回
+var errTemplatesDisabled = errors.New("bill templates are not enabled")
+
+// SaveTemplate saves a transaction under a short name for the user.
+// 同名模板直接覆盖，返回被覆盖的旧模板，没有同名模板时为 nil
+func (u *BillUseCaseImpl) SaveTemplate(ctx context.Context, userName string, tpl domain.BillTemplate) (*domain.BillTemplate, *domain.BillTemplate, error) {
+	u.logFor(ctx).Info("BillUseCase.SaveTemplate called: userName=%s, name=%s, description=%s, amount=%.2f", userName, tpl.Name, tpl.Description, tpl.Amount)
+
+	if u.templates == nil {
+		return nil, nil, errTemplatesDisabled
+	}
+	tpl.Name = domain.NormalizeTemplateName(tpl.Name)
+	if tpl.Name == "" {
+		return nil, nil, fmt.Errorf("template name is required")
+	}
+	if tpl.Amount <= 0 {
+		return nil, nil, fmt.Errorf("amount must be positive")
+	}
+	if tpl.Description == "" {
+		tpl.Description = tpl.Name
+	}
+	if tpl.Type == "" {
+		tpl.Type = domain.BillTypeExpense
+	}
+	if tpl.Category == "" {
+		tpl.Category = domain.CategoryOther
+	}
+
+	previous, err := u.templates.GetTemplate(userName, tpl.Name)
+	if err != nil && !errors.Is(err, domain.ErrTemplateNotFound) {
+		return nil, nil, err
+	}
+	tpl.UserName = userName
+	tpl.UpdatedAt = time.Now()
+	tpl.UsedAt = time.Time{}
+	if err := u.templates.SaveTemplate(&tpl); err != nil {
+		return nil, nil, fmt.Errorf("failed to save template: %v", err)
+	}
+	u.logFor(ctx).Info("Template saved: userName=%s, name=%s, replaced=%v", userName, tpl.Name, previous != nil)
+	return &tpl, previous, nil
+}
+
+// ListTemplates lists the templates of a user
+func (u *BillUseCaseImpl) ListTemplates(ctx context.Context, userName string) ([]*domain.BillTemplate, error) {
+	if u.templates == nil {
+		return nil, errTemplatesDisabled
+	}
+	return u.templates.ListTemplates(userName)
+}
+
+// DeleteTemplate deletes one of the user's templates
+func (u *BillUseCaseImpl) DeleteTemplate(ctx context.Context, userName string, name string) (*domain.BillTemplate, error) {
+	u.logFor(ctx).Info("BillUseCase.DeleteTemplate called: userName=%s, name=%s", userName, name)
+
+	if u.templates == nil {
+		return nil, errTemplatesDisabled
+	}
+	tpl, err := u.templates.GetTemplate(userName, domain.NormalizeTemplateName(name))
+	if err != nil {
+		return nil, err
+	}
+	if err := u.templates.DeleteTemplate(userName, tpl.Name); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// UseTemplate records a bill from one of the user's templates; amount > 0 overrides the saved amount.
+// name 为空时（如"老样子"）使用最近用过或保存的模板
+func (u *BillUseCaseImpl) UseTemplate(ctx context.Context, userName string, userID string, name string, amount float64, originalMsg string) (*domain.Bill, *domain.BillTemplate, error) {
+	u.logFor(ctx).Info("BillUseCase.UseTemplate called: userName=%s, name=%s, amount=%.2f", userName, name, amount)
+
+	if u.templates == nil {
+		return nil, nil, errTemplatesDisabled
+	}
+	tpl, err := u.findTemplate(userName, domain.NormalizeTemplateName(name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bill, err := u.CreateBill(ctx, userName, userID, tpl.Input(amount, originalMsg))
+	if err != nil {
+		return nil, tpl, err
+	}
+	if !bill.AlreadyRecorded {
+		tpl.UsedAt = time.Now()
+		if err := u.templates.SaveTemplate(tpl); err != nil {
+			u.logFor(ctx).Warn("Failed to update template usage: name=%s, err=%v", tpl.Name, err)
+		}
+	}
+	return bill, tpl, nil
+}
+
+// findTemplate 按名字查找模板，名字为空时返回最近用过或保存的模板
+func (u *BillUseCaseImpl) findTemplate(userName, name string) (*domain.BillTemplate, error) {
+	if name != "" {
+		return u.templates.GetTemplate(userName, name)
+	}
+	templates, err := u.templates.ListTemplates(userName)
+	if err != nil {
+		return nil, err
+	}
+	var latest *domain.BillTemplate
+	for _, tpl := range templates {
+		if latest == nil || tpl.LastActive().After(latest.LastActive()) {
+			latest = tpl
+		}
+	}
+	if latest == nil {
+		return nil, domain.ErrTemplateNotFound
+	}
+	return latest, nil
+}
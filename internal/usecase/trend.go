@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// SpendingTrends computes the daily average, trend, most expensive day and no-spend days within a time range.
+// 按页读取全部账单后统计；范围的结束时间晚于当前时间时截止到今天，未来的日子不算作无消费
+func (u *BillUseCaseImpl) SpendingTrends(ctx context.Context, userName string, startTime, endTime time.Time) (*domain.SpendingTrend, error) {
+	u.logFor(ctx).Info("BillUseCase.SpendingTrends called: userName=%s, start=%s, end=%s", userName, startTime.Format("2006-01-02"), endTime.Format("2006-01-02"))
+
+	if now := time.Now(); endTime.After(now) {
+		endTime = now
+	}
+	if endTime.Before(startTime) {
+		return nil, fmt.Errorf("time range starts in the future")
+	}
+
+	bills, err := u.queryAllTransactions(ctx, scopeUserName(ctx, userName), startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	trend := domain.NewSpendingTrend(bills, startTime, endTime)
+	u.logFor(ctx).Info("Spending trends: userName=%s, bills=%d, days=%d, expense=%.2f", userName, len(bills), trend.Days(), trend.Expense)
+	return trend, nil
+}
+
+// ForecastMonth extrapolates this month's expense to month end at the current pace.
+// 月份按用户的时区计算
+func (u *BillUseCaseImpl) ForecastMonth(ctx context.Context, userName string, now time.Time) (*domain.MonthForecast, error) {
+	u.logFor(ctx).Info("BillUseCase.ForecastMonth called: userName=%s, now=%s", userName, now.Format("2006-01-02 15:04:05"))
+
+	now = now.In(u.locationFor(ctx))
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	bills, _, _, err := u.billRepo.QueryTransactions(ctx, scopeUserName(ctx, userName), start, now, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %v", err)
+	}
+
+	forecast := domain.NewMonthForecast(bills, now)
+	u.logFor(ctx).Info("Month forecast: userName=%s, spent=%.2f, average=%.2f, forecast=%.2f", userName, forecast.Spent, forecast.DailyAverage, forecast.Forecast)
+	return forecast, nil
+}
+
+// queryAllTransactions 按页读取时间范围内的全部账单
+func (u *BillUseCaseImpl) queryAllTransactions(ctx context.Context, userName string, startTime, endTime time.Time) ([]*domain.Bill, error) {
+	var bills []*domain.Bill
+	pageToken := ""
+	for {
+		page, next, err := u.billRepo.QueryTransactionsPage(ctx, userName, startTime, endTime, pageToken, exportPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query transactions: %v", err)
+		}
+		bills = append(bills, page...)
+		if next == "" {
+			return bills, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pageToken = next
+	}
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// userDataStart 查询用户全部账单时的起始时间，早于任何实际的记账日期
+var userDataStart = time.Unix(0, 0)
+
+// userDataHorizon 查询用户全部账单时向后覆盖的年数，包含分期等提前生成的未来账单
+const userDataHorizon = 10
+
+// 导出个人数据压缩包中的文件名
+const (
+	userDataProfileFile = "profile.json"
+	userDataBillsFile   = "bills.csv"
+)
+
+// userDataProfile 导出的用户信息，bills 为 bills.csv 中的账单数
+type userDataProfile struct {
+	*domain.UserMapping
+	Bills      int    `json:"bills"`
+	ExportedAt string `json:"exported_at"`
+}
+
+// ExportUserData writes a zip archive with the user's mapping and preferences (profile.json) and
+// all bills they recorded in the ledger of ctx (bills.csv). The bills are streamed page by page
+func (u *BillUseCaseImpl) ExportUserData(ctx context.Context, platformID string, w io.Writer) (int, error) {
+	platform, id := domain.SplitPlatformUserID(platformID)
+	// 用户不存在时在写入任何内容之前返回，调用方仍可以返回错误
+	mapping, err := u.userMappingRepo.GetMapping(platform, id)
+	if err != nil {
+		return 0, err
+	}
+
+	zw := zip.NewWriter(w)
+	csvFile, err := zw.Create(userDataBillsFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", userDataBillsFile, err)
+	}
+	count := 0
+	if mapping.UserName != "" {
+		// 共享账本的查询结果包含其他成员的账单，只导出该用户记录的
+		count, err = u.writeCSV(ctx, mapping.UserName, userDataStart, time.Now().AddDate(userDataHorizon, 0, 0), csvFile, func(bill *domain.Bill) bool {
+			return bill.UserName == mapping.UserName
+		})
+	} else {
+		// 没有名字的用户不会有账单，只写出表头
+		var cw *csv.Writer
+		if cw, err = newExportCSV(csvFile); err == nil {
+			cw.Flush()
+			err = cw.Error()
+		}
+	}
+	if err != nil {
+		return count, err
+	}
+
+	profileFile, err := zw.Create(userDataProfileFile)
+	if err != nil {
+		return count, fmt.Errorf("failed to create %s: %v", userDataProfileFile, err)
+	}
+	enc := json.NewEncoder(profileFile)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(userDataProfile{UserMapping: mapping, Bills: count, ExportedAt: time.Now().Format(time.RFC3339)}); err != nil {
+		return count, fmt.Errorf("failed to write %s: %v", userDataProfileFile, err)
+	}
+	if err := zw.Close(); err != nil {
+		return count, fmt.Errorf("failed to finish archive: %v", err)
+	}
+	u.logFor(ctx).Info("Exported user data: platform_id=%s, name=%s, bills=%d", platformID, mapping.UserName, count)
+	return count, nil
+}
+
+// deleteLinkedMappings 删除与该用户共享用户 ID 的其他映射，即迁移到 union_id 时保留的 open_id 映射（或反之）
+func (u *BillUseCaseImpl) deleteLinkedMappings(platform domain.Platform, id string) error {
+	mapping, err := u.userMappingRepo.GetMapping(platform, id)
+	if err != nil || mapping.UserID == "" {
+		return nil
+	}
+	mappings, err := u.userMappingRepo.ListMappings()
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, m := range mappings {
+		if m.UserID != mapping.UserID || m.PlatformID == mapping.PlatformID {
+			continue
+		}
+		linkedPlatform, linkedID := domain.SplitPlatformUserID(m.PlatformID)
+		if err := u.userMappingRepo.DeleteMapping(linkedPlatform, linkedID); err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUserData deletes a user's mapping, preferences, bill templates and undo journal
+func (u *BillUseCaseImpl) DeleteUserData(ctx context.Context, platformID string) (*domain.UserDataDeletion, error) {
+	platform, id := domain.SplitPlatformUserID(platformID)
+	// 没有设置名字时仍可能保存了个人设置，同样删除映射
+	name, _ := u.userMappingRepo.GetUserName(platform, id)
+	result := &domain.UserDataDeletion{UserName: name}
+
+	if name != "" && u.templates != nil {
+		templates, err := u.templates.ListTemplates(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list templates of %s: %w", name, err)
+		}
+		for _, tpl := range templates {
+			if err := u.templates.DeleteTemplate(name, tpl.Name); err != nil {
+				return nil, fmt.Errorf("failed to delete template %s of %s: %w", tpl.Name, name, err)
+			}
+			result.Templates++
+		}
+	}
+	if name != "" && u.journalRepo != nil {
+		if err := u.journalRepo.Clear(name); err != nil {
+			return nil, fmt.Errorf("failed to clear undo journal of %s: %w", name, err)
+		}
+		result.JournalCleared = true
+	}
+
+	// 映射最后删除，前面失败时用户还能再次发起删除
+	if err := u.deleteLinkedMappings(platform, id); err != nil {
+		return nil, err
+	}
+	err := u.userMappingRepo.DeleteMapping(platform, id)
+	switch {
+	case err == nil:
+		result.MappingDeleted = true
+	case !errors.Is(err, domain.ErrUserNotFound):
+		return nil, err
+	}
+	u.logFor(ctx).Info("User data deleted: platform_id=%s, name=%s, mapping=%v, templates=%d, journal=%v",
+		platformID, name, result.MappingDeleted, result.Templates, result.JournalCleared)
+	return result, nil
+}
+
+// CountUserBills counts all bills recorded by userName in the ledger of ctx
+func (u *BillUseCaseImpl) CountUserBills(ctx context.Context, userName string) (int, error) {
+	bills, err := u.userBills(ctx, userName)
+	if err != nil {
+		return 0, err
+	}
+	return len(bills), nil
+}
+
+// DeleteUserBills deletes all bills recorded by userName in the ledger of ctx
+func (u *BillUseCaseImpl) DeleteUserBills(ctx context.Context, userName string) (int, error) {
+	bills, err := u.userBills(ctx, userName)
+	if err != nil {
+		return 0, err
+	}
+	if len(bills) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, len(bills))
+	for i, bill := range bills {
+		ids[i] = bill.RecordID
+	}
+	// 直接调用仓库删除，不写入撤销记录：用户要求删除的数据不应再以快照形式保留
+	if err := u.billRepo.DeleteBills(ctx, ids); err != nil {
+		u.logFor(ctx).Error("billRepo.DeleteBills failed: user=%s, count=%d, err=%v", userName, len(ids), err)
+		return 0, fmt.Errorf("failed to delete %d bills of %s: %w", len(ids), userName, err)
+	}
+	for _, id := range ids {
+		u.markLoanDeleted(ctx, id, true)
+	}
+	u.logFor(ctx).Info("User bills deleted: user=%s, count=%d", userName, len(ids))
+	return len(ids), nil
+}
+
+// userBills 分页查询 userName 记录的全部账单；共享账本的查询结果包含其他成员的账单，这里只保留该用户的
+func (u *BillUseCaseImpl) userBills(ctx context.Context, userName string) ([]*domain.Bill, error) {
+	if userName == "" {
+		return nil, nil
+	}
+	bills, err := u.queryAllTransactions(ctx, userName, userDataStart, time.Now().AddDate(userDataHorizon, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	own := bills[:0]
+	for _, bill := range bills {
+		if bill.UserName == userName && bill.RecordID != "" {
+			own = append(own, bill)
+		}
+	}
+	return own, nil
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+)
+
+// sharedLedgerRepository 模拟多人共享的表格：查询不按用户过滤，每页 2 条，记录批量删除的 ID
+type sharedLedgerRepository struct {
+	domain.BillRepository
+
+	bills   []*domain.Bill
+	deleted []string
+	pages   int
+}
+
+func (r *sharedLedgerRepository) QueryTransactionsPage(ctx context.Context, userName string, start, end time.Time, pageToken string, pageSize int) ([]*domain.Bill, string, error) {
+	r.pages++
+	offset, _ := strconv.Atoi(pageToken)
+	next := offset + 2
+	if next >= len(r.bills) {
+		return r.bills[offset:], "", nil
+	}
+	return r.bills[offset:next], strconv.Itoa(next), nil
+}
+
+func (r *sharedLedgerRepository) DeleteBills(ctx context.Context, ids []string) error {
+	r.deleted = append(r.deleted, ids...)
+	return nil
+}
+
+func newSharedLedger() *sharedLedgerRepository {
+	return &sharedLedgerRepository{bills: []*domain.Bill{
+		{RecordID: "rec1", UserName: "张三", Description: "午饭"},
+		{RecordID: "rec2", UserName: "李四", Description: "打车"},
+		{RecordID: "rec3", UserName: "张三丰", Description: "咖啡"},
+		{RecordID: "rec4", UserName: "张三", Description: "晚饭"},
+		{RecordID: "rec5", UserName: "李四", Description: "房租"},
+		// 没有 record ID 的行无法删除，不计入
+		{UserName: "张三", Description: "草稿"},
+		{RecordID: "rec7", UserName: "张三", Description: "电影"},
+	}}
+}
+
+// 共享表格中只删除该用户自己的账单，名字前缀相同的用户不受影响
+func TestDeleteUserBillsSharedLedger(t *testing.T) {
+	ctx := context.Background()
+	repo := newSharedLedger()
+	u := NewBillUseCase(repo, nil, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+
+	if n, err := u.CountUserBills(ctx, "张三"); err != nil || n != 3 {
+		t.Errorf("CountUserBills = %d, %v, want 3", n, err)
+	}
+	if repo.pages != 4 {
+		t.Errorf("queried %d pages, want all 4", repo.pages)
+	}
+	n, err := u.DeleteUserBills(ctx, "张三")
+	if err != nil || n != 3 {
+		t.Fatalf("DeleteUserBills = %d, %v, want 3", n, err)
+	}
+	if want := []string{"rec1", "rec4", "rec7"}; !reflect.DeepEqual(repo.deleted, want) {
+		t.Errorf("deleted %v, want %v", repo.deleted, want)
+	}
+
+	// 没有名字的用户没有账单，不查询表格
+	repo.pages, repo.deleted = 0, nil
+	if n, err := u.DeleteUserBills(ctx, ""); err != nil || n != 0 || repo.pages != 0 || repo.deleted != nil {
+		t.Errorf("DeleteUserBills without a name = %d, %v, %d pages", n, err, repo.pages)
+	}
+	if n, err := u.DeleteUserBills(ctx, "王五"); err != nil || n != 0 || repo.deleted != nil {
+		t.Errorf("DeleteUserBills of a user without bills = %d, %v, deleted %v", n, err, repo.deleted)
+	}
+}
+
+func TestDeleteUserData(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	users, err := repository.NewUserMappingRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := repository.NewTemplateRepository(filepath.Join(dir, "templates.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, err := repository.NewOperationJournal(filepath.Join(dir, "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newSharedLedger()
+	u := NewBillUseCase(repo, users, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, journal, templates, time.UTC)
+
+	// 张三迁移到 union_id 后保留了 open_id 的映射
+	for id, name := range map[string]string{"ou_1": "张三", "ou_2": "李四"} {
+		if err := users.SetUserName(domain.PlatformFeishu, id, name); err != nil {
+			t.Fatal(err)
+		}
+		if err := templates.SaveTemplate(&domain.BillTemplate{Name: "午饭", UserName: name, Description: "午饭", Amount: 30}); err != nil {
+			t.Fatal(err)
+		}
+		if err := journal.Push(name, &domain.Operation{Kind: domain.OperationCreate, RecordID: "rec_" + id, At: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := users.CopyMapping(domain.PlatformFeishu, "ou_1", "on_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := templates.SaveTemplate(&domain.BillTemplate{Name: "咖啡", UserName: "张三", Description: "咖啡", Amount: 25}); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := u.DeleteUserData(ctx, "on_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &domain.UserDataDeletion{UserName: "张三", MappingDeleted: true, Templates: 2, JournalCleared: true}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("DeleteUserData = %+v, want %+v", removed, want)
+	}
+	for _, id := range []string{"on_1", "ou_1"} {
+		if _, err := users.GetMapping(domain.PlatformFeishu, id); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("GetMapping(%s) = %v, want ErrUserNotFound", id, err)
+		}
+	}
+	if list, _ := templates.ListTemplates("张三"); len(list) != 0 {
+		t.Errorf("templates left: %v", list)
+	}
+	if op, _ := journal.Last("张三"); op != nil {
+		t.Errorf("journal left: %+v", op)
+	}
+	// 账单留到第二步确认后再删除
+	if repo.pages != 0 || repo.deleted != nil {
+		t.Errorf("DeleteUserData touched the ledger: %d pages, deleted %v", repo.pages, repo.deleted)
+	}
+
+	// 其他用户的数据不受影响
+	if name, err := users.GetUserName(domain.PlatformFeishu, "ou_2"); err != nil || name != "李四" {
+		t.Errorf("GetUserName(ou_2) = %q, %v, want 李四", name, err)
+	}
+	if list, _ := templates.ListTemplates("李四"); len(list) != 1 {
+		t.Errorf("李四 has %d templates, want 1", len(list))
+	}
+	if op, _ := journal.Last("李四"); op == nil {
+		t.Error("李四's journal cleared")
+	}
+
+	// 再次删除时没有可删除的内容
+	removed, err = u.DeleteUserData(ctx, "on_1")
+	if err != nil || removed.MappingDeleted || removed.Templates != 0 {
+		t.Errorf("second DeleteUserData = %+v, %v, want nothing removed", removed, err)
+	}
+}
+
+// 只设置过偏好、没有名字的用户也能删除映射
+func TestDeleteUserDataWithoutName(t *testing.T) {
+	users, err := repository.NewUserMappingRepository("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetPreferences(domain.PlatformTelegram, "42", domain.UserPreferences{Currency: "$"}); err != nil {
+		t.Fatal(err)
+	}
+	u := NewBillUseCase(newSharedLedger(), users, nil, nil, 0, DuplicatePolicy{}, ValidationPolicy{}, nil, nil, nil, nil, nil, time.UTC)
+	removed, err := u.DeleteUserData(context.Background(), domain.PlatformUserID(domain.PlatformTelegram, "42"))
+	if err != nil || !removed.MappingDeleted || removed.UserName != "" || removed.JournalCleared {
+		t.Errorf("DeleteUserData = %+v, %v, want only the mapping removed", removed, err)
+	}
+	if prefs, _ := users.GetPreferences(domain.PlatformTelegram, "42"); prefs != (domain.UserPreferences{}) {
+		t.Errorf("preferences left: %+v", prefs)
+	}
+}
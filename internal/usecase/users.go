@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// ListUsers lists the users who have set a name, ordered by platform ID
+func (u *BillUseCaseImpl) ListUsers(ctx context.Context) ([]*domain.UserMapping, error) {
+	return u.userMappingRepo.ListMappings()
+}
+
+// memberUserID 返回共享账本中名为 name 的成员的用户 ID，找不到或有多个同名用户时为空
+func (u *BillUseCaseImpl) memberUserID(ctx context.Context, name string) string {
+	mappings, err := u.userMappingRepo.FindByUserName(name)
+	if err != nil {
+		u.logFor(ctx).Warn("Find user by name failed: name=%s, err=%v", name, err)
+		return ""
+	}
+	if users := domain.UniqueUsers(mappings); len(users) == 1 {
+		return users[0].PlatformID
+	}
+	return ""
+}
+
+// RenameUser sets the name of a user identified by the platform ID built with PlatformUserID
+// and returns the name saved, which may carry a number when another user has the same name
+func (u *BillUseCaseImpl) RenameUser(ctx context.Context, platformID string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	platform, id := domain.SplitPlatformUserID(platformID)
+	if err := u.userMappingRepo.SetUserName(platform, id, name); err != nil {
+		return "", fmt.Errorf("failed to rename user %s: %w", platformID, err)
+	}
+	saved, err := u.userMappingRepo.GetUserName(platform, id)
+	if err != nil {
+		return "", err
+	}
+	u.logFor(ctx).Info("User renamed: platform_id=%s, name=%s", platformID, saved)
+	return saved, nil
+}
+
+// ForgetUser deletes a user's mapping and preferences; when recordsSince is not nil, the user's
+// records dated after it are deleted first so a failure leaves the mapping in place for a retry.
+// 共享账本中查询结果包含其他成员的账单，只删除记录者是该用户的记录
+func (u *BillUseCaseImpl) ForgetUser(ctx context.Context, platformID string, recordsSince *time.Time) (*domain.ForgetUserResult, error) {
+	platform, id := domain.SplitPlatformUserID(platformID)
+	// 没有设置名字的用户也可以删除，只是没有可删除的账单
+	name, _ := u.userMappingRepo.GetUserName(platform, id)
+	result := &domain.ForgetUserResult{PlatformID: platformID, UserName: name}
+
+	if recordsSince != nil && result.UserName != "" {
+		bills, _, _, err := u.billRepo.QueryTransactions(ctx, result.UserName, *recordsSince, time.Now(), 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query records of %s: %w", result.UserName, err)
+		}
+		var own []*domain.Bill
+		for _, bill := range bills {
+			if bill.UserName == result.UserName && bill.RecordID != "" {
+				own = append(own, bill)
+			}
+		}
+		if err := u.DeleteBills(ctx, own); err != nil {
+			return nil, err
+		}
+		result.DeletedRecords = len(own)
+	}
+
+	if err := u.deleteLinkedMappings(platform, id); err != nil {
+		return nil, err
+	}
+	if err := u.userMappingRepo.DeleteMapping(platform, id); err != nil {
+		return nil, err
+	}
+	u.logFor(ctx).Info("User forgotten: platform_id=%s, name=%s, deleted_records=%d", platformID, result.UserName, result.DeletedRecords)
+	return result, nil
+}
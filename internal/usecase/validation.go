@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+)
+
+// ValidationPolicy configures the checks a new bill must pass before it is written
+type ValidationPolicy struct {
+	MaxAmount            float64 // 金额超过该值时需要用户确认，<=0 表示不限制
+	MaxDescriptionLength int     // 描述最多保留的字数，超出部分截断，<=0 表示不限制
+}
+
+// validateInput 规范化并校验新账单：统一全角字符、截断过长的描述、金额取整到分，拒绝无效金额和空描述
+// 金额超过上限时返回需要确认的错误，用户确认后（ctx 带有确认标记）照常记录
+func (u *BillUseCaseImpl) validateInput(ctx context.Context, in *domain.NewBillInput) error {
+	in.Description = domain.CleanDescription(in.Description, u.validation.MaxDescriptionLength)
+	in.Account = strings.TrimSpace(domain.NormalizeWidth(in.Account))
+	in.Amount = domain.RoundAmount(in.Amount)
+
+	err := validateBillInput(*in, u.validation, domain.LargeAmountConfirmed(ctx))
+	if err != nil {
+		u.logFor(ctx).Warn("Bill input rejected: description=%s, amount=%v, err=%v", in.Description, in.Amount, err)
+	}
+	return err
+}
+
+// validateBillInput 校验已规范化的账单，confirmed 为 true 时不检查金额上限
+func validateBillInput(in domain.NewBillInput, policy ValidationPolicy, confirmed bool) error {
+	invalid := func(reason domain.BillValidationReason) error {
+		return &domain.BillValidationError{Reason: reason, Description: in.Description, Amount: in.Amount, Limit: policy.MaxAmount}
+	}
+	switch {
+	case math.IsNaN(in.Amount) || math.IsInf(in.Amount, 0) || in.Amount <= 0:
+		return invalid(domain.ValidationAmountInvalid)
+	case in.Description == "":
+		return invalid(domain.ValidationDescriptionEmpty)
+	case policy.MaxAmount > 0 && in.Amount > policy.MaxAmount && !confirmed:
+		return invalid(domain.ValidationAmountTooLarge)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wyg1997/LedgerBot/internal/domain"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
+)
+
+func TestValidateBillInput(t *testing.T) {
+	policy := ValidationPolicy{MaxAmount: 100000}
+	tests := []struct {
+		name      string
+		in        domain.NewBillInput
+		policy    ValidationPolicy
+		confirmed bool
+		want      domain.BillValidationReason // 空表示通过
+	}{
+		{"valid", domain.NewBillInput{Description: "午饭", Amount: 35}, policy, false, ""},
+		{"at the limit", domain.NewBillInput{Description: "学费", Amount: 100000}, policy, false, ""},
+		{"above the limit", domain.NewBillInput{Description: "三千", Amount: 3000000}, policy, false, domain.ValidationAmountTooLarge},
+		{"above the limit confirmed", domain.NewBillInput{Description: "买房首付", Amount: 3000000}, policy, true, ""},
+		{"no limit", domain.NewBillInput{Description: "买房首付", Amount: 3000000}, ValidationPolicy{}, false, ""},
+		{"zero", domain.NewBillInput{Description: "午饭", Amount: 0}, policy, false, domain.ValidationAmountInvalid},
+		{"negative", domain.NewBillInput{Description: "午饭", Amount: -35}, policy, false, domain.ValidationAmountInvalid},
+		{"NaN", domain.NewBillInput{Description: "午饭", Amount: math.NaN()}, policy, false, domain.ValidationAmountInvalid},
+		{"infinite", domain.NewBillInput{Description: "午饭", Amount: math.Inf(1)}, ValidationPolicy{}, false, domain.ValidationAmountInvalid},
+		// 确认只跳过金额上限，不跳过其他检查
+		{"invalid amount confirmed", domain.NewBillInput{Description: "午饭", Amount: -1}, policy, true, domain.ValidationAmountInvalid},
+		{"empty description", domain.NewBillInput{Description: "", Amount: 35}, policy, false, domain.ValidationDescriptionEmpty},
+		// 金额无效优先于描述为空
+		{"empty and invalid", domain.NewBillInput{Amount: 0}, policy, false, domain.ValidationAmountInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBillInput(tt.in, tt.policy, tt.confirmed)
+			var invalidErr *domain.BillValidationError
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("validateBillInput = %v, want no error", err)
+			case tt.want != "" && (!errors.As(err, &invalidErr) || invalidErr.Reason != tt.want):
+				t.Errorf("validateBillInput = %v, want %s", err, tt.want)
+			case tt.want == domain.ValidationAmountTooLarge && invalidErr.Limit != tt.policy.MaxAmount:
+				t.Errorf("Limit = %v, want %v", invalidErr.Limit, tt.policy.MaxAmount)
+			}
+		})
+	}
+}
+
+// 写入前统一全角字符、截断描述并把金额取整到分
+func TestCreateBillNormalizesInput(t *testing.T) {
+	u := NewBillUseCase(repository.NewMemoryBillRepository(time.UTC), nil, nil, nil, 0, DuplicatePolicy{},
+		ValidationPolicy{MaxAmount: 100000, MaxDescriptionLength: 6}, nil, nil, nil, nil, nil, time.UTC)
+	ctx := context.Background()
+
+	bill, err := u.CreateBill(ctx, "张三", "ou_1", domain.NewBillInput{
+		Description: "　ＫＦＣ  全家桶套餐　",
+		Amount:      19.999999,
+		Type:        domain.BillTypeExpense,
+		Account:     " 招行　",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bill.Description != "KFC 全家" || bill.Amount != 20 || bill.Account != "招行" {
+		t.Errorf("bill = %q, %v, %q, want normalized description, amount and account", bill.Description, bill.Amount, bill.Account)
+	}
+
+	var invalidErr *domain.BillValidationError
+	if _, err := u.CreateBill(ctx, "张三", "ou_1", domain.NewBillInput{Description: "三千", Amount: 3000000, Type: domain.BillTypeExpense}); !errors.As(err, &invalidErr) || !invalidErr.NeedsConfirmation() {
+		t.Errorf("CreateBill above the limit = %v, want a confirmation request", err)
+	}
+	if _, err := u.CreateBill(domain.WithLargeAmountConfirmed(ctx), "张三", "ou_1", domain.NewBillInput{Description: "买车", Amount: 300000, Type: domain.BillTypeExpense}); err != nil {
+		t.Errorf("confirmed CreateBill = %v, want it recorded", err)
+	}
+	// 只有空白的描述在规范化后为空
+	if _, err := u.CreateBill(ctx, "张三", "ou_1", domain.NewBillInput{Description: "　 ", Amount: 35, Type: domain.BillTypeExpense}); !errors.As(err, &invalidErr) || invalidErr.Reason != domain.ValidationDescriptionEmpty {
+		t.Errorf("CreateBill with a blank description = %v, want description_empty", err)
+	}
+}
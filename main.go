@@ -2,68 +2,338 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/wyg1997/LedgerBot/config"
+	"github.com/wyg1997/LedgerBot/internal/domain"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/ai"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/feishu"
+	"github.com/wyg1997/LedgerBot/internal/infrastructure/platform/telegram"
 	"github.com/wyg1997/LedgerBot/internal/infrastructure/repository"
 	"github.com/wyg1997/LedgerBot/internal/interfaces/http/handler"
+	"github.com/wyg1997/LedgerBot/internal/interfaces/scheduler"
 	"github.com/wyg1997/LedgerBot/internal/usecase"
+	"github.com/wyg1997/LedgerBot/pkg/alert"
+	"github.com/wyg1997/LedgerBot/pkg/cache"
 	"github.com/wyg1997/LedgerBot/pkg/logger"
+	"github.com/wyg1997/LedgerBot/pkg/version"
+	"github.com/wyg1997/LedgerBot/pkg/workerpool"
 )
 
 func main() {
-	// Load configuration
-	cfg := config.LoadConfig()
+	configFile := flag.String("config", "", "YAML config file; environment variables override its values")
+	flag.Parse()
+
+	// Load configuration once: from the YAML file when -config is given, otherwise from the environment
+	var cfg *config.Config
+	if *configFile != "" {
+		var err error
+		if cfg, err = config.LoadConfigFromFile(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		cfg = config.LoadConfig()
+	}
+
+	switch flag.Arg(0) {
+	case "chat":
+		// ledgerbot chat：命令行对话模式，不连接飞书
+		runChat(cfg)
+		return
+	case "example-config":
+		// ledgerbot example-config：按当前配置输出 YAML 配置文件，密钥留空
+		if err := config.WriteExampleConfig(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := cfg.IsValid(); err != nil {
 		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Set log level
-	logger.SetLogLevel(cfg.Storage.LogLevel)
+	// Set log level, format and output
+	logFile, err := setupLogging(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+		os.Exit(1)
+	}
 	log := logger.GetLogger()
 
-	log.Info("Starting Ledger Bot...")
+	build := version.Get()
+	log.WithFields(logger.Fields{"version": build.Version, "commit": build.Commit, "build_time": build.BuildTime}).Info("Starting Ledger Bot...")
+
+	// 根上下文：收到退出信号时取消，正在进行的飞书调用随之停止
+	rootCtx, rootCancel := context.WithCancel(context.Background())
+	defer rootCancel()
+
+	// 自定义时间范围缺少开始时间时的最早查询日期
+	repository.SetCustomRangeLookbackDays(cfg.AI.QueryLookbackDays)
+
+	// 持久化到 DATA_DIR 的缓存，按名字记录以便输出指标，退出时统一关闭。
+	// CACHE_BACKEND=redis 时缓存和会话状态保存在 Redis 中，多个副本共享；连接失败时退回本地缓存
+	caches := make(map[string]cache.Cache)
+	cacheOptions := cache.Options{
+		CleanupInterval: time.Duration(cfg.Cache.CleanUpIntvl) * time.Second,
+		DefaultTTL:      time.Duration(cfg.Cache.TTL) * time.Second,
+		MaxEntries:      cfg.Cache.MaxEntries,
+	}
+	useRedis := cfg.Cache.Backend == config.CacheBackendRedis
+	redisCache := func(name string) cache.Cache {
+		if !useRedis {
+			return nil
+		}
+		c, err := cache.NewRedisCache(cfg.Cache.RedisAddr, cfg.Cache.RedisPassword, cfg.Cache.RedisDB, "ledgerbot:"+name, cacheOptions.DefaultTTL)
+		if err != nil {
+			// 之后的缓存不再尝试连接，避免每个都等待超时
+			log.Warn("Redis unavailable, falling back to local caches: %v", err)
+			useRedis = false
+			return nil
+		}
+		return c
+	}
+	newCache := func(name string) cache.Cache {
+		key := strings.TrimSuffix(name, ".json")
+		c := redisCache(key)
+		if c == nil {
+			c = cache.NewUserMappingCache(filepath.Join(cfg.Storage.DataDir, name), cacheOptions)
+		}
+		caches[key] = c
+		return c
+	}
+	// 待确认的操作、翻页游标等会话状态，未使用 Redis 时只保存在内存中，由所属的服务关闭
+	newStateCache := func(name string) cache.Cache {
+		if c := redisCache(name); c != nil {
+			return c
+		}
+		return cache.NewMemoryCache()
+	}
 
 	// Initialize services
 	feishuService := feishu.NewFeishuService(&cfg.Feishu)
-	aiService := ai.NewOpenAIService(&cfg.AI)
+	aiService := ai.NewOpenAIService(&cfg.AI, cfg.Server.Location, newStateCache)
+
+	// 管理员接收启动自检、停机通知和错误告警；错误日志在窗口内达到阈值时告警，每个冷却期最多一次
+	admin := newAdminNotifier(cfg.Feishu.AdminOpenID, cfg.AI.Language, feishuService)
+	if cfg.Feishu.AdminOpenID != "" && cfg.Feishu.AlertErrorThreshold > 0 {
+		alerts := alert.NewMonitor(alert.Options{
+			Threshold: cfg.Feishu.AlertErrorThreshold,
+			Window:    time.Duration(cfg.Feishu.AlertWindowMinutes) * time.Minute,
+			Cooldown:  time.Duration(cfg.Feishu.AlertCooldownMinutes) * time.Minute,
+			Language:  cfg.AI.Language,
+		}, func(text string) { go admin.send(rootCtx, text) })
+		logger.SetErrorHook(alerts.Record)
+	}
 
 	// Initialize repositories
-	userMappingRepo, err := repository.NewUserMappingRepository(cfg.Storage.DataDir)
+	var userMappingRepo domain.UserMappingRepository
+	if cfg.Storage.UserStoreBackend == config.UserStoreBolt {
+		userMappingRepo, err = repository.NewBoltUserMappingRepository(filepath.Join(cfg.Storage.DataDir, "users.db"))
+	} else {
+		userMappingRepo, err = repository.NewUserMappingRepository(cfg.Storage.DataDir)
+	}
 	if err != nil {
-		log.Fatal("Failed to create user mapping repository: %v", err)
+		logger.FatalAndExit("Failed to create user mapping repository: %v", err)
 	}
+	// 账单按用户名记录，不允许两个用户使用相同的名字
+	userMappingRepo = repository.NewUniqueNameRepository(userMappingRepo, cfg.Storage.UserNameConflict == config.UserNameConflictSuffix)
 
-	billRepo, err := repository.NewBitableBillRepository(feishuService, &cfg.Feishu)
+	// wiki 节点对应的 app_token 几乎不变，缓存后启动和打开群聊账本时无需再调用 wiki 接口
+	var wikiTokens cache.Cache
+	if cfg.Feishu.WikiTokenCache {
+		wikiTokens = newCache("wiki_tokens.json")
+	}
+	billRepo, err := repository.NewBitableBillRepository(rootCtx, feishuService, &cfg.Feishu, wikiTokens, cfg.Server.Location)
+	if err != nil {
+		logger.FatalAndExit("Failed to create bill repository: %v", err)
+	}
+	// 外层包装之前记下所用的表格和字段校验结果，用于启动自检消息
+	bitableInfo, _ := repository.DescribeBitable(billRepo)
+	if cfg.Storage.Backend == config.StorageBackendDual {
+		// 多维表格便于查看，查询统计改由本地库承担
+		localRepo, err := repository.NewFileBillRepository(filepath.Join(cfg.Storage.DataDir, "bills.json"), cfg.Server.Location)
+		if err != nil {
+			logger.FatalAndExit("Failed to create local bill repository: %v", err)
+		}
+		billRepo = repository.NewDualBillRepository(rootCtx, billRepo, localRepo, cfg.Storage.ReconcileDays)
+		log.Info("Dual-write storage enabled: reconcile_days=%d", cfg.Storage.ReconcileDays)
+	}
+	// 短时间内重复的查询直接返回缓存的结果，通过机器人写入时立即失效；使用 Redis 时各副本共享缓存，任一副本写入都会使其失效
+	var queryCache *repository.QueryCacheBillRepository
+	if cfg.Storage.QueryCacheSeconds > 0 {
+		queryCache = repository.NewQueryCacheBillRepository(billRepo, time.Duration(cfg.Storage.QueryCacheSeconds)*time.Second, redisCache)
+		billRepo = queryCache
+	}
+	// 多维表格暂时不可用时新账单先排队，后台稍后写入
+	outbox, err := repository.NewOutboxBillRepository(rootCtx, billRepo, filepath.Join(cfg.Storage.DataDir, "outbox.json"))
 	if err != nil {
-		log.Fatal("Failed to create bill repository: %v", err)
+		logger.FatalAndExit("Failed to create bill outbox: %v", err)
 	}
+	billRepo = outbox
 
 	// Initialize use cases
-	billUseCase := usecase.NewBillUseCase(billRepo, userMappingRepo)
+	// 消息与所建账单的索引，webhook 重放等重复处理同一条消息时不会重复记账
+	sourceIndex := newCache("message_bills.json")
+	duplicates := usecase.DuplicatePolicy{
+		Window:       time.Duration(cfg.Storage.DuplicateWindowMinutes) * time.Minute,
+		RecordAnyway: cfg.Storage.DuplicateRecordAnyway,
+	}
+	validation := usecase.ValidationPolicy{
+		MaxAmount:            cfg.Storage.MaxBillAmount,
+		MaxDescriptionLength: cfg.Storage.MaxDescriptionLength,
+	}
+	// 分期计划，剩余各期由后台任务每月补记
+	installments, err := repository.NewInstallmentRepository(filepath.Join(cfg.Storage.DataDir, "installments.json"))
+	if err != nil {
+		logger.FatalAndExit("Failed to create installment repository: %v", err)
+	}
+	// 周期记账规则，由后台任务按时记账
+	recurring, err := repository.NewRecurringRepository(filepath.Join(cfg.Storage.DataDir, "recurring.json"))
+	if err != nil {
+		logger.FatalAndExit("Failed to create recurring repository: %v", err)
+	}
+	// 借贷索引，记录借款和还款的对方
+	loans, err := repository.NewLoanRepository(filepath.Join(cfg.Storage.DataDir, "loans.json"))
+	if err != nil {
+		logger.FatalAndExit("Failed to create loan repository: %v", err)
+	}
+	// 账单操作日志，每个用户保留最近的操作用于撤销
+	journal, err := repository.NewOperationJournal(filepath.Join(cfg.Storage.DataDir, "journal.json"))
+	if err != nil {
+		logger.FatalAndExit("Failed to create operation journal: %v", err)
+	}
+	// 记账模板，"老样子"按模板记账
+	templates, err := repository.NewTemplateRepository(filepath.Join(cfg.Storage.DataDir, "templates.json"))
+	if err != nil {
+		logger.FatalAndExit("Failed to create template repository: %v", err)
+	}
+	billUseCase := usecase.NewBillUseCase(billRepo, userMappingRepo, aiService, sourceIndex, time.Duration(cfg.Storage.IdempotencyDays)*24*time.Hour, duplicates, validation, installments, recurring, loans, journal, templates, cfg.Server.Location)
+
+	// 已处理的事件持久化到磁盘，重启后仍能过滤飞书的重试推送
+	seenEvents := newCache("seen_events.json")
+	// 消息与所建账单的对应关系，消息撤回时删除对应账单
+	messageRecords := newCache("message_records.json")
+
+	// 消息处理工作池，限制同时调用 AI 和飞书接口的消息数量
+	pool := workerpool.New(cfg.Server.Workers, cfg.Server.QueueSize)
 
 	// Initialize handlers
-	feishuHandler := handler.NewFeishuHandlerAITools(&cfg.Feishu, feishuService, billUseCase, aiService, userMappingRepo)
+	feishuHandler := handler.NewFeishuHandlerAITools(rootCtx, &cfg.Feishu, &cfg.Import, feishuService, billUseCase, aiService, userMappingRepo, seenEvents, messageRecords, newStateCache, pool, cfg.AI.CurrencySymbol, cfg.AI.Language, cfg.Server.Location)
+
+	// 定时日报
+	if cfg.Report.DailyAt != "" {
+		reportMarkers := newCache("report_markers.json")
+		dailyReport := scheduler.NewDailyReport(&cfg.Report, cfg.Server.Location, cfg.AI.CurrencySymbol, feishuService, billUseCase, userMappingRepo, reportMarkers)
+		go dailyReport.Start(rootCtx)
+	}
+
+	// 每月 1 日补记分期账单，启动时先补记停机期间到期的各期
+	go scheduler.NewInstallmentScheduler(billUseCase, cfg.Server.Location).Start(rootCtx)
+
+	// 周期记账，启动时先补记停机期间到期的账单
+	go scheduler.NewRecurringScheduler(cfg.Report.RecurringAt, cfg.Server.Location, cfg.AI.CurrencySymbol, feishuService, billUseCase).Start(rootCtx)
 
 	// Create HTTP server
 	mux := http.NewServeMux()
 
-	// Feishu webhook endpoint
-	mux.HandleFunc("/webhook/feishu", feishuHandler.Webhook)
+	// 飞书同时提供账单存储，未启用飞书聊天时只是不接收飞书消息
+	if cfg.PlatformEnabled(config.PlatformFeishu) {
+		// Feishu webhook endpoint
+		mux.HandleFunc("/webhook/feishu", feishuHandler.Webhook)
+		// 卡片按钮回调
+		mux.HandleFunc("/webhook/feishu/card", feishuHandler.CardWebhook)
+	}
+
+	// Telegram webhook，回复链对应飞书的话题
+	if cfg.PlatformEnabled(config.PlatformTelegram) {
+		telegramService := telegram.NewService(&cfg.Telegram)
+		telegramMessages := newCache("telegram_messages.json")
+		telegramHandler := handler.NewTelegramHandler(&cfg.Telegram, telegramService, feishuHandler, telegramMessages)
+		mux.HandleFunc("/webhook/telegram", telegramHandler.Webhook)
+	}
+
+	// 账单 REST 接口，未配置令牌时不开放
+	var billAPI *handler.BillAPIHandler
+	if cfg.Server.APIToken != "" {
+		billAPI = handler.NewBillAPIHandler(cfg.Server.APIToken, billUseCase, cfg.Server.Location)
+		billAPI.Register(mux)
+	} else {
+		log.Info("API_TOKEN not set, REST API disabled")
+	}
 
 	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	// /health 为存活检查；/health/ready 检查飞书凭证和多维表格，可选检查 AI 服务
+	healthChecks := []handler.HealthCheck{
+		{Name: "feishu", Check: feishuService.CheckTenantAccessToken},
+		{Name: "bitable", Check: billRepo.Ping},
+	}
+	if cfg.Server.HealthCheckAI {
+		healthChecks = append(healthChecks, handler.HealthCheck{Name: "ai", Check: aiService.Ping})
+	}
+	healthHandler := handler.NewHealthHandler(time.Duration(cfg.Server.HealthCheckTTL)*time.Second, healthChecks...)
+	mux.HandleFunc("/health", healthHandler.Live)
+	mux.HandleFunc("/health/ready", healthHandler.Ready)
+
+	// 版本、提交、构建时间和运行时长
+	mux.HandleFunc("/version", handler.Version)
+
+	// 工作池状态：队列深度、运行中和已处理的消息数；等待写入表格的账单数；以及各缓存的条数和淘汰数
+	appStats := func() interface{} {
+		cacheStats := make(map[string]cache.Stats, len(caches))
+		for name, c := range caches {
+			cacheStats[name] = c.Stats()
+		}
+		var queryStats *repository.QueryCacheStats
+		if queryCache != nil {
+			stats := queryCache.Stats()
+			queryStats = &stats
+		}
+		return struct {
+			workerpool.Stats
+			OutboxPending int                         `json:"outbox_pending"`
+			Caches        map[string]cache.Stats      `json:"caches"`
+			QueryCache    *repository.QueryCacheStats `json:"query_cache,omitempty"`
+		}{pool.Stats(), outbox.Pending(), cacheStats, queryStats}
+	}
+	// 公网入口的 /metrics 需要 API_TOKEN，未配置令牌时只在单独的诊断端口提供
+	if billAPI != nil {
+		billAPI.RegisterMetrics(mux, appStats)
+	}
+
+	// 诊断接口：pprof 和 /debug/stats（运行时内存、GC 以及上面的状态），默认只监听本机的单独端口，不经过公网入口
+	var debugSrv *http.Server
+	if cfg.Server.DebugEndpoints {
+		if cfg.Server.DebugAddr == "" {
+			handler.RegisterDebug(mux, appStats)
+			log.Warn("Debug endpoints enabled on the main port %s", cfg.Server.Port)
+		} else {
+			debugMux := http.NewServeMux()
+			handler.RegisterDebug(debugMux, appStats)
+			debugMux.HandleFunc("/metrics", handler.Metrics(appStats))
+			// 不设置写超时：CPU profile 和 trace 会持续请求指定的秒数
+			debugSrv = &http.Server{Addr: cfg.Server.DebugAddr, Handler: debugMux, ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				log.Info("Debug endpoints listening on %s", cfg.Server.DebugAddr)
+				if err := debugSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					// 诊断接口不可用不影响服务
+					log.Error("Debug server failed: %v", err)
+				}
+			}()
+		}
+	}
 
 	// Create server
 	srv := &http.Server{
@@ -71,20 +341,41 @@ func main() {
 		Handler:      mux,
 		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		// 连接错误、TLS 握手失败等由 net/http 报告的错误写入 http 组件的日志
+		ErrorLog: slog.NewLogLogger(logger.NewSlogHandler(logger.GetLogger("http")), slog.LevelError),
+	}
+
+	// 长连接模式：主动连接飞书接收事件，无需公网 webhook 地址
+	if cfg.PlatformEnabled(config.PlatformFeishu) && cfg.Feishu.ConnectionMode == config.ConnectionModeWebSocket {
+		go feishuHandler.StartLongConnection(rootCtx)
 	}
 
 	// Start server in goroutine
+	serverErr := make(chan error, 1)
 	go func() {
 		log.Info("Server starting on port %s", cfg.Server.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server: %v", err)
+			serverErr <- err
 		}
 	}()
 
+	// 启动自检结果私聊发送给管理员，不阻塞启动
+	go admin.NotifyStartup(rootCtx, cfg, bitableInfo, aiService)
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// 服务无法启动（如端口被占用）时同样走下面的退出流程，队列中的账单和缓存仍会写入，最后以状态 1 退出
+	exitCode := 0
+	var stopReason string
+	select {
+	case sig := <-quit:
+		stopReason = "收到信号 " + sig.String()
+	case err := <-serverErr:
+		log.Fatal("Failed to start server: %v", err)
+		exitCode = 1
+		stopReason = fmt.Sprintf("服务启动失败：%v", err)
+	}
 
 	log.Info("Shutting down server...")
 
@@ -92,9 +383,85 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	admin.NotifyShutdown(ctx, stopReason)
+
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("Server forced to shutdown: %v", err)
 	}
+	if debugSrv != nil {
+		_ = debugSrv.Close()
+	}
+
+	// 等待队列中和处理中的消息完成，超时后放弃
+	if err := pool.Shutdown(ctx); err != nil {
+		log.Error("Worker pool forced to shutdown: %v", err)
+	}
+
+	// 尽量写入排队的账单，未写入的留在队列文件中，下次启动时继续
+	if err := outbox.Flush(ctx); err != nil {
+		log.Warn("Bill outbox not fully flushed: %v", err)
+	}
+
+	// 取消根上下文，停止长连接和定时任务
+	rootCancel()
+
+	if closer, ok := userMappingRepo.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Error("Close user mapping repository: %v", err)
+		}
+	}
+	// 停止缓存的清理协程
+	for _, c := range caches {
+		_ = c.Close()
+	}
+	feishuHandler.Close()
+	if queryCache != nil {
+		_ = queryCache.Close()
+	}
+	if closer, ok := aiService.(io.Closer); ok {
+		_ = closer.Close()
+	}
 
 	log.Info("Server exited")
+	if logFile != nil {
+		_ = logFile.Close()
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// setupLogging 按配置设置日志级别、格式和输出位置；配置了日志文件时返回该文件，退出前关闭
+func setupLogging(cfg *config.Config) (io.Closer, error) {
+	logger.SetLogLevel(cfg.Storage.LogLevel)
+	if cfg.Storage.LogLevels != "" {
+		logger.SetLogLevel(cfg.Storage.LogLevels)
+	}
+	logger.SetFormat(cfg.Storage.LogFormat)
+	// 密钥出现在日志中时（如调试输出的请求、错误中的 URL）替换为 ******
+	logger.AddSecrets(
+		cfg.AI.APIKey, cfg.AI.TranscriptionAPIKey,
+		cfg.Feishu.AppSecret, cfg.Feishu.EncryptKey, cfg.Feishu.Verification,
+		cfg.Telegram.BotToken, cfg.Telegram.WebhookSecret,
+		cfg.Server.APIToken, cfg.Cache.RedisPassword,
+	)
+	logger.SetRedactPII(cfg.Storage.LogRedactPII)
+	if cfg.Storage.LogFile == "" {
+		return nil, nil
+	}
+
+	file, err := logger.NewRotatingFile(cfg.Storage.LogFile, logger.RotateOptions{
+		MaxSizeMB:  cfg.Storage.LogMaxSizeMB,
+		MaxBackups: cfg.Storage.LogMaxBackups,
+		MaxAgeDays: cfg.Storage.LogMaxAgeDays,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Storage.LogStderr {
+		logger.SetOutput(io.MultiWriter(os.Stderr, file))
+	} else {
+		logger.SetOutput(file)
+	}
+	return file, nil
 }
\ No newline at end of file
@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMessageRunes 错误消息超过该长度时截断，同一类错误的不同参数多出现在末尾
+const maxMessageRunes = 120
+
+// Options controls when a Monitor alerts
+type Options struct {
+	Threshold int           // 窗口内的错误数达到该值时告警，<=0 表示不告警
+	Window    time.Duration // 统计错误的滑动窗口
+	Cooldown  time.Duration // 两次告警的最小间隔
+	TopN      int           // 告警中列出的错误种类数，<=0 时为 5
+	Language  string        // 告警的语言：zh（默认）或 en
+}
+
+// summaryText 告警各部分的模板
+type summaryText struct {
+	header  string // 参数为窗口时长和错误数
+	more    string // 参数为未列出的错误种类数
+	minutes string // 参数为分钟数
+}
+
+// summaryTexts 各语言的告警模板，未知语言使用中文
+var summaryTexts = map[string]summaryText{
+	"zh": {header: "⚠️ 最近 %s内出现 %d 个错误", more: "\n…另有 %d 种错误", minutes: "%d 分钟"},
+	"en": {header: "⚠️ %[2]d errors in the last %[1]s", more: "\n…and %d more kinds of errors", minutes: "%d minutes"},
+}
+
+// event 窗口内的一次错误
+type event struct {
+	at  time.Time
+	key string
+}
+
+// Monitor counts errors in a sliding window and calls notify with a summary of the most
+// frequent ones when the count reaches the threshold, at most once per cool-down period
+type Monitor struct {
+	opts   Options
+	notify func(text string)
+	now    func() time.Time
+
+	mu        sync.Mutex
+	events    []event // 按时间先后排列
+	lastAlert time.Time
+}
+
+// NewMonitor creates a monitor sending alerts through notify. notify is called on the
+// goroutine recording the error and should not block
+func NewMonitor(opts Options, notify func(text string)) *Monitor {
+	if opts.TopN <= 0 {
+		opts.TopN = 5
+	}
+	return &Monitor{opts: opts, notify: notify, now: time.Now}
+}
+
+// Record counts an error logged by component, e.g. as a logger.SetErrorHook callback
+func (m *Monitor) Record(component, msg string) {
+	if m.opts.Threshold <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	now := m.now()
+	m.prune(now)
+	m.events = append(m.events, event{at: now, key: errorKey(component, msg)})
+	if len(m.events) < m.opts.Threshold || (!m.lastAlert.IsZero() && now.Sub(m.lastAlert) < m.opts.Cooldown) {
+		m.mu.Unlock()
+		return
+	}
+	m.lastAlert = now
+	text := m.summary()
+	m.mu.Unlock()
+
+	m.notify(text)
+}
+
+// prune 丢弃滑出窗口的错误；调用方持有 mu
+func (m *Monitor) prune(now time.Time) {
+	cutoff := now.Add(-m.opts.Window)
+	i := 0
+	for i < len(m.events) && !m.events[i].at.After(cutoff) {
+		i++
+	}
+	m.events = m.events[i:]
+}
+
+// summary 汇总窗口内的错误，按出现次数从多到少列出前 TopN 种；调用方持有 mu
+func (m *Monitor) summary() string {
+	counts := make(map[string]int)
+	var keys []string
+	for _, e := range m.events {
+		if counts[e.key] == 0 {
+			keys = append(keys, e.key)
+		}
+		counts[e.key]++
+	}
+	// 次数相同时先出现的在前
+	sort.SliceStable(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+
+	var b strings.Builder
+	text, ok := summaryTexts[m.opts.Language]
+	if !ok {
+		text = summaryTexts["zh"]
+	}
+	fmt.Fprintf(&b, text.header, formatWindow(text, m.opts.Window), len(m.events))
+	for i, key := range keys {
+		if i == m.opts.TopN {
+			fmt.Fprintf(&b, text.more, len(keys)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n%d. %s ×%d", i+1, key, counts[key])
+	}
+	return b.String()
+}
+
+// errorKey 以组件和截断后的消息区分错误种类，如 "[feishu] create record failed: ..."
+func errorKey(component, msg string) string {
+	msg = strings.TrimSpace(msg)
+	if r := []rune(msg); len(r) > maxMessageRunes {
+		msg = string(r[:maxMessageRunes]) + "…"
+	}
+	return "[" + component + "] " + msg
+}
+
+// formatWindow 窗口时长的写法，如 "10 分钟"
+func formatWindow(text summaryText, d time.Duration) string {
+	if d >= time.Minute && d%time.Minute == 0 {
+		return fmt.Sprintf(text.minutes, int(d/time.Minute))
+	}
+	return d.String()
+}
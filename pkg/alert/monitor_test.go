@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock 测试用的时钟，只在调用 advance 时前进
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestMonitor 创建使用 fakeClock 的 Monitor，返回收到的告警
+func newTestMonitor(opts Options) (*Monitor, *fakeClock, *[]string) {
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)}
+	var alerts []string
+	m := NewMonitor(opts, func(text string) { alerts = append(alerts, text) })
+	m.now = clock.now
+	return m, clock, &alerts
+}
+
+// 错误分散在窗口之外时不告警
+func TestMonitorSparseErrors(t *testing.T) {
+	m, clock, alerts := newTestMonitor(Options{Threshold: 5, Window: 10 * time.Minute, Cooldown: time.Hour})
+	for i := 0; i < 20; i++ {
+		m.Record("feishu", "create bitable record failed")
+		clock.advance(3 * time.Minute)
+	}
+	if len(*alerts) != 0 {
+		t.Errorf("alerts = %q, want none", *alerts)
+	}
+}
+
+// 窗口内的错误达到阈值时告警一次，列出各类错误的次数
+func TestMonitorBurst(t *testing.T) {
+	m, clock, alerts := newTestMonitor(Options{Threshold: 5, Window: 10 * time.Minute, Cooldown: time.Hour})
+	burst := []struct{ component, msg string }{
+		{"feishu", "create bitable record failed: code=1254040"},
+		{"ai", "chat completion failed: timeout"},
+		{"feishu", "create bitable record failed: code=1254040"},
+		{"handler", "reply failed"},
+		{"feishu", "  create bitable record failed: code=1254040  "},
+	}
+	for _, e := range burst {
+		m.Record(e.component, e.msg)
+		clock.advance(time.Minute)
+	}
+	if len(*alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1: %q", len(*alerts), *alerts)
+	}
+	want := "⚠️ 最近 10 分钟内出现 5 个错误" +
+		"\n1. [feishu] create bitable record failed: code=1254040 ×3" +
+		"\n2. [ai] chat completion failed: timeout ×1" +
+		"\n3. [handler] reply failed ×1"
+	if (*alerts)[0] != want {
+		t.Errorf("alert =\n%s\nwant\n%s", (*alerts)[0], want)
+	}
+}
+
+// 冷却期内不重复告警，冷却期过后再次达到阈值时告警
+func TestMonitorCooldown(t *testing.T) {
+	m, clock, alerts := newTestMonitor(Options{Threshold: 3, Window: 10 * time.Minute, Cooldown: time.Hour})
+	for i := 0; i < 30; i++ {
+		m.Record("feishu", "write failed")
+		clock.advance(time.Minute)
+	}
+	if len(*alerts) != 1 {
+		t.Fatalf("got %d alerts within the cool-down, want 1", len(*alerts))
+	}
+
+	clock.advance(time.Hour)
+	m.Record("feishu", "write failed")
+	if len(*alerts) != 1 {
+		t.Fatalf("alerted after the cool-down with 1 error in the window")
+	}
+	m.Record("feishu", "write failed")
+	m.Record("feishu", "write failed")
+	if len(*alerts) != 2 {
+		t.Fatalf("got %d alerts, want a second one after the cool-down", len(*alerts))
+	}
+	if !strings.HasPrefix((*alerts)[1], "⚠️ 最近 10 分钟内出现 3 个错误") {
+		t.Errorf("second alert = %q, want only the errors still in the window", (*alerts)[1])
+	}
+}
+
+func TestMonitorDisabled(t *testing.T) {
+	m, _, alerts := newTestMonitor(Options{Threshold: 0, Window: time.Minute})
+	for i := 0; i < 100; i++ {
+		m.Record("feishu", "write failed")
+	}
+	if len(*alerts) != 0 || len(m.events) != 0 {
+		t.Errorf("disabled monitor alerted %d times and kept %d events", len(*alerts), len(m.events))
+	}
+}
+
+// 英文告警，超过 TopN 的错误种类合并为一行，过长的消息截断
+func TestMonitorEnglishSummary(t *testing.T) {
+	m, _, alerts := newTestMonitor(Options{Threshold: 4, Window: 90 * time.Second, Cooldown: time.Hour, TopN: 2, Language: "en"})
+	long := strings.Repeat("x", maxMessageRunes+10)
+	m.Record("ai", long)
+	m.Record("ai", long+"-different-tail")
+	m.Record("feishu", "send message failed")
+	m.Record("handler", "reply failed")
+	if len(*alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(*alerts))
+	}
+	want := "⚠️ 4 errors in the last 1m30s" +
+		"\n1. [ai] " + strings.Repeat("x", maxMessageRunes) + "… ×2" +
+		"\n2. [feishu] send message failed ×1" +
+		"\n…and 1 more kinds of errors"
+	if (*alerts)[0] != want {
+		t.Errorf("alert =\n%s\nwant\n%s", (*alerts)[0], want)
+	}
+}
@@ -0,0 +1,114 @@
+// Package atomicfile writes small state files so that a crash never leaves a truncated file behind
+package atomicfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BackupPath returns the path where WriteFile keeps the previous version of path
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// WriteFile replaces path with data atomically: the data is written to a temporary file in the
+// same directory, synced and renamed over path. The previous version is kept at BackupPath(path).
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := backup(path); err != nil {
+		return err
+	}
+	return replace(path, data, perm)
+}
+
+// Load reads path and passes its content to parse. When path cannot be read or parsed, the backup
+// written by WriteFile is tried and, if it parses, copied back to path; fromBackup reports this case.
+// A missing path is returned as is (os.IsNotExist) without looking at the backup.
+// parse may be called twice and must not keep partial results from a failed call.
+func Load(path string, parse func(data []byte) error) (fromBackup bool, err error) {
+	if _, err = readAndParse(path, parse); err == nil || os.IsNotExist(err) {
+		return false, err
+	}
+
+	backupData, backupErr := readAndParse(BackupPath(path), parse)
+	if backupErr != nil {
+		return false, fmt.Errorf("%v (backup: %v)", err, backupErr)
+	}
+	// 用备份覆盖损坏的文件，否则下次保存时会把损坏的内容留作备份
+	if err := replace(path, backupData, 0644); err != nil {
+		return true, fmt.Errorf("restore %s from backup: %v", path, err)
+	}
+	return true, nil
+}
+
+// readAndParse 读取并解析文件，返回读取到的内容
+func readAndParse(path string, parse func(data []byte) error) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := parse(data); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// backup 把当前文件硬链接为备份；文件系统不支持硬链接时复制内容。文件不存在时不需要备份
+func backup(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	bak := BackupPath(path)
+	if err := os.Remove(bak); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove old backup: %v", err)
+	}
+	if err := os.Link(path, bak); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read %s for backup: %v", path, err)
+	}
+	return replace(bak, data, 0644)
+}
+
+// replace 写入同目录下的临时文件并同步到磁盘，再重命名覆盖目标文件
+func replace(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %v", err)
+	}
+	// 重命名成功后临时文件已不存在，Remove 失败可以忽略
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write temp file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temp file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("chmod temp file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %v", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir 同步目录，使重命名在断电后也能保留；部分平台不支持对目录 Sync，失败时忽略
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
@@ -0,0 +1,129 @@
+package atomicfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseJSON 解析 JSON 对象，失败时不保留部分结果
+func parseJSON(into *map[string]string) func(data []byte) error {
+	return func(data []byte) error {
+		m := make(map[string]string)
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		*into = m
+		return nil
+	}
+}
+
+func TestWriteFileKeepsBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	if err := WriteFile(path, []byte(`{"v":"1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次写入时没有旧版本，不产生备份
+	if _, err := os.Stat(BackupPath(path)); !os.IsNotExist(err) {
+		t.Errorf("backup after the first write: %v, want none", err)
+	}
+	if err := WriteFile(path, []byte(`{"v":"2"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"v":"2"}` {
+		t.Errorf("file = %s, want version 2", data)
+	}
+	if data, _ := os.ReadFile(BackupPath(path)); string(data) != `{"v":"1"}` {
+		t.Errorf("backup = %s, want version 1", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	// 不留下临时文件
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	var got map[string]string
+
+	if fromBackup, err := Load(path, parseJSON(&got)); !os.IsNotExist(err) || fromBackup {
+		t.Errorf("Load of a missing file = %v, %v, want os.IsNotExist", fromBackup, err)
+	}
+
+	if err := WriteFile(path, []byte(`{"v":"1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fromBackup, err := Load(path, parseJSON(&got)); err != nil || fromBackup || got["v"] != "1" {
+		t.Errorf("Load = %v, %v, %v, want version 1", got, fromBackup, err)
+	}
+}
+
+// 模拟写入中途断电留下的半截文件：从备份恢复，并用备份覆盖损坏的文件
+func TestLoadTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	for _, v := range []string{`{"v":"1"}`, `{"v":"2"}`} {
+		if err := WriteFile(path, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(`{"v":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	fromBackup, err := Load(path, parseJSON(&got))
+	if err != nil || !fromBackup || got["v"] != "1" {
+		t.Fatalf("Load = %v, %v, %v, want version 1 from the backup", got, fromBackup, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"v":"1"}` {
+		t.Errorf("file after restore = %s, want the backup", data)
+	}
+
+	// 恢复后再次保存，备份是恢复的版本而不是损坏的内容
+	if err := WriteFile(path, []byte(`{"v":"3"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(BackupPath(path)); string(data) != `{"v":"1"}` {
+		t.Errorf("backup after restore = %s, want version 1", data)
+	}
+}
+
+func TestLoadCorruptWithoutBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"v":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	fromBackup, err := Load(path, parseJSON(&got))
+	if err == nil || fromBackup || got != nil {
+		t.Fatalf("Load = %v, %v, %v, want an error", got, fromBackup, err)
+	}
+	if !strings.Contains(err.Error(), "backup") {
+		t.Errorf("error %q does not mention the backup", err)
+	}
+
+	// 备份同样损坏时报错，不覆盖原文件
+	if err := os.WriteFile(BackupPath(path), []byte(`not json`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, parseJSON(&got)); err == nil {
+		t.Error("Load with a corrupt backup succeeded")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"v":` {
+		t.Errorf("file = %s, want it left as is", data)
+	}
+}
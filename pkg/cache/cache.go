@@ -1,11 +1,17 @@
 package cache
 
 import (
+	"container/list"
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/wyg1997/LedgerBot/pkg/atomicfile"
+	"github.com/wyg1997/LedgerBot/pkg/logger"
 )
 
 // Cache interface for caching system
@@ -16,33 +22,112 @@ type Cache interface {
 	// Set sets a value in cache with TTL
 	Set(key string, value interface{}, ttl time.Duration) error
 
+	// SetNX sets a value only when key is missing or expired and reports whether it was set.
+	// The check and the write are atomic, also across replicas sharing a Redis cache
+	SetNX(key string, value interface{}, ttl time.Duration) (bool, error)
+
+	// GetDel retrieves a value and removes it in one atomic step, so only one caller, also across
+	// replicas sharing a Redis cache, gets the value
+	GetDel(key string, value interface{}) error
+
 	// Delete removes a value from cache
 	Delete(key string) error
 
+	// DeletePrefix removes every value whose key starts with prefix and returns how many were removed
+	DeletePrefix(prefix string) (int, error)
+
 	// Exists checks if a key exists
 	Exists(key string) bool
 
 	// Clear clears all cache
 	Clear() error
+
+	// Close stops the background cleanup; the cache must not be used afterwards
+	Close() error
+
+	// Stats returns the current size and eviction count of the cache
+	Stats() Stats
+}
+
+// Factory creates the cache called name, letting the caller choose where a component's caches live
+type Factory func(name string) Cache
+
+// Create calls f, or returns NewMemoryCache when f is nil
+func (f Factory) Create(name string) Cache {
+	if f == nil {
+		return NewMemoryCache()
+	}
+	return f(name)
+}
+
+// Stats describes the size of a cache for metrics
+type Stats struct {
+	Entries    int    `json:"entries"`
+	MaxEntries int    `json:"max_entries"`
+	Evictions  uint64 `json:"evictions"` // 因超过条数上限被淘汰的项数，不含过期删除
+}
+
+// Options configures NewUserMappingCache; zero fields use the defaults below
+type Options struct {
+	// CleanupInterval 清理过期项的间隔
+	CleanupInterval time.Duration
+	// DefaultTTL Set 未指定有效期（ttl <= 0）时使用的过期时间
+	DefaultTTL time.Duration
+	// MaxEntries 最多保存的项数，超过时淘汰最久未使用的项
+	MaxEntries int
 }
 
+// 未指定时使用的清理间隔、过期时间和条数上限
+const (
+	DefaultCleanupInterval = 5 * time.Minute
+	DefaultTTL             = time.Hour
+	DefaultMaxEntries      = 10000
+)
+
 // userMappingCache implements Cache for user mappings
 type userMappingCache struct {
 	items map[string]*cacheItem
-	mu    sync.RWMutex
+	// order 按最近使用排序的 key，最近使用的在前
+	order *list.List
+	mu    sync.Mutex
 	file  string
+	// ttl Set 未指定有效期（ttl <= 0）时使用的过期时间
+	ttl        time.Duration
+	maxEntries int
+	evictions  uint64
+	// dirty Get 删除了过期项但尚未写入文件，由清理协程保存，读取时不做磁盘 IO
+	dirty bool
+	// done 关闭后清理协程退出
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 type cacheItem struct {
 	Value     interface{}   `json:"value"`
 	ExpiredAt time.Time     `json:"expired_at"`
+	elem      *list.Element // 在 order 中的位置
 }
 
-// NewUserMappingCache creates a new user mapping cache with file persistence
-func NewUserMappingCache(file string) Cache {
+// NewUserMappingCache creates a new user mapping cache with file persistence. Expired items are
+// removed every opts.CleanupInterval until Close, and the least recently used items are evicted
+// when more than opts.MaxEntries are set
+func NewUserMappingCache(file string, opts Options) Cache {
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = DefaultCleanupInterval
+	}
+	if opts.DefaultTTL <= 0 {
+		opts.DefaultTTL = DefaultTTL
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultMaxEntries
+	}
 	cache := &userMappingCache{
-		items: make(map[string]*cacheItem),
-		file:  file,
+		items:      make(map[string]*cacheItem),
+		order:      list.New(),
+		file:       file,
+		ttl:        opts.DefaultTTL,
+		maxEntries: opts.MaxEntries,
+		done:       make(chan struct{}),
 	}
 
 	// Try to load from file
@@ -51,41 +136,73 @@ func NewUserMappingCache(file string) Cache {
 	}
 
 	// Start cleanup routine
-	go cache.cleanup()
+	go cache.cleanup(opts.CleanupInterval)
 
 	return cache
 }
 
+// NewMemoryCache creates an in-memory cache without file persistence, using the default options
+func NewMemoryCache() Cache {
+	return NewUserMappingCache("", Options{})
+}
+
 // Get retrieves a value from cache
 func (c *userMappingCache) Get(key string, value interface{}) error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	stored, err := c.GetRaw(key)
+	if err != nil {
+		return err
+	}
+
+	// Marshal and unmarshal to copy the value
+	return convert(stored, value)
+}
+
+// GetRaw returns the stored value itself without copying it and marks the item as recently used.
+// Items loaded from the persistence file hold generic JSON types. Expired items are removed here
+// and written to the file by the cleanup goroutine
+func (c *userMappingCache) GetRaw(key string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	item, exists := c.items[key]
 	if !exists {
-		return fmt.Errorf("key not found: %s", key)
+		return nil, fmt.Errorf("key not found: %s", key)
 	}
 
 	// Check if expired
 	if time.Now().After(item.ExpiredAt) {
-		delete(c.items, key)
-		c.save() // Save to file
-		return fmt.Errorf("key expired: %s", key)
-	}
-
-	// Marshal and unmarshal to copy the value
-	data, err := json.Marshal(item.Value)
-	if err != nil {
-		return fmt.Errorf("failed to marshal value: %v", err)
+		c.remove(key, item)
+		c.dirty = c.file != ""
+		return nil, fmt.Errorf("key expired: %s", key)
 	}
-
-	return json.Unmarshal(data, value)
+	c.order.MoveToFront(item.elem)
+	return item.Value, nil
 }
 
-// Set sets a value in cache with TTL
+// Set sets a value in cache with TTL; a non-positive ttl uses the cache's default TTL.
+// When the cache is full, the least recently used item is evicted
 func (c *userMappingCache) Set(key string, value interface{}, ttl time.Duration) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	return c.set(key, value, ttl)
+}
+
+// SetNX sets a value only when key is missing or expired and reports whether it was set
+func (c *userMappingCache) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, exists := c.items[key]; exists && time.Now().Before(item.ExpiredAt) {
+		return false, nil
+	}
+	return true, c.set(key, value, ttl)
+}
+
+// set 写入一项并在超出上限时淘汰最久未使用的项，调用方持有锁
+func (c *userMappingCache) set(key string, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
 
 	// Create cache item
 	item := &cacheItem{
@@ -94,25 +211,91 @@ func (c *userMappingCache) Set(key string, value interface{}, ttl time.Duration)
 	}
 
 	// Store in map
+	if old, exists := c.items[key]; exists {
+		item.elem = old.elem
+		c.order.MoveToFront(item.elem)
+	} else {
+		item.elem = c.order.PushFront(key)
+	}
 	c.items[key] = item
+	for len(c.items) > c.maxEntries {
+		oldest := c.order.Back().Value.(string)
+		c.remove(oldest, c.items[oldest])
+		c.evictions++
+	}
 
 	// Save to file
 	return c.save()
 }
 
+// GetDel retrieves a value and removes it under the same lock
+func (c *userMappingCache) GetDel(key string, value interface{}) error {
+	stored, err := c.GetDelRaw(key)
+	if err != nil {
+		return err
+	}
+	return convert(stored, value)
+}
+
+// GetDelRaw removes the item and returns the stored value itself; expired items are removed too
+// but reported as missing
+func (c *userMappingCache) GetDelRaw(key string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, exists := c.items[key]
+	if !exists {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	c.remove(key, item)
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	if time.Now().After(item.ExpiredAt) {
+		return nil, fmt.Errorf("key expired: %s", key)
+	}
+	return item.Value, nil
+}
+
 // Delete removes a value from cache
 func (c *userMappingCache) Delete(key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	delete(c.items, key)
+	if item, exists := c.items[key]; exists {
+		c.remove(key, item)
+	}
 	return c.save()
 }
 
-// Exists checks if a key exists
+// DeletePrefix removes every value whose key starts with prefix
+func (c *userMappingCache) DeletePrefix(prefix string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, item := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(key, item)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, c.save()
+}
+
+// remove 从 map 和使用顺序中删除一项，调用方持有锁
+func (c *userMappingCache) remove(key string, item *cacheItem) {
+	delete(c.items, key)
+	c.order.Remove(item.elem)
+}
+
+// Exists checks if a key exists; unlike Get it does not mark the item as recently used
 func (c *userMappingCache) Exists(key string) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	item, exists := c.items[key]
 	if !exists {
@@ -129,31 +312,92 @@ func (c *userMappingCache) Clear() error {
 	defer c.mu.Unlock()
 
 	c.items = make(map[string]*cacheItem)
+	c.order.Init()
 	return c.save()
 }
 
+// Stats returns the current size and eviction count of the cache
+func (c *userMappingCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{Entries: len(c.items), MaxEntries: c.maxEntries, Evictions: c.evictions}
+}
+
+// Close stops the cleanup goroutine and saves expired items removed since the last save;
+// calling it more than once is safe
+func (c *userMappingCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.dirty {
+			err = c.save()
+		}
+	})
+	return err
+}
+
 // load loads cache from file
+// 文件损坏时（如写入中途断电）尝试上一次保存时留下的备份
 func (c *userMappingCache) load() error {
 	if c.file == "" {
 		return nil
 	}
 
-	data, err := os.ReadFile(c.file)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // File doesn't exist, which is OK
-		}
-		return fmt.Errorf("failed to read cache file: %v", err)
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if len(data) == 0 {
+	fromBackup, err := atomicfile.Load(c.file, func(data []byte) error {
+		items := make(map[string]*cacheItem)
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return err
+			}
+		}
+		c.restore(items)
 		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil // File doesn't exist, which is OK
+	}
+	if fromBackup {
+		logger.GetLogger("cache").Error("Cache file %s is corrupted, restored %d items from %s", c.file, len(c.items), atomicfile.BackupPath(c.file))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load cache file: %v", err)
 	}
+	return nil
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// restore 用从文件读取的项替换当前内容：丢弃过期项，超过条数上限时保留最晚过期的项。
+// 文件中没有使用顺序，以过期时间近似，越晚过期的越靠前
+func (c *userMappingCache) restore(items map[string]*cacheItem) {
+	now := time.Now()
+	keys := make([]string, 0, len(items))
+	for key, item := range items {
+		if item != nil && now.Before(item.ExpiredAt) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return items[keys[i]].ExpiredAt.After(items[keys[j]].ExpiredAt)
+	})
+	if len(keys) > c.maxEntries {
+		keys = keys[:c.maxEntries]
+	}
 
-	return json.Unmarshal(data, &c.items)
+	c.items = make(map[string]*cacheItem, len(keys))
+	c.order.Init()
+	for _, key := range keys {
+		item := items[key]
+		item.elem = c.order.PushBack(key)
+		c.items[key] = item
+	}
+	// 丢弃了部分项时，下次清理写回文件
+	c.dirty = len(keys) < len(items)
 }
 
 // save saves cache to file
@@ -171,25 +415,31 @@ func (c *userMappingCache) save() error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal cache: %v", err)
 	}
+	c.dirty = false
 
-	return os.WriteFile(c.file, data, 0644)
+	// 先写临时文件再重命名，并保留上一版本作为备份，写入中途退出不会损坏文件
+	return atomicfile.WriteFile(c.file, data, 0644)
 }
 
-// cleanup runs periodically to remove expired items
-func (c *userMappingCache) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
+// cleanup runs periodically to remove expired items until Close is called
+func (c *userMappingCache) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
-		<-ticker.C
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
 
 		c.mu.Lock()
-		changed := false
+		changed := c.dirty
 		now := time.Now()
 
 		for key, item := range c.items {
 			if now.After(item.ExpiredAt) {
-				delete(c.items, key)
+				c.remove(key, item)
 				changed = true
 			}
 		}
@@ -0,0 +1,341 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// 并发读写同时有项过期，用 go test -race 运行以发现数据竞争
+func TestCacheConcurrentAccess(t *testing.T) {
+	c := NewUserMappingCache(filepath.Join(t.TempDir(), "cache.json"), Options{
+		CleanupInterval: time.Millisecond,
+		MaxEntries:      50,
+	})
+	defer c.Close()
+
+	const workers = 8
+	const rounds = 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				key := fmt.Sprintf("key%d", (w+i)%80)
+				switch i % 5 {
+				case 0:
+					// 很快过期，读和清理协程都会删除它
+					_ = c.Set(key, i, time.Microsecond)
+				case 1:
+					_ = c.Set(key, i, time.Minute)
+				case 2:
+					_ = c.Delete(key)
+				case 3:
+					_, _ = c.SetNX(key, i, time.Minute)
+				default:
+					var v int
+					_ = c.Get(key, &v)
+					c.Exists(key)
+				}
+			}
+		}(w)
+	}
+	// 读取同时也通过泛型包装和按前缀删除访问
+	typed := NewTyped[int](c)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			typed.Get(fmt.Sprintf("key%d", i%80))
+			if i%50 == 0 {
+				_, _ = c.DeletePrefix("key1")
+			}
+			c.Stats()
+		}
+	}()
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Entries > stats.MaxEntries {
+		t.Errorf("Stats = %+v, entries over the cap", stats)
+	}
+}
+
+func TestCacheGetExpired(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+
+	if err := c.Set("k", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	var v int
+	if err := c.Get("k", &v); err == nil {
+		t.Fatal("Get of an expired item succeeded")
+	}
+	if n := c.Stats().Entries; n != 0 {
+		t.Errorf("expired item not removed on Get, %d entries left", n)
+	}
+}
+
+func TestCacheGetDel(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+
+	if err := c.Set("k", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var v int
+	if err := c.GetDel("k", &v); err != nil || v != 1 {
+		t.Fatalf("GetDel = %d, %v, want 1", v, err)
+	}
+	if err := c.GetDel("k", &v); err == nil {
+		t.Error("second GetDel succeeded")
+	}
+
+	// 过期项返回错误并被删除
+	if err := c.Set("old", 1, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := c.GetDel("old", &v); err == nil {
+		t.Error("GetDel of an expired item succeeded")
+	}
+	if n := c.Stats().Entries; n != 0 {
+		t.Errorf("%d entries left, want 0", n)
+	}
+
+	// 泛型包装返回保存的值本身
+	typed := NewTyped[[]string](NewMemoryCache())
+	defer typed.Close()
+	if err := typed.Set("k", []string{"a"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := typed.GetDel("k"); !ok || len(got) != 1 || got[0] != "a" {
+		t.Errorf("Typed.GetDel = %v, %v, want [a]", got, ok)
+	}
+	if _, ok := typed.GetDel("k"); ok {
+		t.Error("second Typed.GetDel succeeded")
+	}
+}
+
+// 缓存文件写入中途断电留下半截文件时从备份恢复
+func TestCacheTruncatedFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.json")
+	c := NewUserMappingCache(file, Options{})
+	if err := c.Set("a", "1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("b", "2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c = NewUserMappingCache(file, Options{})
+	defer c.Close()
+	var v string
+	if err := c.Get("a", &v); err != nil || v != "1" {
+		t.Errorf("Get(a) = %q, %v, want 1 from the backup", v, err)
+	}
+	if c.Exists("b") {
+		t.Error("item saved after the backup found")
+	}
+}
+
+// Close 停止清理协程，不留下泄漏的 goroutine
+func TestCacheCloseStopsCleanup(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	file := NewUserMappingCache(filepath.Join(t.TempDir(), "cache.json"), Options{CleanupInterval: time.Millisecond})
+	memory := NewMemoryCache()
+	typed := NewTyped[int](NewMemoryCache())
+	if err := file.Set("k", 1, time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	// 让清理协程至少运行一次
+	time.Sleep(5 * time.Millisecond)
+
+	for _, c := range []interface{ Close() error }{file, memory, typed} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// 重复关闭是安全的
+		if err := c.Close(); err != nil {
+			t.Errorf("second Close = %v", err)
+		}
+	}
+}
+
+// 清理协程按配置的间隔删除过期项，不需要等到下一次读取
+func TestCacheCleanupInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c := NewUserMappingCache(path, Options{CleanupInterval: 5 * time.Millisecond})
+	if err := c.Set("old", 1, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("new", 2, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.Stats().Entries != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expired item not cleaned up, %d entries", c.Stats().Entries)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewUserMappingCache(path, Options{})
+	defer reloaded.Close()
+	if reloaded.Exists("old") || !reloaded.Exists("new") {
+		t.Error("cleanup not saved to the file")
+	}
+}
+
+// 未指定有效期时使用配置的默认过期时间
+func TestCacheDefaultTTL(t *testing.T) {
+	c := NewUserMappingCache("", Options{DefaultTTL: 10 * time.Millisecond})
+	defer c.Close()
+	if err := c.Set("default", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("explicit", 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if c.Exists("default") {
+		t.Error("item set without a TTL outlived the default TTL")
+	}
+	if !c.Exists("explicit") {
+		t.Error("item with an explicit TTL expired")
+	}
+}
+
+// 超过上限时淘汰最久未使用的项：Get 更新使用顺序，Exists 不更新
+func TestCacheLRUEviction(t *testing.T) {
+	c := NewUserMappingCache("", Options{MaxEntries: 3})
+	defer c.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := c.Set(key, key, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var v string
+	if err := c.Get("a", &v); err != nil {
+		t.Fatal(err)
+	}
+	c.Exists("b")
+
+	// 使用顺序为 a c b，b 最久未使用
+	if err := c.Set("d", "d", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	assertKeys(t, c, []string{"a", "c", "d"}, []string{"b"})
+
+	// 覆盖已有的项同样算作使用，不淘汰其他项
+	if err := c.Set("c", "c2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("e", "e", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	assertKeys(t, c, []string{"c", "d", "e"}, []string{"a"})
+
+	if stats := c.Stats(); stats != (Stats{Entries: 3, MaxEntries: 3, Evictions: 2}) {
+		t.Errorf("Stats = %+v, want 3 entries and 2 evictions", stats)
+	}
+	// 删除和过期不计入淘汰数
+	if err := c.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.Entries != 2 || stats.Evictions != 2 {
+		t.Errorf("Stats after Delete = %+v", stats)
+	}
+}
+
+// 保存的文件不超过上限；用更小的上限加载时保留最晚过期的项，并丢弃已过期的项
+func TestCacheLoadRespectsCap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c := NewUserMappingCache(path, Options{MaxEntries: 4})
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		if err := c.Set(key, key, time.Duration(i+1)*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved) != 4 {
+		t.Fatalf("saved %d items, %v, want the cap of 4", len(saved), err)
+	}
+
+	smaller := NewUserMappingCache(path, Options{MaxEntries: 2})
+	assertKeys(t, smaller, []string{"d", "e"}, []string{"a", "b", "c"})
+	if stats := smaller.Stats(); stats.Entries != 2 || stats.MaxEntries != 2 {
+		t.Errorf("Stats after load = %+v, want 2 of 2", stats)
+	}
+	// 加载时丢弃的项在关闭时写回文件
+	if err := smaller.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewUserMappingCache(path, Options{MaxEntries: 10})
+	defer reloaded.Close()
+	assertKeys(t, reloaded, []string{"d", "e"}, []string{"b", "c"})
+}
+
+func TestCacheLoadDropsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	c := NewUserMappingCache(path, Options{})
+	if err := c.Set("short", 1, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("long", 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	reloaded := NewUserMappingCache(path, Options{})
+	defer reloaded.Close()
+	assertKeys(t, reloaded, []string{"long"}, []string{"short"})
+	if n := reloaded.Stats().Entries; n != 1 {
+		t.Errorf("%d entries after load, want 1", n)
+	}
+}
+
+// assertKeys 检查 present 中的键都存在、absent 中的键都不存在
+func assertKeys(t *testing.T, c Cache, present, absent []string) {
+	t.Helper()
+	for _, key := range present {
+		if !c.Exists(key) {
+			t.Errorf("%s evicted, want it kept", key)
+		}
+	}
+	for _, key := range absent {
+		if c.Exists(key) {
+			t.Errorf("%s kept, want it evicted", key)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout 单次 Redis 操作的超时时间，Cache 接口不带 context
+const redisTimeout = 3 * time.Second
+
+// redisScanCount 按前缀遍历 key 时每批返回的数量
+const redisScanCount = 500
+
+// redisCache implements Cache on Redis so that several replicas share the same entries.
+// Keys are stored as "<namespace>:<key>" with the TTL mapped to the Redis expiration
+type redisCache struct {
+	client    *redis.Client
+	namespace string
+	ttl       time.Duration
+}
+
+// NewRedisCache connects to the Redis server at addr and returns a Cache storing its keys under
+// namespace, so several caches can share one database. defaultTTL applies to Set calls without a
+// TTL (DefaultTTL when non-positive). The connection is checked with PING before returning
+func NewRedisCache(addr, password string, db int, namespace string, defaultTTL time.Duration) (Cache, error) {
+	if defaultTTL <= 0 {
+		defaultTTL = DefaultTTL
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis %s: %v", addr, err)
+	}
+	return &redisCache{client: client, namespace: namespace, ttl: defaultTTL}, nil
+}
+
+// Get retrieves a value from cache
+func (c *redisCache) Get(key string, value interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return fmt.Errorf("key not found: %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s from redis: %v", key, err)
+	}
+	return json.Unmarshal(data, value)
+}
+
+// Set sets a value in cache with TTL; a non-positive ttl uses the cache's default TTL
+func (c *redisCache) Set(key string, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := c.client.Set(ctx, c.key(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set %s in redis: %v", key, err)
+	}
+	return nil
+}
+
+// SetNX sets a value with SET NX, so only one replica succeeds for the same key
+func (c *redisCache) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	set, err := c.client.SetNX(ctx, c.key(key), data, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set %s in redis: %v", key, err)
+	}
+	return set, nil
+}
+
+// GetDel retrieves and removes a value with GETDEL, so only one replica gets it
+func (c *redisCache) GetDel(key string, value interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.client.GetDel(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return fmt.Errorf("key not found: %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to getdel %s from redis: %v", key, err)
+	}
+	return json.Unmarshal(data, value)
+}
+
+// Delete removes a value from cache
+func (c *redisCache) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.client.Del(ctx, c.key(key)).Err(); err != nil {
+		return fmt.Errorf("failed to delete %s from redis: %v", key, err)
+	}
+	return nil
+}
+
+// DeletePrefix removes every value whose key starts with prefix
+func (c *redisCache) DeletePrefix(prefix string) (int, error) {
+	keys, err := c.scan(prefix)
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	removed, err := c.client.Del(ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete keys with prefix %s from redis: %v", prefix, err)
+	}
+	return int(removed), nil
+}
+
+// Exists checks if a key exists
+func (c *redisCache) Exists(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	n, err := c.client.Exists(ctx, c.key(key)).Result()
+	return err == nil && n > 0
+}
+
+// Clear removes every key of the namespace
+func (c *redisCache) Clear() error {
+	_, err := c.DeletePrefix("")
+	return err
+}
+
+// Close closes the connection to Redis
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
+
+// Stats counts the keys of the namespace; Redis evicts by its own maxmemory policy, so the
+// cap and eviction count are not tracked
+func (c *redisCache) Stats() Stats {
+	keys, _ := c.scan("")
+	return Stats{Entries: len(keys)}
+}
+
+// key 返回带命名空间的 Redis key
+func (c *redisCache) key(key string) string {
+	return c.namespace + ":" + key
+}
+
+// scan 列出命名空间下以 prefix 开头的全部 Redis key
+func (c *redisCache) scan(prefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	var keys []string
+	iter := c.client.Scan(ctx, 0, escapePattern(c.key(prefix))+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan redis keys with prefix %s: %v", prefix, err)
+	}
+	return keys, nil
+}
+
+// escapePattern 转义 SCAN MATCH 中有特殊含义的字符，使前缀按字面匹配
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
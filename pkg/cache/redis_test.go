@@ -0,0 +1,216 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisCache(t *testing.T, mr *miniredis.Miniredis, namespace string) Cache {
+	t.Helper()
+	c, err := NewRedisCache(mr.Addr(), "", 0, namespace, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestRedisCacheGetSet(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestRedisCache(t, mr, "test")
+
+	if err := c.Set("k", map[string]string{"name": "张三"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := c.Get("k", &got); err != nil || got["name"] != "张三" {
+		t.Fatalf("Get = %v, %v, want 张三", got, err)
+	}
+	if !mr.Exists("test:k") {
+		t.Error("key not stored under the namespace")
+	}
+
+	mr.FastForward(2 * time.Second)
+	if err := c.Get("k", &got); err == nil {
+		t.Error("Get after TTL succeeded, want key not found")
+	}
+	if c.Exists("k") {
+		t.Error("Exists after TTL = true")
+	}
+}
+
+func TestRedisCacheDefaultTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestRedisCache(t, mr, "test")
+
+	if err := c.Set("k", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL("test:k"); ttl != time.Minute {
+		t.Errorf("TTL = %v, want the default %v", ttl, time.Minute)
+	}
+}
+
+func TestRedisCacheSetNX(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestRedisCache(t, mr, "test")
+
+	if set, err := c.SetNX("event:1", true, time.Second); err != nil || !set {
+		t.Fatalf("first SetNX = %v, %v, want true", set, err)
+	}
+	if set, err := c.SetNX("event:1", true, time.Second); err != nil || set {
+		t.Fatalf("second SetNX = %v, %v, want false", set, err)
+	}
+
+	mr.FastForward(2 * time.Second)
+	if set, err := c.SetNX("event:1", true, time.Second); err != nil || !set {
+		t.Fatalf("SetNX after TTL = %v, %v, want true", set, err)
+	}
+}
+
+// 两个副本同时收到同一事件，只有一个能记录成功
+func TestRedisCacheSetNXAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	replicas := []Cache{newTestRedisCache(t, mr, "seen"), newTestRedisCache(t, mr, "seen")}
+
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(c Cache) {
+			defer wg.Done()
+			set, err := c.SetNX("event:1", true, time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			if set {
+				wins.Add(1)
+			}
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+	if n := wins.Load(); n != 1 {
+		t.Errorf("%d SetNX calls succeeded, want 1", n)
+	}
+}
+
+func TestRedisCacheNamespaces(t *testing.T) {
+	mr := miniredis.RunT(t)
+	a := newTestRedisCache(t, mr, "a")
+	b := newTestRedisCache(t, mr, "b")
+
+	for _, key := range []string{"user:1", "user:2", "group:1"} {
+		if err := a.Set(key, key, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Set("user:1", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := a.DeletePrefix("user:"); err != nil || n != 2 {
+		t.Fatalf("DeletePrefix = %d, %v, want 2", n, err)
+	}
+	if a.Exists("user:1") || !a.Exists("group:1") {
+		t.Error("DeletePrefix removed the wrong keys")
+	}
+	if !b.Exists("user:1") {
+		t.Error("DeletePrefix removed a key of another namespace")
+	}
+	if got := a.Stats().Entries; got != 1 {
+		t.Errorf("Stats().Entries = %d, want 1", got)
+	}
+
+	if err := a.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Exists("group:1") || !b.Exists("user:1") {
+		t.Error("Clear must only remove the keys of its namespace")
+	}
+}
+
+// 前缀中的通配符按字面匹配
+func TestRedisCacheDeletePrefixEscapesPattern(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestRedisCache(t, mr, "test")
+
+	for _, key := range []string{"a*b:1", "axb:1"} {
+		if err := c.Set(key, 1, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := c.DeletePrefix("a*b:"); err != nil || n != 1 {
+		t.Fatalf("DeletePrefix = %d, %v, want 1", n, err)
+	}
+	if !c.Exists("axb:1") {
+		t.Error("wildcard in prefix matched another key")
+	}
+}
+
+func TestRedisCacheConnectionFailure(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	if _, err := NewRedisCache(addr, "", 0, "test", 0); err == nil {
+		t.Fatal("NewRedisCache succeeded without a server")
+	}
+}
+
+func TestMemoryCacheSetNX(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+
+	if set, err := c.SetNX("k", 1, time.Minute); err != nil || !set {
+		t.Fatalf("first SetNX = %v, %v, want true", set, err)
+	}
+	if set, err := c.SetNX("k", 2, time.Minute); err != nil || set {
+		t.Fatalf("second SetNX = %v, %v, want false", set, err)
+	}
+	var got int
+	if err := c.Get("k", &got); err != nil || got != 1 {
+		t.Errorf("Get = %d, %v, want the first value 1", got, err)
+	}
+
+	// 过期的项视为不存在
+	if err := c.Set("old", 1, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if set, err := c.SetNX("old", 2, time.Minute); err != nil || !set {
+		t.Fatalf("SetNX on expired key = %v, %v, want true", set, err)
+	}
+}
+
+func TestRedisCacheGetDel(t *testing.T) {
+	mr := miniredis.RunT(t)
+	replicas := []Cache{newTestRedisCache(t, mr, "pending"), newTestRedisCache(t, mr, "pending")}
+	if err := replicas[0].Set("u1:thread1", "confirm", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个副本同时收到“确认”，只有一个取到待确认的操作
+	var got atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(c Cache) {
+			defer wg.Done()
+			var v string
+			if c.GetDel("u1:thread1", &v) == nil && v == "confirm" {
+				got.Add(1)
+			}
+		}(replicas[i%len(replicas)])
+	}
+	wg.Wait()
+	if n := got.Load(); n != 1 {
+		t.Errorf("%d GetDel calls got the value, want 1", n)
+	}
+	if replicas[0].Exists("u1:thread1") {
+		t.Error("GetDel left the key")
+	}
+}
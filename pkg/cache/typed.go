@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Typed is a Cache holding values of a single type T. Values set in this process are returned as
+// they were stored, without the JSON round trip of Cache.Get; JSON is only used for values loaded
+// from the persistence file and for stores that do not keep values in memory.
+// Returned values share memory with the cached ones, so pointers, maps and slices must not be modified
+type Typed[T any] struct {
+	store Cache
+}
+
+// NewTyped returns a typed view of store; store should only be used through it afterwards
+func NewTyped[T any](store Cache) *Typed[T] {
+	return &Typed[T]{store: store}
+}
+
+// RawGetter is implemented by stores keeping values in memory; Typed uses it to return stored
+// values without copying them
+type RawGetter interface {
+	// GetRaw returns the value stored for key as it is, without copying it
+	GetRaw(key string) (interface{}, error)
+
+	// GetDelRaw returns the value stored for key as it is and removes it in one atomic step
+	GetDelRaw(key string) (interface{}, error)
+}
+
+// Get returns the value of key, or false when it is missing, expired or not a T
+func (t *Typed[T]) Get(key string) (T, bool) {
+	raw, ok := t.store.(RawGetter)
+	if !ok {
+		var value T
+		return value, t.store.Get(key, &value) == nil
+	}
+	return typedValue[T](raw.GetRaw(key))
+}
+
+// GetDel returns the value of key and removes it in one atomic step, or false when it is
+// missing, expired or not a T
+func (t *Typed[T]) GetDel(key string) (T, bool) {
+	raw, ok := t.store.(RawGetter)
+	if !ok {
+		var value T
+		return value, t.store.GetDel(key, &value) == nil
+	}
+	return typedValue[T](raw.GetDelRaw(key))
+}
+
+// typedValue 把 store 保存的值转为 T，保存的就是 T 时直接返回
+func typedValue[T any](stored interface{}, err error) (T, bool) {
+	if err != nil {
+		var value T
+		return value, false
+	}
+	if typed, ok := stored.(T); ok {
+		return typed, true
+	}
+	// 从文件加载的值是 map 等通用类型，转换一次
+	return convertTo[T](stored)
+}
+
+// convertTo 通过 JSON 把 from 转换为 T；单独成函数，避免命中路径上的变量逃逸到堆上
+func convertTo[T any](from interface{}) (T, bool) {
+	var value T
+	if err := convert(from, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// Set sets the value of key; a non-positive ttl uses the store's default TTL
+func (t *Typed[T]) Set(key string, value T, ttl time.Duration) error {
+	return t.store.Set(key, value, ttl)
+}
+
+// SetNX sets the value of key only when it is missing or expired and reports whether it was set
+func (t *Typed[T]) SetNX(key string, value T, ttl time.Duration) (bool, error) {
+	return t.store.SetNX(key, value, ttl)
+}
+
+// Delete removes key
+func (t *Typed[T]) Delete(key string) error {
+	return t.store.Delete(key)
+}
+
+// DeletePrefix removes every key starting with prefix and returns how many were removed
+func (t *Typed[T]) DeletePrefix(prefix string) (int, error) {
+	return t.store.DeletePrefix(prefix)
+}
+
+// Exists checks if a key exists
+func (t *Typed[T]) Exists(key string) bool {
+	return t.store.Exists(key)
+}
+
+// Close closes the underlying store
+func (t *Typed[T]) Close() error {
+	return t.store.Close()
+}
+
+// convert 通过 JSON 把 from 转换为 to 指向的类型
+func convert(from interface{}, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %v", err)
+	}
+	return json.Unmarshal(data, to)
+}
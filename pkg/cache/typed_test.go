@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type typedTestValue struct {
+	Name   string
+	Amount float64
+	Tags   []string
+}
+
+var typedTestSample = typedTestValue{Name: "lunch", Amount: 35, Tags: []string{"food", "work"}}
+
+// 内存中的值直接返回，命中时不分配内存
+func TestTypedGetNoAlloc(t *testing.T) {
+	store := NewMemoryCache()
+	defer store.Close()
+	typed := NewTyped[typedTestValue](store)
+	if err := typed.Set("k", typedTestSample, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, ok := typed.Get("k"); !ok {
+			t.Fatal("Get missed")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Typed.Get allocates %.0f times per hit, want 0", allocs)
+	}
+}
+
+// 从文件加载的值是通用类型，仍能转换为 T
+func TestTypedGetLoadedValue(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.json")
+	first := NewUserMappingCache(file, Options{})
+	if err := NewTyped[typedTestValue](first).Set("k", typedTestSample, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	reloaded := NewUserMappingCache(file, Options{})
+	defer reloaded.Close()
+	typed := NewTyped[typedTestValue](reloaded)
+	got, ok := typed.GetDel("k")
+	if !ok || got.Name != typedTestSample.Name || len(got.Tags) != 2 {
+		t.Fatalf("GetDel = %+v, %v, want the stored value", got, ok)
+	}
+	if _, ok := typed.Get("k"); ok {
+		t.Error("Get after GetDel found the key")
+	}
+}
+
+func BenchmarkTypedGet(b *testing.B) {
+	store := NewMemoryCache()
+	defer store.Close()
+	typed := NewTyped[typedTestValue](store)
+	_ = typed.Set("k", typedTestSample, time.Hour)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		typed.Get("k")
+	}
+}
+
+// 对照：Cache.Get 经过一次 JSON 编解码
+func BenchmarkCacheGet(b *testing.B) {
+	store := NewMemoryCache()
+	defer store.Close()
+	_ = store.Set("k", typedTestSample, time.Hour)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var value typedTestValue
+		_ = store.Get("k", &value)
+	}
+}
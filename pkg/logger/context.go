@@ -0,0 +1,49 @@
+package logger
+
+import "context"
+
+// contextKey is the context key for the request-scoped logger
+type contextKey struct{}
+
+// correlationIDKey is the context key for the correlation ID
+type correlationIDKey struct{}
+
+// CorrelationIDField is the field name used for correlation IDs
+const CorrelationIDField = "cid"
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the global logger when there is none.
+// With a component name the logger keeps its fields but uses the component's level
+func FromContext(ctx context.Context, component ...string) Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+			if lg, ok := l.(*logger); ok && len(component) > 0 {
+				return lg.forComponent(component[0])
+			}
+			return l
+		}
+	}
+	return GetLogger(component...)
+}
+
+// WithCorrelationID returns a copy of ctx whose logger tags every line with the correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, correlationIDKey{}, id)
+	return NewContext(ctx, FromContext(ctx).WithField(CorrelationIDField, id))
+}
+
+// CorrelationID returns the correlation ID set by WithCorrelationID, or ""
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
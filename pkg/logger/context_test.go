@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+// captureOutput 将日志输出重定向到缓冲区，测试结束后恢复
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+// 同一条消息经过的各层都带有相同的关联 ID
+func TestWithCorrelationID(t *testing.T) {
+	buf := captureOutput(t)
+	ctx := WithCorrelationID(context.Background(), "ev_123")
+	if got := CorrelationID(ctx); got != "ev_123" {
+		t.Fatalf("CorrelationID = %q, want ev_123", got)
+	}
+
+	FromContext(ctx, "feishu").Info("handler line")
+	FromContext(ctx, "usecase").WithField("user", "张三").Info("usecase line")
+	FromContext(context.Background()).Info("unrelated line")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	for _, line := range lines[:2] {
+		if !strings.Contains(line, "[cid=ev_123]") {
+			t.Errorf("line %q misses the correlation ID", line)
+		}
+	}
+	if !strings.Contains(lines[1], "[cid=ev_123][user=张三]usecase line") {
+		t.Errorf("line %q, want the correlation ID before the added field", lines[1])
+	}
+	if strings.Contains(lines[2], "cid=") {
+		t.Errorf("line %q without a correlation ID has one", lines[2])
+	}
+}
+
+func TestWithCorrelationIDJSON(t *testing.T) {
+	buf := captureOutput(t)
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+
+	FromContext(WithCorrelationID(context.Background(), "ev_456"), "ai").Warn("model slow")
+	var line map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &line); err != nil {
+		t.Fatalf("output %q is not JSON: %v", buf.String(), err)
+	}
+	if line[CorrelationIDField] != "ev_456" || line["msg"] != "model slow" || line["level"] != "warn" {
+		t.Errorf("line = %v, want cid ev_456", line)
+	}
+}
+
+// 空 ID 不修改上下文
+func TestWithCorrelationIDEmpty(t *testing.T) {
+	ctx := context.Background()
+	if got := WithCorrelationID(ctx, ""); got != ctx {
+		t.Error("WithCorrelationID with an empty ID returned a new context")
+	}
+	var missing context.Context
+	if got := CorrelationID(missing); got != "" {
+		t.Errorf("CorrelationID of a nil context = %q", got)
+	}
+}
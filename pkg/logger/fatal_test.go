@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Fatal 只写日志并返回，测试能继续执行，延迟调用照常运行
+func TestFatalReturns(t *testing.T) {
+	buf := captureOutput(t)
+	var hooked []string
+	SetErrorHook(func(component, msg string) { hooked = append(hooked, msg) })
+	defer SetErrorHook(nil)
+
+	deferred := false
+	func() {
+		defer func() { deferred = true }()
+		GetLogger("fatal_test").Fatal("text fatal %d", 1)
+	}()
+	if !deferred {
+		t.Fatal("deferred call skipped after Fatal")
+	}
+
+	SetFormat(FormatJSON)
+	GetLogger("fatal_test").Fatal("json fatal")
+	SetFormat(FormatText)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "[FATAL]text fatal 1") {
+		t.Errorf("text line = %q", lines[0])
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &line); err != nil || line["level"] != "fatal" || line["msg"] != "json fatal" {
+		t.Errorf("JSON line = %q, %v", lines[1], err)
+	}
+	// 告警钩子同样收到 Fatal 日志
+	if len(hooked) != 2 {
+		t.Errorf("error hook called %d times, want 2", len(hooked))
+	}
+}
+
+// FatalAndExit 写日志后以状态码 1 退出，在子进程中运行
+func TestFatalAndExit(t *testing.T) {
+	if os.Getenv("LOGGER_FATAL_AND_EXIT") == "1" {
+		FatalAndExit("startup failed: %s", "port in use")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalAndExit$")
+	cmd.Env = append(os.Environ(), "LOGGER_FATAL_AND_EXIT=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("process exited with %v, want status 1; output %q", err, out)
+	}
+	if !strings.Contains(string(out), "[FATAL]startup failed: port in use") {
+		t.Errorf("output %q misses the fatal line", out)
+	}
+}
@@ -0,0 +1,12 @@
+package logger
+
+// SetErrorHook registers fn to be called with the component and the redacted message of
+// every Error and Fatal line, whatever the configured level; nil removes it. fn runs on
+// the logging goroutine after the line is written and may itself log
+func SetErrorHook(fn func(component, msg string)) {
+	if lg, ok := GetLogger().(*logger); ok {
+		lg.out.mu.Lock()
+		lg.out.errorHook = fn
+		lg.out.mu.Unlock()
+	}
+}
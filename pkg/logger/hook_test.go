@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+// 告警钩子只收到 Error 和 Fatal 日志，不受级别影响，钩子内部可以再写日志
+func TestErrorHook(t *testing.T) {
+	restoreLevels(t)
+	buf := captureOutput(t)
+	SetLogLevel("hook_test=fatal")
+
+	var hooked []string
+	SetErrorHook(func(component, msg string) {
+		hooked = append(hooked, component+": "+msg)
+		GetLogger("alert").Info("hook saw %s", msg)
+	})
+	defer SetErrorHook(nil)
+
+	log := GetLogger("hook_test")
+	log.Debug("debug line")
+	log.Info("info line")
+	log.Warn("warn line")
+	log.Error("write failed: %d", 1)
+	log.WithField("table", "tbl").Fatal("fatal line")
+
+	if want := []string{"hook_test: write failed: 1", "hook_test: fatal line"}; strings.Join(hooked, "|") != strings.Join(want, "|") {
+		t.Errorf("hook received %q, want %q", hooked, want)
+	}
+	if out := buf.String(); !strings.Contains(out, "hook saw write failed: 1") {
+		t.Errorf("output %q misses the line logged by the hook", out)
+	}
+}
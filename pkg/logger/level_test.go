@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// restoreLevels 测试结束后恢复全局日志器的级别
+func restoreLevels(t *testing.T) {
+	t.Helper()
+	lg := GetLogger().(*logger)
+	lg.out.mu.Lock()
+	level, levels := lg.out.level, make(map[string]LogLevel, len(lg.out.levels))
+	for k, v := range lg.out.levels {
+		levels[k] = v
+	}
+	lg.out.mu.Unlock()
+	t.Cleanup(func() {
+		lg.out.mu.Lock()
+		lg.out.level, lg.out.levels = level, levels
+		lg.out.mu.Unlock()
+	})
+}
+
+func TestSetLevels(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string // 依次应用，模拟 LOG_LEVEL 之后再应用 LOG_LEVELS
+		want  map[string]LogLevel
+	}{
+		{
+			name:  "plain level",
+			specs: []string{"debug"},
+			want:  map[string]LogLevel{"": LevelDebug, "feishu": LevelDebug},
+		},
+		{
+			name:  "per component",
+			specs: []string{"feishu=debug,ai=info,default=warn"},
+			want:  map[string]LogLevel{"": LevelWarn, "feishu": LevelDebug, "ai": LevelInfo, "usecase": LevelWarn},
+		},
+		{
+			name:  "components keep their level",
+			specs: []string{"info", "feishu=debug", "error"},
+			want:  map[string]LogLevel{"": LevelError, "feishu": LevelDebug, "ai": LevelError},
+		},
+		{
+			name:  "case and spaces",
+			specs: []string{" Feishu = DEBUG , DEFAULT=Warn "},
+			want:  map[string]LogLevel{"": LevelWarn, "feishu": LevelDebug},
+		},
+		{
+			name:  "later entries win",
+			specs: []string{"ai=debug,ai=error"},
+			want:  map[string]LogLevel{"": LevelInfo, "ai": LevelError},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &output{level: LevelInfo, levels: make(map[string]LogLevel)}
+			for _, spec := range tt.specs {
+				if invalid := o.setLevels(spec); len(invalid) != 0 {
+					t.Fatalf("setLevels(%q) rejected %v", spec, invalid)
+				}
+			}
+			for component, want := range tt.want {
+				if got := o.levelFor(component); got != want {
+					t.Errorf("levelFor(%q) = %s, want %s", component, levelFlags[got], levelFlags[want])
+				}
+			}
+		})
+	}
+}
+
+// 无效的项被忽略并返回，其余项照常生效
+func TestInvalidLevels(t *testing.T) {
+	if invalid := InvalidLevels("feishu=debug,verbose,=info,ai=loud,,default=warn"); !reflect.DeepEqual(invalid, []string{"verbose", "=info", "ai=loud"}) {
+		t.Errorf("InvalidLevels = %q", invalid)
+	}
+	if invalid := InvalidLevels(""); invalid != nil {
+		t.Errorf("InvalidLevels(\"\") = %q, want none", invalid)
+	}
+
+	o := &output{level: LevelInfo, levels: make(map[string]LogLevel)}
+	o.setLevels("ai=loud,feishu=debug")
+	if o.levelFor("ai") != LevelInfo || o.levelFor("feishu") != LevelDebug {
+		t.Errorf("levels = %v, want only the valid entry applied", o.levels)
+	}
+}
+
+// 命名日志器和带上下文的日志器按各自组件的级别输出
+func TestComponentLoggers(t *testing.T) {
+	restoreLevels(t)
+	buf := captureOutput(t)
+	SetLogLevel("feishu=debug,ai=info,default=warn")
+
+	ctx := WithCorrelationID(context.Background(), "ev_1")
+	FromContext(ctx, "feishu").Debug("feishu debug")
+	GetLogger("AI").Debug("ai debug")
+	GetLogger("ai").Info("ai info")
+	GetLogger().Info("default info")
+	GetLogger(DefaultComponent).Warn("default warn")
+	GetLogger("scheduler").Info("scheduler info")
+
+	out := buf.String()
+	for _, want := range []string{"[cid=ev_1]feishu debug", "ai info", "default warn"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %q misses %q", out, want)
+		}
+	}
+	for _, unwanted := range []string{"ai debug", "default info", "scheduler info"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output %q contains %q", out, unwanted)
+		}
+	}
+
+	// 子日志器保留组件
+	buf.Reset()
+	GetLogger("feishu").WithField("user", "张三").Debug("child debug")
+	if !strings.Contains(buf.String(), "child debug") {
+		t.Errorf("child logger lost its component: %q", buf.String())
+	}
+}
+
+func TestComponentJSON(t *testing.T) {
+	restoreLevels(t)
+	buf := captureOutput(t)
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+
+	GetLogger("feishu").Warn("with component")
+	GetLogger().Warn("without component")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	var named, plain map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &named); err != nil || named["component"] != "feishu" {
+		t.Errorf("line %q, %v, want component feishu", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &plain); err != nil || plain["component"] != nil {
+		t.Errorf("line %q, %v, want no component", lines[1], err)
+	}
+}
@@ -1,9 +1,12 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,19 +34,56 @@ var (
 	}
 )
 
+// DefaultComponent is the component of loggers obtained without a name
+const DefaultComponent = "default"
+
+// Output formats accepted by SetFormat
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Fields holds the structured fields attached by WithFields
+type Fields map[string]interface{}
+
 // Logger interface
 type Logger interface {
 	Debug(format string, v ...interface{})
 	Info(format string, v ...interface{})
 	Warn(format string, v ...interface{})
 	Error(format string, v ...interface{})
+	// Fatal logs at the fatal level and returns; it does not exit the process, so deferred
+	// cleanup still runs. Use FatalAndExit where the process must stop
 	Fatal(format string, v ...interface{})
+	// WithField returns a child logger that prefixes every line with key=value
+	WithField(key string, value interface{}) Logger
+	// WithFields returns a child logger carrying all of fields, added in key order
+	WithFields(fields Fields) Logger
+}
+
+// output holds the state shared by a logger and its children
+type output struct {
+	level LogLevel
+	// levels 按组件覆盖的日志级别，未配置的组件使用 level
+	levels map[string]LogLevel
+	json   bool
+	redact redactor
+	// errorHook 每条 Error 及以上级别的日志都会调用，见 SetErrorHook
+	errorHook func(component, msg string)
+	mu        sync.Mutex
+}
+
+// field 子日志记录器附加的一个字段
+type field struct {
+	key   string
+	value interface{}
 }
 
 // logger implementation
 type logger struct {
-	level LogLevel
-	mu    sync.Mutex
+	out       *output
+	component string  // 组件名，决定使用的日志级别；空表示默认组件
+	fields    []field // text 格式渲染为等级后的 [k=v][k=v]，json 格式为同名的键
 }
 
 var (
@@ -51,53 +91,211 @@ var (
 	once     sync.Once
 )
 
-// GetLogger returns a singleton logger instance
-func GetLogger() Logger {
+// GetLogger returns the shared logger. With a component name, such as "feishu", the logger
+// uses the level configured for that component instead of the default level
+func GetLogger(component ...string) Logger {
 	once.Do(func() {
-		levelStr := strings.ToLower(os.Getenv("LOG_LEVEL"))
-		if levelStr == "" {
-			levelStr = "info"
-		}
-
-		level, ok := levelMap[levelStr]
-		if !ok {
-			level = LevelInfo
-		}
-
-		instance = &logger{level: level}
+		out := &output{level: LevelInfo, levels: make(map[string]LogLevel), json: strings.EqualFold(os.Getenv("LOG_FORMAT"), FormatJSON)}
+		out.setLevels(os.Getenv("LOG_LEVEL"))
+		out.setLevels(os.Getenv("LOG_LEVELS"))
+		instance = &logger{out: out}
 	})
+	if len(component) > 0 {
+		if lg, ok := instance.(*logger); ok {
+			return lg.forComponent(component[0])
+		}
+	}
 	return instance
 }
 
-// SetLogLevel sets the log level
+// SetLogLevel sets the default log level ("debug"), or per-component levels with the syntax
+// "feishu=debug,ai=info,default=warn". Components not listed keep their current level
 func SetLogLevel(level string) {
-	if l, ok := levelMap[strings.ToLower(level)]; ok {
-		if lg, ok := instance.(*logger); ok {
-			lg.level = l
+	lg, ok := GetLogger().(*logger)
+	if !ok {
+		return
+	}
+	lg.out.mu.Lock()
+	invalid := lg.out.setLevels(level)
+	lg.out.mu.Unlock()
+
+	for _, entry := range invalid {
+		lg.Warn("Ignoring invalid log level %q", entry)
+	}
+}
+
+// InvalidLevels returns the entries of a LOG_LEVEL or LOG_LEVELS value that SetLogLevel would ignore
+func InvalidLevels(spec string) []string {
+	o := &output{levels: make(map[string]LogLevel)}
+	return o.setLevels(spec)
+}
+
+// setLevels 解析 "debug" 或 "feishu=debug,default=warn" 形式的级别配置，返回无法解析的项
+func (o *output) setLevels(spec string) []string {
+	var invalid []string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		component, levelStr := DefaultComponent, entry
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			component, levelStr = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		}
+		level, ok := levelMap[strings.ToLower(levelStr)]
+		if !ok || component == "" {
+			invalid = append(invalid, entry)
+			continue
+		}
+		if component == DefaultComponent {
+			o.level = level
+		} else {
+			o.levels[component] = level
 		}
 	}
+	return invalid
+}
+
+// levelFor 返回组件生效的日志级别；调用方持有 mu
+func (o *output) levelFor(component string) LogLevel {
+	if level, ok := o.levels[component]; ok {
+		return level
+	}
+	return o.level
+}
+
+// forComponent 返回使用组件级别、保留已有字段的日志记录器
+func (l *logger) forComponent(component string) *logger {
+	component = strings.ToLower(component)
+	if component == DefaultComponent {
+		component = ""
+	}
+	return &logger{out: l.out, component: component, fields: l.fields}
+}
+
+// FatalAndExit logs at the fatal level and exits with status 1 without running deferred calls.
+// It is meant for startup failures in main, before there is anything to clean up
+func FatalAndExit(format string, v ...interface{}) {
+	GetLogger().Fatal(format, v...)
+	os.Exit(1)
+}
+
+// SetFormat sets the output format: text (the default) or json, one object per line
+func SetFormat(format string) {
+	if lg, ok := GetLogger().(*logger); ok {
+		lg.out.mu.Lock()
+		lg.out.json = strings.EqualFold(format, FormatJSON)
+		lg.out.mu.Unlock()
+	}
 }
 
 func (l *logger) log(level LogLevel, format string, v ...interface{}) {
-	if level < l.level {
+	msg := fmt.Sprintf(format, v...)
+	l.out.mu.Lock()
+	hook := l.out.errorHook
+	l.write(level, msg)
+	redacted := l.out.redact.apply(msg)
+	l.out.mu.Unlock()
+
+	// 钩子在释放锁之后调用，其中可以继续写日志
+	if hook != nil && level >= LevelError {
+		component := l.component
+		if component == "" {
+			component = DefaultComponent
+		}
+		hook(component, redacted)
+	}
+}
+
+// write 按格式写出一行日志；调用方持有 out.mu
+func (l *logger) write(level LogLevel, msg string) {
+	if level < l.out.levelFor(l.component) {
 		return
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	redact := &l.out.redact
+	if l.out.json {
+		// JSON 每行一个对象，不带标准库 log 的时间前缀；字段编码后再遮盖一次
+		log.Writer().Write([]byte(redact.apply(string(l.jsonLine(level, redact.apply(msg))))))
+		return
+	}
 
-	prefix := levelFlags[level]
-	timestamp := getTimestamp()
-	msg := fmt.Sprintf(format, v...)
+	var fields strings.Builder
+	for _, f := range l.fields {
+		fmt.Fprintf(&fields, "[%s=%v]", f.key, redact.field(level, f))
+	}
+	logStr := redact.apply(fmt.Sprintf("[%s][%s]%s%s", getTimestamp(), levelFlags[level], fields.String(), msg))
+	log.Println(logStr)
+}
+
+// jsonLine 按 time、level、msg、字段的顺序编码一行 JSON
+func (l *logger) jsonLine(level LogLevel, msg string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, time.Now().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, strings.ToLower(levelFlags[level]))
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, msg)
+	if l.component != "" {
+		buf.WriteString(`,"component":`)
+		writeJSON(&buf, l.component)
+	}
+	for _, f := range l.fields {
+		buf.WriteByte(',')
+		writeJSON(&buf, f.key)
+		buf.WriteByte(':')
+		writeJSON(&buf, l.out.redact.field(level, f))
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// writeJSON 写入值的 JSON 编码；error 写入其消息，无法编码的值写入 %v 的结果
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	buf.Write(data)
+}
+
+func (l *logger) WithField(key string, value interface{}) Logger {
+	return l.with([]field{{key: key, value: value}})
+}
+
+func (l *logger) WithFields(fields Fields) Logger {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-	logStr := fmt.Sprintf("[%s][%s]%s", timestamp, prefix, msg)
+	added := make([]field, len(keys))
+	for i, key := range keys {
+		added[i] = field{key: key, value: fields[key]}
+	}
+	return l.with(added)
+}
 
-	switch level {
-	case LevelFatal:
-		log.Fatal(logStr)
-	default:
-		log.Println(logStr)
+// with 返回附加了字段的子日志记录器；与已有字段同名时替换原值，保持原来的位置
+func (l *logger) with(added []field) Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+len(added))
+	copy(fields, l.fields)
+next:
+	for _, f := range added {
+		for i := range fields {
+			if fields[i].key == f.key {
+				fields[i].value = f.value
+				continue next
+			}
+		}
+		fields = append(fields, f)
 	}
+	return &logger{out: l.out, component: l.component, fields: fields}
 }
 
 func (l *logger) Debug(format string, v ...interface{}) {
@@ -116,6 +314,7 @@ func (l *logger) Error(format string, v ...interface{}) {
 	l.log(LevelError, format, v...)
 }
 
+// Fatal 只记录日志，不退出进程：以前调用 log.Fatal 会跳过 defer 的清理（如优雅退出、缓存落盘、账单队列写入）
 func (l *logger) Fatal(format string, v ...interface{}) {
 	l.log(LevelFatal, format, v...)
 }
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretMask 替换日志中出现的密钥
+const secretMask = "******"
+
+// minSecretLength 短于该长度的值不作为密钥替换，避免误伤日志中的普通文本
+const minSecretLength = 6
+
+var (
+	// bearerPattern Authorization 头中的令牌
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	// platformIDPattern 飞书的 open_id（ou_）和 union_id（on_），只保留前几位
+	platformIDPattern = regexp.MustCompile(`\b(o[un]_[0-9a-zA-Z]{4})[0-9a-zA-Z]+`)
+)
+
+// userNameFields 值为用户名的字段，开启 PII 脱敏时 Debug 日志中以哈希代替
+var userNameFields = map[string]bool{"user": true, "user_name": true}
+
+// redactor 遮盖日志中的密钥和个人标识，由 output.mu 保护
+type redactor struct {
+	secrets  []string
+	replacer *strings.Replacer
+	// pii 为 true 时截断 open_id/union_id，Debug 日志中的用户名字段以哈希代替
+	pii bool
+}
+
+// AddSecrets registers values, such as API keys and app secrets, that are replaced by
+// "******" wherever they appear in a log line. Empty and very short values are ignored
+func AddSecrets(values ...string) {
+	lg, ok := GetLogger().(*logger)
+	if !ok {
+		return
+	}
+	lg.out.mu.Lock()
+	defer lg.out.mu.Unlock()
+
+	r := &lg.out.redact
+	for _, v := range values {
+		if len(strings.TrimSpace(v)) < minSecretLength {
+			continue
+		}
+		r.secrets = append(r.secrets, v)
+		// JSON 格式中字段值经过转义，同时遮盖转义后的形式
+		if data, err := json.Marshal(v); err == nil {
+			if escaped := string(data[1 : len(data)-1]); escaped != v {
+				r.secrets = append(r.secrets, escaped)
+			}
+		}
+	}
+	// 较长的密钥优先匹配，一个密钥是另一个的前缀时不会只遮盖一部分
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	pairs := make([]string, 0, 2*len(r.secrets))
+	for _, s := range r.secrets {
+		pairs = append(pairs, s, secretMask)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// SetRedactPII turns on truncating Feishu open_id/union_id values to a short prefix
+// and hashing user name fields in Debug lines
+func SetRedactPII(enabled bool) {
+	if lg, ok := GetLogger().(*logger); ok {
+		lg.out.mu.Lock()
+		lg.out.redact.pii = enabled
+		lg.out.mu.Unlock()
+	}
+}
+
+// apply 遮盖文本中的密钥、Bearer 令牌，开启 PII 脱敏时截断用户 ID
+func (r *redactor) apply(s string) string {
+	if r.replacer != nil {
+		s = r.replacer.Replace(s)
+	}
+	s = bearerPattern.ReplaceAllString(s, "${1}"+secretMask)
+	if r.pii {
+		s = platformIDPattern.ReplaceAllString(s, "${1}…")
+	}
+	return s
+}
+
+// field 返回字段写入日志前的值：开启 PII 脱敏时 Debug 日志中的用户名以哈希代替，便于关联同一用户而不暴露名字
+func (r *redactor) field(level LogLevel, f field) interface{} {
+	if !r.pii || level != LevelDebug || !userNameFields[f.key] {
+		return f.value
+	}
+	name, ok := f.value.(string)
+	if !ok || name == "" || platformIDPattern.MatchString(name) {
+		return f.value
+	}
+	return HashName(name)
+}
+
+// HashName returns a short stable hash of a user name, such as "u:1a2b3c4d", for logs
+// that need to tell users apart without recording their names
+func HashName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "u:" + hex.EncodeToString(sum[:4])
+}
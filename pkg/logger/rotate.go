@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat 轮转出的旧文件名后缀，按字典序即按时间排序
+const backupTimeFormat = "20060102-150405.000"
+
+// RotateOptions controls when a RotatingFile rolls over and which old files it keeps.
+// Zero values disable the corresponding limit
+type RotateOptions struct {
+	MaxSizeMB  int // 文件超过该大小（MB）时轮转
+	MaxBackups int // 最多保留的旧文件数
+	MaxAgeDays int // 旧文件保留的天数
+}
+
+// RotatingFile is an io.Writer appending to a file that is renamed to
+// "<path>.<time>" and replaced by a new file once it grows past MaxSizeMB
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// maxSize 轮转阈值（字节），<=0 表示不按大小轮转
+	maxSize int64
+}
+
+// NewRotatingFile opens (or creates) the log file at path, appending to it
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	f := &RotatingFile{path: path, opts: opts, maxSize: int64(opts.MaxSizeMB) * 1024 * 1024}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends p to the file, rolling over first when p would take it past the size limit.
+// A single write is never split across files
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, fmt.Errorf("log file %s is closed", f.path)
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			// 轮转失败时继续写入当前文件，不丢日志
+			fmt.Fprintf(os.Stderr, "rotate log file %s: %v\n", f.path, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rolls the file over immediately
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate()
+}
+
+// Close closes the file; later writes fail
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open 以追加方式打开日志文件，并记录已有内容的大小
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %v", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file %s: %v", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate 将当前文件改名为带时间后缀的旧文件，打开新文件并清理超出数量或过期的旧文件；调用方持有 mu
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file %s: %v", f.path, err)
+		}
+		f.file = nil
+	}
+	backup := f.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		// 改名失败时重新打开原文件继续写入
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rename log file %s: %v", f.path, err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune 删除超出 MaxBackups 数量或早于 MaxAgeDays 的旧文件
+func (f *RotatingFile) prune() {
+	backups := f.backups()
+	// 从新到旧排列，超出数量的是最旧的文件
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	cutoff := time.Now().AddDate(0, 0, -f.opts.MaxAgeDays)
+	for i, backup := range backups {
+		expired := f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups
+		if !expired && f.opts.MaxAgeDays > 0 {
+			if info, err := os.Stat(backup); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+		if expired {
+			if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "remove old log file %s: %v\n", backup, err)
+			}
+		}
+	}
+}
+
+// backups 列出轮转出的旧文件
+func (f *RotatingFile) backups() []string {
+	matches, _ := filepath.Glob(f.path + ".*")
+	var backups []string
+	for _, m := range matches {
+		suffix := strings.TrimPrefix(m, f.path+".")
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	return backups
+}
+
+// SetOutput sets where log lines are written, e.g. an io.MultiWriter of os.Stderr and a RotatingFile
+func SetOutput(w io.Writer) {
+	if lg, ok := GetLogger().(*logger); ok {
+		// 与写日志持有同一把锁，切换时不会有写到一半的行
+		lg.out.mu.Lock()
+		defer lg.out.mu.Unlock()
+	}
+	log.SetOutput(w)
+}
@@ -0,0 +1,208 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lineSize 测试写入的每行长度，文件大小是它的整数倍说明没有行被拆开
+const lineSize = 1024
+
+// testLine 构造一行长度为 lineSize 的日志
+func testLine(c byte) []byte {
+	line := bytes.Repeat([]byte{c}, lineSize)
+	line[lineSize-1] = '\n'
+	return line
+}
+
+// rotatedSizes 返回当前文件和各个旧文件的大小
+func rotatedSizes(t *testing.T, f *RotatingFile) (current int64, backups []int64) {
+	t.Helper()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, backup := range f.backups() {
+		info, err := os.Stat(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backups = append(backups, info.Size())
+	}
+	return info.Size(), backups
+}
+
+// 写入超过大小上限后轮转，旧文件和当前文件都不超过上限，也不拆开单次写入
+func TestRotatingFileRollover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "bot.log")
+	f, err := NewRotatingFile(path, RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	const lines = 2500 // 约 2.4 MB，轮转两次
+	for i := 0; i < lines; i++ {
+		if _, err := f.Write(testLine('a' + byte(i%26))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, backups := rotatedSizes(t, f)
+	if len(backups) != 2 {
+		t.Fatalf("%d backups, want 2", len(backups))
+	}
+	total := current
+	for _, size := range append(backups, current) {
+		if size > 1024*1024 || size%lineSize != 0 {
+			t.Errorf("file size %d, want at most 1 MB of whole lines", size)
+		}
+	}
+	for _, size := range backups {
+		total += size
+	}
+	if total != lines*lineSize {
+		t.Errorf("%d bytes written across files, want %d", total, lines*lineSize)
+	}
+}
+
+// 重新打开时追加到已有文件，并计入已有内容的大小
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	if err := os.WriteFile(path, bytes.Repeat(testLine('x'), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewRotatingFile(path, RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := 0; i < 30; i++ {
+		if _, err := f.Write(testLine('y')); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current, backups := rotatedSizes(t, f)
+	if len(backups) != 1 || backups[0] != 1024*lineSize || current != 6*lineSize {
+		t.Errorf("current %d, backups %v, want a rollover at 1 MB", current, backups)
+	}
+}
+
+// 只保留最新的 MaxBackups 个旧文件，并删除超过 MaxAgeDays 的旧文件
+func TestRotatingFilePrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bot.log")
+	// 不属于轮转的文件不被删除
+	other := path + ".keep"
+	if err := os.WriteFile(other, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := path + "." + time.Now().AddDate(0, 0, -40).Format(backupTimeFormat)
+	if err := os.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().AddDate(0, 0, -40)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewRotatingFile(path, RotateOptions{MaxBackups: 2, MaxAgeDays: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("backup older than 30 days kept: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		// 旧文件名精确到毫秒
+		time.Sleep(2 * time.Millisecond)
+		if _, err := f.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backups := f.backups(); len(backups) != 2 {
+		t.Errorf("backups = %v, want the newest 2", backups)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestRotatingFileClose(t *testing.T) {
+	f, err := NewRotatingFile(filepath.Join(t.TempDir(), "bot.log"), RotateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+	if _, err := f.Write([]byte("late\n")); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Write after Close = %v, want an error", err)
+	}
+}
+
+// 多个 goroutine 通过日志器写入时轮转不丢行、不拆行，配合 -race 运行
+func TestRotatingFileConcurrentLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.log")
+	f, err := NewRotatingFile(path, RotateOptions{MaxSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	SetOutput(f)
+	defer SetOutput(os.Stderr)
+
+	const workers, lines = 8, 300
+	payload := strings.Repeat("x", 500)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log := GetLogger("rotate_test")
+			for i := 0; i < lines; i++ {
+				log.Info("%s", payload)
+			}
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	for _, file := range append(f.backups(), path) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			if !strings.HasSuffix(line, payload) {
+				t.Fatalf("broken line in %s: %.80q", file, line)
+			}
+			count++
+		}
+	}
+	if count != workers*lines {
+		t.Errorf("%d lines written, want %d", count, workers*lines)
+	}
+}
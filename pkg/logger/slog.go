@@ -0,0 +1,201 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// slogLevelFatal 对应 LevelFatal 的 slog 级别，slog 没有预定义的 fatal 级别
+const slogLevelFatal = slog.LevelError + 4
+
+// toSlogLevel 将本包的日志级别转换为 slog 级别
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+// fromSlogLevel 将 slog 级别转换为本包的日志级别，介于两个预定义级别之间的按较低的一级处理
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	case level < slogLevelFatal:
+		return LevelError
+	default:
+		return LevelFatal
+	}
+}
+
+// slogHandler 将 slog 记录写入本包的 Logger，属性作为字段
+type slogHandler struct {
+	logger Logger
+	group  string // WithGroup 的前缀，如 "req."
+}
+
+// NewSlogHandler returns an slog.Handler writing records through l, so libraries taking a
+// *slog.Logger share its level, format, redaction and fields. Attributes become fields;
+// groups are flattened into dotted keys such as "req.method"
+func NewSlogHandler(l Logger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// NewSlogLogger returns a *slog.Logger writing through l
+func NewSlogLogger(l Logger) *slog.Logger {
+	return slog.New(NewSlogHandler(l))
+}
+
+// Enabled reports whether l writes records of the level
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	lg, ok := h.logger.(*logger)
+	if !ok {
+		return true
+	}
+	lg.out.mu.Lock()
+	defer lg.out.mu.Unlock()
+	return fromSlogLevel(level) >= lg.out.levelFor(lg.component)
+}
+
+// Handle writes the record with its attributes as fields
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := Fields{}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(fields, h.group, a)
+		return true
+	})
+	// 带关联 ID 的上下文传入 InfoContext 等方法时，日志行同样带有 cid
+	if id := CorrelationID(ctx); id != "" {
+		fields[CorrelationIDField] = id
+	}
+
+	l := h.logger
+	if len(fields) > 0 {
+		l = l.WithFields(fields)
+	}
+	switch fromSlogLevel(r.Level) {
+	case LevelDebug:
+		l.Debug("%s", r.Message)
+	case LevelInfo:
+		l.Info("%s", r.Message)
+	case LevelWarn:
+		l.Warn("%s", r.Message)
+	case LevelError:
+		l.Error("%s", r.Message)
+	default:
+		l.Fatal("%s", r.Message)
+	}
+	return nil
+}
+
+// WithAttrs returns a handler whose records carry attrs
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := Fields{}
+	for _, a := range attrs {
+		addAttr(fields, h.group, a)
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	return &slogHandler{logger: h.logger.WithFields(fields), group: h.group}
+}
+
+// WithGroup returns a handler prefixing later attribute keys with name
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, group: h.group + name + "."}
+}
+
+// addAttr 将 slog 属性加入字段，分组属性展开为带前缀的键
+func addAttr(fields Fields, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(fields, groupPrefix, ga)
+		}
+		return
+	}
+	fields[prefix+a.Key] = a.Value.Any()
+}
+
+// slogLogger implements Logger on top of an slog.Handler
+type slogLogger struct {
+	handler slog.Handler
+}
+
+// FromSlog returns a Logger writing through h, for applications that already configure
+// logging with slog. Fatal is written at slog.LevelError+4 and does not exit
+func FromSlog(h slog.Handler) Logger {
+	return &slogLogger{handler: h}
+}
+
+func (l *slogLogger) log(level LogLevel, format string, v ...interface{}) {
+	ctx := context.Background()
+	slogLevel := toSlogLevel(level)
+	if !l.handler.Enabled(ctx, slogLevel) {
+		return
+	}
+	_ = l.handler.Handle(ctx, slog.NewRecord(time.Now(), slogLevel, fmt.Sprintf(format, v...), 0))
+}
+
+func (l *slogLogger) Debug(format string, v ...interface{}) {
+	l.log(LevelDebug, format, v...)
+}
+
+func (l *slogLogger) Info(format string, v ...interface{}) {
+	l.log(LevelInfo, format, v...)
+}
+
+func (l *slogLogger) Warn(format string, v ...interface{}) {
+	l.log(LevelWarn, format, v...)
+}
+
+func (l *slogLogger) Error(format string, v ...interface{}) {
+	l.log(LevelError, format, v...)
+}
+
+func (l *slogLogger) Fatal(format string, v ...interface{}) {
+	l.log(LevelFatal, format, v...)
+}
+
+func (l *slogLogger) WithField(key string, value interface{}) Logger {
+	return &slogLogger{handler: l.handler.WithAttrs([]slog.Attr{slog.Any(key, value)})}
+}
+
+func (l *slogLogger) WithFields(fields Fields) Logger {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, len(keys))
+	for i, key := range keys {
+		attrs[i] = slog.Any(key, fields[key])
+	}
+	return &slogLogger{handler: l.handler.WithAttrs(attrs)}
+}
@@ -0,0 +1,130 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLevelMapping(t *testing.T) {
+	for level, want := range map[LogLevel]slog.Level{
+		LevelDebug: slog.LevelDebug,
+		LevelInfo:  slog.LevelInfo,
+		LevelWarn:  slog.LevelWarn,
+		LevelError: slog.LevelError,
+		LevelFatal: slog.LevelError + 4,
+	} {
+		if got := toSlogLevel(level); got != want {
+			t.Errorf("toSlogLevel(%s) = %s, want %s", levelFlags[level], got, want)
+		}
+		// 预定义级别来回转换不变
+		if back := fromSlogLevel(want); back != level {
+			t.Errorf("fromSlogLevel(%s) = %s, want %s", want, levelFlags[back], levelFlags[level])
+		}
+	}
+
+	// 介于两个级别之间的按较低的一级处理
+	tests := []struct {
+		in   slog.Level
+		want LogLevel
+	}{
+		{slog.LevelDebug - 4, LevelDebug},
+		{slog.LevelInfo + 2, LevelInfo},
+		{slog.LevelWarn + 1, LevelWarn},
+		{slog.LevelError + 3, LevelError},
+		{slog.LevelError + 8, LevelFatal},
+	}
+	for _, tt := range tests {
+		if got := fromSlogLevel(tt.in); got != tt.want {
+			t.Errorf("fromSlogLevel(%s) = %s, want %s", tt.in, levelFlags[got], levelFlags[tt.want])
+		}
+	}
+}
+
+// slog 记录经过本包日志器输出：级别、属性、分组和关联 ID 都保留
+func TestSlogHandler(t *testing.T) {
+	restoreLevels(t)
+	buf := captureOutput(t)
+	SetLogLevel("slog_test=info")
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+
+	sl := NewSlogLogger(GetLogger("slog_test"))
+	ctx := WithCorrelationID(context.Background(), "ev_9")
+	if sl.Enabled(ctx, slog.LevelDebug) || !sl.Enabled(ctx, slog.LevelInfo) {
+		t.Error("Enabled does not follow the component level")
+	}
+	sl.Debug("filtered")
+	sl.With("service", "api").WithGroup("req").InfoContext(ctx, "request done", "method", "GET", slog.Group("client", "ip", "10.0.0.1"))
+	sl.Warn("slow", "ms", 1200)
+	sl.Error("failed")
+	sl.Log(ctx, slog.LevelError+4, "fatal via slog")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	records := make([]map[string]interface{}, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &records[i]); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+	}
+	for i, want := range []string{"info", "warn", "error", "fatal"} {
+		if records[i]["level"] != want || records[i]["component"] != "slog_test" {
+			t.Errorf("record %d = %v, want level %s", i, records[i], want)
+		}
+	}
+	first := records[0]
+	want := map[string]interface{}{"msg": "request done", "service": "api", "req.method": "GET", "req.client.ip": "10.0.0.1", CorrelationIDField: "ev_9"}
+	for key, value := range want {
+		if first[key] != value {
+			t.Errorf("%s = %v, want %v", key, first[key], value)
+		}
+	}
+	if records[1]["ms"] != float64(1200) {
+		t.Errorf("ms = %v, want 1200", records[1]["ms"])
+	}
+}
+
+// net/http 的 ErrorLog 经过适配器写为 Error 级别
+func TestSlogErrorLogBridge(t *testing.T) {
+	buf := captureOutput(t)
+	errorLog := slog.NewLogLogger(NewSlogHandler(GetLogger("http")), slog.LevelError)
+	errorLog.Printf("http: TLS handshake error from %s: EOF", "10.0.0.1:5000")
+	if out := buf.String(); !strings.Contains(out, "[ERROR]") || !strings.Contains(out, "TLS handshake error from 10.0.0.1:5000") {
+		t.Errorf("output %q, want an ERROR line", out)
+	}
+}
+
+// 在用户提供的 slog.Handler 上构造 Logger
+func TestFromSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := FromSlog(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	l.Debug("filtered")
+	l.WithField("user", "张三").WithFields(Fields{"b": 2, "a": 1}).Info("created %d bills", 3)
+	l.Fatal("fatal does not exit")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), buf.String())
+	}
+	var info, fatal map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info["level"] != "INFO" || info["msg"] != "created 3 bills" || info["user"] != "张三" || info["a"] != float64(1) || info["b"] != float64(2) {
+		t.Errorf("info record = %v", info)
+	}
+	// 字段按键名排序写入，输出顺序稳定
+	if strings.Index(lines[0], `"a":1`) > strings.Index(lines[0], `"b":2`) {
+		t.Errorf("fields out of order in %s", lines[0])
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &fatal); err != nil || fatal["level"] != "ERROR+4" {
+		t.Errorf("fatal record = %v, %v, want level ERROR+4", fatal, err)
+	}
+}
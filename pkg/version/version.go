@@ -0,0 +1,69 @@
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X github.com/wyg1997/LedgerBot/pkg/version.Version=v1.2.0 \
+//	  -X github.com/wyg1997/LedgerBot/pkg/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/wyg1997/LedgerBot/pkg/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Version and Commit left empty fall back to the Go build info (module version and VCS revision)
+var (
+	Version   = ""
+	Commit    = ""
+	BuildTime = ""
+)
+
+// startTime 进程启动时间，用于计算运行时长
+var startTime = time.Now()
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	// 未通过 -ldflags 注入时，使用 go build 记录的模块版本和提交
+	if build, ok := debug.ReadBuildInfo(); ok {
+		// 本地 go build 的模块版本为 (devel)
+		if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, s := range build.Settings {
+			if s.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = s.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if len(info.Commit) > 12 {
+		info.Commit = info.Commit[:12]
+	}
+	return info
+}
+
+// String formats the version and commit, such as "v1.2.0 (a1b2c3d)"
+func (i Info) String() string {
+	if i.Commit == "" {
+		return i.Version
+	}
+	return fmt.Sprintf("%s (%s)", i.Version, i.Commit)
+}
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
@@ -0,0 +1,45 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+// setBuildInfo 模拟 -ldflags -X 注入的构建信息，测试结束后恢复
+func setBuildInfo(t *testing.T, version, commit, buildTime string) {
+	t.Helper()
+	oldVersion, oldCommit, oldBuildTime := Version, Commit, BuildTime
+	Version, Commit, BuildTime = version, commit, buildTime
+	t.Cleanup(func() { Version, Commit, BuildTime = oldVersion, oldCommit, oldBuildTime })
+}
+
+func TestGetInjected(t *testing.T) {
+	setBuildInfo(t, "v1.2.0", "a1b2c3d4e5f6a7b8c9d0", "2026-10-16T14:55:03Z")
+	info := Get()
+	// 提交截取前 12 位
+	want := Info{Version: "v1.2.0", Commit: "a1b2c3d4e5f6", BuildTime: "2026-10-16T14:55:03Z", GoVersion: runtime.Version()}
+	if info != want {
+		t.Errorf("Get() = %+v, want %+v", info, want)
+	}
+	if s := info.String(); s != "v1.2.0 (a1b2c3d4e5f6)" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+// 未注入时使用 go build 记录的信息，测试二进制没有模块版本，显示为 dev
+func TestGetFallback(t *testing.T) {
+	setBuildInfo(t, "", "", "")
+	info := Get()
+	if info.Version != "dev" || info.BuildTime != "" || len(info.Commit) > 12 {
+		t.Errorf("Get() = %+v, want version dev", info)
+	}
+	if s := (Info{Version: "dev"}).String(); s != "dev" {
+		t.Errorf("String() without commit = %q", s)
+	}
+}
+
+func TestUptime(t *testing.T) {
+	if d := Uptime(); d <= 0 {
+		t.Errorf("Uptime() = %s, want positive", d)
+	}
+}
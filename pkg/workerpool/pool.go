@@ -0,0 +1,112 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Pool runs jobs on a fixed number of workers with a bounded queue
+type Pool struct {
+	workers int
+	jobs    chan func()
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	running   atomic.Int64
+	processed atomic.Int64
+	rejected  atomic.Int64
+}
+
+// Stats is a snapshot of the pool state, used for metrics
+type Stats struct {
+	Workers    int   `json:"workers"`
+	QueueSize  int   `json:"queue_size"`
+	QueueDepth int   `json:"queue_depth"` // jobs waiting in the queue
+	Running    int64 `json:"running"`     // jobs currently executing
+	Processed  int64 `json:"processed"`   // jobs finished
+	Rejected   int64 `json:"rejected"`    // jobs rejected because the queue was full
+}
+
+// New creates a pool with the given number of workers and queue capacity
+func New(workers, queueSize int) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &Pool{workers: workers, jobs: make(chan func(), queueSize)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		p.running.Add(1)
+		job()
+		p.running.Add(-1)
+		p.processed.Add(1)
+	}
+}
+
+// Submit queues a job; it returns false when the queue is full or the pool is shut down
+func (p *Pool) Submit(job func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.rejected.Add(1)
+		return false
+	}
+
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		p.rejected.Add(1)
+		return false
+	}
+}
+
+// Shutdown stops accepting jobs and waits for queued and running jobs to finish, or for ctx to be done
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current pool statistics
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:    p.workers,
+		QueueSize:  cap(p.jobs),
+		QueueDepth: len(p.jobs),
+		Running:    p.running.Load(),
+		Processed:  p.processed.Load(),
+		Rejected:   p.rejected.Load(),
+	}
+}